@@ -136,7 +136,7 @@ func init() {
 	// 1: plugs and slots
 	// 2: support for $SLOT()/$PLUG()/$MISSING
 	// 3: support for on-store/on-brand/on-model device scope constraints
-	maxSupportedFormat[SnapDeclarationType.Name] = 3
+	maxSupportedFormat[SnapDeclarationType.Name] = 4
 }
 
 func MockMaxSupportedFormat(assertType *AssertionType, maxFormat int) (restore func()) {
@@ -0,0 +1,47 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// SignDetached returns a detached signature of the content made with the
+// given private key, encoded like the signatures of assertions.
+func SignDetached(content []byte, privKey PrivateKey) ([]byte, error) {
+	return signContent(content, privKey)
+}
+
+// VerifyDetached verifies that the detached signature of the content was
+// made with the key of the given account key while it was valid.
+func VerifyDetached(content, signature []byte, accKey *AccountKey) error {
+	sig, err := decodeSignature(bytes.TrimSpace(signature))
+	if err != nil {
+		return err
+	}
+	if !accKey.isKeyValidAt(sig.CreationTime) {
+		return fmt.Errorf("content is signed with expired public key %q from %q", accKey.PublicKeyID(), accKey.AccountID())
+	}
+	if err := accKey.publicKey().verify(content, sig); err != nil {
+		return fmt.Errorf("failed signature verification: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+)
+
+type detachedSuite struct {
+	storeSigning *assertstest.StoreStack
+	brands       *assertstest.SigningAccounts
+}
+
+var _ = Suite(&detachedSuite{})
+
+func (s *detachedSuite) SetUpTest(c *C) {
+	s.storeSigning = assertstest.NewStoreStack("canonical", nil)
+	s.brands = assertstest.NewSigningAccounts(s.storeSigning)
+	s.brands.Register("my-brand", testPrivKey1, nil)
+}
+
+func (s *detachedSuite) TestSignAndVerifyDetached(c *C) {
+	content := []byte("bootloader binary")
+	sig, err := asserts.SignDetached(content, testPrivKey1)
+	c.Assert(err, IsNil)
+
+	accKey := s.brands.AccountKey("my-brand")
+	// a trailing newline in a signature file is fine
+	c.Check(asserts.VerifyDetached(content, append(sig, '\n'), accKey), IsNil)
+
+	err = asserts.VerifyDetached([]byte("tampered bootloader binary"), sig, accKey)
+	c.Check(err, ErrorMatches, "failed signature verification: .*")
+
+	otherSig, err := asserts.SignDetached(content, testPrivKey2)
+	c.Assert(err, IsNil)
+	err = asserts.VerifyDetached(content, otherSig, accKey)
+	c.Check(err, ErrorMatches, "failed signature verification: .*")
+
+	err = asserts.VerifyDetached(content, []byte("garbage"), accKey)
+	c.Check(err, ErrorMatches, "cannot decode signature: .*")
+}
+
+func (s *detachedSuite) TestVerifyDetachedExpiredKey(c *C) {
+	since := time.Now().AddDate(-2, 0, 0)
+	until := time.Now().AddDate(-1, 0, 0)
+	acct := s.brands.Account("my-brand")
+	accKey := assertstest.NewAccountKey(s.storeSigning, acct, map[string]interface{}{
+		"name":  "old",
+		"since": since.Format(time.RFC3339),
+		"until": until.Format(time.RFC3339),
+	}, testPrivKey2.PublicKey(), "")
+
+	content := []byte("bootloader binary")
+	sig, err := asserts.SignDetached(content, testPrivKey2)
+	c.Assert(err, IsNil)
+	err = asserts.VerifyDetached(content, sig, accKey)
+	c.Check(err, ErrorMatches, `content is signed with expired public key ".*" from "my-brand"`)
+}
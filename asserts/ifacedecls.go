@@ -39,6 +39,8 @@ const (
 	dollarAttrConstraintsFeature = "dollar-attr-constraints"
 	// feature label for on-store/on-brand/on-model
 	deviceScopeConstraintsFeature = "device-scope-constraints"
+	// feature label for plugs-per-slot
+	sideArityConstraintsFeature = "side-arity-constraints"
 )
 
 type attrMatcher interface {
@@ -426,6 +428,36 @@ func compileDeviceScopeConstraint(cMap map[string]interface{}, context string) (
 	}, nil
 }
 
+// SideArityConstraint specifies a constraint on the number of
+// connections allowed for one side of an interface connection,
+// for example the number of plugs that can be connected to a slot.
+type SideArityConstraint struct {
+	// N is the maximum number of connections, if it is 0
+	// (the default or "*") any number of connections is allowed.
+	N int
+}
+
+// Any returns whether any number of connections is allowed.
+func (ac SideArityConstraint) Any() bool {
+	return ac.N <= 0
+}
+
+func compileSideArityConstraint(cMap map[string]interface{}, field, context string) (SideArityConstraint, error) {
+	var a SideArityConstraint
+	s, ok := cMap[field].(string)
+	if ok {
+		if s == "*" {
+			return a, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err == nil && n >= 1 {
+			a.N = n
+			return a, nil
+		}
+	}
+	return a, fmt.Errorf("%s in %s must be an integer >=1 or *", field, context)
+}
+
 // rules
 
 var (
@@ -466,7 +498,13 @@ type constraintsHolder interface {
 	setDeviceScopeConstraint(deviceScope *DeviceScopeConstraint)
 }
 
-func baseCompileConstraints(context string, cDef constraintsDef, target constraintsHolder, attrConstraints, idConstraints []string) error {
+// sideArityConstraintsHolder is implemented by the constraints
+// holders that support side arity constraints.
+type sideArityConstraintsHolder interface {
+	setSideArityConstraint(field string, a SideArityConstraint)
+}
+
+func baseCompileConstraints(context string, cDef constraintsDef, target constraintsHolder, attrConstraints, idConstraints, sideArityConstraints []string) error {
 	cMap := cDef.cMap
 	if cMap == nil {
 		fixed := AlwaysMatchAttributes // "true"
@@ -503,6 +541,17 @@ func baseCompileConstraints(context string, cDef constraintsDef, target constrai
 		}
 		target.setAttributeConstraints(field, cstrs)
 	}
+	for _, field := range sideArityConstraints {
+		if cMap[field] == nil {
+			defaultUsed++
+			continue
+		}
+		a, err := compileSideArityConstraint(cMap, field, context)
+		if err != nil {
+			return err
+		}
+		target.(sideArityConstraintsHolder).setSideArityConstraint(field, a)
+	}
 	onClassic := cMap["on-classic"]
 	if onClassic == nil {
 		defaultUsed++
@@ -541,8 +590,9 @@ func baseCompileConstraints(context string, cDef constraintsDef, target constrai
 	// well-formed
 	// +1+1 accounts for defaults for missing on-classic plus missing
 	// on-store/on-brand/on-model
-	if defaultUsed == len(attributeConstraints)+len(idConstraints)+1+1 {
-		return fmt.Errorf("%s must specify at least one of %s, %s, on-classic, on-store, on-brand, on-model", context, strings.Join(attrConstraints, ", "), strings.Join(idConstraints, ", "))
+	if defaultUsed == len(attributeConstraints)+len(idConstraints)+len(sideArityConstraints)+1+1 {
+		fields := append(append(append([]string(nil), attrConstraints...), idConstraints...), sideArityConstraints...)
+		return fmt.Errorf("%s must specify at least one of %s, on-classic, on-store, on-brand, on-model", context, strings.Join(fields, ", "))
 	}
 	return nil
 }
@@ -752,7 +802,7 @@ func (c *PlugInstallationConstraints) setDeviceScopeConstraint(deviceScope *Devi
 
 func compilePlugInstallationConstraints(context string, cDef constraintsDef) (constraintsHolder, error) {
 	plugInstCstrs := &PlugInstallationConstraints{}
-	err := baseCompileConstraints(context, cDef, plugInstCstrs, []string{"plug-attributes"}, []string{"plug-snap-type"})
+	err := baseCompileConstraints(context, cDef, plugInstCstrs, []string{"plug-attributes"}, []string{"plug-snap-type"}, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -821,7 +871,7 @@ var (
 
 func compilePlugConnectionConstraints(context string, cDef constraintsDef) (constraintsHolder, error) {
 	plugConnCstrs := &PlugConnectionConstraints{}
-	err := baseCompileConstraints(context, cDef, plugConnCstrs, attributeConstraints, plugIDConstraints)
+	err := baseCompileConstraints(context, cDef, plugConnCstrs, attributeConstraints, plugIDConstraints, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -1002,7 +1052,7 @@ func (c *SlotInstallationConstraints) setDeviceScopeConstraint(deviceScope *Devi
 
 func compileSlotInstallationConstraints(context string, cDef constraintsDef) (constraintsHolder, error) {
 	slotInstCstrs := &SlotInstallationConstraints{}
-	err := baseCompileConstraints(context, cDef, slotInstCstrs, []string{"slot-attributes"}, []string{"slot-snap-type"})
+	err := baseCompileConstraints(context, cDef, slotInstCstrs, []string{"slot-attributes"}, []string{"slot-snap-type"}, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -1020,6 +1070,11 @@ type SlotConnectionConstraints struct {
 	SlotAttributes *AttributeConstraints
 	PlugAttributes *AttributeConstraints
 
+	// PlugsPerSlot limits the number of plugs that can be
+	// connected to the slot, it can only be specified for
+	// allow-connection and allow-auto-connection constraints.
+	PlugsPerSlot SideArityConstraint
+
 	OnClassic *OnClassicConstraint
 
 	DeviceScope *DeviceScopeConstraint
@@ -1029,6 +1084,9 @@ func (c *SlotConnectionConstraints) feature(flabel string) bool {
 	if flabel == deviceScopeConstraintsFeature {
 		return c.DeviceScope != nil
 	}
+	if flabel == sideArityConstraintsFeature {
+		return !c.PlugsPerSlot.Any()
+	}
 	return c.PlugAttributes.feature(flabel) || c.SlotAttributes.feature(flabel)
 }
 
@@ -1056,8 +1114,18 @@ func (c *SlotConnectionConstraints) setIDConstraints(field string, cstrs []strin
 	}
 }
 
+func (c *SlotConnectionConstraints) setSideArityConstraint(field string, a SideArityConstraint) {
+	switch field {
+	case "plugs-per-slot":
+		c.PlugsPerSlot = a
+	default:
+		panic("unknown SlotConnectionConstraints field " + field)
+	}
+}
+
 var (
-	slotIDConstraints = []string{"plug-snap-type", "plug-publisher-id", "plug-snap-id"}
+	slotIDConstraints        = []string{"plug-snap-type", "plug-publisher-id", "plug-snap-id"}
+	slotSideArityConstraints = []string{"plugs-per-slot"}
 )
 
 func (c *SlotConnectionConstraints) setOnClassicConstraint(onClassic *OnClassicConstraint) {
@@ -1070,7 +1138,16 @@ func (c *SlotConnectionConstraints) setDeviceScopeConstraint(deviceScope *Device
 
 func compileSlotConnectionConstraints(context string, cDef constraintsDef) (constraintsHolder, error) {
 	slotConnCstrs := &SlotConnectionConstraints{}
-	err := baseCompileConstraints(context, cDef, slotConnCstrs, attributeConstraints, slotIDConstraints)
+	err := baseCompileConstraints(context, cDef, slotConnCstrs, attributeConstraints, slotIDConstraints, nil)
+	if err != nil {
+		return nil, err
+	}
+	return slotConnCstrs, nil
+}
+
+func compileSlotAllowConnectionConstraints(context string, cDef constraintsDef) (constraintsHolder, error) {
+	slotConnCstrs := &SlotConnectionConstraints{}
+	err := baseCompileConstraints(context, cDef, slotConnCstrs, attributeConstraints, slotIDConstraints, slotSideArityConstraints)
 	if err != nil {
 		return nil, err
 	}
@@ -1080,9 +1157,9 @@ func compileSlotConnectionConstraints(context string, cDef constraintsDef) (cons
 var slotRuleCompilers = map[string]subruleCompiler{
 	"allow-installation":    compileSlotInstallationConstraints,
 	"deny-installation":     compileSlotInstallationConstraints,
-	"allow-connection":      compileSlotConnectionConstraints,
+	"allow-connection":      compileSlotAllowConnectionConstraints,
 	"deny-connection":       compileSlotConnectionConstraints,
-	"allow-auto-connection": compileSlotConnectionConstraints,
+	"allow-auto-connection": compileSlotAllowConnectionConstraints,
 	"deny-auto-connection":  compileSlotConnectionConstraints,
 }
 
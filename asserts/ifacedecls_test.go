@@ -1471,6 +1471,35 @@ func (s *plugSlotRulesSuite) TestCompileSlotRuleConnectionConstraintsIDConstrain
 	c.Check(cstrs.PlugPublisherIDs, DeepEquals, []string{"pubidpubidpubidpubidpubidpubid09", "canonical", "$SAME"})
 }
 
+func (s *plugSlotRulesSuite) TestCompileSlotRuleConnectionConstraintsSideArity(c *C) {
+	m, err := asserts.ParseHeaders([]byte(`iface:
+  allow-connection:
+    plugs-per-slot: 1
+  allow-auto-connection:
+    -
+      plug-snap-type:
+        - app
+      plugs-per-slot: *
+    -
+      plugs-per-slot: 2`))
+	c.Assert(err, IsNil)
+
+	rule, err := asserts.CompileSlotRule("iface", m["iface"].(map[string]interface{}))
+	c.Assert(err, IsNil)
+
+	c.Assert(rule.AllowConnection, HasLen, 1)
+	c.Check(rule.AllowConnection[0].PlugsPerSlot, Equals, asserts.SideArityConstraint{N: 1})
+	c.Check(rule.AllowConnection[0].PlugsPerSlot.Any(), Equals, false)
+
+	c.Assert(rule.AllowAutoConnection, HasLen, 2)
+	c.Check(rule.AllowAutoConnection[0].PlugsPerSlot.Any(), Equals, true)
+	c.Check(rule.AllowAutoConnection[1].PlugsPerSlot, Equals, asserts.SideArityConstraint{N: 2})
+
+	// defaults
+	c.Assert(rule.DenyConnection, HasLen, 1)
+	c.Check(rule.DenyConnection[0].PlugsPerSlot.Any(), Equals, true)
+}
+
 func (s *plugSlotRulesSuite) TestCompileSlotRuleConnectionConstraintsOnClassic(c *C) {
 	m, err := asserts.ParseHeaders([]byte(`iface:
   allow-connection: true`))
@@ -1629,7 +1658,7 @@ func (s *plugSlotRulesSuite) TestCompileSlotRuleErrors(c *C) {
 		{`iface:
   allow-connection:
     plug-snap-ids:
-      - foo`, `allow-connection in slot rule for interface "iface" must specify at least one of plug-attributes, slot-attributes, plug-snap-type, plug-publisher-id, plug-snap-id, on-classic, on-store, on-brand, on-model`},
+      - foo`, `allow-connection in slot rule for interface "iface" must specify at least one of plug-attributes, slot-attributes, plug-snap-type, plug-publisher-id, plug-snap-id, plugs-per-slot, on-classic, on-store, on-brand, on-model`},
 		{`iface:
   deny-connection:
     plug-snap-ids:
@@ -1637,7 +1666,7 @@ func (s *plugSlotRulesSuite) TestCompileSlotRuleErrors(c *C) {
 		{`iface:
   allow-auto-connection:
     plug-snap-ids:
-      - foo`, `allow-auto-connection in slot rule for interface "iface" must specify at least one of plug-attributes, slot-attributes, plug-snap-type, plug-publisher-id, plug-snap-id, on-classic, on-store, on-brand, on-model`},
+      - foo`, `allow-auto-connection in slot rule for interface "iface" must specify at least one of plug-attributes, slot-attributes, plug-snap-type, plug-publisher-id, plug-snap-id, plugs-per-slot, on-classic, on-store, on-brand, on-model`},
 		{`iface:
   deny-auto-connection:
     plug-snap-ids:
@@ -1674,6 +1703,16 @@ func (s *plugSlotRulesSuite) TestCompileSlotRuleErrors(c *C) {
   allow-auto-connection:
     on-model:
       - foo//bar`, `on-model in allow-auto-connection in slot rule for interface \"iface\" contains an invalid element: \"foo//bar"`},
+		{`iface:
+  allow-connection:
+    plugs-per-slot: 0`, `plugs-per-slot in allow-connection in slot rule for interface \"iface\" must be an integer >=1 or \*`},
+		{`iface:
+  allow-auto-connection:
+    plugs-per-slot:
+      - 1`, `plugs-per-slot in allow-auto-connection in slot rule for interface \"iface\" must be an integer >=1 or \*`},
+		{`iface:
+  deny-connection:
+    plugs-per-slot: 1`, `deny-connection in slot rule for interface "iface" must specify at least one of plug-attributes, slot-attributes, plug-snap-type, plug-publisher-id, plug-snap-id, on-classic, on-store, on-brand, on-model`},
 	}
 
 	for _, t := range tests {
@@ -187,6 +187,9 @@ func snapDeclarationFormatAnalyze(headers map[string]interface{}, body []byte) (
 		if rule.feature(deviceScopeConstraintsFeature) {
 			setFormatNum(3)
 		}
+		if rule.feature(sideArityConstraintsFeature) {
+			setFormatNum(4)
+		}
 	})
 	if err != nil {
 		return 0, err
@@ -441,6 +441,22 @@ func (sds *snapDeclSuite) TestSuggestedFormat(c *C) {
 		}
 	}
 
+	// plugs-per-slot => format 4
+	for _, conn := range []string{"connection", "auto-connection"} {
+		headers = map[string]interface{}{
+			"slots": map[string]interface{}{
+				"interface3": map[string]interface{}{
+					"allow-" + conn: map[string]interface{}{
+						"plugs-per-slot": "1",
+					},
+				},
+			},
+		}
+		fmtnum, err = asserts.SuggestFormat(asserts.SnapDeclarationType, headers, nil)
+		c.Assert(err, IsNil)
+		c.Check(fmtnum, Equals, 4)
+	}
+
 	// higher format features win
 
 	headers = map[string]interface{}{
@@ -487,6 +503,20 @@ func (sds *snapDeclSuite) TestSuggestedFormat(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(fmtnum, Equals, 3)
 
+	headers = map[string]interface{}{
+		"slots": map[string]interface{}{
+			"interface3": map[string]interface{}{
+				"allow-auto-connection": map[string]interface{}{
+					"on-store":       []interface{}{"store"},
+					"plugs-per-slot": "2",
+				},
+			},
+		},
+	}
+	fmtnum, err = asserts.SuggestFormat(asserts.SnapDeclarationType, headers, nil)
+	c.Assert(err, IsNil)
+	c.Check(fmtnum, Equals, 4)
+
 	// errors
 	headers = map[string]interface{}{
 		"plugs": "what",
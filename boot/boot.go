@@ -274,7 +274,76 @@ func MarkBootSuccessful() error {
 	}
 	m["snap_mode"] = ""
 
-	return bl.SetBootVars(m)
+	if err := bl.SetBootVars(m); err != nil {
+		return err
+	}
+
+	// the kernel that was tried booted fine, its boot chains are the
+	// current ones now
+	if err := markTryBootChainsSuccessful(); err != nil {
+		return fmt.Errorf("cannot mark boot successful: %v", err)
+	}
+	return nil
+}
+
+// RequestFactoryReset sets up the boot environment of the seed mounted
+// at seedDir so that on the next boot the given recovery system is
+// booted in install mode with a factory reset requested. The
+// initramfs then lets snapd know about the reset, which wipes the
+// writable data of the system while installing the run system again.
+func RequestFactoryReset(seedDir, recoverySystem string) error {
+	bl, err := bootloader.Find(seedDir, nil)
+	if err != nil {
+		return fmt.Errorf("cannot request factory reset: %s", err)
+	}
+	return bl.SetBootVars(map[string]string{
+		"snapd_recovery_mode":   ModeInstall,
+		"snapd_recovery_system": recoverySystem,
+		"snapd_factory_reset":   "1",
+	})
+}
+
+// FactoryResetRequested returns whether a factory reset was requested
+// in the boot environment of the seed mounted at seedDir.
+func FactoryResetRequested(seedDir string) (bool, error) {
+	bl, err := bootloader.Find(seedDir, nil)
+	if err != nil {
+		return false, fmt.Errorf("cannot check for factory reset: %s", err)
+	}
+	m, err := bl.GetBootVars("snapd_factory_reset")
+	if err != nil {
+		return false, err
+	}
+	return m["snapd_factory_reset"] == "1", nil
+}
+
+// RebootArgs returns the arguments the system needs to be rebooted with
+// for the boot environment as set up to take effect, or "" if it needs
+// none.
+func RebootArgs() (string, error) {
+	bl, err := bootloader.Find("", nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot get reboot arguments: %s", err)
+	}
+	rbl, ok := bl.(bootloader.RebootBootloader)
+	if !ok {
+		return "", nil
+	}
+	return rbl.GetRebootArguments()
+}
+
+// ClearFactoryReset resets the boot environment of the seed mounted at
+// seedDir once the run system was installed again after a factory
+// reset, so that the run system is booted next.
+func ClearFactoryReset(seedDir string) error {
+	bl, err := bootloader.Find(seedDir, nil)
+	if err != nil {
+		return fmt.Errorf("cannot clear factory reset: %s", err)
+	}
+	return bl.SetBootVars(map[string]string{
+		"snapd_recovery_mode": ModeRun,
+		"snapd_factory_reset": "",
+	})
 }
 
 // BootableSet represents the boot snaps of a system to be made bootable.
@@ -355,6 +424,19 @@ func MakeBootable(model *asserts.Model, rootdir string, bootWith *BootableSet) e
 	}
 	setBoot("snap_kernel", bootWith.KernelPath)
 
+	// the kernel command line arguments from the gadget
+	cmdlineVars, cmdline, err := commandLineVarsFromGadget(bootWith.UnpackedGadgetDir)
+	if err != nil {
+		return fmt.Errorf("cannot set kernel command line: %v", err)
+	}
+	if supportsCommandLineArgs(bl) {
+		for k, v := range cmdlineVars {
+			m[k] = v
+		}
+	} else if cmdline != "" {
+		logger.Noticef("bootloader %q does not support kernel command line arguments from the gadget, ignoring them", bl.Name())
+	}
+
 	if err := bl.SetBootVars(m); err != nil {
 		return err
 	}
@@ -365,6 +365,75 @@ func (s *bootSetSuite) TestMarkBootSuccessfulKKernelUpdate(c *C) {
 	})
 }
 
+func (s *bootSetSuite) TestRequestFactoryReset(c *C) {
+	s.bootloader.BootVars["snap_core"] = "os1"
+	err := boot.RequestFactoryReset("/run/mnt/ubuntu-seed", "20191119")
+	c.Assert(err, IsNil)
+	c.Assert(s.bootloader.BootVars, DeepEquals, map[string]string{
+		// unchanged
+		"snap_core": "os1",
+		// set
+		"snapd_recovery_mode":   "install",
+		"snapd_recovery_system": "20191119",
+		"snapd_factory_reset":   "1",
+	})
+
+	requested, err := boot.FactoryResetRequested("/run/mnt/ubuntu-seed")
+	c.Assert(err, IsNil)
+	c.Check(requested, Equals, true)
+
+	err = boot.ClearFactoryReset("/run/mnt/ubuntu-seed")
+	c.Assert(err, IsNil)
+	c.Assert(s.bootloader.BootVars, DeepEquals, map[string]string{
+		"snap_core":             "os1",
+		"snapd_recovery_mode":   "run",
+		"snapd_recovery_system": "20191119",
+		"snapd_factory_reset":   "",
+	})
+
+	requested, err = boot.FactoryResetRequested("/run/mnt/ubuntu-seed")
+	c.Assert(err, IsNil)
+	c.Check(requested, Equals, false)
+}
+
+func (s *bootSetSuite) TestRequestFactoryResetNoBootloader(c *C) {
+	bootloader.ForceError(errors.New("broken bootloader"))
+	defer bootloader.ForceError(nil)
+
+	err := boot.RequestFactoryReset("/run/mnt/ubuntu-seed", "20191119")
+	c.Assert(err, ErrorMatches, "cannot request factory reset: broken bootloader")
+	_, err = boot.FactoryResetRequested("/run/mnt/ubuntu-seed")
+	c.Assert(err, ErrorMatches, "cannot check for factory reset: broken bootloader")
+	err = boot.ClearFactoryReset("/run/mnt/ubuntu-seed")
+	c.Assert(err, ErrorMatches, "cannot clear factory reset: broken bootloader")
+}
+
+type mockRebootBootloader struct {
+	*bootloadertest.MockBootloader
+	rebootArgs string
+}
+
+func (b *mockRebootBootloader) GetRebootArguments() (string, error) {
+	return b.rebootArgs, nil
+}
+
+func (s *bootSetSuite) TestRebootArgs(c *C) {
+	// the bootloader needs no arguments
+	args, err := boot.RebootArgs()
+	c.Assert(err, IsNil)
+	c.Check(args, Equals, "")
+
+	bootloader.Force(&mockRebootBootloader{MockBootloader: s.bootloader, rebootArgs: "0 tryboot"})
+	args, err = boot.RebootArgs()
+	c.Assert(err, IsNil)
+	c.Check(args, Equals, "0 tryboot")
+
+	bootloader.ForceError(errors.New("broken bootloader"))
+	defer bootloader.ForceError(nil)
+	_, err = boot.RebootArgs()
+	c.Assert(err, ErrorMatches, "cannot get reboot arguments: broken bootloader")
+}
+
 func (s *bootSetSuite) makeSnap(c *C, name, yaml string, revno snap.Revision) (fn string, info *snap.Info) {
 	si := &snap.SideInfo{
 		RealName: name,
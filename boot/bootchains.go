@@ -0,0 +1,318 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+)
+
+// BootAsset is a boot asset, such as a bootloader binary, that is part of
+// a boot chain. It is identified by its role, its name and the hashes of
+// the acceptable versions of it.
+type BootAsset struct {
+	Role   string   `json:"role"`
+	Name   string   `json:"name"`
+	Hashes []string `json:"hashes"`
+}
+
+// BootChain is a sequence of boot assets leading to a given kernel booted
+// with one of the given command lines.
+type BootChain struct {
+	AssetChain     []BootAsset `json:"asset-chain"`
+	Kernel         string      `json:"kernel"`
+	KernelRevision string      `json:"kernel-revision"`
+	KernelCmdlines []string    `json:"kernel-cmdlines"`
+}
+
+// BootChains are the boot chains of the system, the current ones and,
+// while a new kernel or new boot assets are being tried, the try ones.
+type BootChains struct {
+	Current []BootChain `json:"current"`
+	Try     []BootChain `json:"try,omitempty"`
+	// ResealCount is the number of times the keys were resealed.
+	ResealCount int `json:"reseal-count"`
+}
+
+// ResealKeys, if set, is called to reseal the keys of the system, as used
+// for full disk encryption, against the given boot chains whenever those
+// change. Any of the boot chains must then be able to unseal the keys. By
+// default it reseals the sealed key of the data partition, if there is one.
+var ResealKeys func(chains []BootChain) error
+
+func bootChainsFile(rootdir string) string {
+	if rootdir == "" {
+		rootdir = dirs.GlobalRootDir
+	}
+	return filepath.Join(dirs.SnapDeviceDirUnder(rootdir), "boot-chains")
+}
+
+// ReadBootChains returns the boot chains recorded for the system. If none
+// were recorded yet an empty set is returned.
+func ReadBootChains() (*BootChains, error) {
+	data, err := ioutil.ReadFile(bootChainsFile(""))
+	if os.IsNotExist(err) {
+		return &BootChains{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var chains BootChains
+	if err := json.Unmarshal(data, &chains); err != nil {
+		return nil, fmt.Errorf("cannot read boot chains: %v", err)
+	}
+	return &chains, nil
+}
+
+// write writes the boot chains under rootdir, or under the global root
+// directory when rootdir is empty.
+func (chains *BootChains) write(rootdir string) error {
+	data, err := json.Marshal(chains)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(bootChainsFile(rootdir)), 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(bootChainsFile(rootdir), data, 0600, 0)
+}
+
+// UpdateBootChains records the given current and try boot chains and
+// reseals the keys of the system if they are different from the
+// recorded ones.
+func UpdateBootChains(current, try []BootChain) error {
+	chains, err := ReadBootChains()
+	if err != nil {
+		return err
+	}
+	newChains := &BootChains{
+		Current:     toPredictable(current),
+		Try:         toPredictable(try),
+		ResealCount: chains.ResealCount,
+	}
+	if sameBootChains(chains, newChains) {
+		return nil
+	}
+	return resealAndWrite(newChains)
+}
+
+// Reseal reseals the keys of the system against the recorded boot
+// chains.
+func Reseal() error {
+	chains, err := ReadBootChains()
+	if err != nil {
+		return err
+	}
+	return resealAndWrite(chains)
+}
+
+func resealAndWrite(chains *BootChains) error {
+	if ResealKeys != nil {
+		all := make([]BootChain, 0, len(chains.Current)+len(chains.Try))
+		all = append(all, chains.Current...)
+		all = append(all, chains.Try...)
+		if err := ResealKeys(all); err != nil {
+			return fmt.Errorf("cannot reseal keys: %v", err)
+		}
+		chains.ResealCount++
+	}
+	return chains.write("")
+}
+
+func sameBootChains(a, b *BootChains) bool {
+	aj, err := json.Marshal(&BootChains{Current: toPredictable(a.Current), Try: toPredictable(a.Try)})
+	if err != nil {
+		return false
+	}
+	bj, err := json.Marshal(&BootChains{Current: toPredictable(b.Current), Try: toPredictable(b.Try)})
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(aj, bj)
+}
+
+// toPredictable returns a copy of the boot chains with the command lines
+// of each chain, and the chains themselves, in a predictable order.
+func toPredictable(chains []BootChain) []BootChain {
+	if len(chains) == 0 {
+		return nil
+	}
+	predictable := make([]bootChainWithKey, len(chains))
+	for i, chain := range chains {
+		cmdlines := make([]string, len(chain.KernelCmdlines))
+		copy(cmdlines, chain.KernelCmdlines)
+		sort.Strings(cmdlines)
+		chain.KernelCmdlines = cmdlines
+		// the chain only has marshallable fields
+		key, _ := json.Marshal(chain)
+		predictable[i] = bootChainWithKey{chain: chain, key: string(key)}
+	}
+	sort.Sort(byBootChainKey(predictable))
+	sorted := make([]BootChain, len(predictable))
+	for i, p := range predictable {
+		sorted[i] = p.chain
+	}
+	return sorted
+}
+
+type bootChainWithKey struct {
+	chain BootChain
+	key   string
+}
+
+type byBootChainKey []bootChainWithKey
+
+func (b byBootChainKey) Len() int           { return len(b) }
+func (b byBootChainKey) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byBootChainKey) Less(i, j int) bool { return b[i].key < b[j].key }
+
+// setTryKernelBootChains records try boot chains which are the current
+// ones booting the given kernel blob instead, if boot chains are tracked
+// at all.
+func setTryKernelBootChains(kernelBlob string) error {
+	chains, err := ReadBootChains()
+	if err != nil {
+		return err
+	}
+	if len(chains.Current) == 0 {
+		return nil
+	}
+	nameAndRev, err := nameAndRevnoFromSnap(kernelBlob)
+	if err != nil {
+		return err
+	}
+	try := make([]BootChain, len(chains.Current))
+	for i, chain := range chains.Current {
+		chain.Kernel = nameAndRev.Name
+		chain.KernelRevision = nameAndRev.Revision.String()
+		try[i] = chain
+	}
+	return UpdateBootChains(chains.Current, try)
+}
+
+// markTryBootChainsSuccessful makes the try boot chains, if any, the
+// current ones, dropping the chains booting the previous kernel.
+func markTryBootChainsSuccessful() error {
+	chains, err := ReadBootChains()
+	if err != nil {
+		return err
+	}
+	if len(chains.Try) == 0 {
+		return nil
+	}
+	return UpdateBootChains(chains.Try, nil)
+}
+
+// clearTryBootChains drops the try boot chains, if any.
+func clearTryBootChains() error {
+	chains, err := ReadBootChains()
+	if err != nil {
+		return err
+	}
+	if len(chains.Try) == 0 {
+		return nil
+	}
+	return UpdateBootChains(chains.Current, nil)
+}
+
+// gadgetBootAssets are the boot assets shipped by the gadget which the
+// firmware loads, in that order, before the kernel. The ones with the
+// recovery role are also part of the boot chain of the recovery system.
+var gadgetBootAssets = []struct {
+	role string
+	name string
+	file string
+}{
+	{role: "recovery", name: "shim", file: "shim.efi.signed"},
+	{role: "run-mode", name: "grub", file: "grubx64.efi"},
+}
+
+// RecordInitialBootChains records, under rootdir, the boot chains of a
+// freshly installed system booting the given kernel either in run mode or
+// into the given recovery system, with the boot assets and kernel command
+// line arguments provided by the gadget snap unpacked at gadgetSnapRootDir.
+// The boot assets are cached under rootdir as well, for the keys of the
+// system to be resealed against them later. Nothing is recorded when the
+// gadget ships none of the boot assets.
+func RecordInitialBootChains(rootdir, gadgetSnapRootDir string, kernel *snap.Info, recoverySystem string) error {
+	var runAssets, recoveryAssets []BootAsset
+	for _, ga := range gadgetBootAssets {
+		content, err := ioutil.ReadFile(filepath.Join(gadgetSnapRootDir, ga.file))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("cannot record boot chains: %v", err)
+		}
+		digest := sha256.Sum256(content)
+		asset := BootAsset{Role: ga.role, Name: ga.name, Hashes: []string{hex.EncodeToString(digest[:])}}
+		dir := filepath.Join(dirs.SnapBootAssetsDirUnder(rootdir), asset.Role)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("cannot record boot chains: %v", err)
+		}
+		cached := filepath.Join(dir, fmt.Sprintf("%s-%s", asset.Name, asset.Hashes[0]))
+		if err := osutil.AtomicWriteFile(cached, content, 0644, 0); err != nil {
+			return fmt.Errorf("cannot record boot chains: %v", err)
+		}
+		runAssets = append(runAssets, asset)
+		if asset.Role == "recovery" {
+			recoveryAssets = append(recoveryAssets, asset)
+		}
+	}
+	if len(runAssets) == 0 {
+		return nil
+	}
+
+	cmdline, _, err := gadget.KernelCommandLineFromGadget(gadgetSnapRootDir)
+	if err != nil {
+		return fmt.Errorf("cannot record boot chains: %v", err)
+	}
+	chains := []BootChain{{
+		AssetChain:     runAssets,
+		Kernel:         kernel.InstanceName(),
+		KernelRevision: kernel.Revision.String(),
+		KernelCmdlines: []string{withArgs("snapd_recovery_mode="+ModeRun, cmdline)},
+	}}
+	if len(recoveryAssets) != 0 {
+		recoverCmdline := fmt.Sprintf("snapd_recovery_mode=%s snapd_recovery_system=%s", ModeRecover, recoverySystem)
+		chains = append(chains, BootChain{
+			AssetChain:     recoveryAssets,
+			Kernel:         kernel.InstanceName(),
+			KernelRevision: kernel.Revision.String(),
+			KernelCmdlines: []string{withArgs(recoverCmdline, cmdline)},
+		})
+	}
+	if err := (&BootChains{Current: toPredictable(chains)}).write(rootdir); err != nil {
+		return fmt.Errorf("cannot record boot chains: %v", err)
+	}
+	return nil
+}
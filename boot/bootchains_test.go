@@ -0,0 +1,256 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/bootloader/bootloadertest"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type bootChainsSuite struct {
+	baseBootSetSuite
+
+	bootloader *bootloadertest.MockBootloader
+	resealed   [][]boot.BootChain
+	resealErr  error
+}
+
+var _ = Suite(&bootChainsSuite{})
+
+var (
+	shim = boot.BootAsset{Role: "recovery", Name: "shim", Hashes: []string{"shim-hash"}}
+	grub = boot.BootAsset{Role: "run-mode", Name: "grub", Hashes: []string{"grub-hash-1", "grub-hash-2"}}
+
+	pcKernel1 = boot.BootChain{
+		AssetChain:     []boot.BootAsset{shim, grub},
+		Kernel:         "pc-kernel",
+		KernelRevision: "1",
+		KernelCmdlines: []string{"snapd_recovery_mode=run console=ttyS0", "snapd_recovery_mode=run"},
+	}
+	recoveryKernel = boot.BootChain{
+		AssetChain:     []boot.BootAsset{shim},
+		Kernel:         "pc-kernel",
+		KernelRevision: "1",
+		KernelCmdlines: []string{"snapd_recovery_mode=recover"},
+	}
+)
+
+func (s *bootChainsSuite) SetUpTest(c *C) {
+	s.baseBootSetSuite.SetUpTest(c)
+
+	s.bootloader = bootloadertest.Mock("mock", c.MkDir())
+	bootloader.Force(s.bootloader)
+	s.AddCleanup(func() { bootloader.Force(nil) })
+
+	s.resealed = nil
+	s.resealErr = nil
+	oldResealKeys := boot.ResealKeys
+	boot.ResealKeys = func(chains []boot.BootChain) error {
+		s.resealed = append(s.resealed, chains)
+		return s.resealErr
+	}
+	s.AddCleanup(func() { boot.ResealKeys = oldResealKeys })
+}
+
+func (s *bootChainsSuite) bootChainsFile() string {
+	return filepath.Join(dirs.SnapDeviceDir, "boot-chains")
+}
+
+func (s *bootChainsSuite) TestReadBootChainsNone(c *C) {
+	chains, err := boot.ReadBootChains()
+	c.Assert(err, IsNil)
+	c.Check(chains, DeepEquals, &boot.BootChains{})
+}
+
+func (s *bootChainsSuite) TestUpdateBootChains(c *C) {
+	err := boot.UpdateBootChains([]boot.BootChain{pcKernel1, recoveryKernel}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(s.resealed, HasLen, 1)
+	c.Check(s.resealed[0], HasLen, 2)
+	c.Check(s.bootChainsFile(), testutil.FilePresent)
+
+	chains, err := boot.ReadBootChains()
+	c.Assert(err, IsNil)
+	c.Check(chains.ResealCount, Equals, 1)
+	c.Assert(chains.Current, HasLen, 2)
+	c.Check(chains.Try, HasLen, 0)
+	// the chains are recorded in a predictable order
+	c.Check(chains.Current[0].KernelCmdlines, DeepEquals, []string{"snapd_recovery_mode=run", "snapd_recovery_mode=run console=ttyS0"})
+	c.Check(chains.Current[1].KernelCmdlines, DeepEquals, []string{"snapd_recovery_mode=recover"})
+
+	// the same chains in a different order do not need resealing
+	err = boot.UpdateBootChains([]boot.BootChain{recoveryKernel, pcKernel1}, nil)
+	c.Assert(err, IsNil)
+	c.Check(s.resealed, HasLen, 1)
+
+	// but a different command line does
+	pcKernel1Debug := pcKernel1
+	pcKernel1Debug.KernelCmdlines = []string{"snapd_recovery_mode=run debug"}
+	err = boot.UpdateBootChains([]boot.BootChain{recoveryKernel, pcKernel1}, []boot.BootChain{pcKernel1Debug})
+	c.Assert(err, IsNil)
+	c.Assert(s.resealed, HasLen, 2)
+	// the keys are sealed against both the current and the try chains
+	c.Check(s.resealed[1], HasLen, 3)
+
+	chains, err = boot.ReadBootChains()
+	c.Assert(err, IsNil)
+	c.Check(chains.ResealCount, Equals, 2)
+	c.Check(chains.Try, DeepEquals, []boot.BootChain{pcKernel1Debug})
+}
+
+func (s *bootChainsSuite) TestUpdateBootChainsResealError(c *C) {
+	s.resealErr = errors.New("boom")
+
+	err := boot.UpdateBootChains([]boot.BootChain{pcKernel1}, nil)
+	c.Assert(err, ErrorMatches, "cannot reseal keys: boom")
+	c.Check(s.bootChainsFile(), testutil.FileAbsent)
+}
+
+func (s *bootChainsSuite) TestReseal(c *C) {
+	c.Assert(boot.UpdateBootChains([]boot.BootChain{pcKernel1}, nil), IsNil)
+
+	c.Assert(boot.Reseal(), IsNil)
+	c.Assert(s.resealed, HasLen, 2)
+	c.Check(s.resealed[1], DeepEquals, s.resealed[0])
+
+	chains, err := boot.ReadBootChains()
+	c.Assert(err, IsNil)
+	c.Check(chains.ResealCount, Equals, 2)
+}
+
+func (s *bootChainsSuite) TestTryKernelBootChains(c *C) {
+	c.Assert(boot.UpdateBootChains([]boot.BootChain{pcKernel1}, nil), IsNil)
+	s.bootloader.BootVars["snap_kernel"] = "pc-kernel_1.snap"
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "pc-kernel", Revision: snap.R(2)}}
+	err := boot.NewCoreBootParticipant(info, snap.TypeKernel).SetNextBoot()
+	c.Assert(err, IsNil)
+	c.Check(s.bootloader.BootVars["snap_try_kernel"], Equals, "pc-kernel_2.snap")
+
+	c.Assert(s.resealed, HasLen, 2)
+	chains, err := boot.ReadBootChains()
+	c.Assert(err, IsNil)
+	c.Assert(chains.Try, HasLen, 1)
+	c.Check(chains.Try[0].KernelRevision, Equals, "2")
+	c.Check(chains.Current[0].KernelRevision, Equals, "1")
+
+	// the new kernel booted fine
+	s.bootloader.BootVars["snap_mode"] = "trying"
+	c.Assert(boot.MarkBootSuccessful(), IsNil)
+	c.Check(s.bootloader.BootVars["snap_kernel"], Equals, "pc-kernel_2.snap")
+
+	c.Assert(s.resealed, HasLen, 3)
+	chains, err = boot.ReadBootChains()
+	c.Assert(err, IsNil)
+	c.Check(chains.Try, HasLen, 0)
+	c.Assert(chains.Current, HasLen, 1)
+	c.Check(chains.Current[0].KernelRevision, Equals, "2")
+}
+
+func (s *bootChainsSuite) TestTryKernelBootChainsUntracked(c *C) {
+	s.bootloader.BootVars["snap_kernel"] = "pc-kernel_1.snap"
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "pc-kernel", Revision: snap.R(2)}}
+	err := boot.NewCoreBootParticipant(info, snap.TypeKernel).SetNextBoot()
+	c.Assert(err, IsNil)
+	c.Check(s.resealed, HasLen, 0)
+	c.Check(osutil.FileExists(s.bootChainsFile()), Equals, false)
+}
+
+func (s *bootChainsSuite) TestTryKernelBootChainsResealError(c *C) {
+	c.Assert(boot.UpdateBootChains([]boot.BootChain{pcKernel1}, nil), IsNil)
+	s.bootloader.BootVars["snap_kernel"] = "pc-kernel_1.snap"
+	s.resealErr = errors.New("boom")
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "pc-kernel", Revision: snap.R(2)}}
+	err := boot.NewCoreBootParticipant(info, snap.TypeKernel).SetNextBoot()
+	c.Assert(err, ErrorMatches, "cannot set next boot: cannot reseal keys: boom")
+	// the kernel is not tried
+	c.Check(s.bootloader.BootVars["snap_try_kernel"], Equals, "")
+}
+
+func (s *bootChainsSuite) TestRecordInitialBootChains(c *C) {
+	gadgetDir := c.MkDir()
+	c.Assert(ioutil.WriteFile(filepath.Join(gadgetDir, "shim.efi.signed"), []byte("shim"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(gadgetDir, "grubx64.efi"), []byte("grub"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(gadgetDir, "cmdline.extra"), []byte("console=ttyS0\n"), 0644), IsNil)
+	rootdir := c.MkDir()
+
+	kernel := &snap.Info{SideInfo: snap.SideInfo{RealName: "pc-kernel", Revision: snap.R(3)}}
+	err := boot.RecordInitialBootChains(rootdir, gadgetDir, kernel, "20191127")
+	c.Assert(err, IsNil)
+	// nothing is resealed, and the chains of the running system are
+	// left alone
+	c.Check(s.resealed, HasLen, 0)
+	c.Check(s.bootChainsFile(), testutil.FileAbsent)
+
+	shimHash := sha256.Sum256([]byte("shim"))
+	grubHash := sha256.Sum256([]byte("grub"))
+	shim := boot.BootAsset{Role: "recovery", Name: "shim", Hashes: []string{hex.EncodeToString(shimHash[:])}}
+	grub := boot.BootAsset{Role: "run-mode", Name: "grub", Hashes: []string{hex.EncodeToString(grubHash[:])}}
+
+	// the assets are cached in the installed system
+	assetsDir := dirs.SnapBootAssetsDirUnder(rootdir)
+	c.Check(filepath.Join(assetsDir, "recovery", "shim-"+shim.Hashes[0]), testutil.FileEquals, "shim")
+	c.Check(filepath.Join(assetsDir, "run-mode", "grub-"+grub.Hashes[0]), testutil.FileEquals, "grub")
+
+	data, err := ioutil.ReadFile(filepath.Join(dirs.SnapDeviceDirUnder(rootdir), "boot-chains"))
+	c.Assert(err, IsNil)
+	var chains boot.BootChains
+	c.Assert(json.Unmarshal(data, &chains), IsNil)
+	c.Check(chains, DeepEquals, boot.BootChains{
+		Current: []boot.BootChain{
+			{
+				AssetChain:     []boot.BootAsset{shim, grub},
+				Kernel:         "pc-kernel",
+				KernelRevision: "3",
+				KernelCmdlines: []string{"snapd_recovery_mode=run console=ttyS0"},
+			}, {
+				AssetChain:     []boot.BootAsset{shim},
+				Kernel:         "pc-kernel",
+				KernelRevision: "3",
+				KernelCmdlines: []string{"snapd_recovery_mode=recover snapd_recovery_system=20191127 console=ttyS0"},
+			},
+		},
+	})
+}
+
+func (s *bootChainsSuite) TestRecordInitialBootChainsNoBootAssets(c *C) {
+	rootdir := c.MkDir()
+
+	kernel := &snap.Info{SideInfo: snap.SideInfo{RealName: "pc-kernel", Revision: snap.R(3)}}
+	err := boot.RecordInitialBootChains(rootdir, c.MkDir(), kernel, "20191127")
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(dirs.SnapDeviceDirUnder(rootdir), "boot-chains"), testutil.FileAbsent)
+}
@@ -0,0 +1,169 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/logger"
+)
+
+const (
+	// extraCmdlineVar holds the kernel command line arguments which the
+	// bootloader appends to the ones it builds itself
+	extraCmdlineVar = "snapd_extra_cmdline_args"
+	// fullCmdlineVar holds the kernel command line arguments which the
+	// bootloader uses instead of its default ones
+	fullCmdlineVar = "snapd_full_cmdline_args"
+)
+
+// supportsCommandLineArgs returns whether the boot configuration of the
+// given bootloader applies the kernel command line arguments from the boot
+// environment.
+func supportsCommandLineArgs(bl bootloader.Bootloader) bool {
+	cbl, ok := bl.(bootloader.CommandLineBootloader)
+	return ok && cbl.SupportsCommandLineArgs()
+}
+
+// commandLineVarsFromGadget returns the boot variables carrying the kernel
+// command line arguments provided by the gadget snap unpacked at
+// gadgetSnapRootDir, along with the arguments themselves.
+func commandLineVarsFromGadget(gadgetSnapRootDir string) (vars map[string]string, cmdline string, err error) {
+	cmdline, full, err := gadget.KernelCommandLineFromGadget(gadgetSnapRootDir)
+	if err != nil {
+		return nil, "", err
+	}
+	vars = map[string]string{
+		extraCmdlineVar: "",
+		fullCmdlineVar:  "",
+	}
+	if full {
+		vars[fullCmdlineVar] = cmdline
+	} else {
+		vars[extraCmdlineVar] = cmdline
+	}
+	return vars, cmdline, nil
+}
+
+// UpdateCommandLineFromGadget applies the kernel command line arguments
+// provided by the gadget snap unpacked at gadgetSnapRootDir to the boot
+// environment. When boot chains are tracked, the keys are resealed against
+// the updated command lines before the boot environment is modified. It
+// returns true when the command line was updated and a reboot is needed for
+// it to take effect. Bootloaders which ignore the arguments from the boot
+// environment are left alone.
+func UpdateCommandLineFromGadget(gadgetSnapRootDir string) (updated bool, err error) {
+	newVars, cmdline, err := commandLineVarsFromGadget(gadgetSnapRootDir)
+	if err != nil {
+		return false, fmt.Errorf("cannot update kernel command line: %v", err)
+	}
+	bl, err := bootloader.Find("", nil)
+	if err != nil {
+		return false, fmt.Errorf("cannot update kernel command line: %v", err)
+	}
+	if !supportsCommandLineArgs(bl) {
+		if cmdline != "" {
+			logger.Noticef("bootloader %q does not support kernel command line arguments from the gadget, ignoring them", bl.Name())
+		}
+		return false, nil
+	}
+	m, err := bl.GetBootVars(extraCmdlineVar, fullCmdlineVar)
+	if err != nil {
+		return false, err
+	}
+	if m[extraCmdlineVar] == newVars[extraCmdlineVar] && m[fullCmdlineVar] == newVars[fullCmdlineVar] {
+		return false, nil
+	}
+
+	oldCmdline := m[extraCmdlineVar]
+	if m[fullCmdlineVar] != "" {
+		oldCmdline = m[fullCmdlineVar]
+	}
+	if err := setCommandLineBootChains(oldCmdline, cmdline); err != nil {
+		return false, fmt.Errorf("cannot update kernel command line: %v", err)
+	}
+	if err := bl.SetBootVars(newVars); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// setCommandLineBootChains updates the recorded boot chains, if any, so that
+// the command lines carrying either the old or the new gadget provided
+// arguments can be booted.
+func setCommandLineBootChains(oldArgs, newArgs string) error {
+	chains, err := ReadBootChains()
+	if err != nil {
+		return err
+	}
+	if len(chains.Current) == 0 {
+		return nil
+	}
+	return UpdateBootChains(withCommandLineArgs(chains.Current, oldArgs, newArgs), withCommandLineArgs(chains.Try, oldArgs, newArgs))
+}
+
+func withCommandLineArgs(chains []BootChain, oldArgs, newArgs string) []BootChain {
+	if len(chains) == 0 {
+		return nil
+	}
+	updated := make([]BootChain, len(chains))
+	for i, chain := range chains {
+		var cmdlines []string
+		for _, cmdline := range chain.KernelCmdlines {
+			base, ok := withoutArgs(cmdline, oldArgs)
+			if !ok {
+				// a stale variant with arguments applied before
+				// the current ones, it can no longer be booted
+				continue
+			}
+			cmdlines = append(cmdlines, cmdline, withArgs(base, newArgs))
+		}
+		if len(cmdlines) != 0 {
+			chain.KernelCmdlines = cmdlines
+		}
+		updated[i] = chain
+	}
+	return updated
+}
+
+func withoutArgs(cmdline, args string) (string, bool) {
+	switch {
+	case args == "":
+		return cmdline, true
+	case cmdline == args:
+		return "", true
+	case strings.HasSuffix(cmdline, " "+args):
+		return strings.TrimSuffix(cmdline, " "+args), true
+	}
+	return "", false
+}
+
+func withArgs(cmdline, args string) string {
+	if cmdline == "" {
+		return args
+	}
+	if args == "" {
+		return cmdline
+	}
+	return cmdline + " " + args
+}
@@ -0,0 +1,151 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/bootloader"
+)
+
+func (s *bootChainsSuite) TestUpdateCommandLineFromGadget(c *C) {
+	gadgetDir := c.MkDir()
+
+	// nothing provided by the gadget, nothing to do
+	updated, err := boot.UpdateCommandLineFromGadget(gadgetDir)
+	c.Assert(err, IsNil)
+	c.Check(updated, Equals, false)
+
+	err = ioutil.WriteFile(filepath.Join(gadgetDir, "cmdline.extra"), []byte("console=ttyS0\n"), 0644)
+	c.Assert(err, IsNil)
+	updated, err = boot.UpdateCommandLineFromGadget(gadgetDir)
+	c.Assert(err, IsNil)
+	c.Check(updated, Equals, true)
+	c.Check(s.bootloader.BootVars["snapd_extra_cmdline_args"], Equals, "console=ttyS0")
+	c.Check(s.bootloader.BootVars["snapd_full_cmdline_args"], Equals, "")
+
+	// applied already
+	updated, err = boot.UpdateCommandLineFromGadget(gadgetDir)
+	c.Assert(err, IsNil)
+	c.Check(updated, Equals, false)
+
+	// switch to a full command line
+	c.Assert(os.Remove(filepath.Join(gadgetDir, "cmdline.extra")), IsNil)
+	err = ioutil.WriteFile(filepath.Join(gadgetDir, "cmdline.full"), []byte("console=tty1 panic=-1\n"), 0644)
+	c.Assert(err, IsNil)
+	updated, err = boot.UpdateCommandLineFromGadget(gadgetDir)
+	c.Assert(err, IsNil)
+	c.Check(updated, Equals, true)
+	c.Check(s.bootloader.BootVars["snapd_extra_cmdline_args"], Equals, "")
+	c.Check(s.bootloader.BootVars["snapd_full_cmdline_args"], Equals, "console=tty1 panic=-1")
+
+	// no boot chains tracked
+	c.Check(s.resealed, HasLen, 0)
+}
+
+func (s *bootChainsSuite) TestUpdateCommandLineFromGadgetReseals(c *C) {
+	chain := boot.BootChain{
+		AssetChain:     []boot.BootAsset{shim, grub},
+		Kernel:         "pc-kernel",
+		KernelRevision: "1",
+		KernelCmdlines: []string{"snapd_recovery_mode=run"},
+	}
+	c.Assert(boot.UpdateBootChains([]boot.BootChain{chain}, nil), IsNil)
+	c.Assert(s.resealed, HasLen, 1)
+
+	gadgetDir := c.MkDir()
+	err := ioutil.WriteFile(filepath.Join(gadgetDir, "cmdline.extra"), []byte("console=ttyS0"), 0644)
+	c.Assert(err, IsNil)
+	updated, err := boot.UpdateCommandLineFromGadget(gadgetDir)
+	c.Assert(err, IsNil)
+	c.Check(updated, Equals, true)
+
+	c.Assert(s.resealed, HasLen, 2)
+	chains, err := boot.ReadBootChains()
+	c.Assert(err, IsNil)
+	c.Assert(chains.Current, HasLen, 1)
+	// either command line can be booted
+	c.Check(chains.Current[0].KernelCmdlines, DeepEquals, []string{
+		"snapd_recovery_mode=run",
+		"snapd_recovery_mode=run console=ttyS0",
+	})
+
+	// once changed again, the command line without the gadget arguments
+	// is dropped
+	err = ioutil.WriteFile(filepath.Join(gadgetDir, "cmdline.extra"), []byte("console=ttyS0 quiet"), 0644)
+	c.Assert(err, IsNil)
+	updated, err = boot.UpdateCommandLineFromGadget(gadgetDir)
+	c.Assert(err, IsNil)
+	c.Check(updated, Equals, true)
+
+	c.Assert(s.resealed, HasLen, 3)
+	chains, err = boot.ReadBootChains()
+	c.Assert(err, IsNil)
+	c.Check(chains.Current[0].KernelCmdlines, DeepEquals, []string{
+		"snapd_recovery_mode=run console=ttyS0",
+		"snapd_recovery_mode=run console=ttyS0 quiet",
+	})
+	c.Check(s.bootloader.BootVars["snapd_extra_cmdline_args"], Equals, "console=ttyS0 quiet")
+}
+
+func (s *bootChainsSuite) TestUpdateCommandLineFromGadgetResealError(c *C) {
+	c.Assert(boot.UpdateBootChains([]boot.BootChain{pcKernel1}, nil), IsNil)
+	s.resealErr = errors.New("boom")
+
+	gadgetDir := c.MkDir()
+	err := ioutil.WriteFile(filepath.Join(gadgetDir, "cmdline.extra"), []byte("console=ttyS0"), 0644)
+	c.Assert(err, IsNil)
+	_, err = boot.UpdateCommandLineFromGadget(gadgetDir)
+	c.Assert(err, ErrorMatches, "cannot update kernel command line: cannot reseal keys: boom")
+	// the boot environment is left untouched
+	c.Check(s.bootloader.BootVars["snapd_extra_cmdline_args"], Equals, "")
+}
+
+func (s *bootChainsSuite) TestUpdateCommandLineFromGadgetInvalid(c *C) {
+	gadgetDir := c.MkDir()
+	err := ioutil.WriteFile(filepath.Join(gadgetDir, "cmdline.extra"), []byte("init=/bin/sh"), 0644)
+	c.Assert(err, IsNil)
+	_, err = boot.UpdateCommandLineFromGadget(gadgetDir)
+	c.Assert(err, ErrorMatches, `cannot update kernel command line: invalid kernel command line in cmdline.extra: argument "init" is not allowed`)
+}
+
+// noCommandLineBootloader is a bootloader whose boot configuration does not
+// apply the kernel command line arguments from the boot environment.
+type noCommandLineBootloader struct {
+	bootloader.Bootloader
+}
+
+func (s *bootChainsSuite) TestUpdateCommandLineFromGadgetUnsupportedBootloader(c *C) {
+	bootloader.Force(noCommandLineBootloader{s.bootloader})
+
+	gadgetDir := c.MkDir()
+	err := ioutil.WriteFile(filepath.Join(gadgetDir, "cmdline.extra"), []byte("console=ttyS0"), 0644)
+	c.Assert(err, IsNil)
+	updated, err := boot.UpdateCommandLineFromGadget(gadgetDir)
+	c.Assert(err, IsNil)
+	c.Check(updated, Equals, false)
+	c.Check(s.bootloader.BootVars["snapd_extra_cmdline_args"], Equals, "")
+}
@@ -36,3 +36,11 @@ func NewCoreKernel(s snap.PlaceInfo) *coreKernel {
 }
 
 type Trivial = trivial
+
+func MockProcCmdline(newPath string) (restore func()) {
+	oldProcCmdline := procCmdline
+	procCmdline = newPath
+	return func() {
+		procCmdline = oldProcCmdline
+	}
+}
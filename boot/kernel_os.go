@@ -68,6 +68,11 @@ func (bs *coreBootParticipant) SetNextBoot() error {
 		// sure to clean the snap_mode here. This also
 		// mitigates https://forum.snapcraft.io/t/5253
 		if m["snap_mode"] != "" {
+			if bs.t == snap.TypeKernel {
+				if err := clearTryBootChains(); err != nil {
+					return fmt.Errorf("cannot set next boot: %v", err)
+				}
+			}
 			return bootloader.SetBootVars(map[string]string{
 				"snap_mode": "",
 				nextBoot:    "",
@@ -76,6 +81,14 @@ func (bs *coreBootParticipant) SetNextBoot() error {
 		return nil
 	}
 
+	if bs.t == snap.TypeKernel {
+		// the keys need to be resealed against the kernel to try
+		// before rebooting into it
+		if err := setTryKernelBootChains(blobName); err != nil {
+			return fmt.Errorf("cannot set next boot: %v", err)
+		}
+	}
+
 	return bootloader.SetBootVars(map[string]string{
 		nextBoot:    blobName,
 		"snap_mode": "try",
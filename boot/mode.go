@@ -0,0 +1,81 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	// ModeRun is the mode of a system booted normally.
+	ModeRun = "run"
+	// ModeInstall is the mode of a system booted from a recovery
+	// system to install the run system.
+	ModeInstall = "install"
+	// ModeRecover is the mode of a system booted from a recovery
+	// system to recover the run system.
+	ModeRecover = "recover"
+)
+
+var procCmdline = "/proc/cmdline"
+
+// SystemMode returns the mode the system was booted in, as set with
+// snapd_recovery_mode on the kernel command line. Systems booted without
+// it are in run mode.
+func SystemMode() (string, error) {
+	mode, _, err := ModeAndRecoverySystemFromKernelCommandLine()
+	return mode, err
+}
+
+// ModeAndRecoverySystemFromKernelCommandLine returns the mode the system was
+// booted in along with the label of the recovery system it was booted from,
+// as set with snapd_recovery_mode and snapd_recovery_system on the kernel
+// command line. The recovery system is required in install and recover
+// modes.
+func ModeAndRecoverySystemFromKernelCommandLine() (mode, sysLabel string, err error) {
+	cmdline, err := ioutil.ReadFile(procCmdline)
+	if err != nil {
+		return "", "", err
+	}
+	mode = ModeRun
+	for _, arg := range strings.Fields(string(cmdline)) {
+		switch {
+		case strings.HasPrefix(arg, "snapd_recovery_mode="):
+			mode = strings.TrimPrefix(arg, "snapd_recovery_mode=")
+			if mode == "" {
+				mode = ModeRun
+			}
+		case strings.HasPrefix(arg, "snapd_recovery_system="):
+			sysLabel = strings.TrimPrefix(arg, "snapd_recovery_system=")
+		}
+	}
+	switch mode {
+	case ModeRun:
+		return mode, sysLabel, nil
+	case ModeInstall, ModeRecover:
+		if sysLabel == "" {
+			return "", "", fmt.Errorf("cannot use %s mode without a recovery system", mode)
+		}
+		return mode, sysLabel, nil
+	}
+	return "", "", fmt.Errorf("cannot use unknown mode %q", mode)
+}
@@ -0,0 +1,101 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/boot"
+)
+
+type modeSuite struct{}
+
+var _ = Suite(&modeSuite{})
+
+func (s *modeSuite) TestSystemMode(c *C) {
+	cmdline := filepath.Join(c.MkDir(), "cmdline")
+	restore := boot.MockProcCmdline(cmdline)
+	defer restore()
+
+	for _, tc := range []struct {
+		cmdline string
+		mode    string
+	}{
+		{"BOOT_IMAGE=/vmlinuz root=/dev/sda2 ro quiet", boot.ModeRun},
+		{"snapd_recovery_mode=install snapd_recovery_system=20191118", boot.ModeInstall},
+		{"console=ttyS0 snapd_recovery_mode=recover snapd_recovery_system=20191118\n", boot.ModeRecover},
+		{"snapd_recovery_mode=run console=ttyS0", boot.ModeRun},
+		{"snapd_recovery_mode= quiet", boot.ModeRun},
+	} {
+		c.Assert(ioutil.WriteFile(cmdline, []byte(tc.cmdline), 0644), IsNil)
+		mode, err := boot.SystemMode()
+		c.Assert(err, IsNil)
+		c.Check(mode, Equals, tc.mode, Commentf("%q", tc.cmdline))
+	}
+}
+
+func (s *modeSuite) TestSystemModeError(c *C) {
+	restore := boot.MockProcCmdline(filepath.Join(c.MkDir(), "missing"))
+	defer restore()
+
+	_, err := boot.SystemMode()
+	c.Check(err, ErrorMatches, "open .*/missing: no such file or directory")
+
+	cmdline := filepath.Join(c.MkDir(), "cmdline")
+	restore = boot.MockProcCmdline(cmdline)
+	defer restore()
+	c.Assert(ioutil.WriteFile(cmdline, []byte("snapd_recovery_mode=install"), 0644), IsNil)
+	_, err = boot.SystemMode()
+	c.Check(err, ErrorMatches, "cannot use install mode without a recovery system")
+}
+
+func (s *modeSuite) TestModeAndRecoverySystemFromKernelCommandLine(c *C) {
+	cmdline := filepath.Join(c.MkDir(), "cmdline")
+	restore := boot.MockProcCmdline(cmdline)
+	defer restore()
+
+	for _, tc := range []struct {
+		cmdline  string
+		mode     string
+		sysLabel string
+		err      string
+	}{
+		{cmdline: "BOOT_IMAGE=/vmlinuz root=/dev/sda2 ro quiet", mode: boot.ModeRun},
+		{cmdline: "snapd_recovery_mode=run snapd_recovery_system=20191118", mode: boot.ModeRun, sysLabel: "20191118"},
+		{cmdline: "snapd_recovery_mode=install snapd_recovery_system=20191118", mode: boot.ModeInstall, sysLabel: "20191118"},
+		{cmdline: "snapd_recovery_system=20191119 console=ttyS0 snapd_recovery_mode=recover\n", mode: boot.ModeRecover, sysLabel: "20191119"},
+		{cmdline: "snapd_recovery_mode=install", err: "cannot use install mode without a recovery system"},
+		{cmdline: "snapd_recovery_mode=recover snapd_recovery_system=", err: "cannot use recover mode without a recovery system"},
+		{cmdline: "snapd_recovery_mode=other snapd_recovery_system=20191118", err: `cannot use unknown mode "other"`},
+	} {
+		c.Assert(ioutil.WriteFile(cmdline, []byte(tc.cmdline), 0644), IsNil)
+		mode, sysLabel, err := boot.ModeAndRecoverySystemFromKernelCommandLine()
+		if tc.err != "" {
+			c.Check(err, ErrorMatches, tc.err, Commentf("%q", tc.cmdline))
+			continue
+		}
+		c.Assert(err, IsNil)
+		c.Check(mode, Equals, tc.mode, Commentf("%q", tc.cmdline))
+		c.Check(sysLabel, Equals, tc.sysLabel, Commentf("%q", tc.cmdline))
+	}
+}
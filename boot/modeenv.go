@@ -0,0 +1,129 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// Modeenv is the environment shared between the initramfs and snapd,
+// recording the mode of the system and the snaps it boots with.
+type Modeenv struct {
+	Mode           string
+	RecoverySystem string
+	Base           string
+	Kernel         string
+	// FactoryReset is set in install mode when the writable data of
+	// the system is to be wiped while installing the run system.
+	FactoryReset bool
+}
+
+func modeenvFile(rootdir string) string {
+	if rootdir == "" {
+		rootdir = dirs.GlobalRootDir
+	}
+	return dirs.SnapModeenvFileUnder(rootdir)
+}
+
+// ReadModeenv reads the modeenv file under rootdir, or under the global
+// root directory when rootdir is empty.
+func ReadModeenv(rootdir string) (*Modeenv, error) {
+	data, err := ioutil.ReadFile(modeenvFile(rootdir))
+	if err != nil {
+		return nil, err
+	}
+
+	var m Modeenv
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("cannot parse modeenv line %q", line)
+		}
+		switch kv[0] {
+		case "mode":
+			m.Mode = kv[1]
+		case "recovery_system":
+			m.RecoverySystem = kv[1]
+		case "base":
+			m.Base = kv[1]
+		case "current_kernel":
+			m.Kernel = kv[1]
+		case "factory_reset":
+			factoryReset, err := strconv.ParseBool(kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse modeenv factory_reset %q", kv[1])
+			}
+			m.FactoryReset = factoryReset
+		}
+		// unknown keys are ignored so that newer snapd can
+		// extend the modeenv
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if m.Mode == "" {
+		return nil, fmt.Errorf("internal error: mode is unset")
+	}
+	return &m, nil
+}
+
+// Write writes the modeenv file under rootdir, or under the global root
+// directory when rootdir is empty.
+func (m *Modeenv) Write(rootdir string) error {
+	if m.Mode == "" {
+		return fmt.Errorf("internal error: mode is unset")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "mode=%s\n", m.Mode)
+	for _, kv := range []struct{ key, value string }{
+		{"recovery_system", m.RecoverySystem},
+		{"base", m.Base},
+		{"current_kernel", m.Kernel},
+	} {
+		if kv.value != "" {
+			fmt.Fprintf(&buf, "%s=%s\n", kv.key, kv.value)
+		}
+	}
+	if m.FactoryReset {
+		fmt.Fprintf(&buf, "factory_reset=true\n")
+	}
+
+	fname := modeenvFile(rootdir)
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(fname, buf.Bytes(), 0644, 0)
+}
@@ -0,0 +1,124 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type modeenvSuite struct {
+	testutil.BaseTest
+
+	tmpdir string
+}
+
+var _ = Suite(&modeenvSuite{})
+
+func (s *modeenvSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.tmpdir = c.MkDir()
+	dirs.SetRootDir(s.tmpdir)
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+}
+
+func (s *modeenvSuite) TestReadMissing(c *C) {
+	_, err := boot.ReadModeenv("")
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (s *modeenvSuite) TestReadMode(c *C) {
+	fname := dirs.SnapModeenvFileUnder(s.tmpdir)
+	c.Assert(os.MkdirAll(filepath.Dir(fname), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(fname, []byte(`# comment
+mode=run
+recovery_system=20191126
+base=core20_123.snap
+current_kernel=pc-kernel_1.snap
+unknown=ignored
+`), 0644), IsNil)
+
+	modeenv, err := boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	c.Check(modeenv, DeepEquals, &boot.Modeenv{
+		Mode:           "run",
+		RecoverySystem: "20191126",
+		Base:           "core20_123.snap",
+		Kernel:         "pc-kernel_1.snap",
+	})
+}
+
+func (s *modeenvSuite) TestReadErrors(c *C) {
+	fname := dirs.SnapModeenvFileUnder(s.tmpdir)
+	c.Assert(os.MkdirAll(filepath.Dir(fname), 0755), IsNil)
+
+	c.Assert(ioutil.WriteFile(fname, []byte("base=core20_123.snap\n"), 0644), IsNil)
+	_, err := boot.ReadModeenv("")
+	c.Check(err, ErrorMatches, "internal error: mode is unset")
+
+	c.Assert(ioutil.WriteFile(fname, []byte("mode\n"), 0644), IsNil)
+	_, err = boot.ReadModeenv("")
+	c.Check(err, ErrorMatches, `cannot parse modeenv line "mode"`)
+
+	c.Assert(ioutil.WriteFile(fname, []byte("mode=install\nfactory_reset=maybe\n"), 0644), IsNil)
+	_, err = boot.ReadModeenv("")
+	c.Check(err, ErrorMatches, `cannot parse modeenv factory_reset "maybe"`)
+}
+
+func (s *modeenvSuite) TestWriteRoundtrip(c *C) {
+	rootdir := c.MkDir()
+	modeenv := &boot.Modeenv{
+		Mode:           "install",
+		RecoverySystem: "20191126",
+	}
+	c.Assert(modeenv.Write(rootdir), IsNil)
+	c.Check(dirs.SnapModeenvFileUnder(rootdir), testutil.FileEquals, "mode=install\nrecovery_system=20191126\n")
+
+	read, err := boot.ReadModeenv(rootdir)
+	c.Assert(err, IsNil)
+	c.Check(read, DeepEquals, modeenv)
+}
+
+func (s *modeenvSuite) TestWriteRoundtripFactoryReset(c *C) {
+	rootdir := c.MkDir()
+	modeenv := &boot.Modeenv{
+		Mode:           "install",
+		RecoverySystem: "20191126",
+		FactoryReset:   true,
+	}
+	c.Assert(modeenv.Write(rootdir), IsNil)
+	c.Check(dirs.SnapModeenvFileUnder(rootdir), testutil.FileEquals, "mode=install\nrecovery_system=20191126\nfactory_reset=true\n")
+
+	read, err := boot.ReadModeenv(rootdir)
+	c.Assert(err, IsNil)
+	c.Check(read, DeepEquals, modeenv)
+}
+
+func (s *modeenvSuite) TestWriteNoMode(c *C) {
+	err := (&boot.Modeenv{Base: "core20_1.snap"}).Write("")
+	c.Check(err, ErrorMatches, "internal error: mode is unset")
+}
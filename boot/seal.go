@@ -0,0 +1,121 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/secboot"
+)
+
+func init() {
+	ResealKeys = resealKeyToBootChains
+}
+
+// SealedKeyFile returns the path of the sealed key of the encrypted data
+// partition.
+func SealedKeyFile() string {
+	return filepath.Join(dirs.SnapDeviceDir, "ubuntu-data.sealed-key")
+}
+
+// bootAssetPath returns the path of the cached copy of the given version
+// of a boot asset.
+func bootAssetPath(asset BootAsset, hash string) string {
+	return filepath.Join(dirs.SnapBootAssetsDir, asset.Role, fmt.Sprintf("%s-%s", asset.Name, hash))
+}
+
+func kernelBootFile(chain BootChain) secboot.BootFile {
+	blob := filepath.Join(dirs.SnapBlobDir, fmt.Sprintf("%s_%s.snap", chain.Kernel, chain.KernelRevision))
+	return secboot.NewBootFile(blob, "kernel.efi")
+}
+
+// loadChains returns the load chains for the given assets, with every
+// acceptable version of each asset able to load every acceptable version
+// of the next one, and the last one loading the kernel.
+func loadChains(assets []BootAsset, kernel secboot.BootFile) []*secboot.LoadChain {
+	if len(assets) == 0 {
+		return []*secboot.LoadChain{secboot.NewLoadChain(kernel)}
+	}
+	next := loadChains(assets[1:], kernel)
+	chains := make([]*secboot.LoadChain, 0, len(assets[0].Hashes))
+	for _, hash := range assets[0].Hashes {
+		bf := secboot.NewBootFile("", bootAssetPath(assets[0], hash))
+		chains = append(chains, secboot.NewLoadChain(bf, next...))
+	}
+	return chains
+}
+
+func sealKeyModelParams(chains []BootChain) (*secboot.SealKeyModelParams, error) {
+	if len(chains) == 0 {
+		return nil, fmt.Errorf("internal error: no boot chains to seal the key against")
+	}
+	params := &secboot.SealKeyModelParams{}
+	cmdlines := make(map[string]bool)
+	for _, chain := range chains {
+		params.EFILoadChains = append(params.EFILoadChains, loadChains(chain.AssetChain, kernelBootFile(chain))...)
+		for _, cmdline := range chain.KernelCmdlines {
+			if !cmdlines[cmdline] {
+				cmdlines[cmdline] = true
+				params.KernelCmdlines = append(params.KernelCmdlines, cmdline)
+			}
+		}
+	}
+	sort.Strings(params.KernelCmdlines)
+	return params, nil
+}
+
+// SealKeyToBootChains seals the given encryption key, as used for the data
+// partition, against the given boot chains and records them as the current
+// ones.
+func SealKeyToBootChains(key secboot.EncryptionKey, chains []BootChain) error {
+	chains = toPredictable(chains)
+	modelParams, err := sealKeyModelParams(chains)
+	if err != nil {
+		return err
+	}
+	params := &secboot.SealKeyParams{
+		ModelParams: []*secboot.SealKeyModelParams{modelParams},
+		KeyFile:     SealedKeyFile(),
+	}
+	if err := secboot.SealKey(key, params); err != nil {
+		return err
+	}
+	return (&BootChains{Current: chains}).write("")
+}
+
+// resealKeyToBootChains updates the policy of the sealed key, if there is
+// one, so that it can be unsealed by any of the given boot chains.
+func resealKeyToBootChains(chains []BootChain) error {
+	if !osutil.FileExists(SealedKeyFile()) {
+		return nil
+	}
+	modelParams, err := sealKeyModelParams(chains)
+	if err != nil {
+		return err
+	}
+	return secboot.ResealKey(&secboot.ResealKeyParams{
+		ModelParams: []*secboot.SealKeyModelParams{modelParams},
+		KeyFile:     SealedKeyFile(),
+	})
+}
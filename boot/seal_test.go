@@ -0,0 +1,160 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/secboot"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type sealTPM struct {
+	keys     map[string]secboot.EncryptionKey
+	profiles map[string]*secboot.PCRProfile
+}
+
+func (t *sealTPM) Seal(key secboot.EncryptionKey, keyFile string, profile *secboot.PCRProfile) error {
+	t.keys[keyFile] = key
+	t.profiles[keyFile] = profile
+	// like the real thing, write out the sealed key object
+	return ioutil.WriteFile(keyFile, []byte("sealed"), 0600)
+}
+
+func (t *sealTPM) UpdatePolicy(keyFile string, profile *secboot.PCRProfile) error {
+	t.profiles[keyFile] = profile
+	return nil
+}
+
+func (t *sealTPM) Unseal(keyFile string) (secboot.EncryptionKey, error) {
+	return t.keys[keyFile], nil
+}
+
+func (t *sealTPM) Close() error {
+	return nil
+}
+
+type sealSuite struct {
+	baseBootSetSuite
+
+	tpm *sealTPM
+}
+
+var _ = Suite(&sealSuite{})
+
+func (s *sealSuite) SetUpTest(c *C) {
+	s.baseBootSetSuite.SetUpTest(c)
+	c.Assert(os.MkdirAll(dirs.SnapDeviceDir, 0755), IsNil)
+
+	s.tpm = &sealTPM{
+		keys:     make(map[string]secboot.EncryptionKey),
+		profiles: make(map[string]*secboot.PCRProfile),
+	}
+	oldConnect := secboot.ConnectToTPM
+	secboot.ConnectToTPM = func() (secboot.TPM, error) { return s.tpm, nil }
+	s.AddCleanup(func() { secboot.ConnectToTPM = oldConnect })
+	s.AddCleanup(secboot.MockReadSnapFile(func(snapPath, path string) ([]byte, error) {
+		return []byte(filepath.Base(snapPath) + ":" + path), nil
+	}))
+	s.AddCleanup(secboot.MockAuthenticodeDigest(func(image []byte) ([]byte, error) {
+		digest := sha256.Sum256(image)
+		return digest[:], nil
+	}))
+
+	for _, asset := range []boot.BootAsset{shim, grub} {
+		dir := filepath.Join(dirs.SnapBootAssetsDir, asset.Role)
+		c.Assert(os.MkdirAll(dir, 0755), IsNil)
+		for _, hash := range asset.Hashes {
+			err := ioutil.WriteFile(filepath.Join(dir, asset.Name+"-"+hash), []byte(hash), 0644)
+			c.Assert(err, IsNil)
+		}
+	}
+}
+
+func sealDigest(content string) string {
+	digest := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(digest[:])
+}
+
+func (s *sealSuite) TestSealKeyToBootChains(c *C) {
+	key, err := secboot.NewEncryptionKey()
+	c.Assert(err, IsNil)
+
+	err = boot.SealKeyToBootChains(key, []boot.BootChain{recoveryKernel, pcKernel1})
+	c.Assert(err, IsNil)
+
+	kernel := sealDigest("pc-kernel_1.snap:kernel.efi")
+	c.Check(s.tpm.keys[boot.SealedKeyFile()], Equals, key)
+	c.Check(s.tpm.profiles[boot.SealedKeyFile()], DeepEquals, &secboot.PCRProfile{
+		LoadSequences: [][]string{
+			{sealDigest("shim-hash"), sealDigest("grub-hash-1"), kernel},
+			{sealDigest("shim-hash"), sealDigest("grub-hash-2"), kernel},
+			{sealDigest("shim-hash"), kernel},
+		},
+		KernelCmdlines: []string{
+			"snapd_recovery_mode=recover",
+			"snapd_recovery_mode=run",
+			"snapd_recovery_mode=run console=ttyS0",
+		},
+	})
+
+	chains, err := boot.ReadBootChains()
+	c.Assert(err, IsNil)
+	c.Check(chains.Current, HasLen, 2)
+	c.Check(chains.Try, HasLen, 0)
+}
+
+func (s *sealSuite) TestResealWhenBootChainsChange(c *C) {
+	err := boot.SealKeyToBootChains(secboot.EncryptionKey{}, []boot.BootChain{pcKernel1})
+	c.Assert(err, IsNil)
+
+	pcKernel2 := pcKernel1
+	pcKernel2.KernelRevision = "2"
+	err = boot.UpdateBootChains([]boot.BootChain{pcKernel1}, []boot.BootChain{pcKernel2})
+	c.Assert(err, IsNil)
+
+	profile := s.tpm.profiles[boot.SealedKeyFile()]
+	c.Assert(profile.LoadSequences, HasLen, 4)
+	c.Check(profile.LoadSequences[0][2], Equals, sealDigest("pc-kernel_1.snap:kernel.efi"))
+	c.Check(profile.LoadSequences[2][2], Equals, sealDigest("pc-kernel_2.snap:kernel.efi"))
+
+	chains, err := boot.ReadBootChains()
+	c.Assert(err, IsNil)
+	c.Check(chains.ResealCount, Equals, 1)
+}
+
+func (s *sealSuite) TestResealWithoutSealedKey(c *C) {
+	secboot.ConnectToTPM = func() (secboot.TPM, error) {
+		c.Fatalf("unexpected TPM use")
+		return nil, nil
+	}
+
+	err := boot.UpdateBootChains([]boot.BootChain{pcKernel1}, nil)
+	c.Assert(err, IsNil)
+	c.Check(boot.SealedKeyFile(), testutil.FileAbsent)
+}
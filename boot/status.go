@@ -0,0 +1,89 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/bootloader"
+)
+
+const (
+	// KernelCommitted is the status when the default kernel is the one
+	// that was last tried successfully, or no kernel was tried at all.
+	KernelCommitted = "committed"
+	// KernelTryPending is the status when a kernel will be tried on the
+	// next boot.
+	KernelTryPending = "pending"
+	// KernelTrying is the status when the kernel being tried was booted
+	// but the boot was not marked as successful yet.
+	KernelTrying = "trying"
+	// KernelReverted is the status when the kernel that was tried failed
+	// to boot and the bootloader went back to the default one.
+	KernelReverted = "reverted"
+)
+
+// KernelStatus describes the state of kernel updates as tracked by the
+// bootloader.
+type KernelStatus struct {
+	// Kernel is the kernel blob the bootloader boots by default.
+	Kernel string `json:"kernel"`
+	// TryKernel is the kernel blob that was set to be tried, if any.
+	TryKernel string `json:"try-kernel,omitempty"`
+	// Status is one of KernelCommitted, KernelTryPending, KernelTrying
+	// and KernelReverted.
+	Status string `json:"status"`
+}
+
+// GetKernelStatus returns the status of the last kernel update as tracked by
+// the bootloader, which allows confirming whether an update was committed or
+// automatically reverted after a reboot.
+func GetKernelStatus() (*KernelStatus, error) {
+	bl, err := bootloader.Find("", nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get kernel status: %s", err)
+	}
+	m, err := bl.GetBootVars("snap_mode", "snap_kernel", "snap_try_kernel")
+	if err != nil {
+		return nil, fmt.Errorf("cannot get kernel status: %s", err)
+	}
+
+	status := &KernelStatus{
+		Kernel:    m["snap_kernel"],
+		TryKernel: m["snap_try_kernel"],
+	}
+	switch m["snap_mode"] {
+	case "try":
+		status.Status = KernelTryPending
+	case "trying":
+		status.Status = KernelTrying
+	case "":
+		// the try kernel is cleared when its boot is marked as
+		// successful, a leftover one was reverted by the bootloader
+		if status.TryKernel != "" && status.TryKernel != status.Kernel {
+			status.Status = KernelReverted
+		} else {
+			status.Status = KernelCommitted
+		}
+	default:
+		return nil, fmt.Errorf("cannot get kernel status: unexpected snap_mode %q", m["snap_mode"])
+	}
+	return status, nil
+}
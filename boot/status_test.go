@@ -0,0 +1,92 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/bootloader/bootloadertest"
+)
+
+type kernelStatusSuite struct {
+	baseBootSetSuite
+
+	bootloader *bootloadertest.MockBootloader
+}
+
+var _ = Suite(&kernelStatusSuite{})
+
+func (s *kernelStatusSuite) SetUpTest(c *C) {
+	s.baseBootSetSuite.SetUpTest(c)
+
+	s.bootloader = bootloadertest.Mock("mock", c.MkDir())
+	bootloader.Force(s.bootloader)
+	s.AddCleanup(func() { bootloader.Force(nil) })
+}
+
+func (s *kernelStatusSuite) TestGetKernelStatus(c *C) {
+	for _, tc := range []struct {
+		mode, kernel, tryKernel string
+		status                  string
+	}{
+		{"", "pc-kernel_1.snap", "", boot.KernelCommitted},
+		{"try", "pc-kernel_1.snap", "pc-kernel_2.snap", boot.KernelTryPending},
+		{"trying", "pc-kernel_1.snap", "pc-kernel_2.snap", boot.KernelTrying},
+		{"", "pc-kernel_1.snap", "pc-kernel_2.snap", boot.KernelReverted},
+		// reverting to the kernel that was tried before
+		{"", "pc-kernel_1.snap", "pc-kernel_1.snap", boot.KernelCommitted},
+	} {
+		s.bootloader.BootVars["snap_mode"] = tc.mode
+		s.bootloader.BootVars["snap_kernel"] = tc.kernel
+		s.bootloader.BootVars["snap_try_kernel"] = tc.tryKernel
+
+		status, err := boot.GetKernelStatus()
+		c.Assert(err, IsNil)
+		c.Check(status, DeepEquals, &boot.KernelStatus{
+			Kernel:    tc.kernel,
+			TryKernel: tc.tryKernel,
+			Status:    tc.status,
+		})
+	}
+}
+
+func (s *kernelStatusSuite) TestGetKernelStatusAfterMarkBootSuccessful(c *C) {
+	s.bootloader.BootVars["snap_mode"] = "trying"
+	s.bootloader.BootVars["snap_kernel"] = "pc-kernel_1.snap"
+	s.bootloader.BootVars["snap_try_kernel"] = "pc-kernel_2.snap"
+
+	c.Assert(boot.MarkBootSuccessful(), IsNil)
+
+	status, err := boot.GetKernelStatus()
+	c.Assert(err, IsNil)
+	c.Check(status, DeepEquals, &boot.KernelStatus{
+		Kernel: "pc-kernel_2.snap",
+		Status: boot.KernelCommitted,
+	})
+}
+
+func (s *kernelStatusSuite) TestGetKernelStatusUnexpectedMode(c *C) {
+	s.bootloader.BootVars["snap_mode"] = "potato"
+
+	_, err := boot.GetKernelStatus()
+	c.Assert(err, ErrorMatches, `cannot get kernel status: unexpected snap_mode "potato"`)
+}
@@ -20,7 +20,6 @@
 package bootloader
 
 import (
-	"os"
 	"path/filepath"
 
 	"github.com/snapcore/snapd/bootloader/androidbootenv"
@@ -61,28 +60,11 @@ func (a *androidboot) ConfigFile() string {
 }
 
 func (a *androidboot) GetBootVars(names ...string) (map[string]string, error) {
-	env := androidbootenv.NewEnv(a.ConfigFile())
-	if err := env.Load(); err != nil {
-		return nil, err
-	}
-
-	out := make(map[string]string, len(names))
-	for _, name := range names {
-		out[name] = env.Get(name)
-	}
-
-	return out, nil
+	return getBootVars(androidbootenv.NewEnv(a.ConfigFile()), names...)
 }
 
 func (a *androidboot) SetBootVars(values map[string]string) error {
-	env := androidbootenv.NewEnv(a.ConfigFile())
-	if err := env.Load(); err != nil && !os.IsNotExist(err) {
-		return err
-	}
-	for k, v := range values {
-		env.Set(k, v)
-	}
-	return env.Save()
+	return setBootVars(androidbootenv.NewEnv(a.ConfigFile()), values)
 }
 
 func (a *androidboot) ExtractKernelAssets(s snap.PlaceInfo, snapf snap.Container) error {
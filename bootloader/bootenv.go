@@ -0,0 +1,62 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package bootloader
+
+import (
+	"os"
+)
+
+// bootEnv is the storage of the boot variables of a bootloader. It can be
+// an environment read by the bootloader itself, like grubenv, or one from
+// which the configuration of a bootloader that cannot be scripted is
+// rendered, like the one of the Raspberry Pi firmware.
+type bootEnv interface {
+	Get(name string) string
+	Set(name, value string)
+	Load() error
+	Save() error
+}
+
+// getBootVars returns the values of the given variables from the boot
+// environment.
+func getBootVars(env bootEnv, names ...string) (map[string]string, error) {
+	if err := env.Load(); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]string, len(names))
+	for _, name := range names {
+		out[name] = env.Get(name)
+	}
+
+	return out, nil
+}
+
+// setBootVars sets the given variables in the boot environment, creating
+// it if needed.
+func setBootVars(env bootEnv, values map[string]string) error {
+	if err := env.Load(); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for k, v := range values {
+		env.Set(k, v)
+	}
+	return env.Save()
+}
@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
@@ -57,6 +58,43 @@ type Bootloader interface {
 	RemoveKernelAssets(s snap.PlaceInfo) error
 }
 
+// RebootBootloader is implemented by bootloaders that need arguments to be
+// passed to the reboot, for example to boot a try kernel.
+type RebootBootloader interface {
+	Bootloader
+
+	// GetRebootArguments returns the arguments for the next reboot, or
+	// "" if none are needed.
+	GetRebootArguments() (string, error)
+}
+
+// CommandLineBootloader is implemented by bootloaders whose boot
+// configuration builds the kernel command line with the arguments from the
+// snapd_extra_cmdline_args and snapd_full_cmdline_args boot variables.
+type CommandLineBootloader interface {
+	Bootloader
+
+	// SupportsCommandLineArgs returns whether the boot configuration
+	// applies the kernel command line arguments from the boot variables.
+	SupportsCommandLineArgs() bool
+}
+
+// commandLineVars are the boot variables carrying the kernel command line
+// arguments, see CommandLineBootloader.
+var commandLineVars = []string{"snapd_extra_cmdline_args", "snapd_full_cmdline_args"}
+
+// refersToCommandLineVars returns whether the given boot configuration
+// refers to all of the boot variables carrying the kernel command line
+// arguments, that is whether it applies them.
+func refersToCommandLineVars(config string) bool {
+	for _, name := range commandLineVars {
+		if !strings.Contains(config, name) {
+			return false
+		}
+	}
+	return true
+}
+
 type installableBootloader interface {
 	Bootloader
 	setRootDir(string)
@@ -65,7 +103,7 @@ type installableBootloader interface {
 // InstallBootConfig installs the bootloader config from the gadget
 // snap dir into the right place.
 func InstallBootConfig(gadgetDir, rootDir string) error {
-	for _, bl := range []installableBootloader{&grub{}, &uboot{}, &androidboot{}, &lk{}} {
+	for _, bl := range []installableBootloader{&grub{}, &uboot{}, &androidboot{}, &lk{}, &piboot{}} {
 		// the bootloader config file has to be root of the gadget snap
 		gadgetFile := filepath.Join(gadgetDir, bl.Name()+".conf")
 		if !osutil.FileExists(gadgetFile) {
@@ -131,6 +169,11 @@ func Find(rootdir string, opts *Options) (Bootloader, error) {
 		return lk, nil
 	}
 
+	// no, try piboot
+	if piboot := newPiboot(rootdir); piboot != nil {
+		return piboot, nil
+	}
+
 	// no, weeeee
 	return nil, ErrBootloader
 }
@@ -101,6 +101,7 @@ func (s *bootenvTestSuite) TestInstallBootloaderConfig(c *C) {
 		{"uboot.conf", "/boot/uboot/uboot.env"},
 		{"androidboot.conf", "/boot/androidboot/androidboot.env"},
 		{"lk.conf", "/boot/lk/snapbootsel.bin"},
+		{"piboot.conf", "/boot/piboot/piboot.conf"},
 	} {
 		mockGadgetDir := c.MkDir()
 		err := ioutil.WriteFile(filepath.Join(mockGadgetDir, t.gadgetFile), nil, 0644)
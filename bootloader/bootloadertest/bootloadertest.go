@@ -72,6 +72,10 @@ func (b *MockBootloader) Name() string {
 	return b.name
 }
 
+func (b *MockBootloader) SupportsCommandLineArgs() bool {
+	return true
+}
+
 func (b *MockBootloader) ConfigFile() string {
 	return filepath.Join(b.bootdir, "mockboot/mockboot.cfg")
 }
@@ -100,3 +100,15 @@ func LkRuntimeMode(b Bootloader) bool {
 	lk := b.(*lk)
 	return lk.inRuntimeMode
 }
+
+func NewPiboot(rootdir string) Bootloader {
+	return newPiboot(rootdir)
+}
+
+func MockPibootFiles(c *C, rootdir string) {
+	p := &piboot{rootdir: rootdir}
+	err := os.MkdirAll(p.dir(), 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(p.envFile(), nil, 0644)
+	c.Assert(err, IsNil)
+}
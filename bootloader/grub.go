@@ -20,7 +20,7 @@
 package bootloader
 
 import (
-	"os"
+	"io/ioutil"
 	"path/filepath"
 
 	"github.com/snapcore/snapd/bootloader/grubenv"
@@ -46,6 +46,17 @@ func (g *grub) Name() string {
 	return "grub"
 }
 
+// SupportsCommandLineArgs returns whether grub.cfg builds the kernel
+// command line with the arguments from the boot variables, older boot
+// configurations do not.
+func (g *grub) SupportsCommandLineArgs() bool {
+	config, err := ioutil.ReadFile(g.ConfigFile())
+	if err != nil {
+		return false
+	}
+	return refersToCommandLineVars(string(config))
+}
+
 func (g *grub) setRootDir(rootdir string) {
 	g.rootdir = rootdir
 }
@@ -66,29 +77,11 @@ func (g *grub) envFile() string {
 }
 
 func (g *grub) GetBootVars(names ...string) (map[string]string, error) {
-	out := make(map[string]string)
-
-	env := grubenv.NewEnv(g.envFile())
-	if err := env.Load(); err != nil {
-		return nil, err
-	}
-
-	for _, name := range names {
-		out[name] = env.Get(name)
-	}
-
-	return out, nil
+	return getBootVars(grubenv.NewEnv(g.envFile()), names...)
 }
 
 func (g *grub) SetBootVars(values map[string]string) error {
-	env := grubenv.NewEnv(g.envFile())
-	if err := env.Load(); err != nil && !os.IsNotExist(err) {
-		return err
-	}
-	for k, v := range values {
-		env.Set(k, v)
-	}
-	return env.Save()
+	return setBootVars(grubenv.NewEnv(g.envFile()), values)
 }
 
 func (g *grub) ExtractKernelAssets(s snap.PlaceInfo, snapf snap.Container) error {
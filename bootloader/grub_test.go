@@ -21,6 +21,7 @@ package bootloader_test
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os/exec"
 	"path/filepath"
 
@@ -103,6 +104,31 @@ func (s *grubTestSuite) TestNewGrub(c *C) {
 	g := bootloader.NewGrub(s.rootdir)
 	c.Assert(g, NotNil)
 	c.Assert(g.Name(), Equals, "grub")
+	cbl, ok := g.(bootloader.CommandLineBootloader)
+	c.Assert(ok, Equals, true)
+	// the config does not use the command line arguments
+	c.Check(cbl.SupportsCommandLineArgs(), Equals, false)
+}
+
+func (s *grubTestSuite) TestSupportsCommandLineArgs(c *C) {
+	s.makeFakeGrubEnv(c)
+	g := bootloader.NewGrub(s.rootdir)
+	c.Assert(g, NotNil)
+	cbl := g.(bootloader.CommandLineBootloader)
+
+	config := `set cmdline_args="$snapd_extra_cmdline_args"
+if [ -n "$snapd_full_cmdline_args" ]; then
+    set cmdline_args="$snapd_full_cmdline_args"
+fi
+`
+	err := ioutil.WriteFile(filepath.Join(s.rootdir, "boot/grub/grub.cfg"), []byte(config), 0644)
+	c.Assert(err, IsNil)
+	c.Check(cbl.SupportsCommandLineArgs(), Equals, true)
+
+	// both variables need to be used
+	err = ioutil.WriteFile(filepath.Join(s.rootdir, "boot/grub/grub.cfg"), []byte(`set cmdline_args="$snapd_extra_cmdline_args"`), 0644)
+	c.Assert(err, IsNil)
+	c.Check(cbl.SupportsCommandLineArgs(), Equals, false)
 }
 
 func (s *grubTestSuite) TestGetBootloaderWithGrub(c *C) {
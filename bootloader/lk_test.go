@@ -51,6 +51,9 @@ func (s *lkTestSuite) TestNewLk(c *C) {
 	c.Assert(l, NotNil)
 	c.Check(bootloader.LkRuntimeMode(l), Equals, true)
 	c.Check(l.ConfigFile(), Equals, filepath.Join(s.rootdir, "/dev/disk/by-partlabel", "snapbootsel"))
+	// the boot image is booted with the command line it carries
+	_, ok := l.(bootloader.CommandLineBootloader)
+	c.Check(ok, Equals, false)
 }
 
 func (s *lkTestSuite) TestNewLkImageBuildingTime(c *C) {
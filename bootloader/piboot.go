@@ -0,0 +1,121 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package bootloader
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/bootloader/pibootenv"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+)
+
+// piboot is the bootloader of systems booted directly by the Raspberry Pi
+// firmware, without u-boot.
+type piboot struct {
+	rootdir string
+}
+
+// newPiboot creates a new piboot bootloader object
+func newPiboot(rootdir string) Bootloader {
+	p := &piboot{rootdir: rootdir}
+	if !osutil.FileExists(p.envFile()) {
+		return nil
+	}
+	return p
+}
+
+func (p *piboot) Name() string {
+	return "piboot"
+}
+
+func (p *piboot) setRootDir(rootdir string) {
+	p.rootdir = rootdir
+}
+
+func (p *piboot) dir() string {
+	if p.rootdir == "" {
+		panic("internal error: unset rootdir")
+	}
+	return filepath.Join(p.rootdir, "/boot/piboot")
+}
+
+func (p *piboot) ConfigFile() string {
+	return p.envFile()
+}
+
+func (p *piboot) envFile() string {
+	return filepath.Join(p.dir(), "piboot.conf")
+}
+
+func (p *piboot) env() *pibootenv.Env {
+	return pibootenv.NewEnv(p.envFile(), p.dir())
+}
+
+// bootedWithTryboot returns whether the firmware booted using tryboot.txt,
+// as reported in the device tree.
+func (p *piboot) bootedWithTryboot() bool {
+	flag, err := ioutil.ReadFile(filepath.Join(p.rootdir, "/proc/device-tree/chosen/bootloader/tryboot"))
+	if err != nil || len(flag) != 4 {
+		return false
+	}
+	return binary.BigEndian.Uint32(flag) != 0
+}
+
+func (p *piboot) GetBootVars(names ...string) (map[string]string, error) {
+	out, err := getBootVars(p.env(), names...)
+	if err != nil {
+		return nil, err
+	}
+	// the firmware cannot move the try mode along like a boot script
+	// would, a try kernel booted by the firmware is being tried
+	if mode, ok := out["snap_mode"]; ok && mode == "try" && p.bootedWithTryboot() {
+		out["snap_mode"] = "trying"
+	}
+	return out, nil
+}
+
+func (p *piboot) SetBootVars(values map[string]string) error {
+	return setBootVars(p.env(), values)
+}
+
+// GetRebootArguments returns the arguments that make the firmware boot
+// using tryboot.txt on the next boot when a try kernel is staged. The
+// firmware falls back to config.txt on the boot after that by itself.
+func (p *piboot) GetRebootArguments() (string, error) {
+	m, err := getBootVars(p.env(), "snap_mode")
+	if err != nil {
+		return "", err
+	}
+	if m["snap_mode"] == "try" {
+		return "0 tryboot", nil
+	}
+	return "", nil
+}
+
+func (p *piboot) ExtractKernelAssets(s snap.PlaceInfo, snapf snap.Container) error {
+	return extractKernelAssetsToBootDir(p.dir(), s, snapf)
+}
+
+func (p *piboot) RemoveKernelAssets(s snap.PlaceInfo) error {
+	return removeKernelAssetsFromBootDir(p.dir(), s)
+}
@@ -0,0 +1,132 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package bootloader_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type pibootTestSuite struct {
+	baseBootenvTestSuite
+}
+
+var _ = Suite(&pibootTestSuite{})
+
+func (s *pibootTestSuite) TestNewPibootNoPibootReturnsNil(c *C) {
+	p := bootloader.NewPiboot(s.rootdir)
+	c.Assert(p, IsNil)
+}
+
+func (s *pibootTestSuite) TestNewPiboot(c *C) {
+	bootloader.MockPibootFiles(c, s.rootdir)
+	p := bootloader.NewPiboot(s.rootdir)
+	c.Assert(p, NotNil)
+	c.Check(p.Name(), Equals, "piboot")
+	c.Check(p.ConfigFile(), Equals, filepath.Join(s.rootdir, "/boot/piboot/piboot.conf"))
+}
+
+func (s *pibootTestSuite) TestGetBootloaderWithPiboot(c *C) {
+	bootloader.MockPibootFiles(c, s.rootdir)
+
+	bootloader, err := bootloader.Find(s.rootdir, nil)
+	c.Assert(err, IsNil)
+	c.Assert(bootloader.Name(), Equals, "piboot")
+}
+
+func (s *pibootTestSuite) TestPibootSetGetBootVars(c *C) {
+	bootloader.MockPibootFiles(c, s.rootdir)
+	p := bootloader.NewPiboot(s.rootdir)
+
+	err := p.SetBootVars(map[string]string{
+		"snap_mode":   "",
+		"snap_core":   "core18_4.snap",
+		"snap_kernel": "pi-kernel_1.snap",
+	})
+	c.Assert(err, IsNil)
+
+	m, err := p.GetBootVars("snap_mode", "snap_core", "snap_kernel")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snap_mode":   "",
+		"snap_core":   "core18_4.snap",
+		"snap_kernel": "pi-kernel_1.snap",
+	})
+	c.Check(filepath.Join(s.rootdir, "/boot/piboot/config.txt"), testutil.FileEquals, "os_prefix=pi-kernel_1.snap/\n")
+}
+
+func (s *pibootTestSuite) TestPibootTryKernel(c *C) {
+	bootloader.MockPibootFiles(c, s.rootdir)
+	p := bootloader.NewPiboot(s.rootdir)
+
+	err := ioutil.WriteFile(filepath.Join(s.rootdir, "/boot/piboot/config.txt"), []byte("arm_64bit=1\nos_prefix=\n"), 0644)
+	c.Assert(err, IsNil)
+	err = p.SetBootVars(map[string]string{
+		"snap_kernel":     "pi-kernel_1.snap",
+		"snap_try_kernel": "pi-kernel_2.snap",
+		"snap_mode":       "try",
+	})
+	c.Assert(err, IsNil)
+
+	c.Check(filepath.Join(s.rootdir, "/boot/piboot/config.txt"), testutil.FileEquals, "arm_64bit=1\nos_prefix=pi-kernel_1.snap/\n")
+	c.Check(filepath.Join(s.rootdir, "/boot/piboot/tryboot.txt"), testutil.FileEquals, "arm_64bit=1\nos_prefix=pi-kernel_2.snap/\n")
+
+	// the next boot uses tryboot.txt
+	rbl, ok := p.(bootloader.RebootBootloader)
+	c.Assert(ok, Equals, true)
+	args, err := rbl.GetRebootArguments()
+	c.Assert(err, IsNil)
+	c.Check(args, Equals, "0 tryboot")
+
+	// not booted with tryboot yet
+	m, err := p.GetBootVars("snap_mode")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{"snap_mode": "try"})
+
+	// the firmware booted with tryboot.txt
+	flagDir := filepath.Join(s.rootdir, "/proc/device-tree/chosen/bootloader")
+	c.Assert(os.MkdirAll(flagDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(flagDir, "tryboot"), []byte{0, 0, 0, 1}, 0644), IsNil)
+
+	m, err = p.GetBootVars("snap_mode", "snap_try_kernel")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{"snap_mode": "trying", "snap_try_kernel": "pi-kernel_2.snap"})
+
+	// the try kernel booted successfully
+	err = p.SetBootVars(map[string]string{
+		"snap_kernel":     "pi-kernel_2.snap",
+		"snap_try_kernel": "",
+		"snap_mode":       "",
+	})
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(s.rootdir, "/boot/piboot/config.txt"), testutil.FileEquals, "arm_64bit=1\nos_prefix=pi-kernel_2.snap/\n")
+	c.Check(filepath.Join(s.rootdir, "/boot/piboot/tryboot.txt"), testutil.FileAbsent)
+	c.Check(p.ConfigFile(), testutil.FileEquals, "snap_kernel=pi-kernel_2.snap\n")
+
+	args, err = rbl.GetRebootArguments()
+	c.Assert(err, IsNil)
+	c.Check(args, Equals, "")
+}
@@ -0,0 +1,171 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package pibootenv implements the boot environment of the Raspberry Pi
+// firmware. The firmware cannot be scripted, so the boot variables are
+// kept in an environment file and the firmware configuration, config.txt
+// and tryboot.txt, is rendered from them whenever they are saved.
+package pibootenv
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+const (
+	configFile  = "config.txt"
+	trybootFile = "tryboot.txt"
+)
+
+type Env struct {
+	// Map with key-value strings
+	env map[string]string
+	// File for environment storage
+	path string
+	// Directory of the firmware configuration
+	firmwareDir string
+}
+
+// NewEnv returns a new environment stored at the given path, rendering the
+// firmware configuration in firmwareDir.
+func NewEnv(path, firmwareDir string) *Env {
+	return &Env{
+		env:         make(map[string]string),
+		path:        path,
+		firmwareDir: firmwareDir,
+	}
+}
+
+func (p *Env) Get(name string) string {
+	return p.env[name]
+}
+
+func (p *Env) Set(key, value string) {
+	if value == "" {
+		delete(p.env, key)
+		return
+	}
+	p.env[key] = value
+}
+
+func (p *Env) Load() error {
+	file, err := os.Open(p.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		l := strings.SplitN(line, "=", 2)
+		if len(l) < 2 {
+			return fmt.Errorf("cannot parse %s: invalid line %q", p.path, line)
+		}
+		p.env[l[0]] = l[1]
+	}
+	return scanner.Err()
+}
+
+func (p *Env) Save() error {
+	keys := make([]string, 0, len(p.env))
+	for k := range p.env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var w bytes.Buffer
+	for _, k := range keys {
+		fmt.Fprintf(&w, "%s=%s\n", k, p.env[k])
+	}
+	if err := osutil.AtomicWriteFile(p.path, w.Bytes(), 0644, 0); err != nil {
+		return err
+	}
+
+	return p.renderFirmwareConfig()
+}
+
+// renderFirmwareConfig points the firmware to the directory of the kernel
+// to boot, and, when a kernel is to be tried, to the directory of the try
+// kernel in tryboot.txt, which the firmware uses instead of config.txt only
+// for the next boot and only if asked to with the tryboot reboot flag.
+func (p *Env) renderFirmwareConfig() error {
+	kernel := p.env["snap_kernel"]
+	if kernel == "" {
+		// nothing to boot yet
+		return nil
+	}
+
+	config, err := ioutil.ReadFile(filepath.Join(p.firmwareDir, configFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := writeConfigWithPrefix(filepath.Join(p.firmwareDir, configFile), config, kernel); err != nil {
+		return err
+	}
+
+	tryboot := filepath.Join(p.firmwareDir, trybootFile)
+	tryKernel := p.env["snap_try_kernel"]
+	if p.env["snap_mode"] != "try" || tryKernel == "" {
+		if err := os.Remove(tryboot); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return writeConfigWithPrefix(tryboot, config, tryKernel)
+}
+
+// writeConfigWithPrefix writes the given firmware configuration with the
+// os_prefix setting, under which the firmware looks for the kernel, the
+// initrd and the device trees, pointing to the directory of the given
+// kernel.
+func writeConfigWithPrefix(path string, config []byte, kernel string) error {
+	prefix := fmt.Sprintf("os_prefix=%s/", kernel)
+
+	var w bytes.Buffer
+	found := false
+	scanner := bufio.NewScanner(bytes.NewReader(config))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "os_prefix=") {
+			line = prefix
+			found = true
+		}
+		fmt.Fprintln(&w, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if !found {
+		fmt.Fprintln(&w, prefix)
+	}
+
+	return osutil.AtomicWriteFile(path, w.Bytes(), 0644, 0)
+}
@@ -0,0 +1,101 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package pibootenv_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/bootloader/pibootenv"
+	"github.com/snapcore/snapd/testutil"
+)
+
+// Hook up check.v1 into the "go test" runner
+func Test(t *testing.T) { TestingT(t) }
+
+type pibootenvTestSuite struct {
+	dir     string
+	envPath string
+	env     *pibootenv.Env
+}
+
+var _ = Suite(&pibootenvTestSuite{})
+
+func (p *pibootenvTestSuite) SetUpTest(c *C) {
+	p.dir = c.MkDir()
+	p.envPath = filepath.Join(p.dir, "piboot.conf")
+	p.env = pibootenv.NewEnv(p.envPath, p.dir)
+	c.Assert(p.env, NotNil)
+}
+
+func (p *pibootenvTestSuite) TestSet(c *C) {
+	p.env.Set("key", "value")
+	c.Check(p.env.Get("key"), Equals, "value")
+	p.env.Set("key", "")
+	c.Check(p.env.Get("key"), Equals, "")
+}
+
+func (p *pibootenvTestSuite) TestSaveAndLoad(c *C) {
+	p.env.Set("key2", "value2")
+	p.env.Set("key1", "value1")
+	p.env.Set("key3", "")
+
+	err := p.env.Save()
+	c.Assert(err, IsNil)
+	c.Check(p.envPath, testutil.FileEquals, "key1=value1\nkey2=value2\n")
+	// no kernel, no firmware configuration
+	c.Check(filepath.Join(p.dir, "config.txt"), testutil.FileAbsent)
+
+	env2 := pibootenv.NewEnv(p.envPath, p.dir)
+	err = env2.Load()
+	c.Assert(err, IsNil)
+	c.Check(env2.Get("key1"), Equals, "value1")
+	c.Check(env2.Get("key2"), Equals, "value2")
+	c.Check(env2.Get("key3"), Equals, "")
+}
+
+func (p *pibootenvTestSuite) TestLoadInvalid(c *C) {
+	err := ioutil.WriteFile(p.envPath, []byte("# comment\n\nkey=value\ngarbage\n"), 0644)
+	c.Assert(err, IsNil)
+
+	err = p.env.Load()
+	c.Check(err, ErrorMatches, `cannot parse .*/piboot.conf: invalid line "garbage"`)
+}
+
+func (p *pibootenvTestSuite) TestRenderFirmwareConfig(c *C) {
+	config := "# gadget provided\n[pi4]\narm_64bit=1\n[all]\n  os_prefix=old/\n"
+	err := ioutil.WriteFile(filepath.Join(p.dir, "config.txt"), []byte(config), 0644)
+	c.Assert(err, IsNil)
+
+	p.env.Set("snap_kernel", "pi-kernel_1.snap")
+	p.env.Set("snap_try_kernel", "pi-kernel_2.snap")
+	// not trying the kernel yet
+	c.Assert(p.env.Save(), IsNil)
+	c.Check(filepath.Join(p.dir, "config.txt"), testutil.FileEquals, "# gadget provided\n[pi4]\narm_64bit=1\n[all]\nos_prefix=pi-kernel_1.snap/\n")
+	c.Check(filepath.Join(p.dir, "tryboot.txt"), testutil.FileAbsent)
+
+	p.env.Set("snap_mode", "try")
+	c.Assert(p.env.Save(), IsNil)
+	c.Check(filepath.Join(p.dir, "config.txt"), testutil.FileEquals, "# gadget provided\n[pi4]\narm_64bit=1\n[all]\nos_prefix=pi-kernel_1.snap/\n")
+	c.Check(filepath.Join(p.dir, "tryboot.txt"), testutil.FileEquals, "# gadget provided\n[pi4]\narm_64bit=1\n[all]\nos_prefix=pi-kernel_2.snap/\n")
+}
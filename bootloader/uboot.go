@@ -21,6 +21,7 @@ package bootloader
 
 import (
 	"path/filepath"
+	"strings"
 
 	"github.com/snapcore/snapd/bootloader/ubootenv"
 	"github.com/snapcore/snapd/osutil"
@@ -45,6 +46,25 @@ func (u *uboot) Name() string {
 	return "uboot"
 }
 
+// SupportsCommandLineArgs returns whether the boot script kept in the
+// environment builds the kernel command line with the arguments from the
+// boot variables, older boot configurations do not.
+func (u *uboot) SupportsCommandLineArgs() bool {
+	env, err := ubootenv.OpenWithFlags(u.envFile(), ubootenv.OpenBestEffort)
+	if err != nil {
+		return false
+	}
+	// only the values matter, the variables themselves may be set
+	var script strings.Builder
+	for _, line := range strings.Split(env.String(), "\n") {
+		if i := strings.IndexRune(line, '='); i >= 0 {
+			script.WriteString(line[i+1:])
+			script.WriteRune('\n')
+		}
+	}
+	return refersToCommandLineVars(script.String())
+}
+
 func (u *uboot) setRootDir(rootdir string) {
 	u.rootdir = rootdir
 }
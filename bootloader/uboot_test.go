@@ -50,6 +50,30 @@ func (s *ubootTestSuite) TestNewUboot(c *C) {
 	u := bootloader.NewUboot(s.rootdir)
 	c.Assert(u, NotNil)
 	c.Assert(u.Name(), Equals, "uboot")
+	cbl, ok := u.(bootloader.CommandLineBootloader)
+	c.Assert(ok, Equals, true)
+	// the boot script does not use the command line arguments
+	c.Check(cbl.SupportsCommandLineArgs(), Equals, false)
+}
+
+func (s *ubootTestSuite) TestSupportsCommandLineArgs(c *C) {
+	bootloader.MockUbootFiles(c, s.rootdir)
+	u := bootloader.NewUboot(s.rootdir)
+	cbl := u.(bootloader.CommandLineBootloader)
+
+	// setting the variables alone does not count
+	err := u.SetBootVars(map[string]string{
+		"snapd_extra_cmdline_args": "console=ttyS0",
+		"snapd_full_cmdline_args":  "",
+	})
+	c.Assert(err, IsNil)
+	c.Check(cbl.SupportsCommandLineArgs(), Equals, false)
+
+	err = u.SetBootVars(map[string]string{
+		"mmcargs": "setenv bootargs ${snapd_full_cmdline_args}; test -n \"${bootargs}\" || setenv bootargs console=ttyS0 ${snapd_extra_cmdline_args}",
+	})
+	c.Assert(err, IsNil)
+	c.Check(cbl.SupportsCommandLineArgs(), Equals, true)
 }
 
 func (s *ubootTestSuite) TestUbootGetEnvVar(c *C) {
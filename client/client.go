@@ -139,7 +139,7 @@ func (client *Client) WarningsSummary() (count int, timestamp time.Time) {
 }
 
 func (client *Client) WhoAmI() (string, error) {
-	user, err := readAuthData()
+	user, err := auth.read()
 	if os.IsNotExist(err) {
 		return "", nil
 	}
@@ -151,7 +151,7 @@ func (client *Client) WhoAmI() (string, error) {
 }
 
 func (client *Client) setAuthorization(req *http.Request) error {
-	user, err := readAuthData()
+	user, err := auth.read()
 	if os.IsNotExist(err) {
 		return nil
 	}
@@ -522,6 +522,10 @@ const (
 	ErrorKindDaemonRestart = "daemon-restart"
 
 	ErrorKindAssertionNotFound = "assertion-not-found"
+
+	ErrorKindInsufficientDiskSpace = "insufficient-disk-space"
+
+	ErrorKindUnsuccessful = "unsuccessful"
 )
 
 // IsRetryable returns true if the given error is an error
@@ -563,12 +563,12 @@ func (cs *clientSuite) TestClientJSONError(c *C) {
 func (cs *clientSuite) TestUsers(c *C) {
 	cs.rsp = `{"type": "sync", "result":
                      [{"username": "foo","email":"foo@example.com"},
-                      {"username": "bar","email":"bar@example.com"}]}`
+                      {"username": "bar","email":"bar@example.com","needs-relogin":true}]}`
 	users, err := cs.cli.Users()
 	c.Check(err, IsNil)
 	c.Check(users, DeepEquals, []*client.User{
 		{Username: "foo", Email: "foo@example.com"},
-		{Username: "bar", Email: "bar@example.com"},
+		{Username: "bar", Email: "bar@example.com", NeedsReLogin: true},
 	})
 }
 
@@ -53,3 +53,8 @@ func UnmarshalSnapshotAction(body io.Reader) (act snapshotAction, err error) {
 }
 
 type DownloadAction = downloadAction
+
+// CachedAuthUser returns the cached authentication details.
+func CachedAuthUser() *User {
+	return auth.user
+}
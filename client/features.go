@@ -0,0 +1,35 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+// Feature describes a snapd feature flag and its current status.
+type Feature struct {
+	Name             string `json:"name"`
+	Enabled          bool   `json:"enabled"`
+	Exported         bool   `json:"exported,omitempty"`
+	EnabledWhenUnset bool   `json:"enabled-when-unset,omitempty"`
+}
+
+// Features returns the snapd feature flags and their status.
+func (client *Client) Features() ([]*Feature, error) {
+	var features []*Feature
+	_, err := client.doSync("GET", "/v2/features", nil, nil, nil, &features)
+	return features, err
+}
@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/snapcore/snapd/osutil"
 )
@@ -35,6 +36,10 @@ type User struct {
 	Username string `json:"username,omitempty"`
 	Email    string `json:"email,omitempty"`
 
+	// NeedsReLogin is set when the store credentials of the user
+	// expired and the user needs to log in again.
+	NeedsReLogin bool `json:"needs-relogin,omitempty"`
+
 	Macaroon   string   `json:"macaroon,omitempty"`
 	Discharges []string `json:"discharges,omitempty"`
 }
@@ -62,6 +67,7 @@ func (client *Client) Login(email, password, otp string) (*User, error) {
 		return nil, err
 	}
 
+	auth.invalidate()
 	if err := writeAuthData(user); err != nil {
 		return nil, fmt.Errorf("cannot persist login information: %v", err)
 	}
@@ -74,12 +80,13 @@ func (client *Client) Logout() error {
 	if err != nil {
 		return err
 	}
+	auth.invalidate()
 	return removeAuthData()
 }
 
 // LoggedInUser returns the logged in User or nil
 func (client *Client) LoggedInUser() *User {
-	u, err := readAuthData()
+	u, err := auth.read()
 	if err != nil {
 		return nil
 	}
@@ -148,6 +155,77 @@ func readAuthData() (*User, error) {
 	return &user, nil
 }
 
+// authCache keeps the authentication details read from the auth.json
+// file for as long as the file does not change, so that long running
+// clients neither read it for every request nor miss a login or logout
+// done meanwhile by someone else. It is shared by all the clients of
+// the process, which all use the same file.
+type authCache struct {
+	mu       sync.Mutex
+	filename string
+	watcher  *osutil.FileWatcher
+	valid    bool
+	user     *User
+	err      error
+}
+
+var auth authCache
+
+func (ac *authCache) invalidate() {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.valid = false
+}
+
+// read returns the authentication details like readAuthData, but from
+// the cache if the file did not change.
+func (ac *authCache) read() (*User, error) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	filename := storeAuthDataFilename("")
+	if filename != ac.filename {
+		if ac.watcher != nil {
+			// closing an inotify instance waits for the kernel
+			// to release its watches, which can take a while
+			go ac.watcher.Close()
+		}
+		ac.watcher = nil
+		ac.valid = false
+		// the watcher is set up before reading so that no change
+		// is missed; it cannot be if the directory of the file
+		// does not exist (yet)
+		w, err := osutil.NewFileWatcher(filename)
+		if err != nil {
+			ac.filename = ""
+			return readAuthData()
+		}
+		ac.filename = filename
+		ac.watcher = w
+	}
+
+	if changed, err := ac.watcher.Changed(); changed || err != nil {
+		ac.valid = false
+	}
+	if ac.watcher.Gone() {
+		// the directory of the file went away, watch it again
+		// on the next read
+		go ac.watcher.Close()
+		ac.watcher = nil
+		ac.filename = ""
+	}
+	if !ac.valid {
+		ac.user, ac.err = readAuthData()
+		ac.valid = ac.err == nil || os.IsNotExist(ac.err)
+	}
+	if ac.user == nil {
+		return nil, ac.err
+	}
+	// do not hand out the cached copy
+	user := *ac.user
+	return &user, ac.err
+}
+
 // removeAuthData removes any previously written authentication details.
 func removeAuthData() error {
 	filename := storeAuthDataFilename("")
@@ -130,6 +130,92 @@ func (cs *clientSuite) TestClientLogout(c *check.C) {
 	c.Check(osutil.FileExists(outfile), check.Equals, false)
 }
 
+func (cs *clientSuite) TestLoggedInUserFollowsAuthFileChanges(c *check.C) {
+	outfile := filepath.Join(c.MkDir(), "json")
+	os.Setenv(client.TestAuthFileEnvKey, outfile)
+	defer os.Unsetenv(client.TestAuthFileEnvKey)
+
+	c.Assert(cs.cli.LoggedInUser(), check.IsNil)
+
+	// logged in by someone else
+	err := osutil.AtomicWriteFile(outfile, []byte(`{"email":"foo@bar.com","macaroon":"macaroon"}`), 0600, 0)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.cli.LoggedInUser(), check.DeepEquals, &client.User{
+		Email:    "foo@bar.com",
+		Macaroon: "macaroon",
+	})
+	cached := client.CachedAuthUser()
+	c.Assert(cached, check.NotNil)
+
+	// not read again while unchanged
+	c.Check(cs.cli.LoggedInUser(), check.NotNil)
+	c.Check(client.CachedAuthUser(), check.Equals, cached)
+	// and the authorization uses it
+	_, err = cs.cli.WhoAmI()
+	c.Assert(err, check.IsNil)
+	c.Check(client.CachedAuthUser(), check.Equals, cached)
+
+	err = ioutil.WriteFile(outfile, []byte(`{"email":"zed@bar.com","macaroon":"other"}`), 0600)
+	c.Assert(err, check.IsNil)
+	email, err := cs.cli.WhoAmI()
+	c.Assert(err, check.IsNil)
+	c.Check(email, check.Equals, "zed@bar.com")
+
+	// logged out by someone else
+	c.Assert(os.Remove(outfile), check.IsNil)
+	c.Check(cs.cli.LoggedInUser(), check.IsNil)
+	email, err = cs.cli.WhoAmI()
+	c.Assert(err, check.IsNil)
+	c.Check(email, check.Equals, "")
+}
+
+func (cs *clientSuite) TestLoggedInUserAuthDirRecreated(c *check.C) {
+	outfile := filepath.Join(c.MkDir(), "auth", "json")
+	os.Setenv(client.TestAuthFileEnvKey, outfile)
+	defer os.Unsetenv(client.TestAuthFileEnvKey)
+
+	c.Assert(os.MkdirAll(filepath.Dir(outfile), 0700), check.IsNil)
+	err := ioutil.WriteFile(outfile, []byte(`{"email":"foo@bar.com","macaroon":"macaroon"}`), 0600)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.cli.LoggedInUser(), check.NotNil)
+
+	// the directory is removed
+	c.Assert(os.RemoveAll(filepath.Dir(outfile)), check.IsNil)
+	c.Check(cs.cli.LoggedInUser(), check.IsNil)
+
+	// and created again, the file is still watched
+	c.Assert(os.MkdirAll(filepath.Dir(outfile), 0700), check.IsNil)
+	err = ioutil.WriteFile(outfile, []byte(`{"email":"zed@bar.com","macaroon":"other"}`), 0600)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.cli.LoggedInUser(), check.DeepEquals, &client.User{
+		Email:    "zed@bar.com",
+		Macaroon: "other",
+	})
+	err = ioutil.WriteFile(outfile, []byte(`{"email":"bar@bar.com","macaroon":"another"}`), 0600)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.cli.LoggedInUser(), check.DeepEquals, &client.User{
+		Email:    "bar@bar.com",
+		Macaroon: "another",
+	})
+}
+
+func (cs *clientSuite) TestLoggedInUserNoAuthDir(c *check.C) {
+	outfile := filepath.Join(c.MkDir(), "missing", "json")
+	os.Setenv(client.TestAuthFileEnvKey, outfile)
+	defer os.Unsetenv(client.TestAuthFileEnvKey)
+
+	c.Assert(cs.cli.LoggedInUser(), check.IsNil)
+
+	// the directory appears later
+	c.Assert(os.MkdirAll(filepath.Dir(outfile), 0700), check.IsNil)
+	err := ioutil.WriteFile(outfile, []byte(`{"email":"foo@bar.com","macaroon":"macaroon"}`), 0600)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.cli.LoggedInUser(), check.DeepEquals, &client.User{
+		Email:    "foo@bar.com",
+		Macaroon: "macaroon",
+	})
+}
+
 func (cs *clientSuite) TestWriteAuthData(c *check.C) {
 	outfile := filepath.Join(c.MkDir(), "json")
 	os.Setenv(client.TestAuthFileEnvKey, outfile)
@@ -52,6 +52,7 @@ type Snap struct {
 	TrackingChannel  string        `json:"tracking-channel,omitempty"`
 	IgnoreValidation bool          `json:"ignore-validation"`
 	Revision         snap.Revision `json:"revision"`
+	Epoch            *snap.Epoch   `json:"epoch,omitempty"`
 	Confinement      string        `json:"confinement"`
 	Private          bool          `json:"private"`
 	DevMode          bool          `json:"devmode"`
@@ -77,6 +78,17 @@ type Snap struct {
 	Tracks []string `json:"tracks,omitempty"`
 
 	Health *SnapHealth `json:"health,omitempty"`
+
+	RefreshHold *SnapRefreshHold `json:"refresh-hold,omitempty"`
+}
+
+// SnapRefreshHold describes a hold of the auto-refreshes of a snap.
+type SnapRefreshHold struct {
+	// Time is when the hold was requested.
+	Time time.Time `json:"time"`
+	// Until is when the hold expires, it is nil if the auto-refreshes
+	// are held indefinitely.
+	Until *time.Time `json:"until,omitempty"`
 }
 
 type SnapHealth struct {
@@ -88,6 +88,7 @@ type multiActionData struct {
 	Action string   `json:"action"`
 	Snaps  []string `json:"snaps,omitempty"`
 	Users  []string `json:"users,omitempty"`
+	Time   string   `json:"time,omitempty"`
 }
 
 // Install adds the snap with the given name from the given channel (or
@@ -155,6 +156,37 @@ func (client *Client) SnapshotMany(names []string, users []string) (setID uint64
 	return x.SetID, changeID, nil
 }
 
+// HoldRefreshesOptions are the options for HoldRefreshes.
+type HoldRefreshesOptions struct {
+	// Time is until when the auto-refreshes are held, either as a
+	// RFC3339 timestamp, a duration (e.g. "48h") or "forever".
+	Time string
+}
+
+// HoldRefreshes holds the auto-refreshes of the given snaps.
+func (client *Client) HoldRefreshes(snaps []string, opts *HoldRefreshesOptions) (changeID string, err error) {
+	if opts == nil || opts.Time == "" {
+		return "", fmt.Errorf("cannot hold refreshes without a time")
+	}
+	action := multiActionData{
+		Action: "hold",
+		Snaps:  snaps,
+		Time:   opts.Time,
+	}
+	_, changeID, err = client.doMultiSnapActionData(&action)
+	return changeID, err
+}
+
+// UnholdRefreshes removes any hold of the auto-refreshes of the given snaps.
+func (client *Client) UnholdRefreshes(snaps []string) (changeID string, err error) {
+	action := multiActionData{
+		Action: "unhold",
+		Snaps:  snaps,
+	}
+	_, changeID, err = client.doMultiSnapActionData(&action)
+	return changeID, err
+}
+
 var ErrDangerousNotApplicable = fmt.Errorf("dangerous option only meaningful when installing from a local file")
 
 func (client *Client) doSnapAction(actionName string, snapName string, options *SnapOptions) (changeID string, err error) {
@@ -195,7 +227,11 @@ func (client *Client) doMultiSnapActionFull(actionName string, snaps []string, o
 	if options != nil {
 		action.Users = options.Users
 	}
-	data, err := json.Marshal(&action)
+	return client.doMultiSnapActionData(&action)
+}
+
+func (client *Client) doMultiSnapActionData(action *multiActionData) (result json.RawMessage, changeID string, err error) {
+	data, err := json.Marshal(action)
 	if err != nil {
 		return nil, "", fmt.Errorf("cannot marshal multi-snap action: %s", err)
 	}
@@ -212,6 +212,55 @@ func (cs *clientSuite) TestClientMultiSnapshot(c *check.C) {
 	c.Check(changeID, check.Equals, "d728")
 }
 
+func (cs *clientSuite) TestClientHoldRefreshes(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	changeID, err := cs.cli.HoldRefreshes([]string{pkgName}, &client.HoldRefreshesOptions{Time: "forever"})
+	c.Assert(err, check.IsNil)
+	c.Check(changeID, check.Equals, "d728")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	c.Check(cs.req.Header.Get("Content-Type"), check.Equals, "application/json")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var jsonBody map[string]interface{}
+	c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action": "hold",
+		"snaps":  []interface{}{pkgName},
+		"time":   "forever",
+	})
+
+	_, err = cs.cli.HoldRefreshes([]string{pkgName}, nil)
+	c.Check(err, check.ErrorMatches, "cannot hold refreshes without a time")
+}
+
+func (cs *clientSuite) TestClientUnholdRefreshes(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	changeID, err := cs.cli.UnholdRefreshes([]string{pkgName})
+	c.Assert(err, check.IsNil)
+	c.Check(changeID, check.Equals, "d728")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var jsonBody map[string]interface{}
+	c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action": "unhold",
+		"snaps":  []interface{}{pkgName},
+	})
+}
+
 func (cs *clientSuite) TestClientOpInstallPath(c *check.C) {
 	cs.status = 202
 	cs.rsp = `{
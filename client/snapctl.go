@@ -40,6 +40,36 @@ type snapctlOutput struct {
 	Stderr string `json:"stderr"`
 }
 
+// UnsuccessfulError is returned by RunSnapctl when the command ran but
+// reported failure through its exit code rather than as an error.
+type UnsuccessfulError struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+}
+
+func (e *UnsuccessfulError) Error() string {
+	return fmt.Sprintf("snapctl unsuccessful with exit code: %d", e.ExitCode)
+}
+
+func unsuccessfulOutput(value interface{}) (stdout, stderr []byte, exitCode int, err error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, nil, 0, fmt.Errorf("cannot parse unsuccessful snapctl output: %v", value)
+	}
+	if s, ok := m["stdout"].(string); ok {
+		stdout = []byte(s)
+	}
+	if s, ok := m["stderr"].(string); ok {
+		stderr = []byte(s)
+	}
+	code, ok := m["exit-code"].(float64)
+	if !ok {
+		return nil, nil, 0, fmt.Errorf("cannot parse unsuccessful snapctl exit code: %v", m["exit-code"])
+	}
+	return stdout, stderr, int(code), nil
+}
+
 // RunSnapctl requests a snapctl run for the given options.
 func (client *Client) RunSnapctl(options *SnapCtlOptions) (stdout, stderr []byte, err error) {
 	b, err := json.Marshal(options)
@@ -50,6 +80,17 @@ func (client *Client) RunSnapctl(options *SnapCtlOptions) (stdout, stderr []byte
 	var output snapctlOutput
 	_, err = client.doSync("POST", "/v2/snapctl", nil, nil, bytes.NewReader(b), &output)
 	if err != nil {
+		if e, ok := err.(*Error); ok && e.Kind == ErrorKindUnsuccessful {
+			stdout, stderr, exitCode, err := unsuccessfulOutput(e.Value)
+			if err != nil {
+				return nil, nil, err
+			}
+			return stdout, stderr, &UnsuccessfulError{
+				Stdout:   stdout,
+				Stderr:   stderr,
+				ExitCode: exitCode,
+			}
+		}
 		return nil, nil, err
 	}
 
@@ -66,3 +66,34 @@ func (cs *clientSuite) TestClientRunSnapctl(c *check.C) {
 		"args":       []interface{}{"foo", "bar"},
 	})
 }
+
+func (cs *clientSuite) TestClientRunSnapctlUnsuccessful(c *check.C) {
+	cs.rsp = `{
+		"type": "error",
+		"status-code": 200,
+		"result": {
+			"message": "unsuccessful with exit code: 123",
+			"kind": "unsuccessful",
+			"value": {
+				"stdout": "test stdout",
+				"stderr": "test stderr",
+				"exit-code": 123
+			}
+		}
+	}`
+
+	options := &client.SnapCtlOptions{
+		ContextID: "1234ABCD",
+		Args:      []string{"is-connected", "plug"},
+	}
+
+	stdout, stderr, err := cs.cli.RunSnapctl(options)
+	c.Check(err, check.DeepEquals, &client.UnsuccessfulError{
+		Stdout:   []byte("test stdout"),
+		Stderr:   []byte("test stderr"),
+		ExitCode: 123,
+	})
+	c.Check(err, check.ErrorMatches, "snapctl unsuccessful with exit code: 123")
+	c.Check(string(stdout), check.Equals, "test stdout")
+	c.Check(string(stderr), check.Equals, "test stderr")
+}
@@ -87,6 +87,8 @@ func (sh *Snapshot) IsValid() bool {
 type SnapshotSet struct {
 	ID        uint64      `json:"id"`
 	Snapshots []*Snapshot `json:"snapshots"`
+	// set for automatic snapshots, when the set will be removed
+	Expiry *time.Time `json:"expiry-time,omitempty"`
 }
 
 // Time returns the earliest time in the set.
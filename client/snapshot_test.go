@@ -20,6 +20,7 @@
 package client_test
 
 import (
+	"encoding/json"
 	"net/url"
 	"time"
 
@@ -77,6 +78,23 @@ func (cs *clientSuite) TestClientSnapshotSetSize(c *check.C) {
 	}}.Size(), check.DeepEquals, int64(6))
 }
 
+func (cs *clientSuite) TestClientSnapshotSetExpiryJSON(c *check.C) {
+	// no expiry for snapshots created on request
+	buf, err := json.Marshal(client.SnapshotSet{ID: 1})
+	c.Assert(err, check.IsNil)
+	c.Check(string(buf), check.Equals, `{"id":1,"snapshots":null}`)
+
+	expiry := time.Date(2019, 9, 1, 10, 0, 0, 0, time.UTC)
+	buf, err = json.Marshal(client.SnapshotSet{ID: 2, Expiry: &expiry})
+	c.Assert(err, check.IsNil)
+	c.Check(string(buf), check.Equals, `{"id":2,"snapshots":null,"expiry-time":"2019-09-01T10:00:00Z"}`)
+
+	var set client.SnapshotSet
+	c.Assert(json.Unmarshal(buf, &set), check.IsNil)
+	c.Assert(set.Expiry, check.NotNil)
+	c.Check(set.Expiry.Equal(expiry), check.Equals, true)
+}
+
 func (cs *clientSuite) TestClientSnapshotSets(c *check.C) {
 	cs.rsp = `{
 		"type": "sync",
@@ -0,0 +1,256 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/disks"
+	"github.com/snapcore/snapd/seed"
+)
+
+func init() {
+	const (
+		short = "Generate the mounts for the initramfs"
+		long  = `
+The initramfs-mounts command determines the mode the system was booted in
+and prints the mounts that are still missing for that mode, one per line, in
+the form "<what> <where>" suitable for systemd-mount. It is called repeatedly
+until it prints nothing, as the later mounts depend on the content of the
+earlier ones.
+`
+	)
+
+	if _, err := parser.AddCommand("initramfs-mounts", short, long, &cmdInitramfsMounts{}); err != nil {
+		panic(err)
+	}
+}
+
+type cmdInitramfsMounts struct{}
+
+func (c *cmdInitramfsMounts) Execute(args []string) error {
+	return generateInitramfsMounts()
+}
+
+var (
+	osutilIsMounted         = osutil.IsMounted
+	modeAndRecoverySystem   = boot.ModeAndRecoverySystemFromKernelCommandLine
+	disksDiskFromMountPoint = disks.DiskFromMountPoint
+
+	bootFactoryResetRequested = boot.FactoryResetRequested
+)
+
+// runMnt is where the initramfs mounts the partitions and snaps of the
+// system.
+func runMnt() string {
+	return filepath.Join(dirs.GlobalRootDir, "/run/mnt")
+}
+
+// partitionDevice returns the device of the partition with the given
+// filesystem label.
+func partitionDevice(label string) string {
+	return filepath.Join("/dev/disk/by-label", label)
+}
+
+func generateInitramfsMounts() error {
+	mode, recoverySystem, err := modeAndRecoverySystem()
+	if err != nil {
+		return err
+	}
+	if mode == boot.ModeRun {
+		return generateMountsModeRun()
+	}
+	return generateMountsModeInstallRecover(mode, recoverySystem)
+}
+
+// mountPrinter prints the mounts that are missing.
+type mountPrinter struct {
+	printed bool
+}
+
+func (p *mountPrinter) ensure(what, where string) error {
+	isMounted, err := osutilIsMounted(where)
+	if err != nil {
+		return err
+	}
+	if !isMounted {
+		fmt.Fprintf(Stdout, "%s %s\n", what, where)
+		p.printed = true
+	}
+	return nil
+}
+
+// generateMountsModeRun handles the mounts of the run mode, where the base
+// and kernel snaps are the ones recorded in the modeenv of the run system.
+func generateMountsModeRun() error {
+	seedDir := filepath.Join(runMnt(), "ubuntu-seed")
+	dataDir := filepath.Join(runMnt(), "ubuntu-data")
+
+	// 1. the seed partition
+	var p mountPrinter
+	if err := p.ensure(partitionDevice("ubuntu-seed"), seedDir); err != nil {
+		return err
+	}
+	if p.printed {
+		return nil
+	}
+
+	// 2. the data partition, which must be on the same disk as the seed
+	// partition so that a filesystem labeled ubuntu-data on a removable
+	// disk cannot be used instead
+	disk, err := disksDiskFromMountPoint(seedDir)
+	if err != nil {
+		return fmt.Errorf("cannot find the disk of the seed partition: %v", err)
+	}
+	dataPartUUID, err := disk.FindMatchingPartitionUUIDWithFsLabel("ubuntu-data")
+	if err != nil {
+		return fmt.Errorf("cannot find the data partition: %v", err)
+	}
+	if err := p.ensure(filepath.Join("/dev/disk/by-partuuid", dataPartUUID), dataDir); err != nil {
+		return err
+	}
+	if p.printed {
+		return nil
+	}
+
+	// 3. the base and kernel snaps of the run system
+	systemData := filepath.Join(dataDir, "system-data")
+	modeenv, err := boot.ReadModeenv(systemData)
+	if err != nil {
+		return fmt.Errorf("cannot read modeenv: %v", err)
+	}
+	if modeenv.Base == "" {
+		return fmt.Errorf("cannot find the base snap in the modeenv")
+	}
+	if modeenv.Kernel == "" {
+		return fmt.Errorf("cannot find the kernel snap in the modeenv")
+	}
+	snapsDir := dirs.SnapBlobDirUnder(systemData)
+	if err := p.ensure(filepath.Join(snapsDir, modeenv.Base), filepath.Join(runMnt(), "base")); err != nil {
+		return err
+	}
+	return p.ensure(filepath.Join(snapsDir, modeenv.Kernel), filepath.Join(runMnt(), "kernel"))
+}
+
+// generateMountsModeInstallRecover handles the mounts of the install and
+// recover modes, where the base and kernel snaps come from the recovery
+// system and the data is ephemeral.
+func generateMountsModeInstallRecover(mode, recoverySystem string) error {
+	seedDir := filepath.Join(runMnt(), "ubuntu-seed")
+	dataDir := filepath.Join(runMnt(), "ubuntu-data")
+
+	// 1. the seed partition
+	var p mountPrinter
+	if err := p.ensure(partitionDevice("ubuntu-seed"), seedDir); err != nil {
+		return err
+	}
+	if p.printed {
+		return nil
+	}
+
+	// 2. the base and kernel snaps of the recovery system, along with
+	// a tmpfs for the data
+	base, kernel, err := recoverySystemEssentialSnaps(seedDir, recoverySystem)
+	if err != nil {
+		return err
+	}
+	if err := p.ensure(base, filepath.Join(runMnt(), "base")); err != nil {
+		return err
+	}
+	if err := p.ensure(kernel, filepath.Join(runMnt(), "kernel")); err != nil {
+		return err
+	}
+	if err := p.ensure("--type=tmpfs tmpfs", dataDir); err != nil {
+		return err
+	}
+	if p.printed {
+		return nil
+	}
+
+	// 3. all mounted, let snapd know which mode and recovery system
+	// it runs in, and whether the install is a factory reset
+	modeenv := &boot.Modeenv{
+		Mode:           mode,
+		RecoverySystem: recoverySystem,
+	}
+	if mode == boot.ModeInstall {
+		factoryReset, err := bootFactoryResetRequested(seedDir)
+		if err != nil {
+			return err
+		}
+		modeenv.FactoryReset = factoryReset
+	}
+	return modeenv.Write(filepath.Join(dataDir, "system-data"))
+}
+
+// recoverySystemModel returns the model assertion of the given recovery
+// system of the seed, verified with the assertions of the recovery system.
+func recoverySystemModel(seedDir, recoverySystem string) (*asserts.Model, error) {
+	systemSeed, err := seed.Open(filepath.Join(seedDir, "systems", recoverySystem))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open recovery system %q: %v", recoverySystem, err)
+	}
+	if err := systemSeed.LoadAssertions(nil, nil); err != nil {
+		return nil, fmt.Errorf("cannot load assertions of recovery system %q: %v", recoverySystem, err)
+	}
+	return systemSeed.Model()
+}
+
+// recoverySystemEssentialSnaps returns the paths of the base and kernel
+// snaps of the given recovery system of the seed. The snaps of a recovery
+// system are found in its snaps directory.
+func recoverySystemEssentialSnaps(seedDir, recoverySystem string) (base, kernel string, err error) {
+	model, err := recoverySystemModel(seedDir, recoverySystem)
+	if err != nil {
+		return "", "", err
+	}
+	if model.Base() == "" {
+		return "", "", fmt.Errorf("cannot use recovery system %q: model has no base", recoverySystem)
+	}
+	snapsDir := filepath.Join(seedDir, "systems", recoverySystem, "snaps")
+	base, err = findSnap(snapsDir, recoverySystem, model.Base())
+	if err != nil {
+		return "", "", err
+	}
+	kernel, err = findSnap(snapsDir, recoverySystem, model.Kernel())
+	if err != nil {
+		return "", "", err
+	}
+	return base, kernel, nil
+}
+
+func findSnap(snapsDir, recoverySystem, name string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(snapsDir, name+"_*.snap"))
+	if err != nil {
+		return "", err
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("cannot find snap %q of recovery system %q", name, recoverySystem)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("cannot use recovery system %q: too many revisions of snap %q", recoverySystem, name)
+}
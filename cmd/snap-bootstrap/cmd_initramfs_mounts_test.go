@@ -0,0 +1,351 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/boot"
+	main "github.com/snapcore/snapd/cmd/snap-bootstrap"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/disks"
+	"github.com/snapcore/snapd/seed"
+	"github.com/snapcore/snapd/seed/seedtest"
+)
+
+type initramfsMountsSuite struct {
+	bootstrapSuite
+	*seedtest.TestingSeed
+
+	mounted map[string]bool
+}
+
+var _ = Suite(&initramfsMountsSuite{})
+
+func (s *initramfsMountsSuite) SetUpTest(c *C) {
+	s.bootstrapSuite.SetUpTest(c)
+
+	s.TestingSeed = &seedtest.TestingSeed{}
+	s.SetupAssertSigning("canonical", s)
+	s.AddCleanup(seed.MockTrusted(s.StoreSigning.Trusted))
+	brandPrivKey, _ := assertstest.GenerateKey(752)
+	s.Brands.Register("my-brand", brandPrivKey, map[string]interface{}{
+		"verification": "verified",
+	})
+
+	s.mounted = make(map[string]bool)
+	s.AddCleanup(main.MockOsutilIsMounted(func(path string) (bool, error) {
+		return s.mounted[path], nil
+	}))
+	seedDir := filepath.Join(s.rootdir, "/run/mnt/ubuntu-seed")
+	s.AddCleanup(main.MockDisksDiskFromMountPoint(func(mountpoint string) (disks.Disk, error) {
+		c.Check(mountpoint, Equals, seedDir)
+		return &disks.MockDiskMapping{
+			DevNode: "/dev/sda",
+			DevNum:  "8:0",
+			DiskPartitions: []disks.Partition{
+				{PartitionUUID: "seed-partuuid", FilesystemLabel: "ubuntu-seed"},
+				{PartitionUUID: "data-partuuid", FilesystemLabel: "ubuntu-data"},
+			},
+			MountPoints: []string{seedDir},
+		}, nil
+	}))
+	s.AddCleanup(main.MockBootFactoryResetRequested(func(string) (bool, error) {
+		return false, nil
+	}))
+}
+
+func (s *initramfsMountsSuite) mockMode(mode, sysLabel string) {
+	s.AddCleanup(main.MockModeAndRecoverySystem(func() (string, string, error) {
+		return mode, sysLabel, nil
+	}))
+}
+
+func (s *initramfsMountsSuite) mount(where ...string) {
+	for _, w := range where {
+		s.mounted[filepath.Join(s.rootdir, "/run/mnt", w)] = true
+	}
+}
+
+func (s *initramfsMountsSuite) makeRecoverySystem(c *C, label string, snaps ...string) {
+	systemDir := filepath.Join(s.rootdir, "/run/mnt/ubuntu-seed/systems", label)
+	c.Assert(os.MkdirAll(filepath.Join(systemDir, "snaps"), 0755), IsNil)
+	s.AssertsDir = filepath.Join(systemDir, "assertions")
+	c.Assert(os.MkdirAll(s.AssertsDir, 0755), IsNil)
+	c.Assert(os.RemoveAll(filepath.Join(s.AssertsDir, "model")), IsNil)
+	s.WriteAssertions("model", s.MakeModelAssertionChain("my-brand", "my-model", map[string]interface{}{
+		"architecture": "amd64",
+		"gadget":       "pc",
+		"kernel":       "pc-kernel",
+		"base":         "core20",
+	})...)
+	for _, sn := range snaps {
+		c.Assert(ioutil.WriteFile(filepath.Join(systemDir, "snaps", sn), nil, 0644), IsNil)
+	}
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsUnknownMode(c *C) {
+	s.AddCleanup(main.MockModeAndRecoverySystem(func() (string, string, error) {
+		return "", "", fmt.Errorf("cannot use unknown mode %q", "foo")
+	}))
+
+	err := main.ParseArgs([]string{"initramfs-mounts"})
+	c.Assert(err, ErrorMatches, `cannot use unknown mode "foo"`)
+	c.Check(s.Stdout(), Equals, "")
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsInstallModeStep1(c *C) {
+	s.mockMode(boot.ModeInstall, "20191118")
+
+	err := main.ParseArgs([]string{"initramfs-mounts"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, fmt.Sprintf("/dev/disk/by-label/ubuntu-seed %s/run/mnt/ubuntu-seed\n", s.rootdir))
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsInstallModeStep2(c *C) {
+	s.mockMode(boot.ModeInstall, "20191118")
+	s.makeRecoverySystem(c, "20191118", "core20_1.snap", "pc-kernel_1.snap")
+	s.mount("ubuntu-seed")
+
+	err := main.ParseArgs([]string{"initramfs-mounts"})
+	c.Assert(err, IsNil)
+	snapsDir := filepath.Join(s.rootdir, "/run/mnt/ubuntu-seed/systems/20191118/snaps")
+	c.Check(s.Stdout(), Equals, fmt.Sprintf(`%[1]s/core20_1.snap %[2]s/run/mnt/base
+%[1]s/pc-kernel_1.snap %[2]s/run/mnt/kernel
+--type=tmpfs tmpfs %[2]s/run/mnt/ubuntu-data
+`, snapsDir, s.rootdir))
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsInstallModeStep3(c *C) {
+	s.mockMode(boot.ModeInstall, "20191118")
+	s.makeRecoverySystem(c, "20191118", "core20_1.snap", "pc-kernel_1.snap")
+	s.mount("ubuntu-seed", "base", "kernel", "ubuntu-data")
+
+	err := main.ParseArgs([]string{"initramfs-mounts"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+
+	modeenv, err := boot.ReadModeenv(filepath.Join(s.rootdir, "/run/mnt/ubuntu-data/system-data"))
+	c.Assert(err, IsNil)
+	c.Check(modeenv, DeepEquals, &boot.Modeenv{
+		Mode:           boot.ModeInstall,
+		RecoverySystem: "20191118",
+	})
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsInstallModeStep3FactoryReset(c *C) {
+	s.mockMode(boot.ModeInstall, "20191118")
+	s.makeRecoverySystem(c, "20191118", "core20_1.snap", "pc-kernel_1.snap")
+	s.mount("ubuntu-seed", "base", "kernel", "ubuntu-data")
+	s.AddCleanup(main.MockBootFactoryResetRequested(func(seedDir string) (bool, error) {
+		c.Check(seedDir, Equals, filepath.Join(s.rootdir, "/run/mnt/ubuntu-seed"))
+		return true, nil
+	}))
+
+	err := main.ParseArgs([]string{"initramfs-mounts"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+
+	modeenv, err := boot.ReadModeenv(filepath.Join(s.rootdir, "/run/mnt/ubuntu-data/system-data"))
+	c.Assert(err, IsNil)
+	c.Check(modeenv, DeepEquals, &boot.Modeenv{
+		Mode:           boot.ModeInstall,
+		RecoverySystem: "20191118",
+		FactoryReset:   true,
+	})
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsInstallModeStep3FactoryResetError(c *C) {
+	s.mockMode(boot.ModeInstall, "20191118")
+	s.makeRecoverySystem(c, "20191118", "core20_1.snap", "pc-kernel_1.snap")
+	s.mount("ubuntu-seed", "base", "kernel", "ubuntu-data")
+	s.AddCleanup(main.MockBootFactoryResetRequested(func(string) (bool, error) {
+		return false, fmt.Errorf("cannot check for factory reset: boom")
+	}))
+
+	err := main.ParseArgs([]string{"initramfs-mounts"})
+	c.Assert(err, ErrorMatches, "cannot check for factory reset: boom")
+	c.Check(osutil.FileExists(dirs.SnapModeenvFileUnder(filepath.Join(s.rootdir, "/run/mnt/ubuntu-data/system-data"))), Equals, false)
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsRecoverModeStep3(c *C) {
+	s.mockMode(boot.ModeRecover, "20191118")
+	s.makeRecoverySystem(c, "20191118", "core20_1.snap", "pc-kernel_1.snap")
+	s.mount("ubuntu-seed", "base", "kernel", "ubuntu-data")
+
+	err := main.ParseArgs([]string{"initramfs-mounts"})
+	c.Assert(err, IsNil)
+
+	modeenv, err := boot.ReadModeenv(filepath.Join(s.rootdir, "/run/mnt/ubuntu-data/system-data"))
+	c.Assert(err, IsNil)
+	c.Check(modeenv.Mode, Equals, boot.ModeRecover)
+	c.Check(modeenv.RecoverySystem, Equals, "20191118")
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsRecoverySystemErrors(c *C) {
+	s.mockMode(boot.ModeRecover, "20191118")
+	s.mount("ubuntu-seed")
+
+	err := main.ParseArgs([]string{"initramfs-mounts"})
+	c.Check(err, ErrorMatches, `cannot load assertions of recovery system "20191118": no seed assertions`)
+
+	s.makeRecoverySystem(c, "20191118", "core20_1.snap")
+	err = main.ParseArgs([]string{"initramfs-mounts"})
+	c.Check(err, ErrorMatches, `cannot find snap "pc-kernel" of recovery system "20191118"`)
+
+	s.makeRecoverySystem(c, "20191118", "pc-kernel_1.snap", "pc-kernel_2.snap")
+	err = main.ParseArgs([]string{"initramfs-mounts"})
+	c.Check(err, ErrorMatches, `cannot use recovery system "20191118": too many revisions of snap "pc-kernel"`)
+	c.Check(s.Stdout(), Equals, "")
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsRecoverySystemUnverifiedModel(c *C) {
+	s.mockMode(boot.ModeRecover, "20191118")
+	s.mount("ubuntu-seed")
+	s.makeRecoverySystem(c, "20191118", "core20_1.snap", "pc-kernel_1.snap")
+
+	// a model signed with a key the recovery system doesn't vouch for
+	otherPrivKey, _ := assertstest.GenerateKey(752)
+	otherSigning := assertstest.NewSigningDB("my-brand", otherPrivKey)
+	model, err := otherSigning.Sign(asserts.ModelType, map[string]interface{}{
+		"authority-id": "my-brand",
+		"series":       "16",
+		"brand-id":     "my-brand",
+		"model":        "my-model",
+		"architecture": "amd64",
+		"gadget":       "pc",
+		"kernel":       "other-kernel",
+		"base":         "core20",
+		"timestamp":    "2019-11-18T00:00:00Z",
+	}, nil, "")
+	c.Assert(err, IsNil)
+	c.Assert(os.RemoveAll(filepath.Join(s.AssertsDir, "model")), IsNil)
+	s.WriteAssertions("model", model)
+
+	err = main.ParseArgs([]string{"initramfs-mounts"})
+	c.Check(err, ErrorMatches, `cannot load assertions of recovery system "20191118": .*`)
+	c.Check(s.Stdout(), Equals, "")
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsRunModeStep1(c *C) {
+	s.mockMode(boot.ModeRun, "")
+
+	err := main.ParseArgs([]string{"initramfs-mounts"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, fmt.Sprintf(`/dev/disk/by-label/ubuntu-seed %[1]s/run/mnt/ubuntu-seed
+`, s.rootdir))
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsRunModeStep1Data(c *C) {
+	s.mockMode(boot.ModeRun, "")
+	s.mount("ubuntu-seed")
+
+	err := main.ParseArgs([]string{"initramfs-mounts"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, fmt.Sprintf(`/dev/disk/by-partuuid/data-partuuid %[1]s/run/mnt/ubuntu-data
+`, s.rootdir))
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsRunModeNoDataOnSeedDisk(c *C) {
+	s.mockMode(boot.ModeRun, "")
+	s.mount("ubuntu-seed")
+	s.AddCleanup(main.MockDisksDiskFromMountPoint(func(mountpoint string) (disks.Disk, error) {
+		return &disks.MockDiskMapping{
+			DevNode: "/dev/sda",
+			DevNum:  "8:0",
+			DiskPartitions: []disks.Partition{
+				{PartitionUUID: "seed-partuuid", FilesystemLabel: "ubuntu-seed"},
+			},
+		}, nil
+	}))
+
+	err := main.ParseArgs([]string{"initramfs-mounts"})
+	c.Assert(err, ErrorMatches, `cannot find the data partition: filesystem label "ubuntu-data" not found`)
+	c.Check(s.Stdout(), Equals, "")
+
+	s.AddCleanup(main.MockDisksDiskFromMountPoint(func(mountpoint string) (disks.Disk, error) {
+		return nil, fmt.Errorf("cannot find mountpoint")
+	}))
+	err = main.ParseArgs([]string{"initramfs-mounts"})
+	c.Assert(err, ErrorMatches, `cannot find the disk of the seed partition: cannot find mountpoint`)
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsRunModeStep2(c *C) {
+	s.mockMode(boot.ModeRun, "")
+	s.mount("ubuntu-seed", "ubuntu-data")
+
+	systemData := filepath.Join(s.rootdir, "/run/mnt/ubuntu-data/system-data")
+	modeenv := &boot.Modeenv{
+		Mode:   boot.ModeRun,
+		Base:   "core20_123.snap",
+		Kernel: "pc-kernel_1.snap",
+	}
+	c.Assert(modeenv.Write(systemData), IsNil)
+
+	err := main.ParseArgs([]string{"initramfs-mounts"})
+	c.Assert(err, IsNil)
+	snapsDir := dirs.SnapBlobDirUnder(systemData)
+	c.Check(s.Stdout(), Equals, fmt.Sprintf(`%[1]s/core20_123.snap %[2]s/run/mnt/base
+%[1]s/pc-kernel_1.snap %[2]s/run/mnt/kernel
+`, snapsDir, s.rootdir))
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsRunModeStep3(c *C) {
+	s.mockMode(boot.ModeRun, "")
+	s.mount("ubuntu-seed", "ubuntu-data", "base", "kernel")
+
+	modeenv := &boot.Modeenv{
+		Mode:   boot.ModeRun,
+		Base:   "core20_123.snap",
+		Kernel: "pc-kernel_1.snap",
+	}
+	c.Assert(modeenv.Write(filepath.Join(s.rootdir, "/run/mnt/ubuntu-data/system-data")), IsNil)
+
+	err := main.ParseArgs([]string{"initramfs-mounts"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsRunModeIncompleteModeenv(c *C) {
+	s.mockMode(boot.ModeRun, "")
+	s.mount("ubuntu-seed", "ubuntu-data")
+
+	systemData := filepath.Join(s.rootdir, "/run/mnt/ubuntu-data/system-data")
+	err := main.ParseArgs([]string{"initramfs-mounts"})
+	c.Check(err, ErrorMatches, "cannot read modeenv: .*")
+
+	modeenv := &boot.Modeenv{Mode: boot.ModeRun, Kernel: "pc-kernel_1.snap"}
+	c.Assert(modeenv.Write(systemData), IsNil)
+	err = main.ParseArgs([]string{"initramfs-mounts"})
+	c.Check(err, ErrorMatches, "cannot find the base snap in the modeenv")
+
+	modeenv = &boot.Modeenv{Mode: boot.ModeRun, Base: "core20_123.snap"}
+	c.Assert(modeenv.Write(systemData), IsNil)
+	err = main.ParseArgs([]string{"initramfs-mounts"})
+	c.Check(err, ErrorMatches, "cannot find the kernel snap in the modeenv")
+}
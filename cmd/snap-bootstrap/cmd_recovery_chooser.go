@@ -0,0 +1,132 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/osutil"
+)
+
+func init() {
+	const (
+		short = "Choose the recovery system to boot"
+		long  = `
+The recovery-chooser command lists the recovery systems of the seed and asks
+the user which one to boot and in which mode. The choice takes effect at the
+next boot.
+`
+	)
+
+	if _, err := parser.AddCommand("recovery-chooser", short, long, &cmdRecoveryChooser{}); err != nil {
+		panic(err)
+	}
+}
+
+type cmdRecoveryChooser struct{}
+
+func (c *cmdRecoveryChooser) Execute(args []string) error {
+	return chooseRecoverySystem()
+}
+
+// recoverySystems returns the labels of the recovery systems of the seed,
+// sorted so that the most recent one comes last.
+func recoverySystems(seedDir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(seedDir, "systems"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot list recovery systems: %v", err)
+	}
+	var systems []string
+	for _, entry := range entries {
+		if !entry.IsDir() || !osutil.FileExists(filepath.Join(seedDir, "systems", entry.Name(), "model")) {
+			continue
+		}
+		systems = append(systems, entry.Name())
+	}
+	sort.Strings(systems)
+	return systems, nil
+}
+
+func chooseRecoverySystem() error {
+	seedDir := filepath.Join(runMnt(), "ubuntu-seed")
+	systems, err := recoverySystems(seedDir)
+	if err != nil {
+		return err
+	}
+	if len(systems) == 0 {
+		return fmt.Errorf("cannot find any recovery systems")
+	}
+
+	in := bufio.NewReader(Stdin)
+	label, err := choose(in, "Select the recovery system to boot:", systems)
+	if err != nil {
+		return err
+	}
+	mode, err := choose(in, fmt.Sprintf("Select the mode to boot recovery system %q in:", label), []string{boot.ModeRecover, boot.ModeInstall})
+	if err != nil {
+		return err
+	}
+
+	bl, err := bootloader.Find(seedDir, nil)
+	if err != nil {
+		return fmt.Errorf("cannot find the bootloader of the seed: %v", err)
+	}
+	if err := bl.SetBootVars(map[string]string{
+		"snapd_recovery_mode":   mode,
+		"snapd_recovery_system": label,
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(Stdout, "Recovery system %q will be booted in %s mode.\n", label, mode)
+	return nil
+}
+
+// choose asks the user to pick one of the choices, until a valid one is
+// made.
+func choose(in *bufio.Reader, prompt string, choices []string) (string, error) {
+	fmt.Fprintln(Stdout, prompt)
+	for i, choice := range choices {
+		fmt.Fprintf(Stdout, "  %d. %s\n", i+1, choice)
+	}
+	for {
+		fmt.Fprintf(Stdout, "Choice [1-%d]: ", len(choices))
+		line, err := in.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if n, convErr := strconv.Atoi(line); convErr == nil && n >= 1 && n <= len(choices) {
+			return choices[n-1], nil
+		}
+		if err == io.EOF {
+			return "", fmt.Errorf("no choice was made")
+		}
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(Stdout, "Invalid choice %q.\n", line)
+	}
+}
@@ -0,0 +1,124 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/bootloader/bootloadertest"
+	main "github.com/snapcore/snapd/cmd/snap-bootstrap"
+)
+
+type recoveryChooserSuite struct {
+	bootstrapSuite
+
+	bootloader *bootloadertest.MockBootloader
+}
+
+var _ = Suite(&recoveryChooserSuite{})
+
+func (s *recoveryChooserSuite) SetUpTest(c *C) {
+	s.bootstrapSuite.SetUpTest(c)
+
+	s.bootloader = bootloadertest.Mock("mock", c.MkDir())
+	bootloader.Force(s.bootloader)
+	s.AddCleanup(func() { bootloader.Force(nil) })
+}
+
+func (s *recoveryChooserSuite) mockStdin(input string) {
+	oldStdin := main.Stdin
+	s.AddCleanup(func() { main.Stdin = oldStdin })
+	main.Stdin = strings.NewReader(input)
+}
+
+func (s *recoveryChooserSuite) makeSystems(c *C, labels ...string) {
+	for _, label := range labels {
+		systemDir := filepath.Join(s.rootdir, "/run/mnt/ubuntu-seed/systems", label)
+		c.Assert(os.MkdirAll(systemDir, 0755), IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(systemDir, "model"), nil, 0644), IsNil)
+	}
+}
+
+func (s *recoveryChooserSuite) TestRecoveryChooserHappy(c *C) {
+	s.makeSystems(c, "20191119", "20191118")
+	// not a recovery system
+	c.Assert(os.MkdirAll(filepath.Join(s.rootdir, "/run/mnt/ubuntu-seed/systems/incomplete"), 0755), IsNil)
+	s.mockStdin("2\n2\n")
+
+	err := main.ParseArgs([]string{"recovery-chooser"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, `Select the recovery system to boot:
+  1. 20191118
+  2. 20191119
+Choice [1-2]: Select the mode to boot recovery system "20191119" in:
+  1. recover
+  2. install
+Choice [1-2]: Recovery system "20191119" will be booted in install mode.
+`)
+	c.Check(s.bootloader.BootVars, DeepEquals, map[string]string{
+		"snapd_recovery_mode":   "install",
+		"snapd_recovery_system": "20191119",
+	})
+}
+
+func (s *recoveryChooserSuite) TestRecoveryChooserInvalidChoice(c *C) {
+	s.makeSystems(c, "20191118")
+	s.mockStdin("0\nfoo\n1\n1")
+
+	err := main.ParseArgs([]string{"recovery-chooser"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, `Select the recovery system to boot:
+  1. 20191118
+Choice [1-1]: Invalid choice "0".
+Choice [1-1]: Invalid choice "foo".
+Choice [1-1]: Select the mode to boot recovery system "20191118" in:
+  1. recover
+  2. install
+Choice [1-2]: Recovery system "20191118" will be booted in recover mode.
+`)
+	c.Check(s.bootloader.BootVars, DeepEquals, map[string]string{
+		"snapd_recovery_mode":   "recover",
+		"snapd_recovery_system": "20191118",
+	})
+}
+
+func (s *recoveryChooserSuite) TestRecoveryChooserNoChoice(c *C) {
+	s.makeSystems(c, "20191118")
+	s.mockStdin("3\n")
+
+	err := main.ParseArgs([]string{"recovery-chooser"})
+	c.Assert(err, ErrorMatches, "no choice was made")
+	c.Check(s.bootloader.BootVars, HasLen, 0)
+}
+
+func (s *recoveryChooserSuite) TestRecoveryChooserNoSystems(c *C) {
+	err := main.ParseArgs([]string{"recovery-chooser"})
+	c.Assert(err, ErrorMatches, "cannot list recovery systems: .*")
+
+	c.Assert(os.MkdirAll(filepath.Join(s.rootdir, "/run/mnt/ubuntu-seed/systems"), 0755), IsNil)
+	err = main.ParseArgs([]string{"recovery-chooser"})
+	c.Assert(err, ErrorMatches, "cannot find any recovery systems")
+}
@@ -0,0 +1,53 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"github.com/snapcore/snapd/osutil/disks"
+)
+
+var (
+	Run       = run
+	ParseArgs = parseArgs
+)
+
+func MockOsutilIsMounted(f func(path string) (bool, error)) (restore func()) {
+	old := osutilIsMounted
+	osutilIsMounted = f
+	return func() { osutilIsMounted = old }
+}
+
+func MockModeAndRecoverySystem(f func() (mode, sysLabel string, err error)) (restore func()) {
+	old := modeAndRecoverySystem
+	modeAndRecoverySystem = f
+	return func() { modeAndRecoverySystem = old }
+}
+
+func MockDisksDiskFromMountPoint(f func(mountpoint string) (disks.Disk, error)) (restore func()) {
+	old := disksDiskFromMountPoint
+	disksDiskFromMountPoint = f
+	return func() { disksDiskFromMountPoint = old }
+}
+
+func MockBootFactoryResetRequested(f func(seedDir string) (bool, error)) (restore func()) {
+	old := bootFactoryResetRequested
+	bootFactoryResetRequested = f
+	return func() { bootFactoryResetRequested = old }
+}
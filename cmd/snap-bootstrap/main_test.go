@@ -0,0 +1,70 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	main "github.com/snapcore/snapd/cmd/snap-bootstrap"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/testutil"
+)
+
+// Hook up check.v1 into the "go test" runner
+func Test(t *testing.T) { TestingT(t) }
+
+type bootstrapSuite struct {
+	testutil.BaseTest
+
+	rootdir string
+
+	stdout *bytes.Buffer
+}
+
+func (s *bootstrapSuite) SetUpTest(c *C) {
+	s.stdout = bytes.NewBuffer(nil)
+
+	oldStdout := main.Stdout
+	s.AddCleanup(func() { main.Stdout = oldStdout })
+	main.Stdout = s.stdout
+
+	s.rootdir = c.MkDir()
+	dirs.SetRootDir(s.rootdir)
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+}
+
+func (s *bootstrapSuite) Stdout() string {
+	return s.stdout.String()
+}
+
+var _ = Suite(&bootstrapSuite{})
+
+func (s *bootstrapSuite) TestUnknownArg(c *C) {
+	err := main.ParseArgs([]string{"foo"})
+	c.Check(err, ErrorMatches, `Unknown command .foo.*`)
+}
+
+func (s *bootstrapSuite) TestNoCommand(c *C) {
+	err := main.ParseArgs([]string{})
+	c.Check(err, ErrorMatches, "Please specify .*")
+}
@@ -20,12 +20,11 @@
 package main
 
 var (
-	ExpandEnvCmdArgs = expandEnvCmdArgs
-	FindCommand      = findCommand
-	ParseArgs        = parseArgs
-	Run              = run
-	ExecApp          = execApp
-	ExecHook         = execHook
+	FindCommand = findCommand
+	ParseArgs   = parseArgs
+	Run         = run
+	ExecApp     = execApp
+	ExecHook    = execHook
 )
 
 func MockSyscallExec(f func(argv0 string, argv []string, envv []string) (err error)) func() {
@@ -29,7 +29,6 @@ import (
 	"github.com/jessevdk/go-flags"
 
 	"github.com/snapcore/snapd/dirs"
-	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snapenv"
 )
@@ -125,32 +124,6 @@ func findCommand(app *snap.AppInfo, command string) (string, error) {
 	return cmd, nil
 }
 
-func absoluteCommandChain(snapInfo *snap.Info, commandChain []string) []string {
-	chain := make([]string, 0, len(commandChain))
-	snapMountDir := snapInfo.MountDir()
-
-	for _, element := range commandChain {
-		chain = append(chain, filepath.Join(snapMountDir, element))
-	}
-
-	return chain
-}
-
-// expandEnvCmdArgs takes the string list of commandline arguments
-// and expands any $VAR with the given var from the env argument.
-func expandEnvCmdArgs(args []string, env map[string]string) []string {
-	cmdArgs := make([]string, 0, len(args))
-	for _, arg := range args {
-		maybeExpanded := os.Expand(arg, func(k string) string {
-			return env[k]
-		})
-		if maybeExpanded != "" {
-			cmdArgs = append(cmdArgs, maybeExpanded)
-		}
-	}
-	return cmdArgs
-}
-
 func completionHelper() (string, error) {
 	exe, err := osReadlink("/proc/self/exe")
 	if err != nil {
@@ -178,8 +151,9 @@ func execApp(snapApp, revision, command string, args []string) error {
 		return fmt.Errorf("cannot find app %q in %q", appName, snapName)
 	}
 
-	cmdAndArgs, err := findCommand(app, command)
-	if err != nil {
+	// check the command upfront so that errors are the same for all
+	// the commands, including the ones handled specially below
+	if _, err := findCommand(app, command); err != nil {
 		return err
 	}
 
@@ -193,39 +167,36 @@ func execApp(snapApp, revision, command string, args []string) error {
 		}
 		env = append(env, kv)
 	}
-	env = append(env, osutil.SubstituteEnv(app.Env())...)
-
-	// strings.Split() is ok here because we validate all app fields and the
-	// whitelist is pretty strict (see snap/validate.go:appContentWhitelist)
-	// (see also overlord/snapstate/check_snap.go's normPath)
-	tmpArgv := strings.Split(cmdAndArgs, " ")
-	cmd := tmpArgv[0]
-	cmdArgs := expandEnvCmdArgs(tmpArgv[1:], osutil.EnvMap(env))
+	env = app.ExecEnv(env)
 
 	// run the command
-	fullCmd := []string{filepath.Join(app.Snap.MountDir(), cmd)}
+	var fullCmd []string
 	switch command {
 	case "shell":
-		fullCmd[0] = defaultShell
-		cmdArgs = nil
+		fullCmd = append(app.ExecCommandChain(), defaultShell)
+		fullCmd = append(fullCmd, args...)
 	case "complete":
-		fullCmd[0] = defaultShell
 		helper, err := completionHelper()
 		if err != nil {
 			return fmt.Errorf("cannot find completion helper: %v", err)
 		}
-		cmdArgs = []string{
-			helper,
-			filepath.Join(app.Snap.MountDir(), app.Completer),
-		}
+		fullCmd = append(app.ExecCommandChain(), defaultShell, helper, filepath.Join(app.Snap.MountDir(), app.Completer))
+		fullCmd = append(fullCmd, args...)
 	case "gdb":
-		fullCmd = append(fullCmd, fullCmd[0])
-		fullCmd[0] = filepath.Join(dirs.CoreLibExecDir, "snap-gdb-shim")
+		fullCmd, err = app.ExecCommand("", env, args)
+		if err != nil {
+			return err
+		}
+		// the gdb shim goes right after the command-chain
+		chainLen := len(app.CommandChain)
+		shim := filepath.Join(dirs.CoreLibExecDir, "snap-gdb-shim")
+		fullCmd = append(fullCmd[:chainLen], append([]string{shim}, fullCmd[chainLen:]...)...)
+	default:
+		fullCmd, err = app.ExecCommand(command, env, args)
+		if err != nil {
+			return err
+		}
 	}
-	fullCmd = append(fullCmd, cmdArgs...)
-	fullCmd = append(fullCmd, args...)
-
-	fullCmd = append(absoluteCommandChain(app.Snap, app.CommandChain), fullCmd...)
 
 	if err := syscallExec(fullCmd[0], fullCmd, env); err != nil {
 		return fmt.Errorf("cannot exec %q: %s", fullCmd[0], err)
@@ -253,9 +224,9 @@ func execHook(snapName, revision, hookName string) error {
 	}
 
 	// build the environment
-	env := append(os.Environ(), osutil.SubstituteEnv(hook.Env())...)
+	env := hook.ExecEnv(os.Environ())
 
 	// run the hook
-	cmd := append(absoluteCommandChain(hook.Snap, hook.CommandChain), filepath.Join(hook.Snap.HooksDir(), hook.Name))
+	cmd := hook.ExecCommand()
 	return syscallExec(cmd[0], cmd, env)
 }
@@ -438,38 +438,6 @@ func (s *snapExecSuite) TestSnapExecAppIntegrationWithVars(c *C) {
 	c.Check(execEnv, testutil.Contains, fmt.Sprintf("MY_PATH=%s", os.Getenv("PATH")))
 }
 
-func (s *snapExecSuite) TestSnapExecExpandEnvCmdArgs(c *C) {
-	for _, t := range []struct {
-		args     []string
-		env      map[string]string
-		expected []string
-	}{
-		{
-			args:     []string{"foo"},
-			env:      nil,
-			expected: []string{"foo"},
-		},
-		{
-			args:     []string{"$var"},
-			env:      map[string]string{"var": "value"},
-			expected: []string{"value"},
-		},
-		{
-			args:     []string{"foo", "$not_existing"},
-			env:      nil,
-			expected: []string{"foo"},
-		},
-		{
-			args:     []string{"foo", "$var", "baz"},
-			env:      map[string]string{"var": "bar", "unrelated": "env"},
-			expected: []string{"foo", "bar", "baz"},
-		},
-	} {
-		c.Check(snapExec.ExpandEnvCmdArgs(t.args, t.env), DeepEquals, t.expected)
-
-	}
-}
-
 func (s *snapExecSuite) TestSnapExecCompleteError(c *C) {
 	dirs.SetRootDir(c.MkDir())
 	snaptest.MockSnap(c, string(mockYaml), &snap.SideInfo{
@@ -33,18 +33,38 @@ func run(args []string) error {
 	if os.Getuid() != 0 {
 		return fmt.Errorf("please run as root")
 	}
-	if len(os.Args) < 3 {
+	if len(os.Args) >= 2 && os.Args[1] == "check" {
+		return runCheck(os.Args[2:])
+	}
+	args = os.Args[1:]
+	options := &recover.Options{}
+	if len(args) > 0 && args[0] == "--factory-reset" {
+		options.FactoryReset = true
+		args = args[1:]
+	}
+	if len(args) < 2 {
 		// XXX: slightly ugly to return usage as an error but ok for now
-		return fmt.Errorf("usage: %s <gadget root> <block device>\n", os.Args[0])
+		return fmt.Errorf("usage: %s [check [--repair]|--factory-reset] <gadget root> <block device>\n", os.Args[0])
 	}
 
-	gadgetRoot := os.Args[1]
-	device := os.Args[2]
-	options := &recover.Options{}
+	gadgetRoot := args[0]
+	device := args[1]
 
 	return recover.Run(gadgetRoot, device, options)
 }
 
+func runCheck(args []string) error {
+	repair := false
+	if len(args) > 0 && args[0] == "--repair" {
+		repair = true
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("usage: %s check [--repair] <gadget root> <block device>\n", os.Args[0])
+	}
+	return recover.Check(args[0], args[1], repair)
+}
+
 func main() {
 	err := run(os.Args)
 	if err != nil {
@@ -20,8 +20,11 @@ package partition
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 
 	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/osutil/disks"
 )
 
 func makeFilesystem(node, label, filesystem, content string) error {
@@ -34,3 +37,59 @@ func makeFilesystem(node, label, filesystem, content string) error {
 		return fmt.Errorf("cannot create unsupported filesystem %q", filesystem)
 	}
 }
+
+// RepairContent creates the filesystems of the given structures, which must be
+// present on the device as partitions already, and writes the structure
+// content from the gadget into them.
+func (sf *SFDisk) RepairContent(gadgetRoot string, structures []gadget.LaidOutStructure) error {
+	return repairContent(gadgetRoot, structures, sf.nodeAt)
+}
+
+// RepairContentOnDisk is like RepairContent but finds the partitions of the
+// structures among the partitions of the disk as probed by udev.
+func RepairContentOnDisk(disk disks.Disk, gadgetRoot string, structures []gadget.LaidOutStructure) error {
+	partitions, err := disk.Partitions()
+	if err != nil {
+		return err
+	}
+	nodeAt := func(offset gadget.Size) (string, error) {
+		for _, p := range partitions {
+			if gadget.Size(p.StartOffset) == offset {
+				return p.KernelDeviceNode, nil
+			}
+		}
+		return "", fmt.Errorf("cannot find partition starting at %v on %v", offset, disk.KernelDeviceNode())
+	}
+	return repairContent(gadgetRoot, structures, nodeAt)
+}
+
+func repairContent(gadgetRoot string, structures []gadget.LaidOutStructure, nodeAt func(offset gadget.Size) (string, error)) error {
+	for i := range structures {
+		ps := &structures[i]
+		node, err := nodeAt(ps.StartOffset)
+		if err != nil {
+			return err
+		}
+		if err := makeFilesystemWithContent(node, gadgetRoot, ps); err != nil {
+			return fmt.Errorf("cannot repair content of structure %v: %v", ps, err)
+		}
+	}
+	return nil
+}
+
+func makeFilesystemWithContent(node, gadgetRoot string, ps *gadget.LaidOutStructure) error {
+	stagingDir, err := ioutil.TempDir("", "snap-recovery-content")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stagingDir)
+
+	fw, err := gadget.NewMountedFilesystemWriter(gadgetRoot, ps)
+	if err != nil {
+		return err
+	}
+	if err := fw.Write(stagingDir, nil); err != nil {
+		return err
+	}
+	return makeFilesystem(node, ps.EffectiveFilesystemLabel(), ps.Filesystem, stagingDir)
+}
@@ -19,9 +19,13 @@
 package partition_test
 
 import (
+	"path/filepath"
+
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/cmd/snap-recovery/partition"
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/osutil/disks"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -49,3 +53,110 @@ func (s *partitionTestSuite) TestMkfsExt4(c *C) {
 	// details are already tested in the gadget package
 	c.Assert(mockMkfs.Calls(), HasLen, 1)
 }
+
+func (s *partitionTestSuite) TestRepairContent(c *C) {
+	cmdSfdisk := testutil.MockCommand(c, "sfdisk", mockSfdiskScriptBiosAndRecovery)
+	defer cmdSfdisk.Restore()
+	cmdLsblk := testutil.MockCommand(c, "lsblk", mockLsblkScript)
+	defer cmdLsblk.Restore()
+	mockMkfs := testutil.MockCommand(c, "mkfs.vfat", "")
+	defer mockMkfs.Restore()
+	mockMcopy := testutil.MockCommand(c, "mcopy", "")
+	defer mockMcopy.Restore()
+
+	gadgetRoot := filepath.Join(c.MkDir(), "gadget")
+	err := makeMockGadget(gadgetRoot, gadgetContent)
+	c.Assert(err, IsNil)
+	pv, err := gadget.PositionedVolumeFromGadget(gadgetRoot)
+	c.Assert(err, IsNil)
+
+	sf := partition.NewSFDisk("/dev/node")
+	diskLayout, err := sf.Layout()
+	c.Assert(err, IsNil)
+	c.Assert(gadget.EnsureLayoutCompatibility(pv, diskLayout), IsNil)
+
+	missing := gadget.StructuresMissingContent(pv, diskLayout)
+	c.Assert(missing, HasLen, 1)
+	c.Check(missing[0].Name, Equals, "Recovery")
+
+	err = sf.RepairContent(gadgetRoot, missing)
+	c.Assert(err, IsNil)
+	c.Assert(mockMkfs.Calls(), DeepEquals, [][]string{
+		{"mkfs.vfat", "-S", "512", "-s", "1", "-F", "32", "/dev/node2"},
+	})
+	// the content staged from the gadget is copied
+	c.Assert(mockMcopy.Calls(), HasLen, 1)
+	c.Check(mockMcopy.Calls()[0][len(mockMcopy.Calls()[0])-1], Equals, "::")
+}
+
+func (s *partitionTestSuite) TestRepairContentNoPartition(c *C) {
+	cmdSfdisk := testutil.MockCommand(c, "sfdisk", mockSfdiskScriptBios)
+	defer cmdSfdisk.Restore()
+	cmdLsblk := testutil.MockCommand(c, "lsblk", mockLsblkScript)
+	defer cmdLsblk.Restore()
+
+	gadgetRoot := filepath.Join(c.MkDir(), "gadget")
+	err := makeMockGadget(gadgetRoot, gadgetContent)
+	c.Assert(err, IsNil)
+	pv, err := gadget.PositionedVolumeFromGadget(gadgetRoot)
+	c.Assert(err, IsNil)
+
+	sf := partition.NewSFDisk("/dev/node")
+	_, err = sf.Layout()
+	c.Assert(err, IsNil)
+
+	// the recovery partition does not exist
+	err = sf.RepairContent(gadgetRoot, pv.LaidOutStructure[2:3])
+	c.Assert(err, ErrorMatches, `cannot find partition starting at 2097152 on /dev/node`)
+}
+
+func (s *partitionTestSuite) TestRepairContentOnDisk(c *C) {
+	mockMkfs := testutil.MockCommand(c, "mkfs.vfat", "")
+	defer mockMkfs.Restore()
+	mockMcopy := testutil.MockCommand(c, "mcopy", "")
+	defer mockMcopy.Restore()
+
+	gadgetRoot := filepath.Join(c.MkDir(), "gadget")
+	err := makeMockGadget(gadgetRoot, gadgetContent)
+	c.Assert(err, IsNil)
+	pv, err := gadget.PositionedVolumeFromGadget(gadgetRoot)
+	c.Assert(err, IsNil)
+
+	disk := &disks.MockDiskMapping{
+		DevNode:  "/dev/node",
+		DevNum:   "8:0",
+		DiskSize: uint64(4096 * gadget.SizeMiB),
+		DiskPartitions: []disks.Partition{
+			{
+				KernelDeviceNode: "/dev/node1",
+				PartitionLabel:   "BIOS Boot",
+				PartitionType:    "21686148-6449-6e6f-744e-656564454649",
+				StartOffset:      uint64(1 * gadget.SizeMiB),
+				Size:             uint64(1 * gadget.SizeMiB),
+			}, {
+				KernelDeviceNode: "/dev/node2",
+				PartitionLabel:   "Recovery",
+				PartitionType:    "c12a7328-f81f-11d2-ba4b-00a0c93ec93b",
+				StartOffset:      uint64(2 * gadget.SizeMiB),
+				Size:             uint64(1200 * gadget.SizeMiB),
+			},
+		},
+	}
+	diskLayout, err := gadget.LaidOutVolumeFromDisk(disk)
+	c.Assert(err, IsNil)
+	missing := gadget.StructuresMissingContent(pv, diskLayout)
+	c.Assert(missing, HasLen, 1)
+	c.Check(missing[0].Name, Equals, "Recovery")
+
+	err = partition.RepairContentOnDisk(disk, gadgetRoot, missing)
+	c.Assert(err, IsNil)
+	c.Assert(mockMkfs.Calls(), DeepEquals, [][]string{
+		{"mkfs.vfat", "-S", "512", "-s", "1", "-F", "32", "/dev/node2"},
+	})
+	c.Assert(mockMcopy.Calls(), HasLen, 1)
+
+	// the structure must be a partition of the disk
+	disk.DiskPartitions = disk.DiskPartitions[:1]
+	err = partition.RepairContentOnDisk(disk, gadgetRoot, missing)
+	c.Assert(err, ErrorMatches, `cannot find partition starting at 2097152 on /dev/node`)
+}
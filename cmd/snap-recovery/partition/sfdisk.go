@@ -111,6 +111,20 @@ func (sf *SFDisk) Create(pv *gadget.LaidOutVolume) (map[string]string, error) {
 	return deviceMap, nil
 }
 
+// nodeAt returns the device node of the partition starting at the given offset,
+// as found in the partition table read by Layout().
+func (sf *SFDisk) nodeAt(offset gadget.Size) (string, error) {
+	if sf.partitionTable == nil {
+		return "", fmt.Errorf("internal error: partition table of %v was not read", sf.device)
+	}
+	for _, p := range sf.partitionTable.Partitions {
+		if gadget.Size(p.Start)*sectorSize == offset {
+			return p.Node, nil
+		}
+	}
+	return "", fmt.Errorf("cannot find partition starting at %v on %v", offset, sf.device)
+}
+
 // positionedVolumeFromDump takes an sfdisk dump format and returns the partitioning
 // information as a laid out volume.
 func positionedVolumeFromDump(dump *sfdiskDeviceDump) (*gadget.LaidOutVolume, error) {
@@ -155,7 +169,7 @@ func positionedVolumeFromDump(dump *sfdiskDeviceDump) (*gadget.LaidOutVolume, er
 			ID:        ptable.ID,
 			Structure: structure,
 		},
-		Size:             gadget.Size(ptable.LastLBA),
+		Size:             gadget.Size(ptable.LastLBA+1) * sectorSize,
 		SectorSize:       sectorSize,
 		LaidOutStructure: ps,
 	}
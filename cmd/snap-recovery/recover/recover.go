@@ -23,27 +23,100 @@ import (
 
 	"github.com/snapcore/snapd/cmd/snap-recovery/partition"
 	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/osutil/disks"
 )
 
 type Options struct {
+	// FactoryReset makes the filesystems of the system-data structures
+	// be created again, discarding the data of the previous run system.
+	FactoryReset bool
 	// will contain encryption later
 }
 
 func Run(gadgetRoot, device string, options *Options) error {
-
-	// XXX: ensure we test that the current partition table is
-	//      compatible with the gadget
 	lv, err := gadget.PositionedVolumeFromGadget(gadgetRoot)
 	if err != nil {
 		return fmt.Errorf("cannot layout the volume: %v", err)
 	}
 
 	sfdisk := partition.NewSFDisk(device)
-	// XXX: use the output of create to also create filesystems
+	diskLayout, err := sfdisk.Layout()
+	if err != nil {
+		return fmt.Errorf("cannot read %v partitions: %v", device, err)
+	}
+	if err := gadget.EnsureLayoutCompatibility(lv, diskLayout); err != nil {
+		return fmt.Errorf("gadget and %v partition table not compatible: %v", device, err)
+	}
+
 	_, err = sfdisk.Create(lv)
 	if err != nil {
 		return fmt.Errorf("cannot create the partitions: %v", err)
 	}
 
+	// the partitions that were just created have no filesystems yet
+	if err := repairContent(sfdisk, device, gadgetRoot, lv); err != nil {
+		return err
+	}
+	if options.FactoryReset {
+		return wipeData(device, gadgetRoot, lv)
+	}
 	return nil
 }
+
+// Check verifies that the partition table of the device is compatible with
+// the volume declared by the gadget. With repair set, the filesystems of the
+// structures that are present on the device but are missing their content are
+// created again.
+func Check(gadgetRoot, device string, repair bool) error {
+	lv, err := gadget.PositionedVolumeFromGadget(gadgetRoot)
+	if err != nil {
+		return fmt.Errorf("cannot layout the volume: %v", err)
+	}
+
+	disk, err := disks.DiskFromDeviceName(device)
+	if err != nil {
+		return err
+	}
+	diskLayout, err := gadget.LaidOutVolumeFromDisk(disk)
+	if err != nil {
+		return fmt.Errorf("cannot read %v partitions: %v", device, err)
+	}
+	if err := gadget.EnsureLayoutCompatibility(lv, diskLayout); err != nil {
+		return fmt.Errorf("gadget and %v partition table not compatible: %v", device, err)
+	}
+	missing := gadget.StructuresMissingContent(lv, diskLayout)
+	if len(missing) == 0 {
+		return nil
+	}
+	if !repair {
+		return fmt.Errorf("cannot find content of structure %v on %v", missing[0], device)
+	}
+	return partition.RepairContentOnDisk(disk, gadgetRoot, missing)
+}
+
+func repairContent(sfdisk *partition.SFDisk, device, gadgetRoot string, lv *gadget.LaidOutVolume) error {
+	diskLayout, err := sfdisk.Layout()
+	if err != nil {
+		return fmt.Errorf("cannot read %v partitions: %v", device, err)
+	}
+	return sfdisk.RepairContent(gadgetRoot, gadget.StructuresMissingContent(lv, diskLayout))
+}
+
+// wipeData creates the filesystems of the system-data structures of the
+// device again.
+func wipeData(device, gadgetRoot string, lv *gadget.LaidOutVolume) error {
+	var data []gadget.LaidOutStructure
+	for _, ps := range lv.LaidOutStructure {
+		if ps.EffectiveRole() == gadget.SystemData {
+			data = append(data, ps)
+		}
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("cannot find the system-data structure of %v", device)
+	}
+	disk, err := disks.DiskFromDeviceName(device)
+	if err != nil {
+		return err
+	}
+	return partition.RepairContentOnDisk(disk, gadgetRoot, data)
+}
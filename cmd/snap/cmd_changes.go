@@ -43,6 +43,7 @@ change.
 type cmdChanges struct {
 	clientMixin
 	timeMixin
+	formatMixin
 	Positional struct {
 		Snap string `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -55,7 +56,7 @@ type cmdTasks struct {
 
 func init() {
 	addCommand("changes", shortChangesHelp, longChangesHelp,
-		func() flags.Commander { return &cmdChanges{} }, timeDescs, nil)
+		func() flags.Commander { return &cmdChanges{} }, timeDescs.also(formatDescs), nil)
 	addCommand("tasks", shortTasksHelp, longTasksHelp,
 		func() flags.Commander { return &cmdTasks{} },
 		changeIDMixinOptDesc.also(timeDescs),
@@ -106,12 +107,19 @@ func (c *cmdChanges) Execute(args []string) error {
 		return err
 	}
 
+	sort.Sort(changesByTime(changes))
+
+	if c.wantJSON() {
+		if changes == nil {
+			changes = []*client.Change{}
+		}
+		return writeJSON(changes)
+	}
+
 	if len(changes) == 0 {
 		return fmt.Errorf(i18n.G("no changes found"))
 	}
 
-	sort.Sort(changesByTime(changes))
-
 	w := tabWriter()
 
 	fmt.Fprintf(w, i18n.G("ID\tStatus\tSpawn\tReady\tSummary\n"))
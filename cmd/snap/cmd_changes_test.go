@@ -20,6 +20,7 @@
 package main_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -231,3 +232,37 @@ Doing +2016-04-21T01:02:03Z +2016-04-21T01:02:04Z +some summary \(50.00%\)
 `)
 	c.Check(s.Stderr(), check.Equals, "")
 }
+
+func (s *SnapSuite) TestChangesJSON(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Assert(r.URL.Path, check.Equals, "/v2/changes")
+		fmt.Fprintln(w, mockChangesJSON)
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"changes", "--json"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stderr(), check.Equals, "")
+
+	var changes []map[string]interface{}
+	c.Assert(json.Unmarshal([]byte(s.Stdout()), &changes), check.IsNil)
+	c.Assert(changes, check.HasLen, 4)
+	// sorted by spawn time, as in the text output
+	for i, id := range []string{"four", "three", "one", "two"} {
+		c.Check(changes[i]["id"], check.Equals, id)
+	}
+	c.Check(changes[0]["kind"], check.Equals, "install-snap")
+	c.Check(changes[0]["status"], check.Equals, "Do")
+	c.Check(changes[0]["spawn-time"], check.Equals, "2015-02-21T01:02:03Z")
+}
+
+func (s *SnapSuite) TestChangesJSONNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.URL.Path, check.Equals, "/v2/changes")
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"changes", "--format=json"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "[]\n")
+	c.Check(s.Stderr(), check.Equals, "")
+}
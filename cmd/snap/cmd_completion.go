@@ -0,0 +1,339 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortCompletionHelp = i18n.G("Generate shell completion scripts")
+var longCompletionHelp = i18n.G(`
+The completion command prints the script that sets up the completion of snap
+commands in the given shell, one of bash, zsh or fish.
+
+The script asks snap itself for the possible values of what is being completed,
+such as the names of installed snaps, change IDs, or the channels of a snap in
+the store.
+
+For example, to set up the completion in the current bash session:
+
+    $ source <(snap completion bash)
+`)
+
+type cmdCompletion struct {
+	Positional struct {
+		Shell completionShell `positional-arg-name:"<shell>"`
+	} `positional-args:"yes" required:"yes"`
+	parser *flags.Parser
+}
+
+func init() {
+	addCommand("completion", shortCompletionHelp, longCompletionHelp, func() flags.Commander { return &cmdCompletion{} }, nil, []argDesc{{
+		// TRANSLATORS: This needs to begin with < and end with >
+		name: i18n.G("<shell>"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("The shell to generate the completion script for: bash, zsh or fish"),
+	}})
+}
+
+func (x *cmdCompletion) setParser(parser *flags.Parser) {
+	x.parser = parser
+}
+
+type completionShell string
+
+func (completionShell) Complete(match string) []flags.Completion {
+	var ret []flags.Completion
+	for _, shell := range completionShells() {
+		if strings.HasPrefix(shell, match) {
+			ret = append(ret, flags.Completion{Item: shell})
+		}
+	}
+	return ret
+}
+
+func completionShells() []string {
+	shells := make([]string, 0, len(completionTemplates))
+	for shell := range completionTemplates {
+		shells = append(shells, shell)
+	}
+	sort.Strings(shells)
+	return shells
+}
+
+// completionCommand is a top-level command as listed by the completion
+// scripts that show the commands along with their descriptions.
+type completionCommand struct {
+	Name        string
+	Description string
+}
+
+func (x *cmdCompletion) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	shell := string(x.Positional.Shell)
+	tmpl, ok := completionTemplates[shell]
+	if !ok {
+		return fmt.Errorf(i18n.G("cannot generate completion for %q, supported shells are: %s"), shell, strings.Join(completionShells(), ", "))
+	}
+
+	var commands []completionCommand
+	for _, cmd := range x.parser.Commands() {
+		if cmd.Hidden {
+			continue
+		}
+		commands = append(commands, completionCommand{
+			Name:        cmd.Name,
+			Description: cmd.ShortDescription,
+		})
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+
+	return tmpl.Execute(Stdout, struct{ Commands []completionCommand }{commands})
+}
+
+var completionFuncs = template.FuncMap{
+	// quote for the shell, within single quotes
+	"shquote": func(s string) string {
+		return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+	},
+	// quote for fish, which allows escaping within single quotes
+	"fishquote": func(s string) string {
+		s = strings.Replace(s, `\`, `\\`, -1)
+		return "'" + strings.Replace(s, "'", `\'`, -1) + "'"
+	},
+}
+
+var completionTemplates = map[string]*template.Template{
+	"bash": template.Must(template.New("bash").Funcs(completionFuncs).Parse(bashCompletionTemplate)),
+	"zsh":  template.Must(template.New("zsh").Funcs(completionFuncs).Parse(zshCompletionTemplate)),
+	"fish": template.Must(template.New("fish").Funcs(completionFuncs).Parse(fishCompletionTemplate)),
+}
+
+// All the scripts leave the completion of the commands, their options and
+// arguments to snap itself, through go-flags' GO_FLAGS_COMPLETION; besides
+// the word being completed only the command is passed on, except when
+// completing the value of --channel, for which the snap in question is
+// passed too (see channelName).
+
+const bashCompletionTemplate = `# -*- sh -*-
+# Generated by 'snap completion bash'.
+
+_complete_snap() {
+    local cur prev words cword split
+    _init_completion -s -n : || return
+
+    if [[ ${#words[@]} -le 2 ]]; then
+        # we're completing on the first word
+        COMPREPLY=($(GO_FLAGS_COMPLETION=1 "${words[@]}"))
+        return 0
+    fi
+
+    local command
+    if [[ ${words[1]} =~ ^- ]]; then
+        # global options take no args
+        return 0
+    fi
+
+    for w in "${words[@]:1}"; do
+        if [[ "$w" == "-h" || "$w" == "--help" ]]; then
+            # completing on help gets confusing
+            return 0
+        fi
+    done
+
+    command="${words[1]}"
+
+    # Only split on newlines
+    local IFS=$'\n'
+
+    if [[ "$prev" == "--channel" ]]; then
+        # the channels are those of the snap named on the command line
+        local snapname="" i
+        for ((i = 2; i < cword; i++)); do
+            if [[ "${words[i]}" == "--channel" ]]; then
+                ((i++))
+            elif [[ "${words[i]}" != -* ]]; then
+                snapname="${words[i]}"
+                break
+            fi
+        done
+        COMPREPLY=($(GO_FLAGS_COMPLETION=1 snap "$command" ${snapname:+"$snapname"} --channel "$cur"))
+        return 0
+    fi
+
+    # now we pass _just the bit that's being completed_ of the command
+    # to snap for it to figure it out. go-flags isn't smart enough to
+    # look at COMP_WORDS etc. itself.
+    if [ "$command" = "debug" ]; then
+        command="${words[2]}"
+        COMPREPLY=($(GO_FLAGS_COMPLETION=1 snap debug "$command" "$cur"))
+    else
+        COMPREPLY=($(GO_FLAGS_COMPLETION=1 snap "$command" "$cur"))
+    fi
+
+    case $command in
+        install|info|sign-build)
+            _filedir "snap"
+            ;;
+        ack)
+            _filedir
+            ;;
+        try)
+            _filedir -d
+            ;;
+        connect|disconnect|interfaces)
+            # interface completions will only end in ':' when they all
+            # end in ':' (i.e. you either get offered snap names up to
+            # and including the ':', or you get offered the whole thing)
+            if [[ "$COMPREPLY" == *: ]]; then
+                compopt -o nospace
+            fi
+    esac
+
+    __ltrim_colon_completions "$cur"
+
+    return 0
+}
+
+complete -F _complete_snap snap
+`
+
+const zshCompletionTemplate = `#compdef snap
+# Generated by 'snap completion zsh'.
+
+_snap() {
+    local cur="${words[CURRENT]}" prev="${words[CURRENT-1]}"
+    local -a reply
+
+    if (( CURRENT == 2 )); then
+        local -a commands
+        commands=(
+{{- range .Commands}}
+            {{shquote (printf "%s:%s" .Name .Description)}}
+{{- end}}
+        )
+        _describe -t commands command commands
+        return
+    fi
+
+    local command="${words[2]}"
+    if [[ "$command" == -* ]] || (( ${words[(I)-h|--help]} )); then
+        return
+    fi
+
+    local IFS=$'\n'
+    if [[ "$prev" == --channel || "$cur" == --channel=* ]]; then
+        # the channels are those of the snap named on the command line
+        local snapname="" i
+        for (( i = 3; i < CURRENT; i++ )); do
+            if [[ "${words[i]}" == --channel ]]; then
+                (( i++ ))
+            elif [[ "${words[i]}" != -* ]]; then
+                snapname="${words[i]}"
+                break
+            fi
+        done
+        if [[ "$prev" == --channel ]]; then
+            reply=($(GO_FLAGS_COMPLETION=1 snap "$command" ${snapname:+"$snapname"} --channel "$cur"))
+        else
+            reply=($(GO_FLAGS_COMPLETION=1 snap "$command" ${snapname:+"$snapname"} "$cur"))
+        fi
+    elif [[ "$command" == debug ]]; then
+        reply=($(GO_FLAGS_COMPLETION=1 snap debug "${words[3]}" "$cur"))
+    else
+        reply=($(GO_FLAGS_COMPLETION=1 snap "$command" "$cur"))
+    fi
+    compadd -- "${reply[@]}"
+
+    case "$command" in
+        install|info|sign-build)
+            _files -g '*.snap'
+            ;;
+        ack)
+            _files
+            ;;
+        try)
+            _files -/
+            ;;
+    esac
+}
+
+compdef _snap snap
+`
+
+const fishCompletionTemplate = `# Generated by 'snap completion fish'.
+
+function __snap_complete
+    set -l tokens (commandline -opc)
+    set -l cur (commandline -ct)
+    set -e tokens[1]
+    if test (count $tokens) -eq 0
+        return
+    end
+
+    set -l command $tokens[1]
+    if string match -q -- '-*' $command; or contains -- -h $tokens; or contains -- --help $tokens
+        return
+    end
+
+    if test "$tokens[-1]" = --channel; or string match -q -- '--channel=*' $cur
+        # the channels are those of the snap named on the command line
+        set -l snapname
+        set -l skip 0
+        if test (count $tokens) -gt 1
+            for t in $tokens[2..-1]
+                if test $skip -eq 1
+                    set skip 0
+                else if test "$t" = --channel
+                    set skip 1
+                else if not string match -q -- '-*' $t
+                    set snapname $t
+                    break
+                end
+            end
+        end
+        if test "$tokens[-1]" = --channel
+            env GO_FLAGS_COMPLETION=1 snap $command $snapname --channel "$cur"
+        else
+            env GO_FLAGS_COMPLETION=1 snap $command $snapname "$cur"
+        end
+    else if test "$command" = debug; and test (count $tokens) -gt 1
+        env GO_FLAGS_COMPLETION=1 snap debug $tokens[2] "$cur"
+    else
+        env GO_FLAGS_COMPLETION=1 snap $command "$cur"
+    end
+end
+
+complete -c snap -f -n 'not __fish_use_subcommand' -a '(__snap_complete)'
+complete -c snap -n '__fish_seen_subcommand_from install info sign-build ack try' -F
+{{- range .Commands}}
+complete -c snap -f -n __fish_use_subcommand -a {{fishquote .Name}} -d {{fishquote .Description}}
+{{- end}}
+`
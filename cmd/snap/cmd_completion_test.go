@@ -0,0 +1,133 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/jessevdk/go-flags"
+	. "gopkg.in/check.v1"
+
+	. "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestCompletionBash(c *C) {
+	rest, err := Parser(Client()).ParseArgs([]string{"completion", "bash"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Matches, `(?ms).*^# Generated by 'snap completion bash'\..*`+
+		`COMPREPLY=\(\$\(GO_FLAGS_COMPLETION=1 snap "\$command" \${snapname:\+"\$snapname"} --channel "\$cur"\)\).*`+
+		`^complete -F _complete_snap snap\n`)
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestCompletionZsh(c *C) {
+	_, err := Parser(Client()).ParseArgs([]string{"completion", "zsh"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Matches, `(?ms)#compdef snap$.*^ +'install:Install snaps on the system'$.*^compdef _snap snap\n`)
+	// hidden commands are not offered
+	c.Check(s.Stdout(), Not(Matches), `(?ms).*'keys:.*`)
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestCompletionFish(c *C) {
+	_, err := Parser(Client()).ParseArgs([]string{"completion", "fish"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Matches, `(?ms).*^function __snap_complete$.*`+
+		`^complete -c snap -f -n __fish_use_subcommand -a 'install' -d 'Install snaps on the system'$.*`)
+	c.Check(s.Stdout(), Not(Matches), `(?ms).* -a 'keys' .*`)
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestCompletionUnknownShell(c *C) {
+	_, err := Parser(Client()).ParseArgs([]string{"completion", "tcsh"})
+	c.Assert(err, ErrorMatches, `cannot generate completion for "tcsh", supported shells are: bash, fish, zsh`)
+	c.Check(s.Stdout(), Equals, "")
+}
+
+func (s *SnapSuite) TestCompletionShellCompletion(c *C) {
+	os.Setenv("GO_FLAGS_COMPLETION", "1")
+	defer os.Unsetenv("GO_FLAGS_COMPLETION")
+
+	var obtained []flags.Completion
+	parser := Parser(Client())
+	parser.CompletionHandler = func(cs []flags.Completion) {
+		obtained = cs
+	}
+	_, err := parser.ParseArgs([]string{"completion", ""})
+	c.Assert(err, IsNil)
+	c.Check(obtained, DeepEquals, []flags.Completion{{Item: "bash"}, {Item: "fish"}, {Item: "zsh"}})
+}
+
+func (s *SnapSuite) TestChannelCompletion(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/find")
+		c.Check(r.URL.Query().Get("name"), Equals, "hello")
+		fmt.Fprintln(w, `{"type": "sync", "result": [{
+  "name": "hello",
+  "tracks": ["latest", "2"],
+  "channels": {
+    "latest/stable": {"revision": "1", "version": "2.10", "channel": "stable"},
+    "2/beta": {"revision": "3", "version": "3.0", "channel": "2/beta"}
+  }
+}]}`)
+	})
+
+	os.Setenv("GO_FLAGS_COMPLETION", "1")
+	defer os.Unsetenv("GO_FLAGS_COMPLETION")
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+
+	var obtained []flags.Completion
+	parser := Parser(Client())
+	parser.CompletionHandler = func(cs []flags.Completion) {
+		obtained = cs
+	}
+
+	// the channels of the snap named on the command line
+	args := []string{"refresh", "hello", "--channel", ""}
+	os.Args = append([]string{"snap"}, args...)
+	_, err := parser.ParseArgs(args)
+	c.Assert(err, IsNil)
+	c.Check(obtained, DeepEquals, []flags.Completion{
+		{Item: "2"}, {Item: "2/beta"}, {Item: "beta"}, {Item: "candidate"}, {Item: "edge"},
+		{Item: "latest"}, {Item: "latest/stable"}, {Item: "stable"},
+	})
+
+	args = []string{"refresh", "hello", "--channel=l"}
+	os.Args = append([]string{"snap"}, args...)
+	_, err = parser.ParseArgs(args)
+	c.Assert(err, IsNil)
+	c.Check(obtained, DeepEquals, []flags.Completion{{Item: "--channel=latest"}, {Item: "--channel=latest/stable"}})
+	c.Check(n, Equals, 2)
+
+	// without a snap only the risks are offered
+	args = []string{"install", "--channel", "e"}
+	os.Args = append([]string{"snap"}, args...)
+	_, err = parser.ParseArgs(args)
+	c.Assert(err, IsNil)
+	c.Check(obtained, DeepEquals, []flags.Completion{{Item: "edge"}})
+	c.Check(n, Equals, 2)
+}
@@ -32,6 +32,7 @@ import (
 
 type cmdConnections struct {
 	clientMixin
+	formatMixin
 	All         bool `long:"all"`
 	Positionals struct {
 		Snap installedSnapName
@@ -56,9 +57,9 @@ snap.
 func init() {
 	addCommand("connections", shortConnectionsHelp, longConnectionsHelp, func() flags.Commander {
 		return &cmdConnections{}
-	}, map[string]string{
+	}, formatDescs.also(map[string]string{
 		"all": i18n.G("Show connected and unconnected plugs and slots"),
-	}, []argDesc{{
+	}), []argDesc{{
 		// TRANSLATORS: This needs to be wrapped in <>s.
 		name: "<snap>",
 		// TRANSLATORS: This should not start with a lowercase letter.
@@ -131,6 +132,38 @@ func interfaceDeterminant(conn *client.Connection) string {
 	return fmt.Sprintf("[%v]", value)
 }
 
+// connectionJSON is the JSON representation of a connection, or of an
+// unconnected plug or slot, in the output of snap connections --json.
+// The plugs and slots are given as in the text output, but an empty
+// string stands for the missing side of an unconnected one.
+type connectionJSON struct {
+	Interface string `json:"interface"`
+	Plug      string `json:"plug"`
+	Slot      string `json:"slot"`
+	Manual    bool   `json:"manual"`
+	Gadget    bool   `json:"gadget"`
+}
+
+func writeConnectionsJSON(conns []connection) error {
+	out := make([]connectionJSON, len(conns))
+	for i, cn := range conns {
+		out[i] = connectionJSON{
+			Interface: cn.interfaceName,
+			Plug:      cn.plug,
+			Slot:      cn.slot,
+			Manual:    cn.manual,
+			Gadget:    cn.gadget,
+		}
+		if out[i].Plug == "-" {
+			out[i].Plug = ""
+		}
+		if out[i].Slot == "-" {
+			out[i].Slot = ""
+		}
+	}
+	return writeJSON(out)
+}
+
 func (x *cmdConnections) Execute(args []string) error {
 	if len(args) > 0 {
 		return ErrExtraArgs
@@ -159,6 +192,9 @@ func (x *cmdConnections) Execute(args []string) error {
 		return err
 	}
 	if len(connections.Plugs) == 0 && len(connections.Slots) == 0 {
+		if x.wantJSON() {
+			return writeConnectionsJSON(nil)
+		}
 		return nil
 	}
 
@@ -174,9 +210,6 @@ func (x *cmdConnections) Execute(args []string) error {
 		})
 	}
 
-	w := tabWriter()
-	fmt.Fprintln(w, i18n.G("Interface\tPlug\tSlot\tNotes"))
-
 	for _, plug := range connections.Plugs {
 		if len(plug.Connections) == 0 && x.All {
 			annotatedConns = append(annotatedConns, connection{
@@ -203,6 +236,12 @@ func (x *cmdConnections) Execute(args []string) error {
 
 	sort.Sort(byConnectionData(annotatedConns))
 
+	if x.wantJSON() {
+		return writeConnectionsJSON(annotatedConns)
+	}
+
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Interface\tPlug\tSlot\tNotes"))
 	for _, note := range annotatedConns {
 		fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\n", note.interfaceName, note.interfaceDeterminant, note.plug, note.slot, note)
 	}
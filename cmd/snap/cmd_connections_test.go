@@ -277,6 +277,82 @@ func (s *SnapSuite) TestConnectionsSomeConnected(c *C) {
 	c.Assert(s.Stderr(), Equals, "")
 }
 
+func (s *SnapSuite) TestConnectionsJSON(c *C) {
+	result := client.Connections{
+		Established: []client.Connection{
+			{
+				Plug:      client.PlugRef{Snap: "keyboard-lights", Name: "numlock"},
+				Slot:      client.SlotRef{Snap: "core", Name: "numlock-led"},
+				Interface: "leds",
+				Manual:    true,
+			},
+		},
+		Plugs: []client.Plug{
+			{
+				Snap:      "keyboard-lights",
+				Name:      "numlock",
+				Interface: "leds",
+				Connections: []client.SlotRef{{
+					Snap: "core",
+					Name: "numlock-led",
+				}},
+			}, {
+				Snap:      "keyboard-lights",
+				Name:      "capslock",
+				Interface: "leds",
+			},
+		},
+		Slots: []client.Slot{
+			{
+				Snap:      "core",
+				Name:      "numlock-led",
+				Interface: "leds",
+				Connections: []client.PlugRef{{
+					Snap: "keyboard-lights",
+					Name: "numlock",
+				}},
+			},
+		},
+	}
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/connections")
+		EncodeResponseBody(c, w, map[string]interface{}{
+			"type":   "sync",
+			"result": result,
+		})
+	})
+	rest, err := Parser(Client()).ParseArgs([]string{"connections", "--all", "--json"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Assert(s.Stdout(), Equals, `[
+  {
+    "interface": "leds",
+    "plug": "keyboard-lights:capslock",
+    "slot": "",
+    "manual": false,
+    "gadget": false
+  },
+  {
+    "interface": "leds",
+    "plug": "keyboard-lights:numlock",
+    "slot": ":numlock-led",
+    "manual": true,
+    "gadget": false
+  }
+]
+`)
+	c.Assert(s.Stderr(), Equals, "")
+
+	s.ResetStdStreams()
+
+	result = client.Connections{}
+	_, err = Parser(Client()).ParseArgs([]string{"connections", "--format=json"})
+	c.Assert(err, IsNil)
+	c.Assert(s.Stdout(), Equals, "[]\n")
+	c.Assert(s.Stderr(), Equals, "")
+}
+
 func (s *SnapSuite) TestConnectionsSomeDisconnected(c *C) {
 	result := client.Connections{
 		Established: []client.Connection{
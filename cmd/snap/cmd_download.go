@@ -137,7 +137,7 @@ func (x *cmdDownload) Execute(args []string) error {
 	dlOpts := image.DownloadOptions{
 		TargetDir: x.TargetDir,
 		Basename:  x.Basename,
-		Channel:   x.Channel,
+		Channel:   string(x.Channel),
 		CohortKey: x.CohortKey,
 		Revision:  revision,
 		// if something goes wrong, don't force it to start over again
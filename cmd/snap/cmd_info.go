@@ -47,6 +47,7 @@ type infoCmd struct {
 	clientMixin
 	colorMixin
 	timeMixin
+	formatMixin
 
 	Verbose    bool `long:"verbose"`
 	Positional struct {
@@ -70,7 +71,7 @@ func init() {
 		longInfoHelp,
 		func() flags.Commander {
 			return &infoCmd{}
-		}, colorDescs.also(timeDescs).also(map[string]string{
+		}, colorDescs.also(timeDescs).also(formatDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"verbose": i18n.G("Include more details on the snap (expanded notes, base, etc.)"),
 		}), nil)
@@ -387,6 +388,12 @@ func (iw *infoWriter) maybePrintBase() {
 	}
 }
 
+func (iw *infoWriter) maybePrintEpoch() {
+	if iw.verbose && iw.theSnap.Epoch != nil {
+		fmt.Fprintf(iw, "epoch:\t%s\n", iw.theSnap.Epoch)
+	}
+}
+
 func (iw *infoWriter) maybePrintPath() {
 	if iw.path != "" {
 		fmt.Fprintf(iw, "path:\t%q\n", iw.path)
@@ -662,7 +669,50 @@ func (chInfos *channelInfos) dump(w io.Writer) {
 	}
 }
 
+// snapInfoJSON is the JSON representation of a snap in the output of
+// snap info --json. A snap given by name can be both installed and in
+// the store, while one given by path is only described by its file.
+type snapInfoJSON struct {
+	Name      string       `json:"name"`
+	Path      string       `json:"path,omitempty"`
+	File      *client.Snap `json:"file,omitempty"`
+	Installed *client.Snap `json:"installed,omitempty"`
+	Store     *client.Snap `json:"store,omitempty"`
+}
+
+func (x *infoCmd) writeInfoJSON() error {
+	infos := make([]snapInfoJSON, 0, len(x.Positional.Snaps))
+	for _, snapName := range x.Positional.Snaps {
+		snapName := string(snapName)
+		var info snapInfoJSON
+		if diskSnap, err := clientSnapFromPath(snapName); err == nil {
+			info = snapInfoJSON{Name: diskSnap.Name, Path: norm(snapName), File: diskSnap}
+		} else if snapName != "system" {
+			remoteSnap, _, _ := x.client.FindOne(snap.InstanceSnap(snapName))
+			localSnap, _, _ := x.client.Snap(snapName)
+			info = snapInfoJSON{Name: snapName, Installed: localSnap, Store: remoteSnap}
+		}
+		if info.File == nil && info.Installed == nil && info.Store == nil {
+			if len(x.Positional.Snaps) == 1 {
+				return fmt.Errorf("no snap found for %q", snapName)
+			}
+			fmt.Fprintf(Stderr, i18n.G("warning: no snap found for %q\n"), snapName)
+			continue
+		}
+		infos = append(infos, info)
+	}
+	if len(infos) == 0 {
+		return fmt.Errorf(i18n.G("no valid snaps given"))
+	}
+
+	return writeJSON(infos)
+}
+
 func (x *infoCmd) Execute([]string) error {
+	if x.wantJSON() {
+		return x.writeInfoJSON()
+	}
+
 	termWidth, _ := termSize()
 	termWidth -= 3
 	if termWidth > 100 {
@@ -730,6 +780,7 @@ func (x *infoCmd) Execute([]string) error {
 		iw.Flush()
 		iw.maybePrintType()
 		iw.maybePrintBase()
+		iw.maybePrintEpoch()
 		iw.maybePrintSum()
 		iw.maybePrintID()
 		iw.maybePrintCohortKey()
@@ -21,6 +21,7 @@ package main_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -273,7 +274,7 @@ func (s *infoSuite) TestMaybePrintBuildDate(c *check.C) {
 	c.Assert(ioutil.WriteFile(arbfile, nil, 0600), check.IsNil)
 	filename := filepath.Join(c.MkDir(), "foo.snap")
 	diskSnap := squashfs.New(filename)
-	c.Assert(diskSnap.Build(dir, "app"), check.IsNil)
+	c.Assert(diskSnap.Build(dir, &squashfs.BuildOpts{SnapType: "app"}), check.IsNil)
 	buildDate := diskSnap.BuildDate().Format(time.Kitchen)
 
 	// no disk snap -> no build date
@@ -305,7 +306,7 @@ func (s *infoSuite) TestMaybePrintSum(c *check.C) {
 	dir := c.MkDir()
 	filename := filepath.Join(c.MkDir(), "foo.snap")
 	diskSnap := squashfs.New(filename)
-	c.Assert(diskSnap.Build(dir, "app"), check.IsNil)
+	c.Assert(diskSnap.Build(dir, &squashfs.BuildOpts{SnapType: "app"}), check.IsNil)
 	iw := snap.NewInfoWriter(&buf)
 	snap.SetVerbose(iw, true)
 
@@ -348,6 +349,33 @@ func (s *infoSuite) TestMaybePrintContact(c *check.C) {
 	}
 }
 
+func (s *infoSuite) TestMaybePrintEpoch(c *check.C) {
+	var buf flushBuffer
+	iw := snap.NewInfoWriter(&buf)
+	dSnap := &client.Snap{}
+	snap.SetupDiskSnap(iw, "", dSnap)
+
+	// no epoch -> no epoch
+	snap.SetVerbose(iw, true)
+	snap.MaybePrintEpoch(iw)
+	c.Check(buf.String(), check.Equals, "")
+	buf.Reset()
+
+	// no verbose -> no epoch
+	epoch := snaplib.E("1*")
+	dSnap.Epoch = &epoch
+	snap.SetVerbose(iw, false)
+	snap.MaybePrintEpoch(iw)
+	c.Check(buf.String(), check.Equals, "")
+	buf.Reset()
+
+	// epoch + verbose -> epoch
+	snap.SetVerbose(iw, true)
+	snap.MaybePrintEpoch(iw)
+	c.Check(buf.String(), check.Equals, "epoch:\t1*\n")
+	buf.Reset()
+}
+
 func (s *infoSuite) TestMaybePrintBase(c *check.C) {
 	var buf flushBuffer
 	iw := snap.NewInfoWriter(&buf)
@@ -726,6 +754,57 @@ func (s *infoSuite) TestInfoNotFound(c *check.C) {
 	c.Check(s.Stderr(), check.Equals, "")
 }
 
+func (s *infoSuite) TestInfoJSON(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		switch r.URL.Path {
+		case "/v2/find":
+			if r.URL.Query().Get("name") == "hello" {
+				fmt.Fprint(w, mockInfoJSON)
+				return
+			}
+		case "/v2/snaps/hello":
+			fmt.Fprint(w, mockInfoJSONNoLicense)
+			return
+		case "/v2/snaps/x":
+		default:
+			c.Fatalf("unexpected request %v", r)
+		}
+		w.WriteHeader(404)
+		fmt.Fprintln(w, `{"type":"error","status-code":404,"status":"Not Found","result":{"message":"No.","kind":"snap-not-found","value":"x"}}`)
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"info", "--json", "hello", "x"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stderr(), check.Equals, "warning: no snap found for \"x\"\n")
+
+	var raw []map[string]json.RawMessage
+	c.Assert(json.Unmarshal([]byte(s.Stdout()), &raw), check.IsNil)
+	c.Assert(raw, check.HasLen, 1)
+	c.Check(string(raw[0]["name"]), check.Equals, `"hello"`)
+	c.Check(raw[0]["path"], check.IsNil)
+	c.Check(raw[0]["file"], check.IsNil)
+	var installed, store client.Snap
+	c.Assert(json.Unmarshal(raw[0]["installed"], &installed), check.IsNil)
+	c.Assert(json.Unmarshal(raw[0]["store"], &store), check.IsNil)
+	c.Check(installed.Revision.String(), check.Equals, "100")
+	c.Check(installed.TrackingChannel, check.Equals, "beta")
+	c.Check(store.Revision.String(), check.Equals, "1")
+	c.Check(store.License, check.Equals, "MIT")
+}
+
+func (s *infoSuite) TestInfoJSONNotFound(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+		fmt.Fprintln(w, `{"type":"error","status-code":404,"status":"Not Found","result":{"message":"No.","kind":"snap-not-found","value":"x"}}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"info", "--format=json", "x"})
+	c.Check(err, check.ErrorMatches, `no snap found for "x"`)
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"info", "--format=json", "x", "system"})
+	c.Check(err, check.ErrorMatches, `no valid snaps given`)
+	c.Check(s.Stdout(), check.Equals, "")
+}
+
 func (s *infoSuite) TestInfoWithLocalNoLicense(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
@@ -1102,11 +1181,11 @@ func (s *infoSuite) TestInfoParllelInstance(c *check.C) {
 			q := r.URL.Query()
 			// asks for the instance snap
 			c.Check(q.Get("name"), check.Equals, "hello")
-			fmt.Fprintln(w, mockInfoJSONWithChannels)
+			fmt.Fprint(w, mockInfoJSONWithChannels)
 		case 1:
 			c.Check(r.Method, check.Equals, "GET")
 			c.Check(r.URL.Path, check.Equals, "/v2/snaps/hello_foo")
-			fmt.Fprintln(w, mockInfoJSONParallelInstance)
+			fmt.Fprint(w, mockInfoJSONParallelInstance)
 		default:
 			c.Fatalf("expected to get 2 requests, now on %d (%v)", n+1, r)
 		}
@@ -49,11 +49,12 @@ type cmdList struct {
 
 	All bool `long:"all"`
 	colorMixin
+	formatMixin
 }
 
 func init() {
 	addCommand("list", shortListHelp, longListHelp, func() flags.Commander { return &cmdList{} },
-		colorDescs.also(map[string]string{
+		colorDescs.also(formatDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"all": i18n.G("Show all revisions"),
 		}), nil)
@@ -113,6 +114,9 @@ func (x *cmdList) Execute(args []string) error {
 	if err != nil {
 		if err == client.ErrNoSnapsInstalled {
 			if len(names) == 0 {
+				if x.wantJSON() {
+					return writeJSON([]*client.Snap{})
+				}
 				fmt.Fprintln(Stderr, i18n.G("No snaps are installed yet. Try 'snap install hello-world'."))
 				return nil
 			} else {
@@ -125,6 +129,10 @@ func (x *cmdList) Execute(args []string) error {
 	}
 	sort.Sort(snapsByName(snaps))
 
+	if x.wantJSON() {
+		return writeJSON(snaps)
+	}
+
 	esc := x.getEscapes()
 	w := tabWriter()
 
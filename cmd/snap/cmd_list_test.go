@@ -20,6 +20,7 @@
 package main_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 
@@ -43,6 +44,10 @@ indicates that the publisher has been verified.
                                       some things. (default: auto)
       --unicode=[auto|never|always]   Use a little bit of Unicode to improve
                                       legibility. (default: auto)
+      --format=[text|json]            Output format, either 'text' or 'json'.
+                                      (default: text)
+      --json                          Output results in JSON format, same as
+                                      --format=json.
 `
 	s.testSubCommandHelp(c, "list", msg)
 }
@@ -82,6 +87,41 @@ foo   4.2      17   potatoes  bar        blocked
 	c.Check(s.Stderr(), check.Equals, "")
 }
 
+func (s *SnapSuite) TestListJSON(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+		fmt.Fprintln(w, `{"type": "sync", "result": [
+{"name": "zed", "status": "active", "version": "1.0", "revision": 1, "tracking-channel": "stable"},
+{"name": "foo", "status": "active", "version": "4.2", "revision": 17, "tracking-channel": "potatoes"}
+]}`)
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"list", "--json"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stderr(), check.Equals, "")
+
+	var snaps []map[string]interface{}
+	c.Assert(json.Unmarshal([]byte(s.Stdout()), &snaps), check.IsNil)
+	c.Assert(snaps, check.HasLen, 2)
+	c.Check(snaps[0]["name"], check.Equals, "foo")
+	c.Check(snaps[0]["version"], check.Equals, "4.2")
+	c.Check(snaps[0]["revision"], check.Equals, "17")
+	c.Check(snaps[0]["tracking-channel"], check.Equals, "potatoes")
+	c.Check(snaps[1]["name"], check.Equals, "zed")
+}
+
+func (s *SnapSuite) TestListJSONNoSnapsInstalled(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"list", "--format=json"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "[]\n")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
 func (s *SnapSuite) TestListAll(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
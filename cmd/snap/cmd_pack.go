@@ -33,6 +33,7 @@ import (
 type packCmd struct {
 	CheckSkeleton bool   `long:"check-skeleton"`
 	Filename      string `long:"filename"`
+	Compression   string `long:"compression"`
 	Positional    struct {
 		SnapDir   string `positional-arg-name:"<snap-dir>"`
 		TargetDir string `positional-arg-name:"<target-dir>"`
@@ -51,6 +52,10 @@ in some situations it's simpler for a script to feed the filename in. In those
 cases, --filename can be given to override the default. If this filename is
 not absolute it will be taken as relative to target-dir.
 
+The snap is compressed with xz by default. With --compression=zstd the
+snap is faster to decompress, but it can only be mounted by kernels 4.14 or
+later, or with squashfuse.
+
 When used with --check-skeleton, pack only checks whether snap-dir contains
 valid snap metadata and raises an error otherwise. Application commands listed
 in snap metadata file, but appearing with incorrect permission bits result in an
@@ -69,6 +74,8 @@ func init() {
 			"check-skeleton": i18n.G("Validate snap-dir metadata only"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"filename": i18n.G("Output to this filename"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"compression": i18n.G("Compression to use (xz, lzo or zstd)"),
 		}, nil)
 	cmd.extra = func(cmd *flags.Command) {
 		// TRANSLATORS: this describes the default filename for a snap, e.g. core_16-2.35.2_amd64.snap
@@ -90,13 +97,18 @@ func (x *packCmd) Execute([]string) error {
 
 	if x.CheckSkeleton {
 		err := pack.CheckSkeleton(x.Positional.SnapDir)
-		if err == snap.ErrMissingPaths {
+		if verr, ok := err.(*snap.ContainerValidationError); ok && len(verr.BadModes) == 0 {
+			// only missing paths, which is fine for a skeleton
 			return nil
 		}
 		return err
 	}
 
-	snapPath, err := pack.Snap(x.Positional.SnapDir, x.Positional.TargetDir, x.Filename)
+	snapPath, err := pack.Snap(x.Positional.SnapDir, &pack.Options{
+		TargetDir:   x.Positional.TargetDir,
+		SnapName:    x.Filename,
+		Compression: x.Compression,
+	})
 	if err != nil {
 		// TRANSLATORS: the %q is the snap-dir (the first positional
 		// argument to the command); the %v is an error
@@ -41,6 +41,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/portal"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/osutil/strace"
@@ -440,6 +441,15 @@ func (x *cmdRun) snapRunHook(snapName string) error {
 }
 
 func (x *cmdRun) snapRunTimer(snapApp, timer string, args []string) error {
+	if (&snap.TimerInfo{Timer: timer}).UsesCalendarSyntax() {
+		// systemd alone decides when calendar events elapse, there
+		// is no window to check the current time against
+		if _, err := timeutil.ParseCalendarEvents(timer); err != nil {
+			return fmt.Errorf("invalid timer format: %v", err)
+		}
+		return x.snapRunApp(snapApp, args)
+	}
+
 	schedule, err := timeutil.ParseSchedule(timer)
 	if err != nil {
 		return fmt.Errorf("invalid timer format: %v", err)
@@ -617,17 +627,9 @@ func migrateXauthority(info *snap.Info) (string, error) {
 	return targetPath, nil
 }
 
-func activateXdgDocumentPortal(info *snap.Info, snapApp, hook string) error {
-	// Don't do anything for apps or hooks that don't plug the
-	// desktop interface
-	//
-	// NOTE: This check is imperfect because we don't really know
-	// if the interface is connected or not but this is an
-	// acceptable compromise for not having to communicate with
-	// snapd in snap run. In a typical desktop session the
-	// document portal can be in use by many applications, not
-	// just by snaps, so this is at most, pre-emptively using some
-	// extra memory.
+// plugsDesktop returns whether the app or hook plugs the desktop
+// interface.
+func plugsDesktop(info *snap.Info, snapApp, hook string) bool {
 	var plugs map[string]*snap.PlugInfo
 	if hook != "" {
 		plugs = info.Hooks[hook].Plugs
@@ -635,14 +637,26 @@ func activateXdgDocumentPortal(info *snap.Info, snapApp, hook string) error {
 		_, appName := snap.SplitSnapApp(snapApp)
 		plugs = info.Apps[appName].Plugs
 	}
-	plugsDesktop := false
 	for _, plug := range plugs {
 		if plug.Interface == "desktop" {
-			plugsDesktop = true
-			break
+			return true
 		}
 	}
-	if !plugsDesktop {
+	return false
+}
+
+func activateXdgDocumentPortal(info *snap.Info, snapApp, hook string) error {
+	// Don't do anything for apps or hooks that don't plug the
+	// desktop interface
+	//
+	// NOTE: This check is imperfect because we don't really know
+	// if the interface is connected or not but this is an
+	// acceptable compromise for not having to communicate with
+	// snapd in snap run. In a typical desktop session the
+	// document portal can be in use by many applications, not
+	// just by snaps, so this is at most, pre-emptively using some
+	// extra memory.
+	if !plugsDesktop(info, snapApp, hook) {
 		return nil
 	}
 
@@ -654,7 +668,7 @@ func activateXdgDocumentPortal(info *snap.Info, snapApp, hook string) error {
 
 	// If $XDG_RUNTIME_DIR/doc appears to be a mount point, assume
 	// that the document portal is up and running.
-	expectedMountPoint := filepath.Join(xdgRuntimeDir, "doc")
+	expectedMountPoint := portal.DocumentsDir(xdgRuntimeDir)
 	if mounted, err := osutil.IsMounted(expectedMountPoint); err != nil {
 		logger.Noticef("Could not check document portal mount state: %s", err)
 	} else if mounted {
@@ -685,10 +699,10 @@ func activateXdgDocumentPortal(info *snap.Info, snapApp, hook string) error {
 		return err
 	}
 
-	portal := conn.Object("org.freedesktop.portal.Documents",
+	documents := conn.Object(portal.DocumentsBusName,
 		"/org/freedesktop/portal/documents")
 	var mountPoint []byte
-	if err := portal.Call("org.freedesktop.portal.Documents.GetMountPoint", 0).Store(&mountPoint); err != nil {
+	if err := documents.Call("org.freedesktop.portal.Documents.GetMountPoint", 0).Store(&mountPoint); err != nil {
 		// It is not considered an error if
 		// xdg-document-portal is not available on the system.
 		if dbusErr, ok := err.(dbus.Error); ok && dbusErr.Name == "org.freedesktop.DBus.Error.ServiceUnknown" {
@@ -712,6 +726,50 @@ func activateXdgDocumentPortal(info *snap.Info, snapApp, hook string) error {
 	return nil
 }
 
+// registerWithXdgDesktopPortal registers the app or hook with
+// xdg-desktop-portal. Autostart apps and user daemons, which run
+// without a window, are also allowed to run in the background.
+func registerWithXdgDesktopPortal(info *snap.Info, snapApp, hook string) error {
+	if !plugsDesktop(info, snapApp, hook) {
+		return nil
+	}
+
+	// as for the document portal, don't auto-launch a session bus
+	// nor bother when the portals were found to be unavailable
+	if len(osGetenv("DBUS_SESSION_BUS_ADDRESS")) == 0 {
+		return nil
+	}
+	u, err := userCurrent()
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot get the current user: %s"), err)
+	}
+	xdgRuntimeDir := filepath.Join(dirs.XdgRuntimeDirBase, u.Uid)
+	if osutil.FileExists(filepath.Join(xdgRuntimeDir, ".portals-unavailable")) {
+		return nil
+	}
+
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return err
+	}
+
+	if err := portal.Register(conn, info.InstanceName()); err != nil && !portal.IsUnsupported(err) {
+		return err
+	}
+	if hook != "" {
+		return nil
+	}
+	_, appName := snap.SplitSnapApp(snapApp)
+	app := info.Apps[appName]
+	if app.Autostart == "" && !app.IsUserService() {
+		return nil
+	}
+	if err := portal.AllowBackground(conn, info.InstanceName()); err != nil && !portal.IsUnsupported(err) {
+		return err
+	}
+	return nil
+}
+
 func (x *cmdRun) runCmdUnderGdb(origCmd, env []string) error {
 	env = append(env, "SNAP_CONFINE_RUN_UNDER_GDB=1")
 
@@ -892,6 +950,9 @@ func (x *cmdRun) runSnapConfine(info *snap.Info, securityTag, snapApp, hook stri
 	if err := activateXdgDocumentPortal(info, snapApp, hook); err != nil {
 		logger.Noticef("WARNING: cannot start document portal: %s", err)
 	}
+	if err := registerWithXdgDesktopPortal(info, snapApp, hook); err != nil {
+		logger.Noticef("WARNING: cannot register with desktop portal: %s", err)
+	}
 
 	cmd := []string{snapConfine}
 	if info.NeedsClassic() {
@@ -995,6 +995,60 @@ func (s *RunSuite) TestSnapRunAppTimer(c *check.C) {
 		"snapname.app", "--arg1", "arg2"})
 }
 
+func (s *RunSuite) TestSnapRunAppCalendarTimer(c *check.C) {
+	defer mockSnapConfine(dirs.DistroLibExecDir)()
+
+	// mock installed snap
+	snaptest.MockSnapCurrent(c, string(mockYaml), &snap.SideInfo{
+		Revision: snap.R("x2"),
+	})
+
+	// redirect exec
+	execArgs := []string{}
+	execCalled := false
+	restorer := snaprun.MockSyscallExec(func(arg0 string, args []string, envv []string) error {
+		execArgs = args
+		execCalled = true
+		return nil
+	})
+	defer restorer()
+
+	restorer = snaprun.MockTimeNow(func() time.Time {
+		// Sunday Feb 11, 23:55, no calendar event elapses then,
+		// but systemd alone decides when to start the timer
+		return time.Date(2018, 02, 11, 23, 55, 0, 0, time.UTC)
+	})
+	defer restorer()
+
+	rest, err := snaprun.Parser(snaprun.Client()).ParseArgs([]string{"run", `--timer="Mon..Fri 8..12/2:0/30 UTC"`, "--", "snapname.app", "--arg1", "arg2"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{"snapname.app", "--arg1", "arg2"})
+	c.Assert(execCalled, check.Equals, true)
+	c.Check(execArgs, check.DeepEquals, []string{
+		filepath.Join(dirs.DistroLibExecDir, "snap-confine"),
+		"snap.snapname.app",
+		filepath.Join(dirs.CoreLibExecDir, "snap-exec"),
+		"snapname.app", "--arg1", "arg2"})
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *RunSuite) TestSnapRunAppCalendarTimerInvalid(c *check.C) {
+	defer mockSnapConfine(dirs.DistroLibExecDir)()
+
+	snaptest.MockSnapCurrent(c, string(mockYaml), &snap.SideInfo{
+		Revision: snap.R("x2"),
+	})
+
+	restorer := snaprun.MockSyscallExec(func(arg0 string, args []string, envv []string) error {
+		c.Fatalf("unexpected exec")
+		return nil
+	})
+	defer restorer()
+
+	_, err := snaprun.Parser(snaprun.Client()).ParseArgs([]string{"run", `--timer="Mon..Fri 25:00"`, "--", "snapname.app"})
+	c.Assert(err, check.ErrorMatches, `invalid timer format: .*`)
+}
+
 func (s *RunSuite) TestRunCmdWithTraceExecUnhappy(c *check.C) {
 	defer mockSnapConfine(dirs.DistroLibExecDir)()
 
@@ -32,6 +32,7 @@ import (
 
 type svcStatus struct {
 	clientMixin
+	formatMixin
 	Positional struct {
 		ServiceNames []serviceName
 	} `positional-args:"yes"`
@@ -81,7 +82,7 @@ func init() {
 		// TRANSLATORS: This should not start with a lowercase letter.
 		desc: i18n.G("A service specification, which can be just a snap name (for all services in the snap), or <snap>.<app> for a single service."),
 	}}
-	addCommand("services", shortServicesHelp, longServicesHelp, func() flags.Commander { return &svcStatus{} }, nil, argdescs)
+	addCommand("services", shortServicesHelp, longServicesHelp, func() flags.Commander { return &svcStatus{} }, formatDescs, argdescs)
 	addCommand("logs", shortLogsHelp, longLogsHelp, func() flags.Commander { return &svcLogs{} },
 		map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
@@ -125,6 +126,10 @@ func (s *svcStatus) Execute(args []string) error {
 		return err
 	}
 
+	if s.wantJSON() {
+		return writeServicesJSON(services)
+	}
+
 	if len(services) == 0 {
 		fmt.Fprintln(Stderr, i18n.G("There are no services provided by installed snaps."))
 		return nil
@@ -150,6 +155,32 @@ func (s *svcStatus) Execute(args []string) error {
 	return nil
 }
 
+// serviceJSON is the JSON representation of a service in the output of
+// snap services --json.
+type serviceJSON struct {
+	Service string `json:"service"`
+	Snap    string `json:"snap"`
+	App     string `json:"app"`
+	Daemon  string `json:"daemon"`
+	Enabled bool   `json:"enabled"`
+	Active  bool   `json:"active"`
+}
+
+func writeServicesJSON(services []*client.AppInfo) error {
+	out := make([]serviceJSON, len(services))
+	for i, svc := range services {
+		out[i] = serviceJSON{
+			Service: svc.Snap + "." + svc.Name,
+			Snap:    svc.Snap,
+			App:     svc.Name,
+			Daemon:  svc.Daemon,
+			Enabled: svc.Enabled,
+			Active:  svc.Active,
+		}
+	}
+	return writeJSON(out)
+}
+
 func (s *svcLogs) Execute(args []string) error {
 	if len(args) > 0 {
 		return ErrExtraArgs
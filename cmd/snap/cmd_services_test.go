@@ -219,6 +219,47 @@ foo.zed  enabled  active    -
 	c.Check(n, check.Equals, 1)
 }
 
+func (s *appOpSuite) TestAppStatusJSON(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/apps")
+		c.Check(r.URL.Query().Get("select"), check.Equals, "service")
+		w.WriteHeader(200)
+		enc := json.NewEncoder(w)
+		enc.Encode(map[string]interface{}{
+			"type": "sync",
+			"result": []map[string]interface{}{
+				{"snap": "foo", "name": "bar", "daemon": "oneshot", "enabled": true},
+				{"snap": "foo", "name": "zed", "daemon": "simple", "active": true},
+			},
+			"status":      "OK",
+			"status-code": 200,
+		})
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"services", "--json"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `[
+  {
+    "service": "foo.bar",
+    "snap": "foo",
+    "app": "bar",
+    "daemon": "oneshot",
+    "enabled": true,
+    "active": false
+  },
+  {
+    "service": "foo.zed",
+    "snap": "foo",
+    "app": "zed",
+    "daemon": "simple",
+    "enabled": false,
+    "active": true
+  }
+]
+`)
+}
+
 func (s *appOpSuite) TestServiceCompletion(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
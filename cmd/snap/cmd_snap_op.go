@@ -198,7 +198,7 @@ func (x *cmdRemove) Execute([]string) error {
 }
 
 type channelMixin struct {
-	Channel string `long:"channel"`
+	Channel channelName `long:"channel"`
 
 	// shortcuts
 	EdgeChannel      bool `long:"edge"`
@@ -249,10 +249,10 @@ func (mx *channelMixin) setChannelFromCommandline() error {
 		if mx.Channel != "" {
 			return fmt.Errorf("Please specify a single channel")
 		}
-		mx.Channel = ch.chName
+		mx.Channel = channelName(ch.chName)
 	}
 
-	if !strings.Contains(mx.Channel, "/") && mx.Channel != "" && mx.Channel != "edge" && mx.Channel != "beta" && mx.Channel != "candidate" && mx.Channel != "stable" {
+	if !strings.Contains(string(mx.Channel), "/") && mx.Channel != "" && mx.Channel != "edge" && mx.Channel != "beta" && mx.Channel != "candidate" && mx.Channel != "stable" {
 		// shortcut to jump to a different track, e.g.
 		// snap install foo --channel=3.4 # implies 3.4/stable
 		mx.Channel += "/stable"
@@ -557,7 +557,7 @@ func (x *cmdInstall) Execute([]string) error {
 
 	dangerous := x.Dangerous || x.ForceDangerous
 	opts := &client.SnapOptions{
-		Channel:   x.Channel,
+		Channel:   string(x.Channel),
 		Revision:  x.Revision,
 		Dangerous: dangerous,
 		Unaliased: x.Unaliased,
@@ -766,7 +766,7 @@ func (x *cmdRefresh) Execute([]string) error {
 	if len(names) == 1 {
 		opts := &client.SnapOptions{
 			Amend:            x.Amend,
-			Channel:          x.Channel,
+			Channel:          string(x.Channel),
 			IgnoreValidation: x.IgnoreValidation,
 			Revision:         x.Revision,
 			CohortKey:        x.Cohort,
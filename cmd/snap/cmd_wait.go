@@ -34,19 +34,36 @@ import (
 
 type cmdWait struct {
 	clientMixin
+	Equals     string        `long:"equals"`
+	Health     bool          `long:"health"`
+	Timeout    time.Duration `long:"timeout"`
 	Positional struct {
 		Snap installedSnapName `required:"yes"`
 		Key  string
 	} `positional-args:"yes"`
 }
 
+var longWaitHelp = i18n.G(`
+The wait command waits until a configuration becomes true, or, with --equals,
+until it has the given value.
+
+With --health, it instead waits until the health of the snap is okay.
+`)
+
 func init() {
 	addCommand("wait",
 		"Wait for configuration",
-		"The wait command waits until a configuration becomes true.",
+		longWaitHelp,
 		func() flags.Commander {
 			return &cmdWait{}
-		}, nil, []argDesc{
+		}, map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"equals": i18n.G("Wait until the configuration has this value (taken as JSON, or else as a string)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"health": i18n.G("Wait until the health of the snap is okay"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"timeout": i18n.G("Give up waiting after this long (e.g. 30s or 5m)"),
+		}, []argDesc{
 			{
 				name: "<snap>",
 				// TRANSLATORS: This should not start with a lowercase letter.
@@ -109,6 +126,49 @@ func trueishJSON(vi interface{}) (bool, error) {
 	return false, fmt.Errorf("cannot test type %T for truth", vi)
 }
 
+// equalsJSON tells whether v, as decoded from JSON, is the given value,
+// which is taken as JSON if it can be decoded as such and as a string
+// otherwise, like snap set does.
+func equalsJSON(v interface{}, value string) bool {
+	var want interface{}
+	if err := json.Unmarshal([]byte(value), &want); err != nil {
+		want = value
+	}
+	// decode v again without json.Number, so that e.g. 1 and 1.0
+	// are the same
+	b, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	var got interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+// waitFor polls until cond holds, failing with timeoutErr if that did
+// not happen within the timeout given, if any.
+func (x *cmdWait) waitFor(cond func() (bool, error), timeoutErr error) error {
+	var deadline time.Time
+	if x.Timeout > 0 {
+		deadline = time.Now().Add(x.Timeout)
+	}
+	for {
+		ok, err := cond()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return timeoutErr
+		}
+		time.Sleep(waitConfTimeout)
+	}
+}
+
 func (x *cmdWait) Execute(args []string) error {
 	if len(args) > 0 {
 		return ErrExtraArgs
@@ -132,24 +192,30 @@ The same is true of the laugh.`)
 		}
 		return nil
 	}
+	if x.Health {
+		if confKey != "" || x.Equals != "" {
+			return fmt.Errorf(i18n.G("cannot wait for the health of a snap and for its configuration at the same time"))
+		}
+		return x.waitFor(func() (bool, error) {
+			snap, _, err := x.client.Snap(snapName)
+			if err != nil {
+				return false, err
+			}
+			return snap.Health != nil && snap.Health.Status == "okay", nil
+		}, fmt.Errorf(i18n.G("timeout waiting for the health of snap %q to be okay"), snapName))
+	}
 	if confKey == "" {
 		return fmt.Errorf("the required argument `<key>` was not provided")
 	}
 
-	for {
+	return x.waitFor(func() (bool, error) {
 		conf, err := x.client.Conf(snapName, []string{confKey})
 		if err != nil && !isNoOption(err) {
-			return err
-		}
-		res, err := trueishJSON(conf[confKey])
-		if err != nil {
-			return err
+			return false, err
 		}
-		if res {
-			break
+		if x.Equals != "" {
+			return equalsJSON(conf[confKey], x.Equals), nil
 		}
-		time.Sleep(waitConfTimeout)
-	}
-
-	return nil
+		return trueishJSON(conf[confKey])
+	}, fmt.Errorf(i18n.G("timeout waiting for configuration %q of snap %q"), confKey, snapName))
 }
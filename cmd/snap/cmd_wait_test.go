@@ -172,3 +172,84 @@ func (s *SnapSuite) TestCmdWaitIntegration(c *C) {
 		}
 	}
 }
+
+func (s *SnapSuite) TestCmdWaitEquals(c *C) {
+	restore := snap.MockWaitConfTimeout(time.Millisecond)
+	defer restore()
+
+	for _, t := range []struct {
+		values []string
+		equals string
+	}{
+		{[]string{`"bar"`, `"baz"`, `"foo"`}, "foo"},
+		{[]string{`"1"`, `2`, `1.0`}, "1"},
+		{[]string{`{"a": 1}`, `{"a": 2}`}, `{"a":2.0}`},
+		{[]string{`true`, `false`}, "false"},
+	} {
+		n := 0
+		s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+			c.Check(r.URL.Path, Equals, "/v2/snaps/foo/conf")
+			if n >= len(t.values) {
+				c.Fatalf("waited for too long for %q", t.equals)
+			}
+			fmt.Fprintf(w, `{"type":"sync", "status-code": 200, "result": {"key": %s}}`, t.values[n])
+			n++
+		})
+
+		_, err := snap.Parser(snap.Client()).ParseArgs([]string{"wait", "--equals", t.equals, "foo", "key"})
+		c.Assert(err, IsNil)
+		c.Check(n, Equals, len(t.values))
+	}
+}
+
+func (s *SnapSuite) TestCmdWaitTimeout(c *C) {
+	restore := snap.MockWaitConfTimeout(time.Millisecond)
+	defer restore()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v2/snaps/foo/conf")
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {"key": false}}`)
+		n++
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"wait", "--timeout", "20ms", "foo", "key"})
+	c.Assert(err, ErrorMatches, `timeout waiting for configuration "key" of snap "foo"`)
+	c.Check(n > 1, Equals, true)
+}
+
+func (s *SnapSuite) TestCmdWaitHealth(c *C) {
+	restore := snap.MockWaitConfTimeout(time.Millisecond)
+	defer restore()
+
+	healths := []string{``, `, "health": {"status": "waiting"}`, `, "health": {"status": "okay"}`}
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/snaps/foo")
+		if n >= len(healths) {
+			c.Fatalf("waited for too long")
+		}
+		fmt.Fprintf(w, `{"type":"sync", "status-code": 200, "result": {"name": "foo"%s}}`, healths[n])
+		n++
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"wait", "--health", "foo"})
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 3)
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {"name": "foo", "health": {"status": "error"}}}`)
+	})
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"wait", "--health", "--timeout=5ms", "foo"})
+	c.Assert(err, ErrorMatches, `timeout waiting for the health of snap "foo" to be okay`)
+}
+
+func (s *SnapSuite) TestCmdWaitHealthWithKey(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request")
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"wait", "--health", "foo", "key"})
+	c.Assert(err, ErrorMatches, `cannot wait for the health of a snap and for its configuration at the same time`)
+}
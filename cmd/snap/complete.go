@@ -23,6 +23,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -146,6 +147,55 @@ func (s anySnapName) Complete(match string) []flags.Completion {
 	return res
 }
 
+type channelName string
+
+// Complete offers the channels of the snap the completion is for, as
+// found in the store, or just the risks if there is no such snap.
+func (s channelName) Complete(match string) []flags.Completion {
+	candidates := append([]string(nil), channelRisks...)
+	if snapName := completionSnapName(); snapName != "" {
+		if snap, _, err := mkClient().FindOne(snapName); err == nil {
+			candidates = append(candidates, snap.Tracks...)
+			for ch := range snap.Channels {
+				candidates = append(candidates, ch)
+			}
+		}
+	}
+	sort.Strings(candidates)
+
+	var ret []flags.Completion
+	for i, ch := range candidates {
+		if i > 0 && ch == candidates[i-1] {
+			continue
+		}
+		if strings.HasPrefix(ch, match) {
+			ret = append(ret, flags.Completion{Item: ch})
+		}
+	}
+	return ret
+}
+
+// completionSnapName returns the snap named on the command line of a
+// completion request, that is the first argument after the command that
+// is neither an option nor the value of --channel, if any. The word
+// being completed, which comes last, is not considered.
+func completionSnapName() string {
+	if len(os.Args) < 3 {
+		return ""
+	}
+	args := os.Args[2 : len(os.Args)-1]
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--channel" {
+			i++
+			continue
+		}
+		if !strings.HasPrefix(args[i], "-") {
+			return args[i]
+		}
+	}
+	return ""
+}
+
 type changeID string
 
 func (s changeID) Complete(match string) []flags.Completion {
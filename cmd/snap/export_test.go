@@ -113,6 +113,7 @@ var (
 	MaybePrintBuildDate         = (*infoWriter).maybePrintBuildDate
 	MaybePrintContact           = (*infoWriter).maybePrintContact
 	MaybePrintBase              = (*infoWriter).maybePrintBase
+	MaybePrintEpoch             = (*infoWriter).maybePrintEpoch
 	MaybePrintPath              = (*infoWriter).maybePrintPath
 	MaybePrintSum               = (*infoWriter).maybePrintSum
 	MaybePrintCohortKey         = (*infoWriter).maybePrintCohortKey
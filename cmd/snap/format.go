@@ -0,0 +1,53 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+// formatMixin lets the user pick between the usual human readable
+// output of a command and a machine readable one.
+type formatMixin struct {
+	Format string `long:"format" default:"text" choice:"text" choice:"json"`
+	JSON   bool   `long:"json"`
+}
+
+var formatDescs = mixinDescs{
+	// TRANSLATORS: This should not start with a lowercase letter.
+	"format": i18n.G("Output format, either 'text' or 'json'."),
+	// TRANSLATORS: This should not start with a lowercase letter.
+	"json": i18n.G("Output results in JSON format, same as --format=json."),
+}
+
+func (mx formatMixin) wantJSON() bool {
+	return mx.JSON || mx.Format == "json"
+}
+
+// writeJSON writes v to stdout as indented JSON. The field names of
+// the objects written form an interface scripts rely on, so they must
+// not change.
+func writeJSON(v interface{}) error {
+	enc := json.NewEncoder(Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
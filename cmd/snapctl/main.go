@@ -58,6 +58,11 @@ func main() {
 	// no internal command, route via snapd
 	stdout, stderr, err := run()
 	if err != nil {
+		if e, ok := err.(*client.UnsuccessfulError); ok {
+			os.Stdout.Write(e.Stdout)
+			os.Stderr.Write(e.Stderr)
+			os.Exit(e.ExitCode)
+		}
 		fmt.Fprintf(os.Stderr, "error: %s\n", err)
 		os.Exit(1)
 	}
@@ -80,6 +80,11 @@ func ClientSnapFromSnapInfo(snapInfo *snap.Info) (*client.Snap, error) {
 		CommonIDs:   snapInfo.CommonIDs,
 		Website:     snapInfo.Website,
 	}
+	if !snapInfo.Epoch.IsZero() {
+		// the default epoch is not worth reporting
+		epoch := snapInfo.Epoch
+		result.Epoch = &epoch
+	}
 
 	return result, err
 }
@@ -89,7 +94,10 @@ func ClientAppInfoNotes(app *client.AppInfo) string {
 		return "-"
 	}
 
-	var notes = make([]string, 0, 2)
+	var notes = make([]string, 0, 3)
+	if app.DaemonScope == snap.UserDaemon {
+		notes = append(notes, "user")
+	}
 	var seenTimer, seenSocket bool
 	for _, act := range app.Activators {
 		switch act.Type {
@@ -129,6 +137,7 @@ func ClientAppInfosFromSnapAppInfos(apps []*snap.AppInfo) ([]client.AppInfo, err
 	// TODO: pass in an actual notifier here instead of null
 	//       (Status doesn't _need_ it, but benefits from it)
 	sysd := systemd.New(dirs.GlobalRootDir, systemd.SystemMode, progress.Null)
+	userGlobalSysd := systemd.New(dirs.GlobalRootDir, systemd.GlobalUserMode, progress.Null)
 
 	out := make([]client.AppInfo, 0, len(apps))
 	for _, app := range apps {
@@ -142,11 +151,23 @@ func ClientAppInfosFromSnapAppInfos(apps []*snap.AppInfo) ([]client.AppInfo, err
 		}
 
 		appInfo.Daemon = app.Daemon
+		appInfo.DaemonScope = app.DaemonScope
 		if !app.IsService() || !app.Snap.IsActive() {
 			out = append(out, appInfo)
 			continue
 		}
 
+		if app.IsUserService() {
+			// user services run in the sessions of the logged-in
+			// users, only whether they are enabled for all of them
+			// can be reported here
+			if err := userServiceEnableState(userGlobalSysd, app, &appInfo); err != nil {
+				return nil, err
+			}
+			out = append(out, appInfo)
+			continue
+		}
+
 		// collect all services for a single call to systemctl
 		serviceNames := make([]string, 0, 1+len(app.Sockets)+1)
 		serviceNames = append(serviceNames, app.ServiceName())
@@ -197,3 +218,23 @@ func ClientAppInfosFromSnapAppInfos(apps []*snap.AppInfo) ([]client.AppInfo, err
 
 	return out, nil
 }
+
+func userServiceEnableState(sysd systemd.Systemd, app *snap.AppInfo, appInfo *client.AppInfo) error {
+	enabled, err := sysd.IsEnabled(app.ServiceName())
+	if err != nil {
+		return fmt.Errorf("cannot get status of services of app %q: %v", app.Name, err)
+	}
+	appInfo.Enabled = enabled
+	if app.Timer != nil {
+		enabled, err := sysd.IsEnabled(filepath.Base(app.Timer.File()))
+		if err != nil {
+			return fmt.Errorf("cannot get status of services of app %q: %v", app.Name, err)
+		}
+		appInfo.Activators = append(appInfo.Activators, client.AppActivator{
+			Name:    app.Name,
+			Enabled: enabled,
+			Type:    "timer",
+		})
+	}
+	return nil
+}
@@ -35,6 +35,13 @@ func (*cmdSuite) TestC2S(c *check.C) {
 	ci, err := cmd.ClientSnapFromSnapInfo(si)
 	c.Check(err, check.IsNil)
 	c.Check(ci.Website, check.Equals, si.Website)
+	// the default epoch is not reported
+	c.Check(ci.Epoch, check.IsNil)
+
+	si.Epoch = snap.E("1*")
+	ci, err = cmd.ClientSnapFromSnapInfo(si)
+	c.Check(err, check.IsNil)
+	c.Check(ci.Epoch, check.DeepEquals, &si.Epoch)
 }
 
 func (*cmdSuite) TestAppStatusNotes(c *check.C) {
@@ -79,4 +86,13 @@ func (*cmdSuite) TestAppStatusNotes(c *check.C) {
 		},
 	}
 	c.Check(cmd.ClientAppInfoNotes(&ai), check.Equals, "timer-activated,socket-activated")
+
+	ai = client.AppInfo{
+		Daemon:      "simple",
+		DaemonScope: snap.UserDaemon,
+		Activators: []client.AppActivator{
+			{Type: "timer"},
+		},
+	}
+	c.Check(cmd.ClientAppInfoNotes(&ai), check.Equals, "user,timer-activated")
 }
@@ -49,6 +49,7 @@ import (
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/cmd"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/httputil"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/interfaces"
@@ -107,6 +108,12 @@ var api = []*Command{
 	modelCmd,
 	cohortsCmd,
 	serialModelCmd,
+	registrationModelCmd,
+	systemsCmd,
+	featuresCmd,
+	promptsCmd,
+	promptCmd,
+	promptDenialsCmd,
 }
 
 var (
@@ -771,6 +778,10 @@ type snapInstruction struct {
 	License  *licenseData `json:"license"`
 	Snaps    []string     `json:"snaps"`
 	Users    []string     `json:"users"`
+	// Time is used by hold to specify until when auto-refreshes
+	// are held, either as a RFC3339 timestamp, a duration or
+	// "forever".
+	Time string `json:"time,omitempty"`
 
 	// The fields below should not be unmarshalled into. Do not export them.
 	userID int
@@ -820,14 +831,19 @@ var (
 	snapstateRevert            = snapstate.Revert
 	snapstateRevertToRevision  = snapstate.RevertToRevision
 	snapstateSwitch            = snapstate.Switch
+	snapstateHoldRefreshes     = snapstate.HoldRefreshes
+	snapstateUnholdRefreshes   = snapstate.UnholdRefreshes
 
-	snapshotList    = snapshotstate.List
-	snapshotCheck   = snapshotstate.Check
-	snapshotForget  = snapshotstate.Forget
-	snapshotRestore = snapshotstate.Restore
-	snapshotSave    = snapshotstate.Save
+	snapshotList        = snapshotstate.List
+	snapshotCheck       = snapshotstate.Check
+	snapshotForget      = snapshotstate.Forget
+	snapshotRestore     = snapshotstate.Restore
+	snapshotSave        = snapshotstate.Save
+	snapshotExpirations = snapshotstate.Expirations
 
 	assertstateRefreshSnapDeclarations = assertstate.RefreshSnapDeclarations
+
+	ifacestateConnectInstallingProvider = ifacestate.ConnectInstallingProvider
 )
 
 func ensureStateSoonImpl(st *state.State) {
@@ -871,6 +887,9 @@ func snapUpdateMany(inst *snapInstruction, st *state.State) (*snapInstructionRes
 	if err != nil {
 		return nil, err
 	}
+	if err := checkDiskSpace(st, "refresh", updated, tasksets); err != nil {
+		return nil, err
+	}
 
 	var msg string
 	switch len(updated) {
@@ -913,6 +932,9 @@ func verifySnapInstructions(inst *snapInstruction) error {
 			return fmt.Errorf("leave-cohort can only be specified for refresh or switch")
 		}
 	}
+	if inst.Time != "" && inst.Action != "hold" {
+		return fmt.Errorf("time can only be specified for hold")
+	}
 	switch inst.Action {
 	case "install":
 		for _, snapName := range inst.Snaps {
@@ -937,6 +959,9 @@ func snapInstallMany(inst *snapInstruction, st *state.State) (*snapInstructionRe
 	if err != nil {
 		return nil, err
 	}
+	if err := checkDiskSpace(st, "install", installed, tasksets); err != nil {
+		return nil, err
+	}
 
 	var msg string
 	switch len(inst.Snaps) {
@@ -1149,6 +1174,69 @@ func snapshotMany(inst *snapInstruction, st *state.State) (*snapInstructionResul
 	}, nil
 }
 
+// parseHoldTime parses the time until which refreshes should be held,
+// given either as a RFC3339 timestamp, a duration from now or
+// "forever". Holding forever is expressed as a zero time.
+func parseHoldTime(holdTime string) (time.Time, error) {
+	switch holdTime {
+	case "":
+		return time.Time{}, fmt.Errorf("hold requires a time")
+	case "forever":
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, holdTime); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(holdTime)
+	if err != nil || d <= 0 {
+		return time.Time{}, fmt.Errorf("cannot parse hold time %q: expected a RFC3339 timestamp, a positive duration or \"forever\"", holdTime)
+	}
+	return time.Now().Add(d), nil
+}
+
+func snapHoldMany(inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
+	if len(inst.Snaps) == 0 {
+		return nil, fmt.Errorf(i18n.G("cannot hold auto-refreshes without snap names"))
+	}
+	until, err := parseHoldTime(inst.Time)
+	if err != nil {
+		return nil, err
+	}
+	if err := snapstateHoldRefreshes(st, inst.Snaps, until); err != nil {
+		return nil, err
+	}
+
+	var msg string
+	if until.IsZero() {
+		// TRANSLATORS: the %s is a comma-separated list of quoted snap names
+		msg = fmt.Sprintf(i18n.G("Hold auto-refreshes of snaps %s"), strutil.Quoted(inst.Snaps))
+	} else {
+		// TRANSLATORS: the first %s is a comma-separated list of quoted snap names, the second is a timestamp
+		msg = fmt.Sprintf(i18n.G("Hold auto-refreshes of snaps %s until %s"), strutil.Quoted(inst.Snaps), until.Format(time.RFC3339))
+	}
+
+	return &snapInstructionResult{
+		Summary:  msg,
+		Affected: inst.Snaps,
+	}, nil
+}
+
+func snapUnholdMany(inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
+	if len(inst.Snaps) == 0 {
+		return nil, fmt.Errorf(i18n.G("cannot unhold auto-refreshes without snap names"))
+	}
+	if err := snapstateUnholdRefreshes(st, inst.Snaps); err != nil {
+		return nil, err
+	}
+
+	// TRANSLATORS: the %s is a comma-separated list of quoted snap names
+	msg := fmt.Sprintf(i18n.G("Remove hold on auto-refreshes of snaps %s"), strutil.Quoted(inst.Snaps))
+	return &snapInstructionResult{
+		Summary:  msg,
+		Affected: inst.Snaps,
+	}, nil
+}
+
 type snapActionFunc func(*snapInstruction, *state.State) (string, []*state.TaskSet, error)
 
 var snapInstructionDispTable = map[string]snapActionFunc{
@@ -1328,6 +1416,10 @@ func snapsOp(c *Command, r *http.Request, user *auth.UserState) Response {
 		op = snapRemoveMany
 	case "snapshot":
 		op = snapshotMany
+	case "hold":
+		op = snapHoldMany
+	case "unhold":
+		op = snapUnholdMany
 	default:
 		return BadRequest("unsupported multi-snap operation %q", inst.Action)
 	}
@@ -1530,7 +1622,21 @@ func unsafeReadSnapInfoImpl(snapPath string) (*snap.Info, error) {
 	if err != nil {
 		return nil, err
 	}
-	return snap.ReadInfoFromSnapFile(snapf, nil)
+	// read the gadget metadata along, so that broken gadget snaps are
+	// refused before installing them
+	info, files, err := snap.ReadInfoAndFilesFromSnapFile(snapf, nil, []string{"meta/gadget.yaml"})
+	if err != nil {
+		return nil, err
+	}
+	if info.GetType() == snap.TypeGadget {
+		constraints := &gadget.ModelConstraints{
+			Classic: release.OnClassic,
+		}
+		if _, err := gadget.InfoFromSnapFiles(files, constraints); err != nil {
+			return nil, fmt.Errorf("invalid gadget snap: %v", err)
+		}
+	}
+	return info, nil
 }
 
 var unsafeReadSnapInfo = unsafeReadSnapInfoImpl
@@ -1780,6 +1886,22 @@ func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Respons
 
 	switch a.Action {
 	case "connect":
+		var userID int
+		if user != nil {
+			userID = user.ID
+		}
+		// the slot may be provided by a default content provider
+		// that is not installed yet
+		tasksets, err = ifacestateConnectInstallingProvider(r.Context(), st, a.Plugs[0].Snap, a.Plugs[0].Name, a.Slots[0].Snap, a.Slots[0].Name, userID)
+		if err != nil {
+			return errToResponse(err, nil, BadRequest, "%v")
+		}
+		if len(tasksets) > 0 {
+			affected = []string{a.Plugs[0].Snap, a.Slots[0].Snap}
+			summary = fmt.Sprintf("Connect %s:%s to snap %q", a.Plugs[0].Snap, a.Plugs[0].Name, a.Slots[0].Snap)
+			break
+		}
+
 		var connRef *interfaces.ConnRef
 		repo := c.d.overlord.InterfaceManager().Repository()
 		connRef, err = repo.ResolveConnect(a.Plugs[0].Snap, a.Plugs[0].Name, a.Slots[0].Snap, a.Slots[0].Name)
@@ -2126,6 +2248,23 @@ func runSnapctl(c *Command, r *http.Request, user *auth.UserState) Response {
 		if e, ok := err.(*ctlcmd.ForbiddenCommandError); ok {
 			return Forbidden(e.Error())
 		}
+		// keep in sync with snapctl/main.go
+		if e, ok := err.(*ctlcmd.UnsuccessfulError); ok {
+			result := map[string]interface{}{
+				"stdout":    string(stdout),
+				"stderr":    string(stderr),
+				"exit-code": e.ExitCode,
+			}
+			return &resp{
+				Type: ResponseTypeError,
+				Result: &errorResult{
+					Message: e.Error(),
+					Kind:    errorKindUnsuccessful,
+					Value:   result,
+				},
+				Status: 200,
+			}
+		}
 		if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrHelp {
 			stdout = []byte(e.Error())
 		} else {
@@ -24,14 +24,18 @@ import (
 	"fmt"
 	"net/http"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/interfaces/mount"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/timings"
 )
 
@@ -100,7 +104,10 @@ type changeTimings struct {
 type debugTimings struct {
 	ChangeID string `json:"change-id"`
 	// total duration of the activity - present for ensure and startup timings only
-	TotalDuration  time.Duration         `json:"total-duration,omitempty"`
+	TotalDuration time.Duration `json:"total-duration,omitempty"`
+	// total time spent in the handlers of the tasks of the change
+	DoingTime      time.Duration         `json:"doing-time,omitempty"`
+	UndoingTime    time.Duration         `json:"undoing-time,omitempty"`
 	EnsureTimings  []*timings.TimingJSON `json:"ensure-timings,omitempty"`
 	StartupTimings []*timings.TimingJSON `json:"startup-timings,omitempty"`
 	// ChangeTimings are indexed by task id
@@ -254,13 +261,80 @@ func getChangeTimings(st *state.State, changeID, ensureTag, startupTag string, a
 		return BadRequest(err.Error())
 	}
 
-	responseData := []*debugTimings{
-		{
-			ChangeID:      changeID,
-			ChangeTimings: changeTimings,
-		},
+	debugTm := &debugTimings{
+		ChangeID:      changeID,
+		ChangeTimings: changeTimings,
+	}
+	for _, tm := range changeTimings {
+		debugTm.DoingTime += tm.DoingTime
+		debugTm.UndoingTime += tm.UndoingTime
+	}
+	return SyncResponse([]*debugTimings{debugTm}, nil)
+}
+
+func getHandlerTimings(st *state.State) Response {
+	handlerTimings, err := timings.GetHandlerTimings(st)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	return SyncResponse(handlerTimings, nil)
+}
+
+func getSecurityProfiles(c *Command, st *state.State, snapName string) Response {
+	if snapName == "" {
+		return BadRequest("cannot get security profiles without a snap name")
+	}
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, snapName, &snapst); err == state.ErrNoState {
+		return SnapNotFound(snapName, fmt.Errorf("snap %q is not installed", snapName))
+	} else if err != nil {
+		return InternalError("%v", err)
+	}
+
+	profiles, err := c.d.overlord.InterfaceManager().InstalledProfiles(snapName)
+	if err != nil {
+		return InternalError("%v", err)
 	}
-	return SyncResponse(responseData, nil)
+	return SyncResponse(profiles, nil)
+}
+
+// getMountNamespace returns the mount namespace of the snap. The per-user
+// entries of users other than the caller are only visible to root.
+func getMountNamespace(st *state.State, r *http.Request, snapName string) Response {
+	if snapName == "" {
+		return BadRequest("cannot inspect mount namespace without a snap name")
+	}
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, snapName, &snapst); err == state.ErrNoState {
+		return SnapNotFound(snapName, fmt.Errorf("snap %q is not installed", snapName))
+	} else if err != nil {
+		return InternalError("%v", err)
+	}
+
+	info, err := mount.InspectNamespace(snapName)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	if _, uid, _, err := ucrednetGet(r.RemoteAddr); err != nil || uid != 0 {
+		userCurrent := info.UserCurrent
+		info.UserCurrent = nil
+		caller := strconv.FormatUint(uint64(uid), 10)
+		if entries, ok := userCurrent[caller]; ok && err == nil {
+			info.UserCurrent = map[string][]mount.NamespaceEntry{caller: entries}
+		}
+	}
+	return SyncResponse(info, nil)
+}
+
+func getKernelStatus() Response {
+	if release.OnClassic {
+		return BadRequest("cannot get kernel status on a classic system")
+	}
+	status, err := boot.GetKernelStatus()
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	return SyncResponse(status, nil)
 }
 
 func getDebug(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -288,6 +362,14 @@ func getDebug(c *Command, r *http.Request, user *auth.UserState) Response {
 		startupTag := query.Get("startup")
 		all := query.Get("all")
 		return getChangeTimings(st, chgID, ensureTag, startupTag, all == "true")
+	case "handler-timings":
+		return getHandlerTimings(st)
+	case "security-profiles":
+		return getSecurityProfiles(c, st, query.Get("snap"))
+	case "mount-ns":
+		return getMountNamespace(st, r, query.Get("snap"))
+	case "kernel-status":
+		return getKernelStatus()
 	default:
 		return BadRequest("unknown debug aspect %q", aspect)
 	}
@@ -22,11 +22,26 @@ package daemon
 import (
 	"bytes"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
 
 	"gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/bootloader/bootloadertest"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/mount"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
 	"github.com/snapcore/snapd/timings"
 )
@@ -157,6 +172,160 @@ func (s *postDebugSuite) TestGetDebugBaseDeclaration(c *check.C) {
 		testutil.Contains, "type: base-declaration")
 }
 
+func (s *postDebugSuite) TestGetDebugKernelStatus(c *check.C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+	bl := bootloadertest.Mock("mock", c.MkDir())
+	bootloader.Force(bl)
+	defer bootloader.Force(nil)
+	bl.BootVars["snap_kernel"] = "pc-kernel_1.snap"
+	bl.BootVars["snap_try_kernel"] = "pc-kernel_2.snap"
+
+	_ = s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=kernel-status", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getDebug(debugCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, &boot.KernelStatus{
+		Kernel:    "pc-kernel_1.snap",
+		TryKernel: "pc-kernel_2.snap",
+		Status:    boot.KernelReverted,
+	})
+}
+
+func (s *postDebugSuite) TestGetDebugKernelStatusOnClassic(c *check.C) {
+	restore := release.MockOnClassic(true)
+	defer restore()
+
+	_ = s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=kernel-status", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getDebug(debugCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "cannot get kernel status on a classic system")
+}
+
+func (s *postDebugSuite) TestGetDebugSecurityProfiles(c *check.C) {
+	d := s.daemon(c)
+	c.Assert(d.overlord.InterfaceManager().Repository().AddBackend(&udev.Backend{}), check.IsNil)
+	st := d.overlord.State()
+	st.Lock()
+	snapstate.Set(st, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "foo", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+	})
+	st.Unlock()
+
+	rules := filepath.Join(dirs.SnapUdevRulesDir, "70-snap.foo.rules")
+	c.Assert(os.MkdirAll(dirs.SnapUdevRulesDir, 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(rules, []byte("# rules\n"), 0644), check.IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=security-profiles&snap=foo", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getDebug(debugCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, map[interfaces.SecuritySystem]map[string]string{
+		interfaces.SecurityUDev: {rules: "# rules\n"},
+	})
+
+	req, err = http.NewRequest("GET", "/v2/debug?aspect=security-profiles&snap=bar", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getDebug(debugCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 404)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `snap "bar" is not installed`)
+
+	req, err = http.NewRequest("GET", "/v2/debug?aspect=security-profiles", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getDebug(debugCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+}
+
+func (s *postDebugSuite) TestGetDebugMountNamespace(c *check.C) {
+	d := s.daemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	snapstate.Set(st, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "foo", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+	})
+	st.Unlock()
+
+	c.Assert(os.MkdirAll(dirs.SnapMountPolicyDir, 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapMountPolicyDir, "snap.foo.fstab"),
+		[]byte("/snap/foo/1/lib /usr/lib/foo none rbind,x-snapd.origin=layout 0 0\n"), 0644), check.IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=mount-ns&snap=foo", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getDebug(debugCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, &mount.NamespaceInfo{
+		Desired: []mount.NamespaceEntry{{
+			Name:    "/snap/foo/1/lib",
+			Dir:     "/usr/lib/foo",
+			Type:    "none",
+			Options: []string{"rbind", "x-snapd.origin=layout"},
+			Origin:  "layout",
+		}},
+	})
+
+	req, err = http.NewRequest("GET", "/v2/debug?aspect=mount-ns&snap=bar", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getDebug(debugCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 404)
+
+	req, err = http.NewRequest("GET", "/v2/debug?aspect=mount-ns", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getDebug(debugCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+}
+
+func (s *postDebugSuite) TestGetDebugMountNamespaceUserEntries(c *check.C) {
+	d := s.daemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	snapstate.Set(st, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "foo", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+	})
+	st.Unlock()
+
+	c.Assert(os.MkdirAll(dirs.SnapRunNsDir, 0755), check.IsNil)
+	for _, uid := range []string{"1000", "1001"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapRunNsDir, "snap.foo."+uid+".user-fstab"),
+			[]byte("/run/user/"+uid+"/doc /run/user/"+uid+"/doc none rbind 0 0\n"), 0644), check.IsNil)
+	}
+
+	getUsers := func(remoteAddr string) []string {
+		req, err := http.NewRequest("GET", "/v2/debug?aspect=mount-ns&snap=foo", nil)
+		c.Assert(err, check.IsNil)
+		req.RemoteAddr = remoteAddr
+		rsp := getDebug(debugCmd, req, nil).(*resp)
+		c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+		var users []string
+		for uid := range rsp.Result.(*mount.NamespaceInfo).UserCurrent {
+			users = append(users, uid)
+		}
+		sort.Strings(users)
+		return users
+	}
+
+	// root sees the entries of all users
+	c.Check(getUsers("pid=100;uid=0;socket=;"), check.DeepEquals, []string{"1000", "1001"})
+	// other users only see their own
+	c.Check(getUsers("pid=100;uid=1000;socket=;"), check.DeepEquals, []string{"1000"})
+	c.Check(getUsers("pid=100;uid=1002;socket=;"), check.HasLen, 0)
+	// and without credentials nothing is shown
+	c.Check(getUsers(""), check.HasLen, 0)
+}
+
 func mockDurationThreshold() func() {
 	oldDurationThreshold := timings.DurationThreshold
 	restore := func() {
@@ -229,6 +398,27 @@ func (s *postDebugSuite) TestGetDebugTimingsSingleChange(c *check.C) {
 	tmData := dataJSON[0].(map[string]interface{})
 	c.Check(tmData["change-id"], check.DeepEquals, "1")
 	c.Check(tmData["change-timings"], check.NotNil)
+	// no handler was run
+	c.Check(tmData["doing-time"], check.IsNil)
+}
+
+func (s *postDebugSuite) TestGetDebugHandlerTimings(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	st := s.d.overlord.State()
+	st.Lock()
+	task := st.NewTask("foo", "...")
+	timings.RecordHandlerRun(task, state.DoingStatus, time.Second)
+	timings.RecordHandlerRun(task, state.DoingStatus, 2*time.Second)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=handler-timings", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getDebug(debugCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []*timings.HandlerTimings{
+		{Kind: "foo", Status: "Doing", Count: 2, Total: 3 * time.Second, Max: 2 * time.Second},
+	})
 }
 
 func (s *postDebugSuite) TestGetDebugTimingsEnsureLatest(c *check.C) {
@@ -0,0 +1,57 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+)
+
+func (s *apiSuite) TestGetFeatures(c *check.C) {
+	d := s.daemonWithOverlordMock(c)
+
+	st := d.overlord.State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "experimental.hotplug", true)
+	tr.Set("core", "experimental.layouts", false)
+	tr.Commit()
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/features", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getFeatures(featuresCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Assert(rsp.Status, check.Equals, 200)
+
+	infos, ok := rsp.Result.([]configstate.FeatureInfo)
+	c.Assert(ok, check.Equals, true)
+	byName := make(map[string]configstate.FeatureInfo, len(infos))
+	for _, info := range infos {
+		byName[info.Name] = info
+	}
+	c.Check(byName["hotplug"], check.DeepEquals, configstate.FeatureInfo{Name: "hotplug", Enabled: true})
+	c.Check(byName["layouts"], check.DeepEquals, configstate.FeatureInfo{Name: "layouts", EnabledWhenUnset: true})
+	c.Check(byName["parallel-instances"], check.DeepEquals, configstate.FeatureInfo{Name: "parallel-instances", Exported: true})
+}
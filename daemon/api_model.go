@@ -35,6 +35,11 @@ var (
 		GET:    getSerial,
 		UserOK: true,
 	}
+	registrationModelCmd = &Command{
+		Path:   "/v2/model/registration",
+		GET:    getRegistration,
+		UserOK: true,
+	}
 	modelCmd = &Command{
 		Path:   "/v2/model",
 		POST:   postModel,
@@ -180,3 +185,18 @@ func getSerial(c *Command, r *http.Request, _ *auth.UserState) Response {
 
 	return AssertResponse([]asserts.Assertion{serial}, true)
 }
+
+func getRegistration(c *Command, r *http.Request, _ *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	devmgr := c.d.overlord.DeviceManager()
+
+	status, err := devmgr.RegistrationStatus()
+	if err != nil {
+		return InternalError("cannot get registration status: %v", err)
+	}
+
+	return SyncResponse(status, nil)
+}
@@ -360,3 +360,28 @@ func (s *apiSuite) TestGetModelJSONHasSerialAssertion(c *check.C) {
 	c.Assert(devKey, check.FitsTypeOf, "")
 	c.Assert(devKey.(string), check.Equals, string(encDevKey))
 }
+
+func (s *apiSuite) TestGetModelRegistration(c *check.C) {
+	d := s.daemonWithOverlordMock(c)
+	hookMgr, err := hookstate.Manager(d.overlord.State(), d.overlord.TaskRunner())
+	c.Assert(err, check.IsNil)
+	deviceMgr, err := devicestate.Manager(d.overlord.State(), hookMgr, d.overlord.TaskRunner(), nil)
+	c.Assert(err, check.IsNil)
+	d.overlord.AddManager(deviceMgr)
+	st := d.overlord.State()
+	st.Lock()
+	devicestatetest.SetDevice(st, &auth.DeviceState{
+		Brand:  "my-brand",
+		Model:  "my-old-model",
+		Serial: "serialserial",
+	})
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/model/registration", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getRegistration(registrationModelCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, &devicestate.RegistrationStatus{
+		Serial: "serialserial",
+	})
+}
@@ -0,0 +1,222 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/snapcore/snapd/interfaces/prompting"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	promptsCmd = &Command{
+		Path:   "/v2/interfaces/requests/prompts",
+		UserOK: true,
+		GET:    getPrompts,
+	}
+	promptCmd = &Command{
+		Path:     "/v2/interfaces/requests/prompts/{id}",
+		UserOK:   true,
+		PolkitOK: "io.snapcraft.snapd.manage-interfaces",
+		GET:      getPrompt,
+		POST:     postPrompt,
+	}
+	// promptDenialsCmd is used by the apparmor denial listener to submit
+	// the denials of snaps that can be prompted for, it blocks until the
+	// user replied.
+	promptDenialsCmd = &Command{
+		Path:     "/v2/interfaces/requests/denials",
+		RootOnly: true,
+		POST:     postPromptDenial,
+	}
+)
+
+func init() {
+	ifacestate.AddPromptReplyHook(deliverPromptReply)
+}
+
+var (
+	promptWaitersMu sync.Mutex
+	// promptWaiters are the channels the replies to the pending prompt
+	// requests submitted through promptDenialsCmd are delivered on,
+	// keyed by request ID.
+	promptWaiters = make(map[string]chan prompting.Outcome)
+)
+
+func waitPromptReply(id string) <-chan prompting.Outcome {
+	promptWaitersMu.Lock()
+	defer promptWaitersMu.Unlock()
+	ch := make(chan prompting.Outcome, 1)
+	promptWaiters[id] = ch
+	return ch
+}
+
+func stopWaitingPromptReply(id string) {
+	promptWaitersMu.Lock()
+	defer promptWaitersMu.Unlock()
+	delete(promptWaiters, id)
+}
+
+func deliverPromptReply(st *state.State, req *prompting.Request, outcome prompting.Outcome) {
+	promptWaitersMu.Lock()
+	defer promptWaitersMu.Unlock()
+	if ch := promptWaiters[req.ID]; ch != nil {
+		ch <- outcome
+		delete(promptWaiters, req.ID)
+	}
+}
+
+// promptUser returns the uid of the user making the request, whose
+// prompts are the ones visible.
+func promptUser(r *http.Request) (uint32, Response) {
+	_, uid, _, err := ucrednetGet(r.RemoteAddr)
+	if err != nil {
+		return 0, Forbidden("cannot get remote user: %v", err)
+	}
+	return uid, nil
+}
+
+func getPrompts(c *Command, r *http.Request, user *auth.UserState) Response {
+	uid, rsp := promptUser(r)
+	if rsp != nil {
+		return rsp
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	reqs, err := ifacestate.PromptRequests(st, uid)
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+	return SyncResponse(reqs, nil)
+}
+
+func getPrompt(c *Command, r *http.Request, user *auth.UserState) Response {
+	uid, rsp := promptUser(r)
+	if rsp != nil {
+		return rsp
+	}
+	id := muxVars(r)["id"]
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	req, err := ifacestate.PromptRequest(st, uid, id)
+	if err == ifacestate.ErrNoPromptRequest {
+		return NotFound("cannot find prompt request %q", id)
+	}
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+	return SyncResponse(req, nil)
+}
+
+type promptReply struct {
+	Action   prompting.Outcome  `json:"action"`
+	Lifespan prompting.Lifespan `json:"lifespan"`
+}
+
+func postPrompt(c *Command, r *http.Request, user *auth.UserState) Response {
+	uid, rsp := promptUser(r)
+	if rsp != nil {
+		return rsp
+	}
+	id := muxVars(r)["id"]
+
+	var reply promptReply
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&reply); err != nil {
+		return BadRequest("cannot decode request body into prompt reply: %v", err)
+	}
+	if reply.Lifespan == "" {
+		reply.Lifespan = prompting.LifespanSingle
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	err := ifacestate.ReplyToPrompt(st, uid, id, reply.Action, reply.Lifespan)
+	if err == ifacestate.ErrNoPromptRequest {
+		return NotFound("cannot find prompt request %q", id)
+	}
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+	return SyncResponse(nil, nil)
+}
+
+type promptDenialResult struct {
+	Outcome prompting.Outcome `json:"outcome"`
+}
+
+func postPromptDenial(c *Command, r *http.Request, user *auth.UserState) Response {
+	var req prompting.Request
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&req); err != nil {
+		return BadRequest("cannot decode request body into prompt request: %v", err)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	outcome, decided, err := ifacestate.AddPromptRequest(st, &req)
+	if err != nil {
+		st.Unlock()
+		return BadRequest("%v", err)
+	}
+	if decided {
+		st.Unlock()
+		return SyncResponse(&promptDenialResult{Outcome: outcome}, nil)
+	}
+	// start waiting before unlocking so that the reply cannot be missed
+	reply := waitPromptReply(req.ID)
+	st.Unlock()
+	defer stopWaitingPromptReply(req.ID)
+
+	select {
+	case outcome := <-reply:
+		return SyncResponse(&promptDenialResult{Outcome: outcome}, nil)
+	case <-r.Context().Done():
+		removePromptRequest(st, req.ID)
+		return BadRequest("cannot wait for reply to prompt request %s: %v", req.ID, r.Context().Err())
+	case <-c.d.Dying():
+		removePromptRequest(st, req.ID)
+		return InternalError("cannot wait for reply to prompt request %s: snapd is stopping", req.ID)
+	}
+}
+
+// removePromptRequest drops a prompt request nobody waits for the reply
+// of anymore, so that it is not offered to the user.
+func removePromptRequest(st *state.State, id string) {
+	st.Lock()
+	defer st.Unlock()
+	if err := ifacestate.RemovePromptRequest(st, id); err != nil {
+		logger.Noticef("cannot remove prompt request %s: %v", id, err)
+	}
+}
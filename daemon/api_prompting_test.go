@@ -0,0 +1,244 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/prompting"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+)
+
+func (s *apiSuite) enablePrompting() {
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "experimental.apparmor-prompting", true)
+	tr.Commit()
+}
+
+func (s *apiSuite) mockPromptRequest(c *check.C, uid uint32) *prompting.Request {
+	s.enablePrompting()
+
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	req := &prompting.Request{
+		UID:         uid,
+		Snap:        "foo",
+		Interface:   "home",
+		Path:        "/home/user/doc.txt",
+		Permissions: []string{"read"},
+	}
+	_, _, err := ifacestate.AddPromptRequest(st, req)
+	c.Assert(err, check.IsNil)
+	return req
+}
+
+func (s *apiSuite) TestGetPrompts(c *check.C) {
+	s.daemonWithOverlordMock(c)
+	mine := s.mockPromptRequest(c, 1000)
+	s.mockPromptRequest(c, 1001)
+
+	req, err := http.NewRequest("GET", "/v2/interfaces/requests/prompts", nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
+	rsp := getPrompts(promptsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	reqs := rsp.Result.([]*prompting.Request)
+	c.Assert(reqs, check.HasLen, 1)
+	c.Check(reqs[0].ID, check.Equals, mine.ID)
+	c.Check(reqs[0].UID, check.Equals, uint32(1000))
+
+	s.vars = map[string]string{"id": mine.ID}
+	rsp = getPrompt(promptCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result.(*prompting.Request).ID, check.Equals, mine.ID)
+
+	// prompts of other users are not visible
+	s.vars = map[string]string{"id": "2"}
+	rsp = getPrompt(promptCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 404)
+}
+
+func (s *apiSuite) TestGetPromptsDisabled(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	req, err := http.NewRequest("GET", "/v2/interfaces/requests/prompts", nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
+	rsp := getPrompts(promptsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Matches, `experimental feature disabled - .*`)
+}
+
+func (s *apiSuite) TestGetPromptsNoUser(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	req, err := http.NewRequest("GET", "/v2/interfaces/requests/prompts", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getPrompts(promptsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 403)
+}
+
+func (s *apiSuite) TestPostPrompt(c *check.C) {
+	s.daemonWithOverlordMock(c)
+	mine := s.mockPromptRequest(c, 1000)
+
+	s.vars = map[string]string{"id": mine.ID}
+	req, err := http.NewRequest("POST", "/v2/interfaces/requests/prompts/"+mine.ID, bytes.NewBufferString(`{"action": "maybe"}`))
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
+	rsp := postPrompt(promptCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `invalid prompt outcome "maybe"`)
+
+	req, err = http.NewRequest("POST", "/v2/interfaces/requests/prompts/"+mine.ID, bytes.NewBufferString(`{"action": "allow"}`))
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
+	rsp = postPrompt(promptCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+
+	// it is gone now
+	req, err = http.NewRequest("POST", "/v2/interfaces/requests/prompts/"+mine.ID, bytes.NewBufferString(`{"action": "allow"}`))
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
+	rsp = postPrompt(promptCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 404)
+}
+
+const promptDenial = `{"uid": 1000, "snap": "foo", "interface": "home", "path": "/home/user/doc.txt", "permissions": ["read"]}`
+
+func (s *apiSuite) TestPostPromptDenial(c *check.C) {
+	s.daemonWithOverlordMock(c)
+	s.enablePrompting()
+
+	rspCh := make(chan Response, 1)
+	go func() {
+		req, err := http.NewRequest("POST", "/v2/interfaces/requests/denials", bytes.NewBufferString(promptDenial))
+		c.Assert(err, check.IsNil)
+		rspCh <- postPromptDenial(promptDenialsCmd, req, nil)
+	}()
+
+	// wait for the prompt to show up for the user
+	st := s.d.overlord.State()
+	var reqs []*prompting.Request
+	for i := 0; i < 500; i++ {
+		st.Lock()
+		var err error
+		reqs, err = ifacestate.PromptRequests(st, 1000)
+		st.Unlock()
+		c.Assert(err, check.IsNil)
+		if len(reqs) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Assert(reqs, check.HasLen, 1)
+	c.Check(reqs[0].Snap, check.Equals, "foo")
+
+	s.vars = map[string]string{"id": reqs[0].ID}
+	req, err := http.NewRequest("POST", "/v2/interfaces/requests/prompts/"+reqs[0].ID, bytes.NewBufferString(`{"action": "allow", "lifespan": "forever"}`))
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
+	rsp := postPrompt(promptCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+
+	select {
+	case r := <-rspCh:
+		rsp = r.(*resp)
+	case <-time.After(5 * time.Second):
+		c.Fatal("denial was not replied to")
+	}
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, &promptDenialResult{Outcome: prompting.OutcomeAllow})
+
+	// the same denial is now decided by the recorded rule
+	req, err = http.NewRequest("POST", "/v2/interfaces/requests/denials", bytes.NewBufferString(promptDenial))
+	c.Assert(err, check.IsNil)
+	rsp = postPromptDenial(promptDenialsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, &promptDenialResult{Outcome: prompting.OutcomeAllow})
+}
+
+func (s *apiSuite) TestPostPromptDenialCancelled(c *check.C) {
+	s.daemonWithOverlordMock(c)
+	s.enablePrompting()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := http.NewRequest("POST", "/v2/interfaces/requests/denials", bytes.NewBufferString(promptDenial))
+	c.Assert(err, check.IsNil)
+	rsp := postPromptDenial(promptDenialsCmd, req.WithContext(ctx), nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot wait for reply to prompt request 1: context canceled`)
+
+	// nobody waits for the reply anymore, the prompt is dropped
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	reqs, err := ifacestate.PromptRequests(st, 1000)
+	c.Assert(err, check.IsNil)
+	c.Check(reqs, check.HasLen, 0)
+}
+
+func (s *apiSuite) TestPostPromptDenialStopping(c *check.C) {
+	s.daemonWithOverlordMock(c)
+	s.enablePrompting()
+
+	s.d.tomb.Kill(nil)
+	req, err := http.NewRequest("POST", "/v2/interfaces/requests/denials", bytes.NewBufferString(promptDenial))
+	c.Assert(err, check.IsNil)
+	rsp := postPromptDenial(promptDenialsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 500)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot wait for reply to prompt request 1: snapd is stopping`)
+
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	reqs, err := ifacestate.PromptRequests(st, 1000)
+	c.Assert(err, check.IsNil)
+	c.Check(reqs, check.HasLen, 0)
+}
+
+func (s *apiSuite) TestPostPromptDenialInvalid(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	req, err := http.NewRequest("POST", "/v2/interfaces/requests/denials", bytes.NewBufferString(promptDenial))
+	c.Assert(err, check.IsNil)
+	rsp := postPromptDenial(promptDenialsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Matches, `experimental feature disabled - .*`)
+
+	s.enablePrompting()
+	req, err = http.NewRequest("POST", "/v2/interfaces/requests/denials", bytes.NewBufferString(`{"uid": 1000, "snap": "foo", "interface": "network", "permissions": ["connect"]}`))
+	c.Assert(err, check.IsNil)
+	rsp = postPromptDenial(promptDenialsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot prompt for interface "network"`)
+}
@@ -57,6 +57,20 @@ func listSnapshots(c *Command, r *http.Request, user *auth.UserState) Response {
 	if err != nil {
 		return InternalError("%v", err)
 	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	expirations, err := snapshotExpirations(st)
+	st.Unlock()
+	if err != nil {
+		return InternalError("cannot get snapshot expirations: %v", err)
+	}
+	for i := range sets {
+		if expiry, ok := expirations[sets[i].ID]; ok && !expiry.IsZero() {
+			sets[i].Expiry = &expiry
+		}
+	}
+
 	return SyncResponse(sets, nil)
 }
 
@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -97,6 +98,27 @@ func (s *snapshotSuite) TestListSnapshots(c *check.C) {
 	c.Check(rsp.Result, check.DeepEquals, snapshots)
 }
 
+func (s *snapshotSuite) TestListSnapshotsExpiry(c *check.C) {
+	defer daemon.MockSnapshotList(func(context.Context, uint64, []string) ([]client.SnapshotSet, error) {
+		return []client.SnapshotSet{{ID: 1}, {ID: 42}}, nil
+	})()
+
+	expiry := time.Date(2019, 9, 1, 10, 0, 0, 0, time.UTC)
+	st := s.o.State()
+	st.Lock()
+	st.Set("snapshots", map[uint64]interface{}{
+		42: map[string]interface{}{"expiry-time": expiry},
+	})
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/snapshots", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := daemon.ListSnapshots(daemon.SnapshotCmd, req, nil)
+	c.Check(rsp.Type, check.Equals, daemon.ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []client.SnapshotSet{{ID: 1}, {ID: 42, Expiry: &expiry}})
+}
+
 func (s *snapshotSuite) TestListSnapshotsFiltering(c *check.C) {
 	snapshots := []client.SnapshotSet{{ID: 1}, {ID: 42}}
 
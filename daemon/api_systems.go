@@ -0,0 +1,93 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var systemsCmd = &Command{
+	Path:   "/v2/systems",
+	GET:    getSystems,
+	POST:   postSystems,
+	UserOK: true,
+}
+
+var devicestateFactoryReset = devicestate.FactoryReset
+
+type systemsResponse struct {
+	FactoryReset *devicestate.FactoryResetState `json:"factory-reset,omitempty"`
+}
+
+func getSystems(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var rsp systemsResponse
+	reset, err := devicestate.FactoryResetStatus(st)
+	if err != nil && err != state.ErrNoState {
+		return InternalError("cannot get factory reset details: %v", err)
+	}
+	if err == nil {
+		rsp.FactoryReset = reset
+	}
+
+	return SyncResponse(&rsp, nil)
+}
+
+type postSystemsData struct {
+	Action string `json:"action"`
+}
+
+func postSystems(c *Command, r *http.Request, user *auth.UserState) Response {
+	defer r.Body.Close()
+	var data postSystemsData
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&data); err != nil {
+		return BadRequest("cannot decode request body into system action: %v", err)
+	}
+
+	switch data.Action {
+	case "factory-reset":
+		return postSystemsFactoryReset(c)
+	default:
+		return BadRequest("unsupported system action %q", data.Action)
+	}
+}
+
+func postSystemsFactoryReset(c *Command) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	chg, err := devicestateFactoryReset(st)
+	if err != nil {
+		return BadRequest("cannot reset device: %v", err)
+	}
+	ensureStateSoon(st)
+
+	return AsyncResponse(nil, &Meta{Change: chg.ID()})
+}
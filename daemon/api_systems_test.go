@@ -0,0 +1,132 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func (s *apiSuite) TestGetSystemsNoFactoryReset(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	req, err := http.NewRequest("GET", "/v2/systems", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getSystems(systemsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, &systemsResponse{})
+}
+
+func (s *apiSuite) TestGetSystemsFactoryReset(c *check.C) {
+	d := s.daemonWithOverlordMock(c)
+
+	requestTime := time.Date(2019, 11, 1, 10, 0, 0, 0, time.UTC)
+	doneTime := requestTime.Add(10 * time.Minute)
+	st := d.overlord.State()
+	st.Lock()
+	st.Set("factory-reset", &devicestate.FactoryResetState{
+		RequestTime: requestTime,
+		DoneTime:    &doneTime,
+	})
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/systems", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getSystems(systemsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, &systemsResponse{
+		FactoryReset: &devicestate.FactoryResetState{
+			RequestTime: requestTime,
+			DoneTime:    &doneTime,
+		},
+	})
+}
+
+func (s *apiSuite) TestPostSystemsFactoryReset(c *check.C) {
+	d := s.daemonWithOverlordMock(c)
+	st := d.overlord.State()
+
+	soon := 0
+	ensureStateSoon = func(st *state.State) {
+		soon++
+		ensureStateSoonImpl(st)
+	}
+
+	devicestateFactoryReset = func(st *state.State) (*state.Change, error) {
+		chg := st.NewChange("factory-reset", "...")
+		return chg, nil
+	}
+
+	req, err := http.NewRequest("POST", "/v2/systems", bytes.NewBufferString(`{"action": "factory-reset"}`))
+	c.Assert(err, check.IsNil)
+	rsp := postSystems(systemsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 202)
+
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "factory-reset")
+
+	c.Check(soon, check.Equals, 1)
+}
+
+func (s *apiSuite) TestPostSystemsFactoryResetError(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	devicestateFactoryReset = func(st *state.State) (*state.Change, error) {
+		return nil, errors.New("boom")
+	}
+
+	req, err := http.NewRequest("POST", "/v2/systems", bytes.NewBufferString(`{"action": "factory-reset"}`))
+	c.Assert(err, check.IsNil)
+	rsp := postSystems(systemsCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Assert(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "cannot reset device: boom")
+}
+
+func (s *apiSuite) TestPostSystemsUnhappy(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	for _, t := range []struct {
+		body   string
+		errMsg string
+	}{
+		{`{"action": "reboot"}`, `unsupported system action "reboot"`},
+		{`{"action": ""}`, `unsupported system action ""`},
+		{`garbage`, `cannot decode request body into system action: .*`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/systems", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+		rsp := postSystems(systemsCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, t.errMsg)
+	}
+}
@@ -228,7 +228,8 @@ func (s *apiBaseSuite) TearDownSuite(c *check.C) {
 func (s *apiBaseSuite) systemctl(args ...string) (buf []byte, err error) {
 	s.sysctlArgses = append(s.sysctlArgses, args)
 
-	if args[0] != "show" && args[0] != "start" && args[0] != "stop" && args[0] != "restart" {
+	userGlobalIsEnabled := len(args) == 6 && args[0] == "--user" && args[1] == "--global" && args[4] == "is-enabled"
+	if args[0] != "show" && args[0] != "start" && args[0] != "stop" && args[0] != "restart" && !userGlobalIsEnabled {
 		panic(fmt.Sprintf("unexpected systemctl call: %v", args))
 	}
 
@@ -306,8 +307,11 @@ func (s *apiBaseSuite) SetUpTest(c *check.C) {
 	snapstateUpdate = nil
 	snapstateUpdateMany = nil
 	snapstateSwitch = nil
+	snapstateHoldRefreshes = nil
+	snapstateUnholdRefreshes = nil
 
 	devicestateRemodel = nil
+	devicestateFactoryReset = nil
 }
 
 func (s *apiBaseSuite) TearDownTest(c *check.C) {
@@ -331,6 +335,11 @@ func (s *apiBaseSuite) TearDownTest(c *check.C) {
 	snapstateUpdate = snapstate.Update
 	snapstateUpdateMany = snapstate.UpdateMany
 	snapstateSwitch = snapstate.Switch
+	snapstateHoldRefreshes = snapstate.HoldRefreshes
+	snapstateUnholdRefreshes = snapstate.UnholdRefreshes
+	ifacestateConnectInstallingProvider = ifacestate.ConnectInstallingProvider
+
+	devicestateFactoryReset = devicestate.FactoryReset
 }
 
 var modelDefaults = map[string]interface{}{
@@ -980,6 +989,22 @@ func (s *apiSuite) TestMapLocalOfTryResolvesSymlink(c *check.C) {
 	c.Check(mapLocal(about).MountedFrom, check.Equals, "")
 }
 
+func (s *apiSuite) TestMapLocalRefreshHold(c *check.C) {
+	info := snap.Info{SideInfo: snap.SideInfo{RealName: "hello", Revision: snap.R(1)}}
+	snapst := snapstate.SnapState{}
+	about := aboutSnap{info: &info, snapst: &snapst}
+
+	c.Check(mapLocal(about).RefreshHold, check.IsNil)
+
+	held := time.Date(2020, 4, 1, 10, 0, 0, 0, time.UTC)
+	snapst.RefreshHold = &snapstate.RefreshHold{Time: held}
+	c.Check(mapLocal(about).RefreshHold, check.DeepEquals, &client.SnapRefreshHold{Time: held})
+
+	until := held.Add(24 * time.Hour)
+	snapst.RefreshHold.Until = until
+	c.Check(mapLocal(about).RefreshHold, check.DeepEquals, &client.SnapRefreshHold{Time: held, Until: &until})
+}
+
 func (s *apiSuite) TestListIncludesAll(c *check.C) {
 	// Very basic check to help stop us from not adding all the
 	// commands to the command list.
@@ -3064,6 +3089,32 @@ func (s *apiSuite) TestSideloadSnapInstanceNameMismatch(c *check.C) {
 	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `instance name "foo_instance" does not match snap name "bar"`)
 }
 
+func (s *apiSuite) TestUnsafeReadSnapInfoValidatesGadget(c *check.C) {
+	restore := release.MockOnClassic(true)
+	defer restore()
+
+	snapDir := c.MkDir()
+	snaptest.PopulateDir(snapDir, [][]string{
+		{"meta/snap.yaml", "name: pc\nversion: 1.0\ntype: gadget\n"},
+		{"meta/gadget.yaml", "defaults:\n  not-a-snap-id:\n    foo: bar\n"},
+	})
+
+	_, err := unsafeReadSnapInfoImpl(snapDir)
+	c.Check(err, check.ErrorMatches, `invalid gadget snap: default stanza not keyed by "system" or snap-id: not-a-snap-id`)
+
+	snaptest.PopulateDir(snapDir, [][]string{
+		{"meta/gadget.yaml", "defaults:\n  system:\n    foo: bar\n"},
+	})
+	info, err := unsafeReadSnapInfoImpl(snapDir)
+	c.Assert(err, check.IsNil)
+	c.Check(info.SnapName(), check.Equals, "pc")
+
+	// gadgets on core need volumes
+	release.MockOnClassic(false)
+	_, err = unsafeReadSnapInfoImpl(snapDir)
+	c.Check(err, check.ErrorMatches, `invalid gadget snap: bootloader not declared in any volume`)
+}
+
 func (s *apiSuite) TestTrySnap(c *check.C) {
 	d := s.daemonWithFakeSnapManager(c)
 
@@ -4103,6 +4154,205 @@ func (s *apiSuite) TestRefreshMany1(c *check.C) {
 	c.Check(refreshSnapDecls, check.Equals, true)
 }
 
+func (s *apiSuite) mockDiskSpace(dataSize uint64, free map[string]uint64, sameFilesystem bool) (restore func()) {
+	oldCurrentDataSize, oldFreeDiskSpace := currentDataSize, freeDiskSpace
+	currentDataSize = func(instanceName string) (uint64, error) {
+		return dataSize, nil
+	}
+	freeDiskSpace = func(path string) (uint64, uint64, error) {
+		available, ok := free[path]
+		if !ok {
+			return 0, 0, fmt.Errorf("unexpected path %q", path)
+		}
+		if sameFilesystem {
+			return 1, available, nil
+		}
+		return uint64(len(path)), available, nil
+	}
+	return func() {
+		currentDataSize, freeDiskSpace = oldCurrentDataSize, oldFreeDiskSpace
+	}
+}
+
+func fakeSnapSetupTasks(s *state.State, names []string, size int64) []*state.TaskSet {
+	tasksets := make([]*state.TaskSet, 0, len(names))
+	for _, name := range names {
+		t := s.NewTask("fake-download", "Downloading "+name)
+		t.Set("snap-setup", &snapstate.SnapSetup{
+			SideInfo:     &snap.SideInfo{RealName: name},
+			DownloadInfo: &snap.DownloadInfo{Size: size},
+		})
+		tasksets = append(tasksets, state.NewTaskSet(t))
+	}
+	return tasksets
+}
+
+func (s *apiSuite) TestRefreshManyChecksDiskSpace(c *check.C) {
+	assertstateRefreshSnapDeclarations = func(s *state.State, userID int) error {
+		return nil
+	}
+	snapstateUpdateMany = func(_ context.Context, s *state.State, names []string, userID int, flags *snapstate.Flags) ([]string, []*state.TaskSet, error) {
+		return names, fakeSnapSetupTasks(s, names, 1000*1000), nil
+	}
+
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(1), true, "")
+	s.mkInstalledInState(c, d, "baz", "bar", "v1", snap.R(1), true, "")
+
+	// the data of not installed snaps is not accounted for
+	inst := &snapInstruction{Action: "refresh", Snaps: []string{"foo", "baz", "not-installed"}}
+	st := d.overlord.State()
+
+	restore := s.mockDiskSpace(500*1000, map[string]uint64{
+		dirs.SnapBlobDir: 3000 * 1000,
+		dirs.SnapDataDir: 1000 * 1000,
+	}, false)
+	st.Lock()
+	_, err := snapUpdateMany(inst, st)
+	st.Unlock()
+	restore()
+	c.Assert(err, check.IsNil)
+
+	restore = s.mockDiskSpace(500*1000, map[string]uint64{
+		dirs.SnapBlobDir: 2500 * 1000,
+		dirs.SnapDataDir: 1000 * 1000,
+	}, false)
+	st.Lock()
+	_, err = snapUpdateMany(inst, st)
+	st.Unlock()
+	restore()
+	c.Assert(err, check.ErrorMatches, `cannot refresh "foo", "baz", "not-installed": insufficient disk space in .*/var/lib/snapd/snaps, need 3MB but only 2MB available`)
+
+	rsp := inst.errToResponse(err).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Kind, check.Equals, errorKindInsufficientDiskSpace)
+
+	restore = s.mockDiskSpace(600*1000, map[string]uint64{
+		dirs.SnapBlobDir: 3000 * 1000,
+		dirs.SnapDataDir: 1000 * 1000,
+	}, false)
+	st.Lock()
+	_, err = snapUpdateMany(inst, st)
+	st.Unlock()
+	restore()
+	c.Assert(err, check.ErrorMatches, `cannot refresh "foo", "baz", "not-installed": insufficient disk space in .*/var/snap, need 1MB but only 1MB available`)
+}
+
+func (s *apiSuite) TestRefreshManyChecksDiskSpaceSameFilesystem(c *check.C) {
+	assertstateRefreshSnapDeclarations = func(s *state.State, userID int) error {
+		return nil
+	}
+	snapstateUpdateMany = func(_ context.Context, s *state.State, names []string, userID int, flags *snapstate.Flags) ([]string, []*state.TaskSet, error) {
+		return names, fakeSnapSetupTasks(s, names, 1000*1000), nil
+	}
+
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(1), true, "")
+
+	inst := &snapInstruction{Action: "refresh", Snaps: []string{"foo"}}
+	st := d.overlord.State()
+
+	// download and data copy add up on the same filesystem
+	defer s.mockDiskSpace(1000*1000, map[string]uint64{
+		dirs.SnapBlobDir: 1500 * 1000,
+		dirs.SnapDataDir: 1500 * 1000,
+	}, true)()
+	st.Lock()
+	_, err := snapUpdateMany(inst, st)
+	st.Unlock()
+	c.Assert(err, check.ErrorMatches, `cannot refresh "foo": insufficient disk space in .*/var/snap, need 2MB but only 1MB available`)
+}
+
+func (s *apiSuite) TestInstallManyChecksDiskSpace(c *check.C) {
+	snapstateInstallMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+		return names, fakeSnapSetupTasks(s, names, 1000*1000), nil
+	}
+
+	d := s.daemon(c)
+	inst := &snapInstruction{Action: "install", Snaps: []string{"foo", "bar"}}
+	st := d.overlord.State()
+
+	// there is no data to copy for new snaps
+	restore := s.mockDiskSpace(500*1000, map[string]uint64{
+		dirs.SnapBlobDir: 2000 * 1000,
+	}, false)
+	st.Lock()
+	_, err := snapInstallMany(inst, st)
+	st.Unlock()
+	restore()
+	c.Assert(err, check.IsNil)
+
+	restore = s.mockDiskSpace(0, map[string]uint64{
+		dirs.SnapBlobDir: 1500 * 1000,
+	}, false)
+	st.Lock()
+	_, err = snapInstallMany(inst, st)
+	st.Unlock()
+	restore()
+	c.Assert(err, check.ErrorMatches, `cannot install "foo", "bar": insufficient disk space in .*/var/lib/snapd/snaps, need 2MB but only 1MB available`)
+}
+
+func (s *apiSuite) TestRefreshManyChecksDiskSpaceLocalSnaps(c *check.C) {
+	snapDirs := make(map[string]string)
+	for _, name := range []string{"foo", "baz"} {
+		snapDirs[name] = filepath.Join(c.MkDir(), name)
+		c.Assert(os.MkdirAll(filepath.Join(snapDirs[name], "meta"), 0755), check.IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(snapDirs[name], "meta", "snap.yaml"), []byte("name: "+name+"\nversion: 1\n"), 0644), check.IsNil)
+	}
+	assertstateRefreshSnapDeclarations = func(s *state.State, userID int) error {
+		return nil
+	}
+	snapstateUpdateMany = func(_ context.Context, s *state.State, names []string, userID int, flags *snapstate.Flags) ([]string, []*state.TaskSet, error) {
+		var tasksets []*state.TaskSet
+		for _, name := range names {
+			t := s.NewTask("fake-install", "Installing "+name)
+			t.Set("snap-setup", &snapstate.SnapSetup{
+				SideInfo: &snap.SideInfo{RealName: name},
+				SnapPath: snapDirs[name],
+			})
+			tasksets = append(tasksets, state.NewTaskSet(t))
+		}
+		return names, tasksets, nil
+	}
+	var sized []string
+	oldInstallSize := installSize
+	installSize = func(info *snap.Info, container snap.Container) (uint64, error) {
+		_, err := container.ReadFile("meta/snap.yaml")
+		c.Assert(err, check.IsNil)
+		sized = append(sized, info.InstanceName())
+		// includes the current data of foo
+		return 1500 * 1000, nil
+	}
+	defer func() { installSize = oldInstallSize }()
+
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(1), true, "")
+
+	inst := &snapInstruction{Action: "refresh", Snaps: []string{"foo", "baz"}}
+	st := d.overlord.State()
+
+	restore := s.mockDiskSpace(500*1000, map[string]uint64{
+		dirs.SnapBlobDir: 2500 * 1000,
+		dirs.SnapDataDir: 500 * 1000,
+	}, false)
+	st.Lock()
+	_, err := snapUpdateMany(inst, st)
+	st.Unlock()
+	restore()
+	c.Assert(err, check.IsNil)
+	c.Check(sized, check.DeepEquals, []string{"foo", "baz"})
+
+	restore = s.mockDiskSpace(500*1000, map[string]uint64{
+		dirs.SnapBlobDir: 2400 * 1000,
+		dirs.SnapDataDir: 500 * 1000,
+	}, false)
+	st.Lock()
+	_, err = snapUpdateMany(inst, st)
+	st.Unlock()
+	restore()
+	c.Assert(err, check.ErrorMatches, `cannot refresh "foo", "baz": insufficient disk space in .*/var/lib/snapd/snaps, need 2MB but only 2MB available`)
+}
+
 func (s *apiSuite) TestInstallMany(c *check.C) {
 	snapstateInstallMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 2)
@@ -4153,6 +4403,127 @@ func (s *apiSuite) TestRemoveMany(c *check.C) {
 	c.Check(res.Affected, check.DeepEquals, inst.Snaps)
 }
 
+func (s *apiSuite) TestHoldMany(c *check.C) {
+	var gotUntil time.Time
+	snapstateHoldRefreshes = func(s *state.State, names []string, until time.Time) error {
+		c.Check(names, check.DeepEquals, []string{"foo", "bar"})
+		gotUntil = until
+		return nil
+	}
+
+	d := s.daemon(c)
+	st := d.overlord.State()
+
+	inst := &snapInstruction{Action: "hold", Snaps: []string{"foo", "bar"}, Time: "forever"}
+	st.Lock()
+	res, err := snapHoldMany(inst, st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(res.Summary, check.Equals, `Hold auto-refreshes of snaps "foo", "bar"`)
+	c.Check(res.Affected, check.DeepEquals, inst.Snaps)
+	c.Check(res.Tasksets, check.HasLen, 0)
+	c.Check(gotUntil.IsZero(), check.Equals, true)
+
+	inst.Time = "2040-01-02T10:00:00Z"
+	st.Lock()
+	res, err = snapHoldMany(inst, st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(res.Summary, check.Equals, `Hold auto-refreshes of snaps "foo", "bar" until 2040-01-02T10:00:00Z`)
+	c.Check(gotUntil.Equal(time.Date(2040, 1, 2, 10, 0, 0, 0, time.UTC)), check.Equals, true)
+
+	inst.Time = "48h"
+	before := time.Now()
+	st.Lock()
+	_, err = snapHoldMany(inst, st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(gotUntil.Before(before.Add(48*time.Hour)), check.Equals, false)
+	c.Check(gotUntil.After(time.Now().Add(48*time.Hour)), check.Equals, false)
+}
+
+func (s *apiSuite) TestHoldManyErrors(c *check.C) {
+	snapstateHoldRefreshes = func(s *state.State, names []string, until time.Time) error {
+		c.Fatalf("unexpected call")
+		return nil
+	}
+
+	d := s.daemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	for _, t := range []struct {
+		snaps []string
+		time  string
+		err   string
+	}{
+		{nil, "forever", "cannot hold auto-refreshes without snap names"},
+		{[]string{"foo"}, "", "hold requires a time"},
+		{[]string{"foo"}, "tomorrow", `cannot parse hold time "tomorrow": expected a RFC3339 timestamp, a positive duration or "forever"`},
+		{[]string{"foo"}, "-2h", `cannot parse hold time "-2h": .*`},
+	} {
+		inst := &snapInstruction{Action: "hold", Snaps: t.snaps, Time: t.time}
+		_, err := snapHoldMany(inst, st)
+		c.Check(err, check.ErrorMatches, t.err)
+	}
+}
+
+func (s *apiSuite) TestUnholdMany(c *check.C) {
+	snapstateUnholdRefreshes = func(s *state.State, names []string) error {
+		c.Check(names, check.DeepEquals, []string{"foo"})
+		return nil
+	}
+
+	d := s.daemon(c)
+	st := d.overlord.State()
+	inst := &snapInstruction{Action: "unhold", Snaps: []string{"foo"}}
+	st.Lock()
+	res, err := snapUnholdMany(inst, st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(res.Summary, check.Equals, `Remove hold on auto-refreshes of snaps "foo"`)
+	c.Check(res.Affected, check.DeepEquals, inst.Snaps)
+}
+
+func (s *apiSuite) TestPostSnapsOpHold(c *check.C) {
+	snapstateHoldRefreshes = func(s *state.State, names []string, until time.Time) error {
+		c.Check(names, check.DeepEquals, []string{"foo"})
+		return nil
+	}
+
+	d := s.daemonWithOverlordMock(c)
+
+	buf := bytes.NewBufferString(`{"action": "hold", "snaps": ["foo"], "time": "forever"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, ok := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Assert(ok, check.Equals, true)
+	c.Check(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Check(chg.Kind(), check.Equals, "hold-snap")
+	c.Check(chg.Status(), check.Equals, state.DoneStatus)
+}
+
+func (s *apiSuite) TestPostSnapsOpTimeOnlyForHold(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	buf := bytes.NewBufferString(`{"action": "refresh", "time": "forever"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "time can only be specified for hold")
+}
+
 func (s *apiSuite) TestInstallFails(c *check.C) {
 	snapstateInstall = func(ctx context.Context, s *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
 		t := s.NewTask("fake-install-snap-error", "Install task")
@@ -4704,6 +5075,56 @@ func (s *apiSuite) TestConnectPlugSuccess(c *check.C) {
 	}})
 }
 
+func (s *apiSuite) TestConnectPlugInstallingProvider(c *check.C) {
+	d := s.daemon(c)
+
+	s.mockSnap(c, consumerYaml)
+
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+
+	ifacestateConnectInstallingProvider = func(ctx context.Context, st *state.State, plugSnap, plugName, slotSnap, slotName string, userID int) ([]*state.TaskSet, error) {
+		c.Check(plugSnap, check.Equals, "consumer")
+		c.Check(plugName, check.Equals, "plug")
+		c.Check(slotSnap, check.Equals, "producer")
+		c.Check(slotName, check.Equals, "")
+		c.Check(userID, check.Equals, 1)
+		t := st.NewTask("fake-install-snap", "Doing a fake install")
+		return []*state.TaskSet{state.NewTaskSet(t)}, nil
+	}
+
+	action := &interfaceAction{
+		Action: "connect",
+		Plugs:  []plugJSON{{Snap: "consumer", Name: "plug"}},
+		Slots:  []slotJSON{{Snap: "producer"}},
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	interfacesCmd.POST(interfacesCmd, req, &auth.UserState{ID: 1}).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 202)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	id := body["change"].(string)
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(id)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "connect-snap")
+	c.Check(chg.Summary(), check.Equals, `Connect consumer:plug to snap "producer"`)
+	c.Assert(chg.Tasks(), check.HasLen, 1)
+	c.Check(chg.Tasks()[0].Kind(), check.Equals, "fake-install-snap")
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"consumer", "producer"})
+}
+
 func (s *apiSuite) TestConnectPlugFailureInterfaceMismatch(c *check.C) {
 	d := s.daemon(c)
 
@@ -6507,6 +6928,35 @@ func (s *apiSuite) TestSnapctlForbiddenError(c *check.C) {
 	c.Assert(rsp.Status, check.Equals, 403)
 }
 
+func (s *apiSuite) TestSnapctlUnsuccesfulError(c *check.C) {
+	_ = s.daemon(c)
+
+	runSnapctlUcrednetGet = func(string) (int32, uint32, string, error) {
+		return 100, 9999, dirs.SnapSocket, nil
+	}
+	defer func() { runSnapctlUcrednetGet = ucrednetGet }()
+	ctlcmdRun = func(ctx *hookstate.Context, arg []string, uid uint32) ([]byte, []byte, error) {
+		return []byte("out"), []byte("err"), &ctlcmd.UnsuccessfulError{ExitCode: 123}
+	}
+	defer func() { ctlcmdRun = ctlcmd.Run }()
+
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"context-id": "some-context", "args": [%q, %q]}`, "is-connected", "plug"))
+	req, err := http.NewRequest("POST", "/v2/snapctl", buf)
+	c.Assert(err, check.IsNil)
+	rsp := runSnapctl(snapctlCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Result, check.DeepEquals, &errorResult{
+		Message: "unsuccessful with exit code: 123",
+		Kind:    errorKindUnsuccessful,
+		Value: map[string]interface{}{
+			"stdout":    "out",
+			"stderr":    "err",
+			"exit-code": 123,
+		},
+	})
+}
+
 type appSuite struct {
 	apiBaseSuite
 	cmd *testutil.MockCmd
@@ -6673,6 +7123,39 @@ UnitFileState=enabled
 	c.Check(sort.StringsAreSorted(appNames), check.Equals, true)
 }
 
+func (s *appSuite) TestGetAppsInfoUserServices(c *check.C) {
+	s.mkInstalledInState(c, s.d, "snap-e", "dev", "v1", snap.R(1), true, "apps: {svc4: {daemon: simple, daemon-scope: user}, svc5: {daemon: oneshot, daemon-scope: user, timer: 10:00}}")
+
+	req, err := http.NewRequest("GET", "/v2/apps?names=snap-e", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getAppsInfo(appsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.FitsTypeOf, []client.AppInfo{})
+	svcs := rsp.Result.([]client.AppInfo)
+	c.Check(svcs, check.DeepEquals, []client.AppInfo{{
+		Snap:        "snap-e",
+		Name:        "svc4",
+		Daemon:      "simple",
+		DaemonScope: snap.UserDaemon,
+		Enabled:     true,
+	}, {
+		Snap:        "snap-e",
+		Name:        "svc5",
+		Daemon:      "oneshot",
+		DaemonScope: snap.UserDaemon,
+		Enabled:     true,
+		Activators: []client.AppActivator{
+			{Name: "svc5", Type: "timer", Enabled: true},
+		},
+	}})
+	c.Check(s.sysctlArgses, check.DeepEquals, [][]string{
+		{"--user", "--global", "--root", dirs.GlobalRootDir, "is-enabled", "snap.snap-e.svc4.service"},
+		{"--user", "--global", "--root", dirs.GlobalRootDir, "is-enabled", "snap.snap-e.svc5.service"},
+		{"--user", "--global", "--root", dirs.GlobalRootDir, "is-enabled", "snap.snap-e.svc5.timer"},
+	})
+}
+
 func (s *appSuite) TestGetAppsInfoBadSelect(c *check.C) {
 	req, err := http.NewRequest("GET", "/v2/apps?select=potato", nil)
 	c.Assert(err, check.IsNil)
@@ -7002,6 +7485,19 @@ func (s *appSuite) TestPostAppsStartThree(c *check.C) {
 	c.Check(chg.Tasks()[0].Summary(), check.Equals, "start of [snap-a.svc1 snap-a.svc2 snap-b.svc3]")
 }
 
+func (s *appSuite) TestPostAppsStartStopOrdered(c *check.C) {
+	s.mkInstalledInState(c, s.d, "snap-e", "dev", "v1", snap.R(1), true, "apps: {svc4: {daemon: simple, after: [svc6]}, svc5: {daemon: simple, before: [svc6]}, svc6: {daemon: simple}}")
+
+	inst := servicestate.Instruction{Action: "start", Names: []string{"snap-e"}}
+	expected := [][]string{{"systemctl", "start", "snap.snap-e.svc5.service", "snap.snap-e.svc6.service", "snap.snap-e.svc4.service"}}
+	s.testPostApps(c, inst, expected)
+
+	s.cmd.ForgetCalls()
+	inst = servicestate.Instruction{Action: "stop", Names: []string{"snap-e"}}
+	expected = [][]string{{"systemctl", "stop", "snap.snap-e.svc4.service", "snap.snap-e.svc6.service", "snap.snap-e.svc5.service"}}
+	s.testPostApps(c, inst, expected)
+}
+
 func (s *appSuite) TestPosetAppsStop(c *check.C) {
 	inst := servicestate.Instruction{Action: "stop", Names: []string{"snap-a.svc2"}}
 	expected := [][]string{{"systemctl", "stop", "snap.snap-a.svc2.service"}}
@@ -74,6 +74,10 @@ type userResponseData struct {
 	Email    string   `json:"email,omitempty"`
 	SSHKeys  []string `json:"ssh-keys,omitempty"`
 
+	// NeedsReLogin is set when the store credentials of the user
+	// expired and could not be renewed
+	NeedsReLogin bool `json:"needs-relogin,omitempty"`
+
 	Macaroon   string   `json:"macaroon,omitempty"`
 	Discharges []string `json:"discharges,omitempty"`
 }
@@ -171,6 +175,7 @@ func loginUser(c *Command, r *http.Request, user *auth.UserState) Response {
 		// local user logged-in, set its store macaroons
 		user.StoreMacaroon = macaroon
 		user.StoreDischarges = []string{discharge}
+		user.NeedsReLogin = false
 		// user's email address authenticated by the store
 		user.Email = loginData.Email
 		err = auth.UpdateUser(st, user)
@@ -461,9 +466,10 @@ func getUsers(c *Command, r *http.Request, user *auth.UserState) Response {
 	resp := make([]userResponseData, len(users))
 	for i, u := range users {
 		resp[i] = userResponseData{
-			Username: u.Username,
-			Email:    u.Email,
-			ID:       u.ID,
+			Username:     u.Username,
+			Email:        u.Email,
+			ID:           u.ID,
+			NeedsReLogin: u.NeedsReLogin,
 		}
 	}
 	return SyncResponse(resp, nil)
@@ -27,6 +27,7 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -35,6 +36,7 @@ import (
 	"github.com/gorilla/mux"
 	"gopkg.in/tomb.v2"
 
+	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/httputil"
@@ -655,10 +657,37 @@ func (d *Daemon) doReboot(sigCh chan<- os.Signal, waitTimeout time.Duration) err
 
 var shutdownMsg = i18n.G("reboot scheduled to update the system")
 
+var bootRebootArgs = boot.RebootArgs
+
+// writeRebootParam passes the arguments the bootloader needs for the next
+// boot, for example to boot a try kernel, to the reboot that systemd
+// eventually performs.
+func writeRebootParam() error {
+	rebootParam := filepath.Join(dirs.GlobalRootDir, "/run/systemd/reboot-param")
+	args, err := bootRebootArgs()
+	if err != nil {
+		// not all systems have a bootloader snapd knows about
+		logger.Debugf("%v", err)
+	}
+	if args == "" {
+		if err := os.Remove(rebootParam); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(rebootParam), 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(rebootParam, []byte(args+"\n"), 0644, 0)
+}
+
 func rebootImpl(rebootDelay time.Duration) error {
 	if rebootDelay < 0 {
 		rebootDelay = 0
 	}
+	if err := writeRebootParam(); err != nil {
+		return fmt.Errorf("cannot set reboot arguments: %v", err)
+	}
 	mins := int64(rebootDelay / time.Minute)
 	cmd := exec.Command("shutdown", "-r", fmt.Sprintf("+%d", mins), shutdownMsg)
 	if out, err := cmd.CombinedOutput(); err != nil {
@@ -853,6 +853,36 @@ func (s *daemonSuite) TestRebootHelper(c *check.C) {
 	}
 }
 
+func (s *daemonSuite) TestRebootHelperRebootArgs(c *check.C) {
+	cmd := testutil.MockCommand(c, "shutdown", "")
+	defer cmd.Restore()
+	oldBootRebootArgs := bootRebootArgs
+	defer func() { bootRebootArgs = oldBootRebootArgs }()
+
+	rebootParam := filepath.Join(dirs.GlobalRootDir, "/run/systemd/reboot-param")
+
+	// a try kernel is staged
+	bootRebootArgs = func() (string, error) { return "0 tryboot", nil }
+	c.Assert(reboot(0), check.IsNil)
+	c.Check(rebootParam, testutil.FileEquals, "0 tryboot\n")
+	c.Check(cmd.Calls(), check.DeepEquals, [][]string{
+		{"shutdown", "-r", "+0", "reboot scheduled to update the system"},
+	})
+
+	// and no longer is
+	bootRebootArgs = func() (string, error) { return "", nil }
+	c.Assert(reboot(0), check.IsNil)
+	c.Check(rebootParam, testutil.FileAbsent)
+
+	// no bootloader
+	bootRebootArgs = func() (string, error) {
+		return "", fmt.Errorf("cannot get reboot arguments: cannot determine bootloader")
+	}
+	c.Assert(reboot(0), check.IsNil)
+	c.Check(rebootParam, testutil.FileAbsent)
+	c.Check(cmd.Calls(), check.HasLen, 3)
+}
+
 func makeDaemonListeners(c *check.C, d *Daemon) {
 	snapdL, err := net.Listen("tcp", "127.0.0.1:0")
 	c.Assert(err, check.IsNil)
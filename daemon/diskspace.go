@@ -0,0 +1,172 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// insufficientDiskSpaceError is returned when there is not enough free
+// disk space to carry out a multi-snap change.
+type insufficientDiskSpaceError struct {
+	snaps     []string
+	action    string
+	path      string
+	needed    uint64
+	available uint64
+}
+
+func (e *insufficientDiskSpaceError) Error() string {
+	return fmt.Sprintf("cannot %s %s: insufficient disk space in %s, need %s but only %s available",
+		e.action, strutil.Quoted(e.snaps), e.path, strutil.SizeToStr(int64(e.needed)), strutil.SizeToStr(int64(e.available)))
+}
+
+// freeDiskSpaceImpl returns the device of the filesystem holding path
+// and the space available on it to unprivileged users.
+func freeDiskSpaceImpl(path string) (dev uint64, available uint64, err error) {
+	var fi syscall.Stat_t
+	if err := syscall.Stat(path, &fi); err != nil {
+		return 0, 0, err
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return uint64(fi.Dev), st.Bavail * uint64(st.Bsize), nil
+}
+
+var (
+	freeDiskSpace   = freeDiskSpaceImpl
+	currentDataSize = snap.CurrentDataSize
+	installSize     = snap.InstallSize
+)
+
+// checkDiskSpace checks that there is enough free disk space to carry out
+// the given action on the snaps set up by the given task sets. The needed
+// space is, in the snap blob directory, the download size of the snaps as
+// reported by the store or, for snaps already at hand, their install size
+// as estimated by snap.InstallSize, and, in the snap data directory, the
+// size of the data of the current revisions of the snaps, which is copied
+// over on refresh. Snaps with neither are not taken into account.
+//
+// The state must be locked by the caller, it is temporarily unlocked
+// while the snaps and the data directories are walked.
+func checkDiskSpace(st *state.State, action string, snapNames []string, tasksets []*state.TaskSet) error {
+	var downloadSize uint64
+	var local []*snapstate.SnapSetup
+	var installed []string
+	for _, ts := range tasksets {
+		for _, t := range ts.Tasks() {
+			var snapsup snapstate.SnapSetup
+			if err := t.Get("snap-setup", &snapsup); err != nil {
+				continue
+			}
+			switch {
+			case snapsup.DownloadInfo != nil && snapsup.DownloadInfo.Size > 0:
+				downloadSize += uint64(snapsup.DownloadInfo.Size)
+			case snapsup.SnapPath != "" && snapsup.SideInfo != nil:
+				local = append(local, &snapsup)
+			default:
+				continue
+			}
+			var snapst snapstate.SnapState
+			if err := snapstate.Get(st, snapsup.InstanceName(), &snapst); err == nil && snapst.IsInstalled() {
+				installed = append(installed, snapsup.InstanceName())
+			}
+		}
+	}
+	if downloadSize == 0 && len(local) == 0 {
+		return nil
+	}
+
+	st.Unlock()
+	defer st.Lock()
+
+	var dataSize uint64
+	dataSizes := make(map[string]uint64, len(installed))
+	for _, name := range installed {
+		size, err := currentDataSize(name)
+		if err != nil {
+			logger.Noticef("cannot estimate data size of snap %q: %v", name, err)
+			continue
+		}
+		dataSizes[name] = size
+		dataSize += size
+	}
+
+	blobSize := downloadSize
+	for _, snapsup := range local {
+		name := snapsup.InstanceName()
+		container, err := snap.Open(snapsup.SnapPath)
+		if err != nil {
+			logger.Noticef("cannot estimate install size of snap %q: %v", name, err)
+			continue
+		}
+		info := &snap.Info{SideInfo: *snapsup.SideInfo, InstanceKey: snapsup.InstanceKey}
+		size, err := installSize(info, container)
+		if err != nil {
+			logger.Noticef("cannot estimate install size of snap %q: %v", name, err)
+			continue
+		}
+		// the data copied over is accounted for in the data directory
+		if size > dataSizes[name] {
+			blobSize += size - dataSizes[name]
+		}
+	}
+
+	// the blob and data directories may or may not live on the same
+	// filesystem
+	needs := []struct {
+		path string
+		size uint64
+	}{
+		{dirs.SnapBlobDir, blobSize},
+		{dirs.SnapDataDir, dataSize},
+	}
+	needed := make(map[uint64]uint64, len(needs))
+	for _, need := range needs {
+		if need.size == 0 {
+			continue
+		}
+		dev, available, err := freeDiskSpace(need.path)
+		if err != nil {
+			return fmt.Errorf("cannot get free disk space of %s: %v", need.path, err)
+		}
+		needed[dev] += need.size
+		if needed[dev] > available {
+			return &insufficientDiskSpaceError{
+				snaps:     snapNames,
+				action:    action,
+				path:      need.path,
+				needed:    needed[dev],
+				available: available,
+			}
+		}
+	}
+	return nil
+}
@@ -190,6 +190,10 @@ const (
 	errorKindSystemRestart = errorKind("system-restart")
 
 	errorKindAssertionNotFound = errorKind("assertion-not-found")
+
+	errorKindInsufficientDiskSpace = errorKind("insufficient-disk-space")
+
+	errorKindUnsuccessful = errorKind("unsuccessful")
 )
 
 type errorValue interface{}
@@ -582,6 +586,8 @@ func errToResponse(err error, snaps []string, fallback func(format string, v ...
 		case *snapstate.SnapNotClassicError:
 			kind = errorKindSnapNotClassic
 			snapName = err.Snap
+		case *insufficientDiskSpaceError:
+			kind = errorKindInsufficientDiskSpace
 		case net.Error:
 			if err.Timeout() {
 				kind = errorKindNetworkTimeout
@@ -318,6 +318,13 @@ func mapLocal(about aboutSnap) *client.Snap {
 		result.MountedFrom, _ = os.Readlink(result.MountedFrom)
 	}
 	result.Health = about.health
+	if hold := snapst.RefreshHold; hold != nil {
+		result.RefreshHold = &client.SnapRefreshHold{Time: hold.Time}
+		if !hold.Forever() {
+			until := hold.Until
+			result.RefreshHold.Until = &until
+		}
+	}
 
 	return result
 }
@@ -57,8 +57,9 @@ var (
 	SnapRunNsDir              string
 	SnapRunLockDir            string
 
-	SnapSeedDir   string
-	SnapDeviceDir string
+	SnapSeedDir       string
+	SnapDeviceDir     string
+	SnapBootAssetsDir string
 
 	SnapAssertsDBDir      string
 	SnapCookieDir         string
@@ -67,6 +68,7 @@ var (
 	SnapSeqDir            string
 
 	SnapStateFile     string
+	SnapStateDBFile   string
 	SnapSystemKeyFile string
 
 	SnapRepairDir        string
@@ -91,6 +93,9 @@ var (
 	SnapDesktopIconsDir string
 	SnapBusPolicyDir    string
 
+	SnapDBusSessionServicesDir string
+	SnapDBusSystemServicesDir  string
+
 	SystemApparmorDir      string
 	SystemApparmorCacheDir string
 
@@ -217,11 +222,34 @@ func SnapSeedDirUnder(rootdir string) string {
 	return filepath.Join(rootdir, snappyDir, "seed")
 }
 
+// SnapModeenvFileUnder returns the path to the modeenv file under rootdir.
+func SnapModeenvFileUnder(rootdir string) string {
+	return filepath.Join(rootdir, snappyDir, "modeenv")
+}
+
+// SnapDeviceDirUnder returns the path to the device dir under rootdir.
+func SnapDeviceDirUnder(rootdir string) string {
+	return filepath.Join(rootdir, snappyDir, "device")
+}
+
+// SnapBootAssetsDirUnder returns the path to the boot assets cache dir
+// under rootdir.
+func SnapBootAssetsDirUnder(rootdir string) string {
+	return filepath.Join(rootdir, snappyDir, "boot-assets")
+}
+
 // SnapStateFileUnder returns the path to snapd state file under rootdir.
 func SnapStateFileUnder(rootdir string) string {
 	return filepath.Join(rootdir, snappyDir, "state.json")
 }
 
+// SnapStateDBFileUnder returns the path to the snapd state database,
+// used instead of the state file by the bolt state storage, under
+// rootdir.
+func SnapStateDBFileUnder(rootdir string) string {
+	return filepath.Join(rootdir, snappyDir, "state.db")
+}
+
 // SetRootDir allows settings a new global root directory, this is useful
 // for e.g. chroot operations
 func SetRootDir(rootdir string) {
@@ -267,6 +295,7 @@ func SetRootDir(rootdir string) {
 	SnapSeqDir = filepath.Join(rootdir, snappyDir, "sequence")
 
 	SnapStateFile = SnapStateFileUnder(rootdir)
+	SnapStateDBFile = SnapStateDBFileUnder(rootdir)
 	SnapSystemKeyFile = filepath.Join(rootdir, snappyDir, "system-key")
 
 	SnapCacheDir = filepath.Join(rootdir, "/var/cache/snapd")
@@ -276,7 +305,8 @@ func SetRootDir(rootdir string) {
 	SnapAuxStoreInfoDir = filepath.Join(SnapCacheDir, "aux")
 
 	SnapSeedDir = SnapSeedDirUnder(rootdir)
-	SnapDeviceDir = filepath.Join(rootdir, snappyDir, "device")
+	SnapDeviceDir = SnapDeviceDirUnder(rootdir)
+	SnapBootAssetsDir = SnapBootAssetsDirUnder(rootdir)
 
 	SnapRepairDir = filepath.Join(rootdir, snappyDir, "repair")
 	SnapRepairStateFile = filepath.Join(SnapRepairDir, "repair.json")
@@ -291,6 +321,8 @@ func SetRootDir(rootdir string) {
 	SnapUserServicesDir = filepath.Join(rootdir, "/etc/systemd/user")
 	SnapSystemdConfDir = filepath.Join(rootdir, "/etc/systemd/system.conf.d")
 	SnapBusPolicyDir = filepath.Join(rootdir, "/etc/dbus-1/system.d")
+	SnapDBusSessionServicesDir = filepath.Join(rootdir, snappyDir, "dbus-1", "services")
+	SnapDBusSystemServicesDir = filepath.Join(rootdir, snappyDir, "dbus-1", "system-services")
 
 	SystemApparmorDir = filepath.Join(rootdir, "/etc/apparmor.d")
 	SystemApparmorCacheDir = filepath.Join(rootdir, "/etc/apparmor.d/cache")
@@ -43,6 +43,10 @@ const (
 	PerUserMountNamespace
 	// RefreshAppAwareness controls refresh being aware of running applications.
 	RefreshAppAwareness
+	// InstallContentProviders controls installing missing default content providers on connect.
+	InstallContentProviders
+	// AppArmorPrompting controls prompting the user about selected interface denials.
+	AppArmorPrompting
 	// lastFeature is the final known feature, it is only used for testing.
 	lastFeature
 )
@@ -65,6 +69,9 @@ var featureNames = map[SnapdFeature]string{
 	SnapdSnap:             "snapd-snap",
 	PerUserMountNamespace: "per-user-mount-namespace",
 	RefreshAppAwareness:   "refresh-app-awareness",
+
+	InstallContentProviders: "install-content-providers",
+	AppArmorPrompting:       "apparmor-prompting",
 }
 
 // featuresEnabledWhenUnset contains a set of features that are enabled when not explicitly configured.
@@ -43,6 +43,8 @@ func (*featureSuite) TestName(c *C) {
 	c.Check(features.SnapdSnap.String(), Equals, "snapd-snap")
 	c.Check(features.PerUserMountNamespace.String(), Equals, "per-user-mount-namespace")
 	c.Check(features.RefreshAppAwareness.String(), Equals, "refresh-app-awareness")
+	c.Check(features.InstallContentProviders.String(), Equals, "install-content-providers")
+	c.Check(features.AppArmorPrompting.String(), Equals, "apparmor-prompting")
 	c.Check(func() { _ = features.SnapdFeature(1000).String() }, PanicMatches, "unknown feature flag code 1000")
 }
 
@@ -59,6 +61,8 @@ func (*featureSuite) TestIsExported(c *C) {
 	c.Check(features.Layouts.IsExported(), Equals, false)
 	c.Check(features.Hotplug.IsExported(), Equals, false)
 	c.Check(features.SnapdSnap.IsExported(), Equals, false)
+	c.Check(features.InstallContentProviders.IsExported(), Equals, false)
+	c.Check(features.AppArmorPrompting.IsExported(), Equals, false)
 
 	c.Check(features.ParallelInstances.IsExported(), Equals, true)
 	c.Check(features.PerUserMountNamespace.IsExported(), Equals, true)
@@ -91,6 +95,8 @@ func (*featureSuite) TestIsEnabledWhenUnset(c *C) {
 	c.Check(features.SnapdSnap.IsEnabledWhenUnset(), Equals, false)
 	c.Check(features.PerUserMountNamespace.IsEnabledWhenUnset(), Equals, false)
 	c.Check(features.RefreshAppAwareness.IsEnabledWhenUnset(), Equals, false)
+	c.Check(features.InstallContentProviders.IsEnabledWhenUnset(), Equals, false)
+	c.Check(features.AppArmorPrompting.IsEnabledWhenUnset(), Equals, false)
 }
 
 func (*featureSuite) TestControlFile(c *C) {
@@ -0,0 +1,115 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package gadget
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// allowedKernelArguments lists the kernel command line arguments that a gadget
+// may set, those are mostly relevant for tuning of the console and debugging.
+var allowedKernelArguments = map[string]bool{
+	"console":            true,
+	"earlycon":           true,
+	"earlyprintk":        true,
+	"ignore_loglevel":    true,
+	"loglevel":           true,
+	"panic":              true,
+	"printk.devkmsg":     true,
+	"quiet":              true,
+	"debug":              true,
+	"splash":             true,
+	"systemd.log_level":  true,
+	"systemd.log_target": true,
+}
+
+// KernelCommandLineFromGadget returns the kernel command line arguments
+// provided by the gadget in either the cmdline.extra or the cmdline.full file
+// at the top of the gadget snap directory. Arguments from cmdline.extra are
+// appended to the command line built by the bootloader, while ones from
+// cmdline.full replace the default arguments. An empty command line is returned
+// when the gadget provides neither of the files.
+func KernelCommandLineFromGadget(gadgetSnapRootDir string) (cmdline string, full bool, err error) {
+	extra, err := readCommandLineFile(filepath.Join(gadgetSnapRootDir, "cmdline.extra"))
+	if err != nil && !os.IsNotExist(err) {
+		return "", false, err
+	}
+	hasExtra := err == nil
+	fullCmdline, err := readCommandLineFile(filepath.Join(gadgetSnapRootDir, "cmdline.full"))
+	if err != nil && !os.IsNotExist(err) {
+		return "", false, err
+	}
+	hasFull := err == nil
+
+	switch {
+	case hasExtra && hasFull:
+		return "", false, fmt.Errorf("cannot support both extra and full kernel command line")
+	case hasFull:
+		return fullCmdline, true, nil
+	case hasExtra:
+		return extra, false, nil
+	}
+	return "", false, nil
+}
+
+func readCommandLineFile(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	cmdline, err := parseCommandLine(content)
+	if err != nil {
+		return "", fmt.Errorf("invalid kernel command line in %s: %v", filepath.Base(path), err)
+	}
+	return cmdline, nil
+}
+
+// parseCommandLine parses the content of a kernel command line file, which may
+// span multiple lines and contain comments, and validates the arguments.
+func parseCommandLine(content []byte) (string, error) {
+	var args []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.ContainsAny(line, `"'`) {
+			return "", fmt.Errorf("quoted arguments are not supported")
+		}
+		for _, arg := range strings.Fields(line) {
+			name := strings.SplitN(arg, "=", 2)[0]
+			if !allowedKernelArguments[name] {
+				return "", fmt.Errorf("argument %q is not allowed", name)
+			}
+			args = append(args, arg)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(args, " "), nil
+}
@@ -0,0 +1,103 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package gadget_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/gadget"
+)
+
+type cmdlineTestSuite struct {
+	dir string
+}
+
+var _ = Suite(&cmdlineTestSuite{})
+
+func (s *cmdlineTestSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+}
+
+func (s *cmdlineTestSuite) TestKernelCommandLineFromGadgetNone(c *C) {
+	cmdline, full, err := gadget.KernelCommandLineFromGadget(s.dir)
+	c.Assert(err, IsNil)
+	c.Check(cmdline, Equals, "")
+	c.Check(full, Equals, false)
+}
+
+func (s *cmdlineTestSuite) TestKernelCommandLineFromGadgetHappy(c *C) {
+	for _, tc := range []struct {
+		file    string
+		content string
+		cmdline string
+		full    bool
+	}{
+		{"cmdline.extra", "console=ttyS0,115200n8\n", "console=ttyS0,115200n8", false},
+		{"cmdline.extra", "", "", false},
+		{"cmdline.full", `
+# the serial console
+console=ttyS0 console=tty1
+
+# debugging
+  loglevel=7 systemd.log_level=debug
+panic=-1
+`, "console=ttyS0 console=tty1 loglevel=7 systemd.log_level=debug panic=-1", true},
+	} {
+		for _, name := range []string{"cmdline.extra", "cmdline.full"} {
+			os.Remove(filepath.Join(s.dir, name))
+		}
+		err := ioutil.WriteFile(filepath.Join(s.dir, tc.file), []byte(tc.content), 0644)
+		c.Assert(err, IsNil)
+
+		cmdline, full, err := gadget.KernelCommandLineFromGadget(s.dir)
+		c.Assert(err, IsNil)
+		c.Check(cmdline, Equals, tc.cmdline)
+		c.Check(full, Equals, tc.full)
+	}
+}
+
+func (s *cmdlineTestSuite) TestKernelCommandLineFromGadgetErrors(c *C) {
+	err := ioutil.WriteFile(filepath.Join(s.dir, "cmdline.extra"), []byte("console=ttyS0 init=/bin/sh\n"), 0644)
+	c.Assert(err, IsNil)
+	_, _, err = gadget.KernelCommandLineFromGadget(s.dir)
+	c.Assert(err, ErrorMatches, `invalid kernel command line in cmdline.extra: argument "init" is not allowed`)
+
+	// a root shell on the console is not for gadgets to give
+	err = ioutil.WriteFile(filepath.Join(s.dir, "cmdline.extra"), []byte("systemd.debug_shell"), 0644)
+	c.Assert(err, IsNil)
+	_, _, err = gadget.KernelCommandLineFromGadget(s.dir)
+	c.Assert(err, ErrorMatches, `invalid kernel command line in cmdline.extra: argument "systemd.debug_shell" is not allowed`)
+
+	err = ioutil.WriteFile(filepath.Join(s.dir, "cmdline.extra"), []byte(`console="ttyS0"`), 0644)
+	c.Assert(err, IsNil)
+	_, _, err = gadget.KernelCommandLineFromGadget(s.dir)
+	c.Assert(err, ErrorMatches, `invalid kernel command line in cmdline.extra: quoted arguments are not supported`)
+
+	err = ioutil.WriteFile(filepath.Join(s.dir, "cmdline.extra"), []byte("console=ttyS0"), 0644)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(s.dir, "cmdline.full"), []byte("console=ttyS0"), 0644)
+	c.Assert(err, IsNil)
+	_, _, err = gadget.KernelCommandLineFromGadget(s.dir)
+	c.Assert(err, ErrorMatches, `cannot support both extra and full kernel command line`)
+}
@@ -195,6 +195,11 @@ type VolumeContent struct {
 	Size Size `yaml:"size"`
 
 	Unpack bool `yaml:"unpack"`
+
+	// Signature names the file, relative to gadget base directory, with a
+	// detached signature of the image or source file. Once signed, the
+	// content must be signed in all later revisions of the gadget.
+	Signature string `yaml:"signature"`
 }
 
 func (vc VolumeContent) String() string {
@@ -351,6 +356,23 @@ func InfoFromGadgetYaml(gadgetYaml []byte, constraints *ModelConstraints) (*Info
 	return &gi, nil
 }
 
+// InfoFromSnapFiles reads the gadget specific metadata from meta/gadget.yaml
+// among the files read from a gadget snap, keyed by their path, as returned
+// by snap.ReadInfoAndFilesFromSnapFile. Constraints are handled as with
+// ReadInfo.
+func InfoFromSnapFiles(files map[string][]byte, constraints *ModelConstraints) (*Info, error) {
+	gmeta, ok := files["meta/gadget.yaml"]
+	if !ok {
+		if constraints == nil || constraints.Classic {
+			// gadget.yaml is optional for classic gadgets
+			return &Info{}, nil
+		}
+		return nil, fmt.Errorf("cannot find meta/gadget.yaml in the gadget snap")
+	}
+
+	return InfoFromGadgetYaml(gmeta, constraints)
+}
+
 // ReadInfo reads the gadget specific metadata from meta/gadget.yaml in the snap
 // root directory. If constraints is nil, ReadInfo will just check for
 // self-consistency, otherwise rules for the classic or system seed cases are
@@ -734,6 +756,9 @@ func validateFilesystemContent(vc *VolumeContent) error {
 	if vc.Source == "" || vc.Target == "" {
 		return fmt.Errorf("missing source or target")
 	}
+	if vc.Signature != "" && strings.HasSuffix(vc.Source, "/") {
+		return fmt.Errorf("cannot use signature with directory source")
+	}
 	return nil
 }
 
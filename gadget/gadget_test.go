@@ -310,6 +310,26 @@ func (s *gadgetYamlTestSuite) TestReadGadgetYamlMissing(c *C) {
 	c.Assert(err, ErrorMatches, ".*meta/gadget.yaml: no such file or directory")
 }
 
+func (s *gadgetYamlTestSuite) TestInfoFromSnapFiles(c *C) {
+	// if constraints are nil or classic, we allow a missing yaml
+	for _, constraints := range []*gadget.ModelConstraints{nil, {Classic: true}} {
+		gi, err := gadget.InfoFromSnapFiles(map[string][]byte{}, constraints)
+		c.Assert(err, IsNil)
+		c.Check(gi, DeepEquals, &gadget.Info{})
+	}
+	_, err := gadget.InfoFromSnapFiles(map[string][]byte{}, &gadget.ModelConstraints{})
+	c.Check(err, ErrorMatches, "cannot find meta/gadget.yaml in the gadget snap")
+
+	files := map[string][]byte{"meta/gadget.yaml": mockGadgetYaml}
+	gi, err := gadget.InfoFromSnapFiles(files, &gadget.ModelConstraints{})
+	c.Assert(err, IsNil)
+	c.Check(gi.Volumes, HasLen, 1)
+
+	files = map[string][]byte{"meta/gadget.yaml": nil}
+	_, err = gadget.InfoFromSnapFiles(files, &gadget.ModelConstraints{})
+	c.Check(err, ErrorMatches, "bootloader not declared in any volume")
+}
+
 func (s *gadgetYamlTestSuite) TestReadGadgetYamlOnClassicOptional(c *C) {
 	// no meta/gadget.yaml
 	gi, err := gadget.ReadInfo(s.dir, &gadget.ModelConstraints{Classic: true})
@@ -1098,6 +1118,24 @@ size: 1M
 content:
   - source: foo
 `
+	fsSigned := `
+type: 21686148-6449-6E6F-744E-656564454649
+filesystem: vfat
+size: 1M
+content:
+  - source: grubx64.efi
+    target: EFI/boot/grubx64.efi
+    signature: grubx64.efi.sig
+`
+	fsSignedDir := `
+type: 21686148-6449-6E6F-744E-656564454649
+filesystem: vfat
+size: 1M
+content:
+  - source: efi/
+    target: EFI/
+    signature: efi.sig
+`
 
 	for i, tc := range []struct {
 		s   *gadget.VolumeStructure
@@ -1110,6 +1148,8 @@ content:
 		{mustParseStructure(c, fsOk), nil, ""},
 		{mustParseStructure(c, fsMixed), nil, `invalid content #1: cannot use image content for non-bare file system`},
 		{mustParseStructure(c, fsMissing), nil, `invalid content #0: missing source or target`},
+		{mustParseStructure(c, fsSigned), nil, ""},
+		{mustParseStructure(c, fsSignedDir), nil, `invalid content #0: cannot use signature with directory source`},
 	} {
 		c.Logf("tc: %v %+v", i, tc.s)
 
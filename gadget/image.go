@@ -0,0 +1,151 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package gadget
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// LaidOutVolumesFromGadget lays out all volumes declared by the gadget unpacked
+// at gadgetRoot, indexed by the volume name.
+func LaidOutVolumesFromGadget(gadgetRoot string) (map[string]*LaidOutVolume, error) {
+	info, err := ReadInfo(gadgetRoot, nil)
+	if err != nil {
+		return nil, err
+	}
+	volumes := make(map[string]*LaidOutVolume, len(info.Volumes))
+	for name, vol := range info.Volumes {
+		vol := vol
+		lv, err := LayoutVolume(gadgetRoot, &vol, defaultConstraints)
+		if err != nil {
+			return nil, fmt.Errorf("cannot lay out volume %q: %v", name, err)
+		}
+		volumes[name] = lv
+	}
+	return volumes, nil
+}
+
+// WriteImages writes an image of each volume declared by the gadget unpacked
+// at gadgetRoot into outputDir, named after the volume with an .img suffix.
+// Each image carries the partition table of the volume and the content of all
+// its structures. Filesystem content is staged in the optional workDir, or the
+// default temporary location.
+func WriteImages(gadgetRoot, outputDir, workDir string) error {
+	volumes, err := LaidOutVolumesFromGadget(gadgetRoot)
+	if err != nil {
+		return err
+	}
+	if workDir == "" {
+		workDir, err = ioutil.TempDir("", "snap-image-")
+		if err != nil {
+			return fmt.Errorf("cannot create work directory: %v", err)
+		}
+		defer os.RemoveAll(workDir)
+	}
+	names := make([]string, 0, len(volumes))
+	for name := range volumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		imgPath := filepath.Join(outputDir, name+".img")
+		if err := writeVolumeImage(gadgetRoot, imgPath, volumes[name], workDir); err != nil {
+			return fmt.Errorf("cannot write image of volume %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// backupGPTSectors is the number of sectors used by the backup GPT header and
+// partition entries at the end of the disk.
+const backupGPTSectors = 33
+
+func writeVolumeImage(gadgetRoot, imgPath string, lv *LaidOutVolume, workDir string) error {
+	size := lv.Size
+	if lv.EffectiveSchema() == GPT {
+		size += backupGPTSectors * lv.SectorSize
+	}
+	img, err := os.OpenFile(imgPath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot create image file: %v", err)
+	}
+	defer img.Close()
+	if err := img.Truncate(int64(size)); err != nil {
+		return fmt.Errorf("cannot resize image file: %v", err)
+	}
+
+	// partition first, content of the MBR structure is written in a way
+	// that preserves the partition table
+	if err := Partition(imgPath, lv); err != nil {
+		return err
+	}
+
+	for i := range lv.LaidOutStructure {
+		ps := &lv.LaidOutStructure[i]
+		if ps.IsBare() {
+			rw, err := NewRawStructureWriter(gadgetRoot, ps)
+			if err != nil {
+				return err
+			}
+			if err := rw.Write(img); err != nil {
+				return fmt.Errorf("cannot write structure %v: %v", ps, err)
+			}
+			continue
+		}
+		if err := writeFilesystemStructure(img, gadgetRoot, ps, workDir); err != nil {
+			return fmt.Errorf("cannot write structure %v: %v", ps, err)
+		}
+	}
+	return img.Sync()
+}
+
+func writeFilesystemStructure(img io.WriteSeeker, gadgetRoot string, ps *LaidOutStructure, workDir string) error {
+	fsImg, err := ioutil.TempFile(workDir, "snap-fs-image-")
+	if err != nil {
+		return fmt.Errorf("cannot create filesystem image: %v", err)
+	}
+	defer os.Remove(fsImg.Name())
+	defer fsImg.Close()
+	if err := fsImg.Truncate(int64(ps.Size)); err != nil {
+		return fmt.Errorf("cannot resize filesystem image: %v", err)
+	}
+
+	fw, err := NewFilesystemImageWriter(gadgetRoot, ps, workDir)
+	if err != nil {
+		return err
+	}
+	if err := fw.Write(fsImg.Name(), nil); err != nil {
+		return err
+	}
+
+	if _, err := img.Seek(int64(ps.StartOffset), io.SeekStart); err != nil {
+		return fmt.Errorf("cannot seek to structure start offset 0x%x: %v", ps.StartOffset, err)
+	}
+	if _, err := io.Copy(img, fsImg); err != nil {
+		return fmt.Errorf("cannot copy filesystem image: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package gadget_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type imageTestSuite struct {
+	testutil.BaseTest
+
+	dir    string
+	gadget string
+	out    string
+	sfdisk *testutil.MockCmd
+}
+
+var _ = Suite(&imageTestSuite{})
+
+const multiVolumeGadgetYaml = `volumes:
+  pc:
+    bootloader: grub
+    structure:
+      - name: mbr
+        type: mbr
+        size: 440
+        content:
+          - image: pc-boot.img
+      - name: EFI System
+        type: C12A7328-F81F-11D2-BA4B-00A0C93EC93B
+        filesystem: vfat
+        filesystem-label: system-boot
+        size: 1M
+        content:
+          - source: grubx64.efi
+            target: EFI/boot/grubx64.efi
+  boot-flash:
+    schema: mbr
+    structure:
+      - name: u-boot
+        type: bare
+        offset: 32768
+        size: 524288
+        content:
+          - image: u-boot.img
+`
+
+func (s *imageTestSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.dir = c.MkDir()
+	s.gadget = filepath.Join(s.dir, "gadget")
+	s.out = filepath.Join(s.dir, "out")
+	for _, d := range []string{filepath.Join(s.gadget, "meta"), s.out} {
+		c.Assert(os.MkdirAll(d, 0755), IsNil)
+	}
+	for name, content := range map[string]string{
+		"meta/gadget.yaml": multiVolumeGadgetYaml,
+		"pc-boot.img":      "pc-boot",
+		"grubx64.efi":      "grub",
+		"u-boot.img":       "u-boot",
+	} {
+		err := ioutil.WriteFile(filepath.Join(s.gadget, name), []byte(content), 0644)
+		c.Assert(err, IsNil)
+	}
+
+	s.sfdisk = testutil.MockCommand(c, "sfdisk", fmt.Sprintf(`cat > %s/"$(basename "$1")".sfdisk`, s.dir))
+	s.AddCleanup(s.sfdisk.Restore)
+	s.AddCleanup(gadget.MockMkfsHandlers(map[string]gadget.MkfsFunc{
+		"vfat": func(imgFile, label, contentsRootDir string) error {
+			c.Check(label, Equals, "system-boot")
+			c.Check(filepath.Join(contentsRootDir, "EFI/boot/grubx64.efi"), testutil.FileEquals, "grub")
+			f, err := os.OpenFile(imgFile, os.O_WRONLY, 0644)
+			c.Assert(err, IsNil)
+			defer f.Close()
+			_, err = f.WriteString("vfat-filesystem")
+			return err
+		},
+	}))
+}
+
+func (s *imageTestSuite) TestLaidOutVolumesFromGadget(c *C) {
+	volumes, err := gadget.LaidOutVolumesFromGadget(s.gadget)
+	c.Assert(err, IsNil)
+	c.Assert(volumes, HasLen, 2)
+	c.Check(volumes["pc"].LaidOutStructure, HasLen, 2)
+	c.Check(volumes["pc"].Size, Equals, 2*gadget.SizeMiB)
+	c.Check(volumes["boot-flash"].LaidOutStructure, HasLen, 1)
+	c.Check(volumes["boot-flash"].Size, Equals, (32+512)*gadget.SizeKiB)
+}
+
+func readAt(c *C, fname string, offset gadget.Size, size int) []byte {
+	f, err := os.Open(fname)
+	c.Assert(err, IsNil)
+	defer f.Close()
+	buf := make([]byte, size)
+	_, err = f.ReadAt(buf, int64(offset))
+	c.Assert(err, IsNil)
+	return buf
+}
+
+func (s *imageTestSuite) TestWriteImages(c *C) {
+	err := gadget.WriteImages(s.gadget, s.out, c.MkDir())
+	c.Assert(err, IsNil)
+
+	pcImg := filepath.Join(s.out, "pc.img")
+	st, err := os.Stat(pcImg)
+	c.Assert(err, IsNil)
+	// room for the backup GPT
+	c.Check(st.Size(), Equals, int64(2*gadget.SizeMiB+33*512))
+	c.Check(readAt(c, pcImg, 0, 7), DeepEquals, []byte("pc-boot"))
+	c.Check(readAt(c, pcImg, 1*gadget.SizeMiB, 15), DeepEquals, []byte("vfat-filesystem"))
+
+	flashImg := filepath.Join(s.out, "boot-flash.img")
+	st, err = os.Stat(flashImg)
+	c.Assert(err, IsNil)
+	c.Check(st.Size(), Equals, int64((32+512)*gadget.SizeKiB))
+	c.Check(readAt(c, flashImg, 32*gadget.SizeKiB, 6), DeepEquals, []byte("u-boot"))
+	// nothing else was written
+	c.Check(bytes.Count(readAt(c, flashImg, 0, int(32*gadget.SizeKiB)), []byte{0}), Equals, int(32*gadget.SizeKiB))
+
+	// each volume got its own partition table
+	c.Check(s.sfdisk.Calls(), DeepEquals, [][]string{
+		{"sfdisk", flashImg},
+		{"sfdisk", pcImg},
+	})
+	c.Check(filepath.Join(s.dir, "pc.img.sfdisk"), testutil.FileContains, "label: gpt\n")
+	c.Check(filepath.Join(s.dir, "pc.img.sfdisk"), testutil.FileContains, "start=2048, size=2048, type=C12A7328-F81F-11D2-BA4B-00A0C93EC93B")
+	c.Check(filepath.Join(s.dir, "boot-flash.img.sfdisk"), testutil.FileContains, "label: dos\n")
+}
+
+func (s *imageTestSuite) TestWriteImagesErrors(c *C) {
+	err := os.Remove(filepath.Join(s.gadget, "u-boot.img"))
+	c.Assert(err, IsNil)
+
+	err = gadget.WriteImages(s.gadget, s.out, c.MkDir())
+	c.Assert(err, ErrorMatches, `cannot lay out volume "boot-flash": cannot lay out structure #0 \("u-boot"\): content "u-boot.img": .* no such file or directory`)
+}
@@ -0,0 +1,156 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package gadget
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/osutil/disks"
+)
+
+// isPartitioned returns true if the structure is represented by a partition
+// of the disk.
+func isPartitioned(ps *LaidOutStructure) bool {
+	return ps.Type != "bare" && ps.EffectiveRole() != MBR
+}
+
+// findStructureAt returns the structure of the layout that starts at the given
+// offset, or nil when there is none.
+func findStructureAt(layout *LaidOutVolume, offset Size) *LaidOutStructure {
+	for i := range layout.LaidOutStructure {
+		ps := &layout.LaidOutStructure[i]
+		if ps.VolumeStructure != nil && ps.StartOffset == offset {
+			return ps
+		}
+	}
+	return nil
+}
+
+// EnsureLayoutCompatibility checks whether the layout of a disk, as read from
+// the partition table of the block device, is compatible with the layout of
+// the volume declared by the gadget. Each partition of the disk must match a
+// structure of the gadget in its start offset and size, and when the partition
+// already carries a filesystem, in the filesystem type and label. Structures of
+// the gadget may be missing from the disk, as they can be created later on.
+func EnsureLayoutCompatibility(gadgetLayout, diskLayout *LaidOutVolume) error {
+	if gadgetLayout.Size > diskLayout.Size {
+		return fmt.Errorf("device is too small to fit the requested layout: %v < %v", diskLayout.Size, gadgetLayout.Size)
+	}
+	for i := range diskLayout.LaidOutStructure {
+		dps := &diskLayout.LaidOutStructure[i]
+		if dps.VolumeStructure == nil {
+			// partition with no information about its content
+			continue
+		}
+		gps := findStructureAt(gadgetLayout, dps.StartOffset)
+		if gps == nil || !isPartitioned(gps) {
+			return fmt.Errorf("cannot find disk partition %s (starting at %v) in gadget", dps, dps.StartOffset)
+		}
+		if dps.Size != gps.Size {
+			return fmt.Errorf("cannot use disk partition %s: size %v does not match gadget structure %s size %v", dps, dps.Size, gps, gps.Size)
+		}
+		if dps.Filesystem == "" {
+			// no filesystem yet, its content can be repaired
+			continue
+		}
+		if dps.Filesystem != gps.Filesystem {
+			return fmt.Errorf("cannot use disk partition %s: filesystem %q does not match gadget structure %s filesystem %q", dps, dps.Filesystem, gps, gps.Filesystem)
+		}
+		if label := gps.EffectiveFilesystemLabel(); label != "" && dps.Label != label {
+			return fmt.Errorf("cannot use disk partition %s: filesystem label %q does not match gadget structure %s label %q", dps, dps.Label, gps, label)
+		}
+	}
+	return nil
+}
+
+// StructuresMissingContent returns the structures of the gadget layout that
+// declare a filesystem and are present on the disk as partitions without any
+// filesystem. Those can be repaired by creating the filesystem and writing the
+// structure content from the gadget.
+func StructuresMissingContent(gadgetLayout, diskLayout *LaidOutVolume) []LaidOutStructure {
+	var missing []LaidOutStructure
+	for _, gps := range gadgetLayout.LaidOutStructure {
+		if gps.IsBare() || !isPartitioned(&gps) {
+			continue
+		}
+		dps := findStructureAt(diskLayout, gps.StartOffset)
+		if dps == nil || dps.Filesystem != "" {
+			continue
+		}
+		missing = append(missing, gps)
+	}
+	return missing
+}
+
+// partitionTypeFromDisk converts the partition type reported by udev to the
+// notation used by gadget.yaml. udev reports MBR partition types as 0xN or
+// 0xNN, which become the zero-padded NN.
+func partitionTypeFromDisk(partType string) string {
+	if !strings.HasPrefix(partType, "0x") {
+		return strings.ToUpper(partType)
+	}
+	v, err := strconv.ParseUint(partType[2:], 16, 8)
+	if err != nil {
+		return strings.ToUpper(partType[2:])
+	}
+	return fmt.Sprintf("%02X", v)
+}
+
+// LaidOutVolumeFromDisk expresses the partitions of the disk, along with
+// the filesystems they carry, as a laid out volume that can be matched
+// against the volume declared by the gadget with EnsureLayoutCompatibility.
+func LaidOutVolumeFromDisk(disk disks.Disk) (*LaidOutVolume, error) {
+	size, err := disk.Size()
+	if err != nil {
+		return nil, err
+	}
+	partitions, err := disk.Partitions()
+	if err != nil {
+		return nil, err
+	}
+
+	structure := make([]VolumeStructure, len(partitions))
+	ps := make([]LaidOutStructure, len(partitions))
+	for i, p := range partitions {
+		structure[i] = VolumeStructure{
+			Name:       p.PartitionLabel,
+			Size:       Size(p.Size),
+			Type:       partitionTypeFromDisk(p.PartitionType),
+			Label:      p.FilesystemLabel,
+			Filesystem: p.FilesystemType,
+		}
+		ps[i] = LaidOutStructure{
+			VolumeStructure: &structure[i],
+			StartOffset:     Size(p.StartOffset),
+			Index:           i + 1,
+		}
+	}
+
+	return &LaidOutVolume{
+		Volume: &Volume{
+			Structure: structure,
+		},
+		Size:             Size(size),
+		SectorSize:       512,
+		LaidOutStructure: ps,
+	}, nil
+}
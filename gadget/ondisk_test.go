@@ -0,0 +1,232 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package gadget_test
+
+import (
+	"fmt"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/osutil/disks"
+)
+
+type ondiskTestSuite struct{}
+
+var _ = Suite(&ondiskTestSuite{})
+
+func laidOut(size gadget.Size, structures ...gadget.LaidOutStructure) *gadget.LaidOutVolume {
+	vs := make([]gadget.VolumeStructure, len(structures))
+	for i := range structures {
+		vs[i] = *structures[i].VolumeStructure
+	}
+	return &gadget.LaidOutVolume{
+		Volume:           &gadget.Volume{Structure: vs},
+		Size:             size,
+		SectorSize:       512,
+		LaidOutStructure: structures,
+	}
+}
+
+var (
+	ondiskMBR = gadget.LaidOutStructure{
+		VolumeStructure: &gadget.VolumeStructure{Name: "mbr", Type: "mbr", Size: 440},
+		StartOffset:     0,
+		Index:           0,
+	}
+	ondiskBIOS = gadget.LaidOutStructure{
+		VolumeStructure: &gadget.VolumeStructure{Name: "BIOS Boot", Type: "DA,21686148-6449-6E6F-744E-656564454649", Size: 1 * gadget.SizeMiB},
+		StartOffset:     1 * gadget.SizeMiB,
+		Index:           1,
+	}
+	ondiskSeed = gadget.LaidOutStructure{
+		VolumeStructure: &gadget.VolumeStructure{Name: "Recovery", Role: gadget.SystemSeed, Filesystem: "vfat", Label: "ubuntu-seed", Size: 1200 * gadget.SizeMiB},
+		StartOffset:     2 * gadget.SizeMiB,
+		Index:           2,
+	}
+	ondiskData = gadget.LaidOutStructure{
+		VolumeStructure: &gadget.VolumeStructure{Name: "Writable", Role: gadget.SystemData, Filesystem: "ext4", Size: 1200 * gadget.SizeMiB},
+		StartOffset:     1202 * gadget.SizeMiB,
+		Index:           3,
+	}
+
+	ondiskGadgetLayout = laidOut(2402*gadget.SizeMiB, ondiskMBR, ondiskBIOS, ondiskSeed, ondiskData)
+)
+
+func diskPartition(name string, start, size gadget.Size, fs, label string, index int) gadget.LaidOutStructure {
+	return gadget.LaidOutStructure{
+		VolumeStructure: &gadget.VolumeStructure{Name: name, Size: size, Filesystem: fs, Label: label},
+		StartOffset:     start,
+		Index:           index,
+	}
+}
+
+func (s *ondiskTestSuite) TestEnsureLayoutCompatibilityHappy(c *C) {
+	// no partitions yet
+	diskLayout := laidOut(4096 * gadget.SizeMiB)
+	c.Check(gadget.EnsureLayoutCompatibility(ondiskGadgetLayout, diskLayout), IsNil)
+
+	// some partitions created already
+	diskLayout = laidOut(4096*gadget.SizeMiB,
+		diskPartition("BIOS Boot", 1*gadget.SizeMiB, 1*gadget.SizeMiB, "", "", 1),
+		diskPartition("Recovery", 2*gadget.SizeMiB, 1200*gadget.SizeMiB, "vfat", "ubuntu-seed", 2),
+	)
+	c.Check(gadget.EnsureLayoutCompatibility(ondiskGadgetLayout, diskLayout), IsNil)
+
+	// all of them, with an implicit filesystem label and one without
+	// filesystem
+	diskLayout = laidOut(4096*gadget.SizeMiB,
+		diskPartition("BIOS Boot", 1*gadget.SizeMiB, 1*gadget.SizeMiB, "", "", 1),
+		diskPartition("Recovery", 2*gadget.SizeMiB, 1200*gadget.SizeMiB, "", "", 2),
+		diskPartition("Writable", 1202*gadget.SizeMiB, 1200*gadget.SizeMiB, "ext4", "writable", 3),
+	)
+	c.Check(gadget.EnsureLayoutCompatibility(ondiskGadgetLayout, diskLayout), IsNil)
+}
+
+func (s *ondiskTestSuite) TestEnsureLayoutCompatibilityErrors(c *C) {
+	for _, tc := range []struct {
+		disk *gadget.LaidOutVolume
+		err  string
+	}{
+		{
+			disk: laidOut(1024 * gadget.SizeMiB),
+			err:  `device is too small to fit the requested layout: 1073741824 < 2518679552`,
+		}, {
+			disk: laidOut(4096*gadget.SizeMiB,
+				diskPartition("other", 3*gadget.SizeMiB, 1*gadget.SizeMiB, "", "", 1),
+			),
+			err: `cannot find disk partition #1 \("other"\) \(starting at 3145728\) in gadget`,
+		}, {
+			// the MBR is not a partition
+			disk: laidOut(4096*gadget.SizeMiB,
+				diskPartition("other", 0, 440, "", "", 1),
+			),
+			err: `cannot find disk partition #1 \("other"\) \(starting at 0\) in gadget`,
+		}, {
+			disk: laidOut(4096*gadget.SizeMiB,
+				diskPartition("Recovery", 2*gadget.SizeMiB, 1024*gadget.SizeMiB, "vfat", "ubuntu-seed", 2),
+			),
+			err: `cannot use disk partition #2 \("Recovery"\): size 1073741824 does not match gadget structure #2 \("Recovery"\) size 1258291200`,
+		}, {
+			disk: laidOut(4096*gadget.SizeMiB,
+				diskPartition("Recovery", 2*gadget.SizeMiB, 1200*gadget.SizeMiB, "ext4", "ubuntu-seed", 2),
+			),
+			err: `cannot use disk partition #2 \("Recovery"\): filesystem "ext4" does not match gadget structure #2 \("Recovery"\) filesystem "vfat"`,
+		}, {
+			disk: laidOut(4096*gadget.SizeMiB,
+				diskPartition("Writable", 1202*gadget.SizeMiB, 1200*gadget.SizeMiB, "ext4", "other", 3),
+			),
+			err: `cannot use disk partition #3 \("Writable"\): filesystem label "other" does not match gadget structure #3 \("Writable"\) label "writable"`,
+		},
+	} {
+		err := gadget.EnsureLayoutCompatibility(ondiskGadgetLayout, tc.disk)
+		c.Check(err, ErrorMatches, tc.err)
+	}
+}
+
+func (s *ondiskTestSuite) TestStructuresMissingContent(c *C) {
+	diskLayout := laidOut(4096*gadget.SizeMiB,
+		diskPartition("BIOS Boot", 1*gadget.SizeMiB, 1*gadget.SizeMiB, "", "", 1),
+		diskPartition("Recovery", 2*gadget.SizeMiB, 1200*gadget.SizeMiB, "", "", 2),
+	)
+	// the bare BIOS boot structure has no content to repair, the writable
+	// partition does not exist yet
+	missing := gadget.StructuresMissingContent(ondiskGadgetLayout, diskLayout)
+	c.Assert(missing, HasLen, 1)
+	c.Check(missing[0].Name, Equals, "Recovery")
+
+	diskLayout = laidOut(4096*gadget.SizeMiB,
+		diskPartition("BIOS Boot", 1*gadget.SizeMiB, 1*gadget.SizeMiB, "", "", 1),
+		diskPartition("Recovery", 2*gadget.SizeMiB, 1200*gadget.SizeMiB, "vfat", "ubuntu-seed", 2),
+		diskPartition("Writable", 1202*gadget.SizeMiB, 1200*gadget.SizeMiB, "ext4", "writable", 3),
+	)
+	c.Check(gadget.StructuresMissingContent(ondiskGadgetLayout, diskLayout), HasLen, 0)
+}
+
+type mockDisk struct {
+	size       uint64
+	partitions []disks.Partition
+}
+
+func (d *mockDisk) Dev() string              { return "8:0" }
+func (d *mockDisk) KernelDeviceNode() string { return "/dev/sda" }
+func (d *mockDisk) Size() (uint64, error)    { return d.size, nil }
+func (d *mockDisk) Partitions() ([]disks.Partition, error) {
+	return d.partitions, nil
+}
+func (d *mockDisk) FindMatchingPartitionUUIDWithFsLabel(string) (string, error) {
+	return "", fmt.Errorf("unexpected call")
+}
+func (d *mockDisk) FindMatchingPartitionUUIDWithPartLabel(string) (string, error) {
+	return "", fmt.Errorf("unexpected call")
+}
+func (d *mockDisk) MountPointIsFromDisk(string) (bool, error) {
+	return false, fmt.Errorf("unexpected call")
+}
+
+func (s *ondiskTestSuite) TestLaidOutVolumeFromDisk(c *C) {
+	disk := &mockDisk{
+		size: uint64(4096 * gadget.SizeMiB),
+		partitions: []disks.Partition{
+			{
+				PartitionLabel: "BIOS Boot",
+				PartitionType:  "21686148-6449-6e6f-744e-656564454649",
+				StartOffset:    uint64(1 * gadget.SizeMiB),
+				Size:           uint64(1 * gadget.SizeMiB),
+			}, {
+				PartitionLabel:  "Recovery",
+				PartitionType:   "0xc",
+				FilesystemLabel: "ubuntu-seed",
+				FilesystemType:  "vfat",
+				StartOffset:     uint64(2 * gadget.SizeMiB),
+				Size:            uint64(1200 * gadget.SizeMiB),
+			}, {
+				PartitionLabel: "Writable",
+				StartOffset:    uint64(1202 * gadget.SizeMiB),
+				Size:           uint64(1200 * gadget.SizeMiB),
+			},
+		},
+	}
+
+	diskLayout, err := gadget.LaidOutVolumeFromDisk(disk)
+	c.Assert(err, IsNil)
+	c.Check(diskLayout.Size, Equals, 4096*gadget.SizeMiB)
+	c.Check(diskLayout.SectorSize, Equals, gadget.Size(512))
+	c.Assert(diskLayout.LaidOutStructure, HasLen, 3)
+	c.Check(*diskLayout.LaidOutStructure[0].VolumeStructure, DeepEquals, gadget.VolumeStructure{
+		Name: "BIOS Boot",
+		Type: "21686148-6449-6E6F-744E-656564454649",
+		Size: 1 * gadget.SizeMiB,
+	})
+	c.Check(*diskLayout.LaidOutStructure[1].VolumeStructure, DeepEquals, gadget.VolumeStructure{
+		Name:       "Recovery",
+		Type:       "0C",
+		Size:       1200 * gadget.SizeMiB,
+		Label:      "ubuntu-seed",
+		Filesystem: "vfat",
+	})
+	c.Check(diskLayout.LaidOutStructure[2].StartOffset, Equals, 1202*gadget.SizeMiB)
+	c.Check(diskLayout.LaidOutStructure[2].Index, Equals, 3)
+
+	c.Check(gadget.EnsureLayoutCompatibility(ondiskGadgetLayout, diskLayout), IsNil)
+	missing := gadget.StructuresMissingContent(ondiskGadgetLayout, diskLayout)
+	c.Assert(missing, HasLen, 1)
+	c.Check(missing[0].Name, Equals, "Writable")
+}
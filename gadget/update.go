@@ -21,6 +21,8 @@ package gadget
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 
 	"github.com/snapcore/snapd/logger"
 )
@@ -50,13 +52,19 @@ type GadgetData struct {
 // illegal, or a failure occurs at any of the steps. When there is no update, a
 // special error ErrNoUpdate is returned.
 //
-// Updates are opt-in, and are only applied to structures with a higher value of
-// Edition field in the new gadget definition.
+// Updates are opt-in, and by default are only applied to structures with a
+// higher value of Edition field in the new gadget definition. A different
+// policy for selecting the structures to update can be provided with
+// updatePolicy.
+//
+// The content of the updated structures which declares a detached signature is
+// verified with verifier before anything is written. Content that was signed in
+// the old gadget must be signed in the new one too.
 //
 // Data that would be modified during the update is first backed up inside the
 // rollback directory. Should the apply step fail, the modified data is
 // recovered.
-func Update(old, new GadgetData, rollbackDirPath string) error {
+func Update(old, new GadgetData, rollbackDirPath string, updatePolicy UpdatePolicyFunc, verifier ContentVerifier) error {
 	// TODO: support multi-volume gadgets. But for now we simply
 	//       do not do any gadget updates on those. We cannot error
 	//       here because this would break refreshes of gadgets even
@@ -88,8 +96,12 @@ func Update(old, new GadgetData, rollbackDirPath string) error {
 		return fmt.Errorf("cannot apply update to volume: %v", err)
 	}
 
+	if updatePolicy == nil {
+		updatePolicy = defaultPolicy
+	}
+
 	// now we know which structure is which, find which ones need an update
-	updates, err := resolveUpdate(pOld, pNew)
+	updates, err := resolveUpdate(pOld, pNew, updatePolicy)
 	if err != nil {
 		return err
 	}
@@ -105,6 +117,12 @@ func Update(old, new GadgetData, rollbackDirPath string) error {
 		}
 	}
 
+	for _, update := range updates {
+		if err := verifyStructureContent(update.from, update.to, new.RootDir, verifier); err != nil {
+			return fmt.Errorf("cannot update volume structure %v: %v", update.to, err)
+		}
+	}
+
 	return applyUpdates(new, updates, rollbackDirPath)
 }
 
@@ -218,22 +236,83 @@ func canUpdateVolume(from *PartiallyLaidOutVolume, to *LaidOutVolume) error {
 	return nil
 }
 
+// ContentVerifier is a callback that verifies the detached signature of the
+// given content of a gadget.
+type ContentVerifier func(content, signature []byte) error
+
+func isSigned(ps *LaidOutStructure) bool {
+	for _, c := range ps.Content {
+		if c.Signature != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyStructureContent verifies the signatures of the content of the
+// updated structure. All of it must be signed if any content of the old
+// structure was signed.
+func verifyStructureContent(from *LaidOutStructure, to *LaidOutStructure, rootDir string, verifier ContentVerifier) error {
+	mustBeSigned := isSigned(from)
+	for _, c := range to.Content {
+		if c.Signature == "" {
+			if mustBeSigned {
+				return fmt.Errorf("content %v is not signed", c)
+			}
+			continue
+		}
+		if verifier == nil {
+			return fmt.Errorf("cannot verify signature of content %v: no verifier", c)
+		}
+		name := c.Image
+		if name == "" {
+			name = c.Source
+		}
+		content, err := ioutil.ReadFile(filepath.Join(rootDir, name))
+		if err != nil {
+			return fmt.Errorf("cannot verify signature of content %v: %v", c, err)
+		}
+		signature, err := ioutil.ReadFile(filepath.Join(rootDir, c.Signature))
+		if err != nil {
+			return fmt.Errorf("cannot verify signature of content %v: %v", c, err)
+		}
+		if err := verifier(content, signature); err != nil {
+			return fmt.Errorf("cannot verify signature of content %v: %v", c, err)
+		}
+	}
+	return nil
+}
+
+// UpdatePolicyFunc is a callback that evaluates the provided pair of structures
+// and returns true when the pair should be part of an update.
+type UpdatePolicyFunc func(from, to *LaidOutStructure) bool
+
+func defaultPolicy(from, to *LaidOutStructure) bool {
+	// update only when new edition is higher than the old one; boot assets
+	// are assumed to be backwards compatible, once deployed are not rolled
+	// back or replaced unless a higher edition is available
+	return to.Update.Edition > from.Update.Edition
+}
+
+// RemodelUpdatePolicy implements the update policy of a remodel scenario. The
+// assets of the new gadget may be entirely unrelated to the old ones, hence all
+// structures except the MBR are selected for the update.
+func RemodelUpdatePolicy(from, _ *LaidOutStructure) bool {
+	return from.EffectiveRole() != MBR
+}
+
 type updatePair struct {
 	from *LaidOutStructure
 	to   *LaidOutStructure
 }
 
-func resolveUpdate(oldVol *PartiallyLaidOutVolume, newVol *LaidOutVolume) (updates []updatePair, err error) {
+func resolveUpdate(oldVol *PartiallyLaidOutVolume, newVol *LaidOutVolume, policy UpdatePolicyFunc) (updates []updatePair, err error) {
 	if len(oldVol.LaidOutStructure) != len(newVol.LaidOutStructure) {
 		return nil, errors.New("internal error: the number of structures in new and old volume definitions is different")
 	}
 	for j, oldStruct := range oldVol.LaidOutStructure {
 		newStruct := newVol.LaidOutStructure[j]
-		// update only when new edition is higher than the old one; boot
-		// assets are assumed to be backwards compatible, once deployed
-		// are not rolled back or replaced unless a higher edition is
-		// available
-		if newStruct.Update.Edition > oldStruct.Update.Edition {
+		if policy(&oldStruct, &newStruct) {
 			updates = append(updates, updatePair{
 				from: &oldVol.LaidOutStructure[j],
 				to:   &newVol.LaidOutStructure[j],
@@ -755,7 +755,7 @@ func (u *updateTestSuite) TestUpdateApplyHappy(c *C) {
 	defer restore()
 
 	// go go go
-	err := gadget.Update(oldData, newData, rollbackDir)
+	err := gadget.Update(oldData, newData, rollbackDir, nil, nil)
 	c.Assert(err, IsNil)
 	c.Assert(backupCalls, DeepEquals, map[string]bool{
 		"first":  true,
@@ -804,10 +804,64 @@ func (u *updateTestSuite) TestUpdateApplyOnlyWhenNeeded(c *C) {
 	defer restore()
 
 	// go go go
-	err := gadget.Update(oldData, newData, rollbackDir)
+	err := gadget.Update(oldData, newData, rollbackDir, nil, nil)
 	c.Assert(err, IsNil)
 }
 
+func (u *updateTestSuite) TestUpdateApplyUpdatesArePolicyControlled(c *C) {
+	oldData, newData, rollbackDir := updateDataSet(c)
+	// all structures have the same edition
+	oldData.Info.Volumes["foo"].Structure[0].Update.Edition = 1
+	newData.Info.Volumes["foo"].Structure[0].Update.Edition = 1
+	oldData.Info.Volumes["foo"].Structure[1].Update.Edition = 1
+	newData.Info.Volumes["foo"].Structure[1].Update.Edition = 1
+	oldData.Info.Volumes["foo"].Structure[2].Update.Edition = 1
+	newData.Info.Volumes["foo"].Structure[2].Update.Edition = 1
+
+	toUpdate := map[string]int{}
+	restore := gadget.MockUpdaterForStructure(func(ps *gadget.LaidOutStructure, psRootDir, psRollbackDir string) (gadget.Updater, error) {
+		toUpdate[ps.Name]++
+		return &mockUpdater{}, nil
+	})
+	defer restore()
+
+	// nothing to update with the default policy
+	err := gadget.Update(oldData, newData, rollbackDir, nil, nil)
+	c.Assert(err, Equals, gadget.ErrNoUpdate)
+	c.Assert(toUpdate, HasLen, 0)
+
+	policySeen := map[string]int{}
+	err = gadget.Update(oldData, newData, rollbackDir, func(from, to *gadget.LaidOutStructure) bool {
+		c.Check(from.Name, Equals, to.Name)
+		policySeen[to.Name]++
+		return to.Name == "second"
+	}, nil)
+	c.Assert(err, IsNil)
+	c.Check(policySeen, DeepEquals, map[string]int{
+		"first":  1,
+		"second": 1,
+		"third":  1,
+	})
+	c.Check(toUpdate, DeepEquals, map[string]int{
+		"second": 1,
+	})
+}
+
+func (u *updateTestSuite) TestRemodelUpdatePolicy(c *C) {
+	for _, tc := range []struct {
+		from *gadget.LaidOutStructure
+		exp  bool
+	}{
+		{from: &gadget.LaidOutStructure{VolumeStructure: &gadget.VolumeStructure{Role: gadget.MBR}}, exp: false},
+		// legacy MBR structure with type only
+		{from: &gadget.LaidOutStructure{VolumeStructure: &gadget.VolumeStructure{Type: gadget.MBR}}, exp: false},
+		{from: &gadget.LaidOutStructure{VolumeStructure: &gadget.VolumeStructure{Role: gadget.SystemBoot}}, exp: true},
+		{from: &gadget.LaidOutStructure{VolumeStructure: &gadget.VolumeStructure{Type: "bare"}}, exp: true},
+	} {
+		c.Check(gadget.RemodelUpdatePolicy(tc.from, tc.from), Equals, tc.exp)
+	}
+}
+
 func (u *updateTestSuite) TestUpdateApplyErrorLayout(c *C) {
 	// prepare the stage
 	bareStruct := gadget.VolumeStructure{
@@ -848,13 +902,13 @@ func (u *updateTestSuite) TestUpdateApplyErrorLayout(c *C) {
 	// both old and new bare struct data is missing
 
 	// cannot lay out the new volume when bare struct data is missing
-	err := gadget.Update(oldData, newData, rollbackDir)
+	err := gadget.Update(oldData, newData, rollbackDir, nil, nil)
 	c.Assert(err, ErrorMatches, `cannot lay out the new volume: cannot lay out structure #0 \("foo"\): content "first.img": .* no such file or directory`)
 
 	makeSizedFile(c, filepath.Join(newRootDir, "first.img"), gadget.SizeMiB, nil)
 
 	// Update does not error out when when the bare struct data of the old volume is missing
-	err = gadget.Update(oldData, newData, rollbackDir)
+	err = gadget.Update(oldData, newData, rollbackDir, nil, nil)
 	c.Assert(err, Equals, gadget.ErrNoUpdate)
 }
 
@@ -901,7 +955,7 @@ func (u *updateTestSuite) TestUpdateApplyErrorIllegalVolumeUpdate(c *C) {
 	makeSizedFile(c, filepath.Join(oldRootDir, "first.img"), gadget.SizeMiB, nil)
 	makeSizedFile(c, filepath.Join(newRootDir, "first.img"), 900*gadget.SizeKiB, nil)
 
-	err := gadget.Update(oldData, newData, rollbackDir)
+	err := gadget.Update(oldData, newData, rollbackDir, nil, nil)
 	c.Assert(err, ErrorMatches, `cannot apply update to volume: cannot change the number of structures within volume from 1 to 2`)
 }
 
@@ -952,7 +1006,7 @@ func (u *updateTestSuite) TestUpdateApplyErrorIllegalStructureUpdate(c *C) {
 
 	makeSizedFile(c, filepath.Join(oldRootDir, "first.img"), gadget.SizeMiB, nil)
 
-	err := gadget.Update(oldData, newData, rollbackDir)
+	err := gadget.Update(oldData, newData, rollbackDir, nil, nil)
 	c.Assert(err, ErrorMatches, `cannot update volume structure #0 \("foo"\): cannot change a bare structure to filesystem one`)
 }
 
@@ -991,7 +1045,7 @@ func (u *updateTestSuite) TestUpdateApplyErrorDifferentVolume(c *C) {
 	})
 	defer restore()
 
-	err := gadget.Update(oldData, newData, rollbackDir)
+	err := gadget.Update(oldData, newData, rollbackDir, nil, nil)
 	c.Assert(err, ErrorMatches, `cannot find entry for volume "foo" in updated gadget info`)
 }
 
@@ -1035,7 +1089,7 @@ func (u *updateTestSuite) TestUpdateApplyUpdatesAreOptIn(c *C) {
 	})
 	defer restore()
 
-	err := gadget.Update(oldData, newData, rollbackDir)
+	err := gadget.Update(oldData, newData, rollbackDir, nil, nil)
 	c.Assert(err, Equals, gadget.ErrNoUpdate)
 }
 
@@ -1070,7 +1124,7 @@ func (u *updateTestSuite) TestUpdateApplyBackupFails(c *C) {
 	defer restore()
 
 	// go go go
-	err := gadget.Update(oldData, newData, rollbackDir)
+	err := gadget.Update(oldData, newData, rollbackDir, nil, nil)
 	c.Assert(err, ErrorMatches, `cannot backup volume structure #1 \("second"\): failed`)
 }
 
@@ -1114,7 +1168,7 @@ func (u *updateTestSuite) TestUpdateApplyUpdateFailsThenRollback(c *C) {
 	defer restore()
 
 	// go go go
-	err := gadget.Update(oldData, newData, rollbackDir)
+	err := gadget.Update(oldData, newData, rollbackDir, nil, nil)
 	c.Assert(err, ErrorMatches, `cannot update volume structure #1 \("second"\): failed`)
 	c.Assert(backupCalls, DeepEquals, map[string]bool{
 		// all were backed up
@@ -1185,7 +1239,7 @@ func (u *updateTestSuite) TestUpdateApplyUpdateErrorRollbackFail(c *C) {
 	defer restore()
 
 	// go go go
-	err := gadget.Update(oldData, newData, rollbackDir)
+	err := gadget.Update(oldData, newData, rollbackDir, nil, nil)
 	// preserves update error
 	c.Assert(err, ErrorMatches, `cannot update volume structure #2 \("third"\): update error`)
 	c.Assert(backupCalls, DeepEquals, map[string]bool{
@@ -1222,7 +1276,7 @@ func (u *updateTestSuite) TestUpdateApplyBadUpdater(c *C) {
 	defer restore()
 
 	// go go go
-	err := gadget.Update(oldData, newData, rollbackDir)
+	err := gadget.Update(oldData, newData, rollbackDir, nil, nil)
 	c.Assert(err, ErrorMatches, `cannot prepare update for volume structure #0 \("first"\): bad updater for structure`)
 }
 
@@ -1283,13 +1337,88 @@ func (u *updateTestSuite) TestUpdaterMultiVolumesDoesNotError(c *C) {
 	}
 
 	// a new multi volume gadget update gives no error
-	err := gadget.Update(singleVolume, multiVolume, "some-rollback-dir")
+	err := gadget.Update(singleVolume, multiVolume, "some-rollback-dir", nil, nil)
 	c.Assert(err, IsNil)
 	// but it warns that nothing happens either
 	c.Assert(logbuf.String(), testutil.Contains, "WARNING: gadget assests cannot be updated yet when multiple volumes are used")
 
 	// same for old
-	err = gadget.Update(multiVolume, singleVolume, "some-rollback-dir")
+	err = gadget.Update(multiVolume, singleVolume, "some-rollback-dir", nil, nil)
 	c.Assert(err, IsNil)
 	c.Assert(strings.Count(logbuf.String(), "WARNING: gadget assests cannot be updated yet when multiple volumes are used"), Equals, 2)
 }
+
+func (u *updateTestSuite) TestUpdateApplyVerifiesSignatures(c *C) {
+	oldData, newData, rollbackDir := updateDataSet(c)
+	newData.Info.Volumes["foo"].Structure[0].Update.Edition = 1
+	newData.Info.Volumes["foo"].Structure[0].Content = []gadget.VolumeContent{
+		{Image: "first.img", Signature: "first.img.sig"},
+	}
+	makeSizedFile(c, filepath.Join(newData.RootDir, "first.img.sig"), 0, []byte("first signature"))
+
+	updated := 0
+	restore := gadget.MockUpdaterForStructure(func(ps *gadget.LaidOutStructure, psRootDir, psRollbackDir string) (gadget.Updater, error) {
+		return &mockUpdater{
+			updateCb: func() error {
+				updated++
+				return nil
+			},
+		}, nil
+	})
+	defer restore()
+
+	var verified [][]byte
+	verifier := func(content, signature []byte) error {
+		c.Check(content, HasLen, int(900*gadget.SizeKiB))
+		verified = append(verified, signature)
+		return nil
+	}
+	err := gadget.Update(oldData, newData, rollbackDir, nil, verifier)
+	c.Assert(err, IsNil)
+	c.Check(verified, DeepEquals, [][]byte{[]byte("first signature")})
+	c.Check(updated, Equals, 1)
+
+	// tampered content is not written
+	verifier = func(content, signature []byte) error {
+		return errors.New("failed signature verification")
+	}
+	err = gadget.Update(oldData, newData, rollbackDir, nil, verifier)
+	c.Assert(err, ErrorMatches, `cannot update volume structure #0 \("first"\): cannot verify signature of content image:first.img: failed signature verification`)
+	c.Check(updated, Equals, 1)
+
+	// signed content cannot be updated without a verifier
+	err = gadget.Update(oldData, newData, rollbackDir, nil, nil)
+	c.Assert(err, ErrorMatches, `cannot update volume structure #0 \("first"\): cannot verify signature of content image:first.img: no verifier`)
+	c.Check(updated, Equals, 1)
+}
+
+func (u *updateTestSuite) TestUpdateApplySignaturesErrors(c *C) {
+	oldData, newData, rollbackDir := updateDataSet(c)
+	newData.Info.Volumes["foo"].Structure[1].Update.Edition = 1
+
+	restore := gadget.MockUpdaterForStructure(func(ps *gadget.LaidOutStructure, psRootDir, psRollbackDir string) (gadget.Updater, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil
+	})
+	defer restore()
+	verifier := func(content, signature []byte) error {
+		return nil
+	}
+
+	// missing signature file
+	newData.Info.Volumes["foo"].Structure[1].Content = []gadget.VolumeContent{
+		{Source: "/second-content/foo", Target: "/", Signature: "foo.sig"},
+	}
+	err := gadget.Update(oldData, newData, rollbackDir, nil, verifier)
+	c.Assert(err, ErrorMatches, `cannot update volume structure #1 \("second"\): cannot verify signature of content source:/second-content/foo: open .*/foo.sig: no such file or directory`)
+
+	// the old content was signed, the new one must be too
+	oldData.Info.Volumes["foo"].Structure[1].Content = []gadget.VolumeContent{
+		{Source: "/second-content/foo", Target: "/", Signature: "foo.sig"},
+	}
+	newData.Info.Volumes["foo"].Structure[1].Content = []gadget.VolumeContent{
+		{Source: "/second-content/foo", Target: "/"},
+	}
+	err = gadget.Update(oldData, newData, rollbackDir, nil, verifier)
+	c.Assert(err, ErrorMatches, `cannot update volume structure #1 \("second"\): content source:/second-content/foo is not signed`)
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/snapcore/snapd/asserts/sysdb"
 	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/seed/seedwriter"
@@ -174,8 +175,10 @@ func setupSeed(tsto *ToolingStore, model *asserts.Model, opts *Options) error {
 	}
 
 	// sanity check target
-	if osutil.FileExists(dirs.SnapStateFileUnder(opts.RootDir)) {
-		return fmt.Errorf("cannot prepare seed over existing system or an already booted image, detected state file %s", dirs.SnapStateFileUnder(opts.RootDir))
+	for _, stateFile := range []string{dirs.SnapStateFileUnder(opts.RootDir), dirs.SnapStateDBFileUnder(opts.RootDir)} {
+		if osutil.FileExists(stateFile) {
+			return fmt.Errorf("cannot prepare seed over existing system or an already booted image, detected state file %s", stateFile)
+		}
 	}
 	if snaps, _ := filepath.Glob(filepath.Join(dirs.SnapBlobDirUnder(opts.RootDir), "*.snap")); len(snaps) > 0 {
 		return fmt.Errorf("need an empty snap dir in rootdir, got: %v", snaps)
@@ -251,10 +254,19 @@ func setupSeed(tsto *ToolingStore, model *asserts.Model, opts *Options) error {
 		if err != nil {
 			return err
 		}
-		info, err := snap.ReadInfoFromSnapFile(snapFile, si)
+		info, files, err := snap.ReadInfoAndFilesFromSnapFile(snapFile, si, []string{"meta/gadget.yaml"})
 		if err != nil {
 			return err
 		}
+		if info.GetType() == snap.TypeGadget {
+			constraints := &gadget.ModelConstraints{
+				Classic:    model.Classic(),
+				SystemSeed: model.Grade() != asserts.ModelGradeUnset,
+			}
+			if _, err := gadget.InfoFromSnapFiles(files, constraints); err != nil {
+				return fmt.Errorf("cannot use gadget snap %q: %v", sn.Path, err)
+			}
+		}
 
 		if err := w.SetInfo(sn, info); err != nil {
 			return err
@@ -837,6 +837,22 @@ func (s *imageSuite) TestSetupSeedWithClassicSnapFails(c *C) {
 	c.Assert(err, ErrorMatches, `cannot use classic snap "classic-snap" in a core system`)
 }
 
+func (s *imageSuite) TestSetupSeedExistingState(c *C) {
+	for _, stateFile := range []string{"state.json", "state.db"} {
+		rootdir := filepath.Join(c.MkDir(), "imageroot")
+		statePath := filepath.Join(rootdir, "var/lib/snapd", stateFile)
+		c.Assert(os.MkdirAll(filepath.Dir(statePath), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(statePath, nil, 0600), IsNil)
+
+		opts := &image.Options{
+			RootDir:         rootdir,
+			GadgetUnpackDir: c.MkDir(),
+		}
+		err := image.SetupSeed(s.tsto, s.model, opts)
+		c.Check(err, ErrorMatches, `cannot prepare seed over existing system or an already booted image, detected state file .*/var/lib/snapd/`+stateFile)
+	}
+}
+
 func (s *imageSuite) TestSetupSeedWithBase(c *C) {
 	restore := image.MockTrusted(s.StoreSigning.Trusted)
 	defer restore()
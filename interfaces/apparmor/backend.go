@@ -406,6 +406,12 @@ func (b *Backend) prepareProfiles(snapInfo *snap.Info, opts interfaces.Confineme
 	return &profilePathsResults{changed: changedPaths, removed: removedPaths, unchanged: unchangedPaths}, nil
 }
 
+// ConcurrentSetup returns true as the apparmor profiles of different
+// snaps are written and loaded independently of each other.
+func (b *Backend) ConcurrentSetup() bool {
+	return true
+}
+
 // Setup creates and loads apparmor profiles specific to a given snap.
 // The snap can be in developer mode to make security violations non-fatal to
 // the offending application process.
@@ -505,6 +511,11 @@ func (b *Backend) SetupMany(snaps []*snap.Info, confinement func(snapName string
 	return errors
 }
 
+// InstalledProfiles returns the apparmor profiles of the given snap.
+func (b *Backend) InstalledProfiles(snapName string) (map[string]string, error) {
+	return interfaces.ReadProfiles(dirs.SnapAppArmorDir, profileGlobs(snapName))
+}
+
 // Remove removes and unloads apparmor profiles of a given snap.
 func (b *Backend) Remove(snapName string) error {
 	dir := dirs.SnapAppArmorDir
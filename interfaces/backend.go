@@ -97,10 +97,37 @@ type SecurityBackend interface {
 	SandboxFeatures() []string
 }
 
+// SecurityBackendConcurrentSetup interface may be implemented by backends
+// whose Setup can safely run concurrently for different snaps.
+type SecurityBackendConcurrentSetup interface {
+	// ConcurrentSetup returns whether Setup can be called concurrently
+	// for different snaps.
+	ConcurrentSetup() bool
+}
+
+// SecurityBackendProfiles interface may be implemented by backends that
+// can report the profiles they have installed for a snap.
+type SecurityBackendProfiles interface {
+	// InstalledProfiles returns the content of the profiles of the
+	// given snap as currently installed, indexed by their path.
+	InstalledProfiles(snapName string) (map[string]string, error)
+}
+
 // SecurityBackendSetupMany interface may be implemented by backends that can optimize their operations
 // when setting up multiple snaps at once.
 type SecurityBackendSetupMany interface {
-	// SetupMany creates and loads apparmor profiles of multiple snaps. It tries to process all snaps and doesn't interrupt processing
+	// SetupMany creates and loads security profiles of multiple snaps. It tries to process all snaps and doesn't interrupt processing
 	// on errors of individual snaps.
 	SetupMany(snaps []*snap.Info, confinement func(snapName string) ConfinementOptions, repo *Repository, tm timings.Measurer) []error
 }
+
+// SecurityBackendDeferReload interface may be implemented by backends that
+// reload system-wide state whenever the profiles of a snap change, and can
+// postpone this to do it only once for many snaps.
+type SecurityBackendDeferReload interface {
+	// DeferReload postpones the reloads done when setting up or
+	// removing snaps until the returned function is called.
+	DeferReload() (flush func() error)
+	// Reload reloads the system-wide state right away.
+	Reload() error
+}
@@ -30,6 +30,14 @@ import (
 	"github.com/snapcore/snapd/snap"
 )
 
+// commonFilesInterface is the base of the {personal,system}-files
+// interfaces. Their plugs list the paths they need access to per mode:
+// paths listed in the "read" attribute are only readable while paths
+// listed in the "write" attribute are readable and writable. As the
+// modes are kept in separate attributes, snap declarations can grant
+// (auto-)connection per mode, e.g. allowing broad read access with a
+// "read" constraint while keeping writes tightly scoped with a "write"
+// one, or requiring "write" to be $MISSING.
 type commonFilesInterface struct {
 	commonInterface
 
@@ -152,6 +160,23 @@ func (iface *commonFilesInterface) AppArmorConnectedPlug(spec *apparmor.Specific
 	_ = plug.Attr("read", &reads)
 	_ = plug.Attr("write", &writes)
 
+	// paths that are writable are readable as well, do not repeat them
+	// with the read-only permissions
+	writable := make(map[string]bool, len(writes))
+	for _, p := range writes {
+		if s, ok := p.(string); ok {
+			writable[s] = true
+		}
+	}
+	readOnly := make([]interface{}, 0, len(reads))
+	for _, p := range reads {
+		if s, ok := p.(string); ok && writable[s] {
+			continue
+		}
+		readOnly = append(readOnly, p)
+	}
+	reads = readOnly
+
 	errPrefix := fmt.Sprintf(`cannot connect plug %s: `, plug.Name())
 	buf := bytes.NewBufferString(iface.apparmorHeader)
 	if err := allowPathAccess(buf, filesRead, reads); err != nil {
@@ -0,0 +1,332 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+)
+
+const customDeviceSummary = `provides access to custom devices specified via the gadget snap`
+
+const customDeviceBaseDeclarationSlots = `
+  custom-device:
+    allow-installation:
+      slot-snap-type:
+        - gadget
+    allow-connection:
+      plug-attributes:
+        custom-device: $SLOT(custom-device)
+    deny-auto-connection: true
+`
+
+// customDeviceInterface lets the gadget describe, under a name, the
+// device nodes of a board specific device together with the udev rules
+// identifying them, so that they can be exposed to application snaps.
+//
+// The slot supports the following attributes:
+//   - custom-device: the name of the device, defaults to the slot name
+//   - devices: device nodes that can be read and written
+//   - read-devices: device nodes that can only be read
+//   - udev-tagging: a list of udev matches, each with a mandatory "kernel"
+//     entry and optional "subsystem", "attributes" and "environment" ones,
+//     used instead of the device paths to tag the devices
+type customDeviceInterface struct{}
+
+func (iface *customDeviceInterface) Name() string {
+	return "custom-device"
+}
+
+func (iface *customDeviceInterface) StaticInfo() interfaces.StaticInfo {
+	return interfaces.StaticInfo{
+		Summary:              customDeviceSummary,
+		BaseDeclarationSlots: customDeviceBaseDeclarationSlots,
+	}
+}
+
+var (
+	customDeviceNamePattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	// device paths may use "*" and character classes, but no other
+	// AppArmor globbing
+	customDevicePathPattern    = regexp.MustCompile(`^/dev/([-_.+:a-zA-Z0-9/]|\*|\[[-a-zA-Z0-9]+\])+$`)
+	customDeviceKernelPattern  = regexp.MustCompile(`^([-_.+:a-zA-Z0-9]|\*|\?|\[[-a-zA-Z0-9]+\])+$`)
+	customDeviceAttrKeyPattern = regexp.MustCompile(`^[a-zA-Z0-9_][-_./a-zA-Z0-9]*$`)
+	customDeviceEnvKeyPattern  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+func customDeviceName(attrs map[string]interface{}, defaultName string) (string, error) {
+	name, ok := attrs["custom-device"].(string)
+	if !ok || name == "" {
+		name = defaultName
+	}
+	if !customDeviceNamePattern.MatchString(name) {
+		return "", fmt.Errorf("custom-device name %q is not valid", name)
+	}
+	return name, nil
+}
+
+func (iface *customDeviceInterface) validateDevicePath(path string) error {
+	if filepath.Clean(path) != path {
+		return fmt.Errorf("custom-device path %q is not clean", path)
+	}
+	if !customDevicePathPattern.MatchString(path) || strings.Contains(path, "**") {
+		return fmt.Errorf("custom-device path %q is not a valid device path", path)
+	}
+	return nil
+}
+
+// devicePaths returns the paths listed in the given attribute.
+func (iface *customDeviceInterface) devicePaths(attrs interfaces.Attrer, attr string) ([]string, error) {
+	value, ok := attrs.Lookup(attr)
+	if !ok {
+		return nil, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("custom-device %q attribute must be a list of strings", attr)
+	}
+	paths := make([]string, 0, len(list))
+	for _, item := range list {
+		path, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("custom-device %q attribute must be a list of strings", attr)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func customDeviceStringMap(value interface{}, keyPattern *regexp.Regexp, what string) (map[string]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("custom-device udev-tagging %q must be a map of strings", what)
+	}
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("custom-device udev-tagging %q must be a map of strings", what)
+		}
+		if !keyPattern.MatchString(k) {
+			return nil, fmt.Errorf("custom-device udev-tagging %q has invalid key %q", what, k)
+		}
+		if strings.ContainsAny(s, "\"\n") {
+			return nil, fmt.Errorf("custom-device udev-tagging %q has invalid value %q", what, s)
+		}
+		result[k] = s
+	}
+	return result, nil
+}
+
+// udevMatches returns the udev matches for the devices of the slot,
+// built from the udev-tagging attribute if present or from the device
+// paths otherwise. The udev KERNEL key is only the last component of the
+// device path, so /dev/input/event0 is matched as KERNEL=="event0".
+func (iface *customDeviceInterface) udevMatches(attrs interfaces.Attrer) ([]string, error) {
+	devices, err := iface.devicePaths(attrs, "devices")
+	if err != nil {
+		return nil, err
+	}
+	readDevices, err := iface.devicePaths(attrs, "read-devices")
+	if err != nil {
+		return nil, err
+	}
+	allDevices := append(devices, readDevices...)
+
+	value, ok := attrs.Lookup("udev-tagging")
+	if !ok {
+		matches := make([]string, 0, len(allDevices))
+		for _, path := range allDevices {
+			matches = append(matches, fmt.Sprintf(`KERNEL=="%s"`, filepath.Base(path)))
+		}
+		return matches, nil
+	}
+
+	rules, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf(`custom-device "udev-tagging" attribute must be a list of maps`)
+	}
+	var matches []string
+	for _, item := range rules {
+		rule, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf(`custom-device "udev-tagging" attribute must be a list of maps`)
+		}
+		for key := range rule {
+			switch key {
+			case "kernel", "subsystem", "attributes", "environment":
+			default:
+				return nil, fmt.Errorf("custom-device udev-tagging does not support %q", key)
+			}
+		}
+		kernel, ok := rule["kernel"].(string)
+		if !ok || !customDeviceKernelPattern.MatchString(kernel) {
+			return nil, fmt.Errorf(`custom-device udev-tagging requires a valid "kernel" entry`)
+		}
+		if !iface.declaresKernelName(allDevices, kernel) {
+			return nil, fmt.Errorf("custom-device udev-tagging kernel %q does not match any of the devices", kernel)
+		}
+		match := fmt.Sprintf(`KERNEL=="%s"`, kernel)
+		if subsystem, ok := rule["subsystem"]; ok {
+			s, ok := subsystem.(string)
+			if !ok || !customDeviceKernelPattern.MatchString(s) {
+				return nil, fmt.Errorf(`custom-device udev-tagging "subsystem" must be a valid subsystem name`)
+			}
+			match += fmt.Sprintf(`, SUBSYSTEM=="%s"`, s)
+		}
+		attributes, err := customDeviceStringMap(rule["attributes"], customDeviceAttrKeyPattern, "attributes")
+		if err != nil {
+			return nil, err
+		}
+		environment, err := customDeviceStringMap(rule["environment"], customDeviceEnvKeyPattern, "environment")
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range sortedKeys(attributes) {
+			match += fmt.Sprintf(`, ATTR{%s}=="%s"`, k, attributes[k])
+		}
+		for _, k := range sortedKeys(environment) {
+			match += fmt.Sprintf(`, ENV{%s}=="%s"`, k, environment[k])
+		}
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
+
+// declaresKernelName returns whether the kernel name, possibly a pattern
+// itself, is the last component of any of the device paths.
+func (iface *customDeviceInterface) declaresKernelName(devices []string, kernel string) bool {
+	for _, device := range devices {
+		name := filepath.Base(device)
+		if name == kernel {
+			return true
+		}
+		if matched, _ := filepath.Match(name, kernel); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (iface *customDeviceInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
+	name, err := customDeviceName(slot.Attrs, slot.Name)
+	if err != nil {
+		return err
+	}
+	if slot.Attrs == nil {
+		slot.Attrs = make(map[string]interface{})
+	}
+	slot.Attrs["custom-device"] = name
+
+	devices, err := iface.devicePaths(slot, "devices")
+	if err != nil {
+		return err
+	}
+	readDevices, err := iface.devicePaths(slot, "read-devices")
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 && len(readDevices) == 0 {
+		return fmt.Errorf(`custom-device slot %q must have a "devices" or "read-devices" attribute`, slot.Name)
+	}
+	seen := make(map[string]bool, len(devices)+len(readDevices))
+	for _, path := range append(devices, readDevices...) {
+		if err := iface.validateDevicePath(path); err != nil {
+			return err
+		}
+		if seen[path] {
+			return fmt.Errorf("custom-device path %q is listed more than once", path)
+		}
+		seen[path] = true
+	}
+
+	_, err = iface.udevMatches(slot)
+	return err
+}
+
+func (iface *customDeviceInterface) BeforePreparePlug(plug *snap.PlugInfo) error {
+	name, err := customDeviceName(plug.Attrs, plug.Name)
+	if err != nil {
+		return err
+	}
+	if plug.Attrs == nil {
+		plug.Attrs = make(map[string]interface{})
+	}
+	plug.Attrs["custom-device"] = name
+	return nil
+}
+
+func (iface *customDeviceInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	devices, err := iface.devicePaths(slot, "devices")
+	if err != nil {
+		return err
+	}
+	readDevices, err := iface.devicePaths(slot, "read-devices")
+	if err != nil {
+		return err
+	}
+	for _, path := range devices {
+		spec.AddSnippet(fmt.Sprintf("%q rw,", path))
+	}
+	for _, path := range readDevices {
+		spec.AddSnippet(fmt.Sprintf("%q r,", path))
+	}
+	return nil
+}
+
+func (iface *customDeviceInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	matches, err := iface.udevMatches(slot)
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		spec.TagDevice(match)
+	}
+	return nil
+}
+
+func (iface *customDeviceInterface) AutoConnect(*snap.PlugInfo, *snap.SlotInfo) bool {
+	// Allow what is allowed in the declarations
+	return true
+}
+
+func init() {
+	registerIface(&customDeviceInterface{})
+}
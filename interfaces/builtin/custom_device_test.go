@@ -0,0 +1,245 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type customDeviceInterfaceSuite struct {
+	iface    interfaces.Interface
+	slotInfo *snap.SlotInfo
+	slot     *interfaces.ConnectedSlot
+	plugInfo *snap.PlugInfo
+	plug     *interfaces.ConnectedPlug
+}
+
+var _ = Suite(&customDeviceInterfaceSuite{
+	iface: builtin.MustInterface("custom-device"),
+})
+
+const customDeviceConsumerYaml = `name: consumer
+version: 0
+plugs:
+  board-camera:
+    interface: custom-device
+apps:
+  app:
+    command: foo
+    plugs: [board-camera]
+`
+
+const customDeviceGadgetYaml = `name: gadget
+version: 0
+type: gadget
+slots:
+  camera:
+    interface: custom-device
+    custom-device: board-camera
+    devices:
+      - /dev/video[0-9]
+      - /dev/ispmem
+    read-devices:
+      - /dev/camera-info
+    udev-tagging:
+      - kernel: video[0-9]
+        subsystem: video4linux
+        attributes:
+          name: board-isp
+        environment:
+          ID_BOARD_CAMERA: "1"
+      - kernel: ispmem
+`
+
+func (s *customDeviceInterfaceSuite) SetUpTest(c *C) {
+	s.plug, s.plugInfo = MockConnectedPlug(c, customDeviceConsumerYaml, nil, "board-camera")
+	s.slot, s.slotInfo = MockConnectedSlot(c, customDeviceGadgetYaml, nil, "camera")
+}
+
+func (s *customDeviceInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "custom-device")
+}
+
+func (s *customDeviceInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slotInfo), IsNil)
+	c.Check(s.slotInfo.Attrs["custom-device"], Equals, "board-camera")
+
+	// the name defaults to the slot name
+	slot := MockSlot(c, `name: gadget
+version: 0
+type: gadget
+slots:
+  gpu:
+    interface: custom-device
+    devices: [/dev/gpu0]
+`, nil, "gpu")
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, slot), IsNil)
+	c.Check(slot.Attrs["custom-device"], Equals, "gpu")
+}
+
+func (s *customDeviceInterfaceSuite) TestSanitizeSlotErrors(c *C) {
+	for _, t := range []struct {
+		attrs string
+		err   string
+	}{
+		{``, `custom-device slot "dev" must have a "devices" or "read-devices" attribute`},
+		{`custom-device: Bad_Name
+    devices: [/dev/foo]`, `custom-device name "Bad_Name" is not valid`},
+		{`devices: /dev/foo`, `custom-device "devices" attribute must be a list of strings`},
+		{`read-devices: [1]`, `custom-device "read-devices" attribute must be a list of strings`},
+		{`devices: [/dev/foo/../bar]`, `custom-device path "/dev/foo/../bar" is not clean`},
+		{`devices: [/sys/foo]`, `custom-device path "/sys/foo" is not a valid device path`},
+		{`devices: [/dev/**]`, `custom-device path "/dev/\*\*" is not a valid device path`},
+		{`devices: ["/dev/foo\""]`, `custom-device path "/dev/foo\\"" is not a valid device path`},
+		{`devices: [/dev/foo]
+    read-devices: [/dev/foo]`, `custom-device path "/dev/foo" is listed more than once`},
+		{`devices: [/dev/foo]
+    udev-tagging: foo`, `custom-device "udev-tagging" attribute must be a list of maps`},
+		{`devices: [/dev/foo]
+    udev-tagging:
+      - subsystem: foo`, `custom-device udev-tagging requires a valid "kernel" entry`},
+		{`devices: [/dev/foo]
+    udev-tagging:
+      - kernel: bar`, `custom-device udev-tagging kernel "bar" does not match any of the devices`},
+		{`devices: [/dev/foo]
+    udev-tagging:
+      - kernel: foo
+        action: add`, `custom-device udev-tagging does not support "action"`},
+		{`devices: [/dev/foo]
+    udev-tagging:
+      - kernel: foo
+        attributes:
+          "a b": c`, `custom-device udev-tagging "attributes" has invalid key "a b"`},
+		{`devices: [/dev/foo]
+    udev-tagging:
+      - kernel: foo
+        environment:
+          FOO: "a\"b"`, `custom-device udev-tagging "environment" has invalid value "a\\"b"`},
+	} {
+		slot := MockSlot(c, `name: gadget
+version: 0
+type: gadget
+slots:
+  dev:
+    interface: custom-device
+    `+t.attrs+`
+`, nil, "dev")
+		c.Check(interfaces.BeforePrepareSlot(s.iface, slot), ErrorMatches, t.err, Commentf(t.attrs))
+	}
+}
+
+func (s *customDeviceInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(interfaces.BeforePreparePlug(s.iface, s.plugInfo), IsNil)
+	c.Check(s.plugInfo.Attrs["custom-device"], Equals, "board-camera")
+
+	plug := MockPlug(c, `name: consumer
+version: 0
+plugs:
+  camera:
+    interface: custom-device
+    custom-device: "-bad"
+`, nil, "camera")
+	c.Check(interfaces.BeforePreparePlug(s.iface, plug), ErrorMatches, `custom-device name "-bad" is not valid`)
+}
+
+func (s *customDeviceInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	c.Assert(spec.SnippetForTag("snap.consumer.app"), Equals, ""+
+		"\"/dev/camera-info\" r,\n"+
+		"\"/dev/ispmem\" rw,\n"+
+		"\"/dev/video[0-9]\" rw,")
+}
+
+func (s *customDeviceInterfaceSuite) TestUDevSpec(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 3)
+	c.Assert(spec.Snippets(), testutil.Contains, `# custom-device
+KERNEL=="video[0-9]", SUBSYSTEM=="video4linux", ATTR{name}=="board-isp", ENV{ID_BOARD_CAMERA}=="1", TAG+="snap_consumer_app"`)
+	c.Assert(spec.Snippets(), testutil.Contains, `# custom-device
+KERNEL=="ispmem", TAG+="snap_consumer_app"`)
+	c.Assert(spec.Snippets(), testutil.Contains, `TAG=="snap_consumer_app", RUN+="/usr/lib/snapd/snap-device-helper $env{ACTION} snap_consumer_app $devpath $major:$minor"`)
+}
+
+func (s *customDeviceInterfaceSuite) TestUDevSpecFromDevices(c *C) {
+	slot, _ := MockConnectedSlot(c, `name: gadget
+version: 0
+type: gadget
+slots:
+  camera:
+    interface: custom-device
+    custom-device: board-camera
+    devices: [/dev/video0]
+    read-devices: [/dev/input/event*]
+`, nil, "camera")
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Assert(spec.Snippets(), testutil.Contains, `# custom-device
+KERNEL=="video0", TAG+="snap_consumer_app"`)
+	c.Assert(spec.Snippets(), testutil.Contains, `# custom-device
+KERNEL=="event*", TAG+="snap_consumer_app"`)
+}
+
+func (s *customDeviceInterfaceSuite) TestUDevSpecNestedDevices(c *C) {
+	slot, _ := MockConnectedSlot(c, `name: gadget
+version: 0
+type: gadget
+slots:
+  gpu:
+    interface: custom-device
+    custom-device: board-gpu
+    devices: [/dev/dri/card0, /dev/dri/renderD128]
+    udev-tagging:
+      - kernel: card0
+        subsystem: drm
+`, nil, "gpu")
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Assert(spec.Snippets(), testutil.Contains, `# custom-device
+KERNEL=="card0", SUBSYSTEM=="drm", TAG+="snap_consumer_app"`)
+	for _, snippet := range spec.Snippets() {
+		c.Check(snippet, Not(testutil.Contains), "dri/")
+	}
+}
+
+func (s *customDeviceInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, false)
+	c.Assert(si.ImplicitOnClassic, Equals, false)
+	c.Assert(si.Summary, Equals, "provides access to custom devices specified via the gadget snap")
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "custom-device")
+}
+
+func (s *customDeviceInterfaceSuite) TestAutoConnect(c *C) {
+	c.Check(s.iface.AutoConnect(nil, nil), Equals, true)
+}
+
+func (s *customDeviceInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
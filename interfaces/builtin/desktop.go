@@ -27,6 +27,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/mount"
+	"github.com/snapcore/snapd/interfaces/portal"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
@@ -236,7 +237,7 @@ func (iface *desktopInterface) AppArmorConnectedPlug(spec *apparmor.Specificatio
 	// Allow mounting document portal
 	var buf bytes.Buffer
 	fmt.Fprintf(&buf, "  # Mount the document portal\n")
-	fmt.Fprintf(&buf, "  mount options=(bind) /run/user/[0-9]*/doc/by-app/snap.%s/ -> /run/user/[0-9]*/doc/,\n", plug.Snap().InstanceName())
+	fmt.Fprintf(&buf, "  mount options=(bind) %s/ -> %s/,\n", portal.AppDocumentsDir("/run/user/[0-9]*", plug.Snap().InstanceName()), portal.DocumentsDir("/run/user/[0-9]*"))
 	fmt.Fprintf(&buf, "  umount /run/user/[0-9]*/doc/,\n\n")
 	spec.AddUpdateNS(buf.String())
 
@@ -261,10 +262,9 @@ func (iface *desktopInterface) AppArmorConnectedPlug(spec *apparmor.Specificatio
 }
 
 func (iface *desktopInterface) MountConnectedPlug(spec *mount.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
-	appId := "snap." + plug.Snap().InstanceName()
 	spec.AddUserMountEntry(osutil.MountEntry{
-		Name:    "$XDG_RUNTIME_DIR/doc/by-app/" + appId,
-		Dir:     "$XDG_RUNTIME_DIR/doc",
+		Name:    portal.AppDocumentsDir("$XDG_RUNTIME_DIR", plug.Snap().InstanceName()),
+		Dir:     portal.DocumentsDir("$XDG_RUNTIME_DIR"),
 		Options: []string{"bind", "rw", osutil.XSnapdIgnoreMissing()},
 	})
 
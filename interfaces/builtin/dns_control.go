@@ -0,0 +1,85 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+const dnsControlSummary = `allows control over the DNS configuration of the system`
+
+const dnsControlBaseDeclarationSlots = `
+  dns-control:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const dnsControlConnectedPlugAppArmor = `
+# Description: Can configure the DNS servers and search domains used by
+# systemd-resolved, per link and globally.
+
+#include <abstractions/dbus-strict>
+
+dbus (send)
+    bus=system
+    path="/org/freedesktop/resolve1"
+    interface="org.freedesktop.resolve1.Manager"
+    member="{SetLink*,RevertLink,FlushCaches,ResetServerFeatures,ResetStatistics}"
+    peer=(label=unconfined),
+
+dbus (send)
+    bus=system
+    path="/org/freedesktop/resolve1/link/*"
+    interface="org.freedesktop.resolve1.Link"
+    member="{Set*,Revert}"
+    peer=(label=unconfined),
+
+dbus (send)
+    bus=system
+    path="/org/freedesktop/resolve1{,/link/*}"
+    interface="org.freedesktop.DBus.Properties"
+    member="Get{,All}"
+    peer=(label=unconfined),
+
+/{,usr/}bin/resolvectl ixr,
+/{,usr/}bin/systemd-resolve ixr,
+
+# the resulting configuration
+/etc/resolv.conf r,
+/run/systemd/resolve/{,*} r,
+`
+
+const dnsControlConnectedPlugSecComp = `
+# Description: Can configure the DNS servers and search domains used by
+# systemd-resolved. resolvectl looks up links by name over netlink.
+bind
+socket AF_NETLINK - NETLINK_ROUTE
+`
+
+func init() {
+	registerIface(&commonInterface{
+		name:                  "dns-control",
+		summary:               dnsControlSummary,
+		implicitOnCore:        true,
+		implicitOnClassic:     true,
+		baseDeclarationSlots:  dnsControlBaseDeclarationSlots,
+		connectedPlugAppArmor: dnsControlConnectedPlugAppArmor,
+		connectedPlugSecComp:  dnsControlConnectedPlugSecComp,
+		reservedForOS:         true,
+	})
+}
@@ -0,0 +1,81 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+const firewallControlNftSummary = `allows control over the nftables network firewall`
+
+const firewallControlNftBaseDeclarationSlots = `
+  firewall-control-nft:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const firewallControlNftConnectedPlugAppArmor = `
+# Description: Can configure the nftables firewall. Unlike firewall-control
+# this does not grant the use of the iptables tools, raw sockets nor the
+# tuning of the network stack.
+
+capability net_admin,
+
+network netlink raw,
+
+/{,usr/}{,s}bin/nft ixr,
+
+# the default ruleset and the files it includes
+/etc/nftables.conf r,
+/etc/nftables/{,**} r,
+
+# nft accesses these for routing expressions and device groups
+/etc/iproute2/ r,
+/etc/iproute2/rt_marks r,
+/etc/iproute2/rt_realms r,
+/etc/iproute2/group r,
+
+# check the state of the nftables kernel modules
+/sys/module/nf_tables/               r,
+/sys/module/nf_tables/initstate      r,
+`
+
+const firewallControlNftConnectedPlugSecComp = `
+# Description: Can configure the nftables firewall.
+bind
+socket AF_NETLINK - NETLINK_NETFILTER
+`
+
+// nf_tables is not auto-loaded on all systems
+var firewallControlNftConnectedPlugKmod = []string{
+	"nf_tables",
+}
+
+func init() {
+	registerIface(&commonInterface{
+		name:                     "firewall-control-nft",
+		summary:                  firewallControlNftSummary,
+		implicitOnCore:           true,
+		implicitOnClassic:        true,
+		baseDeclarationSlots:     firewallControlNftBaseDeclarationSlots,
+		connectedPlugAppArmor:    firewallControlNftConnectedPlugAppArmor,
+		connectedPlugSecComp:     firewallControlNftConnectedPlugSecComp,
+		connectedPlugKModModules: firewallControlNftConnectedPlugKmod,
+		reservedForOS:            true,
+	})
+}
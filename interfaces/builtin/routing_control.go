@@ -0,0 +1,81 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+const routingControlSummary = `allows control over network routing`
+
+const routingControlBaseDeclarationSlots = `
+  routing-control:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const routingControlConnectedPlugAppArmor = `
+# Description: Can configure the routing tables and routing policy rules
+# of the network stack, without the broader access of network-control.
+
+capability net_admin,
+
+network netlink raw,
+
+/{,usr/}{,s}bin/ip ixr,
+
+# routing tables and realms names
+/etc/iproute2/ r,
+/etc/iproute2/rt_tables r,
+/etc/iproute2/rt_tables.d/{,*} r,
+/etc/iproute2/rt_protos r,
+/etc/iproute2/rt_protos.d/{,*} r,
+/etc/iproute2/rt_realms r,
+/etc/iproute2/rt_scopes r,
+
+@{PROC}/@{pid}/net/route r,
+@{PROC}/@{pid}/net/ipv6_route r,
+@{PROC}/@{pid}/net/fib_trie r,
+
+# forwarding
+@{PROC}/sys/net/ipv4/ip_forward rw,
+@{PROC}/sys/net/ipv4/conf/*/forwarding rw,
+@{PROC}/sys/net/ipv6/conf/*/forwarding rw,
+@{PROC}/sys/net/ipv4/route/flush w,
+@{PROC}/sys/net/ipv6/route/flush w,
+`
+
+const routingControlConnectedPlugSecComp = `
+# Description: Can configure the routing tables and routing policy rules
+# of the network stack.
+bind
+socket AF_NETLINK - NETLINK_ROUTE
+`
+
+func init() {
+	registerIface(&commonInterface{
+		name:                  "routing-control",
+		summary:               routingControlSummary,
+		implicitOnCore:        true,
+		implicitOnClassic:     true,
+		baseDeclarationSlots:  routingControlBaseDeclarationSlots,
+		connectedPlugAppArmor: routingControlConnectedPlugAppArmor,
+		connectedPlugSecComp:  routingControlConnectedPlugSecComp,
+		reservedForOS:         true,
+	})
+}
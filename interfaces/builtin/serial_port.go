@@ -20,9 +20,11 @@
 package builtin
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -79,6 +81,11 @@ var serialDeviceNodePattern = regexp.MustCompile("^/dev/tty(mxc|USB|ACM|AMA|XRUS
 // are also specified
 var serialUDevSymlinkPattern = regexp.MustCompile("^/dev/serial-port-[a-z0-9]+$")
 
+// Patterns of the names and values of the additional udev properties a
+// device must have to be matched by a slot with usb vid and pid
+var serialUDevPropertyPattern = regexp.MustCompile("^[A-Z][A-Z0-9_]*$")
+var serialUDevPropertyValuePattern = regexp.MustCompile(`^[a-zA-Z0-9_.:/+-]+$`)
+
 // BeforePrepareSlot checks validity of the defined slot
 func (iface *serialPortInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
 	// Check slot has a path attribute identify serial device
@@ -117,6 +124,24 @@ func (iface *serialPortInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
 		if ok && (usbInterfaceNumber < 0 || usbInterfaceNumber >= UsbMaxInterfaces) {
 			return fmt.Errorf("serial-port usb-interface-number attribute cannot be negative or larger than %d", UsbMaxInterfaces-1)
 		}
+
+		if attr, ok := slot.Attrs["udev-properties"]; ok {
+			props, ok := attr.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("serial-port udev-properties attribute must be a map of strings")
+			}
+			for key, value := range props {
+				if !serialUDevPropertyPattern.MatchString(key) {
+					return fmt.Errorf("serial-port udev-properties attribute has invalid property name %q", key)
+				}
+				s, ok := value.(string)
+				if !ok || !serialUDevPropertyValuePattern.MatchString(s) {
+					return fmt.Errorf("serial-port udev-properties attribute has invalid value of property %q", key)
+				}
+			}
+		}
+	} else if _, ok := slot.Attrs["udev-properties"]; ok {
+		return fmt.Errorf("serial-port udev-properties attribute requires usb-vendor and usb-product attributes")
 	} else {
 		// Just a path attribute - must be a valid usb device node
 		// Check the path attribute is in the allowable pattern
@@ -128,7 +153,7 @@ func (iface *serialPortInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
 }
 
 func (iface *serialPortInterface) UDevPermanentSlot(spec *udev.Specification, slot *snap.SlotInfo) error {
-	var usbVendor, usbProduct, usbInterfaceNumber int64
+	var usbVendor, usbProduct int64
 	var path string
 	if err := slot.Attr("usb-vendor", &usbVendor); err != nil {
 		return nil
@@ -139,15 +164,9 @@ func (iface *serialPortInterface) UDevPermanentSlot(spec *udev.Specification, sl
 	if err := slot.Attr("path", &path); err != nil || path == "" {
 		return nil
 	}
-	if err := slot.Attr("usb-interface-number", &usbInterfaceNumber); err == nil {
-		spec.AddSnippet(fmt.Sprintf(`# serial-port
-IMPORT{builtin}="usb_id"
-SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="%04x", ATTRS{idProduct}=="%04x", ENV{ID_USB_INTERFACE_NUM}=="%02x", SYMLINK+="%s"`, usbVendor, usbProduct, usbInterfaceNumber, strings.TrimPrefix(path, "/dev/")))
-	} else {
-		spec.AddSnippet(fmt.Sprintf(`# serial-port
+	spec.AddSnippet(fmt.Sprintf(`# serial-port
 IMPORT{builtin}="usb_id"
-SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="%04x", ATTRS{idProduct}=="%04x", SYMLINK+="%s"`, usbVendor, usbProduct, strings.TrimPrefix(path, "/dev/")))
-	}
+%s, SYMLINK+="%s"`, serialPortUSBMatch(slot, usbVendor, usbProduct), strings.TrimPrefix(path, "/dev/")))
 	return nil
 }
 
@@ -189,18 +208,48 @@ func (iface *serialPortInterface) UDevConnectedPlug(spec *udev.Specification, pl
 	if hasOnlyPath {
 		spec.TagDevice(fmt.Sprintf(`SUBSYSTEM=="tty", KERNEL=="%s"`, strings.TrimPrefix(path, "/dev/")))
 	} else {
-		var usbInterfaceNumber int64
-		if err := slot.Attr("usb-interface-number", &usbInterfaceNumber); err == nil {
-			spec.TagDevice(fmt.Sprintf(`IMPORT{builtin}="usb_id"
-SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="%04x", ATTRS{idProduct}=="%04x", ENV{ID_USB_INTERFACE_NUM}=="%02x"`, usbVendor, usbProduct, usbInterfaceNumber))
-		} else {
-			spec.TagDevice(fmt.Sprintf(`IMPORT{builtin}="usb_id"
-SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="%04x", ATTRS{idProduct}=="%04x"`, usbVendor, usbProduct))
-		}
+		spec.TagDevice(fmt.Sprintf(`IMPORT{builtin}="usb_id"
+%s`, serialPortUSBMatch(slot, usbVendor, usbProduct)))
 	}
 	return nil
 }
 
+// serialPortUSBMatch returns the udev match keys selecting the USB serial
+// device described by the slot.
+func serialPortUSBMatch(slot interfaces.Attrer, usbVendor, usbProduct int64) string {
+	match := fmt.Sprintf(`SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="%04x", ATTRS{idProduct}=="%04x"`, usbVendor, usbProduct)
+	var usbInterfaceNumber int64
+	if err := slot.Attr("usb-interface-number", &usbInterfaceNumber); err == nil {
+		match += fmt.Sprintf(`, ENV{ID_USB_INTERFACE_NUM}=="%02x"`, usbInterfaceNumber)
+	}
+	props := serialPortUDevProperties(slot)
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		match += fmt.Sprintf(`, ENV{%s}=="%s"`, key, props[key])
+	}
+	return match
+}
+
+// serialPortUDevProperties returns the additional udev properties the
+// device must have, as declared with the udev-properties attribute.
+func serialPortUDevProperties(slot interfaces.Attrer) map[string]string {
+	var attr map[string]interface{}
+	if err := slot.Attr("udev-properties", &attr); err != nil {
+		return nil
+	}
+	props := make(map[string]string, len(attr))
+	for key, value := range attr {
+		if s, ok := value.(string); ok {
+			props[key] = s
+		}
+	}
+	return props
+}
+
 func (iface *serialPortInterface) AutoConnect(*snap.PlugInfo, *snap.SlotInfo) bool {
 	// allow what declarations allowed
 	return true
@@ -226,6 +275,41 @@ func (iface *serialPortInterface) HotplugDeviceDetected(di *hotplug.HotplugDevic
 	return &slot, nil
 }
 
+// serialPortHotplugKeyAttrs are the attributes identifying a port of an USB
+// serial adapter. The interface number tells apart the ports of adapters
+// that expose several of them under the same serial number.
+// Warning, any future changes to these attributes require a new key version.
+var serialPortHotplugKeyAttrs = []string{"ID_VENDOR_ID", "ID_MODEL_ID", "ID_SERIAL", "ID_USB_INTERFACE_NUM"}
+
+// serialPortHotplugKeyVersion is the version of the keys computed from
+// serialPortHotplugKeyAttrs. Slots created with the default key of the
+// hotplug subsystem, before serial-port provided its own keys, keep using it.
+const serialPortHotplugKeyVersion = 1
+
+// HotplugKey returns the key of an USB serial port that is stable across
+// re-plugging of multi-port adapters. Other devices use the default key.
+// The key has the format serial-port:<version><checksum>.
+func (iface *serialPortInterface) HotplugKey(di *hotplug.HotplugDeviceInfo) (snap.HotplugKey, error) {
+	if bus, _ := di.Attribute("ID_BUS"); bus != "usb" {
+		return "", nil
+	}
+	if _, ok := di.Attribute("ID_USB_INTERFACE_NUM"); !ok {
+		return "", nil
+	}
+	key := sha256.New()
+	for _, attr := range serialPortHotplugKeyAttrs {
+		val, ok := di.Attribute(attr)
+		if !ok || val == "" {
+			return "", nil
+		}
+		key.Write([]byte(attr))
+		key.Write([]byte{0})
+		key.Write([]byte(val))
+		key.Write([]byte{0})
+	}
+	return snap.HotplugKey(fmt.Sprintf("serial-port:%x%x", serialPortHotplugKeyVersion, key.Sum(nil))), nil
+}
+
 func slotDeviceAttrEqual(di *hotplug.HotplugDeviceInfo, devinfoAttribute string, slotAttributeValue int64) bool {
 	var attr string
 	var ok bool
@@ -254,6 +338,11 @@ func (iface *serialPortInterface) HandledByGadget(di *hotplug.HotplugDeviceInfo,
 				return false
 			}
 		}
+		for key, value := range serialPortUDevProperties(slot) {
+			if attr, ok := di.Attribute(key); !ok || attr != value {
+				return false
+			}
+		}
 		return true
 	}
 
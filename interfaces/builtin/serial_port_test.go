@@ -20,6 +20,8 @@
 package builtin_test
 
 import (
+	"fmt"
+
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/interfaces"
@@ -602,6 +604,134 @@ func (s *SerialPortInterfaceSuite) TestHotplugHandledByGadget(c *C) {
 	c.Assert(byGadgetPred.HandledByGadget(di, s.testUDev2Info), Equals, true)
 }
 
+const serialPortUDevPropertiesGadgetYaml = `
+name: some-device
+version: 0
+type: gadget
+slots:
+  port-a:
+    interface: serial-port
+    usb-vendor: 0x0403
+    usb-product: 0x6011
+    usb-interface-number: 1
+    udev-properties:
+      ID_SERIAL_SHORT: FT4ABC
+      ID_USB_DRIVER: ftdi_sio
+    path: /dev/serial-port-a
+apps:
+  app-accessing-port:
+    command: foo
+    plugs: [serial-port]
+`
+
+func (s *SerialPortInterfaceSuite) TestUDevPropertiesSnippets(c *C) {
+	info := snaptest.MockInfo(c, serialPortUDevPropertiesGadgetYaml, nil)
+	slotInfo := info.Slots["port-a"]
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, slotInfo), IsNil)
+
+	spec := &udev.Specification{}
+	c.Assert(spec.AddPermanentSlot(s.iface, slotInfo), IsNil)
+	c.Assert(spec.Snippets(), DeepEquals, []string{`# serial-port
+IMPORT{builtin}="usb_id"
+SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="0403", ATTRS{idProduct}=="6011", ENV{ID_USB_INTERFACE_NUM}=="01", ENV{ID_SERIAL_SHORT}=="FT4ABC", ENV{ID_USB_DRIVER}=="ftdi_sio", SYMLINK+="serial-port-a"`})
+
+	spec = &udev.Specification{}
+	slot := interfaces.NewConnectedSlot(slotInfo, nil, nil)
+	c.Assert(spec.AddConnectedPlug(s.iface, s.testPlugPort1, slot), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 2)
+	c.Check(spec.Snippets()[0], Equals, `# serial-port
+IMPORT{builtin}="usb_id"
+SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="0403", ATTRS{idProduct}=="6011", ENV{ID_USB_INTERFACE_NUM}=="01", ENV{ID_SERIAL_SHORT}=="FT4ABC", ENV{ID_USB_DRIVER}=="ftdi_sio", TAG+="snap_client-snap_app-accessing-2-ports"`)
+}
+
+func (s *SerialPortInterfaceSuite) TestUDevPropertiesHandledByGadget(c *C) {
+	info := snaptest.MockInfo(c, serialPortUDevPropertiesGadgetYaml, nil)
+	slotInfo := info.Slots["port-a"]
+	byGadgetPred := s.iface.(hotplug.HandledByGadgetPredicate)
+
+	props := map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/ttyUSB1", "ID_VENDOR_ID": "0403", "ID_MODEL_ID": "6011", "ID_USB_INTERFACE_NUM": "01", "ID_SERIAL_SHORT": "FT4ABC", "ID_USB_DRIVER": "ftdi_sio", "ACTION": "add", "SUBSYSTEM": "tty", "ID_BUS": "usb"}
+	di, err := hotplug.NewHotplugDeviceInfo(props)
+	c.Assert(err, IsNil)
+	c.Check(byGadgetPred.HandledByGadget(di, slotInfo), Equals, true)
+
+	// another adapter of the same model
+	props["ID_SERIAL_SHORT"] = "FT4XYZ"
+	di, err = hotplug.NewHotplugDeviceInfo(props)
+	c.Assert(err, IsNil)
+	c.Check(byGadgetPred.HandledByGadget(di, slotInfo), Equals, false)
+
+	delete(props, "ID_SERIAL_SHORT")
+	di, err = hotplug.NewHotplugDeviceInfo(props)
+	c.Assert(err, IsNil)
+	c.Check(byGadgetPred.HandledByGadget(di, slotInfo), Equals, false)
+}
+
+func (s *SerialPortInterfaceSuite) TestSanitizeBadUDevProperties(c *C) {
+	for _, t := range []struct {
+		attrs string
+		err   string
+	}{
+		{"udev-properties: foo", `serial-port udev-properties attribute must be a map of strings`},
+		{"udev-properties: {id_serial: foo}", `serial-port udev-properties attribute has invalid property name "id_serial"`},
+		{"udev-properties: {ID_SERIAL: 'foo\"'}", `serial-port udev-properties attribute has invalid value of property "ID_SERIAL"`},
+		{"udev-properties: {ID_SERIAL: [foo]}", `serial-port udev-properties attribute has invalid value of property "ID_SERIAL"`},
+	} {
+		info := snaptest.MockInfo(c, fmt.Sprintf(`
+name: some-device
+version: 0
+type: gadget
+slots:
+  port:
+    interface: serial-port
+    usb-vendor: 0x0403
+    usb-product: 0x6011
+    path: /dev/serial-port-a
+    %s
+`, t.attrs), nil)
+		c.Check(interfaces.BeforePrepareSlot(s.iface, info.Slots["port"]), ErrorMatches, t.err, Commentf(t.attrs))
+	}
+
+	info := snaptest.MockInfo(c, `
+name: some-device
+version: 0
+type: gadget
+slots:
+  port:
+    interface: serial-port
+    path: /dev/ttyUSB0
+    udev-properties: {ID_SERIAL: foo}
+`, nil)
+	c.Check(interfaces.BeforePrepareSlot(s.iface, info.Slots["port"]), ErrorMatches, `serial-port udev-properties attribute requires usb-vendor and usb-product attributes`)
+}
+
+func (s *SerialPortInterfaceSuite) TestHotplugKey(c *C) {
+	keyHandler := s.iface.(hotplug.HotplugKeyHandler)
+
+	port := func(ifaceNum string) *hotplug.HotplugDeviceInfo {
+		di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/ttyUSB0", "ID_VENDOR_ID": "0403", "ID_MODEL_ID": "6011", "ID_SERIAL": "FTDI_Quad_RS232-HS_FT4ABC", "ID_USB_INTERFACE_NUM": ifaceNum, "ACTION": "add", "SUBSYSTEM": "tty", "ID_BUS": "usb"})
+		c.Assert(err, IsNil)
+		return di
+	}
+
+	key0, err := keyHandler.HotplugKey(port("00"))
+	c.Assert(err, IsNil)
+	c.Check(string(key0), Matches, "serial-port:1[0-9a-f]{64}")
+	key1, err := keyHandler.HotplugKey(port("01"))
+	c.Assert(err, IsNil)
+	c.Check(key1, Not(Equals), key0)
+	// the key is stable
+	again, err := keyHandler.HotplugKey(port("00"))
+	c.Assert(err, IsNil)
+	c.Check(again, Equals, key0)
+
+	// devices without an interface number use the default key
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/ttyUSB0", "ID_VENDOR_ID": "0403", "ID_MODEL_ID": "6001", "ACTION": "add", "SUBSYSTEM": "tty", "ID_BUS": "usb"})
+	c.Assert(err, IsNil)
+	key, err := keyHandler.HotplugKey(di)
+	c.Assert(err, IsNil)
+	c.Check(key, Equals, snap.HotplugKey(""))
+}
+
 func (s *SerialPortInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
@@ -86,6 +86,33 @@ func (s *systemFilesInterfaceSuite) TestConnectedPlugAppArmor(c *C) {
 `)
 }
 
+func (s *systemFilesInterfaceSuite) TestConnectedPlugAppArmorReadAndWrite(c *C) {
+	const mockPlugSnapInfo = `name: other
+version: 1.0
+plugs:
+ system-files:
+  read: [/etc/foo, /etc/bar]
+  write: [/etc/foo]
+apps:
+ app:
+  command: foo
+  plugs: [system-files]
+`
+	plugSnap := snaptest.MockInfo(c, mockPlugSnapInfo, nil)
+	plug := interfaces.NewConnectedPlug(plugSnap.Plugs["system-files"], nil, nil)
+
+	apparmorSpec := &apparmor.Specification{}
+	err := apparmorSpec.AddConnectedPlug(s.iface, plug, s.slot)
+	c.Assert(err, IsNil)
+	// paths listed in both modes only get the write permissions
+	c.Check(apparmorSpec.SnippetForTag("snap.other.app"), Equals, `
+# Description: Can access specific system files or directories.
+# This is restricted because it gives file access to arbitrary locations.
+"/etc/bar{,/,/**}" rk,
+"/etc/foo{,/,/**}" rwkl,
+`)
+}
+
 func (s *systemFilesInterfaceSuite) TestSanitizeSlot(c *C) {
 	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slotInfo), IsNil)
 }
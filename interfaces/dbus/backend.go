@@ -35,6 +35,7 @@ import (
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/portal"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/release"
@@ -56,7 +57,9 @@ func (b *Backend) Name() interfaces.SecuritySystem {
 }
 
 // setupDbusServiceForUserd will setup the service file for the new
-// `snap userd` instance on re-exec
+// `snap userd` instance on re-exec, along with the bus configuration that
+// lets the session and system buses find the service activation files of
+// snaps
 func setupDbusServiceForUserd(snapInfo *snap.Info) error {
 	coreOrSnapdRoot := snapInfo.MountDir()
 
@@ -70,16 +73,28 @@ func setupDbusServiceForUserd(snapInfo *snap.Info) error {
 		return nil
 	}
 
-	for _, srv := range []string{
-		"io.snapcraft.Launcher.service",
-		"io.snapcraft.Settings.service",
+	for _, f := range []struct {
+		path     string
+		optional bool
+	}{
+		{path: "/usr/share/dbus-1/services/io.snapcraft.Launcher.service"},
+		{path: "/usr/share/dbus-1/services/io.snapcraft.Settings.service"},
+		// older core and snapd snaps do not carry the bus
+		// configuration yet
+		{path: "/usr/share/dbus-1/session.d/snapd.session-services.conf", optional: true},
+		{path: "/usr/share/dbus-1/system.d/snapd.system-services.conf", optional: true},
 	} {
-		dst := filepath.Join("/usr/share/dbus-1/services/", srv)
-		src := filepath.Join(coreOrSnapdRoot, dst)
+		src := filepath.Join(coreOrSnapdRoot, f.path)
+		if f.optional && !osutil.FileExists(src) {
+			continue
+		}
 
 		// we only need the GlobalRootDir for testing
-		dst = filepath.Join(dirs.GlobalRootDir, dst)
+		dst := filepath.Join(dirs.GlobalRootDir, f.path)
 		if !osutil.FilesAreEqual(src, dst) {
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				return err
+			}
 			if err := osutil.CopyFile(src, dst, osutil.CopyFlagPreserveAll); err != nil {
 				return err
 			}
@@ -88,6 +103,12 @@ func setupDbusServiceForUserd(snapInfo *snap.Info) error {
 	return nil
 }
 
+// ConcurrentSetup returns true, the dbus configuration files are
+// written per snap.
+func (b *Backend) ConcurrentSetup() bool {
+	return true
+}
+
 // Setup creates dbus configuration files specific to a given snap.
 //
 // DBus has no concept of a complain mode so confinment type is ignored.
@@ -123,6 +144,11 @@ func (b *Backend) Setup(snapInfo *snap.Info, opts interfaces.ConfinementOptions,
 	return nil
 }
 
+// InstalledProfiles returns the dbus configuration files of the given snap.
+func (b *Backend) InstalledProfiles(snapName string) (map[string]string, error) {
+	return interfaces.ReadProfiles(dirs.SnapBusPolicyDir, []string{fmt.Sprintf("%s.conf", interfaces.SecurityTagGlob(snapName))})
+}
+
 // Remove removes dbus configuration files of a given snap.
 //
 // This method should be called after removing a snap.
@@ -183,7 +209,8 @@ func (b *Backend) NewSpecification() interfaces.Specification {
 	return &Specification{}
 }
 
-// SandboxFeatures returns list of features supported by snapd for dbus communication.
+// SandboxFeatures returns list of features supported by snapd for dbus
+// communication, including the desktop portals available to snaps.
 func (b *Backend) SandboxFeatures() []string {
-	return []string{"mediated-bus-access"}
+	return append([]string{"mediated-bus-access"}, portal.SandboxFeatures()...)
 }
@@ -280,6 +280,12 @@ func (s *backendSuite) TestAppBoundIfaces(c *C) {
 
 func (s *backendSuite) TestSandboxFeatures(c *C) {
 	c.Assert(s.Backend.SandboxFeatures(), DeepEquals, []string{"mediated-bus-access"})
+
+	// available portals are reported too
+	dir := filepath.Join(dirs.GlobalRootDir, "/usr/share/dbus-1/services")
+	c.Assert(os.MkdirAll(dir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "org.freedesktop.portal.Documents.service"), nil, 0644), IsNil)
+	c.Assert(s.Backend.SandboxFeatures(), DeepEquals, []string{"mediated-bus-access", "document-portal"})
 }
 
 func makeFakeDbusUserdServiceFiles(c *C, coreOrSnapdSnap *snap.Info) {
@@ -300,6 +306,18 @@ func makeFakeDbusUserdServiceFiles(c *C, coreOrSnapdSnap *snap.Info) {
 	}
 }
 
+func makeFakeDbusServiceDirConfigs(c *C, coreOrSnapdSnap *snap.Info) {
+	for _, fn := range []string{
+		"/usr/share/dbus-1/session.d/snapd.session-services.conf",
+		"/usr/share/dbus-1/system.d/snapd.system-services.conf",
+	} {
+		path := filepath.Join(coreOrSnapdSnap.MountDir(), fn)
+		c.Assert(os.MkdirAll(filepath.Dir(path), 0755), IsNil)
+		content := fmt.Sprintf("content of %s for snap %s", filepath.Base(fn), coreOrSnapdSnap.InstanceName())
+		c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+	}
+}
+
 func (s *backendSuite) testSetupWritesUsedFilesForCoreOrSnapd(c *C, coreOrSnapdYaml string) {
 	coreOrSnapdInfo := snaptest.MockInfo(c, coreOrSnapdYaml, &snap.SideInfo{Revision: snap.R(2)})
 	makeFakeDbusUserdServiceFiles(c, coreOrSnapdInfo)
@@ -313,6 +331,8 @@ func (s *backendSuite) testSetupWritesUsedFilesForCoreOrSnapd(c *C, coreOrSnapdY
 	} {
 		c.Assert(filepath.Join(dirs.GlobalRootDir, "/usr/share/dbus-1/services/"+fn), testutil.FilePresent)
 	}
+	// the snap does not carry the bus configuration
+	c.Check(filepath.Join(dirs.GlobalRootDir, "/usr/share/dbus-1/session.d"), testutil.FileAbsent)
 }
 
 var (
@@ -328,6 +348,18 @@ func (s *backendSuite) TestSetupWritesUsedFilesForSnapd(c *C) {
 	s.testSetupWritesUsedFilesForCoreOrSnapd(c, snapdYaml)
 }
 
+func (s *backendSuite) TestSetupWritesServiceDirConfigs(c *C) {
+	snapdInfo := snaptest.MockInfo(c, snapdYaml, &snap.SideInfo{Revision: snap.R(3)})
+	makeFakeDbusUserdServiceFiles(c, snapdInfo)
+	makeFakeDbusServiceDirConfigs(c, snapdInfo)
+
+	err := s.Backend.Setup(snapdInfo, interfaces.ConfinementOptions{}, s.Repo, nil)
+	c.Assert(err, IsNil)
+
+	c.Check(filepath.Join(dirs.GlobalRootDir, "/usr/share/dbus-1/session.d/snapd.session-services.conf"), testutil.FileEquals, "content of snapd.session-services.conf for snap snapd")
+	c.Check(filepath.Join(dirs.GlobalRootDir, "/usr/share/dbus-1/system.d/snapd.system-services.conf"), testutil.FileEquals, "content of snapd.system-services.conf for snap snapd")
+}
+
 func (s *backendSuite) TestSetupWritesUsedFilesBothSnapdAndCoreInstalled(c *C) {
 	err := os.MkdirAll(filepath.Join(dirs.SnapMountDir, "snapd/current"), 0755)
 	c.Assert(err, IsNil)
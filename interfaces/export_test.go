@@ -60,6 +60,14 @@ func MockReadBuildID(mock func(p string) (string, error)) (restore func()) {
 	}
 }
 
+func MockSetupConcurrency(n int) (restore func()) {
+	old := setupConcurrency
+	setupConcurrency = func() int { return n }
+	return func() {
+		setupConcurrency = old
+	}
+}
+
 type SystemKey = systemKey
 
 var SystemKeyVersion = systemKeyVersion
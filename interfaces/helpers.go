@@ -21,6 +21,10 @@ package interfaces
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"sync"
 
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/timings"
@@ -36,19 +40,95 @@ func SetupMany(repo *Repository, backend SecurityBackend, snaps []*snap.Info, co
 			errors = setupManyInterface.SetupMany(snaps, confinementOpts, repo, nesttm)
 		})
 	} else {
-		// For each snap:
-		for _, snapInfo := range snaps {
-			snapName := snapInfo.InstanceName()
-			// Compute confinement options
-			opts := confinementOpts(snapName)
-
-			// Refresh security of this snap and backend
-			timings.Run(tm, "setup-security-backend", fmt.Sprintf("setup security backend %q for snap %q", backend.Name(), snapInfo.InstanceName()), func(nesttm timings.Measurer) {
-				if err := backend.Setup(snapInfo, opts, repo, nesttm); err != nil {
-					errors = append(errors, err)
-				}
-			})
+		for _, err := range SetupEach(repo, backend, snaps, confinementOpts, tm) {
+			if err != nil {
+				errors = append(errors, err)
+			}
 		}
 	}
 	return errors
 }
+
+// setupConcurrency is the maximum number of snaps set up at the same
+// time by backends supporting concurrent setup.
+var setupConcurrency = runtime.NumCPU
+
+// SetupEach generates the profiles of each of the snaps using the Setup()
+// method of the security backend. The snaps are set up concurrently if
+// the backend implements SecurityBackendConcurrentSetup and allows it,
+// one after the other otherwise. The returned errors match the snaps
+// by index, and are nil for the snaps set up successfully.
+func SetupEach(repo *Repository, backend SecurityBackend, snaps []*snap.Info, confinementOpts func(snapName string) ConfinementOptions, tm timings.Measurer) []error {
+	errors := make([]error, len(snaps))
+	if len(snaps) == 0 {
+		return errors
+	}
+
+	// the spans are created upfront as measurers are not safe for
+	// concurrent use
+	spans := make([]*timings.Span, len(snaps))
+	for i, snapInfo := range snaps {
+		spans[i] = tm.StartSpan("setup-security-backend", fmt.Sprintf("setup security backend %q for snap %q", backend.Name(), snapInfo.InstanceName()))
+	}
+	setup := func(i int) {
+		snapInfo := snaps[i]
+		// Compute confinement options
+		opts := confinementOpts(snapInfo.InstanceName())
+		// Refresh security of this snap and backend
+		errors[i] = backend.Setup(snapInfo, opts, repo, spans[i])
+		spans[i].Stop()
+	}
+
+	workers := 1
+	if concurrent, ok := backend.(SecurityBackendConcurrentSetup); ok && concurrent.ConcurrentSetup() {
+		workers = setupConcurrency()
+	}
+	if workers > len(snaps) {
+		workers = len(snaps)
+	}
+	if workers <= 1 {
+		for i := range snaps {
+			setup(i)
+		}
+		return errors
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				setup(i)
+			}
+		}()
+	}
+	for i := range snaps {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return errors
+}
+
+// ReadProfiles returns the content of the files in dir matching any of
+// the globs, indexed by their path.
+func ReadProfiles(dir string, globs []string) (map[string]string, error) {
+	profiles := make(map[string]string)
+	for _, glob := range globs {
+		matches, err := filepath.Glob(filepath.Join(dir, glob))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range matches {
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			profiles[path] = string(content)
+		}
+	}
+	return profiles, nil
+}
@@ -21,6 +21,9 @@ package interfaces_test
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
 
 	. "gopkg.in/check.v1"
 
@@ -170,3 +173,81 @@ func (s *HelpersSuite) TestSetupManySetupNotOK(c *C) {
 	c.Check(errs, HasLen, 2)
 	c.Check(setupCalls, Equals, 2)
 }
+
+func (s *HelpersSuite) TestSetupEachConcurrently(c *C) {
+	defer interfaces.MockSetupConcurrency(2)()
+
+	confinementOpts := func(snapName string) interfaces.ConfinementOptions {
+		return interfaces.ConfinementOptions{DevMode: snapName == "other-snap"}
+	}
+
+	// both snaps must be set up at the same time for the setup to proceed
+	started := make(chan string, 2)
+	proceed := make(chan struct{})
+	backend := &ifacetest.TestSecurityBackendConcurrentSetup{
+		TestSecurityBackend: ifacetest.TestSecurityBackend{
+			BackendName: "fake",
+			SetupCallback: func(snap *snap.Info, opts interfaces.ConfinementOptions, repo *interfaces.Repository) error {
+				started <- snap.InstanceName()
+				<-proceed
+				if snap.InstanceName() == "other-snap" {
+					c.Check(opts.DevMode, Equals, true)
+					return fmt.Errorf("boom")
+				}
+				return nil
+			},
+		},
+	}
+
+	go func() {
+		names := []string{<-started, <-started}
+		sort.Strings(names)
+		c.Check(names, DeepEquals, []string{"other-snap", "some-snap"})
+		close(proceed)
+	}()
+
+	errs := interfaces.SetupEach(s.repo, backend, []*snap.Info{s.snap1, s.snap2}, confinementOpts, s.tm)
+	c.Assert(errs, HasLen, 2)
+	c.Check(errs[0], IsNil)
+	c.Check(errs[1], ErrorMatches, "boom")
+	c.Check(backend.SetupCalls, HasLen, 2)
+}
+
+func (s *HelpersSuite) TestSetupEachSerially(c *C) {
+	defer interfaces.MockSetupConcurrency(2)()
+
+	confinementOpts := func(snapName string) interfaces.ConfinementOptions {
+		return interfaces.ConfinementOptions{}
+	}
+
+	var setups []string
+	backend := &ifacetest.TestSecurityBackend{
+		BackendName: "fake",
+		SetupCallback: func(snap *snap.Info, opts interfaces.ConfinementOptions, repo *interfaces.Repository) error {
+			setups = append(setups, snap.InstanceName())
+			return nil
+		},
+	}
+
+	errs := interfaces.SetupEach(s.repo, backend, []*snap.Info{s.snap1, s.snap2}, confinementOpts, s.tm)
+	c.Check(errs, DeepEquals, []error{nil, nil})
+	c.Check(setups, DeepEquals, []string{"some-snap", "other-snap"})
+}
+
+func (s *HelpersSuite) TestReadProfiles(c *C) {
+	dir := c.MkDir()
+	for _, name := range []string{"snap.some-snap.app", "snap.some-snap.hook.configure", "snap.other-snap.app"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, name), []byte(name+"\n"), 0644), IsNil)
+	}
+
+	profiles, err := interfaces.ReadProfiles(dir, []string{"snap.some-snap.*", "snap-update-ns.some-snap"})
+	c.Assert(err, IsNil)
+	c.Check(profiles, DeepEquals, map[string]string{
+		filepath.Join(dir, "snap.some-snap.app"):            "snap.some-snap.app\n",
+		filepath.Join(dir, "snap.some-snap.hook.configure"): "snap.some-snap.hook.configure\n",
+	})
+
+	profiles, err = interfaces.ReadProfiles(filepath.Join(dir, "missing"), []string{"*"})
+	c.Assert(err, IsNil)
+	c.Check(profiles, HasLen, 0)
+}
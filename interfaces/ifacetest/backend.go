@@ -20,6 +20,8 @@
 package ifacetest
 
 import (
+	"sync"
+
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/timings"
@@ -111,3 +113,56 @@ func (b *TestSecurityBackendSetupMany) SetupMany(snaps []*snap.Info, confinement
 	}
 	return b.SetupManyCallback(snaps, confinement, repo, tm)
 }
+
+// TestSecurityBackendConcurrentSetup is a security backend that allows
+// concurrent Setup calls, on top of TestSecurityBackend.
+type TestSecurityBackendConcurrentSetup struct {
+	TestSecurityBackend
+
+	mu sync.Mutex
+}
+
+// ConcurrentSetup returns true.
+func (b *TestSecurityBackendConcurrentSetup) ConcurrentSetup() bool {
+	return true
+}
+
+// Setup records information about the call and calls the setup callback if one is defined.
+// The callback can be called concurrently.
+func (b *TestSecurityBackendConcurrentSetup) Setup(snapInfo *snap.Info, opts interfaces.ConfinementOptions, repo *interfaces.Repository, tm timings.Measurer) error {
+	b.mu.Lock()
+	b.SetupCalls = append(b.SetupCalls, TestSetupCall{SnapInfo: snapInfo, Options: opts})
+	b.mu.Unlock()
+	if b.SetupCallback == nil {
+		return nil
+	}
+	return b.SetupCallback(snapInfo, opts, repo)
+}
+
+// TestSecurityBackendDeferReload is a security backend that can defer
+// its reloads, on top of TestSecurityBackend.
+type TestSecurityBackendDeferReload struct {
+	TestSecurityBackend
+
+	// DeferReloadCalls counts the calls to DeferReload
+	DeferReloadCalls int
+	// FlushCalls counts the calls to the functions returned by DeferReload
+	FlushCalls int
+	// ReloadCalls counts the calls to Reload
+	ReloadCalls int
+}
+
+// DeferReload records the call and returns a function recording the flush.
+func (b *TestSecurityBackendDeferReload) DeferReload() (flush func() error) {
+	b.DeferReloadCalls++
+	return func() error {
+		b.FlushCalls++
+		return nil
+	}
+}
+
+// Reload records the call.
+func (b *TestSecurityBackendDeferReload) Reload() error {
+	b.ReloadCalls++
+	return nil
+}
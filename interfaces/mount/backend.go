@@ -55,6 +55,11 @@ func (b *Backend) Name() interfaces.SecuritySystem {
 	return interfaces.SecurityMount
 }
 
+// ConcurrentSetup returns true as mount profiles are per snap.
+func (b *Backend) ConcurrentSetup() bool {
+	return true
+}
+
 // Setup creates mount mount profile files specific to a given snap.
 func (b *Backend) Setup(snapInfo *snap.Info, confinement interfaces.ConfinementOptions, repo *interfaces.Repository, tm timings.Measurer) error {
 	// Record all changes to the mount system for this snap.
@@ -81,6 +86,11 @@ func (b *Backend) Setup(snapInfo *snap.Info, confinement interfaces.ConfinementO
 	return nil
 }
 
+// InstalledProfiles returns the mount configuration files of the given snap.
+func (b *Backend) InstalledProfiles(snapName string) (map[string]string, error) {
+	return interfaces.ReadProfiles(dirs.SnapMountPolicyDir, []string{fmt.Sprintf("snap.%s.*fstab", snapName)})
+}
+
 // Remove removes mount configuration files of a given snap.
 //
 // This method should be called after removing a snap.
@@ -0,0 +1,145 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package mount
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// NamespaceEntry is an entry of the mount namespace of a snap.
+type NamespaceEntry struct {
+	Name    string   `json:"name"`
+	Dir     string   `json:"dir"`
+	Type    string   `json:"type,omitempty"`
+	Options []string `json:"options,omitempty"`
+	// Origin is "layout" or "overname" for entries derived from the
+	// snap itself, "synthetic" for the entries snap-update-ns creates
+	// to make others possible and empty for the entries requested by
+	// interface connections.
+	Origin string `json:"origin,omitempty"`
+}
+
+// NamespaceInfo describes the mount namespace of a snap, both as
+// requested by snapd and as applied by snap-update-ns.
+type NamespaceInfo struct {
+	// Preserved is whether the snap has a preserved mount namespace.
+	Preserved bool `json:"preserved"`
+	// Desired are the entries snapd requested.
+	Desired []NamespaceEntry `json:"desired,omitempty"`
+	// Current are the entries snap-update-ns applied.
+	Current []NamespaceEntry `json:"current,omitempty"`
+	// Pending are the desired entries that are not applied.
+	Pending []NamespaceEntry `json:"pending,omitempty"`
+	// UserCurrent are the per-user entries applied by snap-update-ns,
+	// indexed by the user ID.
+	UserCurrent map[string][]NamespaceEntry `json:"user-current,omitempty"`
+}
+
+func namespaceEntry(e *osutil.MountEntry) NamespaceEntry {
+	origin := e.XSnapdOrigin()
+	if origin == "" && e.XSnapdSynthetic() {
+		origin = "synthetic"
+	}
+	return NamespaceEntry{
+		Name:    e.Name,
+		Dir:     e.Dir,
+		Type:    e.Type,
+		Options: e.Options,
+		Origin:  origin,
+	}
+}
+
+func loadNamespaceEntries(fname string) ([]osutil.MountEntry, []NamespaceEntry, error) {
+	profile, err := osutil.LoadMountProfile(fname)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot load mount profile %q: %v", fname, err)
+	}
+	var entries []NamespaceEntry
+	for i := range profile.Entries {
+		entries = append(entries, namespaceEntry(&profile.Entries[i]))
+	}
+	return profile.Entries, entries, nil
+}
+
+// InspectNamespace returns the desired and current state of the mount
+// namespace of the given snap. The current state is the one recorded by
+// snap-update-ns when it last updated the namespace.
+func InspectNamespace(snapName string) (*NamespaceInfo, error) {
+	info := &NamespaceInfo{
+		Preserved: osutil.FileExists(mountNsPath(snapName)),
+	}
+
+	desired, desiredEntries, err := loadNamespaceEntries(filepath.Join(dirs.SnapMountPolicyDir, fmt.Sprintf("snap.%s.fstab", snapName)))
+	if err != nil {
+		return nil, err
+	}
+	current, currentEntries, err := loadNamespaceEntries(filepath.Join(dirs.SnapRunNsDir, fmt.Sprintf("snap.%s.fstab", snapName)))
+	if err != nil {
+		return nil, err
+	}
+	info.Desired = desiredEntries
+	info.Current = currentEntries
+
+	if info.Preserved {
+		for i := range desired {
+			applied := false
+			for j := range current {
+				if desired[i].Equal(&current[j]) {
+					applied = true
+					break
+				}
+			}
+			if !applied {
+				info.Pending = append(info.Pending, desiredEntries[i])
+			}
+		}
+	}
+
+	// per-user profiles are named snap.<name>.<uid>.user-fstab
+	prefix := fmt.Sprintf("snap.%s.", snapName)
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapRunNsDir, prefix+"*.user-fstab"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	for _, fname := range matches {
+		uid := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(fname), prefix), ".user-fstab")
+		if _, err := strconv.ParseUint(uid, 10, 32); err != nil {
+			// belongs to a snap with a name sharing the prefix
+			continue
+		}
+		_, entries, err := loadNamespaceEntries(fname)
+		if err != nil {
+			return nil, err
+		}
+		if info.UserCurrent == nil {
+			info.UserCurrent = make(map[string][]NamespaceEntry)
+		}
+		info.UserCurrent[uid] = entries
+	}
+	return info, nil
+}
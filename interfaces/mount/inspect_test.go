@@ -0,0 +1,111 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package mount_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces/mount"
+)
+
+type inspectSuite struct{}
+
+var _ = Suite(&inspectSuite{})
+
+func (s *inspectSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	c.Assert(os.MkdirAll(dirs.SnapMountPolicyDir, 0755), IsNil)
+	c.Assert(os.MkdirAll(dirs.SnapRunNsDir, 0755), IsNil)
+}
+
+func (s *inspectSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *inspectSuite) TestInspectNamespaceEmpty(c *C) {
+	info, err := mount.InspectNamespace("snap-name")
+	c.Assert(err, IsNil)
+	c.Check(info, DeepEquals, &mount.NamespaceInfo{})
+}
+
+func (s *inspectSuite) TestInspectNamespace(c *C) {
+	desired := "" +
+		"/snap/snap-name/1/lib /usr/lib/foo none rbind,x-snapd.origin=layout 0 0\n" +
+		"/snap/other/2/share /snap/snap-name/1/share none bind,ro 0 0\n"
+	current := "" +
+		"tmpfs /usr/lib tmpfs x-snapd.synthetic,x-snapd.needed-by=/usr/lib/foo 0 0\n" +
+		"/snap/snap-name/1/lib /usr/lib/foo none rbind,x-snapd.origin=layout 0 0\n"
+	user := "$XDG_RUNTIME_DIR/doc/by-app/snap.snap-name $XDG_RUNTIME_DIR/doc none bind,rw 0 0\n"
+
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapMountPolicyDir, "snap.snap-name.fstab"), []byte(desired), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapRunNsDir, "snap.snap-name.fstab"), []byte(current), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapRunNsDir, "snap.snap-name.1000.user-fstab"), []byte(user), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapRunNsDir, "snap-name.mnt"), nil, 0644), IsNil)
+	// profiles of snaps sharing the name prefix are ignored
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapRunNsDir, "snap.snap-name.other.1000.user-fstab"), []byte(user), 0644), IsNil)
+
+	layout := mount.NamespaceEntry{
+		Name:    "/snap/snap-name/1/lib",
+		Dir:     "/usr/lib/foo",
+		Type:    "none",
+		Options: []string{"rbind", "x-snapd.origin=layout"},
+		Origin:  "layout",
+	}
+	content := mount.NamespaceEntry{
+		Name:    "/snap/other/2/share",
+		Dir:     "/snap/snap-name/1/share",
+		Type:    "none",
+		Options: []string{"bind", "ro"},
+	}
+
+	info, err := mount.InspectNamespace("snap-name")
+	c.Assert(err, IsNil)
+	c.Check(info, DeepEquals, &mount.NamespaceInfo{
+		Preserved: true,
+		Desired:   []mount.NamespaceEntry{layout, content},
+		Current: []mount.NamespaceEntry{{
+			Name:    "tmpfs",
+			Dir:     "/usr/lib",
+			Type:    "tmpfs",
+			Options: []string{"x-snapd.synthetic", "x-snapd.needed-by=/usr/lib/foo"},
+			Origin:  "synthetic",
+		}, layout},
+		Pending: []mount.NamespaceEntry{content},
+		UserCurrent: map[string][]mount.NamespaceEntry{
+			"1000": {{
+				Name:    "$XDG_RUNTIME_DIR/doc/by-app/snap.snap-name",
+				Dir:     "$XDG_RUNTIME_DIR/doc",
+				Type:    "none",
+				Options: []string{"bind", "rw"},
+			}},
+		},
+	})
+}
+
+func (s *inspectSuite) TestInspectNamespaceBrokenProfile(c *C) {
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapMountPolicyDir, "snap.snap-name.fstab"), []byte("garbage\n"), 0644), IsNil)
+	_, err := mount.InspectNamespace("snap-name")
+	c.Check(err, ErrorMatches, `cannot load mount profile ".*/snap.snap-name.fstab": .*`)
+}
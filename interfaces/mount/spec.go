@@ -86,6 +86,10 @@ func mountEntryFromLayout(layout *snap.Layout) osutil.MountEntry {
 	if layout.Type == "tmpfs" {
 		entry.Type = "tmpfs"
 		entry.Name = "tmpfs"
+		if layout.Size != 0 {
+			// The size limit is passed as data to mount(2) by snap-update-ns.
+			entry.Options = append(entry.Options, fmt.Sprintf("size=%d", layout.Size))
+		}
 	}
 
 	if layout.Symlink != "" {
@@ -181,6 +181,21 @@ func (s *specSuite) TestParallelInstanceMountEntryFromLayout(c *C) {
 	})
 }
 
+func (s *specSuite) TestMountEntryFromLayoutTmpfsSize(c *C) {
+	const tmpfsSizeYaml = `name: vanguard
+version: 0
+layout:
+  /mytmp:
+    type: tmpfs
+    size: 16MB
+`
+	snapInfo := snaptest.MockInfo(c, tmpfsSizeYaml, &snap.SideInfo{Revision: snap.R(42)})
+	s.spec.AddLayout(snapInfo)
+	c.Assert(s.spec.MountEntries(), DeepEquals, []osutil.MountEntry{
+		{Dir: "/mytmp", Name: "tmpfs", Type: "tmpfs", Options: []string{"size=16000000", "x-snapd.origin=layout"}},
+	})
+}
+
 func (s *specSuite) TestSpecificationUberclash(c *C) {
 	// When everything clashes for access to /foo, what happens?
 	const uberclashYaml = `name: uberclash
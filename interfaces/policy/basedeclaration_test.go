@@ -292,6 +292,129 @@ plugs:
 	c.Check(err, IsNil)
 }
 
+func (s *baseDeclSuite) TestAutoConnectionFilesPerMode(c *C) {
+	for _, t := range []struct {
+		iface     string
+		readPath  string
+		writePath string
+		readRe    string
+		writeRe   string
+	}{
+		{"system-files", "/etc/foo", "/var/lib/foo/bar", "/etc/.+", "/var/lib/foo/.+"},
+		{"personal-files", "$HOME/.foo", "$HOME/.config/foo/bar", `\$HOME/.+`, `\$HOME/\.config/foo/.+`},
+	} {
+		// read access is granted broadly while write access is
+		// scoped to specific locations
+		plugsSlots := fmt.Sprintf(`
+plugs:
+  %s:
+    allow-installation: true
+    allow-auto-connection:
+      -
+        plug-attributes:
+          read: %s
+          write: $MISSING
+      -
+        plug-attributes:
+          read: %s
+          write: %s
+`, t.iface, t.readRe, t.readRe, t.writeRe)
+		plugDecl := s.mockSnapDecl(c, "plug-snap", "plug-snap-id", "pub1", plugsSlots)
+
+		for _, plug := range []struct {
+			attrs string
+			ok    bool
+		}{
+			{fmt.Sprintf("read: [%q]", t.readPath), true},
+			{fmt.Sprintf("read: [%q]\n    write: [%q]", t.readPath, t.writePath), true},
+			{fmt.Sprintf("write: [%q]", t.readPath), false},
+			{fmt.Sprintf("read: [%q]\n    write: [%q]", t.readPath, t.readPath), false},
+		} {
+			cand := s.connectCand(c, t.iface, "", fmt.Sprintf(`name: plug-snap
+version: 0
+plugs:
+  %s:
+    %s
+`, t.iface, plug.attrs))
+			cand.PlugSnapDeclaration = plugDecl
+			comm := Commentf("%s: %s", t.iface, plug.attrs)
+			err := cand.CheckAutoConnect()
+			if plug.ok {
+				c.Check(err, IsNil, comm)
+			} else {
+				c.Check(err, NotNil, comm)
+			}
+		}
+	}
+}
+
+func (s *baseDeclSuite) TestPlugInstallationFilesPerMode(c *C) {
+	for _, t := range []struct {
+		iface     string
+		readPath  string
+		writePath string
+		readRe    string
+		writeRe   string
+	}{
+		{"system-files", "/etc/foo", "/var/lib/foo/bar", "/etc/.+", "/var/lib/foo/.+"},
+		{"personal-files", "$HOME/.foo", "$HOME/.config/foo/bar", `\$HOME/.+`, `\$HOME/\.config/foo/.+`},
+	} {
+		// the base declaration alone does not allow either mode
+		for _, attrs := range []string{
+			fmt.Sprintf("read: [%q]", t.readPath),
+			fmt.Sprintf("write: [%q]", t.writePath),
+		} {
+			ic := s.installPlugCand(c, t.iface, snap.TypeApp, fmt.Sprintf(`name: install-plug-snap
+version: 0
+plugs:
+  %s:
+    %s
+`, t.iface, attrs))
+			c.Check(ic.Check(), NotNil, Commentf("%s: %s", t.iface, attrs))
+		}
+
+		plugsSlots := fmt.Sprintf(`
+plugs:
+  %s:
+    allow-installation:
+      -
+        plug-attributes:
+          read: %s
+          write: $MISSING
+      -
+        plug-attributes:
+          read: %s
+          write: %s
+`, t.iface, t.readRe, t.readRe, t.writeRe)
+		snapDecl := s.mockSnapDecl(c, "install-plug-snap", "install-plug-snap-id", "pub1", plugsSlots)
+
+		for _, plug := range []struct {
+			attrs string
+			ok    bool
+		}{
+			{fmt.Sprintf("read: [%q]", t.readPath), true},
+			{fmt.Sprintf("read: [%q]\n    write: [%q]", t.readPath, t.writePath), true},
+			{fmt.Sprintf("write: [%q]", t.readPath), false},
+			{fmt.Sprintf("read: [%q]\n    write: [%q]", t.readPath, t.readPath), false},
+		} {
+			ic := s.installPlugCand(c, t.iface, snap.TypeApp, fmt.Sprintf(`name: install-plug-snap
+version: 0
+plugs:
+  %s:
+    %s
+`, t.iface, plug.attrs))
+			ic.SnapDeclaration = snapDecl
+			comm := Commentf("%s: %s", t.iface, plug.attrs)
+			err := ic.Check()
+			if plug.ok {
+				c.Check(err, IsNil, comm)
+			} else {
+				c.Check(err, NotNil, comm)
+			}
+		}
+	}
+}
+
 func (s *baseDeclSuite) TestAutoConnectionContent(c *C) {
 	// random snaps cannot connect with content
 	// (Sanitize* will now also block this)
@@ -590,6 +713,7 @@ var (
 		"browser-support":         {"core"},
 		"content":                 {"app", "gadget"},
 		"core-support":            {"core"},
+		"custom-device":           {"gadget"},
 		"dbus":                    {"app"},
 		"docker-support":          {"core"},
 		"fwupd":                   {"app"},
@@ -743,6 +867,7 @@ func (s *baseDeclSuite) TestConnection(c *C) {
 	// case-by-case basis
 	noconnect := map[string]bool{
 		"content":                   true,
+		"custom-device":             true,
 		"docker":                    true,
 		"fwupd":                     true,
 		"location-control":          true,
@@ -207,10 +207,10 @@ func (connc *ConnectCandidate) checkSlotRule(kind string, rule *asserts.SlotRule
 		denyConst = rule.DenyAutoConnection
 		allowConst = rule.AllowAutoConnection
 	}
-	if checkSlotConnectionConstraints(connc, denyConst) == nil {
+	if _, err := checkSlotConnectionConstraints(connc, denyConst); err == nil {
 		return fmt.Errorf("%s denied by slot rule of interface %q%s", kind, connc.Plug.Interface(), context)
 	}
-	if checkSlotConnectionConstraints(connc, allowConst) != nil {
+	if _, err := checkSlotConnectionConstraints(connc, allowConst); err != nil {
 		return fmt.Errorf("%s not allowed by slot rule of interface %q%s", kind, connc.Plug.Interface(), context)
 	}
 	return nil
@@ -247,16 +247,75 @@ func (connc *ConnectCandidate) check(kind string) error {
 	return nil
 }
 
+// ConnectCandidateArity holds the arity constraints that apply to a
+// candidate connection.
+type ConnectCandidateArity struct {
+	// PlugsPerSlot limits the number of plugs connected to the slot.
+	PlugsPerSlot asserts.SideArityConstraint
+}
+
+// arity returns the arity constraints of the candidate connection. They
+// are set by the slot side alone, by the first allow constraints of the
+// slot rule (from the slot snap-declaration, or otherwise from the base
+// declaration) matching the connection, independently of which rule
+// decides whether the connection is allowed.
+func (connc *ConnectCandidate) arity(kind string) *ConnectCandidateArity {
+	iface := connc.Slot.Interface()
+	var rule *asserts.SlotRule
+	if slotDecl := connc.SlotSnapDeclaration; slotDecl != nil {
+		rule = slotDecl.SlotRule(iface)
+	}
+	if rule == nil && connc.BaseDeclaration != nil {
+		rule = connc.BaseDeclaration.SlotRule(iface)
+	}
+	if rule == nil {
+		return &ConnectCandidateArity{}
+	}
+	allowConst := rule.AllowConnection
+	if kind == "auto-connection" {
+		allowConst = rule.AllowAutoConnection
+	}
+	allowed, err := checkSlotConnectionConstraints(connc, allowConst)
+	if err != nil {
+		return &ConnectCandidateArity{}
+	}
+	return &ConnectCandidateArity{PlugsPerSlot: allowed.PlugsPerSlot}
+}
+
 // Check checks whether the connection is allowed.
 func (connc *ConnectCandidate) Check() error {
 	return connc.check("connection")
 }
 
+// CheckWithArity checks whether the connection is allowed and
+// returns the arity constraints that apply to it.
+func (connc *ConnectCandidate) CheckWithArity() (*ConnectCandidateArity, error) {
+	if err := connc.check("connection"); err != nil {
+		return nil, err
+	}
+	return connc.arity("connection"), nil
+}
+
+// Arity returns the arity constraints that the slot side sets for the
+// connection, without checking whether the connection is allowed.
+func (connc *ConnectCandidate) Arity() *ConnectCandidateArity {
+	return connc.arity("connection")
+}
+
 // CheckAutoConnect checks whether the connection is allowed to auto-connect.
 func (connc *ConnectCandidate) CheckAutoConnect() error {
 	return connc.check("auto-connection")
 }
 
+// CheckAutoConnectWithArity checks whether the connection is allowed
+// to auto-connect and returns the arity constraints that apply to it.
+func (connc *ConnectCandidate) CheckAutoConnectWithArity() (*ConnectCandidateArity, error) {
+	if err := connc.check("auto-connection"); err != nil {
+		return nil, err
+	}
+	return connc.arity("auto-connection"), nil
+}
+
 // InstallCandidateMinimalCheck represents a candidate snap installed with --dangerous flag that should pass minimum checks
 // against snap type (if present). It doesn't check interface attributes.
 type InstallCandidateMinimalCheck struct {
@@ -2220,3 +2220,88 @@ func (s *policySuite) TestPlugDollarSlotDynamicAttrConnection(c *C) {
 	}
 	c.Check(cand.Check(), IsNil)
 }
+
+func (s *policySuite) TestSlotArityCheckConnection(c *C) {
+	a, err := asserts.Decode([]byte(`type: base-declaration
+authority-id: canonical
+series: 16
+plugs:
+  plug-rule:
+    allow-connection: true
+  plug-and-slot-rule:
+    allow-connection: true
+slots:
+  plug-and-slot-rule:
+    allow-connection:
+      plugs-per-slot: 1
+  one-plug:
+    allow-connection:
+      plugs-per-slot: 1
+    allow-auto-connection:
+      plugs-per-slot: 2
+  any-plugs:
+    allow-connection:
+      plugs-per-slot: *
+timestamp: 2016-09-30T12:00:00Z
+sign-key-sha3-384: Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij
+
+AXNpZw==`))
+	c.Assert(err, IsNil)
+	baseDecl := a.(*asserts.BaseDeclaration)
+
+	plugSnap := snaptest.MockInfo(c, `
+name: plug-snap
+version: 0
+plugs:
+  one-plug:
+  any-plugs:
+  plug-rule:
+  plug-and-slot-rule:
+`, nil)
+	slotSnap := snaptest.MockInfo(c, `
+name: slot-snap
+version: 0
+slots:
+  one-plug:
+  any-plugs:
+  plug-rule:
+  plug-and-slot-rule:
+`, nil)
+
+	tests := []struct {
+		iface       string
+		autoConnect bool
+		any         bool
+		n           int
+	}{
+		{"one-plug", false, false, 1},
+		{"one-plug", true, false, 2},
+		{"any-plugs", false, true, 0},
+		// plug rules do not constrain the arity
+		{"plug-rule", false, true, 0},
+		// the slot side alone sets the arity, even if a plug
+		// rule decides the connection
+		{"plug-and-slot-rule", false, false, 1},
+	}
+
+	for _, t := range tests {
+		cand := policy.ConnectCandidate{
+			Plug:            interfaces.NewConnectedPlug(plugSnap.Plugs[t.iface], nil, nil),
+			Slot:            interfaces.NewConnectedSlot(slotSnap.Slots[t.iface], nil, nil),
+			BaseDeclaration: baseDecl,
+		}
+		var arity *policy.ConnectCandidateArity
+		if t.autoConnect {
+			arity, err = cand.CheckAutoConnectWithArity()
+		} else {
+			arity, err = cand.CheckWithArity()
+		}
+		c.Assert(err, IsNil)
+		c.Check(arity.PlugsPerSlot.Any(), Equals, t.any, Commentf(t.iface))
+		c.Check(arity.PlugsPerSlot.N, Equals, t.n, Commentf(t.iface))
+
+		if !t.autoConnect {
+			c.Check(cand.Arity(), DeepEquals, arity, Commentf(t.iface))
+		}
+	}
+}
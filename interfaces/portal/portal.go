@@ -0,0 +1,86 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package portal implements the integration of snaps with
+// xdg-desktop-portal.
+//
+// The portals identify confined applications by their application ID,
+// which for snaps is derived from the snap instance name. The document
+// portal exposes the files shared with an application below a per
+// application directory, which is bind mounted into the snap's view of
+// the user runtime directory. Snap applications are registered with the
+// portals when run, and the ones running without a window are allowed to
+// run in the background.
+package portal
+
+import (
+	"path/filepath"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+const (
+	// DesktopBusName is the well-known bus name of xdg-desktop-portal.
+	DesktopBusName = "org.freedesktop.portal.Desktop"
+	// DocumentsBusName is the well-known bus name of the document portal.
+	DocumentsBusName = "org.freedesktop.portal.Documents"
+)
+
+// AppID returns the application ID under which the portals know the
+// given snap instance.
+func AppID(instanceName string) string {
+	return "snap." + instanceName
+}
+
+// DocumentsDir returns the mount point of the document portal in the
+// given user runtime directory.
+func DocumentsDir(xdgRuntimeDir string) string {
+	return filepath.Join(xdgRuntimeDir, "doc")
+}
+
+// AppDocumentsDir returns the directory holding the documents shared
+// with the given snap instance through the document portal.
+func AppDocumentsDir(xdgRuntimeDir, instanceName string) string {
+	return filepath.Join(DocumentsDir(xdgRuntimeDir), "by-app", AppID(instanceName))
+}
+
+// sessionServicesDir is where the session bus finds activatable services.
+func sessionServicesDir() string {
+	return filepath.Join(dirs.GlobalRootDir, "/usr/share/dbus-1/services")
+}
+
+// Available returns whether the portal with the given bus name can be
+// activated on the session bus.
+func Available(busName string) bool {
+	return osutil.FileExists(filepath.Join(sessionServicesDir(), busName+".service"))
+}
+
+// SandboxFeatures returns the sandbox feature tags of the portals
+// available on the system.
+func SandboxFeatures() []string {
+	var features []string
+	if Available(DesktopBusName) {
+		features = append(features, "desktop-portal")
+	}
+	if Available(DocumentsBusName) {
+		features = append(features, "document-portal")
+	}
+	return features
+}
@@ -0,0 +1,75 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package portal_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces/portal"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type portalSuite struct{}
+
+var _ = Suite(&portalSuite{})
+
+func (s *portalSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *portalSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *portalSuite) TestAppID(c *C) {
+	c.Check(portal.AppID("foo"), Equals, "snap.foo")
+	c.Check(portal.AppID("foo_bar"), Equals, "snap.foo_bar")
+}
+
+func (s *portalSuite) TestDocumentsDirs(c *C) {
+	c.Check(portal.DocumentsDir("/run/user/1000"), Equals, "/run/user/1000/doc")
+	c.Check(portal.AppDocumentsDir("/run/user/1000", "foo"), Equals, "/run/user/1000/doc/by-app/snap.foo")
+}
+
+func (s *portalSuite) mockService(c *C, busName string) {
+	dir := filepath.Join(dirs.GlobalRootDir, "/usr/share/dbus-1/services")
+	c.Assert(os.MkdirAll(dir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, busName+".service"), nil, 0644), IsNil)
+}
+
+func (s *portalSuite) TestAvailable(c *C) {
+	c.Check(portal.Available(portal.DesktopBusName), Equals, false)
+	c.Check(portal.SandboxFeatures(), HasLen, 0)
+
+	s.mockService(c, portal.DocumentsBusName)
+	c.Check(portal.Available(portal.DesktopBusName), Equals, false)
+	c.Check(portal.Available(portal.DocumentsBusName), Equals, true)
+	c.Check(portal.SandboxFeatures(), DeepEquals, []string{"document-portal"})
+
+	s.mockService(c, portal.DesktopBusName)
+	c.Check(portal.SandboxFeatures(), DeepEquals, []string{"desktop-portal", "document-portal"})
+}
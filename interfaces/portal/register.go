@@ -0,0 +1,71 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package portal
+
+import (
+	"github.com/godbus/dbus"
+)
+
+const (
+	desktopObjectPath = "/org/freedesktop/portal/desktop"
+
+	permissionStoreBusName    = "org.freedesktop.impl.portal.PermissionStore"
+	permissionStoreObjectPath = "/org/freedesktop/impl/portal/PermissionStore"
+
+	// the background portal keeps the applications allowed to run in
+	// the background in this table and entry of the permission store
+	backgroundTable = "background"
+	backgroundID    = "background"
+)
+
+// Register registers the snap instance with xdg-desktop-portal, the
+// portals then identify the calls made on the connection by the
+// application ID of the snap.
+func Register(conn *dbus.Conn, instanceName string) error {
+	return register(conn.Object(DesktopBusName, desktopObjectPath), instanceName)
+}
+
+func register(desktop dbus.BusObject, instanceName string) error {
+	return desktop.Call("org.freedesktop.host.portal.Registry.Register", 0, AppID(instanceName), map[string]dbus.Variant{}).Err
+}
+
+// AllowBackground adds the application ID of the snap instance to the
+// applications the background portal lets run without a window.
+func AllowBackground(conn *dbus.Conn, instanceName string) error {
+	return allowBackground(conn.Object(permissionStoreBusName, permissionStoreObjectPath), instanceName)
+}
+
+func allowBackground(store dbus.BusObject, instanceName string) error {
+	return store.Call("org.freedesktop.impl.portal.PermissionStore.SetPermission", 0, backgroundTable, true, backgroundID, AppID(instanceName), []string{"yes"}).Err
+}
+
+// IsUnsupported returns whether the error of a portal call means that
+// the portal, or the method called, is not available on the system.
+func IsUnsupported(err error) bool {
+	dbusErr, ok := err.(dbus.Error)
+	if !ok {
+		return false
+	}
+	switch dbusErr.Name {
+	case "org.freedesktop.DBus.Error.ServiceUnknown", "org.freedesktop.DBus.Error.UnknownMethod", "org.freedesktop.DBus.Error.UnknownInterface", "org.freedesktop.DBus.Error.UnknownObject":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package portal_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/godbus/dbus"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/portal"
+)
+
+type registerSuite struct{}
+
+var _ = Suite(&registerSuite{})
+
+func (s *registerSuite) TestRegister(c *C) {
+	desktop := fakeBusObject(func(method string, args ...interface{}) error {
+		c.Check(method, Equals, "org.freedesktop.host.portal.Registry.Register")
+		c.Check(args, DeepEquals, []interface{}{"snap.foo_bar", map[string]dbus.Variant{}})
+		return nil
+	})
+	c.Check(portal.RegisterWithObject(desktop, "foo_bar"), IsNil)
+}
+
+func (s *registerSuite) TestRegisterError(c *C) {
+	desktop := fakeBusObject(func(method string, args ...interface{}) error {
+		return errors.New("boom")
+	})
+	c.Check(portal.RegisterWithObject(desktop, "foo"), ErrorMatches, "boom")
+}
+
+func (s *registerSuite) TestAllowBackground(c *C) {
+	store := fakeBusObject(func(method string, args ...interface{}) error {
+		c.Check(method, Equals, "org.freedesktop.impl.portal.PermissionStore.SetPermission")
+		c.Check(args, DeepEquals, []interface{}{"background", true, "background", "snap.foo", []string{"yes"}})
+		return nil
+	})
+	c.Check(portal.AllowBackgroundWithObject(store, "foo"), IsNil)
+}
+
+func (s *registerSuite) TestIsUnsupported(c *C) {
+	for _, name := range []string{
+		"org.freedesktop.DBus.Error.ServiceUnknown",
+		"org.freedesktop.DBus.Error.UnknownMethod",
+		"org.freedesktop.DBus.Error.UnknownInterface",
+		"org.freedesktop.DBus.Error.UnknownObject",
+	} {
+		c.Check(portal.IsUnsupported(dbus.Error{Name: name}), Equals, true, Commentf(name))
+	}
+	c.Check(portal.IsUnsupported(dbus.Error{Name: "org.freedesktop.DBus.Error.AccessDenied"}), Equals, false)
+	c.Check(portal.IsUnsupported(errors.New("boom")), Equals, false)
+	c.Check(portal.IsUnsupported(nil), Equals, false)
+}
+
+// fakeBusObject is a dbus.BusObject implementation that forwards
+// Call invocations
+type fakeBusObject func(method string, args ...interface{}) error
+
+func (f fakeBusObject) Call(method string, flags dbus.Flags, args ...interface{}) *dbus.Call {
+	err := f(method, args...)
+	return &dbus.Call{Err: err}
+}
+
+func (f fakeBusObject) CallWithContext(ctx context.Context, method string, flags dbus.Flags, args ...interface{}) *dbus.Call {
+	err := f(method, args...)
+	return &dbus.Call{Err: err}
+}
+
+func (f fakeBusObject) Go(method string, flags dbus.Flags, ch chan *dbus.Call, args ...interface{}) *dbus.Call {
+	return nil
+}
+
+func (f fakeBusObject) GoWithContext(ctx context.Context, method string, flags dbus.Flags, ch chan *dbus.Call, args ...interface{}) *dbus.Call {
+	return nil
+}
+
+func (f fakeBusObject) AddMatchSignal(iface, member string, options ...dbus.MatchOption) *dbus.Call {
+	return nil
+}
+
+func (f fakeBusObject) RemoveMatchSignal(iface, member string, options ...dbus.MatchOption) *dbus.Call {
+	return nil
+}
+
+func (f fakeBusObject) GetProperty(prop string) (dbus.Variant, error) {
+	return dbus.Variant{}, nil
+}
+
+func (f fakeBusObject) SetProperty(p string, v interface{}) error {
+	return nil
+}
+
+func (f fakeBusObject) Destination() string {
+	return ""
+}
+
+func (f fakeBusObject) Path() dbus.ObjectPath {
+	return ""
+}
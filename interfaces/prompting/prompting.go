@@ -0,0 +1,151 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package prompting contains the types describing requests for
+// permission made on behalf of snaps when they are denied access to a
+// resource, and the replies given to them.
+package prompting
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/snapcore/snapd/strutil"
+)
+
+// Outcome is the reply given to a prompt.
+type Outcome string
+
+const (
+	OutcomeAllow Outcome = "allow"
+	OutcomeDeny  Outcome = "deny"
+)
+
+// Lifespan is how long a reply applies for.
+type Lifespan string
+
+const (
+	// LifespanSingle applies the reply to the prompted request only.
+	LifespanSingle Lifespan = "single"
+	// LifespanForever records the reply as a rule applied to future
+	// matching requests.
+	LifespanForever Lifespan = "forever"
+)
+
+// supportedInterfaces are the interfaces whose denials can be prompted
+// for.
+var supportedInterfaces = []string{"camera", "home"}
+
+// SupportedInterfaces returns the names of the interfaces whose denials
+// can be prompted for.
+func SupportedInterfaces() []string {
+	return append([]string(nil), supportedInterfaces...)
+}
+
+// InterfaceSupported returns whether denials of the given interface
+// can be prompted for.
+func InterfaceSupported(iface string) bool {
+	return strutil.ListContains(supportedInterfaces, iface)
+}
+
+// ValidateOutcome checks that the outcome is a known one.
+func ValidateOutcome(outcome Outcome) error {
+	switch outcome {
+	case OutcomeAllow, OutcomeDeny:
+		return nil
+	}
+	return fmt.Errorf("invalid prompt outcome %q", outcome)
+}
+
+// ValidateLifespan checks that the lifespan is a known one.
+func ValidateLifespan(lifespan Lifespan) error {
+	switch lifespan {
+	case LifespanSingle, LifespanForever:
+		return nil
+	}
+	return fmt.Errorf("invalid prompt lifespan %q", lifespan)
+}
+
+// Request is a request for permission on behalf of a snap that was
+// denied access to a resource.
+type Request struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	// UID is the user the snap was running as.
+	UID       uint32 `json:"uid"`
+	Snap      string `json:"snap"`
+	App       string `json:"app,omitempty"`
+	Interface string `json:"interface"`
+	// Path is the path of the resource, if any.
+	Path        string   `json:"path,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// Validate checks that the request can be prompted for.
+func (r *Request) Validate() error {
+	if r.Snap == "" {
+		return fmt.Errorf("prompt request must have a snap")
+	}
+	if !InterfaceSupported(r.Interface) {
+		return fmt.Errorf("cannot prompt for interface %q", r.Interface)
+	}
+	if len(r.Permissions) == 0 {
+		return fmt.Errorf("prompt request must have permissions")
+	}
+	if r.Path != "" && !filepath.IsAbs(r.Path) {
+		return fmt.Errorf("prompt request path %q must be absolute", r.Path)
+	}
+	return nil
+}
+
+// Rule records an outcome given "forever" to a request, applied to
+// later matching requests.
+type Rule struct {
+	UID       uint32 `json:"uid"`
+	Snap      string `json:"snap"`
+	Interface string `json:"interface"`
+	// PathPattern is a glob, as understood by filepath.Match, the paths
+	// of the matching requests must match.
+	PathPattern string   `json:"path-pattern,omitempty"`
+	Permissions []string `json:"permissions"`
+	Outcome     Outcome  `json:"outcome"`
+}
+
+// Matches returns whether the rule applies to the given request, that
+// is when it is about the same user, snap and interface, for a
+// matching path and with all the requested permissions covered.
+func (rule *Rule) Matches(r *Request) bool {
+	if rule.UID != r.UID || rule.Snap != r.Snap || rule.Interface != r.Interface {
+		return false
+	}
+	if rule.PathPattern != "" {
+		if matched, err := filepath.Match(rule.PathPattern, r.Path); err != nil || !matched {
+			return false
+		}
+	} else if r.Path != "" {
+		return false
+	}
+	for _, perm := range r.Permissions {
+		if !strutil.ListContains(rule.Permissions, perm) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package prompting_test
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/prompting"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type promptingSuite struct{}
+
+var _ = Suite(&promptingSuite{})
+
+func (s *promptingSuite) TestInterfaceSupported(c *C) {
+	c.Check(prompting.SupportedInterfaces(), DeepEquals, []string{"camera", "home"})
+	c.Check(prompting.InterfaceSupported("home"), Equals, true)
+	c.Check(prompting.InterfaceSupported("camera"), Equals, true)
+	c.Check(prompting.InterfaceSupported("network"), Equals, false)
+}
+
+func (s *promptingSuite) TestValidateOutcomeAndLifespan(c *C) {
+	c.Check(prompting.ValidateOutcome(prompting.OutcomeAllow), IsNil)
+	c.Check(prompting.ValidateOutcome(prompting.OutcomeDeny), IsNil)
+	c.Check(prompting.ValidateOutcome("maybe"), ErrorMatches, `invalid prompt outcome "maybe"`)
+
+	c.Check(prompting.ValidateLifespan(prompting.LifespanSingle), IsNil)
+	c.Check(prompting.ValidateLifespan(prompting.LifespanForever), IsNil)
+	c.Check(prompting.ValidateLifespan("session"), ErrorMatches, `invalid prompt lifespan "session"`)
+}
+
+func (s *promptingSuite) TestRequestValidate(c *C) {
+	req := &prompting.Request{
+		Snap:        "foo",
+		Interface:   "home",
+		Path:        "/home/user/doc.txt",
+		Permissions: []string{"read"},
+	}
+	c.Check(req.Validate(), IsNil)
+
+	for _, t := range []struct {
+		mod func(r *prompting.Request)
+		err string
+	}{
+		{func(r *prompting.Request) { r.Snap = "" }, `prompt request must have a snap`},
+		{func(r *prompting.Request) { r.Interface = "network" }, `cannot prompt for interface "network"`},
+		{func(r *prompting.Request) { r.Permissions = nil }, `prompt request must have permissions`},
+		{func(r *prompting.Request) { r.Path = "doc.txt" }, `prompt request path "doc.txt" must be absolute`},
+	} {
+		r := *req
+		t.mod(&r)
+		c.Check(r.Validate(), ErrorMatches, t.err)
+	}
+}
+
+func (s *promptingSuite) TestRuleMatches(c *C) {
+	rule := &prompting.Rule{
+		UID:         1000,
+		Snap:        "foo",
+		Interface:   "home",
+		PathPattern: "/home/user/Documents/*",
+		Permissions: []string{"read", "write"},
+		Outcome:     prompting.OutcomeAllow,
+	}
+	req := &prompting.Request{
+		UID:         1000,
+		Snap:        "foo",
+		Interface:   "home",
+		Path:        "/home/user/Documents/doc.txt",
+		Permissions: []string{"read"},
+	}
+	c.Check(rule.Matches(req), Equals, true)
+
+	for _, mod := range []func(r *prompting.Request){
+		func(r *prompting.Request) { r.UID = 1001 },
+		func(r *prompting.Request) { r.Snap = "bar" },
+		func(r *prompting.Request) { r.Interface = "camera" },
+		func(r *prompting.Request) { r.Path = "/home/user/Pictures/pic.png" },
+		func(r *prompting.Request) { r.Path = "" },
+		func(r *prompting.Request) { r.Permissions = []string{"read", "execute"} },
+	} {
+		r := *req
+		mod(&r)
+		c.Check(rule.Matches(&r), Equals, false)
+	}
+
+	// rules without a path only match requests without one
+	camera := &prompting.Rule{
+		UID:         1000,
+		Snap:        "foo",
+		Interface:   "camera",
+		Permissions: []string{"access"},
+		Outcome:     prompting.OutcomeDeny,
+	}
+	c.Check(camera.Matches(&prompting.Request{UID: 1000, Snap: "foo", Interface: "camera", Permissions: []string{"access"}}), Equals, true)
+	c.Check(camera.Matches(&prompting.Request{UID: 1000, Snap: "foo", Interface: "camera", Path: "/dev/video0", Permissions: []string{"access"}}), Equals, false)
+}
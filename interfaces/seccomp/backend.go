@@ -162,6 +162,12 @@ func bpfBinPath(srcName string) string {
 	return filepath.Join(dirs.SnapSeccompDir, strings.TrimSuffix(srcName, ".src")+".bin")
 }
 
+// ConcurrentSetup returns true, the seccomp profiles of each snap are
+// compiled separately.
+func (b *Backend) ConcurrentSetup() bool {
+	return true
+}
+
 // Setup creates seccomp profiles specific to a given snap.
 // The snap can be in developer mode to make security violations non-fatal to
 // the offending application process.
@@ -226,6 +232,12 @@ func (b *Backend) Setup(snapInfo *snap.Info, opts interfaces.ConfinementOptions,
 	return nil
 }
 
+// InstalledProfiles returns the source of the seccomp profiles of the
+// given snap, the compiled profiles are not included.
+func (b *Backend) InstalledProfiles(snapName string) (map[string]string, error) {
+	return interfaces.ReadProfiles(dirs.SnapSeccompDir, []string{interfaces.SecurityTagGlob(snapName) + ".src"})
+}
+
 // Remove removes seccomp profiles of a given snap.
 func (b *Backend) Remove(snapName string) error {
 	glob := interfaces.SecurityTagGlob(snapName)
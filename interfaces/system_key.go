@@ -20,6 +20,8 @@
 package interfaces
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -254,6 +256,24 @@ func SystemKeyMismatch() (bool, error) {
 	return !reflect.DeepEqual(mySystemKey, &diskSystemKey), nil
 }
 
+// SystemKeyDigest returns a digest of the system-key expected by the
+// running binary. Like in SystemKeyMismatch the
+// apparmor-parser-features are not taken into account.
+func SystemKeyDigest() (string, error) {
+	mySystemKey, err := generateSystemKey()
+	if err != nil {
+		return "", err
+	}
+	sk := *mySystemKey
+	sk.AppArmorParserFeatures = nil
+	sks, err := json.Marshal(&sk)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(sks)
+	return hex.EncodeToString(h[:]), nil
+}
+
 func MockSystemKey(s string) func() {
 	var sk systemKey
 	err := json.Unmarshal([]byte(s), &sk)
@@ -268,3 +268,24 @@ func (s *systemKeySuite) TestStaticVersion(c *C) {
 		"CgroupVersion:",
 	}, " ")+"}")
 }
+
+func (s *systemKeySuite) TestSystemKeyDigest(c *C) {
+	restore := interfaces.MockSystemKey(`{"build-id": "7a94e9736c091b3984bd63f5aebfc883c4d859e0", "apparmor-parser-features": ["a"]}`)
+	digest1, err := interfaces.SystemKeyDigest()
+	c.Assert(err, IsNil)
+	c.Check(digest1, HasLen, 64)
+	restore()
+
+	// the apparmor-parser-features are not part of the digest
+	restore = interfaces.MockSystemKey(`{"build-id": "7a94e9736c091b3984bd63f5aebfc883c4d859e0", "apparmor-parser-features": ["b"]}`)
+	digest2, err := interfaces.SystemKeyDigest()
+	c.Assert(err, IsNil)
+	c.Check(digest2, Equals, digest1)
+	restore()
+
+	restore = interfaces.MockSystemKey(`{"build-id": "other-build-id"}`)
+	defer restore()
+	digest3, err := interfaces.SystemKeyDigest()
+	c.Assert(err, IsNil)
+	c.Check(digest3, Not(Equals), digest1)
+}
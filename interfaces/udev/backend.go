@@ -30,12 +30,14 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/sandbox/cgroup"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/timings"
 )
 
@@ -65,17 +67,75 @@ func snapRulesFilePath(snapName string) string {
 //
 // If the method fails it should be re-tried (with a sensible strategy) by the caller.
 func (b *Backend) Setup(snapInfo *snap.Info, opts interfaces.ConfinementOptions, repo *interfaces.Repository, tm timings.Measurer) error {
+	changed, subsystemTriggers, err := b.writeRules(snapInfo, opts, repo)
+	if err != nil || !changed {
+		return err
+	}
+
+	// FIXME: somehow detect the interfaces that were disconnected and set
+	// subsystemTriggers appropriately. ATM, it is always going to be empty
+	// on disconnect.
+	return reloadRules(subsystemTriggers)
+}
+
+// SetupMany creates udev rules of multiple snaps. The rules of all snaps
+// are written first, after which the udev database is reloaded and the
+// devices of the union of the requested subsystems are re-triggered only
+// once, if any of the rules changed and reloads are not deferred, see
+// DeferReload.
+//
+// Errors are collected for each snap without interrupting processing.
+func (b *Backend) SetupMany(snaps []*snap.Info, confinement func(snapName string) interfaces.ConfinementOptions, repo *interfaces.Repository, tm timings.Measurer) []error {
+	var errors []error
+	var anyChanged bool
+	var allTriggers []string
+	seen := make(map[string]bool)
+	for _, snapInfo := range snaps {
+		opts := confinement(snapInfo.InstanceName())
+		changed, subsystemTriggers, err := b.writeRules(snapInfo, opts, repo)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("cannot setup udev rules for snap %q: %s", snapInfo.InstanceName(), err))
+			continue
+		}
+		if !changed {
+			continue
+		}
+		anyChanged = true
+		for _, subsystem := range subsystemTriggers {
+			if !seen[subsystem] {
+				seen[subsystem] = true
+				allTriggers = append(allTriggers, subsystem)
+			}
+		}
+	}
+
+	if anyChanged {
+		var err error
+		timings.Run(tm, "reload-udev-rules[many]", fmt.Sprintf("reload udev rules of %d snaps", len(snaps)), func(nesttm timings.Measurer) {
+			err = reloadRules(allTriggers)
+		})
+		if err != nil {
+			errors = append(errors, err)
+		}
+	}
+	return errors
+}
+
+// writeRules writes or removes the udev rules file of the given snap and
+// returns whether it changed along with the subsystems that should be
+// re-triggered.
+func (b *Backend) writeRules(snapInfo *snap.Info, opts interfaces.ConfinementOptions, repo *interfaces.Repository) (changed bool, subsystemTriggers []string, err error) {
 	snapName := snapInfo.InstanceName()
 	spec, err := repo.SnapSpecification(b.Name(), snapName)
 	if err != nil {
-		return fmt.Errorf("cannot obtain udev specification for snap %q: %s", snapName, err)
+		return false, nil, fmt.Errorf("cannot obtain udev specification for snap %q: %s", snapName, err)
 	}
 	content := b.deriveContent(spec.(*Specification), snapInfo)
-	subsystemTriggers := spec.(*Specification).TriggeredSubsystems()
+	subsystemTriggers = spec.(*Specification).TriggeredSubsystems()
 
 	dir := dirs.SnapUdevRulesDir
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("cannot create directory for udev rules %q: %s", dir, err)
+		return false, nil, fmt.Errorf("cannot create directory for udev rules %q: %s", dir, err)
 	}
 
 	rulesFilePath := snapRulesFilePath(snapInfo.InstanceName())
@@ -84,15 +144,13 @@ func (b *Backend) Setup(snapInfo *snap.Info, opts interfaces.ConfinementOptions,
 		// Make sure that the rules file gets removed when we don't have any
 		// content and exists.
 		err = os.Remove(rulesFilePath)
-		if err != nil && !os.IsNotExist(err) {
-			return err
-		} else if err == nil {
-			// FIXME: somehow detect the interfaces that were
-			// disconnected and set subsystemTriggers appropriately.
-			// ATM, it is always going to be empty on disconnect.
-			return ReloadRules(subsystemTriggers)
+		if os.IsNotExist(err) {
+			return false, nil, nil
 		}
-		return nil
+		if err != nil {
+			return false, nil, err
+		}
+		return true, subsystemTriggers, nil
 	}
 
 	var buffer bytes.Buffer
@@ -119,15 +177,16 @@ func (b *Backend) Setup(snapInfo *snap.Info, opts interfaces.ConfinementOptions,
 	// udev rules when not needed.
 	err = osutil.EnsureFileState(rulesFilePath, rulesFileState)
 	if err == osutil.ErrSameState {
-		return nil
+		return false, nil, nil
 	} else if err != nil {
-		return err
+		return false, nil, err
 	}
+	return true, subsystemTriggers, nil
+}
 
-	// FIXME: somehow detect the interfaces that were disconnected and set
-	// subsystemTriggers appropriately. ATM, it is always going to be empty
-	// on disconnect.
-	return ReloadRules(subsystemTriggers)
+// InstalledProfiles returns the udev rules of the given snap.
+func (b *Backend) InstalledProfiles(snapName string) (map[string]string, error) {
+	return interfaces.ReadProfiles(dirs.SnapUdevRulesDir, []string{filepath.Base(snapRulesFilePath(snapName))})
 }
 
 // Remove removes udev rules specific to a given snap.
@@ -149,9 +208,71 @@ func (b *Backend) Remove(snapName string) error {
 	// FIXME: somehow detect the interfaces that were disconnected and set
 	// subsystemTriggers appropriately. ATM, it is always going to be empty
 	// on disconnect.
+	return reloadRules(nil)
+}
+
+// pendingReload tracks the deferral of the reloads of the udev rules,
+// see DeferReload.
+var pendingReload struct {
+	mu       sync.Mutex
+	deferred int
+	pending  bool
+	triggers []string
+}
+
+// DeferReload postpones the reloads of the udev database, and the
+// re-triggering of devices, that otherwise happen whenever the rules of
+// a snap are changed or removed, until the returned function is called.
+// If rules changed meanwhile, the database is then reloaded once and the
+// union of the requested subsystems is re-triggered.
+//
+// Deferrals can be nested, the database is reloaded once all of them
+// have been flushed.
+func (b *Backend) DeferReload() (flush func() error) {
+	pendingReload.mu.Lock()
+	defer pendingReload.mu.Unlock()
+	pendingReload.deferred++
+
+	flushed := false
+	return func() error {
+		pendingReload.mu.Lock()
+		defer pendingReload.mu.Unlock()
+		if flushed {
+			return nil
+		}
+		flushed = true
+		pendingReload.deferred--
+		if pendingReload.deferred > 0 || !pendingReload.pending {
+			return nil
+		}
+		triggers := pendingReload.triggers
+		pendingReload.pending = false
+		pendingReload.triggers = nil
+		return ReloadRules(triggers)
+	}
+}
+
+// Reload reloads the udev database and re-triggers the devices right
+// away, regardless of any deferral.
+func (b *Backend) Reload() error {
 	return ReloadRules(nil)
 }
 
+func reloadRules(subsystemTriggers []string) error {
+	pendingReload.mu.Lock()
+	defer pendingReload.mu.Unlock()
+	if pendingReload.deferred > 0 {
+		pendingReload.pending = true
+		for _, subsystem := range subsystemTriggers {
+			if !strutil.ListContains(pendingReload.triggers, subsystem) {
+				pendingReload.triggers = append(pendingReload.triggers, subsystem)
+			}
+		}
+		return nil
+	}
+	return ReloadRules(subsystemTriggers)
+}
+
 func (b *Backend) deriveContent(spec *Specification, snapInfo *snap.Info) (content []string) {
 	for _, snippet := range spec.Snippets() {
 		content = append(content, snippet)
@@ -533,3 +533,101 @@ func (s *backendSuite) TestSandboxFeatures(c *C) {
 		"tagging",
 	})
 }
+
+func (s *backendSuite) TestSetupManyReloadsRulesOnce(c *C) {
+	samba := s.InstallSnap(c, interfaces.ConfinementOptions{}, "", ifacetest.SambaYamlV1, 0)
+	foo := s.InstallSnap(c, interfaces.ConfinementOptions{}, "", ifacetest.HookYaml, 0)
+
+	s.Iface.UDevPermanentSlotCallback = func(spec *udev.Specification, slot *snap.SlotInfo) error {
+		spec.TriggerSubsystem("input")
+		spec.AddSnippet("dummy")
+		return nil
+	}
+	s.Iface.UDevPermanentPlugCallback = func(spec *udev.Specification, plug *snap.PlugInfo) error {
+		spec.TriggerSubsystem("input")
+		spec.TriggerSubsystem("input/key")
+		spec.AddSnippet("dummy")
+		return nil
+	}
+	s.udevadmCmd.ForgetCalls()
+
+	confinement := func(snapName string) interfaces.ConfinementOptions { return interfaces.ConfinementOptions{} }
+	errs := s.Backend.(*udev.Backend).SetupMany([]*snap.Info{samba, foo}, confinement, s.Repo, s.meas)
+	c.Assert(errs, HasLen, 0)
+
+	for _, name := range []string{"70-snap.samba.rules", "70-snap.foo.rules"} {
+		c.Check(filepath.Join(dirs.SnapUdevRulesDir, name), testutil.FilePresent)
+	}
+	// the rules were reloaded and each subsystem triggered only once
+	c.Check(s.udevadmCmd.Calls(), DeepEquals, [][]string{
+		{"udevadm", "control", "--reload-rules"},
+		{"udevadm", "trigger", "--subsystem-nomatch=input"},
+		{"udevadm", "trigger", "--subsystem-match=input"},
+		{"udevadm", "trigger", "--property-match=ID_INPUT_KEY=1", "--property-match=ID_INPUT_KEYBOARD!=1"},
+		{"udevadm", "settle", "--timeout=10"},
+	})
+
+	// nothing changed, nothing is reloaded
+	s.udevadmCmd.ForgetCalls()
+	errs = s.Backend.(*udev.Backend).SetupMany([]*snap.Info{samba, foo}, confinement, s.Repo, s.meas)
+	c.Assert(errs, HasLen, 0)
+	c.Check(s.udevadmCmd.Calls(), HasLen, 0)
+}
+
+func (s *backendSuite) TestDeferReload(c *C) {
+	s.Iface.UDevPermanentSlotCallback = func(spec *udev.Specification, slot *snap.SlotInfo) error {
+		spec.TriggerSubsystem("input")
+		spec.AddSnippet("dummy")
+		return nil
+	}
+	s.Iface.UDevPermanentPlugCallback = func(spec *udev.Specification, plug *snap.PlugInfo) error {
+		spec.TriggerSubsystem("input/key")
+		spec.AddSnippet("dummy")
+		return nil
+	}
+	s.udevadmCmd.ForgetCalls()
+
+	flush1 := s.Backend.(*udev.Backend).DeferReload()
+	flush2 := s.Backend.(*udev.Backend).DeferReload()
+	s.InstallSnap(c, interfaces.ConfinementOptions{}, "", ifacetest.SambaYamlV1, 0)
+	s.InstallSnap(c, interfaces.ConfinementOptions{}, "", ifacetest.HookYaml, 0)
+	c.Check(filepath.Join(dirs.SnapUdevRulesDir, "70-snap.samba.rules"), testutil.FilePresent)
+	c.Check(filepath.Join(dirs.SnapUdevRulesDir, "70-snap.foo.rules"), testutil.FilePresent)
+	c.Check(s.udevadmCmd.Calls(), HasLen, 0)
+
+	// nothing happens until all the deferrals are flushed
+	c.Assert(flush1(), IsNil)
+	c.Check(s.udevadmCmd.Calls(), HasLen, 0)
+	// flushing twice has no effect
+	c.Assert(flush1(), IsNil)
+	c.Check(s.udevadmCmd.Calls(), HasLen, 0)
+
+	c.Assert(flush2(), IsNil)
+	c.Check(s.udevadmCmd.Calls(), DeepEquals, [][]string{
+		{"udevadm", "control", "--reload-rules"},
+		{"udevadm", "trigger", "--subsystem-nomatch=input"},
+		{"udevadm", "trigger", "--subsystem-match=input"},
+		{"udevadm", "trigger", "--property-match=ID_INPUT_KEY=1", "--property-match=ID_INPUT_KEYBOARD!=1"},
+		{"udevadm", "settle", "--timeout=10"},
+	})
+
+	// nothing changed while deferred, nothing is reloaded
+	s.udevadmCmd.ForgetCalls()
+	flush := s.Backend.(*udev.Backend).DeferReload()
+	c.Assert(flush(), IsNil)
+	c.Check(s.udevadmCmd.Calls(), HasLen, 0)
+
+	// reloads are no longer deferred
+	c.Assert(s.Backend.Remove("samba"), IsNil)
+	c.Check(s.udevadmCmd.Calls(), HasLen, 4)
+}
+
+func (s *backendSuite) TestReload(c *C) {
+	c.Assert(s.Backend.(*udev.Backend).Reload(), IsNil)
+	c.Check(s.udevadmCmd.Calls(), DeepEquals, [][]string{
+		{"udevadm", "control", "--reload-rules"},
+		{"udevadm", "trigger", "--subsystem-nomatch=input"},
+		{"udevadm", "trigger", "--property-match=ID_INPUT_JOYSTICK=1"},
+		{"udevadm", "settle", "--timeout=10"},
+	})
+}
@@ -0,0 +1,182 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package osutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/snapcore/snapd/strutil"
+)
+
+// syncDir fsyncs the given directory, so that entries added to,
+// renamed in or removed from it are persisted.
+func syncDir(dir string) error {
+	if snapdUnsafeIO {
+		return nil
+	}
+	// XXX: if go switches to use aio_fsync, we need to open the dir for writing
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// syncTree fsyncs all regular files and directories under root,
+// including root itself.
+func syncTree(root string) error {
+	if snapdUnsafeIO {
+		return nil
+	}
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return f.Sync()
+	})
+}
+
+// AtomicReplaceDir replaces the directory dir with one populated by the
+// given function. The new content is prepared in a temporary directory
+// next to dir, created with the given permissions, which is synced to
+// disk and then renamed into place, so that dir is never observed
+// partially populated. Any previous content of dir is removed.
+//
+// If populate fails, the temporary directory is removed and dir is left
+// untouched.
+func AtomicReplaceDir(dir string, perm os.FileMode, populate func(tmpDir string) error) error {
+	dir = filepath.Clean(dir)
+	parent := filepath.Dir(dir)
+	// as with AtomicFile, the tilde makes this look like a backup
+	tmpDir := dir + "." + strutil.MakeRandomString(12) + "~"
+	if err := os.Mkdir(tmpDir, perm); err != nil {
+		return err
+	}
+	// Mkdir is subject to umask
+	if err := os.Chmod(tmpDir, perm); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+
+	if err := populate(tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	if err := syncTree(tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+
+	oldDir := ""
+	if IsDirectory(dir) {
+		oldDir = dir + "." + strutil.MakeRandomString(12) + ".old~"
+		if err := os.Rename(dir, oldDir); err != nil {
+			os.RemoveAll(tmpDir)
+			return err
+		}
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		if oldDir != "" {
+			// try to put the old content back
+			os.Rename(oldDir, dir)
+		}
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	if err := syncDir(parent); err != nil {
+		return err
+	}
+	if oldDir != "" {
+		if err := os.RemoveAll(oldDir); err != nil {
+			return fmt.Errorf("cannot remove previous content of %q: %v", dir, err)
+		}
+	}
+	return nil
+}
+
+// A SyncBatch batches the fsync of the directories holding files
+// written through it. The files themselves are synced as they are
+// written, but each directory is synced only once, on Commit. This
+// makes writing many files into the same few directories cheaper,
+// at the price of the renames only being durable once Commit
+// returns.
+//
+// A SyncBatch is not safe for concurrent use.
+type SyncBatch struct {
+	dirs map[string]bool
+}
+
+// NewSyncBatch returns an empty SyncBatch.
+func NewSyncBatch() *SyncBatch {
+	return &SyncBatch{dirs: make(map[string]bool)}
+}
+
+// AtomicWriteFile works like the package level AtomicWriteFile, but
+// the sync of the parent directory is deferred to Commit.
+func (b *SyncBatch) AtomicWriteFile(filename string, data []byte, perm os.FileMode, flags AtomicWriteFlags) error {
+	aw, err := NewAtomicFile(filename, perm, flags|atomicWriteNoDirSync, NoChown, NoChown)
+	if err != nil {
+		return err
+	}
+	defer aw.Cancel()
+
+	if _, err := aw.Write(data); err != nil {
+		return err
+	}
+	if err := aw.Commit(); err != nil {
+		return err
+	}
+	b.dirs[filepath.Dir(aw.target)] = true
+	return nil
+}
+
+// Pending returns the directories that are still to be synced, sorted.
+func (b *SyncBatch) Pending() []string {
+	dirs := make([]string, 0, len(b.dirs))
+	for dir := range b.dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// Commit syncs all the directories of the files written so far and
+// resets the batch.
+func (b *SyncBatch) Commit() error {
+	for _, dir := range b.Pending() {
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+		delete(b.dirs, dir)
+	}
+	return nil
+}
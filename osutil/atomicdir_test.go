@@ -0,0 +1,160 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package osutil_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type atomicDirSuite struct{}
+
+var _ = Suite(&atomicDirSuite{})
+
+func (s *atomicDirSuite) TestAtomicReplaceDirNew(c *C) {
+	d := c.MkDir()
+	target := filepath.Join(d, "target")
+
+	err := osutil.AtomicReplaceDir(target, 0755, func(tmpDir string) error {
+		c.Check(filepath.Dir(tmpDir), Equals, d)
+		c.Check(osutil.FileExists(target), Equals, false)
+		if err := os.MkdirAll(filepath.Join(tmpDir, "sub"), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(tmpDir, "sub/foo"), []byte("foo"), 0644)
+	})
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(target, "sub/foo"), testutil.FileEquals, "foo")
+
+	st, err := os.Stat(target)
+	c.Assert(err, IsNil)
+	c.Check(st.Mode().Perm(), Equals, os.FileMode(0755))
+
+	// nothing else left behind
+	entries, err := ioutil.ReadDir(d)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Check(entries[0].Name(), Equals, "target")
+}
+
+func (s *atomicDirSuite) TestAtomicReplaceDirReplaces(c *C) {
+	d := c.MkDir()
+	target := filepath.Join(d, "target")
+	c.Assert(os.MkdirAll(target, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(target, "old"), nil, 0644), IsNil)
+
+	err := osutil.AtomicReplaceDir(target, 0700, func(tmpDir string) error {
+		// the old content is still in place while populating
+		c.Check(filepath.Join(target, "old"), testutil.FilePresent)
+		return ioutil.WriteFile(filepath.Join(tmpDir, "new"), []byte("new"), 0644)
+	})
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(target, "old"), testutil.FileAbsent)
+	c.Check(filepath.Join(target, "new"), testutil.FileEquals, "new")
+
+	st, err := os.Stat(target)
+	c.Assert(err, IsNil)
+	c.Check(st.Mode().Perm(), Equals, os.FileMode(0700))
+
+	entries, err := ioutil.ReadDir(d)
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 1)
+}
+
+func (s *atomicDirSuite) TestAtomicReplaceDirPopulateError(c *C) {
+	d := c.MkDir()
+	target := filepath.Join(d, "target")
+	c.Assert(os.MkdirAll(target, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(target, "old"), nil, 0644), IsNil)
+
+	err := osutil.AtomicReplaceDir(target, 0755, func(tmpDir string) error {
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, "new"), nil, 0644); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	})
+	c.Assert(err, ErrorMatches, "boom")
+
+	// untouched
+	c.Check(filepath.Join(target, "old"), testutil.FilePresent)
+	c.Check(filepath.Join(target, "new"), testutil.FileAbsent)
+	entries, err := ioutil.ReadDir(d)
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 1)
+}
+
+func (s *atomicDirSuite) TestAtomicReplaceDirSafeIO(c *C) {
+	restore := osutil.SetUnsafeIO(false)
+	defer restore()
+
+	target := filepath.Join(c.MkDir(), "target")
+	err := osutil.AtomicReplaceDir(target, 0755, func(tmpDir string) error {
+		return ioutil.WriteFile(filepath.Join(tmpDir, "foo"), []byte("foo"), 0644)
+	})
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(target, "foo"), testutil.FileEquals, "foo")
+}
+
+func (s *atomicDirSuite) TestSyncBatch(c *C) {
+	restore := osutil.SetUnsafeIO(false)
+	defer restore()
+
+	d := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(d, "a"), 0755), IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(d, "b"), 0755), IsNil)
+
+	b := osutil.NewSyncBatch()
+	c.Check(b.Pending(), HasLen, 0)
+
+	c.Assert(b.AtomicWriteFile(filepath.Join(d, "b/1"), []byte("b1"), 0644, 0), IsNil)
+	c.Assert(b.AtomicWriteFile(filepath.Join(d, "a/1"), []byte("a1"), 0644, 0), IsNil)
+	c.Assert(b.AtomicWriteFile(filepath.Join(d, "a/2"), []byte("a2"), 0600, 0), IsNil)
+
+	c.Check(filepath.Join(d, "a/1"), testutil.FileEquals, "a1")
+	c.Check(filepath.Join(d, "a/2"), testutil.FileEquals, "a2")
+	c.Check(filepath.Join(d, "b/1"), testutil.FileEquals, "b1")
+	c.Check(b.Pending(), DeepEquals, []string{filepath.Join(d, "a"), filepath.Join(d, "b")})
+
+	c.Assert(b.Commit(), IsNil)
+	c.Check(b.Pending(), HasLen, 0)
+}
+
+func (s *atomicDirSuite) TestSyncBatchCommitError(c *C) {
+	restore := osutil.SetUnsafeIO(false)
+	defer restore()
+
+	d := c.MkDir()
+	b := osutil.NewSyncBatch()
+	c.Assert(b.AtomicWriteFile(filepath.Join(d, "sub/1"), nil, 0644, 0), ErrorMatches, ".*: no such file or directory")
+	c.Check(b.Pending(), HasLen, 0)
+
+	c.Assert(os.MkdirAll(filepath.Join(d, "sub"), 0755), IsNil)
+	c.Assert(b.AtomicWriteFile(filepath.Join(d, "sub/1"), nil, 0644, 0), IsNil)
+	c.Assert(os.RemoveAll(filepath.Join(d, "sub")), IsNil)
+	c.Assert(b.Commit(), ErrorMatches, ".*: no such file or directory")
+	c.Check(b.Pending(), DeepEquals, []string{filepath.Join(d, "sub")})
+}
@@ -21,14 +21,100 @@ package osutil
 
 import (
 	"os"
+	"runtime"
 	"syscall"
+	"unsafe"
 )
 
 const maxint = int64(^uint(0) >> 1)
 
 var maxcp = maxint // overridden in testing
 
+// ficloneRequest returns the FICLONE ioctl request, _IOW(0x94, 9, int),
+// whose encoding depends on the architecture.
+func ficloneRequest() uintptr {
+	switch runtime.GOARCH {
+	case "ppc64", "ppc64le", "mips", "mipsle", "mips64", "mips64le":
+		return 0x80049409
+	}
+	return 0x40049409
+}
+
+var (
+	// copy_file_range(2) is missing from the syscall package on most
+	// architectures
+	sysCopyFileRange = map[string]uintptr{
+		"386":     377,
+		"amd64":   326,
+		"arm":     391,
+		"arm64":   285,
+		"ppc64":   379,
+		"ppc64le": 379,
+		"s390x":   375,
+	}[runtime.GOARCH]
+)
+
+var (
+	ficlone       = doFiclone
+	copyFileRange = doCopyFileRange
+)
+
+// doFiclone makes dst share the extents of src, which is only supported
+// by some filesystems (btrfs, XFS) and only within the same filesystem.
+func doFiclone(dst, src uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst, ficloneRequest(), src)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func doCopyFileRange(srcFd, dstFd uintptr, srcOff, dstOff *int64, count int) (int, error) {
+	if sysCopyFileRange == 0 {
+		return 0, syscall.ENOSYS
+	}
+	n, _, errno := syscall.Syscall6(sysCopyFileRange, srcFd, uintptr(unsafe.Pointer(srcOff)), dstFd, uintptr(unsafe.Pointer(dstOff)), uintptr(count), 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+// copyRange copies size bytes from fin to fout using copy_file_range,
+// which lets the kernel (and the filesystem) do the copy without
+// bouncing the data through userspace.
+func copyRange(fin, fout fileish, size int64) error {
+	var srcOff, dstOff int64
+	for srcOff < size {
+		count := size - srcOff
+		if count > maxcp {
+			count = maxcp
+		}
+		n, err := copyFileRange(fin.Fd(), fout.Fd(), &srcOff, &dstOff, int(count))
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			// the file is shorter than it claims (or copying it
+			// this way is not actually supported)
+			return syscall.EINVAL
+		}
+	}
+	return nil
+}
+
 func doCopyFile(fin, fout fileish, fi os.FileInfo) error {
+	// the cheapest option is to not copy the data at all
+	if err := ficlone(fout.Fd(), fin.Fd()); err == nil {
+		return nil
+	}
+	// next best is to have the copy done in kernel; the offsets are
+	// explicit so that on failure the sendfile fallback below starts
+	// from scratch
+	if err := copyRange(fin, fout, fi.Size()); err == nil {
+		return nil
+	}
+
 	size := fi.Size()
 	var offset int64
 	for offset < size {
@@ -21,6 +21,7 @@ package osutil
 
 import (
 	"os"
+	"syscall"
 
 	. "gopkg.in/check.v1"
 
@@ -43,3 +44,80 @@ func (s *cpSuite) TestDoCpErr(c *C) {
 	// force an error by asking it to write to a readonly stream
 	c.Check(doCopyFile(f1, os.Stdin, st), NotNil)
 }
+
+func (s *cpSuite) TestCpPrefersFiclone(c *C) {
+	var tried, cloned, ranged bool
+	oldFiclone := ficlone
+	ficlone = func(dst, src uintptr) error {
+		tried = true
+		err := oldFiclone(dst, src)
+		cloned = err == nil
+		return err
+	}
+	defer func() { ficlone = oldFiclone }()
+	oldCopyFileRange := copyFileRange
+	copyFileRange = func(srcFd, dstFd uintptr, srcOff, dstOff *int64, count int) (int, error) {
+		ranged = true
+		return oldCopyFileRange(srcFd, dstFd, srcOff, dstOff, count)
+	}
+	defer func() { copyFileRange = oldCopyFileRange }()
+
+	c.Check(CopyFile(s.f1, s.f2, CopyFlagDefault), IsNil)
+	c.Check(s.f2, testutil.FileEquals, s.data)
+	c.Check(tried, Equals, true)
+	// whether the copy could be reflinked depends on the filesystem
+	// the test runs on, copy_file_range is only used when it could not
+	c.Check(ranged, Equals, !cloned)
+}
+
+func (s *cpSuite) TestCpFicloneUnsupported(c *C) {
+	ficlone = func(dst, src uintptr) error { return syscall.EOPNOTSUPP }
+	defer func() { ficlone = doFiclone }()
+	calls := 0
+	copyFileRange = func(srcFd, dstFd uintptr, srcOff, dstOff *int64, count int) (int, error) {
+		calls++
+		return doCopyFileRange(srcFd, dstFd, srcOff, dstOff, count)
+	}
+	defer func() { copyFileRange = doCopyFileRange }()
+	maxcp = 4
+	defer func() { maxcp = maxint }()
+
+	c.Check(CopyFile(s.f1, s.f2, CopyFlagDefault), IsNil)
+	c.Check(s.f2, testutil.FileEquals, s.data)
+	if sysCopyFileRange != 0 {
+		c.Check(calls, Equals, 3)
+	}
+}
+
+func (s *cpSuite) TestCpFallsBackToSendfile(c *C) {
+	ficlone = func(dst, src uintptr) error { return syscall.EXDEV }
+	defer func() { ficlone = doFiclone }()
+	calls := 0
+	copyFileRange = func(srcFd, dstFd uintptr, srcOff, dstOff *int64, count int) (int, error) {
+		calls++
+		if calls > 1 {
+			return 0, syscall.EXDEV
+		}
+		// a partial copy, which must be redone from scratch
+		*srcOff += 2
+		*dstOff += 2
+		return 2, nil
+	}
+	defer func() { copyFileRange = doCopyFileRange }()
+
+	c.Check(CopyFile(s.f1, s.f2, CopyFlagDefault), IsNil)
+	c.Check(s.f2, testutil.FileEquals, s.data)
+	c.Check(calls, Equals, 2)
+}
+
+func (s *cpSuite) TestCpCopyFileRangeShortFile(c *C) {
+	ficlone = func(dst, src uintptr) error { return syscall.EOPNOTSUPP }
+	defer func() { ficlone = doFiclone }()
+	copyFileRange = func(srcFd, dstFd uintptr, srcOff, dstOff *int64, count int) (int, error) {
+		return 0, nil
+	}
+	defer func() { copyFileRange = doCopyFileRange }()
+
+	c.Check(CopyFile(s.f1, s.f2, CopyFlagDefault), IsNil)
+	c.Check(s.f2, testutil.FileEquals, s.data)
+}
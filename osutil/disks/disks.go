@@ -0,0 +1,127 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package disks provides information about the disks of the system and the
+// partitions they carry, as reported by udev and sysfs.
+package disks
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// Disk is a block device that carries a partition table.
+type Disk interface {
+	// Dev returns the major:minor device number of the disk, for example
+	// "8:0".
+	Dev() string
+
+	// KernelDeviceNode returns the device node of the disk, for example
+	// /dev/sda.
+	KernelDeviceNode() string
+
+	// Size returns the size of the disk in bytes.
+	Size() (uint64, error)
+
+	// Partitions returns the partitions of the disk sorted by their
+	// start offset.
+	Partitions() ([]Partition, error)
+
+	// FindMatchingPartitionUUIDWithFsLabel returns the partition UUID of
+	// the partition of the disk whose filesystem carries the given label.
+	FindMatchingPartitionUUIDWithFsLabel(label string) (string, error)
+
+	// FindMatchingPartitionUUIDWithPartLabel returns the partition UUID of
+	// the partition of the disk with the given partition label.
+	FindMatchingPartitionUUIDWithPartLabel(label string) (string, error)
+
+	// MountPointIsFromDisk returns whether the filesystem mounted at the
+	// given mount point is on a partition of the disk.
+	MountPointIsFromDisk(mountpoint string) (bool, error)
+}
+
+// Partition is a single partition of a disk.
+type Partition struct {
+	// KernelDeviceNode is the device node of the partition, for example
+	// /dev/sda1.
+	KernelDeviceNode string
+	// Major and Minor are the device numbers of the partition.
+	Major int
+	Minor int
+	// PartitionLabel is the name of the partition in a GPT partition
+	// table.
+	PartitionLabel string
+	// PartitionUUID is the unique identifier of the partition.
+	PartitionUUID string
+	// PartitionType is the partition type as found in the partition
+	// table, a GUID for GPT or a hex number for MBR.
+	PartitionType string
+	// FilesystemLabel, FilesystemUUID and FilesystemType describe the
+	// filesystem of the partition, they are empty when the partition
+	// carries no filesystem.
+	FilesystemLabel string
+	FilesystemUUID  string
+	FilesystemType  string
+	// StartOffset is the offset of the partition within the disk, in
+	// bytes.
+	StartOffset uint64
+	// Size is the size of the partition in bytes.
+	Size uint64
+}
+
+// Dev returns the major:minor device number of the partition.
+func (p *Partition) Dev() string {
+	return fmt.Sprintf("%d:%d", p.Major, p.Minor)
+}
+
+// PartitionNotFoundError is returned when no partition of a disk matches
+// a search.
+type PartitionNotFoundError struct {
+	SearchType  string
+	SearchQuery string
+}
+
+func (e PartitionNotFoundError) Error() string {
+	return fmt.Sprintf("%s %q not found", e.SearchType, e.SearchQuery)
+}
+
+// BlkIDDecodeLabel decodes a label as encoded by blkid_encode_string() and
+// found in the ID_FS_LABEL_ENC udev property or in the /dev/disk/by-label
+// symlinks, where characters outside of a safe set are replaced by their
+// \xNN escape sequence.
+func BlkIDDecodeLabel(in string) (string, error) {
+	out := &bytes.Buffer{}
+	for i := 0; i < len(in); i++ {
+		if in[i] != '\\' {
+			out.WriteByte(in[i])
+			continue
+		}
+		if i+3 >= len(in) || in[i+1] != 'x' {
+			return "", fmt.Errorf("cannot decode label %q: invalid escape sequence at offset %d", in, i)
+		}
+		b, err := strconv.ParseUint(in[i+2:i+4], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("cannot decode label %q: invalid escape sequence at offset %d", in, i)
+		}
+		out.WriteByte(byte(b))
+		i += 3
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,288 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package disks
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// sectorSize is the unit of the partition offsets and sizes reported by
+// udev and of the sizes found in sysfs, regardless of the logical sector
+// size of the disk.
+const sectorSize = 512
+
+var udevadmProperties = func(device string) ([]byte, error) {
+	return exec.Command("udevadm", "info", "--query", "property", "--name", device).CombinedOutput()
+}
+
+func udevProperties(device string) (map[string]string, error) {
+	out, err := udevadmProperties(device)
+	if err != nil {
+		return nil, osutil.OutputErr(out, err)
+	}
+	props := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		props[kv[0]] = kv[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return props, nil
+}
+
+func devNumbers(props map[string]string) (major, minor int, err error) {
+	major, err = strconv.Atoi(props["MAJOR"])
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot parse major device number %q", props["MAJOR"])
+	}
+	minor, err = strconv.Atoi(props["MINOR"])
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot parse minor device number %q", props["MINOR"])
+	}
+	return major, minor, nil
+}
+
+type disk struct {
+	major    int
+	minor    int
+	devname  string
+	hasTable bool
+}
+
+func diskFromProperties(device string, props map[string]string) (*disk, error) {
+	if devtype := props["DEVTYPE"]; devtype != "disk" {
+		return nil, fmt.Errorf("device %q is not a disk, it has DEVTYPE of %q", device, devtype)
+	}
+	major, minor, err := devNumbers(props)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find disk %q: %v", device, err)
+	}
+	return &disk{
+		major:    major,
+		minor:    minor,
+		devname:  props["DEVNAME"],
+		hasTable: props["ID_PART_TABLE_TYPE"] != "",
+	}, nil
+}
+
+// DiskFromDeviceName returns the disk with the given device name, for
+// example /dev/sda.
+func DiskFromDeviceName(name string) (Disk, error) {
+	props, err := udevProperties(name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query udev properties of %q: %v", name, err)
+	}
+	return diskFromProperties(name, props)
+}
+
+// DiskFromMountPoint returns the disk carrying the partition mounted at the
+// given mount point.
+func DiskFromMountPoint(mountpoint string) (Disk, error) {
+	entry, err := mountInfoEntryFor(mountpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	props, err := udevProperties(entry.MountSource)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query udev properties of %q: %v", entry.MountSource, err)
+	}
+	if props["DEVTYPE"] == "disk" {
+		// the disk is used as is without a partition table
+		return diskFromProperties(entry.MountSource, props)
+	}
+
+	diskDev := props["ID_PART_ENTRY_DISK"]
+	if diskDev == "" {
+		return nil, fmt.Errorf("cannot find disk of %q mounted at %q: incomplete udev properties", entry.MountSource, mountpoint)
+	}
+	device := "/dev/block/" + diskDev
+	diskProps, err := udevProperties(device)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query udev properties of %q: %v", device, err)
+	}
+	return diskFromProperties(device, diskProps)
+}
+
+func mountInfoEntryFor(mountpoint string) (*osutil.MountInfoEntry, error) {
+	mountInfo, err := osutil.LoadMountInfo(filepath.Join(dirs.GlobalRootDir, osutil.ProcSelfMountInfo))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read mount info: %v", err)
+	}
+	var found *osutil.MountInfoEntry
+	for _, entry := range mountInfo {
+		// later mounts shadow the earlier ones
+		if entry.MountDir == mountpoint {
+			found = entry
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("cannot find mount point %q", mountpoint)
+	}
+	return found, nil
+}
+
+func (d *disk) Dev() string {
+	return fmt.Sprintf("%d:%d", d.major, d.minor)
+}
+
+func (d *disk) KernelDeviceNode() string {
+	return d.devname
+}
+
+func (d *disk) sysfsDir() string {
+	return filepath.Join(dirs.SysfsDir, "dev/block", d.Dev())
+}
+
+func (d *disk) Size() (uint64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(d.sysfsDir(), "size"))
+	if err != nil {
+		return 0, fmt.Errorf("cannot read size of disk %s: %v", d.devname, err)
+	}
+	sectors, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse size of disk %s: %v", d.devname, err)
+	}
+	return sectors * sectorSize, nil
+}
+
+func (d *disk) Partitions() ([]Partition, error) {
+	if !d.hasTable {
+		return nil, nil
+	}
+
+	entries, err := ioutil.ReadDir(d.sysfsDir())
+	if err != nil {
+		return nil, fmt.Errorf("cannot list partitions of disk %s: %v", d.devname, err)
+	}
+
+	var partitions []Partition
+	for _, entry := range entries {
+		// the directories of partitions carry a partition file with
+		// the partition number
+		partDir := filepath.Join(d.sysfsDir(), entry.Name())
+		if !osutil.FileExists(filepath.Join(partDir, "partition")) {
+			continue
+		}
+		devData, err := ioutil.ReadFile(filepath.Join(partDir, "dev"))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read device number of partition %s: %v", entry.Name(), err)
+		}
+		device := "/dev/block/" + strings.TrimSpace(string(devData))
+		props, err := udevProperties(device)
+		if err != nil {
+			return nil, fmt.Errorf("cannot query udev properties of %q: %v", device, err)
+		}
+		p, err := partitionFromProperties(props)
+		if err != nil {
+			return nil, fmt.Errorf("cannot use partition %s: %v", entry.Name(), err)
+		}
+		partitions = append(partitions, *p)
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i].StartOffset < partitions[j].StartOffset
+	})
+	return partitions, nil
+}
+
+func partitionFromProperties(props map[string]string) (*Partition, error) {
+	major, minor, err := devNumbers(props)
+	if err != nil {
+		return nil, err
+	}
+	partLabel, err := BlkIDDecodeLabel(props["ID_PART_ENTRY_NAME"])
+	if err != nil {
+		return nil, err
+	}
+	fsLabel, err := BlkIDDecodeLabel(props["ID_FS_LABEL_ENC"])
+	if err != nil {
+		return nil, err
+	}
+	start, err := strconv.ParseUint(props["ID_PART_ENTRY_OFFSET"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse partition offset %q", props["ID_PART_ENTRY_OFFSET"])
+	}
+	size, err := strconv.ParseUint(props["ID_PART_ENTRY_SIZE"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse partition size %q", props["ID_PART_ENTRY_SIZE"])
+	}
+	return &Partition{
+		KernelDeviceNode: props["DEVNAME"],
+		Major:            major,
+		Minor:            minor,
+		PartitionLabel:   partLabel,
+		PartitionUUID:    props["ID_PART_ENTRY_UUID"],
+		PartitionType:    props["ID_PART_ENTRY_TYPE"],
+		FilesystemLabel:  fsLabel,
+		FilesystemUUID:   props["ID_FS_UUID"],
+		FilesystemType:   props["ID_FS_TYPE"],
+		StartOffset:      start * sectorSize,
+		Size:             size * sectorSize,
+	}, nil
+}
+
+func (d *disk) findPartition(searchType, query string, match func(p *Partition) bool) (string, error) {
+	partitions, err := d.Partitions()
+	if err != nil {
+		return "", err
+	}
+	for i := range partitions {
+		if match(&partitions[i]) {
+			return partitions[i].PartitionUUID, nil
+		}
+	}
+	return "", PartitionNotFoundError{SearchType: searchType, SearchQuery: query}
+}
+
+func (d *disk) FindMatchingPartitionUUIDWithFsLabel(label string) (string, error) {
+	return d.findPartition("filesystem label", label, func(p *Partition) bool {
+		return p.FilesystemLabel == label
+	})
+}
+
+func (d *disk) FindMatchingPartitionUUIDWithPartLabel(label string) (string, error) {
+	return d.findPartition("partition label", label, func(p *Partition) bool {
+		return p.PartitionLabel == label
+	})
+}
+
+func (d *disk) MountPointIsFromDisk(mountpoint string) (bool, error) {
+	other, err := DiskFromMountPoint(mountpoint)
+	if err != nil {
+		return false, err
+	}
+	return other.Dev() == d.Dev(), nil
+}
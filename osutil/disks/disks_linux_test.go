@@ -0,0 +1,278 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package disks_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/disks"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type disksLinuxSuite struct {
+	testutil.BaseTest
+
+	udevProps map[string]string
+}
+
+var _ = Suite(&disksLinuxSuite{})
+
+const mockMountInfo = `26 27 8:3 / /writable rw,relatime shared:7 - ext4 /dev/sda3 rw,data=ordered
+27 28 8:1 / /boot/efi rw,relatime shared:8 - vfat /dev/sda1 rw
+28 29 8:16 / /media/whole rw,relatime shared:9 - ext4 /dev/sdb rw
+29 30 179:1 / /media/sd rw,relatime shared:10 - ext4 /dev/mmcblk0p1 rw
+`
+
+var mockUdevProps = map[string]string{
+	"/dev/sda": `DEVNAME=/dev/sda
+DEVTYPE=disk
+MAJOR=8
+MINOR=0
+ID_PART_TABLE_TYPE=gpt
+`,
+	"/dev/block/8:0": `DEVNAME=/dev/sda
+DEVTYPE=disk
+MAJOR=8
+MINOR=0
+ID_PART_TABLE_TYPE=gpt
+`,
+	"/dev/sda1": `DEVNAME=/dev/sda1
+DEVTYPE=partition
+MAJOR=8
+MINOR=1
+ID_PART_ENTRY_DISK=8:0
+`,
+	"/dev/block/8:1": `DEVNAME=/dev/sda1
+DEVTYPE=partition
+MAJOR=8
+MINOR=1
+ID_PART_ENTRY_DISK=8:0
+ID_PART_ENTRY_NAME=EFI\x20System
+ID_PART_ENTRY_OFFSET=2048
+ID_PART_ENTRY_SIZE=204800
+ID_PART_ENTRY_TYPE=c12a7328-f81f-11d2-ba4b-00a0c93ec93b
+ID_PART_ENTRY_UUID=a1-uuid
+ID_FS_LABEL_ENC=ubuntu-seed
+ID_FS_TYPE=vfat
+ID_FS_UUID=fs-a1-uuid
+`,
+	"/dev/sda3": `DEVNAME=/dev/sda3
+DEVTYPE=partition
+MAJOR=8
+MINOR=3
+ID_PART_ENTRY_DISK=8:0
+`,
+	"/dev/block/8:3": `DEVNAME=/dev/sda3
+DEVTYPE=partition
+MAJOR=8
+MINOR=3
+ID_PART_ENTRY_DISK=8:0
+ID_PART_ENTRY_NAME=writable
+ID_PART_ENTRY_OFFSET=208896
+ID_PART_ENTRY_SIZE=1024000
+ID_PART_ENTRY_TYPE=0fc63daf-8483-4772-8e79-3d69d8477de4
+ID_PART_ENTRY_UUID=a3-uuid
+ID_FS_LABEL_ENC=ubuntu\x20data
+ID_FS_TYPE=ext4
+ID_FS_UUID=fs-a3-uuid
+`,
+	"/dev/sdb": `DEVNAME=/dev/sdb
+DEVTYPE=disk
+MAJOR=8
+MINOR=16
+`,
+	"/dev/mmcblk0p1": `DEVNAME=/dev/mmcblk0p1
+DEVTYPE=partition
+MAJOR=179
+MINOR=1
+`,
+}
+
+func (s *disksLinuxSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+
+	mountInfo := filepath.Join(dirs.GlobalRootDir, osutil.ProcSelfMountInfo)
+	c.Assert(os.MkdirAll(filepath.Dir(mountInfo), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(mountInfo, []byte(mockMountInfo), 0644), IsNil)
+
+	// sysfs entries of sda with its partitions 1 and 3 and a
+	// non partition entry
+	for name, content := range map[string]string{
+		"size":            "2097152\n",
+		"sda1/partition":  "1\n",
+		"sda1/dev":        "8:1\n",
+		"sda3/partition":  "3\n",
+		"sda3/dev":        "8:3\n",
+		"queue/scheduler": "none\n",
+	} {
+		p := filepath.Join(dirs.SysfsDir, "dev/block/8:0", name)
+		c.Assert(os.MkdirAll(filepath.Dir(p), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(p, []byte(content), 0644), IsNil)
+	}
+
+	s.udevProps = mockUdevProps
+	s.AddCleanup(disks.MockUdevadmProperties(func(device string) ([]byte, error) {
+		props, ok := s.udevProps[device]
+		if !ok {
+			return []byte("Unknown device"), fmt.Errorf("exit status 4")
+		}
+		return []byte(props), nil
+	}))
+}
+
+func (s *disksLinuxSuite) TestDiskFromDeviceName(c *C) {
+	d, err := disks.DiskFromDeviceName("/dev/sda")
+	c.Assert(err, IsNil)
+	c.Check(d.Dev(), Equals, "8:0")
+	c.Check(d.KernelDeviceNode(), Equals, "/dev/sda")
+
+	size, err := d.Size()
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, uint64(1024*1024*1024))
+}
+
+func (s *disksLinuxSuite) TestDiskFromDeviceNameNotADisk(c *C) {
+	_, err := disks.DiskFromDeviceName("/dev/sda1")
+	c.Check(err, ErrorMatches, `device "/dev/sda1" is not a disk, it has DEVTYPE of "partition"`)
+
+	_, err = disks.DiskFromDeviceName("/dev/sdz")
+	c.Check(err, ErrorMatches, `cannot query udev properties of "/dev/sdz": Unknown device`)
+}
+
+func (s *disksLinuxSuite) TestDiskFromMountPoint(c *C) {
+	d, err := disks.DiskFromMountPoint("/writable")
+	c.Assert(err, IsNil)
+	c.Check(d.Dev(), Equals, "8:0")
+	c.Check(d.KernelDeviceNode(), Equals, "/dev/sda")
+
+	// disks with no partition table
+	d, err = disks.DiskFromMountPoint("/media/whole")
+	c.Assert(err, IsNil)
+	c.Check(d.Dev(), Equals, "8:16")
+	partitions, err := d.Partitions()
+	c.Assert(err, IsNil)
+	c.Check(partitions, HasLen, 0)
+}
+
+func (s *disksLinuxSuite) TestDiskFromMountPointErrors(c *C) {
+	_, err := disks.DiskFromMountPoint("/not/mounted")
+	c.Check(err, ErrorMatches, `cannot find mount point "/not/mounted"`)
+
+	_, err = disks.DiskFromMountPoint("/media/sd")
+	c.Check(err, ErrorMatches, `cannot find disk of "/dev/mmcblk0p1" mounted at "/media/sd": incomplete udev properties`)
+}
+
+func (s *disksLinuxSuite) TestPartitions(c *C) {
+	d, err := disks.DiskFromDeviceName("/dev/sda")
+	c.Assert(err, IsNil)
+
+	partitions, err := d.Partitions()
+	c.Assert(err, IsNil)
+	c.Check(partitions, DeepEquals, []disks.Partition{
+		{
+			KernelDeviceNode: "/dev/sda1",
+			Major:            8,
+			Minor:            1,
+			PartitionLabel:   "EFI System",
+			PartitionUUID:    "a1-uuid",
+			PartitionType:    "c12a7328-f81f-11d2-ba4b-00a0c93ec93b",
+			FilesystemLabel:  "ubuntu-seed",
+			FilesystemUUID:   "fs-a1-uuid",
+			FilesystemType:   "vfat",
+			StartOffset:      2048 * 512,
+			Size:             204800 * 512,
+		}, {
+			KernelDeviceNode: "/dev/sda3",
+			Major:            8,
+			Minor:            3,
+			PartitionLabel:   "writable",
+			PartitionUUID:    "a3-uuid",
+			PartitionType:    "0fc63daf-8483-4772-8e79-3d69d8477de4",
+			FilesystemLabel:  "ubuntu data",
+			FilesystemUUID:   "fs-a3-uuid",
+			FilesystemType:   "ext4",
+			StartOffset:      208896 * 512,
+			Size:             1024000 * 512,
+		},
+	})
+	c.Check(partitions[1].Dev(), Equals, "8:3")
+}
+
+func (s *disksLinuxSuite) TestPartitionsBadUdevProperties(c *C) {
+	s.udevProps = map[string]string{
+		"/dev/sda":       mockUdevProps["/dev/sda"],
+		"/dev/block/8:1": "MAJOR=8\nMINOR=1\n",
+	}
+
+	d, err := disks.DiskFromDeviceName("/dev/sda")
+	c.Assert(err, IsNil)
+	_, err = d.Partitions()
+	c.Check(err, ErrorMatches, `cannot use partition sda1: cannot parse partition offset ""`)
+}
+
+func (s *disksLinuxSuite) TestFindMatchingPartitionUUID(c *C) {
+	d, err := disks.DiskFromDeviceName("/dev/sda")
+	c.Assert(err, IsNil)
+
+	uuid, err := d.FindMatchingPartitionUUIDWithFsLabel("ubuntu data")
+	c.Assert(err, IsNil)
+	c.Check(uuid, Equals, "a3-uuid")
+
+	uuid, err = d.FindMatchingPartitionUUIDWithPartLabel("EFI System")
+	c.Assert(err, IsNil)
+	c.Check(uuid, Equals, "a1-uuid")
+
+	_, err = d.FindMatchingPartitionUUIDWithFsLabel("ubuntu-boot")
+	c.Check(err, FitsTypeOf, disks.PartitionNotFoundError{})
+	c.Check(err, ErrorMatches, `filesystem label "ubuntu-boot" not found`)
+
+	_, err = d.FindMatchingPartitionUUIDWithPartLabel("other")
+	c.Check(err, ErrorMatches, `partition label "other" not found`)
+}
+
+func (s *disksLinuxSuite) TestMountPointIsFromDisk(c *C) {
+	d, err := disks.DiskFromDeviceName("/dev/sda")
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		mountpoint string
+		fromDisk   bool
+	}{
+		{"/writable", true},
+		{"/boot/efi", true},
+		{"/media/whole", false},
+	} {
+		fromDisk, err := d.MountPointIsFromDisk(t.mountpoint)
+		c.Assert(err, IsNil, Commentf("%s", t.mountpoint))
+		c.Check(fromDisk, Equals, t.fromDisk, Commentf("%s", t.mountpoint))
+	}
+
+	_, err = d.MountPointIsFromDisk("/not/mounted")
+	c.Check(err, ErrorMatches, `cannot find mount point "/not/mounted"`)
+}
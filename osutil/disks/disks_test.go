@@ -0,0 +1,95 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package disks_test
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil/disks"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type disksSuite struct{}
+
+var _ = Suite(&disksSuite{})
+
+func (s *disksSuite) TestBlkIDDecodeLabel(c *C) {
+	for _, t := range []struct {
+		in, out string
+	}{
+		{"", ""},
+		{"ubuntu-data", "ubuntu-data"},
+		{`ubuntu\x20data`, "ubuntu data"},
+		{`\x2fslash\x5c`, `/slash\`},
+		{"ÄÖÜ", "ÄÖÜ"},
+	} {
+		out, err := disks.BlkIDDecodeLabel(t.in)
+		c.Check(err, IsNil, Commentf("%q", t.in))
+		c.Check(out, Equals, t.out, Commentf("%q", t.in))
+	}
+
+	for _, in := range []string{`\x2`, `\y20`, `\xzz`, `trailing\`} {
+		_, err := disks.BlkIDDecodeLabel(in)
+		c.Check(err, ErrorMatches, `cannot decode label .*: invalid escape sequence at offset [0-9]+`, Commentf("%q", in))
+	}
+}
+
+func (s *disksSuite) TestPartitionNotFoundError(c *C) {
+	err := disks.PartitionNotFoundError{SearchType: "filesystem label", SearchQuery: "ubuntu-data"}
+	c.Check(err, ErrorMatches, `filesystem label "ubuntu-data" not found`)
+}
+
+func (s *disksSuite) TestMockDiskMapping(c *C) {
+	d := &disks.MockDiskMapping{
+		DevNode:  "/dev/sda",
+		DevNum:   "8:0",
+		DiskSize: 1024,
+		DiskPartitions: []disks.Partition{
+			{PartitionLabel: "ubuntu-seed", PartitionUUID: "seed-uuid", FilesystemLabel: "ubuntu-seed"},
+			{PartitionLabel: "Writable", PartitionUUID: "data-uuid", FilesystemLabel: "ubuntu-data"},
+		},
+		MountPoints: []string{"/run/mnt/ubuntu-seed"},
+	}
+	c.Check(d.Dev(), Equals, "8:0")
+	c.Check(d.KernelDeviceNode(), Equals, "/dev/sda")
+	size, err := d.Size()
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, uint64(1024))
+
+	uuid, err := d.FindMatchingPartitionUUIDWithFsLabel("ubuntu-data")
+	c.Assert(err, IsNil)
+	c.Check(uuid, Equals, "data-uuid")
+	uuid, err = d.FindMatchingPartitionUUIDWithPartLabel("ubuntu-seed")
+	c.Assert(err, IsNil)
+	c.Check(uuid, Equals, "seed-uuid")
+	_, err = d.FindMatchingPartitionUUIDWithFsLabel("ubuntu-boot")
+	c.Check(err, FitsTypeOf, disks.PartitionNotFoundError{})
+	c.Check(err, ErrorMatches, `filesystem label "ubuntu-boot" not found`)
+
+	fromDisk, err := d.MountPointIsFromDisk("/run/mnt/ubuntu-seed")
+	c.Assert(err, IsNil)
+	c.Check(fromDisk, Equals, true)
+	fromDisk, err = d.MountPointIsFromDisk("/run/mnt/ubuntu-data")
+	c.Assert(err, IsNil)
+	c.Check(fromDisk, Equals, false)
+}
@@ -0,0 +1,85 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package disks
+
+// MockDiskMapping is an implementation of Disk for use in tests of packages
+// that probe disks.
+type MockDiskMapping struct {
+	// DevNode is the kernel device node of the disk, for example /dev/sda.
+	DevNode string
+	// DevNum is the major:minor device number of the disk.
+	DevNum string
+	// DiskSize is the size of the disk in bytes.
+	DiskSize uint64
+	// DiskPartitions are the partitions of the disk, sorted by their start
+	// offset.
+	DiskPartitions []Partition
+	// MountPoints are the mount points of filesystems on partitions of the
+	// disk.
+	MountPoints []string
+}
+
+var _ = Disk(&MockDiskMapping{})
+
+func (d *MockDiskMapping) Dev() string {
+	return d.DevNum
+}
+
+func (d *MockDiskMapping) KernelDeviceNode() string {
+	return d.DevNode
+}
+
+func (d *MockDiskMapping) Size() (uint64, error) {
+	return d.DiskSize, nil
+}
+
+func (d *MockDiskMapping) Partitions() ([]Partition, error) {
+	return d.DiskPartitions, nil
+}
+
+func (d *MockDiskMapping) findPartition(searchType, query string, match func(p *Partition) bool) (string, error) {
+	for i := range d.DiskPartitions {
+		if match(&d.DiskPartitions[i]) {
+			return d.DiskPartitions[i].PartitionUUID, nil
+		}
+	}
+	return "", PartitionNotFoundError{SearchType: searchType, SearchQuery: query}
+}
+
+func (d *MockDiskMapping) FindMatchingPartitionUUIDWithFsLabel(label string) (string, error) {
+	return d.findPartition("filesystem label", label, func(p *Partition) bool {
+		return p.FilesystemLabel == label
+	})
+}
+
+func (d *MockDiskMapping) FindMatchingPartitionUUIDWithPartLabel(label string) (string, error) {
+	return d.findPartition("partition label", label, func(p *Partition) bool {
+		return p.PartitionLabel == label
+	})
+}
+
+func (d *MockDiskMapping) MountPointIsFromDisk(mountpoint string) (bool, error) {
+	for _, mp := range d.MountPoints {
+		if mp == mountpoint {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,123 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package osutil
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// A FileWatcher tells whether a file has changed since it was last
+// asked. As files are often replaced rather than modified in place
+// (see AtomicWriteFile), it is the directory holding the file that is
+// watched, which must exist when the watcher is created.
+//
+// A FileWatcher does not use any goroutine, changes are only looked for
+// when calling Changed.
+type FileWatcher struct {
+	fd   int
+	name string
+	gone bool
+}
+
+const fileWatcherMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// NewFileWatcher returns a FileWatcher for the file with the given path.
+func NewFileWatcher(path string) (*FileWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	if _, err := syscall.InotifyAddWatch(fd, filepath.Dir(path), fileWatcherMask); err != nil {
+		syscall.Close(fd)
+		return nil, &os.PathError{Op: "inotify_add_watch", Path: filepath.Dir(path), Err: err}
+	}
+	w := &FileWatcher{fd: fd, name: filepath.Base(path)}
+	runtime.SetFinalizer(w, (*FileWatcher).Close)
+	return w, nil
+}
+
+// Changed returns whether the watched file changed since the watcher
+// was created or since the last call to Changed. Changes to the
+// directory itself, like it being removed, count as changes too.
+func (w *FileWatcher) Changed() (bool, error) {
+	changed := false
+	var buf [4096]byte
+	for {
+		n, err := syscall.Read(w.fd, buf[:])
+		if err == syscall.EAGAIN {
+			return changed, nil
+		}
+		if err != nil {
+			return changed, os.NewSyscallError("read", err)
+		}
+		if n < syscall.SizeofInotifyEvent {
+			return changed, nil
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(ev.Len)]
+			offset += syscall.SizeofInotifyEvent + int(ev.Len)
+
+			if ev.Mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF|syscall.IN_IGNORED) != 0 {
+				// the watch is gone, or follows the directory
+				// wherever it was moved to
+				w.gone = true
+				changed = true
+				continue
+			}
+			if ev.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				changed = true
+				continue
+			}
+			// the name is padded with NULs
+			name := string(nameBytes)
+			if i := bytes.IndexByte(nameBytes, 0); i >= 0 {
+				name = string(nameBytes[:i])
+			}
+			if name == w.name {
+				changed = true
+			}
+		}
+	}
+}
+
+// Gone returns whether the directory holding the file was removed or
+// moved away, as reported by Changed. Further changes to the file are not
+// noticed anymore then, and a new watcher needs to be created.
+func (w *FileWatcher) Gone() bool {
+	return w.gone
+}
+
+// Close releases the resources of the watcher.
+func (w *FileWatcher) Close() error {
+	if w.fd < 0 {
+		return syscall.EINVAL
+	}
+	err := syscall.Close(w.fd)
+	w.fd = -1
+	runtime.SetFinalizer(w, nil)
+	return err
+}
@@ -0,0 +1,119 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package osutil_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+type fileWatcherSuite struct{}
+
+var _ = Suite(&fileWatcherSuite{})
+
+func (s *fileWatcherSuite) TestChanged(c *C) {
+	d := c.MkDir()
+	p := filepath.Join(d, "auth.json")
+
+	w, err := osutil.NewFileWatcher(p)
+	c.Assert(err, IsNil)
+	defer w.Close()
+
+	changed, err := w.Changed()
+	c.Assert(err, IsNil)
+	c.Check(changed, Equals, false)
+
+	// creating
+	c.Assert(osutil.AtomicWriteFile(p, []byte("1"), 0600, 0), IsNil)
+	changed, err = w.Changed()
+	c.Assert(err, IsNil)
+	c.Check(changed, Equals, true)
+	// and asking again
+	changed, err = w.Changed()
+	c.Assert(err, IsNil)
+	c.Check(changed, Equals, false)
+
+	// modifying in place
+	c.Assert(ioutil.WriteFile(p, []byte("2"), 0600), IsNil)
+	changed, err = w.Changed()
+	c.Assert(err, IsNil)
+	c.Check(changed, Equals, true)
+
+	// other files do not matter
+	c.Assert(ioutil.WriteFile(filepath.Join(d, "other"), []byte("2"), 0600), IsNil)
+	changed, err = w.Changed()
+	c.Assert(err, IsNil)
+	c.Check(changed, Equals, false)
+
+	// removing
+	c.Assert(os.Remove(p), IsNil)
+	changed, err = w.Changed()
+	c.Assert(err, IsNil)
+	c.Check(changed, Equals, true)
+}
+
+func (s *fileWatcherSuite) TestChangedDirRemoved(c *C) {
+	d := filepath.Join(c.MkDir(), "dir")
+	c.Assert(os.Mkdir(d, 0755), IsNil)
+
+	w, err := osutil.NewFileWatcher(filepath.Join(d, "foo"))
+	c.Assert(err, IsNil)
+	defer w.Close()
+
+	c.Check(w.Gone(), Equals, false)
+	c.Assert(os.Remove(d), IsNil)
+	changed, err := w.Changed()
+	c.Assert(err, IsNil)
+	c.Check(changed, Equals, true)
+	c.Check(w.Gone(), Equals, true)
+
+	// a directory with the same name is not watched
+	c.Assert(os.Mkdir(d, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(d, "foo"), []byte("1"), 0600), IsNil)
+	changed, err = w.Changed()
+	c.Assert(err, IsNil)
+	c.Check(changed, Equals, false)
+}
+
+func (s *fileWatcherSuite) TestChangedDirMoved(c *C) {
+	d := filepath.Join(c.MkDir(), "dir")
+	c.Assert(os.Mkdir(d, 0755), IsNil)
+
+	w, err := osutil.NewFileWatcher(filepath.Join(d, "foo"))
+	c.Assert(err, IsNil)
+	defer w.Close()
+
+	c.Assert(os.Rename(d, d+".old"), IsNil)
+	changed, err := w.Changed()
+	c.Assert(err, IsNil)
+	c.Check(changed, Equals, true)
+	c.Check(w.Gone(), Equals, true)
+}
+
+func (s *fileWatcherSuite) TestNoDir(c *C) {
+	_, err := osutil.NewFileWatcher(filepath.Join(c.MkDir(), "missing", "foo"))
+	c.Check(err, ErrorMatches, "inotify_add_watch .*/missing: no such file or directory")
+	c.Check(os.IsNotExist(err), Equals, true)
+}
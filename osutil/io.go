@@ -37,6 +37,10 @@ type AtomicWriteFlags uint
 const (
 	// AtomicWriteFollow makes AtomicWriteFile follow symlinks
 	AtomicWriteFollow AtomicWriteFlags = 1 << iota
+
+	// atomicWriteNoDirSync leaves syncing the parent directory to the
+	// caller, see SyncBatch
+	atomicWriteNoDirSync
 )
 
 // Allow disabling sync for testing. This brings massive improvements on
@@ -58,6 +62,8 @@ type AtomicFile struct {
 	gid     sys.GroupID
 	closed  bool
 	renamed bool
+
+	noDirSync bool
 }
 
 // NewAtomicFile builds an AtomicFile backed by an *os.File that will have
@@ -101,11 +107,12 @@ func NewAtomicFile(filename string, perm os.FileMode, flags AtomicWriteFlags, ui
 	}
 
 	return &AtomicFile{
-		File:    fd,
-		target:  filename,
-		tmpname: tmp,
-		uid:     uid,
-		gid:     gid,
+		File:      fd,
+		target:    filename,
+		tmpname:   tmp,
+		uid:       uid,
+		gid:       gid,
+		noDirSync: flags&atomicWriteNoDirSync != 0,
 	}, nil
 }
 
@@ -157,7 +164,7 @@ func (aw *AtomicFile) Commit() error {
 	}
 
 	var dir *os.File
-	if !snapdUnsafeIO {
+	if !snapdUnsafeIO && !aw.noDirSync {
 		// XXX: if go switches to use aio_fsync, we need to open the dir for writing
 		d, err := os.Open(filepath.Dir(aw.target))
 		if err != nil {
@@ -165,7 +172,8 @@ func (aw *AtomicFile) Commit() error {
 		}
 		dir = d
 		defer dir.Close()
-
+	}
+	if !snapdUnsafeIO {
 		if err := aw.Sync(); err != nil {
 			return err
 		}
@@ -180,7 +188,7 @@ func (aw *AtomicFile) Commit() error {
 	}
 	aw.renamed = true // it is now too late to Cancel()
 
-	if !snapdUnsafeIO {
+	if dir != nil {
 		return dir.Sync()
 	}
 
@@ -0,0 +1,127 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package squashfs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// compression IDs as found in the squashfs superblock
+var compressionNames = map[uint16]string{
+	1: "gzip",
+	2: "lzma",
+	3: "lzo",
+	4: "xz",
+	5: "lz4",
+	6: "zstd",
+}
+
+// the magic is followed by inode count, modification time, block size
+// and fragment count, all 32 bit, then by the compression ID
+const compressionOffset = 20
+
+// Compression returns the name of the compression used by the squashfs
+// image at the given path.
+func Compression(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var header [compressionOffset + 2]byte
+	if _, err := io.ReadFull(f, header[:]); err != nil {
+		return "", fmt.Errorf("cannot read squashfs superblock of %q: %v", path, err)
+	}
+	if !bytes.Equal(header[:4], []byte("hsqs")) {
+		return "", fmt.Errorf("%q is not a squashfs image", path)
+	}
+	id := binary.LittleEndian.Uint16(header[compressionOffset:])
+	name, ok := compressionNames[id]
+	if !ok {
+		return "", fmt.Errorf("unknown squashfs compression %d in %q", id, path)
+	}
+	return name, nil
+}
+
+// zstd support in squashfs appeared in linux 4.14
+var kernelZstdVersion = []int{4, 14}
+
+var kernelMajorMinor = regexp.MustCompile(`^([0-9]+)\.([0-9]+)`)
+
+var kernelSupportsZstdImpl = func() bool {
+	release := osutil.KernelVersion()
+	// the kernel configuration is authoritative when it is around
+	f, err := os.Open(filepath.Join(dirs.GlobalRootDir, "/boot", "config-"+release))
+	if err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			switch scanner.Text() {
+			case "CONFIG_SQUASHFS_ZSTD=y":
+				return true
+			case "# CONFIG_SQUASHFS_ZSTD is not set":
+				return false
+			}
+		}
+		return false
+	}
+
+	m := kernelMajorMinor.FindStringSubmatch(release)
+	if m == nil {
+		return false
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	if major != kernelZstdVersion[0] {
+		return major > kernelZstdVersion[0]
+	}
+	return minor >= kernelZstdVersion[1]
+}
+
+// MockKernelSupportsZstd is exported so KernelSupportsCompression can be
+// overridden by testing.
+func MockKernelSupportsZstd(r bool) func() {
+	old := kernelSupportsZstdImpl
+	kernelSupportsZstdImpl = func() bool {
+		return r
+	}
+	return func() { kernelSupportsZstdImpl = old }
+}
+
+// KernelSupportsCompression returns true if the running kernel can mount
+// squashfs images using the given compression.
+func KernelSupportsCompression(compression string) bool {
+	if compression == "zstd" {
+		return kernelSupportsZstdImpl()
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package squashfs_test
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/squashfs"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type compressionSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&compressionSuite{})
+
+func (s *compressionSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	s.AddCleanup(squashfs.MockNeedsFuse(false))
+	// no fuse helpers unless mocked
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", c.MkDir())
+	s.AddCleanup(func() { os.Setenv("PATH", oldPath) })
+}
+
+func writeSuperblock(c *C, id uint16) string {
+	sb := make([]byte, 96)
+	copy(sb, "hsqs")
+	binary.LittleEndian.PutUint16(sb[20:], id)
+	path := filepath.Join(c.MkDir(), "foo.snap")
+	c.Assert(ioutil.WriteFile(path, sb, 0644), IsNil)
+	return path
+}
+
+func (s *compressionSuite) TestCompression(c *C) {
+	for id, name := range map[uint16]string{1: "gzip", 3: "lzo", 4: "xz", 6: "zstd"} {
+		comp, err := squashfs.Compression(writeSuperblock(c, id))
+		c.Assert(err, IsNil)
+		c.Check(comp, Equals, name)
+	}
+
+	_, err := squashfs.Compression(writeSuperblock(c, 42))
+	c.Check(err, ErrorMatches, `unknown squashfs compression 42 in ".*/foo.snap"`)
+
+	path := filepath.Join(c.MkDir(), "foo.snap")
+	c.Assert(ioutil.WriteFile(path, make([]byte, 96), 0644), IsNil)
+	_, err = squashfs.Compression(path)
+	c.Check(err, ErrorMatches, `".*/foo.snap" is not a squashfs image`)
+
+	c.Assert(ioutil.WriteFile(path, []byte("hsqs"), 0644), IsNil)
+	_, err = squashfs.Compression(path)
+	c.Check(err, ErrorMatches, `cannot read squashfs superblock of ".*/foo.snap": unexpected EOF`)
+}
+
+func (s *compressionSuite) TestKernelSupportsZstdFromVersion(c *C) {
+	for _, t := range []struct {
+		version   string
+		supported bool
+	}{
+		{"4.4.0-1-generic", false},
+		{"4.13.9", false},
+		{"4.14.0", true},
+		{"5.4.0-42-generic", true},
+		{"3.19.0", false},
+		{"unknown", false},
+	} {
+		restore := osutil.MockKernelVersion(t.version)
+		c.Check(squashfs.KernelSupportsCompression("zstd"), Equals, t.supported, Commentf(t.version))
+		c.Check(squashfs.KernelSupportsCompression("xz"), Equals, true)
+		restore()
+	}
+}
+
+func (s *compressionSuite) TestKernelSupportsZstdFromConfig(c *C) {
+	defer osutil.MockKernelVersion("5.4.0-42-generic")()
+	config := filepath.Join(dirs.GlobalRootDir, "/boot/config-5.4.0-42-generic")
+	c.Assert(os.MkdirAll(filepath.Dir(config), 0755), IsNil)
+
+	c.Assert(ioutil.WriteFile(config, []byte("CONFIG_SQUASHFS=y\n# CONFIG_SQUASHFS_ZSTD is not set\n"), 0644), IsNil)
+	c.Check(squashfs.KernelSupportsCompression("zstd"), Equals, false)
+
+	c.Assert(ioutil.WriteFile(config, []byte("CONFIG_SQUASHFS=y\nCONFIG_SQUASHFS_ZSTD=y\n"), 0644), IsNil)
+	c.Check(squashfs.KernelSupportsCompression("zstd"), Equals, true)
+}
+
+func (s *compressionSuite) TestFsTypeForCompression(c *C) {
+	restore := squashfs.MockKernelSupportsZstd(true)
+	defer restore()
+	fstype, options, err := squashfs.FsTypeForCompression("zstd")
+	c.Assert(err, IsNil)
+	c.Check(fstype, Equals, "squashfs")
+	c.Check(options, DeepEquals, []string{"ro", "x-gdu.hide"})
+
+	restore()
+	defer squashfs.MockKernelSupportsZstd(false)()
+	fstype, _, err = squashfs.FsTypeForCompression("xz")
+	c.Assert(err, IsNil)
+	c.Check(fstype, Equals, "squashfs")
+
+	_, _, err = squashfs.FsTypeForCompression("zstd")
+	c.Check(err, ErrorMatches, `cannot mount zstd compressed squashfs: not supported by the kernel and squashfuse is not available`)
+
+	squashfuse := testutil.MockCommand(c, "squashfuse", "")
+	defer squashfuse.Restore()
+	fstype, options, err = squashfs.FsTypeForCompression("zstd")
+	c.Assert(err, IsNil)
+	c.Check(fstype, Equals, "fuse.squashfuse")
+	c.Check(options, DeepEquals, []string{"ro", "x-gdu.hide", "allow_other"})
+}
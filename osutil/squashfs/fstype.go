@@ -20,6 +20,7 @@
 package squashfs
 
 import (
+	"fmt"
 	"os/exec"
 	"strings"
 
@@ -65,10 +66,27 @@ func NeedsFuse() bool {
 // FsType returns what fstype to use for squashfs mounts and what
 // mount options
 func FsType() (fstype string, options []string, err error) {
+	return fsType(NeedsFuse())
+}
+
+// FsTypeForCompression returns what fstype to use for mounting squashfs
+// images using the given compression and what mount options. Images the
+// kernel cannot mount are mounted with fuse instead.
+func FsTypeForCompression(compression string) (fstype string, options []string, err error) {
+	if KernelSupportsCompression(compression) {
+		return FsType()
+	}
+	if !osutil.ExecutableExists("squashfuse") && !osutil.ExecutableExists("snapfuse") {
+		return "", nil, fmt.Errorf("cannot mount %s compressed squashfs: not supported by the kernel and squashfuse is not available", compression)
+	}
+	return fsType(true)
+}
+
+func fsType(useFuse bool) (fstype string, options []string, err error) {
 	fstype = "squashfs"
 	options = []string{"ro", "x-gdu.hide"}
 
-	if NeedsFuse() {
+	if useFuse {
 		options = append(options, "allow_other")
 		switch {
 		case osutil.ExecutableExists("squashfuse"):
@@ -27,6 +27,8 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/macaroon.v1"
 
@@ -62,6 +64,10 @@ type UserState struct {
 	Discharges      []string `json:"discharges,omitempty"`
 	StoreMacaroon   string   `json:"store-macaroon,omitempty"`
 	StoreDischarges []string `json:"store-discharges,omitempty"`
+
+	// NeedsReLogin is set when the store credentials of the user
+	// could not be renewed anymore and the user needs to log in again.
+	NeedsReLogin bool `json:"needs-relogin,omitempty"`
 }
 
 // HasStoreAuth returns true if the user has store authorization.
@@ -72,6 +78,79 @@ func (u *UserState) HasStoreAuth() bool {
 	return u.StoreMacaroon != ""
 }
 
+// StoreAuthExpiry returns when the store credentials of the user
+// expire, or the zero time if they do not expire.
+func (u *UserState) StoreAuthExpiry() time.Time {
+	if !u.HasStoreAuth() {
+		return time.Time{}
+	}
+	return earliestMacaroonExpiry(append([]string{u.StoreMacaroon}, u.StoreDischarges...))
+}
+
+// SessionExpiry returns when the store session of the device expires,
+// or the zero time if it does not expire.
+func (d *DeviceState) SessionExpiry() time.Time {
+	if d.SessionMacaroon == "" {
+		return time.Time{}
+	}
+	return earliestMacaroonExpiry([]string{d.SessionMacaroon})
+}
+
+func earliestMacaroonExpiry(serializedMacaroons []string) time.Time {
+	var earliest time.Time
+	for _, serialized := range serializedMacaroons {
+		expiry, err := MacaroonExpiry(serialized)
+		if err != nil {
+			continue
+		}
+		if !expiry.IsZero() && (earliest.IsZero() || expiry.Before(earliest)) {
+			earliest = expiry
+		}
+	}
+	return earliest
+}
+
+// the timestamps in expiry caveats, those without a zone are in UTC
+var expiryLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+}
+
+// MacaroonExpiry returns the expiry of the given serialized macaroon,
+// as set by its first party "<location>|expires|<timestamp>" caveats,
+// or the zero time if it has none.
+func MacaroonExpiry(serializedMacaroon string) (time.Time, error) {
+	m, err := MacaroonDeserialize(serializedMacaroon)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var expiry time.Time
+	for _, caveat := range m.Caveats() {
+		if caveat.Location != "" {
+			// third party caveat
+			continue
+		}
+		parts := strings.SplitN(caveat.Id, "|", 3)
+		if len(parts) != 3 || parts[1] != "expires" {
+			continue
+		}
+		var t time.Time
+		for _, layout := range expiryLayouts {
+			t, err = time.Parse(layout, parts[2])
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse macaroon expiry %q: %v", parts[2], err)
+		}
+		if expiry.IsZero() || t.Before(expiry) {
+			expiry = t
+		}
+	}
+	return expiry, nil
+}
+
 // MacaroonSerialize returns a store-compatible serialized representation of the given macaroon
 func MacaroonSerialize(m *macaroon.Macaroon) (string, error) {
 	marshalled, err := m.MarshalBinary()
@@ -22,6 +22,7 @@ package auth_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 	"gopkg.in/macaroon.v1"
@@ -55,6 +56,53 @@ func (s *authSuite) TestMacaroonSerialize(c *C) {
 	c.Check(deserialized, DeepEquals, m)
 }
 
+func makeMacaroon(c *C, caveats ...string) string {
+	m, err := macaroon.New([]byte("secret"), "some-id", "location")
+	c.Assert(err, IsNil)
+	for _, caveat := range caveats {
+		c.Assert(m.AddFirstPartyCaveat(caveat), IsNil)
+	}
+	serialized, err := auth.MacaroonSerialize(m)
+	c.Assert(err, IsNil)
+	return serialized
+}
+
+func (s *authSuite) TestMacaroonExpiry(c *C) {
+	expiry, err := auth.MacaroonExpiry(makeMacaroon(c, "some caveat"))
+	c.Assert(err, IsNil)
+	c.Check(expiry.IsZero(), Equals, true)
+
+	expiry, err = auth.MacaroonExpiry(makeMacaroon(c,
+		"login.ubuntu.com|valid_since|2019-05-01T10:00:00.000000",
+		"login.ubuntu.com|expires|2019-06-01T10:00:00.123456",
+		"other|expires|2019-05-20T10:00:00Z",
+	))
+	c.Assert(err, IsNil)
+	c.Check(expiry.Equal(time.Date(2019, 5, 20, 10, 0, 0, 0, time.UTC)), Equals, true)
+
+	_, err = auth.MacaroonExpiry(makeMacaroon(c, "login.ubuntu.com|expires|tomorrow"))
+	c.Assert(err, ErrorMatches, `cannot parse macaroon expiry "tomorrow": .*`)
+
+	_, err = auth.MacaroonExpiry("invalid")
+	c.Assert(err, NotNil)
+}
+
+func (s *authSuite) TestStoreAuthExpiry(c *C) {
+	user := &auth.UserState{}
+	c.Check(user.StoreAuthExpiry().IsZero(), Equals, true)
+
+	user.StoreMacaroon = makeMacaroon(c, "store|expires|2019-07-01T10:00:00Z")
+	c.Check(user.StoreAuthExpiry().Equal(time.Date(2019, 7, 1, 10, 0, 0, 0, time.UTC)), Equals, true)
+
+	user.StoreDischarges = []string{makeMacaroon(c, "login.ubuntu.com|expires|2019-06-01T10:00:00.000000")}
+	c.Check(user.StoreAuthExpiry().Equal(time.Date(2019, 6, 1, 10, 0, 0, 0, time.UTC)), Equals, true)
+
+	device := &auth.DeviceState{}
+	c.Check(device.SessionExpiry().IsZero(), Equals, true)
+	device.SessionMacaroon = makeMacaroon(c, "store|expires|2019-07-01T10:00:00Z")
+	c.Check(device.SessionExpiry().Equal(time.Date(2019, 7, 1, 10, 0, 0, 0, time.UTC)), Equals, true)
+}
+
 func (s *authSuite) TestMacaroonSerializeDeserializeStoreMacaroon(c *C) {
 	// sample serialized macaroon using store server setup.
 	serialized := `MDAxNmxvY2F0aW9uIGxvY2F0aW9uCjAwMTdpZGVudGlmaWVyIHNvbWUgaWQKMDAwZmNpZCBjYXZlYXQKMDAxOWNpZCAzcmQgcGFydHkgY2F2ZWF0CjAwNTF2aWQgcyvpXSVlMnj9wYw5b-WPCLjTnO_8lVzBrRr8tJfu9tOhPORbsEOFyBwPOM_YiiXJ_qh-Pp8HY0HsUueCUY4dxONLIxPWTdMzCjAwMTJjbCByZW1vdGUuY29tCjAwMmZzaWduYXR1cmUgcm_Gdz75wUCWF9KGXZQEANhwfvBcLNt9xXGfAmxurPMK`
@@ -20,20 +20,96 @@
 package overlord
 
 import (
+	"sync"
 	"time"
 
-	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
 type overlordStateBackend struct {
-	path           string
+	storage        stateStorage
 	ensureBefore   func(d time.Duration)
 	requestRestart func(t state.RestartType)
+
+	// checkpointDelay is the window within which consecutive
+	// checkpoints are coalesced into a single write, with no delay
+	// every checkpoint is written out right away
+	checkpointDelay time.Duration
+
+	mu       sync.Mutex
+	pending  []byte
+	timer    *time.Timer
+	writeErr error
+	// syncNext makes the next checkpoint be written out right away
+	syncNext bool
 }
 
 func (osb *overlordStateBackend) Checkpoint(data []byte) error {
-	return osutil.AtomicWriteFile(osb.path, data, 0600, 0)
+	osb.mu.Lock()
+	defer osb.mu.Unlock()
+	if osb.checkpointDelay <= 0 {
+		return osb.storage.Write(data)
+	}
+	if osb.writeErr != nil || osb.syncNext {
+		// a delayed write failed, or a restart was requested, write
+		// out synchronously so that the state keeps retrying until
+		// the problem is resolved or is on disk before the restart
+		osb.syncNext = false
+		osb.pending = data
+		return osb.flushLocked()
+	}
+	osb.pending = data
+	if osb.timer == nil {
+		osb.timer = time.AfterFunc(osb.checkpointDelay, osb.flushDelayed)
+	}
+	return nil
+}
+
+// CheckpointSync writes out the given checkpoint right away, along with
+// replacing any pending one.
+func (osb *overlordStateBackend) CheckpointSync(data []byte) error {
+	osb.mu.Lock()
+	defer osb.mu.Unlock()
+	osb.pending = data
+	return osb.flushLocked()
+}
+
+func (osb *overlordStateBackend) flushDelayed() {
+	osb.mu.Lock()
+	defer osb.mu.Unlock()
+	if err := osb.flushLocked(); err != nil {
+		logger.Noticef("cannot write delayed state checkpoint: %v", err)
+	}
+}
+
+func (osb *overlordStateBackend) flushLocked() error {
+	if osb.timer != nil {
+		osb.timer.Stop()
+		osb.timer = nil
+	}
+	if osb.pending == nil {
+		return nil
+	}
+	if err := osb.storage.Write(osb.pending); err != nil {
+		osb.writeErr = err
+		return err
+	}
+	osb.pending = nil
+	osb.writeErr = nil
+	return nil
+}
+
+// Close writes out the last checkpoint if it is still pending and
+// releases the resources held by the state storage.
+func (osb *overlordStateBackend) Close() error {
+	osb.mu.Lock()
+	defer osb.mu.Unlock()
+	err := osb.flushLocked()
+	if cerr := osb.storage.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
 }
 
 func (osb *overlordStateBackend) EnsureBefore(d time.Duration) {
@@ -41,5 +117,17 @@ func (osb *overlordStateBackend) EnsureBefore(d time.Duration) {
 }
 
 func (osb *overlordStateBackend) RequestRestart(t state.RestartType) {
+	// The state leading to the restart must reach the disk before the
+	// restart happens: write out the pending checkpoint now and the one
+	// done when the state is unlocked, which carries the restart request
+	// itself, without delay.
+	osb.mu.Lock()
+	if osb.checkpointDelay > 0 {
+		osb.syncNext = true
+		if err := osb.flushLocked(); err != nil {
+			logger.Noticef("cannot write state checkpoint before restart: %v", err)
+		}
+	}
+	osb.mu.Unlock()
 	osb.requestRestart(t)
 }
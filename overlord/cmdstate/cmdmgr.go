@@ -46,30 +46,48 @@ func (m *CommandManager) Ensure() error {
 var defaultExecTimeout = 5 * time.Second
 
 func doExec(t *state.Task, tomb *tomb.Tomb) error {
-	var argv []string
+	var argv, env []string
 	var tout time.Duration
+	var captureOutput bool
 
 	st := t.State()
 	st.Lock()
-	err1 := t.Get("argv", &argv)
-	err2 := t.Get("timeout", &tout)
-	st.Unlock()
-	if err1 != nil {
-		return err1
+	err := t.Get("argv", &argv)
+	if err == nil {
+		err = getOptional(t, "timeout", &tout)
+	}
+	if err == nil {
+		err = getOptional(t, "env", &env)
+	}
+	if err == nil {
+		err = getOptional(t, "capture-output", &captureOutput)
 	}
-	if err2 != nil && err2 != state.ErrNoState {
-		return err2
+	st.Unlock()
+	if err != nil {
+		return err
 	}
-	if err2 == state.ErrNoState {
+	if tout == 0 {
 		tout = defaultExecTimeout
 	}
 
-	if buf, err := osutil.RunAndWait(argv, nil, tout, tomb); err != nil {
+	buf, err := osutil.RunAndWait(argv, env, tout, tomb)
+	if err != nil {
 		st.Lock()
 		t.Errorf("# %s\n%s", strings.Join(argv, " "), buf)
 		st.Unlock()
 		return err
 	}
+	if captureOutput && len(buf) > 0 {
+		st.Lock()
+		t.Logf("# %s\n%s", strings.Join(argv, " "), buf)
+		st.Unlock()
+	}
+	return nil
+}
 
+func getOptional(t *state.Task, key string, value interface{}) error {
+	if err := t.Get(key, value); err != nil && err != state.ErrNoState {
+		return err
+	}
 	return nil
 }
@@ -27,11 +27,41 @@ import (
 	"github.com/snapcore/snapd/overlord/state"
 )
 
+// ExecOptions holds the options for running a command as a task.
+type ExecOptions struct {
+	// Timeout is the maximum runtime of the command, the default
+	// timeout is used if it is zero.
+	Timeout time.Duration
+	// Env holds "KEY=value" entries added to the environment of the
+	// command.
+	Env []string
+	// CaptureOutput makes the combined output of the command be
+	// logged in the task also when the command succeeds.
+	CaptureOutput bool
+}
+
 // ExecWithTimeout creates a task that will execute the given command
 // with the given timeout.
 func ExecWithTimeout(st *state.State, summary string, argv []string, timeout time.Duration) *state.TaskSet {
+	return Exec(st, summary, argv, &ExecOptions{Timeout: timeout})
+}
+
+// Exec creates a task that will execute the given command with the
+// given options.
+func Exec(st *state.State, summary string, argv []string, opts *ExecOptions) *state.TaskSet {
+	if opts == nil {
+		opts = &ExecOptions{}
+	}
 	t := st.NewTask("exec-command", summary)
 	t.Set("argv", argv)
-	t.Set("timeout", timeout)
+	if opts.Timeout > 0 {
+		t.Set("timeout", opts.Timeout)
+	}
+	if len(opts.Env) > 0 {
+		t.Set("env", opts.Env)
+	}
+	if opts.CaptureOutput {
+		t.Set("capture-output", true)
+	}
 	return state.NewTaskSet(t)
 }
@@ -199,3 +199,64 @@ func (s *cmdSuite) TestExecTimeoutMissing(c *check.C) {
 	// slept for
 	c.Check(chg.Status(), check.Equals, state.DoneStatus)
 }
+
+func (s *cmdSuite) TestExecOptionsTask(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tasks := cmdstate.Exec(s.state, "this is the summary", []string{"/bin/true"}, &cmdstate.ExecOptions{
+		Timeout:       time.Minute,
+		Env:           []string{"FOO=bar"},
+		CaptureOutput: true,
+	}).Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	task := tasks[0]
+	c.Check(task.Kind(), check.Equals, "exec-command")
+
+	var tout time.Duration
+	c.Check(task.Get("timeout", &tout), check.IsNil)
+	c.Check(tout, check.Equals, time.Minute)
+	var env []string
+	c.Check(task.Get("env", &env), check.IsNil)
+	c.Check(env, check.DeepEquals, []string{"FOO=bar"})
+	var capture bool
+	c.Check(task.Get("capture-output", &capture), check.IsNil)
+	c.Check(capture, check.Equals, true)
+
+	// no options
+	task = cmdstate.Exec(s.state, "this is the summary", []string{"/bin/true"}, nil).Tasks()[0]
+	c.Check(task.Get("timeout", &tout), check.Equals, state.ErrNoState)
+	c.Check(task.Get("env", &env), check.Equals, state.ErrNoState)
+	c.Check(task.Get("capture-output", &capture), check.Equals, state.ErrNoState)
+}
+
+func (s *cmdSuite) TestExecEnvAndCaptureOutput(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts := cmdstate.Exec(s.state, "Doing the thing", []string{"sh", "-c", "echo $FOO"}, &cmdstate.ExecOptions{
+		Env:           []string{"FOO=hello-from-env"},
+		CaptureOutput: true,
+	})
+	chg := s.state.NewChange("do-the-thing", "Doing the thing")
+	chg.AddAll(ts)
+
+	s.waitfor(chg)
+
+	c.Check(chg.Status(), check.Equals, state.DoneStatus)
+	c.Check(strings.Join(chg.Tasks()[0].Log(), "\n"), check.Matches, `(?s).*INFO # sh -c echo \$FOO\nhello-from-env\n`)
+}
+
+func (s *cmdSuite) TestExecOutputNotCapturedByDefault(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts := cmdstate.Exec(s.state, "Doing the thing", []string{"echo", "hello"}, nil)
+	chg := s.state.NewChange("do-the-thing", "Doing the thing")
+	chg.AddAll(ts)
+
+	s.waitfor(chg)
+
+	c.Check(chg.Status(), check.Equals, state.DoneStatus)
+	c.Check(chg.Tasks()[0].Log(), check.HasLen, 0)
+}
@@ -0,0 +1,205 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/strutil"
+)
+
+// Schema describes the configuration options accepted by a snap. Snaps
+// declare it in their meta/config-schema.yaml file.
+type Schema struct {
+	// Options maps (possibly dotted) option names to their schema.
+	Options map[string]*OptionSchema `yaml:"options"`
+}
+
+// OptionSchema describes the values accepted for a configuration option.
+type OptionSchema struct {
+	// Type is one of string, int, number, bool, map or list.
+	Type string `yaml:"type"`
+	// Enum lists the accepted values of a string option.
+	Enum []string `yaml:"enum,omitempty"`
+	// Pattern is a regular expression a string option must match.
+	Pattern string `yaml:"pattern,omitempty"`
+	// Min and Max bound the values of int and number options.
+	Min *float64 `yaml:"min,omitempty"`
+	Max *float64 `yaml:"max,omitempty"`
+
+	subkeys []string
+	pattern *regexp.Regexp
+}
+
+var validOptionTypes = map[string]bool{
+	"string": true,
+	"int":    true,
+	"number": true,
+	"bool":   true,
+	"map":    true,
+	"list":   true,
+}
+
+// ParseSchema parses and validates a snap configuration schema.
+func ParseSchema(data []byte) (*Schema, error) {
+	var schema Schema
+	if err := yaml.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("cannot parse config schema: %v", err)
+	}
+	for name, opt := range schema.Options {
+		if err := opt.validate(name); err != nil {
+			return nil, fmt.Errorf("invalid config schema: option %q: %v", name, err)
+		}
+	}
+	return &schema, nil
+}
+
+func (opt *OptionSchema) validate(name string) error {
+	if opt == nil {
+		return fmt.Errorf("missing option schema")
+	}
+	subkeys, err := ParseKey(name)
+	if err != nil {
+		return err
+	}
+	if len(subkeys) == 0 {
+		return fmt.Errorf("option name cannot be empty")
+	}
+	opt.subkeys = subkeys
+
+	if !validOptionTypes[opt.Type] {
+		return fmt.Errorf("unsupported type %q", opt.Type)
+	}
+	if (len(opt.Enum) != 0 || opt.Pattern != "") && opt.Type != "string" {
+		return fmt.Errorf("enum and pattern can only be used with string options")
+	}
+	if (opt.Min != nil || opt.Max != nil) && opt.Type != "int" && opt.Type != "number" {
+		return fmt.Errorf("min and max can only be used with int or number options")
+	}
+	if opt.Min != nil && opt.Max != nil && *opt.Min > *opt.Max {
+		return fmt.Errorf("min cannot be greater than max")
+	}
+	if opt.Pattern != "" {
+		// anchor the pattern so that it matches whole values
+		opt.pattern, err = regexp.Compile("^(?:" + opt.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %v", err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the given snap configuration document against the
+// schema. Options not covered by the schema are not checked.
+func (s *Schema) Validate(instanceName string, config map[string]interface{}) error {
+	names := make([]string, 0, len(s.Options))
+	for name := range s.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		opt := s.Options[name]
+		value, ok := lookupOption(config, opt.subkeys)
+		if !ok {
+			continue
+		}
+		if err := opt.check(value); err != nil {
+			return fmt.Errorf("invalid value for snap %q option %q: %v", instanceName, name, err)
+		}
+	}
+	return nil
+}
+
+func lookupOption(config map[string]interface{}, subkeys []string) (interface{}, bool) {
+	var value interface{} = config
+	for _, subkey := range subkeys {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = m[subkey]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func valueType(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return "int"
+		}
+		return "number"
+	case float64:
+		return "number"
+	case map[string]interface{}:
+		return "map"
+	case []interface{}:
+		return "list"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func (opt *OptionSchema) check(value interface{}) error {
+	typ := valueType(value)
+	if typ != opt.Type && !(opt.Type == "number" && typ == "int") {
+		return fmt.Errorf("expected %s, got %s", opt.Type, typ)
+	}
+
+	switch opt.Type {
+	case "string":
+		str := value.(string)
+		if len(opt.Enum) != 0 && !strutil.ListContains(opt.Enum, str) {
+			return fmt.Errorf("%q is not one of %s", str, strutil.Quoted(opt.Enum))
+		}
+		if opt.pattern != nil && !opt.pattern.MatchString(str) {
+			return fmt.Errorf("%q does not match %q", str, opt.Pattern)
+		}
+	case "int", "number":
+		var n float64
+		switch value := value.(type) {
+		case json.Number:
+			n, _ = value.Float64()
+		case float64:
+			n = value
+		}
+		if opt.Min != nil && n < *opt.Min {
+			return fmt.Errorf("%v is less than the minimum %v", value, *opt.Min)
+		}
+		if opt.Max != nil && n > *opt.Max {
+			return fmt.Errorf("%v is greater than the maximum %v", value, *opt.Max)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package config_test
+
+import (
+	"bytes"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/jsonutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+)
+
+type schemaSuite struct{}
+
+var _ = Suite(&schemaSuite{})
+
+const testSchema = `
+options:
+  mode:
+    type: string
+    enum: [fast, slow]
+  name:
+    type: string
+    pattern: "[a-z]+"
+  port:
+    type: int
+    min: 1
+    max: 65535
+  ratio:
+    type: number
+    max: 1
+  debug:
+    type: bool
+  server.hosts:
+    type: list
+  server.extra:
+    type: map
+`
+
+func decodeConfig(c *C, doc string) map[string]interface{} {
+	var cfg map[string]interface{}
+	err := jsonutil.DecodeWithNumber(bytes.NewBufferString(doc), &cfg)
+	c.Assert(err, IsNil)
+	return cfg
+}
+
+func (s *schemaSuite) TestValidateHappy(c *C) {
+	schema, err := config.ParseSchema([]byte(testSchema))
+	c.Assert(err, IsNil)
+
+	for _, doc := range []string{
+		`{}`,
+		`{"mode": "fast", "name": "foo", "port": 8080, "ratio": 0.5, "debug": true}`,
+		// ints are accepted for numbers
+		`{"ratio": 1}`,
+		`{"server": {"hosts": ["a", "b"], "extra": {"a": 1}}}`,
+		// options not in the schema are not checked
+		`{"other": 1, "server": {"other": "foo"}}`,
+		// nested options are not checked if their parent is not a map
+		`{"server": "foo"}`,
+	} {
+		c.Check(schema.Validate("foo", decodeConfig(c, doc)), IsNil, Commentf(doc))
+	}
+}
+
+func (s *schemaSuite) TestValidateUnhappy(c *C) {
+	schema, err := config.ParseSchema([]byte(testSchema))
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		doc    string
+		errMsg string
+	}{
+		{`{"mode": 1}`, `invalid value for snap "foo" option "mode": expected string, got int`},
+		{`{"mode": "medium"}`, `invalid value for snap "foo" option "mode": "medium" is not one of "fast", "slow"`},
+		{`{"name": "Foo"}`, `invalid value for snap "foo" option "name": "Foo" does not match "\[a-z\]\+"`},
+		{`{"port": "80"}`, `invalid value for snap "foo" option "port": expected int, got string`},
+		{`{"port": 1.5}`, `invalid value for snap "foo" option "port": expected int, got number`},
+		{`{"port": 0}`, `invalid value for snap "foo" option "port": 0 is less than the minimum 1`},
+		{`{"port": 70000}`, `invalid value for snap "foo" option "port": 70000 is greater than the maximum 65535`},
+		{`{"ratio": 1.5}`, `invalid value for snap "foo" option "ratio": 1.5 is greater than the maximum 1`},
+		{`{"debug": "yes"}`, `invalid value for snap "foo" option "debug": expected bool, got string`},
+		{`{"server": {"hosts": "a"}}`, `invalid value for snap "foo" option "server.hosts": expected list, got string`},
+		{`{"server": {"extra": null}}`, `invalid value for snap "foo" option "server.extra": expected map, got null`},
+	} {
+		err := schema.Validate("foo", decodeConfig(c, t.doc))
+		c.Check(err, ErrorMatches, t.errMsg, Commentf(t.doc))
+	}
+}
+
+func (s *schemaSuite) TestParseSchemaErrors(c *C) {
+	for _, t := range []struct {
+		schema string
+		errMsg string
+	}{
+		{`options: [`, `cannot parse config schema: .*`},
+		{"options:\n  foo:\n", `invalid config schema: option "foo": missing option schema`},
+		{"options:\n  Foo:\n    type: string", `invalid config schema: option "Foo": invalid option name: "Foo"`},
+		{"options:\n  foo:\n    type: blob", `invalid config schema: option "foo": unsupported type "blob"`},
+		{"options:\n  foo:\n    type: int\n    enum: [a]", `invalid config schema: option "foo": enum and pattern can only be used with string options`},
+		{"options:\n  foo:\n    type: string\n    min: 1", `invalid config schema: option "foo": min and max can only be used with int or number options`},
+		{"options:\n  foo:\n    type: int\n    min: 2\n    max: 1", `invalid config schema: option "foo": min cannot be greater than max`},
+		{"options:\n  foo:\n    type: string\n    pattern: \"[\"", `invalid config schema: option "foo": invalid pattern: .*`},
+	} {
+		_, err := config.ParseSchema([]byte(t.schema))
+		c.Check(err, ErrorMatches, t.errMsg, Commentf(t.schema))
+	}
+}
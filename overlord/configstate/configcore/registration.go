@@ -0,0 +1,66 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.registration.retry-interval"] = true
+	supportedConfigurations["core.registration.max-retries"] = true
+	supportedConfigurations["core.registration.max-backoff"] = true
+}
+
+func validateRegistrationSettings(tr config.Conf) error {
+	for _, opt := range []string{"registration.retry-interval", "registration.max-backoff"} {
+		value, err := coreCfg(tr, opt)
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			continue
+		}
+		dur, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s cannot be parsed: %v", opt, err)
+		}
+		if dur < time.Second {
+			return fmt.Errorf("%s must be at least one second", opt)
+		}
+	}
+
+	value, err := coreCfg(tr, "registration.max-retries")
+	if err != nil {
+		return err
+	}
+	if value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("registration.max-retries must be a non-negative number, got %q", value)
+		}
+	}
+	return nil
+}
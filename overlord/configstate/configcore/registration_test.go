@@ -0,0 +1,63 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type registrationSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&registrationSuite{})
+
+func (s *registrationSuite) TestConfigureRegistrationHappy(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"registration.retry-interval": "2m",
+			"registration.max-retries":    "30",
+			"registration.max-backoff":    "1h",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *registrationSuite) TestConfigureRegistrationInvalid(c *C) {
+	for _, t := range []struct {
+		conf map[string]interface{}
+		err  string
+	}{
+		{map[string]interface{}{"registration.retry-interval": "invalid"}, `registration.retry-interval cannot be parsed:.*`},
+		{map[string]interface{}{"registration.retry-interval": "10ms"}, `registration.retry-interval must be at least one second`},
+		{map[string]interface{}{"registration.max-backoff": "invalid"}, `registration.max-backoff cannot be parsed:.*`},
+		{map[string]interface{}{"registration.max-retries": "many"}, `registration.max-retries must be a non-negative number, got "many"`},
+		{map[string]interface{}{"registration.max-retries": "-1"}, `registration.max-retries must be a non-negative number, got "-1"`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf:  t.conf,
+		})
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
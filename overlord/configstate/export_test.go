@@ -19,5 +19,21 @@
 
 package configstate
 
+import (
+	"github.com/snapcore/snapd/features"
+)
+
 var NewConfigureHandler = newConfigureHandler
 var SortPatchKeysByDepth = sortPatchKeysByDepth
+
+func MockFeatureChangeHooks() (restore func()) {
+	featureChangeHooksMu.Lock()
+	defer featureChangeHooksMu.Unlock()
+	old := featureChangeHooks
+	featureChangeHooks = make(map[features.SnapdFeature][]FeatureChangeHook)
+	return func() {
+		featureChangeHooksMu.Lock()
+		defer featureChangeHooksMu.Unlock()
+		featureChangeHooks = old
+	}
+}
@@ -0,0 +1,124 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"sync"
+
+	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// FeatureInfo describes a snapd feature flag and its current status.
+type FeatureInfo struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// Exported is set for features that are visible outside of snapd
+	// as control files.
+	Exported bool `json:"exported,omitempty"`
+	// EnabledWhenUnset is set for features that are on unless
+	// explicitly turned off.
+	EnabledWhenUnset bool `json:"enabled-when-unset,omitempty"`
+}
+
+// Features returns the status of all the known snapd features.
+func Features(st *state.State) ([]FeatureInfo, error) {
+	tr := config.NewTransaction(st)
+	known := features.KnownFeatures()
+	infos := make([]FeatureInfo, 0, len(known))
+	for _, feature := range known {
+		enabled, err := config.GetFeatureFlag(tr, feature)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, FeatureInfo{
+			Name:             feature.String(),
+			Enabled:          enabled,
+			Exported:         feature.IsExported(),
+			EnabledWhenUnset: feature.IsEnabledWhenUnset(),
+		})
+	}
+	return infos, nil
+}
+
+// IsFeatureEnabled returns whether the given feature is currently
+// enabled. The state must be locked by the caller.
+func IsFeatureEnabled(st *state.State, feature features.SnapdFeature) (bool, error) {
+	return config.GetFeatureFlag(config.NewTransaction(st), feature)
+}
+
+// FeatureChangeHook is called, with the state locked, once a change
+// of a feature flag was committed.
+type FeatureChangeHook func(st *state.State, feature features.SnapdFeature, enabled bool) error
+
+var (
+	featureChangeHooksMu sync.Mutex
+	featureChangeHooks   = make(map[features.SnapdFeature][]FeatureChangeHook)
+)
+
+// AddFeatureChangeHook registers a hook called whenever the given
+// feature gets enabled or disabled through the system configuration.
+func AddFeatureChangeHook(feature features.SnapdFeature, hook FeatureChangeHook) {
+	featureChangeHooksMu.Lock()
+	defer featureChangeHooksMu.Unlock()
+	featureChangeHooks[feature] = append(featureChangeHooks[feature], hook)
+}
+
+// featureFlags returns the value of all the known features as seen by
+// the given configuration.
+func featureFlags(tr config.Conf) map[features.SnapdFeature]bool {
+	flags := make(map[features.SnapdFeature]bool)
+	for _, feature := range features.KnownFeatures() {
+		enabled, err := config.GetFeatureFlag(tr, feature)
+		if err != nil {
+			// invalid values are rejected by configcore
+			continue
+		}
+		flags[feature] = enabled
+	}
+	return flags
+}
+
+// runFeatureChangeHooks calls the hooks of the features whose value
+// differs between the given flags and the committed configuration.
+func runFeatureChangeHooks(st *state.State, before map[features.SnapdFeature]bool) {
+	after := featureFlags(config.NewTransaction(st))
+
+	featureChangeHooksMu.Lock()
+	hooks := make(map[features.SnapdFeature][]FeatureChangeHook, len(featureChangeHooks))
+	for feature, fs := range featureChangeHooks {
+		hooks[feature] = fs
+	}
+	featureChangeHooksMu.Unlock()
+
+	for _, feature := range features.KnownFeatures() {
+		enabled, ok := after[feature]
+		if !ok || enabled == before[feature] {
+			continue
+		}
+		for _, hook := range hooks[feature] {
+			if err := hook(st, feature, enabled); err != nil {
+				logger.Noticef("cannot handle change of feature %q: %v", feature, err)
+			}
+		}
+	}
+}
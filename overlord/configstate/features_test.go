@@ -0,0 +1,111 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type featuresSuite struct {
+	configcoreHijackSuite
+}
+
+var _ = Suite(&featuresSuite{})
+
+func (s *featuresSuite) SetUpTest(c *C) {
+	s.configcoreHijackSuite.SetUpTest(c)
+	s.AddCleanup(configstate.MockFeatureChangeHooks())
+	s.AddCleanup(configstate.MockConfigcoreRun(func(config.Conf) error { return nil }))
+}
+
+func (s *featuresSuite) TestFeatures(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "experimental.hotplug", true)
+	tr.Set("core", "experimental.layouts", false)
+	tr.Commit()
+
+	infos, err := configstate.Features(s.state)
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, len(features.KnownFeatures()))
+	c.Check(infos[features.Layouts], DeepEquals, configstate.FeatureInfo{Name: "layouts", EnabledWhenUnset: true})
+	c.Check(infos[features.Hotplug], DeepEquals, configstate.FeatureInfo{Name: "hotplug", Enabled: true})
+	c.Check(infos[features.ParallelInstances], DeepEquals, configstate.FeatureInfo{Name: "parallel-instances", Exported: true})
+
+	enabled, err := configstate.IsFeatureEnabled(s.state, features.Hotplug)
+	c.Assert(err, IsNil)
+	c.Check(enabled, Equals, true)
+	enabled, err = configstate.IsFeatureEnabled(s.state, features.ParallelInstances)
+	c.Assert(err, IsNil)
+	c.Check(enabled, Equals, false)
+}
+
+func (s *featuresSuite) configure(c *C, patch map[string]interface{}) {
+	s.state.Lock()
+	chg := s.state.NewChange("configure-core", "configure core")
+	chg.AddAll(configstate.Configure(s.state, "core", patch, 0))
+	s.state.Unlock()
+
+	err := s.o.Settle(5 * time.Second)
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(chg.Err(), IsNil)
+}
+
+func (s *featuresSuite) TestFeatureChangeHooks(c *C) {
+	type call struct {
+		feature features.SnapdFeature
+		enabled bool
+	}
+	var calls []call
+	configstate.AddFeatureChangeHook(features.Hotplug, func(st *state.State, feature features.SnapdFeature, enabled bool) error {
+		// the change is committed already
+		isEnabled, err := configstate.IsFeatureEnabled(st, feature)
+		c.Assert(err, IsNil)
+		c.Check(isEnabled, Equals, enabled)
+		calls = append(calls, call{feature, enabled})
+		return nil
+	})
+
+	s.configure(c, map[string]interface{}{"experimental.hotplug": true})
+	c.Check(calls, DeepEquals, []call{{features.Hotplug, true}})
+
+	// no change, no call
+	s.configure(c, map[string]interface{}{"experimental.hotplug": "true"})
+	c.Check(calls, HasLen, 1)
+
+	// other features do not matter
+	s.configure(c, map[string]interface{}{"experimental.layouts": false})
+	c.Check(calls, HasLen, 1)
+
+	s.configure(c, map[string]interface{}{"experimental.hotplug": nil})
+	c.Check(calls, DeepEquals, []call{{features.Hotplug, true}, {features.Hotplug, false}})
+}
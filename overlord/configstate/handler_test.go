@@ -256,3 +256,72 @@ volumes:
 	err = s.handler.Before()
 	c.Check(err, ErrorMatches, `cannot apply gadget config defaults for snap "test-snap", no configure hook`)
 }
+
+func (s *configureHandlerSuite) mockTestSnap(c *C, schema string) {
+	info := snaptest.MockSnap(c, "name: test-snap\nversion: 1\n", &snap.SideInfo{Revision: snap.R(1)})
+	if schema != "" {
+		err := ioutil.WriteFile(filepath.Join(info.MountDir(), "meta", "config-schema.yaml"), []byte(schema), 0644)
+		c.Assert(err, IsNil)
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "test-snap", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+}
+
+const testConfigSchema = `
+options:
+  port:
+    type: int
+    min: 1
+`
+
+func (s *configureHandlerSuite) TestDoneValidatesSchema(c *C) {
+	s.mockTestSnap(c, testConfigSchema)
+
+	s.context.Lock()
+	s.context.Set("patch", map[string]interface{}{
+		"port": 0,
+	})
+	s.context.Unlock()
+
+	c.Assert(s.handler.Before(), IsNil)
+	err := s.handler.Done()
+	c.Check(err, ErrorMatches, `invalid value for snap "test-snap" option "port": 0 is less than the minimum 1`)
+
+	// the hook itself can fix the value
+	s.context.Lock()
+	tr := configstate.ContextTransaction(s.context)
+	c.Assert(tr.Set("test-snap", "port", 80), IsNil)
+	s.context.Unlock()
+
+	c.Check(s.handler.Done(), IsNil)
+}
+
+func (s *configureHandlerSuite) TestDoneNoSchema(c *C) {
+	s.mockTestSnap(c, "")
+
+	s.context.Lock()
+	s.context.Set("patch", map[string]interface{}{
+		"port": 0,
+	})
+	s.context.Unlock()
+
+	c.Assert(s.handler.Before(), IsNil)
+	c.Check(s.handler.Done(), IsNil)
+}
+
+func (s *configureHandlerSuite) TestDoneInvalidSchema(c *C) {
+	s.mockTestSnap(c, "options:\n  port:\n    type: integer\n")
+
+	c.Assert(s.handler.Before(), IsNil)
+	err := s.handler.Done()
+	c.Check(err, ErrorMatches, `cannot use config schema of snap "test-snap": invalid config schema: option "port": unsupported type "integer"`)
+}
@@ -21,11 +21,15 @@ package configstate
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 )
 
 // configureHandler is the handler for the configure hook.
@@ -50,12 +54,16 @@ func ContextTransaction(context *hookstate.Context) *config.Transaction {
 	tr = config.NewTransaction(context.State())
 
 	context.OnDone(func() error {
-		tr.Commit()
-		if context.InstanceName() == "core" {
-			// make sure the Ensure logic can process
-			// system configuration changes as soon as possible
-			context.State().EnsureBefore(0)
+		if context.InstanceName() != "core" {
+			tr.Commit()
+			return nil
 		}
+		before := featureFlags(config.NewTransaction(context.State()))
+		tr.Commit()
+		runFeatureChangeHooks(context.State(), before)
+		// make sure the Ensure logic can process
+		// system configuration changes as soon as possible
+		context.State().EnsureBefore(0)
 		return nil
 	})
 
@@ -123,9 +131,53 @@ func (h *configureHandler) Before() error {
 }
 
 // Done is called by the HookManager after the configure hook has exited
-// successfully.
+// successfully. It validates the resulting configuration against the
+// schema of the snap, if it declares one.
 func (h *configureHandler) Done() error {
-	return nil
+	h.context.Lock()
+	defer h.context.Unlock()
+
+	instanceName := h.context.InstanceName()
+	// the system configuration is validated by configcore
+	if instanceName == "core" {
+		return nil
+	}
+
+	info, err := snapstate.CurrentInfo(h.context.State(), instanceName)
+	if err != nil {
+		return err
+	}
+	schema, err := readConfigSchema(info)
+	if err != nil {
+		return err
+	}
+	if schema == nil {
+		return nil
+	}
+
+	tr := ContextTransaction(h.context)
+	var cfg map[string]interface{}
+	if err := tr.Get(instanceName, "", &cfg); err != nil && !config.IsNoOption(err) {
+		return err
+	}
+	return schema.Validate(instanceName, cfg)
+}
+
+// readConfigSchema reads the configuration schema declared by the
+// given snap, it returns nil if the snap does not declare one.
+func readConfigSchema(info *snap.Info) (*config.Schema, error) {
+	data, err := ioutil.ReadFile(filepath.Join(info.MountDir(), "meta", "config-schema.yaml"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	schema, err := config.ParseSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot use config schema of snap %q: %v", info.InstanceName(), err)
+	}
+	return schema, nil
 }
 
 // Error is called by the HookManager after the configure hook has exited
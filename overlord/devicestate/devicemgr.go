@@ -20,7 +20,11 @@
 package devicestate
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
@@ -56,6 +60,8 @@ type DeviceManager struct {
 
 	ensureSeedInConfigRan bool
 
+	ensureInstalledRan bool
+
 	lastBecomeOperationalAttempt time.Time
 	becomeOperationalBackoff     time.Duration
 	registered                   bool
@@ -88,6 +94,7 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, runner *state.T
 	}
 
 	hookManager.Register(regexp.MustCompile("^prepare-device$"), newPrepareDeviceHandler)
+	hookManager.Register(regexp.MustCompile("^install-device$"), newInstallDeviceHandler)
 
 	runner.AddHandler("generate-device-key", m.doGenerateDeviceKey, nil)
 	runner.AddHandler("request-serial", m.doRequestSerial, nil)
@@ -103,7 +110,14 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, runner *state.T
 	// deployed boot assets must be backward compatible with reverted kernel
 	// or gadget snaps. There are no further changes to the boot assets,
 	// unless a new gadget update is deployed.
-	runner.AddHandler("update-gadget-assets", m.doUpdateGadgetAssets, nil)
+	runner.AddHandler("update-gadget-assets", m.doUpdateGadgetAssets, m.undoUpdateGadgetAssets)
+	// the system is rebooted and its writable data wiped after a
+	// factory reset was prepared, there is nothing to undo
+	runner.AddHandler("factory-reset", m.doFactoryReset, nil)
+	runner.AddHandler("finish-factory-reset", m.doFinishFactoryReset, nil)
+	// the run system is set up from scratch in install mode, a
+	// failed install is started over by rebooting into install mode
+	runner.AddHandler("setup-run-system", m.doSetupRunSystem, nil)
 
 	runner.AddBlocked(gadgetUpdateBlocked)
 
@@ -186,6 +200,24 @@ func (h prepareDeviceHandler) Error(err error) error {
 	return nil
 }
 
+type installDeviceHandler struct{}
+
+func newInstallDeviceHandler(context *hookstate.Context) hookstate.Handler {
+	return installDeviceHandler{}
+}
+
+func (h installDeviceHandler) Before() error {
+	return nil
+}
+
+func (h installDeviceHandler) Done() error {
+	return nil
+}
+
+func (h installDeviceHandler) Error(err error) error {
+	return nil
+}
+
 func (m *DeviceManager) changeInFlight(kind string) bool {
 	for _, chg := range m.state.Changes() {
 		if chg.Kind() == kind && !chg.Status().Ready() {
@@ -218,12 +250,16 @@ func (m *DeviceManager) ensureOperationalShouldBackoff(now time.Time) bool {
 	if !m.lastBecomeOperationalAttempt.IsZero() && m.lastBecomeOperationalAttempt.Add(m.becomeOperationalBackoff).After(now) {
 		return true
 	}
+	maxBackoff := m.maxBecomeOperationalBackoff()
 	if m.becomeOperationalBackoff == 0 {
 		m.becomeOperationalBackoff = 5 * time.Minute
+		if m.becomeOperationalBackoff > maxBackoff {
+			m.becomeOperationalBackoff = maxBackoff
+		}
 	} else {
 		newBackoff := m.becomeOperationalBackoff * 2
-		if newBackoff > (12 * time.Hour) {
-			newBackoff = 24 * time.Hour
+		if newBackoff > maxBackoff/2 {
+			newBackoff = maxBackoff
 		}
 		m.becomeOperationalBackoff = newBackoff
 	}
@@ -231,6 +267,19 @@ func (m *DeviceManager) ensureOperationalShouldBackoff(now time.Time) bool {
 	return false
 }
 
+// maxBecomeOperationalBackoff returns the maximum backoff between full
+// registration retries, as set with the registration.max-backoff
+// system option.
+func (m *DeviceManager) maxBecomeOperationalBackoff() time.Duration {
+	maxBackoff := 24 * time.Hour
+	if v := registrationOption(config.NewTransaction(m.state), "max-backoff"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			maxBackoff = d
+		}
+	}
+	return maxBackoff
+}
+
 func setClassicFallbackModel(st *state.State, device *auth.DeviceState) error {
 	err := assertstate.Add(st, sysdb.GenericClassicModel())
 	if err != nil && !asserts.IsUnaccceptedUpdate(err) {
@@ -294,6 +343,10 @@ func (m *DeviceManager) ensureOperational() error {
 	if m.changeInFlight("become-operational") {
 		return nil
 	}
+	if m.changeInFlight("factory-reset") {
+		// the device registers again once the reset is completed
+		return nil
+	}
 
 	var storeID, gadget string
 	model, err := m.Model()
@@ -454,6 +507,130 @@ func (m *DeviceManager) ensureBootOk() error {
 	return nil
 }
 
+// ensureFactoryResetDone creates the change completing a factory reset
+// once the run system installed by it was seeded.
+func (m *DeviceManager) ensureFactoryResetDone() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	if release.OnClassic {
+		return nil
+	}
+
+	resetData, err := ioutil.ReadFile(filepath.Join(factoryResetDir(), factoryResetStateFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	mode, err := bootSystemMode()
+	if err != nil {
+		return fmt.Errorf("cannot determine the system mode: %v", err)
+	}
+	if mode != boot.ModeRun {
+		return nil
+	}
+
+	var seeded bool
+	err = m.state.Get("seeded", &seeded)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !seeded {
+		return nil
+	}
+
+	var reset FactoryResetState
+	if err := json.Unmarshal(resetData, &reset); err != nil {
+		return fmt.Errorf("cannot read factory reset details: %v", err)
+	}
+	if current, err := FactoryResetStatus(m.state); err == nil && current.RequestTime.Equal(reset.RequestTime) {
+		// the state was not wiped yet, the reset is still
+		// pending a reboot
+		return nil
+	}
+	if m.changeInFlight("factory-reset") {
+		return nil
+	}
+
+	t := m.state.NewTask("finish-factory-reset", i18n.G("Record completion of factory reset"))
+	chg := m.state.NewChange("factory-reset", i18n.G("Complete factory reset"))
+	chg.AddTask(t)
+	m.state.EnsureBefore(0)
+
+	return nil
+}
+
+var bootSystemMode = boot.SystemMode
+
+// ensureInstalled sets up the run system, running the install-device hook
+// of the gadget first if it has one, when the system was booted in install
+// mode and the recovery system it was booted from is seeded.
+func (m *DeviceManager) ensureInstalled() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	if release.OnClassic || m.ensureInstalledRan {
+		return nil
+	}
+
+	mode, err := bootSystemMode()
+	if err != nil {
+		return fmt.Errorf("cannot determine the system mode: %v", err)
+	}
+	if mode != boot.ModeInstall {
+		m.ensureInstalledRan = true
+		return nil
+	}
+
+	var seeded bool
+	err = m.state.Get("seeded", &seeded)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !seeded {
+		return nil
+	}
+
+	if m.changeInFlight("install-system") {
+		return nil
+	}
+
+	model, err := m.Model()
+	if err != nil {
+		return err
+	}
+	gadgetInfo, err := snapstate.CurrentInfo(m.state, model.Gadget())
+	if err != nil {
+		return err
+	}
+
+	m.ensureInstalledRan = true
+
+	var tasks []*state.Task
+	setupRunSystem := m.state.NewTask("setup-run-system", i18n.G("Setup system for run mode"))
+	if gadgetInfo.Hooks["install-device"] != nil {
+		summary := i18n.G("Run install-device hook")
+		hooksup := &hookstate.HookSetup{
+			Snap: gadgetInfo.InstanceName(),
+			Hook: "install-device",
+		}
+		installDevice := hookstate.HookTask(m.state, summary, hooksup, nil)
+		tasks = append(tasks, installDevice)
+		// a failing hook aborts the install before the disk is touched
+		setupRunSystem.WaitFor(installDevice)
+	}
+	tasks = append(tasks, setupRunSystem)
+
+	chg := m.state.NewChange("install-system", i18n.G("Install the system"))
+	chg.AddAll(state.NewTaskSet(tasks...))
+	m.state.EnsureBefore(0)
+
+	return nil
+}
+
 func markSeededInConfig(st *state.State) error {
 	var seedDone bool
 	tr := config.NewTransaction(st)
@@ -521,6 +698,9 @@ func (m *DeviceManager) Ensure() error {
 	if err := m.ensureSeedYaml(); err != nil {
 		errs = append(errs, err)
 	}
+	if err := m.ensureFactoryResetDone(); err != nil {
+		errs = append(errs, err)
+	}
 	if err := m.ensureOperational(); err != nil {
 		errs = append(errs, err)
 	}
@@ -529,6 +709,10 @@ func (m *DeviceManager) Ensure() error {
 		errs = append(errs, err)
 	}
 
+	if err := m.ensureInstalled(); err != nil {
+		errs = append(errs, err)
+	}
+
 	if err := m.ensureSeedInConfig(); err != nil {
 		errs = append(errs, err)
 	}
@@ -582,6 +766,56 @@ func (m *DeviceManager) Serial() (*asserts.Serial, error) {
 	return findSerial(m.state, nil)
 }
 
+// RegistrationStatus describes the progress of the registration of the
+// device, i.e. of the acquisition of its serial.
+type RegistrationStatus struct {
+	Serial string `json:"serial,omitempty"`
+	// InProgress is set while a registration attempt is running.
+	InProgress bool `json:"in-progress,omitempty"`
+	// Attempts is the number of registration attempts since snapd
+	// started.
+	Attempts    int        `json:"attempts,omitempty"`
+	LastAttempt *time.Time `json:"last-attempt,omitempty"`
+	NextAttempt *time.Time `json:"next-attempt,omitempty"`
+	// LastError is the last error reported while acquiring a serial.
+	LastError string `json:"last-error,omitempty"`
+}
+
+// RegistrationStatus returns the status of the registration of the
+// device. The state must be locked by the caller.
+func (m *DeviceManager) RegistrationStatus() (*RegistrationStatus, error) {
+	device, err := m.device()
+	if err != nil {
+		return nil, err
+	}
+	status := &RegistrationStatus{
+		Serial:   device.Serial,
+		Attempts: ensureOperationalAttempts(m.state),
+	}
+	if !m.lastBecomeOperationalAttempt.IsZero() {
+		last := m.lastBecomeOperationalAttempt
+		status.LastAttempt = &last
+		if device.Serial == "" {
+			next := last.Add(m.becomeOperationalBackoff)
+			status.NextAttempt = &next
+		}
+	}
+
+	var regErr registrationError
+	if err := m.state.Get("registration-error", &regErr); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	status.LastError = regErr.Message
+
+	for _, chg := range m.state.Changes() {
+		if chg.Kind() == "become-operational" && !chg.Status().Ready() {
+			status.InProgress = true
+			break
+		}
+	}
+	return status, nil
+}
+
 // implement storecontext.Backend
 
 type storeContextBackend struct {
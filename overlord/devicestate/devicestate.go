@@ -25,6 +25,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/i18n"
@@ -45,6 +46,7 @@ import (
 var (
 	snapstateInstallWithDeviceContext = snapstate.InstallWithDeviceContext
 	snapstateUpdateWithDeviceContext  = snapstate.UpdateWithDeviceContext
+	snapstateRemoveWithDeviceContext  = snapstate.RemoveWithDeviceContext
 )
 
 // findModel returns the device model assertion.
@@ -103,6 +105,14 @@ func findSerial(st *state.State, device *auth.DeviceState) (*asserts.Serial, err
 }
 
 // auto-refresh
+func deviceSerial(st *state.State) (string, error) {
+	device, err := internal.Device(st)
+	if err != nil {
+		return "", err
+	}
+	return device.Serial, nil
+}
+
 func canAutoRefresh(st *state.State) (bool, error) {
 	// we need to be seeded first
 	var seeded bool
@@ -211,6 +221,7 @@ func delayedCrossMgrInit() {
 	snapstate.CanAutoRefresh = canAutoRefresh
 	snapstate.CanManageRefreshes = CanManageRefreshes
 	snapstate.IsOnMeteredConnection = netutil.IsOnMeteredConnection
+	snapstate.DeviceSerial = deviceSerial
 	snapstate.DeviceCtx = DeviceCtx
 	snapstate.Remodeling = Remodeling
 }
@@ -345,6 +356,23 @@ func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Mo
 		tss = append(tss, ts)
 	}
 
+	// adjust gadget track
+	if current.Gadget() == new.Gadget() && current.GadgetTrack() != new.GadgetTrack() {
+		ts, err := snapstateUpdateWithDeviceContext(st, new.Gadget(), &snapstate.RevisionOptions{Channel: new.GadgetTrack()}, userID, snapstate.Flags{NoReRefresh: true}, deviceCtx, fromChange)
+		if err != nil {
+			return nil, err
+		}
+		tss = append(tss, ts)
+	}
+	// add new gadget
+	if current.Gadget() != new.Gadget() {
+		ts, err := snapstateInstallWithDeviceContext(ctx, st, new.Gadget(), &snapstate.RevisionOptions{Channel: new.GadgetTrack()}, userID, snapstate.Flags{}, deviceCtx, fromChange)
+		if err != nil {
+			return nil, err
+		}
+		tss = append(tss, ts)
+	}
+
 	// add new required-snaps, no longer required snaps will be cleaned
 	// in "set-model"
 	for _, snapRef := range new.RequiredNoEssentialSnaps() {
@@ -422,13 +450,27 @@ func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Mo
 	}
 
 	// Set the new model assertion - this *must* be the last thing done
-	// by the change.
+	// by the change, apart from removing the gadget of the old model
+	// which is only possible once the new model is set.
 	setModel := st.NewTask("set-model", i18n.G("Set new model assertion"))
 	for _, tsPrev := range tss {
 		setModel.WaitAll(tsPrev)
 	}
 	tss = append(tss, state.NewTaskSet(setModel))
 
+	// remove the old gadget
+	if current.Gadget() != new.Gadget() {
+		ts, err := snapstateRemoveWithDeviceContext(st, current.Gadget(), snap.R(0), nil, deviceCtx, fromChange)
+		if _, ok := err.(*snap.NotInstalledError); ok {
+			return tss, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ts.WaitFor(setModel)
+		tss = append(tss, ts)
+	}
+
 	return tss, nil
 }
 
@@ -458,10 +500,16 @@ func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
 	if current.Series() != new.Series() {
 		return nil, fmt.Errorf("cannot remodel to different series yet")
 	}
+	if current.Classic() != new.Classic() {
+		return nil, fmt.Errorf("cannot remodel between classic and non-classic models")
+	}
 
 	// TODO: we need dedicated assertion language to permit for
 	// model transitions before we allow cross vault
 	// transitions.
+	if current.BrandID() != new.BrandID() {
+		return nil, fmt.Errorf("cannot remodel to different brands yet")
+	}
 
 	remodelKind := ClassifyRemodel(current, new)
 
@@ -477,9 +525,6 @@ func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
 	if current.Base() != new.Base() {
 		return nil, fmt.Errorf("cannot remodel to different bases yet")
 	}
-	if current.Gadget() != new.Gadget() {
-		return nil, fmt.Errorf("cannot remodel to different gadgets yet")
-	}
 
 	// TODO: should we run a remodel only while no other change is
 	// running?  do we add a task upfront that waits for that to be
@@ -568,3 +613,54 @@ func Remodeling(st *state.State) bool {
 	}
 	return false
 }
+
+// FactoryResetState holds the details of the last factory reset of
+// the device.
+type FactoryResetState struct {
+	RequestTime time.Time  `json:"request-time"`
+	DoneTime    *time.Time `json:"done-time,omitempty"`
+}
+
+// FactoryResetStatus returns the details of the last factory reset of
+// the device, state.ErrNoState is returned if the device was never
+// reset.
+func FactoryResetStatus(st *state.State) (*FactoryResetState, error) {
+	var reset FactoryResetState
+	if err := st.Get("factory-reset", &reset); err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+// FactoryReset creates a change that resets the device to its
+// factory state. The system reboots so that the writable data is wiped
+// and the installation from the seed runs again. The device key and
+// serial are wiped as well, once the system is seeded again the device
+// registers anew.
+func FactoryReset(st *state.State) (*state.Change, error) {
+	if release.OnClassic {
+		return nil, fmt.Errorf("cannot factory reset classic systems")
+	}
+
+	var seeded bool
+	err := st.Get("seeded", &seeded)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if !seeded {
+		return nil, fmt.Errorf("cannot factory reset until fully seeded")
+	}
+
+	// nothing else can be in-flight
+	for _, chg := range st.Changes() {
+		if !chg.IsReady() {
+			return nil, &snapstate.ChangeConflictError{Message: "cannot factory reset, other changes are in progress"}
+		}
+	}
+
+	t := st.NewTask("factory-reset", i18n.G("Prepare factory reset"))
+	chg := st.NewChange("factory-reset", i18n.G("Reset device to factory state"))
+	chg.AddTask(t)
+
+	return chg, nil
+}
@@ -21,6 +21,7 @@ package devicestate_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -41,6 +42,7 @@ import (
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/assertstest"
 	"github.com/snapcore/snapd/asserts/sysdb"
+	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/bootloader"
 	"github.com/snapcore/snapd/bootloader/bootloadertest"
 	"github.com/snapcore/snapd/dirs"
@@ -50,6 +52,7 @@ import (
 	"github.com/snapcore/snapd/interfaces/builtin"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/disks"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/assertstate/assertstatetest"
@@ -58,6 +61,7 @@ import (
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
 	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
 	"github.com/snapcore/snapd/overlord/ifacestate/ifacerepo"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
@@ -917,6 +921,64 @@ func (s *deviceMgrSuite) TestDoRequestSerialMaxTentatives(c *C) {
 	c.Check(chg.Err(), ErrorMatches, `(?s).*cannot retrieve request-id for making a request for a serial: unexpected status 501.*`)
 }
 
+func (s *deviceMgrSuite) TestDoRequestSerialMaxRetriesConfig(c *C) {
+	privKey, _ := assertstest.GenerateKey(testKeyLength)
+
+	mockServer := s.mockServer(c, devicestatetest.ReqIDFailID501, nil)
+	defer mockServer.Close()
+
+	restore := devicestate.MockBaseStoreURL(mockServer.URL)
+	defer restore()
+
+	restore = devicestate.MockRepeatRequestSerial("after-add-serial")
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "registration.max-retries", 1)
+	tr.Set("core", "registration.retry-interval", "0s")
+	tr.Commit()
+
+	s.makeModelAssertionInState(c, "canonical", "pc", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+	})
+
+	devicestatetest.MockGadget(c, s.state, "pc", snap.R(2), nil)
+
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc",
+		KeyID: privKey.PublicKey().ID(),
+	})
+	devicestate.KeypairManager(s.mgr).Put(privKey)
+
+	t := s.state.NewTask("request-serial", "test")
+	chg := s.state.NewChange("become-operational", "...")
+	chg.AddTask(t)
+
+	// avoid full seeding
+	s.seeding()
+
+	s.state.Unlock()
+	s.se.Ensure()
+	s.se.Wait()
+	s.state.Lock()
+
+	// no retries left
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*cannot retrieve request-id for making a request for a serial: unexpected status 501.*`)
+
+	// the error is recorded for the registration status
+	status, err := s.mgr.RegistrationStatus()
+	c.Assert(err, IsNil)
+	c.Check(status.InProgress, Equals, false)
+	c.Check(status.LastError, Equals, "cannot retrieve request-id for making a request for a serial: unexpected status 501")
+}
+
 func (s *deviceMgrSuite) TestFullDeviceRegistrationPollHappy(c *C) {
 	r1 := devicestate.MockKeyLength(testKeyLength)
 	defer r1()
@@ -1291,6 +1353,9 @@ func (s *deviceMgrSuite) TestFullDeviceRegistrationErrorBackoff(c *C) {
 }
 
 func (s *deviceMgrSuite) TestEnsureBecomeOperationalShouldBackoff(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
 	t0 := time.Now()
 	c.Check(devicestate.EnsureOperationalShouldBackoff(s.mgr, t0), Equals, false)
 	c.Check(devicestate.BecomeOperationalBackoff(s.mgr), Equals, 5*time.Minute)
@@ -1310,6 +1375,75 @@ func (s *deviceMgrSuite) TestEnsureBecomeOperationalShouldBackoff(c *C) {
 	}
 }
 
+func (s *deviceMgrSuite) TestEnsureBecomeOperationalShouldBackoffMaxBackoffConfig(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "registration.max-backoff", "3m")
+	tr.Commit()
+
+	t0 := time.Now()
+	c.Check(devicestate.EnsureOperationalShouldBackoff(s.mgr, t0), Equals, false)
+	c.Check(devicestate.BecomeOperationalBackoff(s.mgr), Equals, 3*time.Minute)
+	c.Check(devicestate.EnsureOperationalShouldBackoff(s.mgr, t0.Add(4*time.Minute)), Equals, false)
+	c.Check(devicestate.BecomeOperationalBackoff(s.mgr), Equals, 3*time.Minute)
+}
+
+func (s *deviceMgrSuite) TestRegistrationStatus(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc",
+	})
+
+	status, err := s.mgr.RegistrationStatus()
+	c.Assert(err, IsNil)
+	c.Check(status, DeepEquals, &devicestate.RegistrationStatus{})
+
+	t0 := time.Now()
+	c.Check(devicestate.EnsureOperationalShouldBackoff(s.mgr, t0), Equals, false)
+	devicestate.IncEnsureOperationalAttempts(s.state)
+
+	chg := s.state.NewChange("become-operational", "...")
+	t := s.state.NewTask("request-serial", "test")
+	chg.AddTask(t)
+	devicestate.SetRegistrationError(s.state, errors.New("cannot retrieve request-id for making a request for a serial: unexpected status 501"))
+	// the task log is not looked at
+	t.Errorf("something else")
+
+	status, err = s.mgr.RegistrationStatus()
+	c.Assert(err, IsNil)
+	next := t0.Add(5 * time.Minute)
+	c.Check(status, DeepEquals, &devicestate.RegistrationStatus{
+		InProgress:  true,
+		Attempts:    1,
+		LastAttempt: &t0,
+		NextAttempt: &next,
+		LastError:   "cannot retrieve request-id for making a request for a serial: unexpected status 501",
+	})
+
+	t.SetStatus(state.ErrorStatus)
+	status, err = s.mgr.RegistrationStatus()
+	c.Assert(err, IsNil)
+	c.Check(status.InProgress, Equals, false)
+	c.Check(status.LastError, Matches, `(?s).*unexpected status 501.*`)
+
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand:  "canonical",
+		Model:  "pc",
+		Serial: "8989",
+	})
+	devicestate.SetRegistrationError(s.state, nil)
+	status, err = s.mgr.RegistrationStatus()
+	c.Assert(err, IsNil)
+	c.Check(status.Serial, Equals, "8989")
+	c.Check(status.NextAttempt, IsNil)
+	c.Check(status.LastError, Equals, "")
+}
+
 func (s *deviceMgrSuite) TestFullDeviceRegistrationMismatchedSerial(c *C) {
 	r1 := devicestate.MockKeyLength(testKeyLength)
 	defer r1()
@@ -2503,7 +2637,8 @@ func (s *deviceMgrSuite) TestRemodelUnhappy(c *C) {
 	}{
 		{map[string]string{"architecture": "pdp-7"}, "cannot remodel to different architectures yet"},
 		{map[string]string{"base": "core20"}, "cannot remodel to different bases yet"},
-		{map[string]string{"gadget": "other-gadget"}, "cannot remodel to different gadgets yet"},
+		{map[string]string{"brand": "my-brand"}, "cannot remodel to different brands yet"},
+		{map[string]string{"classic": "true"}, "cannot remodel between classic and non-classic models"},
 	} {
 		// copy current model unless new model test data is different
 		for k, v := range cur {
@@ -2512,12 +2647,19 @@ func (s *deviceMgrSuite) TestRemodelUnhappy(c *C) {
 			}
 			t.new[k] = v
 		}
-		new := s.brands.Model(t.new["brand"], t.new["model"], map[string]interface{}{
+		headers := map[string]interface{}{
 			"architecture": t.new["architecture"],
 			"kernel":       t.new["kernel"],
 			"gadget":       t.new["gadget"],
 			"base":         t.new["base"],
-		})
+		}
+		if t.new["classic"] != "" {
+			headers = map[string]interface{}{
+				"architecture": t.new["architecture"],
+				"classic":      t.new["classic"],
+			}
+		}
+		new := s.brands.Model(t.new["brand"], t.new["model"], headers)
 		chg, err := devicestate.Remodel(s.state, new)
 		c.Check(chg, IsNil)
 		c.Check(err, ErrorMatches, t.errStr)
@@ -2651,6 +2793,120 @@ func (s *deviceMgrSuite) TestRemodelTasksSwitchKernel(c *C) {
 	c.Assert(tss, HasLen, 2)
 }
 
+func (s *deviceMgrSuite) TestRemodelTasksSwitchGadgetTrack(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("seeded", true)
+	s.state.Set("refresh-privacy-key", "some-privacy-key")
+
+	var testDeviceCtx snapstate.DeviceContext
+
+	restore := devicestate.MockSnapstateUpdateWithDeviceContext(func(st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		c.Check(name, Equals, "pc")
+		c.Check(opts.Channel, Equals, "18")
+		c.Check(flags.NoReRefresh, Equals, true)
+		c.Check(deviceCtx, Equals, testDeviceCtx)
+		c.Check(fromChange, Equals, "99")
+
+		tDownload := s.state.NewTask("fake-download", fmt.Sprintf("Download %s to track %s", name, opts.Channel))
+		tValidate := s.state.NewTask("validate-snap", fmt.Sprintf("Validate %s", name))
+		tValidate.WaitFor(tDownload)
+		tUpdate := s.state.NewTask("fake-update", fmt.Sprintf("Update %s to track %s", name, opts.Channel))
+		tUpdate.WaitFor(tValidate)
+		ts := state.NewTaskSet(tDownload, tValidate, tUpdate)
+		ts.MarkEdge(tValidate, snapstate.DownloadAndChecksDoneEdge)
+		return ts, nil
+	})
+	defer restore()
+
+	current := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+		"base":         "core18",
+	})
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc=18",
+		"base":         "core18",
+		"revision":     "1",
+	})
+
+	testDeviceCtx = &snapstatetest.TrivialDeviceContext{Remodeling: true}
+
+	tss, err := devicestate.RemodelTasks(context.Background(), s.state, current, new, testDeviceCtx, "99")
+	c.Assert(err, IsNil)
+	// 1 gadget track switch plus the remodel task
+	c.Assert(tss, HasLen, 2)
+	c.Check(tss[0].Tasks()[2].Summary(), Equals, "Update pc to track 18")
+}
+
+func (s *deviceMgrSuite) TestRemodelSwitchGadget(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("seeded", true)
+	s.state.Set("refresh-privacy-key", "some-privacy-key")
+
+	restore := devicestate.MockSnapstateInstallWithDeviceContext(func(ctx context.Context, st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		c.Check(name, Equals, "other-gadget")
+		c.Check(opts.Channel, Equals, "18")
+		c.Check(deviceCtx.ForRemodeling(), Equals, true)
+
+		tDownload := s.state.NewTask("fake-download", fmt.Sprintf("Download %s", name))
+		tValidate := s.state.NewTask("validate-snap", fmt.Sprintf("Validate %s", name))
+		tValidate.WaitFor(tDownload)
+		tInstall := s.state.NewTask("fake-install", fmt.Sprintf("Install %s", name))
+		tInstall.WaitFor(tValidate)
+		ts := state.NewTaskSet(tDownload, tValidate, tInstall)
+		ts.MarkEdge(tValidate, snapstate.DownloadAndChecksDoneEdge)
+		return ts, nil
+	})
+	defer restore()
+	restore = devicestate.MockSnapstateRemoveWithDeviceContext(func(st *state.State, name string, revision snap.Revision, flags *snapstate.RemoveFlags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		c.Check(name, Equals, "pc")
+		c.Check(revision.Unset(), Equals, true)
+		c.Check(deviceCtx.ForRemodeling(), Equals, true)
+		c.Check(deviceCtx.Model().Gadget(), Equals, "other-gadget")
+
+		tRemove := s.state.NewTask("fake-remove", fmt.Sprintf("Remove %s", name))
+		return state.NewTaskSet(tRemove), nil
+	})
+	defer restore()
+
+	s.makeModelAssertionInState(c, "canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+		"base":         "core18",
+	})
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc-model",
+	})
+
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "other-gadget=18",
+		"base":         "core18",
+		"revision":     "1",
+	})
+	chg, err := devicestate.Remodel(s.state, new)
+	c.Assert(err, IsNil)
+	c.Check(chg.Summary(), Equals, "Refresh model assertion from revision 0 to 1")
+
+	tl := chg.Tasks()
+	// 1 new gadget, the set-model task and the removal of the old gadget
+	c.Assert(tl, HasLen, 3+1+1)
+	c.Check(tl[0].Summary(), Equals, "Download other-gadget")
+	c.Check(tl[2].Summary(), Equals, "Install other-gadget")
+	c.Check(tl[3].Kind(), Equals, "set-model")
+	c.Check(tl[3].WaitTasks(), DeepEquals, []*state.Task{tl[0], tl[1], tl[2]})
+	c.Check(tl[4].Summary(), Equals, "Remove pc")
+	c.Check(tl[4].WaitTasks(), DeepEquals, []*state.Task{tl[3]})
+}
+
 func (s *deviceMgrSuite) TestRemodelRequiredSnaps(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -3456,7 +3712,8 @@ volumes:
     bootloader: grub
 `
 
-func setupGadgetUpdate(c *C, st *state.State) (chg *state.Change, tsk *state.Task) {
+func (s *deviceMgrSuite) setupGadgetUpdate(c *C) (chg *state.Change, tsk *state.Task) {
+	st := s.state
 	siCurrent := &snap.SideInfo{
 		RealName: "foo-gadget",
 		Revision: snap.R(33),
@@ -3476,6 +3733,15 @@ func setupGadgetUpdate(c *C, st *state.State) (chg *state.Change, tsk *state.Tas
 
 	st.Lock()
 
+	s.makeModelAssertionInState(c, "my-brand", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "foo-gadget",
+	})
+	devicestatetest.SetDevice(st, &auth.DeviceState{
+		Brand: "my-brand",
+		Model: "pc-model",
+	})
 	snapstate.Set(st, "foo-gadget", &snapstate.SnapState{
 		SnapType: "gadget",
 		Sequence: []*snap.SideInfo{siCurrent},
@@ -3499,9 +3765,11 @@ func setupGadgetUpdate(c *C, st *state.State) (chg *state.Change, tsk *state.Tas
 func (s *deviceMgrSuite) TestUpdateGadgetOnCoreSimple(c *C) {
 	var updateCalled bool
 	var passedRollbackDir string
-	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string) error {
+	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string, policy gadget.UpdatePolicyFunc, verifier gadget.ContentVerifier) error {
 		updateCalled = true
 		passedRollbackDir = path
+		// not a remodel, the default policy is used
+		c.Check(policy, IsNil)
 		st, err := os.Stat(path)
 		c.Assert(err, IsNil)
 		m := st.Mode()
@@ -3511,7 +3779,7 @@ func (s *deviceMgrSuite) TestUpdateGadgetOnCoreSimple(c *C) {
 	})
 	defer restore()
 
-	chg, t := setupGadgetUpdate(c, s.state)
+	chg, t := s.setupGadgetUpdate(c)
 
 	for i := 0; i < 6; i++ {
 		s.se.Ensure()
@@ -3531,45 +3799,67 @@ func (s *deviceMgrSuite) TestUpdateGadgetOnCoreSimple(c *C) {
 	c.Check(s.restartRequests, DeepEquals, []state.RestartType{state.RestartSystem})
 }
 
-func (s *deviceMgrSuite) TestUpdateGadgetOnCoreNoUpdateNeeded(c *C) {
-	var called bool
-	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string) error {
-		called = true
-		return gadget.ErrNoUpdate
+func (s *deviceMgrSuite) TestUpdateGadgetOnCoreVerifiesWithBrandKeys(c *C) {
+	var passedVerifier gadget.ContentVerifier
+	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string, policy gadget.UpdatePolicyFunc, verifier gadget.ContentVerifier) error {
+		passedVerifier = verifier
+		return nil
 	})
 	defer restore()
 
-	chg, t := setupGadgetUpdate(c, s.state)
+	chg, _ := s.setupGadgetUpdate(c)
 
-	s.se.Ensure()
-	s.se.Wait()
+	for i := 0; i < 6; i++ {
+		s.se.Ensure()
+		s.se.Wait()
+	}
 
 	s.state.Lock()
 	defer s.state.Unlock()
 	c.Assert(chg.IsReady(), Equals, true)
 	c.Check(chg.Err(), IsNil)
-	c.Check(t.Status(), Equals, state.DoneStatus)
-	c.Check(t.Log(), HasLen, 1)
-	c.Check(t.Log()[0], Matches, ".* INFO No gadget assets update needed")
-	c.Check(called, Equals, true)
-	c.Check(s.restartRequests, HasLen, 0)
-}
+	c.Assert(passedVerifier, NotNil)
 
-func (s *deviceMgrSuite) TestUpdateGadgetOnCoreRollbackDirCreateFailed(c *C) {
-	if os.Geteuid() == 0 {
-		c.Skip("this test cannot run as root (permissions are not honored)")
-	}
+	content := []byte("bootloader")
+	sig, err := asserts.SignDetached(content, brandPrivKey)
+	c.Assert(err, IsNil)
+	c.Check(passedVerifier(content, sig), IsNil)
+	c.Check(passedVerifier([]byte("tampered bootloader"), sig), ErrorMatches, "failed signature verification: .*")
 
-	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string) error {
-		return errors.New("unexpected call")
+	// signed by another brand
+	sig, err = asserts.SignDetached(content, brandPrivKey2)
+	c.Assert(err, IsNil)
+	c.Check(passedVerifier(content, sig), ErrorMatches, "failed signature verification: .*")
+}
+
+func (s *deviceMgrSuite) TestUpdateGadgetOnCoreRemodelPolicy(c *C) {
+	var passedPolicy gadget.UpdatePolicyFunc
+	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string, policy gadget.UpdatePolicyFunc, verifier gadget.ContentVerifier) error {
+		passedPolicy = policy
+		return nil
 	})
 	defer restore()
 
-	chg, t := setupGadgetUpdate(c, s.state)
+	chg, t := s.setupGadgetUpdate(c)
 
-	rollbackDir := filepath.Join(dirs.SnapRollbackDir, "foo-gadget_34")
-	err := os.MkdirAll(dirs.SnapRollbackDir, 0000)
-	c.Assert(err, IsNil)
+	s.state.Lock()
+	s.makeModelAssertionInState(c, "canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "foo-gadget",
+	})
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc-model",
+	})
+	newModel := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "foo-gadget",
+		"revision":     "1",
+	})
+	chg.Set("new-model", string(asserts.Encode(newModel)))
+	s.state.Unlock()
 
 	for i := 0; i < 6; i++ {
 		s.se.Ensure()
@@ -3579,23 +3869,164 @@ func (s *deviceMgrSuite) TestUpdateGadgetOnCoreRollbackDirCreateFailed(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
 	c.Assert(chg.IsReady(), Equals, true)
-	c.Check(chg.Err(), ErrorMatches, `(?s).*cannot prepare update rollback directory: .*`)
-	c.Check(t.Status(), Equals, state.ErrorStatus)
-	c.Check(osutil.IsDirectory(rollbackDir), Equals, false)
-	c.Check(s.restartRequests, HasLen, 0)
+	c.Check(chg.Err(), IsNil)
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	// editions of a remodeled gadget are not compared
+	c.Assert(passedPolicy, NotNil)
+	mbr := &gadget.LaidOutStructure{VolumeStructure: &gadget.VolumeStructure{Role: gadget.MBR}}
+	bare := &gadget.LaidOutStructure{VolumeStructure: &gadget.VolumeStructure{Type: "bare"}}
+	c.Check(passedPolicy(mbr, mbr), Equals, false)
+	c.Check(passedPolicy(bare, bare), Equals, true)
 }
 
-func (s *deviceMgrSuite) TestUpdateGadgetOnCoreUpdateFailed(c *C) {
-	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string) error {
-		return errors.New("gadget exploded")
+func (s *deviceMgrSuite) TestUpdateGadgetOnCoreNoUpdateNeeded(c *C) {
+	var called bool
+	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string, policy gadget.UpdatePolicyFunc, verifier gadget.ContentVerifier) error {
+		called = true
+		return gadget.ErrNoUpdate
 	})
 	defer restore()
-	chg, t := setupGadgetUpdate(c, s.state)
 
-	for i := 0; i < 6; i++ {
-		s.se.Ensure()
-		s.se.Wait()
-	}
+	chg, t := s.setupGadgetUpdate(c)
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(chg.IsReady(), Equals, true)
+	c.Check(chg.Err(), IsNil)
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	c.Check(t.Log(), HasLen, 1)
+	c.Check(t.Log()[0], Matches, ".* INFO No gadget assets update needed")
+	c.Check(called, Equals, true)
+	c.Check(s.restartRequests, HasLen, 0)
+}
+
+func (s *deviceMgrSuite) TestUpdateGadgetOnCoreCommandLineOnly(c *C) {
+	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string, policy gadget.UpdatePolicyFunc, verifier gadget.ContentVerifier) error {
+		return gadget.ErrNoUpdate
+	})
+	defer restore()
+
+	chg, t := s.setupGadgetUpdate(c)
+	err := ioutil.WriteFile(filepath.Join(dirs.SnapMountDir, "foo-gadget/34/cmdline.extra"), []byte("console=ttyS0"), 0644)
+	c.Assert(err, IsNil)
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(chg.IsReady(), Equals, true)
+	c.Check(chg.Err(), IsNil)
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	c.Assert(t.Log(), HasLen, 2)
+	c.Check(t.Log()[0], Matches, ".* INFO No gadget assets update needed")
+	c.Check(t.Log()[1], Matches, ".* INFO Updated kernel command line")
+	c.Check(s.bootloader.BootVars["snapd_extra_cmdline_args"], Equals, "console=ttyS0")
+	c.Check(s.restartRequests, DeepEquals, []state.RestartType{state.RestartSystem})
+}
+
+func (s *deviceMgrSuite) TestUpdateGadgetOnCoreCommandLineUndo(c *C) {
+	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string, policy gadget.UpdatePolicyFunc, verifier gadget.ContentVerifier) error {
+		return gadget.ErrNoUpdate
+	})
+	defer restore()
+
+	chg, t := s.setupGadgetUpdate(c)
+	err := ioutil.WriteFile(filepath.Join(dirs.SnapMountDir, "foo-gadget/33/cmdline.extra"), []byte("console=tty1"), 0644)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(dirs.SnapMountDir, "foo-gadget/34/cmdline.extra"), []byte("console=ttyS0"), 0644)
+	c.Assert(err, IsNil)
+	s.bootloader.SetBootVars(map[string]string{"snapd_extra_cmdline_args": "console=tty1"})
+
+	s.state.Lock()
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitFor(t)
+	chg.AddTask(terr)
+	s.state.Unlock()
+
+	for i := 0; i < 3; i++ {
+		s.se.Ensure()
+		s.se.Wait()
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(chg.IsReady(), Equals, true)
+	c.Check(chg.Err(), ErrorMatches, "(?s).*provoking total undo.*")
+	c.Check(t.Status(), Equals, state.UndoneStatus)
+	c.Assert(t.Log(), HasLen, 3)
+	c.Check(t.Log()[1], Matches, ".* INFO Updated kernel command line")
+	c.Check(t.Log()[2], Matches, ".* INFO Restored kernel command line")
+	c.Check(s.bootloader.BootVars["snapd_extra_cmdline_args"], Equals, "console=tty1")
+	c.Check(s.restartRequests, DeepEquals, []state.RestartType{state.RestartSystem, state.RestartSystem})
+}
+
+func (s *deviceMgrSuite) TestUpdateGadgetOnCoreCommandLineInvalid(c *C) {
+	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string, policy gadget.UpdatePolicyFunc, verifier gadget.ContentVerifier) error {
+		return gadget.ErrNoUpdate
+	})
+	defer restore()
+
+	chg, t := s.setupGadgetUpdate(c)
+	err := ioutil.WriteFile(filepath.Join(dirs.SnapMountDir, "foo-gadget/34/cmdline.extra"), []byte("init=/bin/sh"), 0644)
+	c.Assert(err, IsNil)
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(chg.IsReady(), Equals, true)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*cannot update kernel command line: invalid kernel command line in cmdline.extra: argument "init" is not allowed.*`)
+	c.Check(t.Status(), Equals, state.ErrorStatus)
+	c.Check(s.bootloader.BootVars["snapd_extra_cmdline_args"], Equals, "")
+	c.Check(s.restartRequests, HasLen, 0)
+}
+
+func (s *deviceMgrSuite) TestUpdateGadgetOnCoreRollbackDirCreateFailed(c *C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (permissions are not honored)")
+	}
+
+	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string, policy gadget.UpdatePolicyFunc, verifier gadget.ContentVerifier) error {
+		return errors.New("unexpected call")
+	})
+	defer restore()
+
+	chg, t := s.setupGadgetUpdate(c)
+
+	rollbackDir := filepath.Join(dirs.SnapRollbackDir, "foo-gadget_34")
+	err := os.MkdirAll(dirs.SnapRollbackDir, 0000)
+	c.Assert(err, IsNil)
+
+	for i := 0; i < 6; i++ {
+		s.se.Ensure()
+		s.se.Wait()
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(chg.IsReady(), Equals, true)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*cannot prepare update rollback directory: .*`)
+	c.Check(t.Status(), Equals, state.ErrorStatus)
+	c.Check(osutil.IsDirectory(rollbackDir), Equals, false)
+	c.Check(s.restartRequests, HasLen, 0)
+}
+
+func (s *deviceMgrSuite) TestUpdateGadgetOnCoreUpdateFailed(c *C) {
+	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string, policy gadget.UpdatePolicyFunc, verifier gadget.ContentVerifier) error {
+		return errors.New("gadget exploded")
+	})
+	defer restore()
+	chg, t := s.setupGadgetUpdate(c)
+
+	for i := 0; i < 6; i++ {
+		s.se.Ensure()
+		s.se.Wait()
+	}
 
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -3609,7 +4040,7 @@ func (s *deviceMgrSuite) TestUpdateGadgetOnCoreUpdateFailed(c *C) {
 }
 
 func (s *deviceMgrSuite) TestUpdateGadgetOnCoreNotDuringFirstboot(c *C) {
-	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string) error {
+	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string, policy gadget.UpdatePolicyFunc, verifier gadget.ContentVerifier) error {
 		return errors.New("unexpected call")
 	})
 	defer restore()
@@ -3653,7 +4084,7 @@ func (s *deviceMgrSuite) TestUpdateGadgetOnCoreNotDuringFirstboot(c *C) {
 }
 
 func (s *deviceMgrSuite) TestUpdateGadgetOnCoreBadGadgetYaml(c *C) {
-	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string) error {
+	restore := devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string, policy gadget.UpdatePolicyFunc, verifier gadget.ContentVerifier) error {
 		return errors.New("unexpected call")
 	})
 	defer restore()
@@ -3713,7 +4144,7 @@ func (s *deviceMgrSuite) TestUpdateGadgetOnClassicErrorsOut(c *C) {
 	restore := release.MockOnClassic(true)
 	defer restore()
 
-	restore = devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string) error {
+	restore = devicestate.MockGadgetUpdate(func(current, update gadget.GadgetData, path string, policy gadget.UpdatePolicyFunc, verifier gadget.ContentVerifier) error {
 		return errors.New("unexpected call")
 	})
 	defer restore()
@@ -3814,6 +4245,15 @@ volumes:
 
 	s.state.Lock()
 
+	s.makeModelAssertionInState(c, "my-brand", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "foo-gadget",
+	})
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand: "my-brand",
+		Model: "pc-model",
+	})
 	snapstate.Set(s.state, "foo-gadget", &snapstate.SnapState{
 		SnapType: "gadget",
 		Sequence: []*snap.SideInfo{siCurrent},
@@ -3864,7 +4304,7 @@ func (s *deviceMgrSuite) TestCurrentAndUpdateInfo(c *C) {
 		Type:     snap.TypeGadget,
 	}
 
-	current, update, err := devicestate.GadgetCurrentAndUpdate(s.state, snapsup)
+	current, update, err := devicestate.GadgetCurrentAndUpdate(s.state, snapsup, false)
 	c.Assert(current, IsNil)
 	c.Assert(update, IsNil)
 	c.Assert(err, IsNil)
@@ -3879,7 +4319,7 @@ func (s *deviceMgrSuite) TestCurrentAndUpdateInfo(c *C) {
 	// mock current first, but gadget.yaml is still missing
 	ci := snaptest.MockSnapWithFiles(c, snapYaml, siCurrent, nil)
 
-	current, update, err = devicestate.GadgetCurrentAndUpdate(s.state, snapsup)
+	current, update, err = devicestate.GadgetCurrentAndUpdate(s.state, snapsup, false)
 	c.Assert(current, IsNil)
 	c.Assert(update, IsNil)
 	c.Assert(err, ErrorMatches, "cannot read current gadget snap details: .*/33/meta/gadget.yaml: no such file or directory")
@@ -3888,14 +4328,14 @@ func (s *deviceMgrSuite) TestCurrentAndUpdateInfo(c *C) {
 	ioutil.WriteFile(filepath.Join(ci.MountDir(), "meta/gadget.yaml"), []byte(gadgetYaml), 0644)
 
 	// update missing snap.yaml
-	current, update, err = devicestate.GadgetCurrentAndUpdate(s.state, snapsup)
+	current, update, err = devicestate.GadgetCurrentAndUpdate(s.state, snapsup, false)
 	c.Assert(current, IsNil)
 	c.Assert(update, IsNil)
 	c.Assert(err, ErrorMatches, "cannot read candidate gadget snap details: cannot find installed snap .* .*/34/meta/snap.yaml")
 
 	ui := snaptest.MockSnapWithFiles(c, snapYaml, si, nil)
 
-	current, update, err = devicestate.GadgetCurrentAndUpdate(s.state, snapsup)
+	current, update, err = devicestate.GadgetCurrentAndUpdate(s.state, snapsup, false)
 	c.Assert(current, IsNil)
 	c.Assert(update, IsNil)
 	c.Assert(err, ErrorMatches, "cannot read candidate gadget snap details: .*/34/meta/gadget.yaml: no such file or directory")
@@ -3910,7 +4350,7 @@ volumes:
 	// drop gadget.yaml for update snap
 	ioutil.WriteFile(filepath.Join(ui.MountDir(), "meta/gadget.yaml"), []byte(updateGadgetYaml), 0644)
 
-	current, update, err = devicestate.GadgetCurrentAndUpdate(s.state, snapsup)
+	current, update, err = devicestate.GadgetCurrentAndUpdate(s.state, snapsup, false)
 	c.Assert(err, IsNil)
 	c.Assert(current, DeepEquals, &gadget.GadgetData{
 		Info: &gadget.Info{
@@ -3935,6 +4375,64 @@ volumes:
 	})
 }
 
+func (s *deviceMgrSuite) TestCurrentAndUpdateInfoRemodel(c *C) {
+	siCurrent := &snap.SideInfo{
+		RealName: "foo-gadget",
+		Revision: snap.R(33),
+		SnapID:   "foo-id",
+	}
+	si := &snap.SideInfo{
+		RealName: "new-gadget",
+		Revision: snap.R(1),
+		SnapID:   "new-gadget-id",
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.makeModelAssertionInState(c, "canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "foo-gadget",
+	})
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc-model",
+	})
+
+	snapstate.Set(s.state, "foo-gadget", &snapstate.SnapState{
+		SnapType: "gadget",
+		Sequence: []*snap.SideInfo{siCurrent},
+		Current:  siCurrent.Revision,
+		Active:   true,
+	})
+	ci := snaptest.MockSnapWithFiles(c, snapYaml, siCurrent, [][]string{
+		{"meta/gadget.yaml", gadgetYaml},
+	})
+	ui := snaptest.MockSnapWithFiles(c, "name: new-gadget\ntype: gadget\n", si, [][]string{
+		{"meta/gadget.yaml", gadgetYaml},
+	})
+
+	snapsup := &snapstate.SnapSetup{
+		SideInfo: si,
+		Type:     snap.TypeGadget,
+	}
+
+	// not remodeling, the new gadget is not installed
+	current, update, err := devicestate.GadgetCurrentAndUpdate(s.state, snapsup, false)
+	c.Assert(err, IsNil)
+	c.Check(current, IsNil)
+	c.Check(update, IsNil)
+
+	// when remodeling the gadget of the current model is used
+	current, update, err = devicestate.GadgetCurrentAndUpdate(s.state, snapsup, true)
+	c.Assert(err, IsNil)
+	c.Assert(current, NotNil)
+	c.Check(current.RootDir, Equals, ci.MountDir())
+	c.Assert(update, NotNil)
+	c.Check(update.RootDir, Equals, ui.MountDir())
+}
+
 func (s *deviceMgrSuite) TestGadgetUpdateBlocksWhenOtherTasks(c *C) {
 	restore := release.MockOnClassic(true)
 	defer restore()
@@ -3980,3 +4478,525 @@ func (s *deviceMgrSuite) TestGadgetUpdateBlocksOtherTasks(c *C) {
 	// not blocking without gadget update task
 	c.Assert(devicestate.GadgetUpdateBlocked(t1, []*state.Task{t2}), Equals, false)
 }
+
+func (s *deviceMgrSuite) TestFactoryResetUnhappy(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// not seeded
+	_, err := devicestate.FactoryReset(s.state)
+	c.Assert(err, ErrorMatches, "cannot factory reset until fully seeded")
+
+	s.state.Set("seeded", true)
+
+	// other changes in progress
+	chg := s.state.NewChange("other", "...")
+	chg.AddTask(s.state.NewTask("nop", "..."))
+	_, err = devicestate.FactoryReset(s.state)
+	c.Assert(err, ErrorMatches, "cannot factory reset, other changes are in progress")
+	c.Check(err, FitsTypeOf, &snapstate.ChangeConflictError{})
+
+	// classic
+	restore := release.MockOnClassic(true)
+	defer restore()
+	_, err = devicestate.FactoryReset(s.state)
+	c.Assert(err, ErrorMatches, "cannot factory reset classic systems")
+}
+
+// mockSeedDisk mocks the disk of the seed partition mounted at
+// /run/mnt/ubuntu-seed
+func (s *deviceMgrSuite) mockSeedDisk(c *C) (restore func()) {
+	seedMnt := filepath.Join(dirs.GlobalRootDir, "/run/mnt/ubuntu-seed")
+	return devicestate.MockDiskFromMountPoint(func(mountpoint string) (disks.Disk, error) {
+		c.Check(mountpoint, Equals, seedMnt)
+		return &disks.MockDiskMapping{
+			DevNode: "/dev/sda",
+			DevNum:  "8:0",
+			DiskPartitions: []disks.Partition{
+				{KernelDeviceNode: "/dev/sda2", PartitionUUID: "seed-partuuid", FilesystemLabel: "ubuntu-seed"},
+				{KernelDeviceNode: "/dev/sda3", PartitionUUID: "data-partuuid", FilesystemLabel: "ubuntu-data"},
+			},
+			MountPoints: []string{seedMnt},
+		}, nil
+	})
+}
+
+func (s *deviceMgrSuite) TestFactoryReset(c *C) {
+	restore := s.mockSeedDisk(c)
+	defer restore()
+	restore = devicestate.MockBootSystemMode(func() (string, error) { return "run", nil })
+	defer restore()
+	c.Assert((&boot.Modeenv{Mode: "run", RecoverySystem: "20191127"}).Write(""), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("seeded", true)
+
+	s.makeModelAssertionInState(c, "my-brand", "my-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+	})
+	s.makeSerialAssertionInState(c, "my-brand", "my-model", "serialserialserial")
+	devicestate.KeypairManager(s.mgr).Put(devKey)
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand:  "my-brand",
+		Model:  "my-model",
+		Serial: "serialserialserial",
+		KeyID:  devKey.PublicKey().ID(),
+	})
+
+	chg, err := devicestate.FactoryReset(s.state)
+	c.Assert(err, IsNil)
+	c.Check(chg.Kind(), Equals, "factory-reset")
+	c.Check(chg.Summary(), Equals, "Reset device to factory state")
+	tl := chg.Tasks()
+	c.Assert(tl, HasLen, 1)
+	c.Check(tl[0].Kind(), Equals, "factory-reset")
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.restartRequests, DeepEquals, []state.RestartType{state.RestartSystem})
+	// the recovery system is booted in install mode next
+	c.Check(s.bootloader.BootVars["snapd_recovery_mode"], Equals, "install")
+	c.Check(s.bootloader.BootVars["snapd_recovery_system"], Equals, "20191127")
+	c.Check(s.bootloader.BootVars["snapd_factory_reset"], Equals, "1")
+
+	reset, err := devicestate.FactoryResetStatus(s.state)
+	c.Assert(err, IsNil)
+	c.Check(reset.RequestTime.IsZero(), Equals, false)
+	c.Check(reset.DoneTime, IsNil)
+
+	// only the details of the reset are kept on the seed partition,
+	// never the device key or serial
+	factoryResetDir := filepath.Join(dirs.GlobalRootDir, "/run/mnt/ubuntu-seed/factory-reset")
+	files, err := ioutil.ReadDir(factoryResetDir)
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 1)
+	c.Check(files[0].Name(), Equals, "factory-reset.json")
+
+	// until the state is wiped and the system seeded again the reset
+	// is still pending
+	s.state.Unlock()
+	err = devicestate.EnsureFactoryResetDone(s.mgr)
+	s.state.Lock()
+	c.Assert(err, IsNil)
+	c.Check(s.state.Changes(), HasLen, 1)
+	reset, err = devicestate.FactoryResetStatus(s.state)
+	c.Assert(err, IsNil)
+	c.Check(reset.DoneTime, IsNil)
+	c.Check(factoryResetDir, testutil.FilePresent)
+}
+
+func (s *deviceMgrSuite) TestFactoryResetNoSeedDisk(c *C) {
+	restore := devicestate.MockDiskFromMountPoint(func(mountpoint string) (disks.Disk, error) {
+		return nil, fmt.Errorf("mountpoint not found")
+	})
+	defer restore()
+	c.Assert((&boot.Modeenv{Mode: "run", RecoverySystem: "20191127"}).Write(""), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("seeded", true)
+
+	chg, err := devicestate.FactoryReset(s.state)
+	c.Assert(err, IsNil)
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Check(chg.Err(), ErrorMatches, `(?s).*cannot factory reset: cannot find the disk of the seed partition: mountpoint not found.*`)
+	c.Check(s.restartRequests, HasLen, 0)
+	c.Check(s.bootloader.BootVars["snapd_factory_reset"], Equals, "")
+	_, err = devicestate.FactoryResetStatus(s.state)
+	c.Check(err, Equals, state.ErrNoState)
+}
+
+func (s *deviceMgrSuite) mockFactoryResetDir(c *C, requestTime time.Time) (factoryResetDir string) {
+	factoryResetDir = filepath.Join(dirs.GlobalRootDir, "/run/mnt/ubuntu-seed/factory-reset")
+	c.Assert(os.MkdirAll(factoryResetDir, 0700), IsNil)
+	data, err := json.Marshal(&devicestate.FactoryResetState{RequestTime: requestTime})
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(factoryResetDir, "factory-reset.json"), data, 0600)
+	c.Assert(err, IsNil)
+	return factoryResetDir
+}
+
+func (s *deviceMgrSuite) findFactoryResetChange() *state.Change {
+	for _, chg := range s.state.Changes() {
+		if chg.Kind() == "factory-reset" {
+			return chg
+		}
+	}
+	return nil
+}
+
+func (s *deviceMgrSuite) TestEnsureFactoryResetDone(c *C) {
+	mode := "install"
+	restore := devicestate.MockBootSystemMode(func() (string, error) { return mode, nil })
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.makeModelAssertionInState(c, "my-brand", "my-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+	})
+
+	requestTime := time.Now().Add(-time.Hour)
+	factoryResetDir := s.mockFactoryResetDir(c, requestTime)
+
+	// the system was wiped and is seeded again
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand: "my-brand",
+		Model: "my-model",
+	})
+	devicestatetest.MockGadget(c, s.state, "pc", snap.R(2), nil)
+	s.state.Set("seeded", true)
+
+	// not in run mode yet, nothing happens
+	s.state.Unlock()
+	err := devicestate.EnsureFactoryResetDone(s.mgr)
+	s.state.Lock()
+	c.Assert(err, IsNil)
+	c.Check(s.findFactoryResetChange(), IsNil)
+
+	// not seeded yet, nothing happens
+	mode = "run"
+	s.state.Set("seeded", false)
+	s.state.Unlock()
+	err = devicestate.EnsureFactoryResetDone(s.mgr)
+	s.state.Lock()
+	c.Assert(err, IsNil)
+	c.Check(s.findFactoryResetChange(), IsNil)
+
+	s.state.Set("seeded", true)
+
+	s.state.Unlock()
+	err = devicestate.EnsureFactoryResetDone(s.mgr)
+	s.state.Lock()
+	c.Assert(err, IsNil)
+
+	chg := s.findFactoryResetChange()
+	c.Assert(chg, NotNil)
+	c.Check(chg.Summary(), Equals, "Complete factory reset")
+	tl := chg.Tasks()
+	c.Assert(tl, HasLen, 1)
+	c.Check(tl[0].Kind(), Equals, "finish-factory-reset")
+
+	// the device does not register again meanwhile
+	s.state.Unlock()
+	err = devicestate.EnsureOperational(s.mgr)
+	s.state.Lock()
+	c.Assert(err, IsNil)
+	c.Check(s.state.Changes(), HasLen, 1)
+
+	// only run the change, the device registers again afterwards
+	s.state.Unlock()
+	c.Assert(s.o.TaskRunner().Ensure(), IsNil)
+	s.o.TaskRunner().Wait()
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+
+	// the device has no identity until it registered again
+	device, err := devicestatetest.Device(s.state)
+	c.Assert(err, IsNil)
+	c.Check(device.Serial, Equals, "")
+	c.Check(device.KeyID, Equals, "")
+
+	reset, err := devicestate.FactoryResetStatus(s.state)
+	c.Assert(err, IsNil)
+	c.Check(reset.RequestTime.Equal(requestTime), Equals, true)
+	c.Check(reset.DoneTime, NotNil)
+
+	c.Check(factoryResetDir, testutil.FileAbsent)
+
+	// no change of a single reset
+	s.state.Unlock()
+	err = devicestate.EnsureFactoryResetDone(s.mgr)
+	s.state.Lock()
+	c.Assert(err, IsNil)
+	c.Check(s.state.Changes(), HasLen, 1)
+
+	// the device registers again
+	s.state.Unlock()
+	err = devicestate.EnsureOperational(s.mgr)
+	s.state.Lock()
+	c.Assert(err, IsNil)
+	c.Check(s.findBecomeOperationalChange(), NotNil)
+}
+
+func (s *deviceMgrSuite) mockInstallMode(c *C, gadgetYaml string) (restore func()) {
+	// the seed partition is on /dev/sda, where snap-recovery creates the
+	// data partition
+	restoreDisk := s.mockSeedDisk(c)
+	// snap-recovery is run from the snapd lib exec dir
+	c.Assert(os.MkdirAll(dirs.DistroLibExecDir, 0755), IsNil)
+
+	// the modeenv written by the initramfs for install mode
+	c.Assert((&boot.Modeenv{Mode: "install", RecoverySystem: "20191127"}).Write(""), IsNil)
+
+	siGadget := &snap.SideInfo{RealName: "pc", Revision: snap.R(1)}
+	snaptest.MockSnap(c, gadgetYaml, siGadget)
+	// the base and kernel snaps get copied to the run system
+	c.Assert(os.MkdirAll(dirs.SnapBlobDir, 0755), IsNil)
+	siBase := &snap.SideInfo{RealName: "core20", Revision: snap.R(2)}
+	baseInfo := snaptest.MockSnap(c, "name: core20\ntype: base\nversion: 1\n", siBase)
+	c.Assert(ioutil.WriteFile(baseInfo.MountFile(), []byte("core20"), 0644), IsNil)
+	siKernel := &snap.SideInfo{RealName: "pc-kernel", Revision: snap.R(3)}
+	kernelInfo := snaptest.MockSnap(c, "name: pc-kernel\ntype: kernel\nversion: 1\n", siKernel)
+	c.Assert(ioutil.WriteFile(kernelInfo.MountFile(), []byte("pc-kernel"), 0644), IsNil)
+	s.bootloader.SetBootVars(map[string]string{
+		"snap_mode":   "",
+		"snap_kernel": "pc-kernel_3.snap",
+		"snap_core":   "core20_2.snap",
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "pc", &snapstate.SnapState{
+		SnapType: "gadget",
+		Active:   true,
+		Sequence: []*snap.SideInfo{siGadget},
+		Current:  siGadget.Revision,
+	})
+	snapstate.Set(s.state, "core20", &snapstate.SnapState{
+		SnapType: "base",
+		Active:   true,
+		Sequence: []*snap.SideInfo{siBase},
+		Current:  siBase.Revision,
+	})
+	snapstate.Set(s.state, "pc-kernel", &snapstate.SnapState{
+		SnapType: "kernel",
+		Active:   true,
+		Sequence: []*snap.SideInfo{siKernel},
+		Current:  siKernel.Revision,
+	})
+	s.makeModelAssertionInState(c, "canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"base":         "core20",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+	})
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand:  "canonical",
+		Model:  "pc-model",
+		Serial: "serial",
+	})
+	s.state.Set("seeded", true)
+
+	restoreMode := devicestate.MockBootSystemMode(func() (string, error) { return "install", nil })
+	return func() {
+		restoreMode()
+		restoreDisk()
+	}
+}
+
+func (s *deviceMgrSuite) findInstallSystemChange() *state.Change {
+	for _, chg := range s.state.Changes() {
+		if chg.Kind() == "install-system" {
+			return chg
+		}
+	}
+	return nil
+}
+
+func (s *deviceMgrSuite) TestInstallModeRunsInstallDeviceHook(c *C) {
+	restore := s.mockInstallMode(c, "name: pc\ntype: gadget\nversion: 1\nhooks:\n  install-device:\n")
+	defer restore()
+
+	mockRecovery := testutil.MockCommand(c, filepath.Join(dirs.DistroLibExecDir, "snap-recovery"), "")
+	defer mockRecovery.Restore()
+	mockMount := testutil.MockCommand(c, "mount", "")
+	defer mockMount.Restore()
+	mockUmount := testutil.MockCommand(c, "umount", "")
+	defer mockUmount.Restore()
+	// the boot assets of the gadget
+	gadgetDir := filepath.Join(dirs.SnapMountDir, "pc/1")
+	c.Assert(ioutil.WriteFile(filepath.Join(gadgetDir, "shim.efi.signed"), []byte("shim"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(gadgetDir, "grubx64.efi"), []byte("grub"), 0644), IsNil)
+
+	var hookCalls []string
+	restore = hookstate.MockRunHook(func(ctx *hookstate.Context, _ *tomb.Tomb) ([]byte, error) {
+		hookCalls = append(hookCalls, ctx.HookName())
+		// the run system is not set up yet
+		c.Check(mockRecovery.Calls(), HasLen, 0)
+
+		_, _, err := ctlcmd.Run(ctx, []string{"set", "secure-element.provisioned=true"}, 0)
+		c.Check(err, IsNil)
+		_, _, err = ctlcmd.Run(ctx, []string{"restart", "pc.svc"}, 0)
+		c.Check(err, ErrorMatches, `cannot use "restart" from the install-device hook`)
+		return nil, nil
+	})
+	defer restore()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.findInstallSystemChange()
+	c.Assert(chg, NotNil)
+	c.Check(chg.Err(), IsNil)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(hookCalls, DeepEquals, []string{"install-device"})
+	c.Check(mockRecovery.Calls(), DeepEquals, [][]string{
+		{"snap-recovery", filepath.Join(dirs.SnapMountDir, "pc/1"), "/dev/sda"},
+	})
+	c.Check(s.restartRequests, DeepEquals, []state.RestartType{state.RestartSystem})
+
+	// the data partition of the run system was set up
+	runData := filepath.Join(dirs.GlobalRootDir, "/run/mnt/run-ubuntu-data")
+	c.Check(mockMount.Calls(), DeepEquals, [][]string{
+		{"mount", filepath.Join(dirs.GlobalRootDir, "/dev/disk/by-partuuid/data-partuuid"), runData},
+	})
+	c.Check(mockUmount.Calls(), DeepEquals, [][]string{{"umount", runData}})
+	systemData := filepath.Join(runData, "system-data")
+	modeenv, err := boot.ReadModeenv(systemData)
+	c.Assert(err, IsNil)
+	c.Check(modeenv, DeepEquals, &boot.Modeenv{
+		Mode:           "run",
+		RecoverySystem: "20191127",
+		Base:           "core20_2.snap",
+		Kernel:         "pc-kernel_3.snap",
+	})
+	c.Check(filepath.Join(dirs.SnapBlobDirUnder(systemData), "core20_2.snap"), testutil.FileEquals, "core20")
+	c.Check(filepath.Join(dirs.SnapBlobDirUnder(systemData), "pc-kernel_3.snap"), testutil.FileEquals, "pc-kernel")
+	// with its initial boot chains
+	data, err := ioutil.ReadFile(filepath.Join(dirs.SnapDeviceDirUnder(systemData), "boot-chains"))
+	c.Assert(err, IsNil)
+	var chains boot.BootChains
+	c.Assert(json.Unmarshal(data, &chains), IsNil)
+	c.Assert(chains.Current, HasLen, 2)
+	c.Check(chains.Current[0].AssetChain, HasLen, 2)
+	c.Check(chains.Current[0].Kernel, Equals, "pc-kernel")
+	c.Check(chains.Current[0].KernelRevision, Equals, "3")
+	c.Check(chains.Current[0].KernelCmdlines, DeepEquals, []string{"snapd_recovery_mode=run"})
+	c.Check(chains.Current[1].AssetChain, HasLen, 1)
+	c.Check(chains.Current[1].KernelCmdlines, DeepEquals, []string{"snapd_recovery_mode=recover snapd_recovery_system=20191127"})
+
+	var provisioned bool
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Get("pc", "secure-element.provisioned", &provisioned), IsNil)
+	c.Check(provisioned, Equals, true)
+}
+
+func (s *deviceMgrSuite) TestInstallModeInstallDeviceHookFails(c *C) {
+	restore := s.mockInstallMode(c, "name: pc\ntype: gadget\nversion: 1\nhooks:\n  install-device:\n")
+	defer restore()
+
+	mockRecovery := testutil.MockCommand(c, filepath.Join(dirs.DistroLibExecDir, "snap-recovery"), "")
+	defer mockRecovery.Restore()
+
+	restore = hookstate.MockRunHook(func(ctx *hookstate.Context, _ *tomb.Tomb) ([]byte, error) {
+		return []byte("cannot provision secure element"), errors.New("exit status 1")
+	})
+	defer restore()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.findInstallSystemChange()
+	c.Assert(chg, NotNil)
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*cannot provision secure element.*`)
+	// the install was aborted before the disk was touched
+	c.Check(mockRecovery.Calls(), HasLen, 0)
+	c.Check(s.restartRequests, HasLen, 0)
+	for _, t := range chg.Tasks() {
+		if t.Kind() == "setup-run-system" {
+			c.Check(t.Status(), Equals, state.HoldStatus)
+		}
+	}
+
+	// and it is not started over
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+	c.Check(s.state.Changes(), HasLen, 1)
+}
+
+func (s *deviceMgrSuite) TestInstallModeWithoutInstallDeviceHook(c *C) {
+	restore := s.mockInstallMode(c, "name: pc\ntype: gadget\nversion: 1\n")
+	defer restore()
+
+	mockRecovery := testutil.MockCommand(c, filepath.Join(dirs.DistroLibExecDir, "snap-recovery"), "echo 'cannot create the partitions'; exit 1")
+	defer mockRecovery.Restore()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.findInstallSystemChange()
+	c.Assert(chg, NotNil)
+	c.Assert(chg.Tasks(), HasLen, 1)
+	c.Check(chg.Tasks()[0].Kind(), Equals, "setup-run-system")
+	c.Check(chg.Err(), ErrorMatches, `(?s).*cannot setup the run system: cannot create the partitions.*`)
+	c.Check(mockRecovery.Calls(), HasLen, 1)
+	c.Check(s.restartRequests, HasLen, 0)
+}
+
+func (s *deviceMgrSuite) TestInstallModeFactoryReset(c *C) {
+	restore := s.mockInstallMode(c, "name: pc\ntype: gadget\nversion: 1\n")
+	defer restore()
+	// the initramfs found the factory reset requested
+	c.Assert((&boot.Modeenv{Mode: "install", RecoverySystem: "20191127", FactoryReset: true}).Write(""), IsNil)
+	s.bootloader.SetBootVars(map[string]string{
+		"snapd_recovery_mode":   "install",
+		"snapd_recovery_system": "20191127",
+		"snapd_factory_reset":   "1",
+	})
+
+	mockRecovery := testutil.MockCommand(c, filepath.Join(dirs.DistroLibExecDir, "snap-recovery"), "")
+	defer mockRecovery.Restore()
+	mockMount := testutil.MockCommand(c, "mount", "")
+	defer mockMount.Restore()
+	mockUmount := testutil.MockCommand(c, "umount", "")
+	defer mockUmount.Restore()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.findInstallSystemChange()
+	c.Assert(chg, NotNil)
+	c.Check(chg.Err(), IsNil)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	// the data of the previous run system is wiped
+	c.Check(mockRecovery.Calls(), DeepEquals, [][]string{
+		{"snap-recovery", "--factory-reset", filepath.Join(dirs.SnapMountDir, "pc/1"), "/dev/sda"},
+	})
+	// and the run system is booted next
+	c.Check(s.bootloader.BootVars["snapd_recovery_mode"], Equals, "run")
+	c.Check(s.bootloader.BootVars["snapd_factory_reset"], Equals, "")
+	c.Check(s.restartRequests, DeepEquals, []state.RestartType{state.RestartSystem})
+}
+
+func (s *deviceMgrSuite) TestEnsureInstalledNotInstallMode(c *C) {
+	restore := devicestate.MockBootSystemMode(func() (string, error) { return "run", nil })
+	defer restore()
+
+	s.state.Lock()
+	s.state.Set("seeded", true)
+	s.state.Unlock()
+
+	c.Assert(devicestate.EnsureInstalled(s.mgr), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.findInstallSystemChange(), IsNil)
+}
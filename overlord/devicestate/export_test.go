@@ -25,9 +25,11 @@ import (
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/osutil/disks"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storecontext"
+	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/timings"
 )
 
@@ -107,6 +109,14 @@ func MockSnapstateUpdateWithDeviceContext(f func(st *state.State, name string, o
 	}
 }
 
+func MockSnapstateRemoveWithDeviceContext(f func(st *state.State, name string, revision snap.Revision, flags *snapstate.RemoveFlags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error)) (restore func()) {
+	old := snapstateRemoveWithDeviceContext
+	snapstateRemoveWithDeviceContext = f
+	return func() {
+		snapstateRemoveWithDeviceContext = old
+	}
+}
+
 func EnsureSeedYaml(m *DeviceManager) error {
 	return m.ensureSeedYaml()
 }
@@ -121,6 +131,14 @@ func MockPopulateStateFromSeed(f func(*state.State, timings.Measurer) ([]*state.
 	}
 }
 
+func EnsureFactoryResetDone(m *DeviceManager) error {
+	return m.ensureFactoryResetDone()
+}
+
+func EnsureOperational(m *DeviceManager) error {
+	return m.ensureOperational()
+}
+
 func EnsureBootOk(m *DeviceManager) error {
 	return m.ensureBootOk()
 }
@@ -152,6 +170,7 @@ var (
 
 	IncEnsureOperationalAttempts = incEnsureOperationalAttempts
 	EnsureOperationalAttempts    = ensureOperationalAttempts
+	SetRegistrationError         = setRegistrationError
 
 	RemodelTasks = remodelTasks
 
@@ -163,10 +182,30 @@ var (
 	GadgetCurrentAndUpdate = gadgetCurrentAndUpdate
 )
 
-func MockGadgetUpdate(mock func(current, update gadget.GadgetData, path string) error) (restore func()) {
+func MockGadgetUpdate(mock func(current, update gadget.GadgetData, path string, policy gadget.UpdatePolicyFunc, verifier gadget.ContentVerifier) error) (restore func()) {
 	old := gadgetUpdate
 	gadgetUpdate = mock
 	return func() {
 		gadgetUpdate = old
 	}
 }
+
+func MockBootSystemMode(f func() (string, error)) (restore func()) {
+	old := bootSystemMode
+	bootSystemMode = f
+	return func() {
+		bootSystemMode = old
+	}
+}
+
+func MockDiskFromMountPoint(f func(mountpoint string) (disks.Disk, error)) (restore func()) {
+	old := diskFromMountPoint
+	diskFromMountPoint = f
+	return func() {
+		diskFromMountPoint = old
+	}
+}
+
+func EnsureInstalled(m *DeviceManager) error {
+	return m.ensureInstalled()
+}
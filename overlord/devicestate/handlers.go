@@ -25,10 +25,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -37,11 +39,13 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/httputil"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/disks"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/configstate/config"
@@ -100,14 +104,11 @@ func (m *DeviceManager) doSetModel(t *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 	for snapName, snapst := range snapStates {
-		// TODO: remove this type restriction once we remodel
-		//       gadgets and add tests that ensure
-		//       that the required flag is properly set/unset
 		typ, err := snapst.Type()
 		if err != nil {
 			return err
 		}
-		if typ != snap.TypeApp && typ != snap.TypeBase && typ != snap.TypeKernel {
+		if typ != snap.TypeApp && typ != snap.TypeBase && typ != snap.TypeKernel && typ != snap.TypeGadget {
 			continue
 		}
 		// clean required flag if no-longer needed
@@ -355,14 +356,67 @@ type requestIDResp struct {
 	RequestID string `json:"request-id"`
 }
 
+// registrationOption returns the value of the given registration.*
+// system option, or "" if unset.
+func registrationOption(tr *config.Transaction, name string) string {
+	var v interface{}
+	if err := tr.Get("core", "registration."+name, &v); err != nil || v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// registrationRetryParams returns the interval between retries of the
+// serial request and the maximum number of tentatives, honouring the
+// registration.retry-interval and registration.max-retries system
+// options.
+func registrationRetryParams(st *state.State) (interval time.Duration, tentatives int) {
+	interval, tentatives = retryInterval, maxTentatives
+	tr := config.NewTransaction(st)
+	if v := registrationOption(tr, "retry-interval"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			interval = d
+		}
+	}
+	if v := registrationOption(tr, "max-retries"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			tentatives = n
+		}
+	}
+	return interval, tentatives
+}
+
+// registrationError is the last error met while requesting the serial of
+// the device, as reported by RegistrationStatus.
+type registrationError struct {
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// setRegistrationError records the last error met while requesting the
+// serial of the device, a nil error clears it.
+func setRegistrationError(st *state.State, err error) {
+	if err == nil {
+		st.Set("registration-error", nil)
+		return
+	}
+	st.Set("registration-error", &registrationError{
+		Message: err.Error(),
+		Time:    time.Now(),
+	})
+}
+
 func retryErr(t *state.Task, nTentatives int, reason string, a ...interface{}) error {
 	t.State().Lock()
 	defer t.State().Unlock()
-	if nTentatives >= maxTentatives {
+	// errors that will be retried are otherwise only logged
+	setRegistrationError(t.State(), fmt.Errorf(reason, a...))
+	interval, tentatives := registrationRetryParams(t.State())
+	if nTentatives >= tentatives {
 		return fmt.Errorf(reason, a...)
 	}
 	t.Errorf(reason, a...)
-	return &state.Retry{After: retryInterval}
+	return &state.Retry{After: interval}
 }
 
 type serverError struct {
@@ -697,11 +751,18 @@ func getSerialRequestConfig(t *state.Task, regCtx registrationContext, client *h
 	return &cfg, nil
 }
 
-func (m *DeviceManager) doRequestSerial(t *state.Task, _ *tomb.Tomb) error {
+func (m *DeviceManager) doRequestSerial(t *state.Task, _ *tomb.Tomb) (err error) {
 	st := t.State()
 	st.Lock()
 	defer st.Unlock()
 
+	defer func() {
+		if _, ok := err.(*state.Retry); ok {
+			return
+		}
+		setRegistrationError(st, err)
+	}()
+
 	perfTimings := timings.NewForTask(t)
 	defer perfTimings.Save(st)
 
@@ -966,11 +1027,23 @@ func pendingGadgetInfo(snapsup *snapstate.SnapSetup) (*gadget.GadgetData, error)
 	return &gadget.GadgetData{Info: update, RootDir: info.MountDir()}, nil
 }
 
-func gadgetCurrentAndUpdate(st *state.State, snapsup *snapstate.SnapSetup) (current *gadget.GadgetData, update *gadget.GadgetData, err error) {
+func gadgetCurrentAndUpdate(st *state.State, snapsup *snapstate.SnapSetup, remodeling bool) (current *gadget.GadgetData, update *gadget.GadgetData, err error) {
 	snapst, err := snapState(st, snapsup.InstanceName())
 	if err != nil {
 		return nil, nil, err
 	}
+	if !snapst.IsInstalled() && remodeling {
+		// remodeling to a different gadget, the assets to update
+		// are the ones of the gadget of the current model
+		model, err := findModel(st)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot find current model: %v", err)
+		}
+		snapst, err = snapState(st, model.Gadget())
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
 	currentData, err := currentGadgetInfo(snapst)
 	if err != nil {
@@ -990,9 +1063,39 @@ func gadgetCurrentAndUpdate(st *state.State, snapsup *snapstate.SnapSetup) (curr
 }
 
 var (
-	gadgetUpdate = gadget.Update
+	gadgetUpdate                    = gadget.Update
+	bootUpdateCommandLineFromGadget = boot.UpdateCommandLineFromGadget
 )
 
+// gadgetContentVerifier returns a verifier of gadget content signed with any
+// of the keys of the brand.
+func gadgetContentVerifier(st *state.State, brandID string) (gadget.ContentVerifier, error) {
+	db := assertstate.DB(st)
+	as, err := db.FindMany(asserts.AccountKeyType, map[string]string{
+		"account-id": brandID,
+	})
+	if err != nil && !asserts.IsNotFound(err) {
+		return nil, err
+	}
+	accKeys := make([]*asserts.AccountKey, len(as))
+	for i, a := range as {
+		accKeys[i] = a.(*asserts.AccountKey)
+	}
+
+	return func(content, signature []byte) error {
+		if len(accKeys) == 0 {
+			return fmt.Errorf("no account keys of brand %q", brandID)
+		}
+		var err error
+		for _, accKey := range accKeys {
+			if err = asserts.VerifyDetached(content, signature, accKey); err == nil {
+				return nil
+			}
+		}
+		return err
+	}, nil
+}
+
 func (m *DeviceManager) doUpdateGadgetAssets(t *state.Task, _ *tomb.Tomb) error {
 	if release.OnClassic {
 		return fmt.Errorf("cannot run update gadget assets task on a classic system")
@@ -1007,7 +1110,25 @@ func (m *DeviceManager) doUpdateGadgetAssets(t *state.Task, _ *tomb.Tomb) error
 		return err
 	}
 
-	currentData, updateData, err := gadgetCurrentAndUpdate(t.State(), snapsup)
+	remodeling := false
+	_, err = remodelCtxFromTask(t)
+	switch err {
+	case nil:
+		remodeling = true
+	case state.ErrNoState:
+		// not part of a remodel
+	default:
+		return err
+	}
+
+	var updatePolicy gadget.UpdatePolicyFunc
+	if remodeling {
+		// the new gadget may be unrelated to the old one, editions
+		// cannot be compared
+		updatePolicy = gadget.RemodelUpdatePolicy
+	}
+
+	currentData, updateData, err := gadgetCurrentAndUpdate(t.State(), snapsup, remodeling)
 	if err != nil {
 		return err
 	}
@@ -1016,22 +1137,45 @@ func (m *DeviceManager) doUpdateGadgetAssets(t *state.Task, _ *tomb.Tomb) error
 		return nil
 	}
 
+	deviceCtx, err := DeviceCtx(st, t, nil)
+	if err != nil {
+		return err
+	}
+	verifier, err := gadgetContentVerifier(st, deviceCtx.Model().BrandID())
+	if err != nil {
+		return err
+	}
+
 	snapRollbackDir, err := makeRollbackDir(fmt.Sprintf("%v_%v", snapsup.InstanceName(), snapsup.SideInfo.Revision))
 	if err != nil {
 		return fmt.Errorf("cannot prepare update rollback directory: %v", err)
 	}
 
 	st.Unlock()
-	err = gadgetUpdate(*currentData, *updateData, snapRollbackDir)
+	err = gadgetUpdate(*currentData, *updateData, snapRollbackDir, updatePolicy, verifier)
 	st.Lock()
+	assetsUpdated := true
 	if err != nil {
-		if err == gadget.ErrNoUpdate {
-			// no update needed
-			t.Logf("No gadget assets update needed")
-			return nil
+		if err != gadget.ErrNoUpdate {
+			return err
 		}
+		// no update needed
+		t.Logf("No gadget assets update needed")
+		assetsUpdated = false
+	}
+
+	st.Unlock()
+	cmdlineUpdated, err := bootUpdateCommandLineFromGadget(updateData.RootDir)
+	st.Lock()
+	if err != nil {
 		return err
 	}
+	if cmdlineUpdated {
+		t.Logf("Updated kernel command line")
+	}
+	if !assetsUpdated && !cmdlineUpdated {
+		return nil
+	}
 
 	t.SetStatus(state.DoneStatus)
 
@@ -1045,3 +1189,286 @@ func (m *DeviceManager) doUpdateGadgetAssets(t *state.Task, _ *tomb.Tomb) error
 
 	return nil
 }
+
+// undoUpdateGadgetAssets restores the kernel command line arguments of the
+// current gadget. The assets themselves are rolled back by gadget.Update
+// when it fails and are not restored once updated.
+func (m *DeviceManager) undoUpdateGadgetAssets(t *state.Task, _ *tomb.Tomb) error {
+	if release.OnClassic {
+		return fmt.Errorf("cannot run update gadget assets task on a classic system")
+	}
+
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, err := snapstate.TaskSnapSetup(t)
+	if err != nil {
+		return err
+	}
+
+	remodeling := false
+	_, err = remodelCtxFromTask(t)
+	switch err {
+	case nil:
+		remodeling = true
+	case state.ErrNoState:
+		// not part of a remodel
+	default:
+		return err
+	}
+
+	currentData, _, err := gadgetCurrentAndUpdate(st, snapsup, remodeling)
+	if err != nil {
+		return err
+	}
+	if currentData == nil {
+		// nothing was updated during first boot & seeding
+		return nil
+	}
+
+	st.Unlock()
+	cmdlineUpdated, err := bootUpdateCommandLineFromGadget(currentData.RootDir)
+	st.Lock()
+	if err != nil {
+		return err
+	}
+	if !cmdlineUpdated {
+		return nil
+	}
+	t.Logf("Restored kernel command line")
+
+	t.SetStatus(state.UndoneStatus)
+
+	st.RequestRestart(state.RestartSystem)
+
+	return nil
+}
+
+const factoryResetStateFile = "factory-reset.json"
+
+func (m *DeviceManager) doFactoryReset(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	// the reset installs the run system again from the recovery
+	// system it was installed from, which is on the seed partition
+	if _, err := installDisk(); err != nil {
+		return fmt.Errorf("cannot factory reset: %v", err)
+	}
+	modeenv, err := boot.ReadModeenv("")
+	if err != nil {
+		return fmt.Errorf("cannot read modeenv: %v", err)
+	}
+	if modeenv.RecoverySystem == "" {
+		return fmt.Errorf("cannot factory reset without a recovery system")
+	}
+
+	// start from a clean slate, this directory is on the seed
+	// partition which is kept when the writable data is wiped; only the
+	// details of the reset are recorded there, the device key and serial
+	// must not end up on the unencrypted seed and are wiped with the
+	// rest of the data, the device registers again after the reset
+	dir := factoryResetDir()
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("cannot prepare factory reset directory: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("cannot prepare factory reset directory: %v", err)
+	}
+
+	reset := &FactoryResetState{RequestTime: time.Now()}
+	data, err := json.Marshal(reset)
+	if err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFile(filepath.Join(dir, factoryResetStateFile), data, 0600, 0); err != nil {
+		return fmt.Errorf("cannot record factory reset: %v", err)
+	}
+
+	if err := boot.RequestFactoryReset(seedMnt(), modeenv.RecoverySystem); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	st.Set("factory-reset", reset)
+
+	t.SetStatus(state.DoneStatus)
+
+	st.RequestRestart(state.RestartSystem)
+
+	return nil
+}
+
+// doFinishFactoryReset completes a factory reset in the run system
+// installed by it, recording the reset in the new state. The device
+// registers again once the change is done.
+func (m *DeviceManager) doFinishFactoryReset(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	dir := factoryResetDir()
+	resetData, err := ioutil.ReadFile(filepath.Join(dir, factoryResetStateFile))
+	if err != nil {
+		return fmt.Errorf("cannot read factory reset details: %v", err)
+	}
+	var reset FactoryResetState
+	if err := json.Unmarshal(resetData, &reset); err != nil {
+		return fmt.Errorf("cannot read factory reset details: %v", err)
+	}
+
+	now := time.Now()
+	reset.DoneTime = &now
+	st.Set("factory-reset", &reset)
+
+	return os.RemoveAll(dir)
+}
+
+var diskFromMountPoint = disks.DiskFromMountPoint
+
+// seedMnt is where the initramfs mounts the ubuntu-seed partition, in all
+// modes.
+func seedMnt() string {
+	return filepath.Join(dirs.GlobalRootDir, "/run/mnt/ubuntu-seed")
+}
+
+// factoryResetDir is where the details of a factory reset are kept across
+// it, it is on the seed partition as the writable data is wiped.
+func factoryResetDir() string {
+	return filepath.Join(seedMnt(), "factory-reset")
+}
+
+// installDisk returns the disk holding the seed partition the system was
+// booted from, which is the disk the run system is installed to.
+func installDisk() (disks.Disk, error) {
+	disk, err := diskFromMountPoint(seedMnt())
+	if err != nil {
+		return nil, fmt.Errorf("cannot find the disk of the seed partition: %v", err)
+	}
+	return disk, nil
+}
+
+// runDataMnt is where the ubuntu-data partition of the run system is
+// mounted while it is set up, the data of install mode itself being
+// ephemeral.
+func runDataMnt() string {
+	return filepath.Join(dirs.GlobalRootDir, "/run/mnt/run-ubuntu-data")
+}
+
+// setupRunData populates the ubuntu-data partition created for the run
+// system with the given base and kernel snaps, with the modeenv the
+// initramfs boots the run system with and with its initial boot chains.
+func setupRunData(disk disks.Disk, gadgetInfo, baseInfo, kernelInfo *snap.Info) error {
+	installModeenv, err := boot.ReadModeenv("")
+	if err != nil {
+		return fmt.Errorf("cannot read modeenv: %v", err)
+	}
+	// the partition is looked up on the install disk, a filesystem
+	// labeled ubuntu-data on another disk must not be picked
+	partUUID, err := disk.FindMatchingPartitionUUIDWithFsLabel("ubuntu-data")
+	if err != nil {
+		return fmt.Errorf("cannot find the data partition: %v", err)
+	}
+
+	mnt := runDataMnt()
+	if err := os.MkdirAll(mnt, 0755); err != nil {
+		return err
+	}
+	dataPart := filepath.Join(dirs.GlobalRootDir, "/dev/disk/by-partuuid", partUUID)
+	if output, err := exec.Command("mount", dataPart, mnt).CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot mount the data partition: %v", osutil.OutputErr(output, err))
+	}
+
+	err = writeRunData(filepath.Join(mnt, "system-data"), installModeenv.RecoverySystem, gadgetInfo, baseInfo, kernelInfo)
+	if output, uerr := exec.Command("umount", mnt).CombinedOutput(); uerr != nil && err == nil {
+		err = fmt.Errorf("cannot unmount the data partition: %v", osutil.OutputErr(output, uerr))
+	}
+	return err
+}
+
+func writeRunData(systemData, recoverySystem string, gadgetInfo, baseInfo, kernelInfo *snap.Info) error {
+	snapsDir := dirs.SnapBlobDirUnder(systemData)
+	if err := os.MkdirAll(snapsDir, 0755); err != nil {
+		return err
+	}
+	for _, info := range []*snap.Info{baseInfo, kernelInfo} {
+		dst := filepath.Join(snapsDir, filepath.Base(info.MountFile()))
+		if err := osutil.CopyFile(info.MountFile(), dst, osutil.CopyFlagOverwrite); err != nil {
+			return fmt.Errorf("cannot copy snap %q: %v", info.InstanceName(), err)
+		}
+	}
+
+	modeenv := &boot.Modeenv{
+		Mode:           boot.ModeRun,
+		RecoverySystem: recoverySystem,
+		Base:           filepath.Base(baseInfo.MountFile()),
+		Kernel:         filepath.Base(kernelInfo.MountFile()),
+	}
+	if err := modeenv.Write(systemData); err != nil {
+		return err
+	}
+	return boot.RecordInitialBootChains(systemData, gadgetInfo.MountDir(), kernelInfo, recoverySystem)
+}
+
+func (m *DeviceManager) doSetupRunSystem(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	model, err := m.Model()
+	if err != nil {
+		return err
+	}
+	gadgetInfo, err := snapstate.CurrentInfo(st, model.Gadget())
+	if err != nil {
+		return fmt.Errorf("cannot get gadget info: %v", err)
+	}
+	if model.Base() == "" {
+		return fmt.Errorf("cannot setup the run system of a model without a base")
+	}
+	baseInfo, err := snapstate.CurrentInfo(st, model.Base())
+	if err != nil {
+		return fmt.Errorf("cannot get base info: %v", err)
+	}
+	kernelInfo, err := snapstate.CurrentInfo(st, model.Kernel())
+	if err != nil {
+		return fmt.Errorf("cannot get kernel info: %v", err)
+	}
+	disk, err := installDisk()
+	if err != nil {
+		return fmt.Errorf("cannot setup the run system: %v", err)
+	}
+	installModeenv, err := boot.ReadModeenv("")
+	if err != nil {
+		return fmt.Errorf("cannot read modeenv: %v", err)
+	}
+	args := []string{gadgetInfo.MountDir(), disk.KernelDeviceNode()}
+	if installModeenv.FactoryReset {
+		// the data of the previous run system is wiped
+		args = append([]string{"--factory-reset"}, args...)
+	}
+
+	// partitioning and creating the filesystems may take a while
+	st.Unlock()
+	output, err := exec.Command(filepath.Join(dirs.DistroLibExecDir, "snap-recovery"), args...).CombinedOutput()
+	if err == nil {
+		err = setupRunData(disk, gadgetInfo, baseInfo, kernelInfo)
+	} else {
+		err = osutil.OutputErr(output, err)
+	}
+	if err == nil && installModeenv.FactoryReset {
+		// boot the run system next
+		err = boot.ClearFactoryReset(seedMnt())
+	}
+	st.Lock()
+	if err != nil {
+		return fmt.Errorf("cannot setup the run system: %v", err)
+	}
+
+	t.SetStatus(state.DoneStatus)
+
+	st.RequestRestart(state.RestartSystem)
+
+	return nil
+}
@@ -63,7 +63,7 @@ const (
 	UpdateRemodel RemodelKind = iota
 	// same brand/model, different brand store
 	StoreSwitchRemodel
-	// different brand/model, maybe different brand store
+	// same brand, different model, maybe different brand store
 	ReregRemodel
 )
 
@@ -79,11 +79,9 @@ func (k RemodelKind) String() string {
 	panic(fmt.Sprintf("internal error: unknown remodel kind: %d", k))
 }
 
-// ClassifyRemodel returns what kind of remodeling is going from oldModel to newModel.
+// ClassifyRemodel returns what kind of remodeling is going from oldModel to newModel,
+// remodeling to a different brand is not supported.
 func ClassifyRemodel(oldModel, newModel *asserts.Model) RemodelKind {
-	if oldModel.BrandID() != newModel.BrandID() {
-		return ReregRemodel
-	}
 	if oldModel.Model() != newModel.Model() {
 		return ReregRemodel
 	}
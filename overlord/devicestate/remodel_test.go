@@ -135,22 +135,12 @@ func (s *remodelLogicSuite) TestClassifyRemodel(c *C) {
 			"store": "my-other-store",
 		}, devicestate.ReregRemodel},
 		{map[string]interface{}{
-			"authority-id": "other-brand",
-			"brand-id":     "other-brand",
-			"model":        "other-model",
+			"model": "other-model",
 		}, devicestate.ReregRemodel},
 		{map[string]interface{}{
-			"authority-id":   "other-brand",
-			"brand-id":       "other-brand",
 			"model":          "other-model",
 			"required-snaps": []interface{}{"other-required1"},
 		}, devicestate.ReregRemodel},
-		{map[string]interface{}{
-			"authority-id": "other-brand",
-			"brand-id":     "other-brand",
-			"model":        "other-model",
-			"store":        "my-other-store",
-		}, devicestate.ReregRemodel},
 	}
 
 	for _, t := range cases {
@@ -32,3 +32,21 @@ func MockCheckTimeout(t time.Duration) (restore func()) {
 }
 
 var KnownStatuses = knownStatuses
+
+func MockCheckInterval(d time.Duration) (restore func()) {
+	old := checkInterval
+	checkInterval = d
+	return func() {
+		checkInterval = old
+	}
+}
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
+
+var AutoRefreshHeldByHealth = autoRefreshHeldByHealth
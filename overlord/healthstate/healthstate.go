@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"sort"
 	"time"
 
 	"github.com/snapcore/snapd/logger"
@@ -34,7 +35,17 @@ import (
 	"github.com/snapcore/snapd/strutil"
 )
 
-var checkTimeout = 30 * time.Second
+var (
+	checkTimeout = 30 * time.Second
+	// checkInterval is how often the check-health hooks of the snaps
+	// are run
+	checkInterval = 6 * time.Hour
+	// unhealthyRefreshHold is for how long the auto-refresh of a snap
+	// whose current revision reported itself unhealthy is held
+	unhealthyRefreshHold = 24 * time.Hour
+
+	timeNow = time.Now
+)
 
 func init() {
 	if s, ok := os.LookupEnv("SNAPD_CHECK_HEALTH_HOOK_TIMEOUT"); ok {
@@ -44,8 +55,16 @@ func init() {
 			logger.Debugf("cannot override check-health timeout: %v", err)
 		}
 	}
+	if s, ok := os.LookupEnv("SNAPD_CHECK_HEALTH_INTERVAL"); ok {
+		if d, err := time.ParseDuration(s); err == nil {
+			checkInterval = d
+		} else {
+			logger.Debugf("cannot override check-health interval: %v", err)
+		}
+	}
 
 	snapstate.CheckHealthHook = Hook
+	snapstate.AutoRefreshHeldByHealth = autoRefreshHeldByHealth
 }
 
 func Hook(st *state.State, snapName string, snapRev snap.Revision) *state.Task {
@@ -95,6 +114,9 @@ type HealthState struct {
 	Status    HealthStatus  `json:"status"`
 	Message   string        `json:"message,omitempty"`
 	Code      string        `json:"code,omitempty"`
+	// UnhealthySince is when the revision started reporting itself
+	// unhealthy, for as long as it keeps doing so
+	UnhealthySince *time.Time `json:"unhealthy-since,omitempty"`
 }
 
 func Init(hookManager *hookstate.HookManager) {
@@ -173,6 +195,17 @@ func appendHealth(ctx *hookstate.Context, health *HealthState) error {
 		}
 		hs = map[string]*HealthState{}
 	}
+	health.UnhealthySince = nil
+	if health.Status.Unhealthy() {
+		since := health.Timestamp
+		if prev := hs[ctx.InstanceName()]; prev != nil && prev.Revision == health.Revision && prev.Status.Unhealthy() {
+			since = prev.Timestamp
+			if prev.UnhealthySince != nil {
+				since = *prev.UnhealthySince
+			}
+		}
+		health.UnhealthySince = &since
+	}
 	hs[ctx.InstanceName()] = health
 	st.Set("health", hs)
 
@@ -224,3 +257,102 @@ func Get(st *state.State, snap string) (*HealthState, error) {
 
 	return &health, nil
 }
+
+// Unhealthy returns whether the status denotes a snap that is not
+// working as expected.
+func (s HealthStatus) Unhealthy() bool {
+	return s == BlockedStatus || s == ErrorStatus
+}
+
+// autoRefreshHeldByHealth returns whether the auto-refresh of the snap
+// is held because its current revision just went unhealthy, that is it
+// has been reporting itself unhealthy for less than unhealthyRefreshHold.
+func autoRefreshHeldByHealth(st *state.State, instanceName string, current snap.Revision) bool {
+	health, err := Get(st, instanceName)
+	if err != nil {
+		logger.Noticef("cannot get the health of snap %q: %v", instanceName, err)
+		return false
+	}
+	if health == nil || health.Revision != current || !health.Status.Unhealthy() {
+		return false
+	}
+	since := health.Timestamp
+	if health.UnhealthySince != nil {
+		since = *health.UnhealthySince
+	}
+	return timeNow().Before(since.Add(unhealthyRefreshHold))
+}
+
+// HealthManager periodically runs the check-health hooks of the
+// installed snaps.
+type HealthManager struct {
+	state *state.State
+}
+
+// Manager returns a new HealthManager.
+func Manager(st *state.State) *HealthManager {
+	return &HealthManager{state: st}
+}
+
+// Ensure implements StateManager.Ensure. It runs the health checks of
+// the snaps whose health was not checked for longer than the check
+// interval.
+func (m *HealthManager) Ensure() error {
+	st := m.state
+	st.Lock()
+	defer st.Unlock()
+
+	var seeded bool
+	if err := st.Get("seeded", &seeded); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !seeded {
+		return nil
+	}
+	for _, chg := range st.Changes() {
+		if chg.Kind() == "check-health" && !chg.Status().Ready() {
+			// checks still in progress
+			return nil
+		}
+	}
+
+	snapStates, err := snapstate.All(st)
+	if err != nil {
+		return err
+	}
+	healths, err := All(st)
+	if err != nil {
+		return err
+	}
+
+	now := timeNow()
+	var due []string
+	for name, snapst := range snapStates {
+		if !snapst.Active {
+			continue
+		}
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			logger.Noticef("cannot check the health of snap %q: %v", name, err)
+			continue
+		}
+		if info.Hooks["check-health"] == nil {
+			continue
+		}
+		if health := healths[name]; health != nil && health.Revision == snapst.Current && now.Before(health.Timestamp.Add(checkInterval)) {
+			continue
+		}
+		due = append(due, name)
+	}
+	if len(due) == 0 {
+		return nil
+	}
+	sort.Strings(due)
+
+	chg := st.NewChange("check-health", fmt.Sprintf("Run health checks of snaps %s", strutil.Quoted(due)))
+	for _, name := range due {
+		chg.AddTask(Hook(st, name, snapStates[name].Current))
+	}
+	st.EnsureBefore(0)
+	return nil
+}
@@ -253,3 +253,144 @@ func (s *healthSuite) TestSetFromHookContextEmpty(c *check.C) {
 	// no health in the context -> no health in state
 	c.Check(s.state.Get("health", &hs), check.Equals, state.ErrNoState)
 }
+
+func (s *healthSuite) mockCheckHealthHook(c *check.C) {
+	hookFn := filepath.Join(s.info.MountDir(), "meta", "hooks", "check-health")
+	c.Assert(os.MkdirAll(filepath.Dir(hookFn), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(hookFn, nil, 0755), check.IsNil)
+}
+
+func (s *healthSuite) TestManagerEnsure(c *check.C) {
+	s.mockCheckHealthHook(c)
+	mgr := healthstate.Manager(s.state)
+
+	// nothing happens before seeding
+	c.Assert(mgr.Ensure(), check.IsNil)
+	s.state.Lock()
+	c.Check(s.state.Changes(), check.HasLen, 0)
+	s.state.Set("seeded", true)
+	s.state.Unlock()
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+
+	s.state.Lock()
+	chgs := s.state.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	chg := chgs[0]
+	c.Check(chg.Kind(), check.Equals, "check-health")
+	c.Check(chg.Summary(), check.Equals, `Run health checks of snaps "test-snap"`)
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	var hooksup hookstate.HookSetup
+	c.Assert(tasks[0].Get("hook-setup", &hooksup), check.IsNil)
+	c.Check(hooksup.Snap, check.Equals, "test-snap")
+	c.Check(hooksup.Hook, check.Equals, "check-health")
+	c.Check(hooksup.Revision, check.Equals, snap.R(42))
+	s.state.Unlock()
+
+	// no new checks while the previous ones are in progress
+	c.Assert(mgr.Ensure(), check.IsNil)
+	s.state.Lock()
+	c.Check(s.state.Changes(), check.HasLen, 1)
+	tasks[0].SetStatus(state.DoneStatus)
+	s.state.Unlock()
+
+	// nor when the health was checked recently
+	now := time.Now()
+	s.AddCleanup(healthstate.MockTimeNow(func() time.Time { return now }))
+	s.state.Lock()
+	s.state.Set("health", map[string]*healthstate.HealthState{
+		"test-snap": {Revision: snap.R(42), Timestamp: now.Add(-time.Hour), Status: healthstate.OkayStatus},
+	})
+	s.state.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	s.state.Lock()
+	c.Check(s.state.Changes(), check.HasLen, 1)
+	s.state.Unlock()
+
+	// but once the check interval elapsed
+	s.AddCleanup(healthstate.MockCheckInterval(30 * time.Minute))
+	c.Assert(mgr.Ensure(), check.IsNil)
+	s.state.Lock()
+	c.Check(s.state.Changes(), check.HasLen, 2)
+	s.state.Unlock()
+}
+
+func (s *healthSuite) TestManagerEnsureNoHook(c *check.C) {
+	mgr := healthstate.Manager(s.state)
+	s.state.Lock()
+	s.state.Set("seeded", true)
+	s.state.Unlock()
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), check.HasLen, 0)
+}
+
+func (s *healthSuite) TestAutoRefreshHeldByHealth(c *check.C) {
+	now := time.Now()
+	s.AddCleanup(healthstate.MockTimeNow(func() time.Time { return now }))
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// no health
+	c.Check(healthstate.AutoRefreshHeldByHealth(s.state, "test-snap", snap.R(42)), check.Equals, false)
+
+	for _, t := range []struct {
+		rev    snap.Revision
+		status healthstate.HealthStatus
+		ago    time.Duration
+		held   bool
+	}{
+		{snap.R(42), healthstate.OkayStatus, time.Hour, false},
+		{snap.R(42), healthstate.WaitingStatus, time.Hour, false},
+		{snap.R(42), healthstate.ErrorStatus, time.Hour, true},
+		{snap.R(42), healthstate.BlockedStatus, time.Hour, true},
+		// the snap is unhealthy since a while
+		{snap.R(42), healthstate.ErrorStatus, 25 * time.Hour, false},
+		// reported by another revision
+		{snap.R(41), healthstate.ErrorStatus, time.Hour, false},
+	} {
+		s.state.Set("health", map[string]*healthstate.HealthState{
+			"test-snap": {Revision: t.rev, Timestamp: now.Add(-t.ago), Status: t.status},
+		})
+		held := healthstate.AutoRefreshHeldByHealth(s.state, "test-snap", snap.R(42))
+		c.Check(held, check.Equals, t.held, check.Commentf("%v %s %v", t.rev, t.status, t.ago))
+	}
+}
+
+func (s *healthSuite) TestAutoRefreshHeldByHealthSinceUnhealthy(c *check.C) {
+	now := time.Now()
+	s.AddCleanup(healthstate.MockTimeNow(func() time.Time { return now }))
+
+	ctx, err := hookstate.NewContext(nil, s.state, &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(42)}, nil, "")
+	c.Assert(err, check.IsNil)
+
+	ctx.Lock()
+	defer ctx.Unlock()
+
+	setHealth := func(status healthstate.HealthStatus, ago time.Duration) {
+		ctx.Set("health", &healthstate.HealthState{Revision: snap.R(42), Timestamp: now.Add(-ago), Status: status})
+		c.Assert(healthstate.SetFromHookContext(ctx), check.IsNil)
+	}
+
+	// went unhealthy a while ago and kept reporting it since
+	setHealth(healthstate.ErrorStatus, 25*time.Hour)
+	setHealth(healthstate.BlockedStatus, 13*time.Hour)
+	setHealth(healthstate.ErrorStatus, time.Hour)
+	health, err := healthstate.Get(s.state, "test-snap")
+	c.Assert(err, check.IsNil)
+	c.Assert(health.UnhealthySince, check.NotNil)
+	c.Check(health.UnhealthySince.Equal(now.Add(-25*time.Hour)), check.Equals, true)
+	c.Check(healthstate.AutoRefreshHeldByHealth(s.state, "test-snap", snap.R(42)), check.Equals, false)
+
+	// recovered and went unhealthy again
+	setHealth(healthstate.OkayStatus, 30*time.Minute)
+	health, err = healthstate.Get(s.state, "test-snap")
+	c.Assert(err, check.IsNil)
+	c.Check(health.UnhealthySince, check.IsNil)
+	setHealth(healthstate.ErrorStatus, 10*time.Minute)
+	c.Check(healthstate.AutoRefreshHeldByHealth(s.state, "test-snap", snap.R(42)), check.Equals, true)
+}
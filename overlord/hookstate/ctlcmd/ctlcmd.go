@@ -103,6 +103,17 @@ func (f ForbiddenCommandError) Error() string {
 	return f.Message
 }
 
+// UnsuccessfulError carries a specific exit code to be returned to the
+// client, used by commands such as is-connected that answer a question
+// through their exit code.
+type UnsuccessfulError struct {
+	ExitCode int
+}
+
+func (e UnsuccessfulError) Error() string {
+	return fmt.Sprintf("unsuccessful with exit code: %d", e.ExitCode)
+}
+
 // ForbiddenCommand contains information about an attempt to use a command in a context where it is not allowed.
 type ForbiddenCommand struct {
 	Uid  uint32
@@ -113,6 +124,25 @@ func (f *ForbiddenCommand) Execute(args []string) error {
 	return &ForbiddenCommandError{Message: fmt.Sprintf("cannot use %q with uid %d, try with sudo", f.Name, f.Uid)}
 }
 
+// forbiddenInHookCommand is used in place of the commands which cannot be
+// used from a given hook.
+type forbiddenInHookCommand struct {
+	Hook string
+	Name string
+}
+
+func (f *forbiddenInHookCommand) Execute(args []string) error {
+	return &ForbiddenCommandError{Message: fmt.Sprintf("cannot use %q from the %s hook", f.Name, f.Hook)}
+}
+
+// installDeviceCommands are the only commands allowed from the install-device
+// hook, which runs before the run system was set up.
+var installDeviceCommands = map[string]bool{
+	"get":   true,
+	"set":   true,
+	"unset": true,
+}
+
 // Run runs the requested command.
 func Run(context *hookstate.Context, args []string, uid uint32) (stdout, stderr []byte, err error) {
 	parser := flags.NewParser(nil, flags.PassDoubleDash|flags.HelpFlag)
@@ -124,7 +154,9 @@ func Run(context *hookstate.Context, args []string, uid uint32) (stdout, stderr
 		var data interface{}
 		// commands listed here will be allowed for regular users
 		// note: commands still need valid context and snaps can only access own config.
-		if uid == 0 || name == "get" || name == "services" || name == "set-health" {
+		if context != nil && context.HookName() == "install-device" && !installDeviceCommands[name] {
+			data = &forbiddenInHookCommand{Hook: context.HookName(), Name: name}
+		} else if uid == 0 || name == "get" || name == "services" || name == "set-health" || name == "is-connected" || name == "system-mode" {
 			cmd := cmdInfo.generator()
 			cmd.setStdout(&stdoutBuffer)
 			cmd.setStderr(&stderrBuffer)
@@ -110,3 +110,28 @@ func (s *ctlcmdSuite) TestHiddenCommand(c *C) {
 	// mock-hidden is not in the help message
 	c.Check(err.Error(), Not(testutil.Contains), "  mock-hidden\n")
 }
+
+func (s *ctlcmdSuite) TestInstallDeviceHookCommands(c *C) {
+	handler := hooktest.NewMockHandler()
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "pc", Revision: snap.R(1), Hook: "install-device"}
+	context, err := hookstate.NewContext(task, st, setup, handler, "")
+	st.Unlock()
+	c.Assert(err, IsNil)
+
+	mockCommand := ctlcmd.AddMockCommand("mock")
+	defer ctlcmd.RemoveCommand("mock")
+
+	_, _, err = ctlcmd.Run(context, []string{"mock"}, 0)
+	c.Check(err, FitsTypeOf, &ctlcmd.ForbiddenCommandError{})
+	c.Check(err, ErrorMatches, `cannot use "mock" from the install-device hook`)
+	c.Check(mockCommand.Args, IsNil)
+
+	_, _, err = ctlcmd.Run(context, []string{"restart", "pc.svc"}, 0)
+	c.Check(err, ErrorMatches, `cannot use "restart" from the install-device hook`)
+
+	_, _, err = ctlcmd.Run(context, []string{"set", "secure-element.provisioned=true"}, 0)
+	c.Check(err, IsNil)
+}
@@ -88,3 +88,9 @@ func (c *MockCommand) Execute(args []string) error {
 
 	return nil
 }
+
+func MockBootSystemMode(f func() (string, error)) (restore func()) {
+	old := bootSystemMode
+	bootSystemMode = f
+	return func() { bootSystemMode = old }
+}
@@ -80,6 +80,10 @@ by explicitly requesting that via the --plug and --slot command line options:
     $ snapctl get :myplug --slot usb-vendor
 
 This requests the "usb-vendor" setting from the slot that is connected to "myplug".
+
+Elements of list attributes may be retrieved by their index:
+
+    $ snapctl get :myplug content.paths[0].target
 `)
 
 func init() {
@@ -317,18 +321,17 @@ func (c *getCommand) getInterfaceSetting(context *hookstate.Context, plugOrSlot
 	}
 
 	return c.printValues(func(key string) (interface{}, bool, error) {
-		subkeys, err := config.ParseKey(key)
+		path, err := parseAttributePath(key)
 		if err != nil {
 			return nil, false, err
 		}
 
-		var value interface{}
-		err = getAttribute(context.InstanceName(), subkeys, 0, staticAttrs, &value)
+		value, err := getAttribute(context.InstanceName(), path, staticAttrs)
 		if err == nil {
 			return value, true, nil
 		}
 		if isNoAttribute(err) {
-			err = getAttribute(context.InstanceName(), subkeys, 0, dynamicAttrs, &value)
+			value, err = getAttribute(context.InstanceName(), path, dynamicAttrs)
 			if err == nil {
 				return value, true, nil
 			}
@@ -284,6 +284,10 @@ func (s *getAttrSuite) SetUpTest(c *C) {
 		"aattr":   "foo",
 		"baz":     []string{"a", "b"},
 		"mapattr": map[string]interface{}{"mapattr1": "mapval1", "mapattr2": "mapval2"},
+		"listattr": []interface{}{
+			map[string]interface{}{"name": "first"},
+			"second",
+		},
 	}
 	dynamicPlugAttrs := map[string]interface{}{
 		"dyn-plug-attr": "c",
@@ -348,6 +352,27 @@ var getPlugAttributesTests = []struct {
 }, {
 	args:   "get -d :aplug baz",
 	stdout: "{\n\t\"baz\": [\n\t\t\"a\",\n\t\t\"b\"\n\t]\n}\n",
+}, {
+	args:   "get :aplug baz[1]",
+	stdout: "b\n",
+}, {
+	args:   "get :aplug listattr[0].name",
+	stdout: "first\n",
+}, {
+	args:   "get -d :aplug listattr[1]",
+	stdout: "{\n\t\"listattr[1]\": \"second\"\n}\n",
+}, {
+	args:  "get :aplug baz[2]",
+	error: `no "baz\[2\]" attribute`,
+}, {
+	args:  "get :aplug aattr[0]",
+	error: `snap "test-snap" attribute "aattr" is not a list`,
+}, {
+	args:  "get :aplug listattr[1].name",
+	error: `snap "test-snap" attribute "listattr\[1\]" is not a map`,
+}, {
+	args:  "get :aplug baz[x]",
+	error: `invalid attribute path: "baz\[x\]"`,
 }, {
 	args:  "get :aplug",
 	error: `.*get which attribute.*`,
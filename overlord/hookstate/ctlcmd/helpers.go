@@ -23,12 +23,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/jsonutil"
 	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/servicestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -184,49 +187,190 @@ func isNoAttribute(err error) bool {
 	return ok
 }
 
-func jsonRaw(v interface{}) *json.RawMessage {
-	data, err := json.Marshal(v)
-	if err != nil {
-		panic(fmt.Errorf("internal error: cannot marshal attributes: %v", err))
+// AttributeTypeError indicates that an interface attribute does not
+// have the type required to access it, for example when indexing an
+// attribute that is not a list.
+type AttributeTypeError struct {
+	Snap      string
+	Attribute string
+	// Expected is either "map" or "list".
+	Expected string
+}
+
+func (e *AttributeTypeError) Error() string {
+	return fmt.Sprintf("snap %q attribute %q is not a %s", e.Snap, e.Attribute, e.Expected)
+}
+
+// attributePathElem is an element of an attribute path, either the key
+// of a map or the index of a list.
+type attributePathElem struct {
+	key   string
+	index int
+	// isIndex is set for list indexes
+	isIndex bool
+}
+
+type attributePath []attributePathElem
+
+func (path attributePath) String() string {
+	var buf bytes.Buffer
+	for _, elem := range path {
+		if elem.isIndex {
+			fmt.Fprintf(&buf, "[%d]", elem.index)
+			continue
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('.')
+		}
+		buf.WriteString(elem.key)
+	}
+	return buf.String()
+}
+
+var validAttributePathComponent = regexp.MustCompile(`^([^\[\]]+)((?:\[[0-9]+\])*)$`)
+
+// parseAttributePath parses an attribute path. The path is a dotted
+// path through nested maps, where list elements can be addressed by
+// their index. For example "a.b[1].c" describes the value
+// of c in {a: {b: [..., {c: value}]}}.
+func parseAttributePath(key string) (attributePath, error) {
+	var path attributePath
+	for _, comp := range strings.Split(key, ".") {
+		m := validAttributePathComponent.FindStringSubmatch(comp)
+		if m == nil {
+			return nil, fmt.Errorf("invalid attribute path: %q", key)
+		}
+		if _, err := config.ParseKey(m[1]); err != nil {
+			return nil, err
+		}
+		path = append(path, attributePathElem{key: m[1]})
+		if m[2] == "" {
+			continue
+		}
+		for _, idx := range strings.Split(strings.Trim(m[2], "[]"), "][") {
+			n, err := strconv.Atoi(idx)
+			if err != nil {
+				return nil, fmt.Errorf("invalid attribute path: %q", key)
+			}
+			path = append(path, attributePathElem{index: n, isIndex: true})
+		}
 	}
-	raw := json.RawMessage(data)
-	return &raw
+	return path, nil
 }
 
-// getAttribute unmarshals into result the value of the provided key from attributes map.
-// If the key does not exist, an error of type *NoAttributeError is returned.
-// The provided key may be formed as a dotted key path through nested maps.
-// For example, the "a.b.c" key describes the {a: {b: {c: value}}} map.
-func getAttribute(snapName string, subkeys []string, pos int, attrs map[string]interface{}, result interface{}) error {
-	if pos >= len(subkeys) {
-		return fmt.Errorf("internal error: invalid subkeys index %d for subkeys %q", pos, subkeys)
+// attributeValue returns the plain representation of an attribute value,
+// decoding the JSON form it may be in.
+func attributeValue(value interface{}) (interface{}, error) {
+	var raw []byte
+	switch v := value.(type) {
+	case *json.RawMessage:
+		raw = *v
+	case json.RawMessage:
+		raw = v
+	case map[string]interface{}, []interface{}, string, bool, json.Number, nil:
+		return v, nil
+	default:
+		var err error
+		if raw, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
 	}
-	value, ok := attrs[subkeys[pos]]
-	if !ok {
-		return &NoAttributeError{Attribute: strings.Join(subkeys[:pos+1], ".")}
+	var decoded interface{}
+	if err := jsonutil.DecodeWithNumber(bytes.NewReader(raw), &decoded); err != nil {
+		return nil, err
 	}
+	return decoded, nil
+}
 
-	if pos+1 == len(subkeys) {
-		raw, ok := value.(*json.RawMessage)
-		if !ok {
-			raw = jsonRaw(value)
+// getAttribute returns the value of the attribute described by path.
+// If the attribute does not exist, an error of type *NoAttributeError is
+// returned. If the path traverses a value that is not a map or a list
+// as required, an error of type *AttributeTypeError is returned.
+func getAttribute(snapName string, path attributePath, attrs map[string]interface{}) (interface{}, error) {
+	var value interface{} = attrs
+	for i, elem := range path {
+		var err error
+		if value, err = attributeValue(value); err != nil {
+			return nil, err
 		}
-		if err := jsonutil.DecodeWithNumber(bytes.NewReader(*raw), &result); err != nil {
-			key := strings.Join(subkeys, ".")
-			return fmt.Errorf("internal error: cannot unmarshal snap %s attribute %q into %T: %s, json: %s", snapName, key, result, err, *raw)
+		if elem.isIndex {
+			l, ok := value.([]interface{})
+			if !ok {
+				return nil, &AttributeTypeError{Snap: snapName, Attribute: path[:i].String(), Expected: "list"}
+			}
+			if elem.index >= len(l) {
+				return nil, &NoAttributeError{Attribute: path.String()}
+			}
+			value = l[elem.index]
+		} else {
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, &AttributeTypeError{Snap: snapName, Attribute: path[:i].String(), Expected: "map"}
+			}
+			v, ok := m[elem.key]
+			if !ok {
+				return nil, &NoAttributeError{Attribute: path.String()}
+			}
+			value = v
 		}
-		return nil
 	}
+	return attributeValue(value)
+}
+
+// setAttribute sets the attribute described by path to value in attrs.
+// Missing maps along the path are created, list elements can be
+// replaced or appended by using the index one past the end of the list.
+func setAttribute(snapName string, path attributePath, attrs map[string]interface{}, value interface{}) error {
+	if len(path) == 0 || path[0].isIndex {
+		return fmt.Errorf("internal error: invalid attribute path %q", path)
+	}
+	_, err := setAttributeAt(snapName, path, 0, attrs, value)
+	return err
+}
 
-	attrsm, ok := value.(map[string]interface{})
-	if !ok {
-		raw, ok := value.(*json.RawMessage)
+func setAttributeAt(snapName string, path attributePath, pos int, container interface{}, value interface{}) (interface{}, error) {
+	if pos == len(path) {
+		return value, nil
+	}
+	container, err := attributeValue(container)
+	if err != nil {
+		return nil, err
+	}
+	elem := path[pos]
+	if elem.isIndex {
+		l, ok := container.([]interface{})
 		if !ok {
-			raw = jsonRaw(value)
+			return nil, &AttributeTypeError{Snap: snapName, Attribute: path[:pos].String(), Expected: "list"}
+		}
+		if elem.index > len(l) {
+			return nil, fmt.Errorf("index %d of attribute %q is out of range", elem.index, path[:pos].String())
 		}
-		if err := jsonutil.DecodeWithNumber(bytes.NewReader(*raw), &attrsm); err != nil {
-			return fmt.Errorf("snap %q attribute %q is not a map", snapName, strings.Join(subkeys[:pos+1], "."))
+		var existing interface{}
+		if elem.index < len(l) {
+			existing = l[elem.index]
 		}
+		v, err := setAttributeAt(snapName, path, pos+1, existing, value)
+		if err != nil {
+			return nil, err
+		}
+		if elem.index == len(l) {
+			return append(l, v), nil
+		}
+		l[elem.index] = v
+		return l, nil
+	}
+
+	if container == nil {
+		container = make(map[string]interface{})
+	}
+	m, ok := container.(map[string]interface{})
+	if !ok {
+		return nil, &AttributeTypeError{Snap: snapName, Attribute: path[:pos].String(), Expected: "map"}
+	}
+	v, err := setAttributeAt(snapName, path, pos+1, m[elem.key], value)
+	if err != nil {
+		return nil, err
 	}
-	return getAttribute(snapName, subkeys, pos+1, attrsm, result)
+	m[elem.key] = v
+	return m, nil
 }
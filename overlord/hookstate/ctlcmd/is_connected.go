@@ -0,0 +1,103 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+)
+
+var (
+	shortIsConnectedHelp = i18n.G(`Return success if the given plug or slot is connected`)
+	longIsConnectedHelp  = i18n.G(`
+The is-connected command returns success if the given plug or slot of the
+calling snap is connected, and failure otherwise.
+
+$ snapctl is-connected plug
+$ echo $?
+1
+
+Snaps can only query their own plugs and slots - snap name is implicit and
+implied by the snapctl execution context.
+`)
+)
+
+func init() {
+	addCommand("is-connected", shortIsConnectedHelp, longIsConnectedHelp, func() command { return &isConnectedCommand{} })
+}
+
+type isConnectedCommand struct {
+	baseCommand
+
+	Positional struct {
+		PlugOrSlotSpec string `positional-arg-name:"<plug|slot>" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func (c *isConnectedCommand) Execute(args []string) error {
+	plugOrSlot := c.Positional.PlugOrSlotSpec
+
+	ctx := c.context()
+	if ctx == nil {
+		return fmt.Errorf(i18n.G("cannot check connection status without a context"))
+	}
+
+	snapName := ctx.InstanceName()
+	st := ctx.State()
+	st.Lock()
+	defer st.Unlock()
+
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if err != nil {
+		return fmt.Errorf("cannot get snap info: %v", err)
+	}
+	isPlug := info.Plugs[plugOrSlot] != nil
+	isSlot := info.Slots[plugOrSlot] != nil
+	if !isPlug && !isSlot {
+		return fmt.Errorf("snap %q has no plug or slot named %q", snapName, plugOrSlot)
+	}
+
+	conns, err := ifacestate.ConnectionStates(st)
+	if err != nil {
+		return fmt.Errorf("internal error: cannot get connections: %v", err)
+	}
+
+	for refStr, connState := range conns {
+		if connState.Undesired || connState.HotplugGone {
+			continue
+		}
+		connRef, err := interfaces.ParseConnRef(refStr)
+		if err != nil {
+			return fmt.Errorf("internal error: %v", err)
+		}
+		if isPlug && connRef.PlugRef.Snap == snapName && connRef.PlugRef.Name == plugOrSlot {
+			return nil
+		}
+		if isSlot && connRef.SlotRef.Snap == snapName && connRef.SlotRef.Name == plugOrSlot {
+			return nil
+		}
+	}
+
+	return &UnsuccessfulError{ExitCode: 1}
+}
@@ -0,0 +1,155 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type isConnectedSuite struct {
+	testutil.BaseTest
+	st          *state.State
+	mockHandler *hooktest.MockHandler
+}
+
+var _ = Suite(&isConnectedSuite{})
+
+const isConnectedTestSnapYaml = `name: snap1
+plugs:
+  plug1:
+    interface: x11
+  plug2:
+    interface: x11
+slots:
+  slot1:
+    interface: x11
+  slot2:
+    interface: x11
+`
+
+const isConnectedTestSnap2Yaml = `name: snap2
+plugs:
+  plug1:
+    interface: x11
+slots:
+  slot1:
+    interface: x11
+`
+
+func (s *isConnectedSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+	s.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+
+	s.mockHandler = hooktest.NewMockHandler()
+	s.st = state.New(nil)
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	for _, yaml := range []string{isConnectedTestSnapYaml, isConnectedTestSnap2Yaml} {
+		info := snaptest.MockSnapCurrent(c, yaml, &snap.SideInfo{Revision: snap.R(1)})
+		snapstate.Set(s.st, info.InstanceName(), &snapstate.SnapState{
+			Active:   true,
+			Sequence: []*snap.SideInfo{{RealName: info.SnapName(), Revision: info.Revision}},
+			Current:  info.Revision,
+		})
+	}
+
+	s.st.Set("conns", map[string]interface{}{
+		"snap1:plug1 snap2:slot1": map[string]interface{}{
+			"interface": "x11",
+		},
+		"snap2:plug1 snap1:slot1": map[string]interface{}{
+			"interface": "x11",
+		},
+		"snap1:plug2 snap2:slot1": map[string]interface{}{
+			"interface": "x11",
+			"undesired": true,
+		},
+		"snap2:plug1 snap1:slot2": map[string]interface{}{
+			"interface":    "x11",
+			"hotplug-gone": true,
+		},
+	})
+}
+
+func (s *isConnectedSuite) mockContext(c *C) *hookstate.Context {
+	s.st.Lock()
+	defer s.st.Unlock()
+	task := s.st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "snap1", Revision: snap.R(1), Hook: "test-hook"}
+	ctx, err := hookstate.NewContext(task, s.st, setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+	return ctx
+}
+
+func (s *isConnectedSuite) TestIsConnected(c *C) {
+	for _, t := range []struct {
+		name      string
+		connected bool
+	}{
+		{"plug1", true},
+		{"slot1", true},
+		// undesired
+		{"plug2", false},
+		// hotplug gone
+		{"slot2", false},
+	} {
+		stdout, stderr, err := ctlcmd.Run(s.mockContext(c), []string{"is-connected", t.name}, 0)
+		if t.connected {
+			c.Check(err, IsNil, Commentf("%s", t.name))
+		} else {
+			c.Check(err, DeepEquals, &ctlcmd.UnsuccessfulError{ExitCode: 1}, Commentf("%s", t.name))
+		}
+		c.Check(string(stdout), Equals, "")
+		c.Check(string(stderr), Equals, "")
+	}
+}
+
+func (s *isConnectedSuite) TestIsConnectedAsRegularUser(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext(c), []string{"is-connected", "plug1"}, 1000)
+	c.Check(err, IsNil)
+}
+
+func (s *isConnectedSuite) TestNoSuchPlugOrSlot(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext(c), []string{"is-connected", "foo"}, 0)
+	c.Check(err, ErrorMatches, `snap "snap1" has no plug or slot named "foo"`)
+}
+
+func (s *isConnectedSuite) TestNoContext(c *C) {
+	_, _, err := ctlcmd.Run(nil, []string{"is-connected", "plug1"}, 0)
+	c.Check(err, ErrorMatches, "cannot check connection status without a context")
+}
+
+func (s *isConnectedSuite) TestMissingArg(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext(c), []string{"is-connected"}, 0)
+	c.Check(err, ErrorMatches, "the required argument `<plug|slot>` was not provided")
+}
@@ -0,0 +1,80 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/snapstate"
+)
+
+var (
+	shortRefreshHelp = i18n.G("Hold or proceed with the pending auto-refresh of the snap")
+	longRefreshHelp  = i18n.G(`
+The refresh command is called from within the gate-auto-refresh hook of a
+snap to decide whether its pending auto-refresh goes ahead.
+
+With --hold the auto-refresh is postponed, the hook will be run again at a
+later auto-refresh. A snap cannot hold its own auto-refresh indefinitely,
+once the maximum hold time elapsed the refresh proceeds regardless.
+
+With --proceed the auto-refresh goes ahead.
+`)
+)
+
+func init() {
+	addCommand("refresh", shortRefreshHelp, longRefreshHelp, func() command { return &refreshCommand{} })
+}
+
+type refreshCommand struct {
+	baseCommand
+
+	Hold    bool `long:"hold" description:"Postpone the pending auto-refresh of the snap"`
+	Proceed bool `long:"proceed" description:"Let the pending auto-refresh of the snap go ahead"`
+}
+
+func (c *refreshCommand) Execute([]string) error {
+	if c.Hold == c.Proceed {
+		return fmt.Errorf(i18n.G("exactly one of --hold or --proceed must be given"))
+	}
+
+	ctx := c.context()
+	if ctx == nil {
+		return fmt.Errorf(i18n.G("cannot %s without a context"), "refresh")
+	}
+	if ctx.IsEphemeral() || ctx.HookName() != "gate-auto-refresh" {
+		return fmt.Errorf(i18n.G("can only be used from the gate-auto-refresh hook"))
+	}
+
+	ctx.Lock()
+	defer ctx.Unlock()
+
+	if c.Proceed {
+		return snapstate.GateAutoRefreshProceed(ctx.State(), ctx.InstanceName())
+	}
+	until, err := snapstate.GateAutoRefreshHold(ctx.State(), ctx.InstanceName())
+	if err != nil {
+		return err
+	}
+	c.printf(i18n.G("Auto-refresh held until %s\n"), until.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,107 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type refreshSuite struct {
+	testutil.BaseTest
+	state       *state.State
+	mockHandler *hooktest.MockHandler
+}
+
+var _ = check.Suite(&refreshSuite{})
+
+func (s *refreshSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+	s.mockHandler = hooktest.NewMockHandler()
+
+	s.state = state.New(nil)
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "test-snap", Revision: snap.R(42)}},
+		Current:  snap.R(42),
+		SnapType: "app",
+	})
+}
+
+func (s *refreshSuite) mockContext(c *check.C, hook string) *hookstate.Context {
+	s.state.Lock()
+	defer s.state.Unlock()
+	task := s.state.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(42), Hook: hook}
+	ctx, err := hookstate.NewContext(task, s.state, setup, s.mockHandler, "")
+	c.Assert(err, check.IsNil)
+	return ctx
+}
+
+func (s *refreshSuite) TestBadArgs(c *check.C) {
+	for _, args := range [][]string{
+		{"refresh"},
+		{"refresh", "--hold", "--proceed"},
+	} {
+		_, _, err := ctlcmd.Run(s.mockContext(c, "gate-auto-refresh"), args, 0)
+		c.Check(err, check.ErrorMatches, "exactly one of --hold or --proceed must be given")
+	}
+
+	_, _, err := ctlcmd.Run(nil, []string{"refresh", "--hold"}, 0)
+	c.Check(err, check.ErrorMatches, "cannot refresh without a context")
+
+	_, _, err = ctlcmd.Run(s.mockContext(c, "configure"), []string{"refresh", "--hold"}, 0)
+	c.Check(err, check.ErrorMatches, "can only be used from the gate-auto-refresh hook")
+}
+
+func (s *refreshSuite) TestHoldAndProceed(c *check.C) {
+	ctx := s.mockContext(c, "gate-auto-refresh")
+
+	stdout, stderr, err := ctlcmd.Run(ctx, []string{"refresh", "--hold"}, 0)
+	c.Assert(err, check.IsNil)
+	c.Check(string(stdout), check.Matches, `Auto-refresh held until .*\n`)
+	c.Check(string(stderr), check.Equals, "")
+
+	s.state.Lock()
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "test-snap", &snapst), check.IsNil)
+	s.state.Unlock()
+	c.Assert(snapst.GatedRefreshHold, check.NotNil)
+	c.Check(snapst.GatedRefreshHold.Active(time.Now()), check.Equals, true)
+
+	_, _, err = ctlcmd.Run(ctx, []string{"refresh", "--proceed"}, 0)
+	c.Assert(err, check.IsNil)
+
+	s.state.Lock()
+	c.Assert(snapstate.Get(s.state, "test-snap", &snapst), check.IsNil)
+	s.state.Unlock()
+	c.Check(snapst.GatedRefreshHold, check.IsNil)
+}
@@ -20,20 +20,20 @@
 package ctlcmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"strings"
 
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/jsonutil"
 	"github.com/snapcore/snapd/overlord/configstate"
-	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 )
 
 type setCommand struct {
 	baseCommand
 
+	Typed bool `short:"t" description:"parse the value strictly as JSON document"`
+
 	Positional struct {
 		PlugOrSlotSpec string   `positional-arg-name:":<plug|slot>"`
 		ConfValues     []string `positional-arg-name:"key=value"`
@@ -60,6 +60,17 @@ Plug and slot attributes may be set in the respective prepare and connect hooks
 naming the respective plug or slot:
 
     $ snapctl set :myplug path=/dev/ttyS0
+
+Nested attributes and elements of list attributes are addressed with a path,
+where list elements are referred to by their index. An element may be added
+to the end of a list by using the index one past its last element:
+
+    $ snapctl set :myplug content.paths[1]='{"source": "$SNAP_DATA/b"}'
+
+Values that are not valid JSON are set as strings, unless -t is used, in
+which case an error is returned instead:
+
+    $ snapctl set -t :myplug ports='[8080, 8081]'
 `)
 
 func init() {
@@ -111,10 +122,9 @@ func (s *setCommand) setConfigSetting(context *hookstate.Context) error {
 			return fmt.Errorf(i18n.G("invalid parameter: %q (want key=value)"), patchValue)
 		}
 		key := parts[0]
-		var value interface{}
-		if err := jsonutil.DecodeWithNumber(strings.NewReader(parts[1]), &value); err != nil {
-			// Not valid JSON-- just save the string as-is.
-			value = parts[1]
+		value, err := s.parseValue(key, parts[1])
+		if err != nil {
+			return err
 		}
 
 		tr.Set(s.context().InstanceName(), key, value)
@@ -123,26 +133,34 @@ func (s *setCommand) setConfigSetting(context *hookstate.Context) error {
 	return nil
 }
 
-func setInterfaceAttribute(context *hookstate.Context, staticAttrs map[string]interface{}, dynamicAttrs map[string]interface{}, key string, value interface{}) error {
-	data, err := json.Marshal(value)
-	if err != nil {
-		return fmt.Errorf("cannot marshal snap %q option %q: %s", context.InstanceName(), key, err)
+// parseValue parses the value of a key=value argument. Values that are
+// not valid JSON are used as plain strings, unless strict typing was
+// requested.
+func (s *setCommand) parseValue(key, value string) (interface{}, error) {
+	var v interface{}
+	if err := jsonutil.DecodeWithNumber(strings.NewReader(value), &v); err != nil {
+		if s.Typed {
+			return nil, fmt.Errorf(i18n.G("cannot parse value of %q as JSON: %v"), key, err)
+		}
+		// Not valid JSON-- just save the string as-is.
+		return value, nil
 	}
-	raw := json.RawMessage(data)
+	return v, nil
+}
 
-	subkeys, err := config.ParseKey(key)
+func setInterfaceAttribute(context *hookstate.Context, staticAttrs map[string]interface{}, dynamicAttrs map[string]interface{}, key string, value interface{}) error {
+	path, err := parseAttributePath(key)
 	if err != nil {
 		return err
 	}
 
-	// We're called from setInterfaceSetting, subkeys is derived from key
+	// We're called from setInterfaceSetting, path is derived from key
 	// part of key=value argument and is guaranteed to be non-empty at this
 	// point.
-	if len(subkeys) == 0 {
-		return fmt.Errorf("internal error: unexpected empty subkeys for key %q", key)
+	if len(path) == 0 {
+		return fmt.Errorf("internal error: unexpected empty path for key %q", key)
 	}
-	var existing interface{}
-	err = getAttribute(context.InstanceName(), subkeys[:1], 0, staticAttrs, &existing)
+	_, err = getAttribute(context.InstanceName(), path[:1], staticAttrs)
 	if err == nil {
 		return fmt.Errorf(i18n.G("attribute %q cannot be overwritten"), key)
 	}
@@ -151,8 +169,7 @@ func setInterfaceAttribute(context *hookstate.Context, staticAttrs map[string]in
 		return err
 	}
 
-	_, err = config.PatchConfig(context.InstanceName(), subkeys, 0, dynamicAttrs, &raw)
-	return err
+	return setAttribute(context.InstanceName(), path, dynamicAttrs, value)
 }
 
 func (s *setCommand) setInterfaceSetting(context *hookstate.Context, plugOrSlot string) error {
@@ -191,6 +208,9 @@ func (s *setCommand) setInterfaceSetting(context *hookstate.Context, plugOrSlot
 	if err = attrsTask.Get(dynKey, &dynamicAttrs); err != nil {
 		return fmt.Errorf(i18n.G("internal error: cannot get %s from appropriate task, %s"), which, err)
 	}
+	if dynamicAttrs == nil {
+		dynamicAttrs = make(map[string]interface{})
+	}
 
 	for _, attrValue := range s.Positional.ConfValues {
 		parts := strings.SplitN(attrValue, "=", 2)
@@ -198,10 +218,9 @@ func (s *setCommand) setInterfaceSetting(context *hookstate.Context, plugOrSlot
 			return fmt.Errorf(i18n.G("invalid parameter: %q (want key=value)"), attrValue)
 		}
 
-		var value interface{}
-		if err := jsonutil.DecodeWithNumber(strings.NewReader(parts[1]), &value); err != nil {
-			// Not valid JSON, save the string as-is
-			value = parts[1]
+		value, err := s.parseValue(parts[0], parts[1])
+		if err != nil {
+			return err
 		}
 		err = setInterfaceAttribute(context, staticAttrs, dynamicAttrs, parts[0], value)
 		if err != nil {
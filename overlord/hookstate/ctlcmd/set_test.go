@@ -203,6 +203,23 @@ func (s *setSuite) TestSetNumbers(c *C) {
 	c.Check(value, Equals, json.Number("123456.7890"))
 }
 
+func (s *setSuite) TestSetTyped(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"set", "-t", "foo=bar"}, 0)
+	c.Check(err, ErrorMatches, `cannot parse value of "foo" as JSON: .*`)
+
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"set", "-t", `foo="bar"`}, 0)
+	c.Check(err, IsNil)
+
+	s.mockContext.Lock()
+	defer s.mockContext.Unlock()
+	c.Check(s.mockContext.Done(), IsNil)
+
+	var value interface{}
+	tr := config.NewTransaction(s.mockContext.State())
+	c.Check(tr.Get("test-snap", "foo", &value), IsNil)
+	c.Check(value, Equals, "bar")
+}
+
 func (s *setSuite) TestCommandSavesDeltasOnly(c *C) {
 	// Setup an initial configuration
 	s.mockContext.State().Lock()
@@ -327,6 +344,71 @@ func (s *setAttrSuite) TestSetPlugAttributesSupportsDottedSyntax(c *C) {
 	c.Check(dynattrs["my"], DeepEquals, map[string]interface{}{"attr1": "foo", "attr2": "bar"})
 }
 
+func (s *setAttrSuite) TestSetPlugAttributesListElements(c *C) {
+	_, _, err := ctlcmd.Run(s.mockPlugHookContext, []string{"set", ":aplug", `content.paths=[{"source": "a"}]`}, 0)
+	c.Assert(err, IsNil)
+	// replace a nested value of an existing element and append a new one
+	_, _, err = ctlcmd.Run(s.mockPlugHookContext, []string{"set", ":aplug", "content.paths[0].target=x", `content.paths[1]={"source": "b"}`}, 0)
+	c.Assert(err, IsNil)
+
+	stdout, _, err := ctlcmd.Run(s.mockPlugHookContext, []string{"get", ":aplug", "content.paths[1].source"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "b\n")
+
+	attrsTask, err := ctlcmd.AttributesTask(s.mockPlugHookContext)
+	c.Assert(err, IsNil)
+	st := s.mockPlugHookContext.State()
+	st.Lock()
+	defer st.Unlock()
+	dynattrs := make(map[string]interface{})
+	err = attrsTask.Get("plug-dynamic", &dynattrs)
+	c.Assert(err, IsNil)
+	c.Check(dynattrs["content"], DeepEquals, map[string]interface{}{
+		"paths": []interface{}{
+			map[string]interface{}{"source": "a", "target": "x"},
+			map[string]interface{}{"source": "b"},
+		},
+	})
+}
+
+func (s *setAttrSuite) TestSetPlugAttributesTypeErrors(c *C) {
+	_, _, err := ctlcmd.Run(s.mockPlugHookContext, []string{"set", ":aplug", "foo=bar", `list=["a"]`}, 0)
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		arg, err string
+	}{
+		{"foo.baz=1", `cannot set attribute: snap "test-snap" attribute "foo" is not a map`},
+		{"foo[0]=1", `cannot set attribute: snap "test-snap" attribute "foo" is not a list`},
+		{"list.x=1", `cannot set attribute: snap "test-snap" attribute "list" is not a map`},
+		{"list[0].x=1", `cannot set attribute: snap "test-snap" attribute "list\[0\]" is not a map`},
+		{"list[2]=1", `cannot set attribute: index 2 of attribute "list" is out of range`},
+		{"lorem[0]=1", `cannot set attribute: attribute "lorem\[0\]" cannot be overwritten`},
+		{"list[a]=1", `cannot set attribute: invalid attribute path: "list\[a\]"`},
+	} {
+		_, _, err := ctlcmd.Run(s.mockPlugHookContext, []string{"set", ":aplug", t.arg}, 0)
+		c.Check(err, ErrorMatches, t.err, Commentf(t.arg))
+	}
+}
+
+func (s *setAttrSuite) TestSetPlugAttributesTyped(c *C) {
+	_, _, err := ctlcmd.Run(s.mockPlugHookContext, []string{"set", "-t", ":aplug", "foo=bar"}, 0)
+	c.Check(err, ErrorMatches, `cannot parse value of "foo" as JSON: .*`)
+
+	_, _, err = ctlcmd.Run(s.mockPlugHookContext, []string{"set", "-t", ":aplug", `foo="bar"`, "enabled=true"}, 0)
+	c.Assert(err, IsNil)
+
+	attrsTask, err := ctlcmd.AttributesTask(s.mockPlugHookContext)
+	c.Assert(err, IsNil)
+	st := s.mockPlugHookContext.State()
+	st.Lock()
+	defer st.Unlock()
+	dynattrs := make(map[string]interface{})
+	err = attrsTask.Get("plug-dynamic", &dynattrs)
+	c.Assert(err, IsNil)
+	c.Check(dynattrs, DeepEquals, map[string]interface{}{"foo": "bar", "enabled": true})
+}
+
 func (s *setAttrSuite) TestPlugOrSlotEmpty(c *C) {
 	stdout, stderr, err := ctlcmd.Run(s.mockPlugHookContext, []string{"set", ":", "foo=bar"}, 0)
 	c.Check(err, ErrorMatches, "plug or slot name not provided")
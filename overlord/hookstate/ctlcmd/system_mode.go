@@ -0,0 +1,86 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	shortSystemModeHelp = i18n.G("Get the current system mode and associated details")
+	longSystemModeHelp  = i18n.G(`
+The system-mode command returns information about the mode the system was
+booted in.
+
+The mode is one of run, recover or install. When the system is being
+installed from its seed the output also carries "factory: true", and once
+the device was reset to its factory state the time the reset completed is
+reported as factory-reset.
+
+$ snapctl system-mode
+system-mode: install
+factory: true
+`)
+)
+
+var bootSystemMode = boot.SystemMode
+
+func init() {
+	addCommand("system-mode", shortSystemModeHelp, longSystemModeHelp, func() command { return &systemModeCommand{} })
+}
+
+type systemModeCommand struct {
+	baseCommand
+}
+
+func (c *systemModeCommand) Execute(args []string) error {
+	ctx := c.context()
+	if ctx == nil {
+		return fmt.Errorf(i18n.G("cannot query the system mode without a context"))
+	}
+
+	mode, err := bootSystemMode()
+	if err != nil {
+		return fmt.Errorf("cannot determine the system mode: %v", err)
+	}
+
+	st := ctx.State()
+	st.Lock()
+	reset, err := devicestate.FactoryResetStatus(st)
+	st.Unlock()
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+
+	c.printf("system-mode: %s\n", mode)
+	if mode == boot.ModeInstall {
+		c.printf("factory: true\n")
+	}
+	if reset != nil && reset.DoneTime != nil {
+		c.printf("factory-reset: %s\n", reset.DoneTime.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type systemModeSuite struct {
+	testutil.BaseTest
+	st          *state.State
+	mockHandler *hooktest.MockHandler
+}
+
+var _ = Suite(&systemModeSuite{})
+
+func (s *systemModeSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.mockHandler = hooktest.NewMockHandler()
+	s.st = state.New(nil)
+}
+
+func (s *systemModeSuite) mockContext(c *C) *hookstate.Context {
+	s.st.Lock()
+	defer s.st.Unlock()
+	task := s.st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "test-hook"}
+	ctx, err := hookstate.NewContext(task, s.st, setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+	return ctx
+}
+
+func (s *systemModeSuite) mockMode(mode string) {
+	s.AddCleanup(ctlcmd.MockBootSystemMode(func() (string, error) {
+		return mode, nil
+	}))
+}
+
+func (s *systemModeSuite) TestRunMode(c *C) {
+	s.mockMode("run")
+
+	stdout, stderr, err := ctlcmd.Run(s.mockContext(c), []string{"system-mode"}, 1000)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "system-mode: run\n")
+	c.Check(string(stderr), Equals, "")
+}
+
+func (s *systemModeSuite) TestInstallModeIsFactory(c *C) {
+	s.mockMode("install")
+
+	stdout, _, err := ctlcmd.Run(s.mockContext(c), []string{"system-mode"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "system-mode: install\nfactory: true\n")
+}
+
+func (s *systemModeSuite) TestFactoryReset(c *C) {
+	s.mockMode("run")
+
+	done := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	s.st.Lock()
+	s.st.Set("factory-reset", &devicestate.FactoryResetState{
+		RequestTime: done.Add(-time.Hour),
+		DoneTime:    &done,
+	})
+	s.st.Unlock()
+
+	stdout, _, err := ctlcmd.Run(s.mockContext(c), []string{"system-mode"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "system-mode: run\nfactory-reset: 2019-10-01T12:00:00Z\n")
+}
+
+func (s *systemModeSuite) TestError(c *C) {
+	s.AddCleanup(ctlcmd.MockBootSystemMode(func() (string, error) {
+		return "", errors.New("boom")
+	}))
+
+	_, _, err := ctlcmd.Run(s.mockContext(c), []string{"system-mode"}, 0)
+	c.Check(err, ErrorMatches, "cannot determine the system mode: boom")
+}
+
+func (s *systemModeSuite) TestNoContext(c *C) {
+	_, _, err := ctlcmd.Run(nil, []string{"system-mode"}, 0)
+	c.Check(err, ErrorMatches, "cannot query the system mode without a context")
+}
@@ -20,6 +20,7 @@ package hookstate
 import (
 	"fmt"
 	"regexp"
+	"time"
 
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -31,6 +32,7 @@ func init() {
 	snapstate.SetupPreRefreshHook = SetupPreRefreshHook
 	snapstate.SetupPostRefreshHook = SetupPostRefreshHook
 	snapstate.SetupRemoveHook = SetupRemoveHook
+	snapstate.SetupGateAutoRefreshHook = SetupGateAutoRefreshHook
 }
 
 func SetupInstallHook(st *state.State, snapName string) *state.Task {
@@ -99,6 +101,24 @@ func SetupRemoveHook(st *state.State, snapName string) *state.Task {
 	return task
 }
 
+// gateAutoRefreshHookTimeout is how long a gate-auto-refresh hook can
+// run before it is killed and the auto-refresh proceeds.
+var gateAutoRefreshHookTimeout = 5 * time.Minute
+
+func SetupGateAutoRefreshHook(st *state.State, snapName string) *state.Task {
+	hooksup := &HookSetup{
+		Snap:     snapName,
+		Hook:     "gate-auto-refresh",
+		Optional: true,
+		// a failing hook does not block the refresh
+		IgnoreError: true,
+		Timeout:     gateAutoRefreshHookTimeout,
+	}
+
+	summary := fmt.Sprintf(i18n.G("Run gate-auto-refresh hook of %q snap if present"), hooksup.Snap)
+	return HookTask(st, summary, hooksup, nil)
+}
+
 func setupHooks(hookMgr *HookManager) {
 	handlerGenerator := func(context *Context) Handler {
 		return &snapHookHandler{}
@@ -108,4 +128,5 @@ func setupHooks(hookMgr *HookManager) {
 	hookMgr.Register(regexp.MustCompile("^post-refresh$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^pre-refresh$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^remove$"), handlerGenerator)
+	hookMgr.Register(regexp.MustCompile("^gate-auto-refresh$"), handlerGenerator)
 }
@@ -18,10 +18,13 @@
 package ifacestate
 
 import (
+	"context"
 	"time"
 
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/policy"
 	"github.com/snapcore/snapd/overlord/ifacestate/udevmonitor"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
@@ -77,6 +80,12 @@ func MockRemoveStaleConnections(f func(st *state.State) error) (restore func())
 	return func() { removeStaleConnections = old }
 }
 
+func MockSnapstateInstall(f func(ctx context.Context, st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags) (*state.TaskSet, error)) (restore func()) {
+	old := snapstateInstall
+	snapstateInstall = f
+	return func() { snapstateInstall = old }
+}
+
 func MockContentLinkRetryTimeout(d time.Duration) (restore func()) {
 	old := contentLinkRetryTimeout
 	contentLinkRetryTimeout = d
@@ -162,3 +171,39 @@ func MockWriteSystemKey(fn func() error) func() {
 func (m *InterfaceManager) TransitionConnectionsCoreMigration(st *state.State, oldName, newName string) error {
 	return m.transitionConnectionsCoreMigration(st, oldName, newName)
 }
+
+func MockPromptReplyHooks() (restore func()) {
+	promptReplyHooksMu.Lock()
+	defer promptReplyHooksMu.Unlock()
+	old := promptReplyHooks
+	promptReplyHooks = nil
+	return func() {
+		promptReplyHooksMu.Lock()
+		defer promptReplyHooksMu.Unlock()
+		promptReplyHooks = old
+	}
+}
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
+
+func NewAutoConnectChecker(s *state.State, deviceCtx snapstate.DeviceContext) (interfaces.PolicyFunc, error) {
+	checker, err := newAutoConnectChecker(s, deviceCtx)
+	if err != nil {
+		return nil, err
+	}
+	return checker.check, nil
+}
+
+func MockCheckAutoConnectPolicy(f func(ic *policy.ConnectCandidate) *policy.ConnectCandidateArity) (restore func()) {
+	old := checkAutoConnectPolicy
+	checkAutoConnectPolicy = f
+	return func() {
+		checkAutoConnectPolicy = old
+	}
+}
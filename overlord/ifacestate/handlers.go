@@ -687,6 +687,261 @@ func (m *InterfaceManager) undoConnect(task *state.Task, _ *tomb.Tomb) error {
 	return nil
 }
 
+// connectionSpecs returns, for each security backend, the specification
+// derived from the given connection alone.
+func (m *InterfaceManager) connectionSpecs(conn *interfaces.Connection) (map[interfaces.SecuritySystem]interfaces.Specification, error) {
+	iface := m.repo.Interface(conn.Plug.Interface())
+	specs := make(map[interfaces.SecuritySystem]interfaces.Specification)
+	for _, backend := range m.repo.Backends() {
+		spec := backend.NewSpecification()
+		if err := spec.AddConnectedPlug(iface, conn.Plug, conn.Slot); err != nil {
+			return nil, err
+		}
+		if err := spec.AddConnectedSlot(iface, conn.Plug, conn.Slot); err != nil {
+			return nil, err
+		}
+		specs[backend.Name()] = spec
+	}
+	return specs, nil
+}
+
+// setConnectionDynamicAttrs replaces the dynamic attributes of an existing
+// connection in the repository and in the state, and regenerates the
+// security profiles of the connected snaps for the backends whose view of
+// the connection changed.
+func (m *InterfaceManager) setConnectionDynamicAttrs(task *state.Task, connRef *interfaces.ConnRef, plugDynamic, slotDynamic map[string]interface{}, tm timings.Measurer) (err error) {
+	st := task.State()
+
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+	cstate, ok := conns[connRef.ID()]
+	if !ok || cstate.Undesired || cstate.HotplugGone {
+		return fmt.Errorf("cannot update attributes of %s:%s to %s:%s, it is not connected",
+			connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
+	}
+
+	oldConn, err := m.repo.Connection(connRef)
+	if err != nil {
+		return err
+	}
+	before, err := m.connectionSpecs(oldConn)
+	if err != nil {
+		return err
+	}
+	if err := m.repo.Disconnect(connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name); err != nil {
+		return err
+	}
+
+	// the backends whose profiles were regenerated with the new attributes
+	var setupBackends []interfaces.SecurityBackend
+	var snaps []*snap.Info
+	defer func() {
+		if err == nil {
+			return
+		}
+		// put the old connection back in place
+		m.repo.Disconnect(connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
+		if _, rerr := m.repo.Connect(connRef, oldConn.Plug.StaticAttrs(), oldConn.Plug.DynamicAttrs(), oldConn.Slot.StaticAttrs(), oldConn.Slot.DynamicAttrs(), nil); rerr != nil {
+			task.Errorf("cannot restore connection %s: %v", connRef.ID(), rerr)
+			return
+		}
+		for _, backend := range setupBackends {
+			for _, snapInfo := range snaps {
+				if rerr := m.setupSnapSecurityBackend(task, backend, snapInfo, tm); rerr != nil {
+					task.Errorf("cannot restore %s for snap %q: %s", backend.Name(), snapInfo.InstanceName(), rerr)
+				}
+			}
+		}
+	}()
+
+	newConn, err := m.repo.Connect(connRef, cstate.StaticPlugAttrs, plugDynamic, cstate.StaticSlotAttrs, slotDynamic, nil)
+	if err != nil {
+		return err
+	}
+	after, err := m.connectionSpecs(newConn)
+	if err != nil {
+		return err
+	}
+
+	var backends []interfaces.SecurityBackend
+	for _, backend := range m.repo.Backends() {
+		if !reflect.DeepEqual(before[backend.Name()], after[backend.Name()]) {
+			backends = append(backends, backend)
+		}
+	}
+	if len(backends) == 0 {
+		task.Logf("No security profiles affected by the change of attributes.")
+	}
+
+	snaps = []*snap.Info{newConn.Plug.Snap()}
+	if newConn.Slot.Snap().InstanceName() != newConn.Plug.Snap().InstanceName() {
+		snaps = append(snaps, newConn.Slot.Snap())
+	}
+	for _, backend := range backends {
+		setupBackends = append(setupBackends, backend)
+		for _, snapInfo := range snaps {
+			if err = m.setupSnapSecurityBackend(task, backend, snapInfo, tm); err != nil {
+				task.Errorf("cannot setup %s for snap %q: %s", backend.Name(), snapInfo.InstanceName(), err)
+				return err
+			}
+		}
+	}
+
+	cstate.DynamicPlugAttrs = newConn.Plug.DynamicAttrs()
+	cstate.DynamicSlotAttrs = newConn.Slot.DynamicAttrs()
+	setConns(st, conns)
+	return nil
+}
+
+// setupSnapSecurityBackend regenerates the security profiles of the given
+// snap for a single backend.
+func (m *InterfaceManager) setupSnapSecurityBackend(task *state.Task, backend interfaces.SecurityBackend, snapInfo *snap.Info, tm timings.Measurer) error {
+	st := task.State()
+	m.deferBackendReloads(task)
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, snapInfo.InstanceName(), &snapst); err != nil {
+		return err
+	}
+	opts := confinementOptions(snapst.Flags)
+	var err error
+	st.Unlock()
+	timings.Run(tm, "setup-security-backend", fmt.Sprintf("setup security backend %q for snap %q", backend.Name(), snapInfo.InstanceName()), func(nesttm timings.Measurer) {
+		err = backend.Setup(snapInfo, opts, m.repo, nesttm)
+	})
+	st.Lock()
+	return err
+}
+
+func (m *InterfaceManager) cleanupBackendReloads(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	chg := task.Change()
+	flushes, ok := m.backendReloadFlushes[chg.ID()]
+	delete(m.backendReloadFlushes, chg.ID())
+
+	var deferred bool
+	if err := chg.Get("security-backends-reload-deferred", &deferred); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !deferred {
+		// nothing deferred or already reloaded by another task
+		return nil
+	}
+	chg.Set("security-backends-reload-deferred", false)
+
+	var reloads []func() error
+	if ok {
+		reloads = flushes
+	} else {
+		// deferred before a restart of snapd
+		for _, backend := range m.repo.Backends() {
+			if deferrer, ok := backend.(interfaces.SecurityBackendDeferReload); ok {
+				reloads = append(reloads, deferrer.Reload)
+			}
+		}
+	}
+
+	st.Unlock()
+	for _, reload := range reloads {
+		if err := reload(); err != nil {
+			logger.Noticef("cannot reload security backend: %v", err)
+		}
+	}
+	st.Lock()
+	return nil
+}
+
+func (m *InterfaceManager) doUpdateConnectionAttrs(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	perfTimings := timings.NewForTask(task)
+	defer perfTimings.Save(st)
+
+	plugRef, slotRef, err := getPlugAndSlotRefs(task)
+	if err != nil {
+		return err
+	}
+	connRef := &interfaces.ConnRef{PlugRef: plugRef, SlotRef: slotRef}
+
+	var plugAttrs, slotAttrs map[string]interface{}
+	if err := task.Get("plug-attrs", &plugAttrs); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if err := task.Get("slot-attrs", &slotAttrs); err != nil && err != state.ErrNoState {
+		return err
+	}
+
+	conn, err := m.repo.Connection(connRef)
+	if err != nil {
+		return err
+	}
+	task.Set("old-plug-dynamic", conn.Plug.DynamicAttrs())
+	task.Set("old-slot-dynamic", conn.Slot.DynamicAttrs())
+
+	// work on a copy of the connection so that the attributes are
+	// validated like the ones set by interface hooks, dropping the dynamic
+	// attributes shadowing static ones declared by a newer revision
+	plug := interfaces.NewConnectedPlug(m.repo.Plug(plugRef.Snap, plugRef.Name), conn.Plug.StaticAttrs(), withoutStaticAttrs(conn.Plug.StaticAttrs(), conn.Plug.DynamicAttrs()))
+	for key, value := range plugAttrs {
+		if err := plug.SetAttr(key, value); err != nil {
+			return err
+		}
+	}
+	slot := interfaces.NewConnectedSlot(m.repo.Slot(slotRef.Snap, slotRef.Name), conn.Slot.StaticAttrs(), withoutStaticAttrs(conn.Slot.StaticAttrs(), conn.Slot.DynamicAttrs()))
+	for key, value := range slotAttrs {
+		if err := slot.SetAttr(key, value); err != nil {
+			return err
+		}
+	}
+
+	return m.setConnectionDynamicAttrs(task, connRef, plug.DynamicAttrs(), slot.DynamicAttrs(), perfTimings)
+}
+
+// withoutStaticAttrs returns the dynamic attributes which are not shadowing
+// a static attribute with the same name. Dynamic attributes cannot be set
+// over static ones, so those were set before the snap started declaring
+// the attribute statically.
+func withoutStaticAttrs(static, dynamic map[string]interface{}) map[string]interface{} {
+	attrs := make(map[string]interface{}, len(dynamic))
+	for name, value := range dynamic {
+		if _, ok := static[name]; !ok {
+			attrs[name] = value
+		}
+	}
+	return attrs
+}
+
+func (m *InterfaceManager) undoUpdateConnectionAttrs(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	perfTimings := timings.NewForTask(task)
+	defer perfTimings.Save(st)
+
+	plugRef, slotRef, err := getPlugAndSlotRefs(task)
+	if err != nil {
+		return err
+	}
+	connRef := &interfaces.ConnRef{PlugRef: plugRef, SlotRef: slotRef}
+
+	var oldPlugDynamic, oldSlotDynamic map[string]interface{}
+	if err := task.Get("old-plug-dynamic", &oldPlugDynamic); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if err := task.Get("old-slot-dynamic", &oldSlotDynamic); err != nil && err != state.ErrNoState {
+		return err
+	}
+
+	return m.setConnectionDynamicAttrs(task, connRef, oldPlugDynamic, oldSlotDynamic, perfTimings)
+}
+
 // timeout for shared content retry
 var contentLinkRetryTimeout = 30 * time.Second
 
@@ -1129,10 +1384,51 @@ func (m *InterfaceManager) doAutoConnect(task *state.Task, _ *tomb.Tomb) error {
 		st.EnsureBefore(0)
 	}
 
+	updatets, err := m.contentSourceUpdateTasks(st, snapName)
+	if err != nil {
+		return err
+	}
+	if len(updatets.Tasks()) > 0 {
+		snapstate.InjectTasks(task, updatets)
+
+		st.EnsureBefore(0)
+	}
+
 	task.SetStatus(state.DoneStatus)
 	return nil
 }
 
+// contentSourceUpdateTasks returns the tasks updating in place the content
+// connections of the given snap providing the content, whose dynamic slot
+// attributes, set for a previous revision of the snap, shadow the ones
+// describing the content now declared statically by its slot.
+func (m *InterfaceManager) contentSourceUpdateTasks(st *state.State, snapName string) (*state.TaskSet, error) {
+	ts := state.NewTaskSet()
+	connRefs, err := m.repo.Connections(snapName)
+	if err != nil {
+		return nil, err
+	}
+	for _, connRef := range connRefs {
+		if connRef.SlotRef.Snap != snapName {
+			continue
+		}
+		conn, err := m.repo.Connection(connRef)
+		if err != nil {
+			return nil, err
+		}
+		if conn.Slot.Interface() != "content" {
+			continue
+		}
+		dynamic := conn.Slot.DynamicAttrs()
+		if len(withoutStaticAttrs(conn.Slot.StaticAttrs(), dynamic)) == len(dynamic) {
+			continue
+		}
+		// updating the connection drops the shadowing attributes
+		ts.AddTask(updateConnectionAttrsTask(st, connRef, nil, nil))
+	}
+	return ts, nil
+}
+
 // doAutoDisconnect creates tasks for disconnecting all interfaces of a snap and running its interface hooks.
 func (m *InterfaceManager) doAutoDisconnect(task *state.Task, _ *tomb.Tomb) error {
 	st := task.State()
@@ -1348,6 +1644,42 @@ func (m *InterfaceManager) doGadgetConnect(task *state.Task, _ *tomb.Tomb) error
 	return nil
 }
 
+// doConnectProvider connects a content plug to its default provider
+// once the provider got installed in the same change.
+func (m *InterfaceManager) doConnectProvider(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	plugRef, slotRef, err := getPlugAndSlotRefs(task)
+	if err != nil {
+		return err
+	}
+
+	// the provider may get connected by its own auto-connect
+	if err := checkAutoconnectConflicts(st, task, plugRef.Snap, slotRef.Snap); err != nil {
+		if retry, ok := err.(*state.Retry); ok {
+			task.Logf("connect of default provider will be retried: %s", retry.Reason)
+			return err // will retry
+		}
+		return fmt.Errorf("connect of default provider conflict check failed: %s", err)
+	}
+
+	ts, err := connect(st, plugRef.Snap, plugRef.Name, slotRef.Snap, slotRef.Name, connectOpts{})
+	if err != nil {
+		if _, ok := err.(*ErrAlreadyConnected); ok {
+			task.Logf("%s", err)
+			return nil
+		}
+		return err
+	}
+	snapstate.InjectTasks(task, ts)
+	st.EnsureBefore(0)
+
+	task.SetStatus(state.DoneStatus)
+	return nil
+}
+
 // doHotplugConnect creates task(s) to (re)create old connections or auto-connect viable slots in response to hotplug "add" event.
 func (m *InterfaceManager) doHotplugConnect(task *state.Task, _ *tomb.Tomb) error {
 	st := task.State()
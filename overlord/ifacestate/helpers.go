@@ -21,6 +21,8 @@ package ifacestate
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -29,6 +31,7 @@ import (
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/backends"
 	"github.com/snapcore/snapd/interfaces/builtin"
@@ -37,9 +40,11 @@ import (
 	"github.com/snapcore/snapd/jsonutil"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/timings"
 )
 
@@ -109,6 +114,102 @@ func profilesNeedRegenerationImpl() bool {
 
 var profilesNeedRegeneration = profilesNeedRegenerationImpl
 var writeSystemKey = interfaces.WriteSystemKey
+var systemKeyDigest = interfaces.SystemKeyDigest
+
+// systemProfileInputs returns what the security profiles of all snaps
+// are computed from: the system-key, the snapd feature flags and the
+// revisions of the snapd and core snaps, which carry snap-confine and
+// the other helpers the profiles refer to. Nil is returned when those
+// cannot be pinned down, e.g. with unasserted snapd or core snaps whose
+// content can change without a new revision.
+func systemProfileInputs(st *state.State) (map[string]interface{}, error) {
+	systemKey, err := systemKeyDigest()
+	if err != nil {
+		logger.Debugf("cannot compute system-key digest: %v", err)
+		return nil, nil
+	}
+
+	tr := config.NewTransaction(st)
+	flags := make(map[string]bool)
+	for _, f := range features.KnownFeatures() {
+		enabled, err := config.GetFeatureFlag(tr, f)
+		if err != nil {
+			return nil, err
+		}
+		flags[f.String()] = enabled
+	}
+
+	revisions := make(map[string]snap.Revision)
+	for _, name := range []string{"snapd", "core"} {
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, name, &snapst); err != nil && err != state.ErrNoState {
+			return nil, err
+		}
+		if snapst.Current.Local() {
+			return nil, nil
+		}
+		revisions[name] = snapst.Current
+	}
+
+	return map[string]interface{}{
+		"system-key": systemKey,
+		"features":   flags,
+		"revisions":  revisions,
+	}, nil
+}
+
+// securityProfileInputs returns a digest of what the security profiles
+// of the given snap are computed from: the system inputs, the revision
+// and confinement of the snap and its connections, including the
+// revisions of the snaps on the other end. An empty digest is returned
+// for unasserted snaps as their content can change without a new
+// revision.
+func securityProfileInputs(st *state.State, conns map[string]*connState, systemInputs map[string]interface{}, snapInfo *snap.Info, opts interfaces.ConfinementOptions) (string, error) {
+	if snapInfo.Revision.Unset() || snapInfo.Revision.Local() {
+		return "", nil
+	}
+	instanceName := snapInfo.InstanceName()
+	snapConns := make(map[string]*connState)
+	peers := make(map[string]snap.Revision)
+	for id, cstate := range conns {
+		if cstate.Undesired || cstate.HotplugGone {
+			continue
+		}
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return "", err
+		}
+		var peer string
+		switch instanceName {
+		case connRef.PlugRef.Snap:
+			peer = connRef.SlotRef.Snap
+		case connRef.SlotRef.Snap:
+			peer = connRef.PlugRef.Snap
+		default:
+			continue
+		}
+		snapConns[id] = cstate
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, peer, &snapst); err != nil && err != state.ErrNoState {
+			return "", err
+		}
+		peers[peer] = snapst.Current
+	}
+
+	inputs := map[string]interface{}{
+		"system":      systemInputs,
+		"revision":    snapInfo.Revision,
+		"confinement": opts,
+		"connections": snapConns,
+		"peers":       peers,
+	}
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:]), nil
+}
 
 // regenerateAllSecurityProfiles will regenerate all security profiles.
 func (m *InterfaceManager) regenerateAllSecurityProfiles(tm timings.Measurer) error {
@@ -146,12 +247,58 @@ func (m *InterfaceManager) regenerateAllSecurityProfiles(tm timings.Measurer) er
 		return confinementOptions(snapst.Flags)
 	}
 
+	// Skip the snaps whose profiles were generated from the very same
+	// inputs already, e.g. when the system-key went missing but
+	// nothing else changed.
+	var cached map[string]string
+	if err := m.state.Get("security-profiles-inputs", &cached); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if cached != nil {
+		// Drop the cached inputs while the profiles are regenerated
+		// and checkpoint, so that snapd being interrupted in the
+		// middle of it is not mistaken for all of them being in
+		// place at the next startup.
+		m.state.Set("security-profiles-inputs", nil)
+		m.state.Unlock()
+		m.state.Lock()
+	}
+	conns, err := getConns(m.state)
+	if err != nil {
+		return err
+	}
+	systemInputs, err := systemProfileInputs(m.state)
+	if err != nil {
+		return err
+	}
+	inputs := make(map[string]string, len(snaps))
+	outdated := make([]*snap.Info, 0, len(snaps))
+	for _, snapInfo := range snaps {
+		instanceName := snapInfo.InstanceName()
+		if systemInputs != nil {
+			digest, err := securityProfileInputs(m.state, conns, systemInputs, snapInfo, confinementOpts(instanceName))
+			if err != nil {
+				return err
+			}
+			if digest != "" {
+				inputs[instanceName] = digest
+				if cached[instanceName] == digest {
+					continue
+				}
+			}
+		}
+		outdated = append(outdated, snapInfo)
+	}
+
 	// For each backend:
 	for _, backend := range securityBackends {
 		if backend.Name() == "" {
 			continue // Test backends have no name, skip them to simplify testing.
 		}
-		if errors := interfaces.SetupMany(m.repo, backend, snaps, confinementOpts, tm); len(errors) > 0 {
+		if len(outdated) == 0 {
+			break
+		}
+		if errors := interfaces.SetupMany(m.repo, backend, outdated, confinementOpts, tm); len(errors) > 0 {
 			logger.Noticef("cannot regenerate %s profiles", backend.Name())
 			for _, err := range errors {
 				logger.Noticef(err.Error())
@@ -161,9 +308,13 @@ func (m *InterfaceManager) regenerateAllSecurityProfiles(tm timings.Measurer) er
 	}
 
 	if shouldWriteSystemKey {
+		m.state.Set("security-profiles-inputs", inputs)
 		if err := writeSystemKey(); err != nil {
 			logger.Noticef("cannot write system key: %v", err)
 		}
+	} else {
+		// the errors cannot be attributed to single snaps
+		m.state.Set("security-profiles-inputs", nil)
 	}
 	return nil
 }
@@ -358,10 +509,23 @@ func (m *InterfaceManager) removeConnections(snapName string) error {
 
 func (m *InterfaceManager) setupSecurityByBackend(task *state.Task, snaps []*snap.Info, opts []interfaces.ConfinementOptions, tm timings.Measurer) error {
 	st := task.State()
+	m.deferBackendReloads(task)
 
 	// Setup all affected snaps, start with the most important security
 	// backend and run it for all snaps. See LP: 1802581
 	for _, backend := range m.repo.Backends() {
+		if concurrent, ok := backend.(interfaces.SecurityBackendConcurrentSetup); ok && concurrent.ConcurrentSetup() && len(snaps) > 1 {
+			if err := m.setupSecurityConcurrently(task, backend, snaps, opts, tm); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := backend.(interfaces.SecurityBackendSetupMany); ok && len(snaps) > 1 {
+			if err := m.setupSecurityMany(task, backend, snaps, opts, tm); err != nil {
+				return err
+			}
+			continue
+		}
 		for i, snapInfo := range snaps {
 			st.Unlock()
 			var err error
@@ -379,12 +543,70 @@ func (m *InterfaceManager) setupSecurityByBackend(task *state.Task, snaps []*sna
 	return nil
 }
 
+func confinementOptionsOf(snaps []*snap.Info, opts []interfaces.ConfinementOptions) func(snapName string) interfaces.ConfinementOptions {
+	snapOpts := make(map[string]interfaces.ConfinementOptions, len(snaps))
+	for i, snapInfo := range snaps {
+		snapOpts[snapInfo.InstanceName()] = opts[i]
+	}
+	return func(snapName string) interfaces.ConfinementOptions {
+		return snapOpts[snapName]
+	}
+}
+
+// setupSecurityConcurrently sets up the given snaps concurrently with
+// a backend that supports it. All snaps are set up even if some of
+// them fail, the first error is returned.
+func (m *InterfaceManager) setupSecurityConcurrently(task *state.Task, backend interfaces.SecurityBackend, snaps []*snap.Info, opts []interfaces.ConfinementOptions, tm timings.Measurer) error {
+	st := task.State()
+	confinementOpts := confinementOptionsOf(snaps, opts)
+
+	st.Unlock()
+	errs := interfaces.SetupEach(m.repo, backend, snaps, confinementOpts, tm)
+	st.Lock()
+
+	var firstErr error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		task.Errorf("cannot setup %s for snap %q: %s", backend.Name(), snaps[i].InstanceName(), err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// setupSecurityMany sets up the given snaps in one batch with a backend
+// that can coalesce the work, e.g. reloading udev rules only once for
+// all the snaps affected by a connection.
+func (m *InterfaceManager) setupSecurityMany(task *state.Task, backend interfaces.SecurityBackend, snaps []*snap.Info, opts []interfaces.ConfinementOptions, tm timings.Measurer) error {
+	st := task.State()
+	name := backend.Name()
+
+	var errs []error
+	st.Unlock()
+	timings.Run(tm, "setup-security-backend", fmt.Sprintf("setup security backend %q for %d snaps", name, len(snaps)), func(nesttm timings.Measurer) {
+		errs = backend.(interfaces.SecurityBackendSetupMany).SetupMany(snaps, confinementOptionsOf(snaps, opts), m.repo, nesttm)
+	})
+	st.Lock()
+
+	for _, err := range errs {
+		task.Errorf("cannot setup %s: %s", name, err)
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
 func (m *InterfaceManager) setupSnapSecurity(task *state.Task, snapInfo *snap.Info, opts interfaces.ConfinementOptions, tm timings.Measurer) error {
 	return m.setupSecurityByBackend(task, []*snap.Info{snapInfo}, []interfaces.ConfinementOptions{opts}, tm)
 }
 
 func (m *InterfaceManager) removeSnapSecurity(task *state.Task, instanceName string) error {
 	st := task.State()
+	m.deferBackendReloads(task)
 	for _, backend := range m.repo.Backends() {
 		st.Unlock()
 		err := backend.Remove(instanceName)
@@ -397,6 +619,32 @@ func (m *InterfaceManager) removeSnapSecurity(task *state.Task, instanceName str
 	return nil
 }
 
+// deferBackendReloads makes the reloads done by the security backends
+// when setting up and removing the snaps of the change of the task happen
+// only once, when the change is ready, see cleanupBackendReloads. It must
+// be called with the state locked.
+func (m *InterfaceManager) deferBackendReloads(task *state.Task) {
+	chg := task.Change()
+	if chg == nil {
+		return
+	}
+	if _, ok := m.backendReloadFlushes[chg.ID()]; ok {
+		return
+	}
+	var flushes []func() error
+	for _, backend := range m.repo.Backends() {
+		if deferrer, ok := backend.(interfaces.SecurityBackendDeferReload); ok {
+			flushes = append(flushes, deferrer.DeferReload())
+		}
+	}
+	m.backendReloadFlushes[chg.ID()] = flushes
+	if len(flushes) > 0 {
+		// remember the deferral, the reloads still need to happen if
+		// snapd is restarted before the change is ready
+		chg.Set("security-backends-reload-deferred", true)
+	}
+}
+
 func addHotplugSlot(st *state.State, repo *interfaces.Repository, stateSlots map[string]*HotplugSlotInfo, iface interfaces.Interface, slot *snap.SlotInfo) error {
 	if slot.HotplugKey == "" {
 		return fmt.Errorf("internal error: cannot store slot %q, not a hotplug slot", slot.Name)
@@ -448,6 +696,9 @@ type autoConnectChecker struct {
 	deviceCtx snapstate.DeviceContext
 	cache     map[string]*asserts.SnapDeclaration
 	baseDecl  *asserts.BaseDeclaration
+	// allowed tracks the auto-connections allowed by this checker to
+	// slots with a limited number of connected plugs
+	allowed map[interfaces.SlotRef][]interfaces.PlugRef
 }
 
 func newAutoConnectChecker(s *state.State, deviceCtx snapstate.DeviceContext) (*autoConnectChecker, error) {
@@ -460,6 +711,7 @@ func newAutoConnectChecker(s *state.State, deviceCtx snapstate.DeviceContext) (*
 		deviceCtx: deviceCtx,
 		cache:     make(map[string]*asserts.SnapDeclaration),
 		baseDecl:  baseDecl,
+		allowed:   make(map[interfaces.SlotRef][]interfaces.PlugRef),
 	}, nil
 }
 
@@ -508,18 +760,198 @@ func (c *autoConnectChecker) check(plug *interfaces.ConnectedPlug, slot *interfa
 		}
 	}
 
-	// check the connection against the declarations' rules
-	ic := policy.ConnectCandidate{
-		Plug:                plug,
-		PlugSnapDeclaration: plugDecl,
-		Slot:                slot,
-		SlotSnapDeclaration: slotDecl,
-		BaseDeclaration:     c.baseDecl,
-		Model:               modelAs,
-		Store:               storeAs,
+	key, cacheable := autoConnectPolicyKeyFor(plug, plugDecl, slot, slotDecl)
+	var cache *autoConnectPolicyCache
+	var arity *policy.ConnectCandidateArity
+	found := false
+	if cacheable {
+		cache = cachedAutoConnectPolicy(c.st, autoConnectPolicyContext(c.baseDecl, modelAs, storeAs))
+		arity, found = cache.results[key]
+	}
+	if !found {
+		// check the connection against the declarations' rules
+		ic := policy.ConnectCandidate{
+			Plug:                plug,
+			PlugSnapDeclaration: plugDecl,
+			Slot:                slot,
+			SlotSnapDeclaration: slotDecl,
+			BaseDeclaration:     c.baseDecl,
+			Model:               modelAs,
+			Store:               storeAs,
+		}
+
+		arity = checkAutoConnectPolicy(&ic)
+		if cacheable {
+			cache.add(key, arity)
+		}
+	}
+	if arity == nil {
+		return false, nil
+	}
+
+	if !arity.PlugsPerSlot.Any() {
+		plugRef := interfaces.PlugRef{Snap: plug.Snap().InstanceName(), Name: plug.Name()}
+		slotRef := interfaces.SlotRef{Snap: slot.Snap().InstanceName(), Name: slot.Name()}
+		for _, allowed := range c.allowed[slotRef] {
+			if allowed == plugRef {
+				return true, nil
+			}
+		}
+		connected, err := connectedPlugsOfSlot(c.st, slotRef, plugRef)
+		if err != nil {
+			return false, err
+		}
+		connected = append(connected, c.allowed[slotRef]...)
+		if len(connected) >= arity.PlugsPerSlot.N {
+			logger.Noticef("cannot auto-connect %s to %s: %s", plugRef, slotRef, slotArityError(arity.PlugsPerSlot.N, connected))
+			return false, nil
+		}
+		c.allowed[slotRef] = append(c.allowed[slotRef], plugRef)
+	}
+
+	return true, nil
+}
+
+// checkAutoConnectPolicy returns the arity constraints of the
+// auto-connection if it is allowed by the policy, nil otherwise.
+var checkAutoConnectPolicy = func(ic *policy.ConnectCandidate) *policy.ConnectCandidateArity {
+	arity, err := ic.CheckAutoConnectWithArity()
+	if err != nil {
+		return nil
 	}
+	return arity
+}
+
+// connectedPlugsOfSlot returns the plugs connected to the given slot
+// according to the state, ignoring the given plug.
+func connectedPlugsOfSlot(st *state.State, slotRef interfaces.SlotRef, ignore interfaces.PlugRef) ([]interfaces.PlugRef, error) {
+	conns, err := getConns(st)
+	if err != nil {
+		return nil, err
+	}
+	var plugs []interfaces.PlugRef
+	for id, cstate := range conns {
+		if cstate.Undesired || cstate.HotplugGone {
+			continue
+		}
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return nil, err
+		}
+		if connRef.SlotRef != slotRef || connRef.PlugRef == ignore {
+			continue
+		}
+		plugs = append(plugs, connRef.PlugRef)
+	}
+	sort.Slice(plugs, func(i, j int) bool {
+		return plugs[i].String() < plugs[j].String()
+	})
+	return plugs, nil
+}
+
+func slotArityError(n int, connected []interfaces.PlugRef) error {
+	plugs := make([]string, len(connected))
+	for i, plugRef := range connected {
+		plugs[i] = plugRef.String()
+	}
+	return fmt.Errorf("the slot allows at most %d connection(s) and is already connected to %s", n, strutil.Quoted(plugs))
+}
 
-	return ic.CheckAutoConnect() == nil, nil
+// maxAutoConnectPolicyCacheEntries bounds the number of auto-connection
+// policy decisions remembered, the cache is dropped once it is exceeded.
+const maxAutoConnectPolicyCacheEntries = 10000
+
+type autoConnectPolicyCacheKey struct{}
+
+// autoConnectPolicyKey identifies the outcome of the auto-connection
+// policy check of a plug and slot pair. The outcome only depends on the
+// static attributes of the plug and slot, which are fixed for a given
+// snap revision, and on the rules of the snap declarations, which are
+// fixed for a given declaration revision.
+type autoConnectPolicyKey struct {
+	plugSnapID  string
+	plugRev     snap.Revision
+	plugDeclRev int
+	plug        string
+	slotSnapID  string
+	slotRev     snap.Revision
+	slotDeclRev int
+	slot        string
+}
+
+// autoConnectPolicyCache remembers auto-connection policy decisions
+// across auto-connect checkers, so that installing a snap on systems
+// with many snaps does not re-evaluate the declarations of all the
+// candidate plug and slot pairs every time.
+type autoConnectPolicyCache struct {
+	// context captures the base declaration, model and store the
+	// decisions were taken against
+	context string
+	// results holds the arity constraints of allowed
+	// auto-connections, nil for disallowed ones
+	results map[autoConnectPolicyKey]*policy.ConnectCandidateArity
+}
+
+func (cache *autoConnectPolicyCache) add(key autoConnectPolicyKey, arity *policy.ConnectCandidateArity) {
+	if len(cache.results) >= maxAutoConnectPolicyCacheEntries {
+		cache.results = make(map[autoConnectPolicyKey]*policy.ConnectCandidateArity)
+	}
+	cache.results[key] = arity
+}
+
+func autoConnectPolicyContext(baseDecl *asserts.BaseDeclaration, modelAs *asserts.Model, storeAs *asserts.Store) string {
+	storeRev := -1
+	if storeAs != nil {
+		storeRev = storeAs.Revision()
+	}
+	return fmt.Sprintf("%d/%s/%s/%d/%s/%d", baseDecl.Revision(), modelAs.BrandID(), modelAs.Model(), modelAs.Revision(), modelAs.Store(), storeRev)
+}
+
+// cachedAutoConnectPolicy returns the auto-connection policy cache of the
+// state, it is reset whenever the given context changes.
+func cachedAutoConnectPolicy(st *state.State, context string) *autoConnectPolicyCache {
+	cache, _ := st.Cached(autoConnectPolicyCacheKey{}).(*autoConnectPolicyCache)
+	if cache == nil || cache.context != context {
+		cache = &autoConnectPolicyCache{
+			context: context,
+			results: make(map[autoConnectPolicyKey]*policy.ConnectCandidateArity),
+		}
+		st.Cache(autoConnectPolicyCacheKey{}, cache)
+	}
+	return cache
+}
+
+func autoConnectPolicyKeyFor(plug *interfaces.ConnectedPlug, plugDecl *asserts.SnapDeclaration, slot *interfaces.ConnectedSlot, slotDecl *asserts.SnapDeclaration) (key autoConnectPolicyKey, cacheable bool) {
+	// only asserted snaps have stable revisions and declarations
+	if plugDecl == nil || slotDecl == nil {
+		return key, false
+	}
+	plugSnap := plug.Snap()
+	slotSnap := slot.Snap()
+	if plugSnap.Revision.Unset() || plugSnap.Revision.Local() || slotSnap.Revision.Unset() || slotSnap.Revision.Local() {
+		return key, false
+	}
+	// dynamic attributes are not tied to the snap revision
+	if len(plug.DynamicAttrs()) != 0 || len(slot.DynamicAttrs()) != 0 {
+		return key, false
+	}
+	// neither are the attributes of hotplug slots
+	if slotInfo := slotSnap.Slots[slot.Name()]; slotInfo == nil || slotInfo.HotplugKey != "" {
+		return key, false
+	}
+	if plugSnap.Plugs[plug.Name()] == nil {
+		return key, false
+	}
+	return autoConnectPolicyKey{
+		plugSnapID:  plugSnap.SnapID,
+		plugRev:     plugSnap.Revision,
+		plugDeclRev: plugDecl.Revision(),
+		plug:        plug.Name(),
+		slotSnapID:  slotSnap.SnapID,
+		slotRev:     slotSnap.Revision,
+		slotDeclRev: slotDecl.Revision(),
+		slot:        slot.Name(),
+	}, true
 }
 
 type connectChecker struct {
@@ -584,10 +1016,28 @@ func (c *connectChecker) check(plug *interfaces.ConnectedPlug, slot *interfaces.
 	// if either of plug or slot snaps don't have a declaration it
 	// means they were installed with "dangerous", so the security
 	// check should be skipped at this point.
+	var arity *policy.ConnectCandidateArity
 	if plugDecl != nil && slotDecl != nil {
-		if err := ic.Check(); err != nil {
+		var err error
+		arity, err = ic.CheckWithArity()
+		if err != nil {
 			return false, err
 		}
+	} else if slotDecl != nil {
+		// the arity is set by the slot side alone, it is enforced
+		// even if the plug snap was installed with "dangerous"
+		arity = ic.Arity()
+	}
+	if arity != nil && !arity.PlugsPerSlot.Any() {
+		plugRef := interfaces.PlugRef{Snap: plug.Snap().InstanceName(), Name: plug.Name()}
+		slotRef := interfaces.SlotRef{Snap: slot.Snap().InstanceName(), Name: slot.Name()}
+		connected, err := connectedPlugsOfSlot(c.st, slotRef, plugRef)
+		if err != nil {
+			return false, err
+		}
+		if len(connected) >= arity.PlugsPerSlot.N {
+			return false, fmt.Errorf("cannot connect %s to %s: %s", plugRef, slotRef, slotArityError(arity.PlugsPerSlot.N, connected))
+		}
 	}
 	return true, nil
 }
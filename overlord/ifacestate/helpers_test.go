@@ -25,7 +25,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -35,6 +37,7 @@ import (
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -45,6 +48,8 @@ import (
 
 type helpersSuite struct {
 	st *state.State
+
+	restoreSystemKey func()
 }
 
 var _ = Suite(&helpersSuite{})
@@ -52,9 +57,11 @@ var _ = Suite(&helpersSuite{})
 func (s *helpersSuite) SetUpTest(c *C) {
 	s.st = state.New(nil)
 	dirs.SetRootDir(c.MkDir())
+	s.restoreSystemKey = interfaces.MockSystemKey(`{"build-id": "7a94e9736c091b3984bd63f5aebfc883c4d859e0"}`)
 }
 
 func (s *helpersSuite) TearDownTest(c *C) {
+	s.restoreSystemKey()
 	dirs.SetRootDir("")
 }
 
@@ -535,6 +542,165 @@ func (s *helpersSuite) TestProfileRegenerationSetupManyFailsSystemKeyNotWritten(
 	c.Check(log.String(), Matches, ".*cannot regenerate fake profiles\n.*FAILED\n")
 }
 
+func (s *helpersSuite) TestProfileRegenerationSkipsUnchangedSnaps(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	var setupSnaps [][]string
+
+	// Create a fake security backend
+	backend := &ifacetest.TestSecurityBackendSetupMany{
+		TestSecurityBackend: ifacetest.TestSecurityBackend{BackendName: "fake"},
+		SetupManyCallback: func(snaps []*snap.Info, confinement func(snapName string) interfaces.ConfinementOptions, repo *interfaces.Repository, tm timings.Measurer) []error {
+			var names []string
+			for _, si := range snaps {
+				names = append(names, si.InstanceName())
+			}
+			sort.Strings(names)
+			setupSnaps = append(setupSnaps, names)
+			return nil
+		},
+	}
+	restore := ifacestate.MockSecurityBackends([]interfaces.SecurityBackend{backend})
+	defer restore()
+
+	// Create a mock overlord, mainly to have state.
+	ovld := overlord.Mock()
+	st := ovld.State()
+
+	mockSnaps(c, st)
+
+	// Pretend that security profiles are out of date.
+	restore = ifacestate.MockProfilesNeedRegeneration(func() bool { return true })
+	defer restore()
+	restore = ifacestate.MockWriteSystemKey(func() error { return nil })
+	defer restore()
+	// the connection to core below is not stale
+	restore = ifacestate.MockRemoveStaleConnections(func(*state.State) error { return nil })
+	defer restore()
+
+	startUp := func() {
+		mgr, err := ifacestate.Manager(st, nil, ovld.TaskRunner(), nil, nil)
+		c.Assert(err, IsNil)
+		c.Assert(mgr.StartUp(), IsNil)
+	}
+
+	startUp()
+	c.Check(setupSnaps, DeepEquals, [][]string{{"bar", "foo"}})
+
+	// nothing changed
+	startUp()
+	c.Check(setupSnaps, HasLen, 1)
+
+	// a new connection of foo
+	st.Lock()
+	st.Set("conns", map[string]interface{}{
+		"foo:network core:network": map[string]interface{}{"interface": "network"},
+	})
+	st.Unlock()
+	startUp()
+	c.Check(setupSnaps, DeepEquals, [][]string{{"bar", "foo"}, {"foo"}})
+
+	// a different system-key
+	interfaces.MockSystemKey(`{"build-id": "other-build-id"}`)
+	startUp()
+	c.Check(setupSnaps, DeepEquals, [][]string{{"bar", "foo"}, {"foo"}, {"bar", "foo"}})
+	setupSnaps = nil
+
+	// a feature flag changed
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "experimental.parallel-instances", true)
+	tr.Commit()
+	st.Unlock()
+	startUp()
+	c.Check(setupSnaps, DeepEquals, [][]string{{"bar", "foo"}})
+
+	// a new snapd revision, the snap is kept inactive so that it has no
+	// profiles of its own
+	setSnapd := func(rev snap.Revision) {
+		st.Lock()
+		defer st.Unlock()
+		snapstate.Set(st, "snapd", &snapstate.SnapState{
+			SnapType: string(snap.TypeSnapd),
+			Sequence: []*snap.SideInfo{{RealName: "snapd", Revision: rev}},
+			Current:  rev,
+		})
+	}
+	setSnapd(snap.R(2))
+	startUp()
+	c.Check(setupSnaps, DeepEquals, [][]string{{"bar", "foo"}, {"bar", "foo"}})
+	startUp()
+	c.Check(setupSnaps, HasLen, 2)
+	setSnapd(snap.R(3))
+	startUp()
+	c.Check(setupSnaps, HasLen, 3)
+
+	// nothing is skipped with an unasserted snapd
+	setSnapd(snap.R("x1"))
+	startUp()
+	startUp()
+	c.Check(setupSnaps, HasLen, 5)
+}
+
+type checkpointRecorder struct {
+	checkpoints []string
+}
+
+func (b *checkpointRecorder) Checkpoint(data []byte) error {
+	b.checkpoints = append(b.checkpoints, string(data))
+	return nil
+}
+
+func (b *checkpointRecorder) EnsureBefore(d time.Duration) {}
+
+func (b *checkpointRecorder) RequestRestart(t state.RestartType) {}
+
+func (s *helpersSuite) TestProfileRegenerationDropsCachedInputsFirst(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	recorder := &checkpointRecorder{}
+	st := state.New(recorder)
+	mockSnaps(c, st)
+	st.Lock()
+	st.Set("security-profiles-inputs", map[string]string{"foo": "stale", "bar": "stale"})
+	st.Unlock()
+
+	var setupManyCalls int
+	backend := &ifacetest.TestSecurityBackendSetupMany{
+		TestSecurityBackend: ifacetest.TestSecurityBackend{BackendName: "fake"},
+		SetupManyCallback: func(snaps []*snap.Info, confinement func(snapName string) interfaces.ConfinementOptions, repo *interfaces.Repository, tm timings.Measurer) []error {
+			setupManyCalls++
+			// the cached inputs are gone from the state on disk
+			// before any profile is regenerated
+			c.Assert(recorder.checkpoints, Not(HasLen), 0)
+			last := recorder.checkpoints[len(recorder.checkpoints)-1]
+			c.Check(strings.Contains(last, "security-profiles-inputs"), Equals, false)
+			return nil
+		},
+	}
+	restore := ifacestate.MockSecurityBackends([]interfaces.SecurityBackend{backend})
+	defer restore()
+	restore = ifacestate.MockProfilesNeedRegeneration(func() bool { return true })
+	defer restore()
+	restore = ifacestate.MockWriteSystemKey(func() error { return nil })
+	defer restore()
+
+	mgr, err := ifacestate.Manager(st, nil, state.NewTaskRunner(st), nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(mgr.StartUp(), IsNil)
+	c.Check(setupManyCalls, Equals, 1)
+
+	// and recorded again once all of them are
+	st.Lock()
+	defer st.Unlock()
+	var inputs map[string]string
+	c.Assert(st.Get("security-profiles-inputs", &inputs), IsNil)
+	c.Check(inputs, HasLen, 2)
+	c.Check(inputs["foo"], Not(Equals), "stale")
+}
+
 func (s *helpersSuite) TestIsHotplugChange(c *C) {
 	s.st.Lock()
 	defer s.st.Unlock()
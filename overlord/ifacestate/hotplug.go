@@ -104,6 +104,33 @@ func defaultDeviceKey(devinfo *hotplug.HotplugDeviceInfo, keyVersion int) (snap.
 	return snap.HotplugKey(fmt.Sprintf("%x%x", keyVersion, key.Sum(nil))), nil
 }
 
+// keepLegacyHotplugKey returns the legacy key if there is a hotplug slot
+// for the interface with that key but none with the given key. This is the
+// case of slots created before the interface provided its own key, which
+// would otherwise be orphaned along with their connections.
+func keepLegacyHotplugKey(st *state.State, ifaceName string, key, legacyKey snap.HotplugKey) (snap.HotplugKey, error) {
+	slots, err := getHotplugSlots(st)
+	if err != nil {
+		return "", err
+	}
+	var haveLegacy bool
+	for _, slot := range slots {
+		if slot.Interface != ifaceName {
+			continue
+		}
+		switch slot.HotplugKey {
+		case key:
+			return key, nil
+		case legacyKey:
+			haveLegacy = true
+		}
+	}
+	if haveLegacy {
+		return legacyKey, nil
+	}
+	return key, nil
+}
+
 // hotplugDeviceAdded gets called when a device is added to the system.
 func (m *InterfaceManager) hotplugDeviceAdded(devinfo *hotplug.HotplugDeviceInfo) {
 	st := m.state
@@ -184,6 +211,15 @@ InterfacesLoop:
 			logger.Noticef("no valid hotplug key provided by interface %q, device %s ignored", iface.Name(), devinfo)
 			continue
 		}
+		if key != defaultKey && defaultKey != "" {
+			// the interface may have computed the key differently when
+			// the slot of the device was created
+			key, err = keepLegacyHotplugKey(st, iface.Name(), key, defaultKey)
+			if err != nil {
+				logger.Noticef("internal error: cannot obtain hotplug slots: %v", err)
+				continue
+			}
+		}
 
 		proposedSlot, err = proposedSlot.Clean()
 		if err != nil {
@@ -432,6 +432,58 @@ func (s *hotplugSuite) TestHotplugAddWithDefaultKey(c *C) {
 	c.Check(slots[0].HotplugKey, Equals, testIfaceDkey)
 }
 
+func (s *hotplugSuite) TestHotplugAddKeepsLegacyDefaultKey(c *C) {
+	s.MockModel(c, nil)
+
+	testIfaceDkey := keyHelper("ID_VENDOR_ID\x00vendor\x00ID_MODEL_ID\x00model\x00ID_SERIAL_SHORT\x00serial\x00")
+
+	// the slot of the test-a interface was created with the default key,
+	// before the interface provided its own key
+	st := s.state
+	st.Lock()
+	st.Set("hotplug-slots", map[string]interface{}{
+		"hotplugslot-a": map[string]interface{}{
+			"name":         "hotplugslot-a",
+			"interface":    "test-a",
+			"hotplug-key":  testIfaceDkey,
+			"hotplug-gone": true,
+		}})
+	st.Unlock()
+
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":         "a/path",
+		"ACTION":          "add",
+		"SUBSYSTEM":       "foo",
+		"ID_VENDOR_ID":    "vendor",
+		"ID_MODEL_ID":     "model",
+		"ID_SERIAL_SHORT": "serial",
+	})
+	c.Assert(err, IsNil)
+	s.udevMon.AddDevice(di)
+
+	c.Assert(s.o.Settle(5*time.Second), IsNil)
+
+	st.Lock()
+	defer st.Unlock()
+
+	// the existing slot is restored with its key
+	repo := s.mgr.Repository()
+	slots := repo.AllSlots("test-a")
+	c.Assert(slots, HasLen, 1)
+	c.Check(slots[0].Name, Equals, "hotplugslot-a")
+	c.Check(slots[0].HotplugKey, Equals, testIfaceDkey)
+
+	var hotplugSlots map[string]*ifacestate.HotplugSlotInfo
+	c.Assert(st.Get("hotplug-slots", &hotplugSlots), IsNil)
+	c.Check(hotplugSlots["hotplugslot-a"].HotplugKey, Equals, testIfaceDkey)
+	c.Check(hotplugSlots["hotplugslot-a"].HotplugGone, Equals, false)
+
+	// other interfaces with their own key are not affected
+	slots = repo.AllSlots("test-b")
+	c.Assert(slots, HasLen, 1)
+	c.Check(slots[0].HotplugKey, Equals, snap.HotplugKey("key-2"))
+}
+
 func (s *hotplugSuite) TestHotplugAddWithAutoconnect(c *C) {
 	s.MockModel(c, nil)
 
@@ -20,6 +20,7 @@
 package ifacestate
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -55,10 +56,17 @@ type InterfaceManager struct {
 	enumerationDone      bool
 	// maps sysfs path -> [(interface name, device key)...]
 	hotplugDevicePaths map[string][]deviceData
+	// whether the hotplug feature was enabled when last checked
+	hotplugWasEnabled bool
 
 	// extras
 	extraInterfaces []interfaces.Interface
 	extraBackends   []interfaces.SecurityBackend
+
+	// backendReloadFlushes holds, per change ID, the functions flushing
+	// the reloads of the security backends deferred while the snaps of
+	// the change are set up and removed
+	backendReloadFlushes map[string][]func() error
 }
 
 // Manager returns a new InterfaceManager.
@@ -81,21 +89,27 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, runner *state.T
 		// extras
 		extraInterfaces: extraInterfaces,
 		extraBackends:   extraBackends,
+
+		backendReloadFlushes: make(map[string][]func() error),
 	}
 
 	taskKinds := map[string]bool{}
 	addHandler := func(kind string, do, undo state.HandlerFunc) {
 		taskKinds[kind] = true
 		runner.AddHandler(kind, do, undo)
+		// the security backends are reloaded once for the whole change
+		runner.AddCleanup(kind, m.cleanupBackendReloads)
 	}
 
 	addHandler("connect", m.doConnect, m.undoConnect)
 	addHandler("disconnect", m.doDisconnect, m.undoDisconnect)
+	addHandler("update-connection-attrs", m.doUpdateConnectionAttrs, m.undoUpdateConnectionAttrs)
 	addHandler("setup-profiles", m.doSetupProfiles, m.undoSetupProfiles)
 	addHandler("remove-profiles", m.doRemoveProfiles, m.doSetupProfiles)
 	addHandler("discard-conns", m.doDiscardConns, m.undoDiscardConns)
 	addHandler("auto-connect", m.doAutoConnect, m.undoAutoConnect)
 	addHandler("gadget-connect", m.doGadgetConnect, nil)
+	addHandler("connect-provider", m.doConnectProvider, nil)
 	addHandler("auto-disconnect", m.doAutoDisconnect, nil)
 	addHandler("hotplug-add-slot", m.doHotplugAddSlot, nil)
 	addHandler("hotplug-connect", m.doHotplugConnect, nil)
@@ -186,7 +200,14 @@ func (m *InterfaceManager) Ensure() error {
 	udevMon := m.udevMon
 	m.udevMonMu.Unlock()
 	if udevMon != nil {
-		return nil
+		if !m.hotplugJustEnabled() {
+			return nil
+		}
+		// devices present when hotplug gets enabled were ignored,
+		// restart the monitor so that they are enumerated again
+		logger.Noticef("hotplug enabled, restarting udev monitor")
+		m.Stop()
+		m.resetHotplugDevices()
 	}
 
 	// don't initialize udev monitor until we have a system snap so that we
@@ -257,7 +278,13 @@ type ConnectionState struct {
 func (m *InterfaceManager) ConnectionStates() (connStateByRef map[string]ConnectionState, err error) {
 	m.state.Lock()
 	defer m.state.Unlock()
-	states, err := getConns(m.state)
+	return ConnectionStates(m.state)
+}
+
+// ConnectionStates returns the state of the connections stored in
+// the given state, the state must be locked by the caller.
+func ConnectionStates(st *state.State) (connStateByRef map[string]ConnectionState, err error) {
+	states, err := getConns(st)
 	if err != nil {
 		return nil, err
 	}
@@ -279,6 +306,25 @@ func (m *InterfaceManager) ConnectionStates() (connStateByRef map[string]Connect
 	return connStateByRef, nil
 }
 
+// InstalledProfiles returns the security profiles of the given snap as
+// currently installed by the security backends that can report them,
+// indexed by the name of the backend and the path of the profile.
+func (m *InterfaceManager) InstalledProfiles(snapName string) (map[interfaces.SecuritySystem]map[string]string, error) {
+	profiles := make(map[interfaces.SecuritySystem]map[string]string)
+	for _, backend := range m.repo.Backends() {
+		profilesBackend, ok := backend.(interfaces.SecurityBackendProfiles)
+		if !ok {
+			continue
+		}
+		backendProfiles, err := profilesBackend.InstalledProfiles(snapName)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s profiles of snap %q: %v", backend.Name(), snapName, err)
+		}
+		profiles[backend.Name()] = backendProfiles
+	}
+	return profiles, nil
+}
+
 // DisableUDevMonitor disables the instantiation of udev monitor, but has no effect
 // if udev is already created; it should be called after creating InterfaceManager, before
 // first Ensure.
@@ -296,7 +342,37 @@ var (
 	createUDevMonitor    = udevmonitor.New
 )
 
+// hotplugJustEnabled returns true if the hotplug feature was
+// enabled since the last check.
+func (m *InterfaceManager) hotplugJustEnabled() bool {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	enabled, err := m.hotplugEnabled()
+	if err != nil {
+		logger.Noticef("internal error: cannot get hotplug feature flag: %v", err)
+		return false
+	}
+	wasEnabled := m.hotplugWasEnabled
+	m.hotplugWasEnabled = enabled
+	return enabled && !wasEnabled
+}
+
+// resetHotplugDevices forgets the devices observed by the udev monitor
+// so that they are processed again on its next enumeration.
+func (m *InterfaceManager) resetHotplugDevices() {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	m.enumeratedDeviceKeys = make(map[string]map[snap.HotplugKey]bool)
+	m.enumerationDone = false
+	m.hotplugDevicePaths = make(map[string][]deviceData)
+}
+
 func (m *InterfaceManager) initUDevMonitor() error {
+	// remember the state of the feature the monitor is started with
+	m.hotplugJustEnabled()
+
 	mon := createUDevMonitor(m.hotplugDeviceAdded, m.hotplugDeviceRemoved, m.hotplugEnumerationDone)
 	if err := mon.Connect(); err != nil {
 		return err
@@ -22,15 +22,19 @@
 package ifacestate
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/policy"
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -39,6 +43,8 @@ import (
 
 var connectRetryTimeout = time.Second * 5
 
+var snapstateInstall = snapstate.Install
+
 // ErrAlreadyConnected describes the error that occurs when attempting to connect already connected interface.
 type ErrAlreadyConnected struct {
 	Connection interfaces.ConnRef
@@ -53,6 +59,15 @@ func (e ErrAlreadyConnected) Error() string {
 	return fmt.Sprintf("already connected: %q", e.Connection.ID())
 }
 
+// ErrNotConnected describes the error that occurs when attempting to update a connection that does not exist.
+type ErrNotConnected struct {
+	Connection interfaces.ConnRef
+}
+
+func (e ErrNotConnected) Error() string {
+	return fmt.Sprintf("not connected: %q", e.Connection.ID())
+}
+
 // findSymmetricAutoconnectTask checks if there is another auto-connect task affecting same snap because of plug/slot.
 func findSymmetricAutoconnectTask(st *state.State, plugSnap, slotSnap string, installTask *state.Task) (bool, error) {
 	snapsup, err := snapstate.TaskSnapSetup(installTask)
@@ -96,6 +111,78 @@ func Connect(st *state.State, plugSnap, plugName, slotSnap, slotName string) (*s
 	return connect(st, plugSnap, plugName, slotSnap, slotName, connectOpts{})
 }
 
+// ConnectInstallingProvider returns the task sets for connecting a
+// content plug to the slot of its default provider when that provider
+// is not installed yet. The provider is installed first and the
+// connection is made once the installation is done. It returns nil
+// task sets if the slot snap is installed, if the plug does not
+// declare slotSnap as its default-provider or if the
+// install-content-providers feature is disabled.
+func ConnectInstallingProvider(ctx context.Context, st *state.State, plugSnap, plugName, slotSnap, slotName string, userID int) ([]*state.TaskSet, error) {
+	tr := config.NewTransaction(st)
+	enabled, err := config.GetFeatureFlag(tr, features.InstallContentProviders)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	var slotSnapst snapstate.SnapState
+	err = snapstate.Get(st, slotSnap, &slotSnapst)
+	if err != state.ErrNoState {
+		// installed, or an error
+		return nil, err
+	}
+
+	var plugSnapst snapstate.SnapState
+	if err := snapstate.Get(st, plugSnap, &plugSnapst); err != nil {
+		if err == state.ErrNoState {
+			return nil, nil
+		}
+		return nil, err
+	}
+	plugSnapInfo, err := plugSnapst.CurrentInfo()
+	if err != nil {
+		return nil, err
+	}
+	plug := plugSnapInfo.Plugs[plugName]
+	if plug == nil || plug.Interface != "content" {
+		return nil, nil
+	}
+	var dprovider string
+	if err := plug.Attr("default-provider", &dprovider); err != nil || dprovider == "" {
+		return nil, nil
+	}
+	// the default-provider may also be given as "snapname:slotname"
+	providerSnap, providerSlot := dprovider, ""
+	if idx := strings.IndexRune(dprovider, ':'); idx >= 0 {
+		providerSnap, providerSlot = dprovider[:idx], dprovider[idx+1:]
+	}
+	if providerSnap != slotSnap {
+		return nil, nil
+	}
+	if slotName == "" {
+		slotName = providerSlot
+	}
+	if slotName == "" {
+		return nil, fmt.Errorf("cannot connect %s:%s to snap %q that is not installed, the slot name must be given", plugSnap, plugName, slotSnap)
+	}
+
+	if err := snapstate.CheckChangeConflict(st, plugSnap, nil); err != nil {
+		return nil, err
+	}
+
+	installTs, err := snapstateInstall(ctx, st, slotSnap, nil, userID, snapstate.Flags{})
+	if err != nil {
+		return nil, err
+	}
+
+	connectProvider := st.NewTask("connect-provider", fmt.Sprintf(i18n.G("Connect %s:%s to %s:%s"), plugSnap, plugName, slotSnap, slotName))
+	connectProvider.Set("slot", interfaces.SlotRef{Snap: slotSnap, Name: slotName})
+	connectProvider.Set("plug", interfaces.PlugRef{Snap: plugSnap, Name: plugName})
+	connectProvider.WaitAll(installTs)
+
+	return []*state.TaskSet{installTs, state.NewTaskSet(connectProvider)}, nil
+}
+
 func connect(st *state.State, plugSnap, plugName, slotSnap, slotName string, flags connectOpts) (*state.TaskSet, error) {
 	// TODO: Store the intent-to-connect in the state so that we automatically
 	// try to reconnect on reboot (reconnection can fail or can connect with
@@ -321,6 +408,38 @@ func Disconnect(st *state.State, conn *interfaces.Connection) (*state.TaskSet, e
 	return disconnectTasks(st, conn, disconnectOpts{})
 }
 
+// UpdateConnectionAttrs returns a set of tasks for updating the dynamic
+// attributes of an existing connection in place. The given attributes are
+// merged with the current dynamic attributes of the plug and of the slot,
+// and only the security profiles affected by the change are regenerated,
+// without disconnecting and reconnecting the plug and slot.
+func UpdateConnectionAttrs(st *state.State, plugSnap, plugName, slotSnap, slotName string, plugAttrs, slotAttrs map[string]interface{}) (*state.TaskSet, error) {
+	if err := snapstate.CheckChangeConflictMany(st, []string{plugSnap, slotSnap}, ""); err != nil {
+		return nil, err
+	}
+
+	conns, err := getConns(st)
+	if err != nil {
+		return nil, err
+	}
+	connRef := interfaces.ConnRef{PlugRef: interfaces.PlugRef{Snap: plugSnap, Name: plugName}, SlotRef: interfaces.SlotRef{Snap: slotSnap, Name: slotName}}
+	if conn, ok := conns[connRef.ID()]; !ok || conn.Undesired || conn.HotplugGone {
+		return nil, &ErrNotConnected{Connection: connRef}
+	}
+
+	return state.NewTaskSet(updateConnectionAttrsTask(st, &connRef, plugAttrs, slotAttrs)), nil
+}
+
+func updateConnectionAttrsTask(st *state.State, connRef *interfaces.ConnRef, plugAttrs, slotAttrs map[string]interface{}) *state.Task {
+	summary := fmt.Sprintf(i18n.G("Update attributes of connection %s:%s to %s:%s"), connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
+	task := st.NewTask("update-connection-attrs", summary)
+	task.Set("plug", connRef.PlugRef)
+	task.Set("slot", connRef.SlotRef)
+	task.Set("plug-attrs", plugAttrs)
+	task.Set("slot-attrs", slotAttrs)
+	return task
+}
+
 type disconnectOpts struct {
 	AutoDisconnect bool
 	ByHotplug      bool
@@ -492,6 +611,7 @@ func delayedCrossMgrInit() {
 		// hook into conflict checks mechanisms
 		snapstate.AddAffectedSnapsByKind("connect", connectDisconnectAffectedSnaps)
 		snapstate.AddAffectedSnapsByKind("disconnect", connectDisconnectAffectedSnaps)
+		snapstate.AddAffectedSnapsByKind("update-connection-attrs", connectDisconnectAffectedSnaps)
 	})
 }
 
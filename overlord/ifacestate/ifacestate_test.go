@@ -21,12 +21,15 @@ package ifacestate_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -39,10 +42,12 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/interfaces/policy"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/ifacestate/ifacerepo"
@@ -373,6 +378,128 @@ func (s *interfaceManagerSuite) TestConnectTask(c *C) {
 	c.Assert(err, ErrorMatches, `internal error: missing .* edge in task set`)
 }
 
+var contentConsumerYaml = `
+name: consumer
+version: 1
+plugs:
+ plug:
+  interface: content
+  content: foo
+  default-provider: producer
+ legacy-plug:
+  interface: content
+  content: bar
+  default-provider: producer:legacy-slot
+ other-plug:
+  interface: content
+  content: baz
+  default-provider: other-producer
+`
+
+func (s *interfaceManagerSuite) TestConnectInstallingProvider(c *C) {
+	s.mockSnap(c, contentConsumerYaml)
+	_ = s.manager(c)
+
+	var installs []string
+	restore := ifacestate.MockSnapstateInstall(func(ctx context.Context, st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		c.Check(userID, Equals, 42)
+		installs = append(installs, name)
+		return state.NewTaskSet(st.NewTask("fake-install-snap", "...")), nil
+	})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// disabled by default
+	tss, err := ifacestate.ConnectInstallingProvider(context.Background(), s.state, "consumer", "plug", "producer", "slot", 42)
+	c.Assert(err, IsNil)
+	c.Check(tss, IsNil)
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "experimental.install-content-providers", true)
+	tr.Commit()
+
+	tss, err = ifacestate.ConnectInstallingProvider(context.Background(), s.state, "consumer", "plug", "producer", "slot", 42)
+	c.Assert(err, IsNil)
+	c.Assert(tss, HasLen, 2)
+	c.Check(installs, DeepEquals, []string{"producer"})
+	c.Assert(tss[1].Tasks(), HasLen, 1)
+	task := tss[1].Tasks()[0]
+	c.Check(task.Kind(), Equals, "connect-provider")
+	c.Check(task.Summary(), Equals, "Connect consumer:plug to producer:slot")
+	c.Check(task.WaitTasks(), DeepEquals, tss[0].Tasks())
+	var plug interfaces.PlugRef
+	c.Assert(task.Get("plug", &plug), IsNil)
+	c.Check(plug, Equals, interfaces.PlugRef{Snap: "consumer", Name: "plug"})
+	var slot interfaces.SlotRef
+	c.Assert(task.Get("slot", &slot), IsNil)
+	c.Check(slot, Equals, interfaces.SlotRef{Snap: "producer", Name: "slot"})
+
+	// the slot name is taken from a legacy default-provider
+	tss, err = ifacestate.ConnectInstallingProvider(context.Background(), s.state, "consumer", "legacy-plug", "producer", "", 42)
+	c.Assert(err, IsNil)
+	c.Assert(tss, HasLen, 2)
+	c.Assert(tss[1].Tasks()[0].Get("slot", &slot), IsNil)
+	c.Check(slot, Equals, interfaces.SlotRef{Snap: "producer", Name: "legacy-slot"})
+
+	// but it must be known
+	_, err = ifacestate.ConnectInstallingProvider(context.Background(), s.state, "consumer", "plug", "producer", "", 42)
+	c.Check(err, ErrorMatches, `cannot connect consumer:plug to snap "producer" that is not installed, the slot name must be given`)
+
+	// not the default provider
+	tss, err = ifacestate.ConnectInstallingProvider(context.Background(), s.state, "consumer", "plug", "other-producer", "slot", 42)
+	c.Assert(err, IsNil)
+	c.Check(tss, IsNil)
+
+	// installed provider
+	s.state.Unlock()
+	s.mockSnap(c, producerYaml)
+	s.state.Lock()
+	tss, err = ifacestate.ConnectInstallingProvider(context.Background(), s.state, "consumer", "plug", "producer", "slot", 42)
+	c.Assert(err, IsNil)
+	c.Check(tss, IsNil)
+
+	c.Check(installs, DeepEquals, []string{"producer", "producer"})
+}
+
+func (s *interfaceManagerSuite) TestDoConnectProvider(c *C) {
+	s.MockModel(c, nil)
+
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	_ = s.manager(c)
+
+	s.state.Lock()
+	change := s.state.NewChange("kind", "summary")
+	task := s.state.NewTask("connect-provider", "")
+	task.Set("plug", interfaces.PlugRef{Snap: "consumer", Name: "plug"})
+	task.Set("slot", interfaces.SlotRef{Snap: "producer", Name: "slot"})
+	change.AddTask(task)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(change.Err(), IsNil)
+	c.Check(change.Status(), Equals, state.DoneStatus)
+	var kinds []string
+	for _, t := range change.Tasks() {
+		kinds = append(kinds, t.Kind())
+	}
+	c.Check(kinds, testutil.Contains, "connect")
+
+	repo := s.manager(c).Repository()
+	ifaces := repo.Interfaces()
+	c.Check(ifaces.Connections, DeepEquals, []*interfaces.ConnRef{{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}})
+}
+
 func (s *interfaceManagerSuite) TestConnectTasksDelayProfilesFlag(c *C) {
 	s.mockSnap(c, consumerYaml)
 	s.mockSnap(c, producerYaml)
@@ -1278,6 +1405,104 @@ func (s *interfaceManagerSuite) TestConnectTaskCheckAllowed(c *C) {
 	})
 }
 
+func (s *interfaceManagerSuite) TestConnectTaskCheckSlotArity(c *C) {
+	s.MockModel(c, nil)
+
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    allow-connection:
+      plugs-per-slot: 1
+`))
+	defer restore()
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"})
+
+	s.MockSnapDecl(c, "consumer", "one-publisher", nil)
+	s.mockSnap(c, consumerYaml)
+	s.MockSnapDecl(c, "consumer2", "one-publisher", nil)
+	s.mockSnap(c, consumer2Yaml)
+	s.MockSnapDecl(c, "producer", "one-publisher", nil)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer2:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	s.state.Unlock()
+
+	_ = s.manager(c)
+
+	s.state.Lock()
+	change := s.state.NewChange("kind", "summary")
+	ts, err := ifacestate.Connect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(change.Err(), ErrorMatches, `(?s).*cannot connect consumer:plug to producer:slot: the slot allows at most 1 connection\(s\) and is already connected to "consumer2:plug".*`)
+	c.Check(change.Status(), Equals, state.ErrorStatus)
+
+	repo := s.manager(c).Repository()
+	c.Check(repo.Interfaces().Connections, DeepEquals, []*interfaces.ConnRef{{
+		PlugRef: interfaces.PlugRef{Snap: "consumer2", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"}}})
+}
+
+func (s *interfaceManagerSuite) TestConnectTaskCheckSlotArityDangerousPlugSnap(c *C) {
+	s.MockModel(c, nil)
+
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    allow-connection:
+      plugs-per-slot: 1
+`))
+	defer restore()
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"})
+
+	// consumer was installed with "dangerous"
+	s.mockSnap(c, consumerYaml)
+	s.MockSnapDecl(c, "consumer2", "one-publisher", nil)
+	s.mockSnap(c, consumer2Yaml)
+	s.MockSnapDecl(c, "producer", "one-publisher", nil)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer2:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	s.state.Unlock()
+
+	_ = s.manager(c)
+
+	s.state.Lock()
+	change := s.state.NewChange("kind", "summary")
+	ts, err := ifacestate.Connect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// the arity of the slot is enforced from the slot side alone
+	c.Check(change.Err(), ErrorMatches, `(?s).*cannot connect consumer:plug to producer:slot: the slot allows at most 1 connection\(s\) and is already connected to "consumer2:plug".*`)
+	c.Check(change.Status(), Equals, state.ErrorStatus)
+}
+
 func (s *interfaceManagerSuite) testConnectTaskCheck(c *C, setup func(), check func(*state.Change)) {
 	restore := assertstest.MockBuiltinBaseDeclaration([]byte(`
 type: base-declaration
@@ -1499,23 +1724,302 @@ func (s *interfaceManagerSuite) TestDisconnectTask(c *C) {
 	c.Assert(slot.Snap, Equals, "producer")
 	c.Assert(slot.Name, Equals, "slot")
 
-	// verify connection attributes are present in the disconnect task
-	var plugStaticAttrs1, plugDynamicAttrs1, slotStaticAttrs1, slotDynamicAttrs1 map[string]interface{}
+	// verify connection attributes are present in the disconnect task
+	var plugStaticAttrs1, plugDynamicAttrs1, slotStaticAttrs1, slotDynamicAttrs1 map[string]interface{}
+
+	c.Assert(task.Get("plug-static", &plugStaticAttrs1), IsNil)
+	c.Assert(plugStaticAttrs1, DeepEquals, map[string]interface{}{"attr1": "value1"})
+	c.Assert(task.Get("plug-dynamic", &plugDynamicAttrs1), IsNil)
+	c.Assert(plugDynamicAttrs1, DeepEquals, map[string]interface{}{"attr3": "value3"})
+
+	c.Assert(task.Get("slot-static", &slotStaticAttrs1), IsNil)
+	c.Assert(slotStaticAttrs1, DeepEquals, map[string]interface{}{"attr2": "value2"})
+	c.Assert(task.Get("slot-dynamic", &slotDynamicAttrs1), IsNil)
+	c.Assert(slotDynamicAttrs1, DeepEquals, map[string]interface{}{"attr4": "value4"})
+}
+
+// Disconnect works when both plug and slot are specified
+func (s *interfaceManagerSuite) TestDisconnectFull(c *C) {
+	s.testDisconnect(c, "consumer", "plug", "producer", "slot")
+}
+
+func (s *interfaceManagerSuite) TestUpdateConnectionAttrs(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{
+		InterfaceName: "test",
+		TestConnectedPlugCallback: func(spec *ifacetest.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+			var path string
+			if err := slot.Attr("path", &path); err == nil {
+				spec.AddSnippet(path)
+			}
+			return nil
+		},
+	}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface":    "test",
+			"slot-dynamic": map[string]interface{}{"path": "/old"},
+		},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+
+	update := func(plugAttrs, slotAttrs map[string]interface{}) *state.Change {
+		s.state.Lock()
+		chg := s.state.NewChange("update-connection-attrs", "...")
+		ts, err := ifacestate.UpdateConnectionAttrs(s.state, "consumer", "plug", "producer", "slot", plugAttrs, slotAttrs)
+		c.Assert(err, IsNil)
+		chg.AddAll(ts)
+		s.state.Unlock()
+		s.settle(c)
+		return chg
+	}
+
+	// an attribute not affecting any security profile
+	chg := update(map[string]interface{}{"foo": "bar"}, nil)
+	s.state.Lock()
+	c.Assert(chg.Err(), IsNil)
+	c.Check(chg.Tasks()[0].Log()[0], Matches, `.* No security profiles affected by the change of attributes.`)
+	s.state.Unlock()
+	c.Check(s.secBackend.SetupCalls, HasLen, 0)
+
+	conn := s.getConnection(c, "consumer", "plug", "producer", "slot")
+	c.Check(conn.Plug.DynamicAttrs(), DeepEquals, map[string]interface{}{"foo": "bar"})
+
+	// an attribute affecting the profiles
+	chg = update(nil, map[string]interface{}{"path": "/new"})
+	s.state.Lock()
+	c.Assert(chg.Err(), IsNil)
+	s.state.Unlock()
+	c.Assert(s.secBackend.SetupCalls, HasLen, 2)
+	c.Check(s.secBackend.SetupCalls[0].SnapInfo.InstanceName(), Equals, "consumer")
+	c.Check(s.secBackend.SetupCalls[1].SnapInfo.InstanceName(), Equals, "producer")
+	c.Check(s.secBackend.RemoveCalls, HasLen, 0)
+
+	conn = s.getConnection(c, "consumer", "plug", "producer", "slot")
+	c.Check(conn.Slot.DynamicAttrs(), DeepEquals, map[string]interface{}{"path": "/new"})
+	c.Check(mgr.Repository().Interfaces().Connections, HasLen, 1)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface":    "test",
+			"plug-dynamic": map[string]interface{}{"foo": "bar"},
+			"slot-dynamic": map[string]interface{}{"path": "/new"},
+		},
+	})
+}
+
+func (s *interfaceManagerSuite) TestUpdateConnectionAttrsUndo(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface":    "test",
+			"plug-dynamic": map[string]interface{}{"foo": "old"},
+		},
+	})
+	s.state.Unlock()
+
+	s.manager(c)
+
+	s.state.Lock()
+	chg := s.state.NewChange("update-connection-attrs", "...")
+	ts, err := ifacestate.UpdateConnectionAttrs(s.state, "consumer", "plug", "producer", "slot", map[string]interface{}{"foo": "new", "bar": "baz"}, nil)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitAll(ts)
+	chg.AddTask(terr)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Tasks()[0].Status(), Equals, state.UndoneStatus)
+	s.state.Unlock()
+
+	conn := s.getConnection(c, "consumer", "plug", "producer", "slot")
+	c.Check(conn.Plug.DynamicAttrs(), DeepEquals, map[string]interface{}{"foo": "old"})
+}
+
+func (s *interfaceManagerSuite) TestUpdateConnectionAttrsSetupErrorRestoresConnection(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{
+		InterfaceName: "test",
+		TestConnectedPlugCallback: func(spec *ifacetest.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+			var path string
+			if err := slot.Attr("path", &path); err == nil {
+				spec.AddSnippet(path)
+			}
+			return nil
+		},
+	}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface":    "test",
+			"slot-dynamic": map[string]interface{}{"path": "/old"},
+		},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+
+	var setupPaths []string
+	s.secBackend.SetupCallback = func(snapInfo *snap.Info, opts interfaces.ConfinementOptions, repo *interfaces.Repository) error {
+		conn, err := repo.Connection(&interfaces.ConnRef{
+			PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+			SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		})
+		c.Assert(err, IsNil)
+		path := conn.Slot.DynamicAttrs()["path"].(string)
+		setupPaths = append(setupPaths, snapInfo.InstanceName()+":"+path)
+		if snapInfo.InstanceName() == "producer" && path == "/new" {
+			return fmt.Errorf("boom")
+		}
+		return nil
+	}
+
+	s.state.Lock()
+	chg := s.state.NewChange("update-connection-attrs", "...")
+	ts, err := ifacestate.UpdateConnectionAttrs(s.state, "consumer", "plug", "producer", "slot", nil, map[string]interface{}{"path": "/new"})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	c.Check(chg.Err(), ErrorMatches, `(?s).*boom.*`)
+	s.state.Unlock()
+
+	// the profiles of the snaps set up with the new attributes were
+	// regenerated with the old ones
+	c.Check(setupPaths, DeepEquals, []string{"consumer:/new", "producer:/new", "consumer:/old", "producer:/old"})
+
+	conn := s.getConnection(c, "consumer", "plug", "producer", "slot")
+	c.Check(conn.Slot.DynamicAttrs(), DeepEquals, map[string]interface{}{"path": "/old"})
+	c.Check(mgr.Repository().Interfaces().Connections, HasLen, 1)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface":    "test",
+			"slot-dynamic": map[string]interface{}{"path": "/old"},
+		},
+	})
+}
+
+func (s *interfaceManagerSuite) TestAutoConnectUpdatesStaleContentSource(c *C) {
+	s.MockModel(c, nil)
+
+	const consumerYaml = `
+name: consumer
+version: 1
+plugs:
+ plug:
+  interface: content
+  content: foo
+  target: $SNAP/foo
+`
+	const producerV1Yaml = `
+name: producer
+version: 1
+slots:
+ slot:
+  interface: content
+  content: foo
+`
+	const producerV2Yaml = `
+name: producer
+version: 2
+slots:
+ slot:
+  interface: content
+  content: foo
+  read: [$SNAP/v2]
+`
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerV1Yaml)
+	snaptest.MockSnapInstance(c, "", producerV2Yaml, &snap.SideInfo{Revision: snap.R(2)})
+
+	// the source of the content was set by the interface hooks of the
+	// first revision, the second one declares it
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface":    "content",
+			"slot-dynamic": map[string]interface{}{"read": []interface{}{"$SNAP/v1"}},
+		},
+	})
+	s.state.Unlock()
+
+	s.manager(c)
+
+	s.state.Lock()
+	snapstate.Set(s.state, "producer", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "producer", Revision: snap.R(1)}, {RealName: "producer", Revision: snap.R(2)}},
+		Current:  snap.R(2),
+		SnapType: "app",
+	})
+	s.state.Unlock()
+
+	chg := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: "producer", Revision: snap.R(2)},
+	})
+	s.settle(c)
+
+	s.state.Lock()
+	c.Assert(chg.Err(), IsNil)
+	var kinds []string
+	for _, t := range chg.Tasks() {
+		kinds = append(kinds, t.Kind())
+	}
+	c.Check(kinds, DeepEquals, []string{"setup-profiles", "auto-connect", "update-connection-attrs"})
+	s.state.Unlock()
+
+	conn := s.getConnection(c, "consumer", "plug", "producer", "slot")
+	c.Check(conn.Slot.DynamicAttrs(), DeepEquals, map[string]interface{}{})
+	var read []interface{}
+	c.Assert(conn.Slot.Attr("read", &read), IsNil)
+	c.Check(read, DeepEquals, []interface{}{"$SNAP/v2"})
+}
 
-	c.Assert(task.Get("plug-static", &plugStaticAttrs1), IsNil)
-	c.Assert(plugStaticAttrs1, DeepEquals, map[string]interface{}{"attr1": "value1"})
-	c.Assert(task.Get("plug-dynamic", &plugDynamicAttrs1), IsNil)
-	c.Assert(plugDynamicAttrs1, DeepEquals, map[string]interface{}{"attr3": "value3"})
+func (s *interfaceManagerSuite) TestUpdateConnectionAttrsErrors(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	s.manager(c)
 
-	c.Assert(task.Get("slot-static", &slotStaticAttrs1), IsNil)
-	c.Assert(slotStaticAttrs1, DeepEquals, map[string]interface{}{"attr2": "value2"})
-	c.Assert(task.Get("slot-dynamic", &slotDynamicAttrs1), IsNil)
-	c.Assert(slotDynamicAttrs1, DeepEquals, map[string]interface{}{"attr4": "value4"})
-}
+	s.state.Lock()
+	defer s.state.Unlock()
 
-// Disconnect works when both plug and slot are specified
-func (s *interfaceManagerSuite) TestDisconnectFull(c *C) {
-	s.testDisconnect(c, "consumer", "plug", "producer", "slot")
+	_, err := ifacestate.UpdateConnectionAttrs(s.state, "consumer", "plug", "producer", "slot", map[string]interface{}{"foo": "bar"}, nil)
+	c.Check(err, ErrorMatches, `not connected: "consumer:plug producer:slot"`)
+
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "undesired": true},
+	})
+	_, err = ifacestate.UpdateConnectionAttrs(s.state, "consumer", "plug", "producer", "slot", map[string]interface{}{"foo": "bar"}, nil)
+	c.Check(err, ErrorMatches, `not connected: "consumer:plug producer:slot"`)
 }
 
 func (s *interfaceManagerSuite) getConnection(c *C, plugSnap, plugName, slotSnap, slotName string) *interfaces.Connection {
@@ -2327,6 +2831,131 @@ slots:
 	check(conns, repo.Interfaces().Connections)
 }
 
+func (s *interfaceManagerSuite) TestAutoConnectCheckerCachesPolicy(c *C) {
+	s.MockModel(c, nil)
+
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    allow-auto-connection:
+      plug-publisher-id:
+        - $SLOT_PUBLISHER_ID
+`))
+	defer restore()
+
+	checks := 0
+	restore = ifacestate.MockCheckAutoConnectPolicy(func(ic *policy.ConnectCandidate) *policy.ConnectCandidateArity {
+		checks++
+		arity, err := ic.CheckAutoConnectWithArity()
+		if err != nil {
+			return nil
+		}
+		return arity
+	})
+	defer restore()
+
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.MockSnapDecl(c, "producer", "one-publisher", nil)
+	s.MockSnapDecl(c, "consumer", "one-publisher", nil)
+	producer := s.mockSnap(c, producerYaml)
+	consumer := s.mockSnap(c, consumerYaml)
+	_ = s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	deviceCtx, err := snapstate.DeviceCtx(s.state, nil, nil)
+	c.Assert(err, IsNil)
+
+	plug := interfaces.NewConnectedPlug(consumer.Plugs["plug"], nil, nil)
+	slot := interfaces.NewConnectedSlot(producer.Slots["slot"], nil, nil)
+
+	checkAutoConnect := func() bool {
+		check, err := ifacestate.NewAutoConnectChecker(s.state, deviceCtx)
+		c.Assert(err, IsNil)
+		ok, err := check(plug, slot)
+		c.Assert(err, IsNil)
+		return ok
+	}
+
+	c.Check(checkAutoConnect(), Equals, true)
+	c.Check(checks, Equals, 1)
+
+	// the decision is remembered across checkers
+	c.Check(checkAutoConnect(), Equals, true)
+	c.Check(checks, Equals, 1)
+
+	// a new revision of a snap declaration is checked again
+	s.MockSnapDecl(c, "consumer", "other-publisher", map[string]interface{}{
+		"revision": "1",
+	})
+	c.Check(checkAutoConnect(), Equals, false)
+	c.Check(checks, Equals, 2)
+	c.Check(checkAutoConnect(), Equals, false)
+	c.Check(checks, Equals, 2)
+
+	// so is a new revision of the snap
+	consumer.Revision = snap.R(2)
+	c.Check(checkAutoConnect(), Equals, false)
+	c.Check(checks, Equals, 3)
+
+	// decisions involving dynamic attributes are not cached
+	plug = interfaces.NewConnectedPlug(consumer.Plugs["plug"], nil, map[string]interface{}{"dynamic": "value"})
+	c.Check(checkAutoConnect(), Equals, false)
+	c.Check(checkAutoConnect(), Equals, false)
+	c.Check(checks, Equals, 5)
+}
+
+// The auto-connect task will not connect more plugs to a slot than
+// allowed by the slot rule.
+func (s *interfaceManagerSuite) TestDoSetupSnapSecurityAutoConnectsSlotArity(c *C) {
+	s.MockModel(c, nil)
+
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    allow-auto-connection:
+      plugs-per-slot: 1
+`))
+	defer restore()
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.MockSnapDecl(c, "consumer", "one-publisher", nil)
+	s.mockSnap(c, consumerYaml)
+	s.MockSnapDecl(c, "consumer2", "one-publisher", nil)
+	s.mockSnap(c, consumer2Yaml)
+
+	mgr := s.manager(c)
+
+	s.MockSnapDecl(c, "producer", "one-publisher", nil)
+	snapInfo := s.mockSnap(c, producerYaml)
+
+	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: snapInfo.SnapName(),
+			SnapID:   snapInfo.SnapID,
+			Revision: snapInfo.Revision,
+		},
+	})
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(change.Status(), Equals, state.DoneStatus)
+
+	// only one of the candidate plugs was connected
+	c.Check(mgr.Repository().Interfaces().Connections, HasLen, 1)
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, HasLen, 1)
+}
+
 // The auto-connect task will check snap declarations providing the
 // model assertion to fulfill device scope constraints: here no store
 // in the model assertion fails an on-store constraint.
@@ -3135,6 +3764,189 @@ func (s *interfaceManagerSuite) TestSetupProfilesUsesFreshSnapInfo(c *C) {
 	c.Check(s.secBackend.SetupCalls[1].SnapInfo.Revision, Equals, coreSnapInfo.Revision)
 }
 
+// setup-profiles sets up the affected snaps concurrently with backends
+// supporting it, all of them are set up even if some fail.
+func (s *interfaceManagerSuite) TestSetupProfilesConcurrentBackend(c *C) {
+	s.MockModel(c, nil)
+
+	var mu sync.Mutex
+	var setups []string
+	backend := &ifacetest.TestSecurityBackendConcurrentSetup{
+		TestSecurityBackend: ifacetest.TestSecurityBackend{
+			BackendName: "concurrent",
+			SetupCallback: func(snapInfo *snap.Info, opts interfaces.ConfinementOptions, repo *interfaces.Repository) error {
+				mu.Lock()
+				defer mu.Unlock()
+				setups = append(setups, snapInfo.InstanceName())
+				if snapInfo.InstanceName() == "consumer" {
+					return fmt.Errorf("boom")
+				}
+				return nil
+			},
+		},
+	}
+	s.mockSecBackend(c, backend)
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"})
+
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, consumer2Yaml)
+	producer := s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot":  map[string]interface{}{"interface": "test"},
+		"consumer2:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	s.state.Unlock()
+
+	_ = s.manager(c)
+
+	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: producer.SnapName(),
+			Revision: producer.Revision,
+		},
+	})
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(change.Status(), Equals, state.ErrorStatus)
+	c.Check(change.Err(), ErrorMatches, `(?s).*boom.*`)
+
+	// all the affected snaps were set up by the concurrent backend
+	// despite the failure, before the undo set them up again
+	c.Assert(len(setups) >= 3, Equals, true)
+	done := setups[:3]
+	sort.Strings(done)
+	c.Check(done, DeepEquals, []string{"consumer", "consumer2", "producer"})
+	c.Check(strings.Join(change.Tasks()[0].Log(), "\n"), Matches, `(?s).* ERROR cannot setup concurrent for snap "consumer": boom.*`)
+}
+
+func (s *interfaceManagerSuite) TestSetupProfilesSetupManyBackend(c *C) {
+	s.MockModel(c, nil)
+
+	devMode := make(map[string]bool)
+	backend := &ifacetest.TestSecurityBackendSetupMany{
+		TestSecurityBackend: ifacetest.TestSecurityBackend{BackendName: "many"},
+		SetupManyCallback: func(snaps []*snap.Info, confinement func(snapName string) interfaces.ConfinementOptions, repo *interfaces.Repository, tm timings.Measurer) []error {
+			for _, snapInfo := range snaps {
+				devMode[snapInfo.InstanceName()] = confinement(snapInfo.InstanceName()).DevMode
+			}
+			return nil
+		},
+	}
+	s.mockSecBackend(c, backend)
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"})
+
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, consumer2Yaml)
+	producer := s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot":  map[string]interface{}{"interface": "test"},
+		"consumer2:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	s.state.Unlock()
+
+	_ = s.manager(c)
+	backend.SetupManyCalls = nil
+
+	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: producer.SnapName(),
+			Revision: producer.Revision,
+		},
+		Flags: snapstate.Flags{DevMode: true},
+	})
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(change.Status(), Equals, state.DoneStatus)
+
+	// all the affected snaps were set up in a single batch
+	c.Check(backend.SetupCalls, HasLen, 0)
+	c.Assert(backend.SetupManyCalls, HasLen, 1)
+	var names []string
+	for _, snapInfo := range backend.SetupManyCalls[0].SnapInfos {
+		names = append(names, snapInfo.InstanceName())
+	}
+	sort.Strings(names)
+	c.Check(names, DeepEquals, []string{"consumer", "consumer2", "producer"})
+	// each snap got its own confinement options
+	c.Check(devMode, DeepEquals, map[string]bool{"consumer": false, "consumer2": false, "producer": true})
+}
+
+func (s *interfaceManagerSuite) TestSetupProfilesDeferReloadBackend(c *C) {
+	s.MockModel(c, nil)
+
+	backend := &ifacetest.TestSecurityBackendDeferReload{
+		TestSecurityBackend: ifacetest.TestSecurityBackend{BackendName: "deferred"},
+	}
+	s.mockSecBackend(c, backend)
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"})
+
+	s.mockSnap(c, consumerYaml)
+	producer := s.mockSnap(c, producerYaml)
+
+	_ = s.manager(c)
+
+	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: producer.SnapName(),
+			Revision: producer.Revision,
+		},
+	})
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(change.Err(), IsNil)
+	c.Check(change.Status(), Equals, state.DoneStatus)
+
+	// the snaps were set up by both tasks under a single deferral
+	c.Check(len(backend.SetupCalls) > 1, Equals, true)
+	c.Check(backend.DeferReloadCalls, Equals, 1)
+	c.Check(backend.FlushCalls, Equals, 1)
+	c.Check(backend.ReloadCalls, Equals, 0)
+
+	var deferred bool
+	c.Assert(change.Get("security-backends-reload-deferred", &deferred), IsNil)
+	c.Check(deferred, Equals, false)
+}
+
+func (s *interfaceManagerSuite) TestDeferredReloadAfterRestart(c *C) {
+	backend := &ifacetest.TestSecurityBackendDeferReload{
+		TestSecurityBackend: ifacetest.TestSecurityBackend{BackendName: "deferred"},
+	}
+	s.mockSecBackend(c, backend)
+
+	_ = s.manager(c)
+
+	s.state.Lock()
+	// the deferral was recorded by a previous snapd process
+	change := s.state.NewChange("test", "")
+	task := s.state.NewTask("setup-profiles", "")
+	change.AddTask(task)
+	task.SetStatus(state.DoneStatus)
+	change.Set("security-backends-reload-deferred", true)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(task.IsClean(), Equals, true)
+	c.Check(backend.FlushCalls, Equals, 0)
+	c.Check(backend.ReloadCalls, Equals, 1)
+}
+
 // auto-connect needs to setup security for connected slots after autoconnection
 func (s *interfaceManagerSuite) TestAutoConnectSetupSecurityForConnectedSlots(c *C) {
 	s.MockModel(c, nil)
@@ -5624,6 +6436,68 @@ func (s *interfaceManagerSuite) TestUDevMonitorInit(c *C) {
 	c.Assert(u.StopCalls, Equals, 1)
 }
 
+func (s *interfaceManagerSuite) TestUDevMonitorRestartedWhenHotplugEnabled(c *C) {
+	var monitors []*udevMonitorMock
+	st := s.state
+	st.Lock()
+	snapstate.Set(s.state, "core", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "core", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "os",
+	})
+	st.Unlock()
+
+	restoreTimeout := ifacestate.MockUDevInitRetryTimeout(0 * time.Second)
+	defer restoreTimeout()
+
+	restoreCreate := ifacestate.MockCreateUDevMonitor(func(udevmonitor.DeviceAddedFunc, udevmonitor.DeviceRemovedFunc, udevmonitor.EnumerationDoneFunc) udevmonitor.Interface {
+		u := &udevMonitorMock{}
+		monitors = append(monitors, u)
+		return u
+	})
+	defer restoreCreate()
+
+	mgr, err := ifacestate.Manager(s.state, nil, s.o.TaskRunner(), nil, nil)
+	c.Assert(err, IsNil)
+	s.o.AddManager(mgr)
+	c.Assert(s.o.StartUp(), IsNil)
+
+	for i := 0; i < 3; i++ {
+		c.Assert(s.se.Ensure(), IsNil)
+	}
+	c.Assert(monitors, HasLen, 1)
+
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "experimental.hotplug", true)
+	tr.Commit()
+	st.Unlock()
+
+	// the monitor is restarted to enumerate the existing devices
+	for i := 0; i < 3; i++ {
+		c.Assert(s.se.Ensure(), IsNil)
+	}
+	c.Assert(monitors, HasLen, 2)
+	c.Check(monitors[0].StopCalls, Equals, 1)
+	c.Check(monitors[1].ConnectCalls, Equals, 1)
+	c.Check(monitors[1].RunCalls, Equals, 1)
+
+	// disabling hotplug does not restart the monitor
+	st.Lock()
+	tr = config.NewTransaction(st)
+	tr.Set("core", "experimental.hotplug", false)
+	tr.Commit()
+	st.Unlock()
+	c.Assert(s.se.Ensure(), IsNil)
+	c.Assert(monitors, HasLen, 2)
+
+	s.se.Stop()
+	c.Check(monitors[1].StopCalls, Equals, 1)
+}
+
 func (s *interfaceManagerSuite) TestUDevMonitorInitErrors(c *C) {
 	u := udevMonitorMock{
 		ConnectError: fmt.Errorf("Connect failed"),
@@ -0,0 +1,254 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/interfaces/prompting"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// ErrNoPromptRequest is returned when replying to a prompt request
+// that does not exist or is not visible to the user.
+var ErrNoPromptRequest = errors.New("no such prompt request")
+
+// PromptReplyHook is called, with the state locked, once a prompt
+// request was replied to, so that the outcome can be delivered to
+// whatever is holding the denied access.
+type PromptReplyHook func(st *state.State, req *prompting.Request, outcome prompting.Outcome)
+
+var (
+	promptReplyHooksMu sync.Mutex
+	promptReplyHooks   []PromptReplyHook
+)
+
+// AddPromptReplyHook registers a hook called whenever a prompt request
+// is replied to.
+func AddPromptReplyHook(hook PromptReplyHook) {
+	promptReplyHooksMu.Lock()
+	defer promptReplyHooksMu.Unlock()
+	promptReplyHooks = append(promptReplyHooks, hook)
+}
+
+func runPromptReplyHooks(st *state.State, req *prompting.Request, outcome prompting.Outcome) {
+	promptReplyHooksMu.Lock()
+	hooks := append([]PromptReplyHook(nil), promptReplyHooks...)
+	promptReplyHooksMu.Unlock()
+	for _, hook := range hooks {
+		hook(st, req, outcome)
+	}
+}
+
+func checkPromptingEnabled(st *state.State) error {
+	enabled, err := config.GetFeatureFlag(config.NewTransaction(st), features.AppArmorPrompting)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		_, flag := features.AppArmorPrompting.ConfigOption()
+		return fmt.Errorf("experimental feature disabled - test it by setting '%s' to true", flag)
+	}
+	return nil
+}
+
+func getPromptRequests(st *state.State) (map[string]*prompting.Request, error) {
+	var requests map[string]*prompting.Request
+	err := st.Get("prompt-requests", &requests)
+	if err != nil && err != state.ErrNoState {
+		return nil, fmt.Errorf("cannot obtain prompt requests: %v", err)
+	}
+	if requests == nil {
+		requests = make(map[string]*prompting.Request)
+	}
+	return requests, nil
+}
+
+func getPromptRules(st *state.State) ([]*prompting.Rule, error) {
+	var rules []*prompting.Rule
+	err := st.Get("prompt-rules", &rules)
+	if err != nil && err != state.ErrNoState {
+		return nil, fmt.Errorf("cannot obtain prompt rules: %v", err)
+	}
+	return rules, nil
+}
+
+var timeNow = time.Now
+
+// AddPromptRequest records a request for permission made on behalf of
+// a snap that was denied access. If a rule recorded from an earlier
+// reply applies to the request its outcome is returned with decided
+// set, otherwise the request is kept pending until replied to with
+// ReplyToPrompt and its ID is set.
+func AddPromptRequest(st *state.State, req *prompting.Request) (outcome prompting.Outcome, decided bool, err error) {
+	if err := checkPromptingEnabled(st); err != nil {
+		return "", false, err
+	}
+	if err := req.Validate(); err != nil {
+		return "", false, err
+	}
+
+	rules, err := getPromptRules(st)
+	if err != nil {
+		return "", false, err
+	}
+	for _, rule := range rules {
+		if rule.Matches(req) {
+			return rule.Outcome, true, nil
+		}
+	}
+
+	requests, err := getPromptRequests(st)
+	if err != nil {
+		return "", false, err
+	}
+	var lastID int
+	if err := st.Get("last-prompt-request-id", &lastID); err != nil && err != state.ErrNoState {
+		return "", false, err
+	}
+	lastID++
+	st.Set("last-prompt-request-id", lastID)
+
+	req.ID = strconv.Itoa(lastID)
+	req.Timestamp = timeNow()
+	requests[req.ID] = req
+	st.Set("prompt-requests", requests)
+	return "", false, nil
+}
+
+// PromptRequests returns the pending prompt requests of the given user,
+// oldest first.
+func PromptRequests(st *state.State, uid uint32) ([]*prompting.Request, error) {
+	if err := checkPromptingEnabled(st); err != nil {
+		return nil, err
+	}
+	requests, err := getPromptRequests(st)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*prompting.Request, 0, len(requests))
+	for _, req := range requests {
+		if req.UID == uid {
+			result = append(result, req)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, _ := strconv.Atoi(result[i].ID)
+		b, _ := strconv.Atoi(result[j].ID)
+		return a < b
+	})
+	return result, nil
+}
+
+// PromptRequest returns the pending prompt request of the given user
+// with the given ID.
+func PromptRequest(st *state.State, uid uint32, id string) (*prompting.Request, error) {
+	if err := checkPromptingEnabled(st); err != nil {
+		return nil, err
+	}
+	requests, err := getPromptRequests(st)
+	if err != nil {
+		return nil, err
+	}
+	req := requests[id]
+	if req == nil || req.UID != uid {
+		return nil, ErrNoPromptRequest
+	}
+	return req, nil
+}
+
+// RemovePromptRequest drops the pending prompt request with the given ID
+// without replying to it, once nothing is waiting for its reply anymore.
+// It does nothing if the request was already replied to.
+func RemovePromptRequest(st *state.State, id string) error {
+	requests, err := getPromptRequests(st)
+	if err != nil {
+		return err
+	}
+	if _, ok := requests[id]; !ok {
+		return nil
+	}
+	delete(requests, id)
+	st.Set("prompt-requests", requests)
+	return nil
+}
+
+// ReplyToPrompt replies to the pending prompt request of the given user
+// with the given ID. With a "forever" lifespan the outcome is also
+// recorded as a rule applied to later requests of the snap for the same
+// path, and the other pending requests it covers are replied to as
+// well.
+func ReplyToPrompt(st *state.State, uid uint32, id string, outcome prompting.Outcome, lifespan prompting.Lifespan) error {
+	if err := prompting.ValidateOutcome(outcome); err != nil {
+		return err
+	}
+	if err := prompting.ValidateLifespan(lifespan); err != nil {
+		return err
+	}
+	req, err := PromptRequest(st, uid, id)
+	if err != nil {
+		return err
+	}
+	requests, err := getPromptRequests(st)
+	if err != nil {
+		return err
+	}
+
+	replied := []*prompting.Request{req}
+	delete(requests, req.ID)
+
+	if lifespan == prompting.LifespanForever {
+		rules, err := getPromptRules(st)
+		if err != nil {
+			return err
+		}
+		rule := &prompting.Rule{
+			UID:         req.UID,
+			Snap:        req.Snap,
+			Interface:   req.Interface,
+			PathPattern: req.Path,
+			Permissions: req.Permissions,
+			Outcome:     outcome,
+		}
+		st.Set("prompt-rules", append(rules, rule))
+
+		for otherID, other := range requests {
+			if rule.Matches(other) {
+				replied = append(replied, other)
+				delete(requests, otherID)
+			}
+		}
+	}
+	st.Set("prompt-requests", requests)
+
+	for _, r := range replied {
+		logger.Debugf("prompt request %s of snap %q for %q replied with %q", r.ID, r.Snap, r.Interface, outcome)
+		runPromptReplyHooks(st, r, outcome)
+	}
+	return nil
+}
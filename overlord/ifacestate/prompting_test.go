@@ -0,0 +1,227 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/prompting"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type promptingSuite struct {
+	st *state.State
+
+	restore []func()
+}
+
+var _ = Suite(&promptingSuite{})
+
+func (s *promptingSuite) SetUpTest(c *C) {
+	s.st = state.New(nil)
+	s.restore = []func(){
+		ifacestate.MockPromptReplyHooks(),
+		ifacestate.MockTimeNow(func() time.Time {
+			return time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+		}),
+	}
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	tr := config.NewTransaction(s.st)
+	tr.Set("core", "experimental.apparmor-prompting", true)
+	tr.Commit()
+}
+
+func (s *promptingSuite) TearDownTest(c *C) {
+	for _, restore := range s.restore {
+		restore()
+	}
+}
+
+func (s *promptingSuite) homeRequest(uid uint32, path string, perms ...string) *prompting.Request {
+	return &prompting.Request{
+		UID:         uid,
+		Snap:        "foo",
+		App:         "app",
+		Interface:   "home",
+		Path:        path,
+		Permissions: perms,
+	}
+}
+
+func (s *promptingSuite) TestPromptingDisabled(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	tr := config.NewTransaction(s.st)
+	tr.Set("core", "experimental.apparmor-prompting", false)
+	tr.Commit()
+
+	_, _, err := ifacestate.AddPromptRequest(s.st, s.homeRequest(1000, "/home/user/doc.txt", "read"))
+	c.Check(err, ErrorMatches, `experimental feature disabled - test it by setting 'experimental.apparmor-prompting' to true`)
+	_, err = ifacestate.PromptRequests(s.st, 1000)
+	c.Check(err, ErrorMatches, `experimental feature disabled - .*`)
+}
+
+func (s *promptingSuite) TestAddPromptRequest(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	req1 := s.homeRequest(1000, "/home/user/doc.txt", "read")
+	_, decided, err := ifacestate.AddPromptRequest(s.st, req1)
+	c.Assert(err, IsNil)
+	c.Check(decided, Equals, false)
+	c.Check(req1.ID, Equals, "1")
+	c.Check(req1.Timestamp.Equal(time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)), Equals, true)
+
+	req2 := s.homeRequest(1001, "/home/other/doc.txt", "read")
+	_, _, err = ifacestate.AddPromptRequest(s.st, req2)
+	c.Assert(err, IsNil)
+	req3 := s.homeRequest(1000, "/home/user/pic.png", "read", "write")
+	_, _, err = ifacestate.AddPromptRequest(s.st, req3)
+	c.Assert(err, IsNil)
+
+	reqs, err := ifacestate.PromptRequests(s.st, 1000)
+	c.Assert(err, IsNil)
+	c.Assert(reqs, HasLen, 2)
+	c.Check(reqs[0].ID, Equals, "1")
+	c.Check(reqs[1].ID, Equals, "3")
+	c.Check(reqs[1].Permissions, DeepEquals, []string{"read", "write"})
+
+	req, err := ifacestate.PromptRequest(s.st, 1001, "2")
+	c.Assert(err, IsNil)
+	c.Check(req.Path, Equals, "/home/other/doc.txt")
+	// requests of other users are not visible
+	_, err = ifacestate.PromptRequest(s.st, 1000, "2")
+	c.Check(err, Equals, ifacestate.ErrNoPromptRequest)
+
+	// unsupported interfaces cannot be prompted for
+	_, _, err = ifacestate.AddPromptRequest(s.st, &prompting.Request{
+		UID:         1000,
+		Snap:        "foo",
+		Interface:   "network",
+		Permissions: []string{"access"},
+	})
+	c.Check(err, ErrorMatches, `cannot prompt for interface "network"`)
+}
+
+func (s *promptingSuite) TestReplyToPromptSingle(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	var replies []string
+	ifacestate.AddPromptReplyHook(func(st *state.State, req *prompting.Request, outcome prompting.Outcome) {
+		replies = append(replies, req.ID+":"+string(outcome))
+	})
+
+	_, _, err := ifacestate.AddPromptRequest(s.st, s.homeRequest(1000, "/home/user/doc.txt", "read"))
+	c.Assert(err, IsNil)
+
+	err = ifacestate.ReplyToPrompt(s.st, 1001, "1", prompting.OutcomeAllow, prompting.LifespanSingle)
+	c.Check(err, Equals, ifacestate.ErrNoPromptRequest)
+	err = ifacestate.ReplyToPrompt(s.st, 1000, "1", "maybe", prompting.LifespanSingle)
+	c.Check(err, ErrorMatches, `invalid prompt outcome "maybe"`)
+	err = ifacestate.ReplyToPrompt(s.st, 1000, "1", prompting.OutcomeAllow, "session")
+	c.Check(err, ErrorMatches, `invalid prompt lifespan "session"`)
+	c.Check(replies, HasLen, 0)
+
+	err = ifacestate.ReplyToPrompt(s.st, 1000, "1", prompting.OutcomeAllow, prompting.LifespanSingle)
+	c.Assert(err, IsNil)
+	c.Check(replies, DeepEquals, []string{"1:allow"})
+
+	reqs, err := ifacestate.PromptRequests(s.st, 1000)
+	c.Assert(err, IsNil)
+	c.Check(reqs, HasLen, 0)
+
+	// the same access prompts again
+	_, decided, err := ifacestate.AddPromptRequest(s.st, s.homeRequest(1000, "/home/user/doc.txt", "read"))
+	c.Assert(err, IsNil)
+	c.Check(decided, Equals, false)
+}
+
+func (s *promptingSuite) TestRemovePromptRequest(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	var replies []string
+	ifacestate.AddPromptReplyHook(func(st *state.State, req *prompting.Request, outcome prompting.Outcome) {
+		replies = append(replies, req.ID+":"+string(outcome))
+	})
+
+	_, _, err := ifacestate.AddPromptRequest(s.st, s.homeRequest(1000, "/home/user/doc.txt", "read"))
+	c.Assert(err, IsNil)
+
+	c.Assert(ifacestate.RemovePromptRequest(s.st, "1"), IsNil)
+	reqs, err := ifacestate.PromptRequests(s.st, 1000)
+	c.Assert(err, IsNil)
+	c.Check(reqs, HasLen, 0)
+	// no reply was given
+	c.Check(replies, HasLen, 0)
+	err = ifacestate.ReplyToPrompt(s.st, 1000, "1", prompting.OutcomeAllow, prompting.LifespanSingle)
+	c.Check(err, Equals, ifacestate.ErrNoPromptRequest)
+
+	// removing it again is fine
+	c.Check(ifacestate.RemovePromptRequest(s.st, "1"), IsNil)
+}
+
+func (s *promptingSuite) TestReplyToPromptForever(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	var replies []string
+	ifacestate.AddPromptReplyHook(func(st *state.State, req *prompting.Request, outcome prompting.Outcome) {
+		replies = append(replies, req.ID+":"+string(outcome))
+	})
+
+	for _, req := range []*prompting.Request{
+		s.homeRequest(1000, "/home/user/doc.txt", "read", "write"),
+		s.homeRequest(1000, "/home/user/doc.txt", "read"),
+		s.homeRequest(1000, "/home/user/pic.png", "read"),
+		s.homeRequest(1001, "/home/user/doc.txt", "read"),
+	} {
+		_, _, err := ifacestate.AddPromptRequest(s.st, req)
+		c.Assert(err, IsNil)
+	}
+
+	err := ifacestate.ReplyToPrompt(s.st, 1000, "1", prompting.OutcomeDeny, prompting.LifespanForever)
+	c.Assert(err, IsNil)
+	// the pending request covered by the new rule was replied to as well
+	c.Check(replies, DeepEquals, []string{"1:deny", "2:deny"})
+
+	reqs, err := ifacestate.PromptRequests(s.st, 1000)
+	c.Assert(err, IsNil)
+	c.Assert(reqs, HasLen, 1)
+	c.Check(reqs[0].ID, Equals, "3")
+
+	// later matching requests are decided by the rule
+	outcome, decided, err := ifacestate.AddPromptRequest(s.st, s.homeRequest(1000, "/home/user/doc.txt", "write"))
+	c.Assert(err, IsNil)
+	c.Check(decided, Equals, true)
+	c.Check(outcome, Equals, prompting.OutcomeDeny)
+
+	_, decided, err = ifacestate.AddPromptRequest(s.st, s.homeRequest(1000, "/home/user/doc.txt", "execute"))
+	c.Assert(err, IsNil)
+	c.Check(decided, Equals, false)
+}
@@ -64,6 +64,10 @@ var (
 
 	defaultCachedDownloads = 5
 
+	// maxStateCheckpointDelay caps for how long state writes can be
+	// coalesced
+	maxStateCheckpointDelay = 30 * time.Second
+
 	configstateInit = configstate.Init
 )
 
@@ -71,6 +75,8 @@ var (
 // track of all available state managers and related helpers.
 type Overlord struct {
 	stateEng *StateEngine
+	// stateBackend persists the state, it is nil for a mocked overlord
+	stateBackend *overlordStateBackend
 	// ensure loop
 	loopTomb    *tomb.Tomb
 	ensureLock  sync.Mutex
@@ -118,18 +124,47 @@ func New(restartBehavior RestartBehavior) (*Overlord, error) {
 		restartBehavior: restartBehavior,
 	}
 
+	storage, err := newStateStorage(os.Getenv("SNAPD_STATE_STORAGE"), dirs.SnapStateFile, osutil.GetenvBool("SNAPD_STATE_COMPRESS"))
+	if err != nil {
+		return nil, err
+	}
+	oldStorage, err := migrateStateStorage(storage, dirs.SnapStateFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot migrate the state: %v", err)
+	}
 	backend := &overlordStateBackend{
-		path:           dirs.SnapStateFile,
+		storage:        storage,
 		ensureBefore:   o.ensureBefore,
 		requestRestart: o.requestRestart,
 	}
+	// coalescing the state writes reduces the IO, at the price of
+	// possibly losing the last changes on power loss
+	if s := os.Getenv("SNAPD_STATE_CHECKPOINT_DELAY"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse SNAPD_STATE_CHECKPOINT_DELAY: %v", err)
+		}
+		if d > maxStateCheckpointDelay {
+			d = maxStateCheckpointDelay
+		}
+		backend.checkpointDelay = d
+	}
+	o.stateBackend = backend
 	s, err := loadState(backend, restartBehavior)
 	if err != nil {
+		storage.Close()
 		return nil, err
 	}
+	if oldStorage != nil {
+		// the migrated state was loaded fine
+		if err := oldStorage.Remove(); err != nil {
+			return nil, fmt.Errorf("cannot remove the migrated state: %v", err)
+		}
+	}
 
 	o.stateEng = NewStateEngine(s)
 	o.runner = state.NewTaskRunner(s)
+	o.runner.ObserveRuns(timings.RecordHandlerRun)
 
 	// any unknown task should be ignored and succeed
 	matchAnyUnknownTask := func(_ *state.Task) bool {
@@ -174,6 +209,7 @@ func New(restartBehavior RestartBehavior) (*Overlord, error) {
 		return nil, err
 	}
 	healthstate.Init(hookMgr)
+	o.addManager(healthstate.Manager(s))
 
 	// the shared task runner should be added last!
 	o.stateEng.AddManager(o.runner)
@@ -210,7 +246,7 @@ func (o *Overlord) addManager(mgr StateManager) {
 	o.stateEng.AddManager(mgr)
 }
 
-func loadState(backend state.Backend, restartBehavior RestartBehavior) (*state.State, error) {
+func loadState(backend *overlordStateBackend, restartBehavior RestartBehavior) (*state.State, error) {
 	curBootID, err := osutil.BootID()
 	if err != nil {
 		return nil, fmt.Errorf("fatal: cannot find current boot id: %v", err)
@@ -218,7 +254,7 @@ func loadState(backend state.Backend, restartBehavior RestartBehavior) (*state.S
 
 	perfTimings := timings.New(map[string]string{"startup": "load-state"})
 
-	if !osutil.FileExists(dirs.SnapStateFile) {
+	if !backend.storage.Exists() {
 		// fail fast, mostly interesting for tests, this dir is setup
 		// by the snapd package
 		stateDir := filepath.Dir(dirs.SnapStateFile)
@@ -233,7 +269,7 @@ func loadState(backend state.Backend, restartBehavior RestartBehavior) (*state.S
 		return s, nil
 	}
 
-	r, err := os.Open(dirs.SnapStateFile)
+	r, err := backend.storage.Open()
 	if err != nil {
 		return nil, fmt.Errorf("cannot read the state file: %s", err)
 	}
@@ -424,6 +460,12 @@ func (o *Overlord) Stop() error {
 	o.loopTomb.Kill(nil)
 	err := o.loopTomb.Wait()
 	o.stateEng.Stop()
+	if o.stateBackend != nil {
+		// make sure the last checkpoint reaches the disk
+		if ferr := o.stateBackend.Close(); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
 	return err
 }
 
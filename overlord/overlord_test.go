@@ -637,6 +637,282 @@ func (ovs *overlordSuite) TestCheckpoint(c *C) {
 	c.Check(dirs.SnapStateFile, testutil.FileContains, `"mark":1`)
 }
 
+func (ovs *overlordSuite) TestCheckpointCompressed(c *C) {
+	os.Setenv("SNAPD_STATE_COMPRESS", "1")
+	defer os.Unsetenv("SNAPD_STATE_COMPRESS")
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+
+	s := o.State()
+	s.Lock()
+	s.Set("mark", 1)
+	s.Unlock()
+
+	data, err := ioutil.ReadFile(dirs.SnapStateFile)
+	c.Assert(err, IsNil)
+	c.Check(data[:2], DeepEquals, []byte{0x1f, 0x8b})
+
+	// the compressed state is read back
+	o, err = overlord.New(nil)
+	c.Assert(err, IsNil)
+	s = o.State()
+	s.Lock()
+	defer s.Unlock()
+	var mark int
+	c.Assert(s.Get("mark", &mark), IsNil)
+	c.Check(mark, Equals, 1)
+}
+
+func (ovs *overlordSuite) TestCheckpointCompressedUncompressedOnStop(c *C) {
+	os.Setenv("SNAPD_STATE_COMPRESS", "1")
+	defer os.Unsetenv("SNAPD_STATE_COMPRESS")
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+
+	s := o.State()
+	s.Lock()
+	s.Set("mark", 1)
+	s.Unlock()
+	c.Check(dirs.SnapStateFile, Not(testutil.FileContains), `"mark":1`)
+
+	// a snapd not knowing about compression can read the state left
+	// behind
+	o.Loop()
+	c.Assert(o.Stop(), IsNil)
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"mark":1`)
+}
+
+func (ovs *overlordSuite) TestCheckpointDelayed(c *C) {
+	os.Setenv("SNAPD_STATE_CHECKPOINT_DELAY", "1h")
+	defer os.Unsetenv("SNAPD_STATE_CHECKPOINT_DELAY")
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+
+	s := o.State()
+	s.Lock()
+	s.Set("mark", 1)
+	s.Unlock()
+
+	// the write was coalesced
+	c.Check(dirs.SnapStateFile, testutil.FileAbsent)
+
+	// but is flushed when stopping
+	o.Loop()
+	c.Assert(o.Stop(), IsNil)
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"mark":1`)
+}
+
+func (ovs *overlordSuite) TestCheckpointDelayedNotOnTaskStatus(c *C) {
+	os.Setenv("SNAPD_STATE_CHECKPOINT_DELAY", "1h")
+	defer os.Unsetenv("SNAPD_STATE_CHECKPOINT_DELAY")
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+
+	s := o.State()
+	s.Lock()
+	chg := s.NewChange("change", "...")
+	t := s.NewTask("foo", "...")
+	chg.AddTask(t)
+	s.Unlock()
+	// the write was coalesced
+	c.Check(dirs.SnapStateFile, testutil.FileAbsent)
+
+	// but not the one of a task status change
+	s.Lock()
+	t.SetStatus(state.DoingStatus)
+	s.Unlock()
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"status":3`)
+
+	// later checkpoints are delayed again
+	s.Lock()
+	s.Set("mark", 1)
+	s.Unlock()
+	c.Check(dirs.SnapStateFile, Not(testutil.FileContains), `"mark":1`)
+}
+
+func (ovs *overlordSuite) TestCheckpointDelayedWindow(c *C) {
+	os.Setenv("SNAPD_STATE_CHECKPOINT_DELAY", "10ms")
+	defer os.Unsetenv("SNAPD_STATE_CHECKPOINT_DELAY")
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+
+	s := o.State()
+	for i := 1; i <= 3; i++ {
+		s.Lock()
+		s.Set("mark", i)
+		s.Unlock()
+	}
+
+	for i := 0; i < 100; i++ {
+		if osutil.FileExists(dirs.SnapStateFile) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"mark":3`)
+}
+
+func (ovs *overlordSuite) TestCheckpointBolt(c *C) {
+	os.Setenv("SNAPD_STATE_STORAGE", "bolt")
+	defer os.Unsetenv("SNAPD_STATE_STORAGE")
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+
+	s := o.State()
+	s.Lock()
+	s.Set("mark", 1)
+	chg := s.NewChange("change", "...")
+	chg.AddTask(s.NewTask("foo", "..."))
+	s.Unlock()
+
+	o.Loop()
+	c.Assert(o.Stop(), IsNil)
+	c.Check(dirs.SnapStateFile, testutil.FileAbsent)
+	c.Check(filepath.Join(filepath.Dir(dirs.SnapStateFile), "test.db"), testutil.FilePresent)
+
+	// the state is read back
+	o, err = overlord.New(nil)
+	c.Assert(err, IsNil)
+	s = o.State()
+	s.Lock()
+	var mark int
+	c.Assert(s.Get("mark", &mark), IsNil)
+	c.Check(mark, Equals, 1)
+	c.Assert(s.Changes(), HasLen, 1)
+	c.Check(s.Changes()[0].Tasks(), HasLen, 1)
+	s.Unlock()
+	o.Loop()
+	c.Assert(o.Stop(), IsNil)
+}
+
+func (ovs *overlordSuite) TestStateStorageMigration(c *C) {
+	fakeState := []byte(fmt.Sprintf(`{"data":{"patch-level":%d,"patch-sublevel":%d,"patch-sublevel-last-version":%q,"some":"data","refresh-privacy-key":"0123456789ABCDEF"},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0}`, patch.Level, patch.Sublevel, cmd.Version))
+	err := ioutil.WriteFile(dirs.SnapStateFile, fakeState, 0600)
+	c.Assert(err, IsNil)
+
+	os.Setenv("SNAPD_STATE_STORAGE", "bolt")
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	o.Loop()
+	c.Assert(o.Stop(), IsNil)
+	// the JSON state was moved over
+	c.Check(dirs.SnapStateFile, testutil.FileAbsent)
+
+	// and back
+	os.Setenv("SNAPD_STATE_STORAGE", "json")
+	defer os.Unsetenv("SNAPD_STATE_STORAGE")
+	o, err = overlord.New(nil)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(filepath.Dir(dirs.SnapStateFile), "test.db"), testutil.FileAbsent)
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"some":"data"`)
+
+	s := o.State()
+	s.Lock()
+	defer s.Unlock()
+	var some string
+	c.Assert(s.Get("some", &some), IsNil)
+	c.Check(some, Equals, "data")
+}
+
+func (ovs *overlordSuite) TestStateStorageMigrationKeepsStateUntilLoaded(c *C) {
+	fakeState := []byte(fmt.Sprintf(`{"data":{"patch-level":%d,"patch-sublevel":%d,"patch-sublevel-last-version":%q,"some":"data","refresh-privacy-key":"0123456789ABCDEF"},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0}`, patch.Level, patch.Sublevel, cmd.Version))
+	err := ioutil.WriteFile(dirs.SnapStateFile, fakeState, 0600)
+	c.Assert(err, IsNil)
+
+	os.Setenv("SNAPD_STATE_STORAGE", "bolt")
+	defer os.Unsetenv("SNAPD_STATE_STORAGE")
+
+	// loading the migrated state fails
+	rb := &testRestartBehavior{rebootVerifiedErr: errors.New("boom")}
+	_, err = overlord.New(rb)
+	c.Assert(err, ErrorMatches, "boom")
+	c.Check(filepath.Join(filepath.Dir(dirs.SnapStateFile), "test.db"), testutil.FilePresent)
+	// the JSON state is kept
+	c.Check(dirs.SnapStateFile, testutil.FileEquals, fakeState)
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	c.Check(dirs.SnapStateFile, testutil.FileAbsent)
+	s := o.State()
+	s.Lock()
+	var some string
+	c.Assert(s.Get("some", &some), IsNil)
+	c.Check(some, Equals, "data")
+	s.Unlock()
+	o.Loop()
+	c.Assert(o.Stop(), IsNil)
+}
+
+func (ovs *overlordSuite) TestStateStorageMigrationNoExtension(c *C) {
+	stateFile := filepath.Join(filepath.Dir(dirs.SnapStateFile), "test")
+	dirs.SnapStateFile = stateFile
+	fakeState := []byte(fmt.Sprintf(`{"data":{"patch-level":%d,"patch-sublevel":%d,"patch-sublevel-last-version":%q,"some":"data","refresh-privacy-key":"0123456789ABCDEF"},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0}`, patch.Level, patch.Sublevel, cmd.Version))
+	err := ioutil.WriteFile(stateFile, fakeState, 0600)
+	c.Assert(err, IsNil)
+
+	os.Setenv("SNAPD_STATE_STORAGE", "bolt")
+	defer os.Unsetenv("SNAPD_STATE_STORAGE")
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	o.Loop()
+	c.Assert(o.Stop(), IsNil)
+	c.Check(stateFile, testutil.FileAbsent)
+	c.Check(stateFile+".db", testutil.FilePresent)
+}
+
+func (ovs *overlordSuite) TestNewUnknownStateStorage(c *C) {
+	os.Setenv("SNAPD_STATE_STORAGE", "punchcards")
+	defer os.Unsetenv("SNAPD_STATE_STORAGE")
+
+	_, err := overlord.New(nil)
+	c.Assert(err, ErrorMatches, `unknown state storage "punchcards"`)
+}
+
+func (ovs *overlordSuite) TestCheckpointDelayedFlushedOnRestart(c *C) {
+	os.Setenv("SNAPD_STATE_CHECKPOINT_DELAY", "1h")
+	defer os.Unsetenv("SNAPD_STATE_CHECKPOINT_DELAY")
+
+	rb := &testRestartBehavior{}
+	o, err := overlord.New(rb)
+	c.Assert(err, IsNil)
+
+	s := o.State()
+	s.Lock()
+	s.Set("mark", 1)
+	s.Unlock()
+	c.Check(dirs.SnapStateFile, testutil.FileAbsent)
+
+	s.Lock()
+	s.Set("mark", 2)
+	// the pending checkpoint is written out before restarting
+	s.RequestRestart(state.RestartDaemon)
+	c.Check(rb.restartRequested, Equals, state.RestartDaemon)
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"mark":1`)
+	// and so is the one carrying the restart request
+	s.Unlock()
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"mark":2`)
+
+	// later checkpoints are delayed again
+	s.Lock()
+	s.Set("mark", 3)
+	s.Unlock()
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"mark":2`)
+}
+
+func (ovs *overlordSuite) TestNewInvalidCheckpointDelay(c *C) {
+	os.Setenv("SNAPD_STATE_CHECKPOINT_DELAY", "soon")
+	defer os.Unsetenv("SNAPD_STATE_CHECKPOINT_DELAY")
+
+	_, err := overlord.New(nil)
+	c.Assert(err, ErrorMatches, `cannot parse SNAPD_STATE_CHECKPOINT_DELAY: .*`)
+}
+
 type sampleManager struct {
 	ensureCallback func()
 }
@@ -70,6 +70,13 @@ func Control(st *state.State, appInfos []*snap.AppInfo, inst *Instruction, conte
 		return nil, fmt.Errorf("unknown action %q", inst.Action)
 	}
 
+	// honour the after/before relations between the services of
+	// each snap, stopping happens in the reverse order of starting
+	appInfos, err := sortedServices(appInfos, inst.Action == "stop")
+	if err != nil {
+		return nil, err
+	}
+
 	st.Lock()
 	defer st.Unlock()
 
@@ -119,3 +126,34 @@ func Control(st *state.State, appInfos []*snap.AppInfo, inst *Instruction, conte
 
 	return tts, nil
 }
+
+// sortedServices returns the given services ordered according to the
+// after/before relations between the services of each snap, or in
+// the reverse of that order if reverse is set. The services of the
+// different snaps are kept in the order the snaps first appear in.
+func sortedServices(appInfos []*snap.AppInfo, reverse bool) ([]*snap.AppInfo, error) {
+	var snapNames []string
+	bySnap := make(map[string][]*snap.AppInfo)
+	for _, app := range appInfos {
+		snapName := app.Snap.InstanceName()
+		if _, ok := bySnap[snapName]; !ok {
+			snapNames = append(snapNames, snapName)
+		}
+		bySnap[snapName] = append(bySnap[snapName], app)
+	}
+
+	sorted := make([]*snap.AppInfo, 0, len(appInfos))
+	for _, snapName := range snapNames {
+		apps, err := snap.SortServices(bySnap[snapName])
+		if err != nil {
+			return nil, err
+		}
+		if reverse {
+			for i, j := 0, len(apps)-1; i < j; i, j = i+1, j-1 {
+				apps[i], apps[j] = apps[j], apps[i]
+			}
+		}
+		sorted = append(sorted, apps...)
+	}
+	return sorted, nil
+}
@@ -148,6 +148,23 @@ func removeSnapshotState(st *state.State, setIDs ...uint64) error {
 	return nil
 }
 
+// Expirations returns the expiry times of the automatic snapshot sets,
+// keyed by set id.
+// The state needs to be locked by the caller.
+func Expirations(st *state.State) (map[uint64]time.Time, error) {
+	var snapshots map[uint64]*snapshotState
+	err := st.Get("snapshots", &snapshots)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+
+	expirations := make(map[uint64]time.Time, len(snapshots))
+	for setID, snapshotSet := range snapshots {
+		expirations[setID] = snapshotSet.ExpiryTime
+	}
+	return expirations, nil
+}
+
 // expiredSnapshotSets returns expired snapshot sets from the state whose expiry-time is before the given cutoffTime.
 // The state needs to be locked by the caller.
 func expiredSnapshotSets(st *state.State, cutoffTime time.Time) (map[uint64]bool, error) {
@@ -1389,6 +1389,28 @@ func (snapshotSuite) TestRemoveSnapshotState(c *check.C) {
 	})
 }
 
+func (snapshotSuite) TestExpirations(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	expirations, err := snapshotstate.Expirations(st)
+	c.Assert(err, check.IsNil)
+	c.Check(expirations, check.HasLen, 0)
+
+	st.Set("snapshots", map[uint64]interface{}{
+		12: map[string]interface{}{"expiry-time": "2019-01-11T11:11:00Z"},
+		13: map[string]interface{}{"expiry-time": "2019-02-12T12:11:00Z"},
+	})
+
+	expirations, err = snapshotstate.Expirations(st)
+	c.Assert(err, check.IsNil)
+	c.Check(expirations, check.DeepEquals, map[uint64]time.Time{
+		12: time.Date(2019, 1, 11, 11, 11, 0, 0, time.UTC),
+		13: time.Date(2019, 2, 12, 12, 11, 0, 0, time.UTC),
+	})
+}
+
 func (snapshotSuite) TestExpiredSnapshotSets(c *check.C) {
 	st := state.New(nil)
 	st.Lock()
@@ -0,0 +1,130 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/store"
+)
+
+var (
+	// authRefreshInterval is how often the expiry of the store
+	// credentials is checked
+	authRefreshInterval = 6 * time.Hour
+	// authRefreshMargin is how long before their expiry the store
+	// credentials are renewed
+	authRefreshMargin = 3 * 24 * time.Hour
+)
+
+// authRefresh renews the store credentials of the users and of the
+// device ahead of their expiry, instead of waiting for the store to
+// reject them.
+type authRefresh struct {
+	state *state.State
+
+	nextAuthRefresh time.Time
+}
+
+func newAuthRefresh(st *state.State) *authRefresh {
+	return &authRefresh{state: st}
+}
+
+// Ensure will ensure that the store credentials close to their expiry
+// get renewed.
+func (r *authRefresh) Ensure() error {
+	r.state.Lock()
+	defer r.state.Unlock()
+
+	// sneakily don't do anything if in testing
+	if CanAutoRefresh == nil {
+		return nil
+	}
+
+	now := timeNow()
+	if now.Before(r.nextAuthRefresh) {
+		return nil
+	}
+	r.nextAuthRefresh = now.Add(authRefreshInterval)
+
+	var authState auth.AuthState
+	if err := r.state.Get("auth", &authState); err != nil {
+		if err == state.ErrNoState {
+			return nil
+		}
+		return err
+	}
+	renewBefore := now.Add(authRefreshMargin)
+	theStore := Store(r.state, nil)
+
+	for i := range authState.Users {
+		user := &authState.Users[i]
+		if user.NeedsReLogin {
+			continue
+		}
+		expiry := user.StoreAuthExpiry()
+		if expiry.IsZero() || expiry.After(renewBefore) {
+			continue
+		}
+		r.state.Unlock()
+		err := theStore.RefreshUserAuth(user)
+		r.state.Lock()
+		switch err {
+		case nil:
+			logger.Debugf("Renewed the store credentials of user %d.", user.ID)
+		case store.ErrInvalidCredentials:
+			logger.Noticef("Store credentials of user %d cannot be renewed, the user needs to log in again.", user.ID)
+			if err := markNeedsReLogin(r.state, user.ID); err != nil {
+				return err
+			}
+		default:
+			logger.Noticef("Cannot renew the store credentials of user %d: %v.", user.ID, err)
+		}
+	}
+
+	if device := authState.Device; device != nil {
+		expiry := device.SessionExpiry()
+		if !expiry.IsZero() && !expiry.After(renewBefore) {
+			r.state.Unlock()
+			err := theStore.RefreshDeviceSession()
+			r.state.Lock()
+			if err != nil {
+				logger.Noticef("Cannot renew the store session of the device: %v.", err)
+			}
+		}
+	}
+	return nil
+}
+
+func markNeedsReLogin(st *state.State, userID int) error {
+	user, err := auth.User(st, userID)
+	if err != nil {
+		if err == auth.ErrInvalidUser {
+			// logged out in the meantime
+			return nil
+		}
+		return err
+	}
+	user.NeedsReLogin = true
+	return auth.UpdateUser(st, user)
+}
@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"fmt"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/macaroon.v1"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/storetest"
+)
+
+type authRefreshStore struct {
+	storetest.Store
+
+	ops     []string
+	userErr error
+}
+
+func (r *authRefreshStore) RefreshUserAuth(user *auth.UserState) error {
+	r.ops = append(r.ops, fmt.Sprintf("refresh-user:%d", user.ID))
+	return r.userErr
+}
+
+func (r *authRefreshStore) RefreshDeviceSession() error {
+	r.ops = append(r.ops, "refresh-device-session")
+	return nil
+}
+
+type authRefreshTestSuite struct {
+	state *state.State
+	store *authRefreshStore
+	now   time.Time
+}
+
+var _ = Suite(&authRefreshTestSuite{})
+
+func (s *authRefreshTestSuite) SetUpTest(c *C) {
+	s.state = state.New(nil)
+	s.store = &authRefreshStore{}
+	s.now = time.Date(2019, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	s.state.Lock()
+	snapstate.ReplaceStore(s.state, s.store)
+	s.state.Unlock()
+
+	snapstate.CanAutoRefresh = func(*state.State) (bool, error) { return true, nil }
+}
+
+func (s *authRefreshTestSuite) TearDownTest(c *C) {
+	snapstate.CanAutoRefresh = nil
+}
+
+func expiringMacaroon(c *C, expiry time.Time) string {
+	m, err := macaroon.New([]byte("secret"), "some-id", "location")
+	c.Assert(err, IsNil)
+	c.Assert(m.AddFirstPartyCaveat("location|expires|"+expiry.Format(time.RFC3339)), IsNil)
+	serialized, err := auth.MacaroonSerialize(m)
+	c.Assert(err, IsNil)
+	return serialized
+}
+
+func (s *authRefreshTestSuite) TestAuthRefresh(c *C) {
+	defer snapstate.MockTimeNow(func() time.Time { return s.now })()
+
+	s.state.Lock()
+	s.state.Set("auth", auth.AuthState{
+		Users: []auth.UserState{
+			// expires soon
+			{ID: 1, StoreMacaroon: expiringMacaroon(c, s.now.Add(24*time.Hour))},
+			// expires in a while
+			{ID: 2, StoreMacaroon: expiringMacaroon(c, s.now.Add(30*24*time.Hour))},
+			// never expires
+			{ID: 3, StoreMacaroon: "no-expiry"},
+			// no store auth
+			{ID: 4},
+		},
+		Device: &auth.DeviceState{SessionMacaroon: expiringMacaroon(c, s.now.Add(time.Hour))},
+	})
+	s.state.Unlock()
+
+	ar := snapstate.NewAuthRefresh(s.state)
+	c.Assert(ar.Ensure(), IsNil)
+	c.Check(s.store.ops, DeepEquals, []string{"refresh-user:1", "refresh-device-session"})
+
+	// not checked again until the next interval
+	c.Assert(ar.Ensure(), IsNil)
+	c.Check(s.store.ops, HasLen, 2)
+}
+
+func (s *authRefreshTestSuite) TestAuthRefreshNeedsReLogin(c *C) {
+	defer snapstate.MockTimeNow(func() time.Time { return s.now })()
+	s.store.userErr = store.ErrInvalidCredentials
+
+	s.state.Lock()
+	s.state.Set("auth", auth.AuthState{
+		Users: []auth.UserState{
+			{ID: 1, StoreMacaroon: expiringMacaroon(c, s.now.Add(time.Hour))},
+		},
+	})
+	s.state.Unlock()
+
+	ar := snapstate.NewAuthRefresh(s.state)
+	c.Assert(ar.Ensure(), IsNil)
+	c.Check(s.store.ops, DeepEquals, []string{"refresh-user:1"})
+
+	s.state.Lock()
+	user, err := auth.User(s.state, 1)
+	s.state.Unlock()
+	c.Assert(err, IsNil)
+	c.Check(user.NeedsReLogin, Equals, true)
+
+	// no more attempts until the user logs in again
+	defer snapstate.MockTimeNow(func() time.Time { return s.now.Add(24 * time.Hour) })()
+	c.Assert(ar.Ensure(), IsNil)
+	c.Check(s.store.ops, HasLen, 1)
+}
@@ -51,6 +51,7 @@ var (
 	CanAutoRefresh        func(st *state.State) (bool, error)
 	CanManageRefreshes    func(st *state.State) bool
 	IsOnMeteredConnection func() (bool, error)
+	DeviceSerial          func(st *state.State) (string, error)
 )
 
 // refreshRetryDelay specified the minimum time to retry failed refreshes
@@ -72,6 +73,25 @@ func newAutoRefresh(st *state.State) *autoRefresh {
 	}
 }
 
+// nextRefreshDelay returns the delay until the next refresh after last
+// according to the schedule. Devices that have a serial refresh at a stable
+// position within spread windows, so that refreshes of a fleet of devices
+// are staggered across the windows.
+func (m *autoRefresh) nextRefreshDelay(schedule []*timeutil.Schedule, last time.Time) time.Duration {
+	var serial string
+	if DeviceSerial != nil {
+		var err error
+		serial, err = DeviceSerial(m.state)
+		if err != nil {
+			logger.Noticef("Cannot get device serial for refresh scheduling: %v", err)
+		}
+	}
+	if serial == "" {
+		return timeutil.Next(schedule, last, maxPostponement)
+	}
+	return timeutil.NextWithSeed(schedule, last, maxPostponement, serial)
+}
+
 // RefreshSchedule will return a user visible string with the current schedule
 // for the automatic refreshes and a flag indicating whether the schedule is a
 // legacy one.
@@ -240,7 +260,7 @@ func (m *autoRefresh) Ensure() error {
 	if m.nextRefresh.IsZero() {
 		// store attempts in memory so that we can backoff
 		if !lastRefresh.IsZero() {
-			delta := timeutil.Next(refreshSchedule, lastRefresh, maxPostponement)
+			delta := m.nextRefreshDelay(refreshSchedule, lastRefresh)
 			now = time.Now()
 			m.nextRefresh = now.Add(delta)
 		} else {
@@ -266,7 +286,7 @@ func (m *autoRefresh) Ensure() error {
 		m.clearRefreshHold()
 		if m.nextRefresh.Before(holdTime) {
 			// next refresh is obsolete, compute the next one
-			delta := timeutil.Next(refreshSchedule, holdTime, maxPostponement)
+			delta := m.nextRefreshDelay(refreshSchedule, holdTime)
 			now = time.Now()
 			m.nextRefresh = now.Add(delta)
 		}
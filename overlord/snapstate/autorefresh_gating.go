@@ -0,0 +1,134 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	// gatedRefreshHoldDuration is for how long a single hold from
+	// a gate-auto-refresh hook postpones the auto-refresh of a snap
+	gatedRefreshHoldDuration = 24 * time.Hour
+	// maxGatedRefreshHold is for how long in total a snap can keep
+	// postponing its own auto-refresh
+	maxGatedRefreshHold = 7 * 24 * time.Hour
+)
+
+// gateAutoRefreshTasks returns a task set running the
+// gate-auto-refresh hooks of the given snaps followed by the
+// conditional refresh of the snaps that did not hold their refresh.
+func gateAutoRefreshTasks(st *state.State, gated []string) *state.TaskSet {
+	ts := state.NewTaskSet()
+	conditional := st.NewTask("conditional-auto-refresh", fmt.Sprintf(i18n.G("Auto-refresh snaps %s unless held"), strutil.Quoted(gated)))
+	conditional.Set("snap-names", gated)
+	for _, name := range gated {
+		hook := SetupGateAutoRefreshHook(st, name)
+		conditional.WaitFor(hook)
+		ts.AddTask(hook)
+	}
+	ts.AddTask(conditional)
+	return ts
+}
+
+// reportGatedAutoRefresh updates the snaps reported by the auto-refresh
+// change, which lists the gated snaps upfront, once it is known which of
+// them are refreshed. The snaps that held their refresh are reported as
+// held-snaps instead.
+func reportGatedAutoRefresh(chg *state.Change, gated, updated, held []string) error {
+	var names []string
+	if err := chg.Get("snap-names", &names); err != nil && err != state.ErrNoState {
+		return err
+	}
+	reported := make([]string, 0, len(names))
+	for _, name := range names {
+		if strutil.ListContains(gated, name) && !strutil.ListContains(updated, name) {
+			continue
+		}
+		reported = append(reported, name)
+	}
+	chg.Set("snap-names", reported)
+
+	var apiData map[string]interface{}
+	if err := chg.Get("api-data", &apiData); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if apiData == nil {
+		apiData = make(map[string]interface{})
+	}
+	apiData["snap-names"] = reported
+	if len(held) > 0 {
+		apiData["held-snaps"] = held
+	}
+	chg.Set("api-data", apiData)
+	return nil
+}
+
+// GateAutoRefreshHold holds the pending auto-refresh of the given snap,
+// as requested from its gate-auto-refresh hook. It returns the time
+// until which the refresh is held. A snap cannot postpone its own
+// auto-refresh indefinitely, once the maximum hold time elapsed an
+// error is returned and the refresh proceeds.
+func GateAutoRefreshHold(st *state.State, instanceName string) (time.Time, error) {
+	snapStates, err := installedSnapStates(st, []string{instanceName})
+	if err != nil {
+		return time.Time{}, err
+	}
+	snapst := snapStates[0]
+
+	now := timeNow()
+	first := now
+	if snapst.GatedRefreshHold != nil {
+		first = snapst.GatedRefreshHold.Time
+	}
+	limit := first.Add(maxGatedRefreshHold)
+	if !now.Before(limit) {
+		return time.Time{}, fmt.Errorf("cannot hold auto-refresh of snap %q any longer, it has been held since %s", instanceName, first.Format(time.RFC3339))
+	}
+
+	until := now.Add(gatedRefreshHoldDuration)
+	if until.After(limit) {
+		until = limit
+	}
+	snapst.GatedRefreshHold = &RefreshHold{Time: first, Until: until}
+	Set(st, instanceName, snapst)
+	return until, nil
+}
+
+// GateAutoRefreshProceed lets the pending auto-refresh of the given
+// snap proceed, as requested from its gate-auto-refresh hook.
+func GateAutoRefreshProceed(st *state.State, instanceName string) error {
+	snapStates, err := installedSnapStates(st, []string{instanceName})
+	if err != nil {
+		return err
+	}
+	snapst := snapStates[0]
+	if snapst.GatedRefreshHold == nil {
+		return nil
+	}
+	snapst.GatedRefreshHold = nil
+	Set(st, instanceName, snapst)
+	return nil
+}
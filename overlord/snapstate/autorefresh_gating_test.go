@@ -0,0 +1,278 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	. "github.com/snapcore/snapd/testutil"
+)
+
+func (s *snapmgrTestSuite) TestGateAutoRefreshHold(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	now := time.Date(2020, 4, 1, 10, 0, 0, 0, time.UTC)
+	restore := snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.setupHoldSnap("some-snap")
+
+	until, err := snapstate.GateAutoRefreshHold(s.state, "some-snap")
+	c.Assert(err, IsNil)
+	c.Check(until, DeepEquals, now.Add(24*time.Hour))
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.GatedRefreshHold, DeepEquals, &snapstate.RefreshHold{Time: now, Until: until})
+	// the hold set by the user is not touched
+	c.Check(snapst.RefreshHold, IsNil)
+
+	// holding again extends the hold but remembers when it started
+	first := now
+	now = now.Add(6*24*time.Hour + 12*time.Hour)
+	until, err = snapstate.GateAutoRefreshHold(s.state, "some-snap")
+	c.Assert(err, IsNil)
+	// capped at the maximum hold time
+	c.Check(until, DeepEquals, first.Add(7*24*time.Hour))
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.GatedRefreshHold, DeepEquals, &snapstate.RefreshHold{Time: first, Until: until})
+
+	now = until
+	_, err = snapstate.GateAutoRefreshHold(s.state, "some-snap")
+	c.Assert(err, ErrorMatches, `cannot hold auto-refresh of snap "some-snap" any longer, it has been held since 2020-04-01T10:00:00Z`)
+
+	err = snapstate.GateAutoRefreshProceed(s.state, "some-snap")
+	c.Assert(err, IsNil)
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.GatedRefreshHold, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestGateAutoRefreshNotInstalled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := snapstate.GateAutoRefreshHold(s.state, "not-installed")
+	c.Check(err, ErrorMatches, `snap "not-installed" is not installed`)
+	err = snapstate.GateAutoRefreshProceed(s.state, "not-installed")
+	c.Check(err, ErrorMatches, `snap "not-installed" is not installed`)
+}
+
+func (s *snapmgrTestSuite) TestGatedRefreshHoldSkipsAutoRefresh(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupHoldSnap("some-snap")
+	_, err := snapstate.GateAutoRefreshHold(s.state, "some-snap")
+	c.Assert(err, IsNil)
+
+	updates, _, err := snapstate.UpdateMany(context.Background(), s.state, nil, 0, &snapstate.Flags{IsAutoRefresh: true})
+	c.Assert(err, IsNil)
+	c.Check(updates, HasLen, 0)
+
+	// manual refreshes are not affected
+	updates, _, err = snapstate.UpdateMany(context.Background(), s.state, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(updates, DeepEquals, []string{"some-snap"})
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshHeldByHealth(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupHoldSnap("some-snap")
+
+	var calls []string
+	snapstate.AutoRefreshHeldByHealth = func(st *state.State, instanceName string, current snap.Revision) bool {
+		c.Check(current, Equals, snap.R(1))
+		calls = append(calls, instanceName)
+		return true
+	}
+	defer func() { snapstate.AutoRefreshHeldByHealth = nil }()
+
+	updates, _, err := snapstate.UpdateMany(context.Background(), s.state, nil, 0, &snapstate.Flags{IsAutoRefresh: true})
+	c.Assert(err, IsNil)
+	c.Check(updates, HasLen, 0)
+	c.Check(calls, DeepEquals, []string{"some-snap"})
+
+	// manual refreshes are not affected
+	updates, _, err = snapstate.UpdateMany(context.Background(), s.state, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(updates, DeepEquals, []string{"some-snap"})
+	c.Check(calls, HasLen, 1)
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshGateAutoRefreshHook(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupHoldSnap("some-snap")
+	s.setupHoldSnap("gating-snap")
+
+	updates, tss, err := snapstate.AutoRefresh(context.Background(), s.state)
+	c.Assert(err, IsNil)
+	c.Check(updates, DeepEquals, []string{"gating-snap", "some-snap"})
+	// refresh of some-snap, its re-refresh and the gating tasks
+	c.Assert(tss, HasLen, 3)
+	verifyUpdateTasks(c, unlinkBefore|cleanupAfter, 0, tss[0], s.state)
+
+	gating := tss[2].Tasks()
+	c.Assert(gating, HasLen, 2)
+	c.Check(gating[0].Kind(), Equals, "run-hook")
+	var hooksup hookstate.HookSetup
+	c.Assert(gating[0].Get("hook-setup", &hooksup), IsNil)
+	c.Check(hooksup, DeepEquals, hookstate.HookSetup{
+		Snap:        "gating-snap",
+		Hook:        "gate-auto-refresh",
+		Optional:    true,
+		IgnoreError: true,
+		Timeout:     5 * time.Minute,
+	})
+
+	conditional := gating[1]
+	c.Check(conditional.Kind(), Equals, "conditional-auto-refresh")
+	c.Check(conditional.Summary(), Equals, `Auto-refresh snaps "gating-snap" unless held`)
+	c.Check(conditional.WaitTasks(), DeepEquals, []*state.Task{gating[0]})
+	var names []string
+	c.Assert(conditional.Get("snap-names", &names), IsNil)
+	c.Check(names, DeepEquals, []string{"gating-snap"})
+}
+
+type conditionalAutoRefreshSuite struct {
+	baseHandlerSuite
+}
+
+var _ = Suite(&conditionalAutoRefreshSuite{})
+
+func (s *conditionalAutoRefreshSuite) setupSnap(name string, hold *snapstate.RefreshHold) {
+	snapstate.Set(s.state, name, &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: name, SnapID: name + "-id", Revision: snap.R(1)},
+		},
+		Current:          snap.R(1),
+		SnapType:         "app",
+		GatedRefreshHold: hold,
+	})
+}
+
+func (s *conditionalAutoRefreshSuite) TestDoConditionalAutoRefresh(c *C) {
+	now := time.Now()
+	var chgID string
+	defer snapstate.MockConditionalAutoRefreshUpdateMany(func(ctx context.Context, st *state.State, snaps []string, userID int, filter snapstate.UpdateFilter, flags *snapstate.Flags, changeID string) ([]string, []*state.TaskSet, error) {
+		c.Check(changeID, Equals, chgID)
+		c.Check(snaps, DeepEquals, []string{"expired-snap", "some-snap"})
+		c.Check(flags, DeepEquals, &snapstate.Flags{IsAutoRefresh: true, NoReRefresh: true})
+
+		task := st.NewTask("witness", "...")
+		return []string{"some-snap"}, []*state.TaskSet{state.NewTaskSet(task)}, nil
+	})()
+
+	s.state.Lock()
+	s.setupSnap("some-snap", nil)
+	s.setupSnap("held-snap", &snapstate.RefreshHold{Time: now, Until: now.Add(time.Hour)})
+	s.setupSnap("expired-snap", &snapstate.RefreshHold{Time: now.Add(-2 * time.Hour), Until: now.Add(-time.Hour)})
+	chg := s.state.NewChange("auto-refresh", "...")
+	// the gated snaps are listed upfront along with the not gated ones
+	names := []string{"expired-snap", "held-snap", "other-snap", "removed-snap", "some-snap"}
+	chg.Set("snap-names", names)
+	chg.Set("api-data", map[string]interface{}{"snap-names": names})
+	task := s.state.NewTask("conditional-auto-refresh", "test")
+	task.Set("snap-names", []string{"expired-snap", "held-snap", "removed-snap", "some-snap"})
+	chg.AddTask(task)
+	chgID = chg.ID()
+	s.state.Unlock()
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(task.Status(), Equals, state.DoneStatus)
+	c.Check(logstr(task), Contains, `Auto-refresh of "held-snap" held by the snap until`)
+	c.Check(logstr(task), Contains, `Auto-refreshing "some-snap".`)
+
+	tasks := chg.Tasks()
+	c.Assert(tasks, HasLen, 2)
+	c.Check(tasks[1].Kind(), Equals, "witness")
+
+	// the expired hold was cleared
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "expired-snap", &snapst), IsNil)
+	c.Check(snapst.GatedRefreshHold, IsNil)
+	c.Assert(snapstate.Get(s.state, "held-snap", &snapst), IsNil)
+	c.Check(snapst.GatedRefreshHold, NotNil)
+
+	// only the refreshed snaps are reported as such
+	c.Assert(chg.Get("snap-names", &names), IsNil)
+	c.Check(names, DeepEquals, []string{"other-snap", "some-snap"})
+	var apiData map[string]interface{}
+	c.Assert(chg.Get("api-data", &apiData), IsNil)
+	c.Check(apiData, DeepEquals, map[string]interface{}{
+		"snap-names": []interface{}{"other-snap", "some-snap"},
+		"held-snaps": []interface{}{"held-snap"},
+	})
+}
+
+func (s *conditionalAutoRefreshSuite) TestDoConditionalAutoRefreshAllHeld(c *C) {
+	now := time.Now()
+	defer snapstate.MockConditionalAutoRefreshUpdateMany(func(context.Context, *state.State, []string, int, snapstate.UpdateFilter, *snapstate.Flags, string) ([]string, []*state.TaskSet, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil, nil
+	})()
+
+	s.state.Lock()
+	s.setupSnap("held-snap", &snapstate.RefreshHold{Time: now, Until: now.Add(time.Hour)})
+	chg := s.state.NewChange("auto-refresh", "...")
+	chg.Set("snap-names", []string{"held-snap"})
+	chg.Set("api-data", map[string]interface{}{"snap-names": []string{"held-snap"}})
+	task := s.state.NewTask("conditional-auto-refresh", "test")
+	task.Set("snap-names", []string{"held-snap"})
+	chg.AddTask(task)
+	s.state.Unlock()
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(task.Status(), Equals, state.DoneStatus)
+	c.Check(logstr(task), Contains, `No auto-refreshes to proceed with.`)
+	c.Check(chg.Tasks(), HasLen, 1)
+
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), IsNil)
+	c.Check(names, HasLen, 0)
+	var apiData map[string]interface{}
+	c.Assert(chg.Get("api-data", &apiData), IsNil)
+	c.Check(apiData, DeepEquals, map[string]interface{}{
+		"snap-names": []interface{}{},
+		"held-snaps": []interface{}{"held-snap"},
+	})
+}
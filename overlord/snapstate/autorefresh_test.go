@@ -318,6 +318,44 @@ func (s *autoRefreshTestSuite) TestDefaultScheduleIsRandomized(c *C) {
 	}
 }
 
+func (s *autoRefreshTestSuite) TestNextRefreshStaggeredBySerial(c *C) {
+	serial := ""
+	snapstate.DeviceSerial = func(*state.State) (string, error) { return serial, nil }
+	defer func() { snapstate.DeviceSerial = nil }()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// just refreshed, the next refresh is in one of the next windows
+	s.state.Set("last-refresh", time.Now())
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.timer", "00:00~24:00/4")
+	tr.Commit()
+
+	nextRefresh := func() time.Time {
+		af := snapstate.NewAutoRefresh(s.state)
+		s.state.Unlock()
+		defer s.state.Lock()
+		c.Assert(af.Ensure(), IsNil)
+		return af.NextRefresh()
+	}
+
+	serial = "serial-1"
+	next1 := nextRefresh()
+	c.Check(next1.After(time.Now()), Equals, true)
+	// the refresh time is stable for the device
+	for i := 0; i < 5; i++ {
+		c.Check(nextRefresh().Sub(next1) < time.Second, Equals, true)
+	}
+
+	// other devices refresh at other times of the window
+	serial = "serial-2"
+	next2 := nextRefresh()
+	c.Check(next2.Sub(next1) >= time.Second || next1.Sub(next2) >= time.Second, Equals, true)
+
+	c.Check(s.store.ops, HasLen, 0)
+}
+
 func (s *autoRefreshTestSuite) TestLastRefreshRefreshHold(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -36,6 +36,8 @@ import (
 // A StoreService can find, list available updates and download snaps.
 type StoreService interface {
 	EnsureDeviceSession() (*auth.DeviceState, error)
+	RefreshDeviceSession() error
+	RefreshUserAuth(user *auth.UserState) error
 
 	SnapInfo(ctx context.Context, spec store.SnapSpec, user *auth.UserState) (*snap.Info, error)
 	Find(ctx context.Context, search *store.Search, user *auth.UserState) ([]*snap.Info, error)
@@ -83,6 +85,10 @@ type managerBackend interface {
 	RemoveSnapDataDir(info *snap.Info, hasOtherInstances bool) error
 	DiscardSnapNamespace(snapName string) error
 
+	// desktop database related
+	DeferDesktopDatabaseUpdate() (flush func() error)
+	UpdateDesktopDatabase() error
+
 	// alias related
 	UpdateAliases(add []*backend.Alias, remove []*backend.Alias) error
 	RemoveSnapAliases(snapName string) error
@@ -179,6 +179,12 @@ func generateWrappers(s *snap.Info) (err error) {
 	}
 	cleanupFuncs = append(cleanupFuncs, wrappers.RemoveSnapIcons)
 
+	// add the D-Bus service activation files
+	if err = wrappers.AddSnapDBusActivationFiles(s); err != nil {
+		return err
+	}
+	cleanupFuncs = append(cleanupFuncs, wrappers.RemoveSnapDBusActivationFiles)
+
 	return nil
 }
 
@@ -203,7 +209,12 @@ func removeGeneratedWrappers(s *snap.Info, meter progress.Meter) error {
 		logger.Noticef("Cannot remove desktop icons for %q: %v", s.InstanceName(), err4)
 	}
 
-	return firstErr(err1, err2, err3, err4)
+	err5 := wrappers.RemoveSnapDBusActivationFiles(s)
+	if err5 != nil {
+		logger.Noticef("Cannot remove D-Bus activation files for %q: %v", s.InstanceName(), err5)
+	}
+
+	return firstErr(err1, err2, err3, err4, err5)
 }
 
 // UnlinkSnap makes the snap unavailable to the system removing wrappers and symlinks.
@@ -249,3 +260,15 @@ func removeCurrentSymlinks(info snap.PlaceInfo) error {
 
 	return nil
 }
+
+// DeferDesktopDatabaseUpdate postpones the updates of the desktop
+// database done when linking or unlinking snaps until the returned
+// function is called, see wrappers.DeferDesktopDatabaseUpdate.
+func (b Backend) DeferDesktopDatabaseUpdate() (flush func() error) {
+	return wrappers.DeferDesktopDatabaseUpdate()
+}
+
+// UpdateDesktopDatabase updates the desktop database right away.
+func (b Backend) UpdateDesktopDatabase() error {
+	return wrappers.UpdateDesktopDatabase()
+}
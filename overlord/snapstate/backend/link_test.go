@@ -102,6 +102,32 @@ apps:
 	c.Assert(l, HasLen, 0)
 }
 
+func (s *linkSuite) TestLinkDoUndoDBusActivationFiles(c *C) {
+	const yaml = `name: hello
+version: 1.0
+apps:
+ svc:
+   command: svc
+   daemon: simple
+   activates-on: [name]
+slots:
+ name:
+   interface: dbus
+   bus: system
+   name: org.example.Hello
+`
+	info := snaptest.MockSnap(c, yaml, &snap.SideInfo{Revision: snap.R(11)})
+
+	err := s.be.LinkSnap(info, nil, s.perfTimings)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(dirs.SnapDBusSystemServicesDir, "org.example.Hello.service"), testutil.FilePresent)
+
+	// undo will remove
+	err = s.be.UnlinkSnap(info, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(dirs.SnapDBusSystemServicesDir, "org.example.Hello.service"), testutil.FileAbsent)
+}
+
 func (s *linkSuite) TestLinkDoUndoCurrentSymlink(c *C) {
 	const yaml = `name: hello
 version: 1.0
@@ -279,6 +279,8 @@ func (f *fakeStore) lookupRefresh(cand refreshCand) (*snap.Info, error) {
 		name = "services-snap"
 	case "some-snap-id":
 		name = "some-snap"
+	case "gating-snap-id":
+		name = "gating-snap"
 	case "some-epoch-snap-id":
 		name = "some-epoch-snap"
 		epoch = snap.E("42")
@@ -553,6 +555,13 @@ func (f *fakeStore) Download(ctx context.Context, name, targetFn string, snapInf
 	if user != nil {
 		macaroon = user.StoreMacaroon
 	}
+	// partial downloads are always kept so that they can be resumed
+	if !dlOpts.LeavePartialOnError {
+		return fmt.Errorf("internal error: download is not resumable")
+	}
+	opts := *dlOpts
+	opts.LeavePartialOnError = false
+	dlOpts = &opts
 	// only add the options if they contain anything interesting
 	if *dlOpts == (store.DownloadOptions{}) {
 		dlOpts = nil
@@ -607,6 +616,10 @@ type fakeSnappyBackend struct {
 	linkSnapFailTrigger     string
 	copySnapDataFailTrigger string
 	emptyContainer          snap.Container
+
+	desktopDatabaseDeferrals int
+	desktopDatabaseFlushes   int
+	desktopDatabaseUpdates   int
 }
 
 func (f *fakeSnappyBackend) OpenSnapFile(snapFilePath string, si *snap.SideInfo) (*snap.Info, snap.Container, error) {
@@ -714,10 +727,18 @@ func (f *fakeSnappyBackend) ReadInfo(name string, si *snap.SideInfo) (*snap.Info
 		info.Epoch = snap.Epoch{}
 	case "some-epoch-snap":
 		info.Epoch = snap.E("13")
+		if si.Revision == snap.R(5) {
+			// a revision from before the epoch bump
+			info.Epoch = snap.E("5")
+		}
 	case "gadget", "brand-gadget":
 		info.SnapType = snap.TypeGadget
 	case "core":
 		info.SnapType = snap.TypeOS
+	case "gating-snap":
+		info.Hooks = map[string]*snap.HookInfo{
+			"gate-auto-refresh": {Name: "gate-auto-refresh", Snap: info},
+		}
 	case "services-snap":
 		var err error
 		// fix services after/before so that there is only one solution
@@ -843,9 +864,14 @@ func (f *fakeSnappyBackend) StartServices(svcs []*snap.AppInfo, meter progress.M
 }
 
 func (f *fakeSnappyBackend) StopServices(svcs []*snap.AppInfo, reason snap.ServiceStopReason, meter progress.Meter, tm timings.Measurer) error {
+	services := make([]string, 0, len(svcs))
+	for _, svc := range svcs {
+		services = append(services, svc.Name)
+	}
 	f.appendOp(&fakeOp{
-		op:   fmt.Sprintf("stop-snap-services:%s", reason),
-		path: svcSnapMountDir(svcs),
+		op:       fmt.Sprintf("stop-snap-services:%s", reason),
+		path:     svcSnapMountDir(svcs),
+		services: services,
 	})
 	return nil
 }
@@ -979,6 +1005,25 @@ func (ba byAlias) Less(i, j int) bool {
 	return ba[i].Name < ba[j].Name
 }
 
+func (f *fakeSnappyBackend) DeferDesktopDatabaseUpdate() (flush func() error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.desktopDatabaseDeferrals++
+	return func() error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.desktopDatabaseFlushes++
+		return nil
+	}
+}
+
+func (f *fakeSnappyBackend) UpdateDesktopDatabase() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.desktopDatabaseUpdates++
+	return nil
+}
+
 func (f *fakeSnappyBackend) UpdateAliases(add []*backend.Alias, remove []*backend.Alias) error {
 	if len(add) != 0 {
 		add = append([]*backend.Alias(nil), add...)
@@ -290,21 +290,15 @@ func validateFlagsForInfo(info *snap.Info, snapst *SnapState, flags Flags) error
 			Snap: info.InstanceName(),
 		}
 	case snap.ClassicConfinement:
-		if !release.OnClassic {
-			return &SnapNeedsClassicSystemError{Snap: info.InstanceName()}
-		}
-
-		if flags.Classic {
-			return nil
-		}
-
-		if snapst != nil && snapst.Flags.Classic {
-			return nil
-		}
-
-		return &SnapNeedsClassicError{
-			Snap: info.InstanceName(),
+		sanctioned := flags.Classic || (snapst != nil && snapst.Flags.Classic)
+		err := snap.CheckClassicConfinement(info, release.OnClassic, sanctioned)
+		if cerr, ok := err.(*snap.ClassicConfinementError); ok {
+			if cerr.NeedsClassicSystem {
+				return &SnapNeedsClassicSystemError{Snap: cerr.Snap}
+			}
+			return &SnapNeedsClassicError{Snap: cerr.Snap}
 		}
+		return err
 	default:
 		return fmt.Errorf("unknown confinement %q", c)
 	}
@@ -483,12 +477,12 @@ func checkGadgetOrKernel(st *state.State, snapInfo, curInfo *snap.Info, _ snap.C
 		// check if we are in the remodel case
 		if deviceCtx != nil && deviceCtx.ForRemodeling() {
 			model := deviceCtx.Model()
-			if model.Kernel() == snapInfo.InstanceName() {
+			if whichName(model) == snapInfo.InstanceName() {
 				return nil
 			}
 		}
 
-		return fmt.Errorf("internal error: cannot remodel gadget yet")
+		return fmt.Errorf("internal error: cannot install %s snap %q not named by the model", kind, snapInfo.InstanceName())
 	}
 	if err != nil {
 		return fmt.Errorf("cannot find original %s snap: %v", kind, err)
@@ -580,6 +574,21 @@ func earlyEpochCheck(info *snap.Info, snapst *SnapState) error {
 	return checkEpochs(nil, info, cur, nil, Flags{}, nil)
 }
 
+// check that the revision of the snap installed in the system (via snapst)
+// to revert to, described by info, can read the data written by the
+// current one
+func revertEpochCheck(info *snap.Info, snapst *SnapState) error {
+	cur, err := snapst.CurrentInfo()
+	if err != nil {
+		return err
+	}
+	if cur.Epoch.CanWrite(info.Epoch) {
+		return nil
+	}
+
+	return fmt.Errorf("cannot revert %q to revision %s with epoch %s, because it can't read the current epoch of %s", info.InstanceName(), info.Revision, info.Epoch, cur.Epoch)
+}
+
 // check that the listed system users are valid
 var osutilEnsureUserGroup = osutil.EnsureUserGroup
 
@@ -1206,7 +1206,7 @@ version: 2
 	c.Check(err, IsNil)
 }
 
-func (s *checkSnapSuite) TestCheckSnapRemodelGadgetDoesNotWorkYet(c *C) {
+func (s *checkSnapSuite) TestCheckSnapRemodelGadget(c *C) {
 	reset := release.MockOnClassic(false)
 	defer reset()
 
@@ -1242,8 +1242,7 @@ version: 2
 	restore := snapstate.MockOpenSnapFile(openSnapFile)
 	defer restore()
 
-	// happy case, the new-gadget matches the model but we do not
-	// support this yet
+	// happy case, the new-gadget matches the model
 	deviceCtx := &snapstatetest.TrivialDeviceContext{
 		Remodeling: true,
 		DeviceModel: MakeModel(map[string]interface{}{
@@ -1253,7 +1252,21 @@ version: 2
 	}
 
 	st.Unlock()
-	err = snapstate.CheckSnap(st, "snap-path", "new-kernel", nil, nil, snapstate.Flags{}, deviceCtx)
+	err = snapstate.CheckSnap(st, "snap-path", "new-gadget", nil, nil, snapstate.Flags{}, deviceCtx)
+	st.Lock()
+	c.Check(err, IsNil)
+
+	// the new-gadget is not the one named by the model
+	deviceCtx = &snapstatetest.TrivialDeviceContext{
+		Remodeling: true,
+		DeviceModel: MakeModel(map[string]interface{}{
+			"kernel": "kernel",
+			"gadget": "other-gadget",
+		}),
+	}
+
+	st.Unlock()
+	err = snapstate.CheckSnap(st, "snap-path", "new-gadget", nil, nil, snapstate.Flags{}, deviceCtx)
 	st.Lock()
-	c.Check(err, ErrorMatches, "internal error: cannot remodel gadget yet")
+	c.Check(err, ErrorMatches, `internal error: cannot install gadget snap "new-gadget" not named by the model`)
 }
@@ -142,6 +142,8 @@ func NextCatalogRefresh(cr *catalogRefresh) time.Time {
 	return cr.nextCatalogRefresh
 }
 
+var NewAuthRefresh = newAuthRefresh
+
 func MockRefreshRetryDelay(d time.Duration) func() {
 	origRefreshRetryDelay := refreshRetryDelay
 	refreshRetryDelay = d
@@ -190,6 +192,14 @@ func MockReRefreshUpdateMany(f func(context.Context, *state.State, []string, int
 	}
 }
 
+func MockConditionalAutoRefreshUpdateMany(f func(context.Context, *state.State, []string, int, UpdateFilter, *Flags, string) ([]string, []*state.TaskSet, error)) (restore func()) {
+	old := conditionalAutoRefreshUpdateMany
+	conditionalAutoRefreshUpdateMany = f
+	return func() {
+		conditionalAutoRefreshUpdateMany = old
+	}
+}
+
 func MockReRefreshRetryTimeout(d time.Duration) (restore func()) {
 	old := reRefreshRetryTimeout
 	reRefreshRetryTimeout = d
@@ -215,3 +225,9 @@ func MockPidsCgroupDir(dir string) (restore func()) {
 		pidsCgroupDir = old
 	}
 }
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() { timeNow = old }
+}
@@ -564,9 +564,20 @@ func (m *SnapManager) doDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 	meter := NewTaskProgressAdapterUnlocked(t)
 	targetFn := snapsup.MountFile()
 
+	// the partial download is kept around when the task is
+	// interrupted, e.g. by snapd being stopped or crashing, so that
+	// the download resumes from there when the task is run again;
+	// it is removed by cleanupDownloadSnap once the change is ready
+	if fi, err := os.Stat(partialDownloadFile(targetFn)); err == nil && fi.Size() > 0 {
+		st.Lock()
+		t.Logf("Resuming download of snap %q from byte %d.", snapsup.SnapName(), fi.Size())
+		st.Unlock()
+	}
+
 	dlOpts := &store.DownloadOptions{
-		IsAutoRefresh: snapsup.IsAutoRefresh,
-		RateLimit:     rate,
+		IsAutoRefresh:       snapsup.IsAutoRefresh,
+		RateLimit:           rate,
+		LeavePartialOnError: true,
 	}
 	if snapsup.DownloadInfo == nil {
 		var storeInfo *snap.Info
@@ -601,6 +612,32 @@ func (m *SnapManager) doDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 	return nil
 }
 
+// partialDownloadFile returns the file the store downloads the snap
+// to before moving it to targetFn.
+func partialDownloadFile(targetFn string) string {
+	return targetFn + ".partial"
+}
+
+func (m *SnapManager) cleanupDownloadSnap(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	snapsup, err := TaskSnapSetup(t)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+	if snapsup.SideInfo == nil || snapsup.SideInfo.RealName == "" {
+		return nil
+	}
+
+	// a successful download moved the partial file to its
+	// target already
+	if err := os.Remove(partialDownloadFile(snapsup.MountFile())); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 var (
 	mountPollInterval = 1 * time.Second
 )
@@ -819,6 +856,7 @@ func (m *SnapManager) doUnlinkCurrentSnap(t *state.Task, _ *tomb.Tomb) error {
 
 	snapst.Active = false
 
+	m.deferDesktopDatabaseUpdate(t)
 	pb := NewTaskProgressAdapterLocked(t)
 	err = m.backend.UnlinkSnap(oldInfo, pb)
 	if err != nil {
@@ -854,6 +892,7 @@ func (m *SnapManager) undoUnlinkCurrentSnap(t *state.Task, _ *tomb.Tomb) error {
 	}
 
 	snapst.Active = true
+	m.deferDesktopDatabaseUpdate(t)
 	err = m.backend.LinkSnap(oldInfo, model, perfTimings)
 	if err != nil {
 		return err
@@ -985,6 +1024,58 @@ func (m *SnapManager) cleanupCopySnapData(t *state.Task, _ *tomb.Tomb) error {
 	return nil
 }
 
+// deferDesktopDatabaseUpdate makes the desktop database updates done when
+// linking and unlinking the snaps of the change of the task happen only
+// once, when the change is ready, see cleanupDesktopDatabase. It must be
+// called with the state locked.
+func (m *SnapManager) deferDesktopDatabaseUpdate(t *state.Task) {
+	chg := t.Change()
+	if chg == nil {
+		return
+	}
+	if _, ok := m.desktopDatabaseFlushes[chg.ID()]; ok {
+		return
+	}
+	m.desktopDatabaseFlushes[chg.ID()] = m.backend.DeferDesktopDatabaseUpdate()
+	// remember the deferral, the update still needs to happen if snapd
+	// is restarted before the change is ready
+	chg.Set("desktop-database-deferred", true)
+}
+
+func (m *SnapManager) cleanupDesktopDatabase(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	chg := t.Change()
+	var deferred bool
+	if err := chg.Get("desktop-database-deferred", &deferred); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !deferred {
+		// nothing deferred or already updated by another task
+		return nil
+	}
+	chg.Set("desktop-database-deferred", false)
+
+	flush := m.desktopDatabaseFlushes[chg.ID()]
+	delete(m.desktopDatabaseFlushes, chg.ID())
+
+	st.Unlock()
+	var err error
+	if flush != nil {
+		err = flush()
+	} else {
+		// deferred before a restart of snapd
+		err = m.backend.UpdateDesktopDatabase()
+	}
+	st.Lock()
+	if err != nil {
+		logger.Noticef("cannot update the desktop database: %v", err)
+	}
+	return nil
+}
+
 // writeSeqFile writes the sequence file for failover handling
 func writeSeqFile(name string, snapst *SnapState) error {
 	p := filepath.Join(dirs.SnapSeqDir, name+".json")
@@ -1088,6 +1179,7 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) (err error) {
 
 	// XXX: this block is slightly ugly, find a pattern when we have more examples
 	model, _ := ModelFromTask(t)
+	m.deferDesktopDatabaseUpdate(t)
 	err = m.backend.LinkSnap(newInfo, model, perfTimings)
 	if err != nil {
 		pb := NewTaskProgressAdapterLocked(t)
@@ -1369,6 +1461,7 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 			return err
 		}
 	}
+	m.deferDesktopDatabaseUpdate(t)
 	pb := NewTaskProgressAdapterLocked(t)
 	err = m.backend.UnlinkSnap(newInfo, pb)
 	if err != nil {
@@ -1511,6 +1604,16 @@ func (m *SnapManager) stopSnapServices(t *state.Task, _ *tomb.Tomb) error {
 		return nil
 	}
 
+	// stop the services in the reverse order of their startup
+	startupOrdered, err := snap.SortServices(svcs)
+	if err != nil {
+		return err
+	}
+	stopOrdered := make([]*snap.AppInfo, len(startupOrdered))
+	for i, svc := range startupOrdered {
+		stopOrdered[len(startupOrdered)-1-i] = svc
+	}
+
 	var stopReason snap.ServiceStopReason
 	if err := t.Get("stop-reason", &stopReason); err != nil && err != state.ErrNoState {
 		return err
@@ -1518,7 +1621,7 @@ func (m *SnapManager) stopSnapServices(t *state.Task, _ *tomb.Tomb) error {
 
 	pb := NewTaskProgressAdapterUnlocked(t)
 	st.Unlock()
-	err = m.backend.StopServices(svcs, stopReason, pb, perfTimings)
+	err = m.backend.StopServices(stopOrdered, stopReason, pb, perfTimings)
 	st.Lock()
 	return err
 }
@@ -1539,6 +1642,7 @@ func (m *SnapManager) doUnlinkSnap(t *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
+	m.deferDesktopDatabaseUpdate(t)
 	pb := NewTaskProgressAdapterLocked(t)
 	err = m.backend.UnlinkSnap(info, pb)
 	if err != nil {
@@ -1637,6 +1741,7 @@ func (m *SnapManager) doDiscardSnap(t *state.Task, _ *tomb.Tomb) error {
 		return &state.Retry{After: 3 * time.Minute}
 	}
 	if len(snapst.Sequence) == 0 {
+		infoCache.Forget(snapsup.InstanceName())
 		// Remove configuration associated with this snap.
 		err = config.DeleteSnapConfig(st, snapsup.InstanceName())
 		if err != nil {
@@ -2365,6 +2470,75 @@ func (m *SnapManager) doCheckReRefresh(t *state.Task, tomb *tomb.Tomb) error {
 	return nil
 }
 
+// conditionalAutoRefreshUpdateMany exists just to make testing simpler
+var conditionalAutoRefreshUpdateMany = updateManyFiltered
+
+func (m *SnapManager) doConditionalAutoRefresh(t *state.Task, tomb *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var snapNames []string
+	if err := t.Get("snap-names", &snapNames); err != nil {
+		return err
+	}
+
+	now := timeNow()
+	var proceed, held []string
+	for _, name := range snapNames {
+		var snapst SnapState
+		err := Get(st, name, &snapst)
+		if err == state.ErrNoState {
+			// removed in the meantime
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if snapst.GatedRefreshHold.Active(now) {
+			t.Logf("Auto-refresh of %q held by the snap until %s.", name, snapst.GatedRefreshHold.Until.Format(time.RFC3339))
+			held = append(held, name)
+			continue
+		}
+		if snapst.GatedRefreshHold != nil {
+			snapst.GatedRefreshHold = nil
+			Set(st, name, &snapst)
+		}
+		proceed = append(proceed, name)
+	}
+
+	chg := t.Change()
+	if len(proceed) == 0 {
+		t.Logf("No auto-refreshes to proceed with.")
+		return reportGatedAutoRefresh(chg, snapNames, nil, held)
+	}
+
+	// the change already carries the re-refresh check of the
+	// snaps that were not gated
+	flags := &Flags{IsAutoRefresh: true, NoReRefresh: true}
+	updated, tasksets, err := conditionalAutoRefreshUpdateMany(tomb.Context(nil), st, proceed, 0, nil, flags, chg.ID())
+	if err != nil {
+		return err
+	}
+	if err := reportGatedAutoRefresh(chg, snapNames, updated, held); err != nil {
+		return err
+	}
+
+	if len(updated) == 0 {
+		t.Logf("No auto-refreshes found.")
+	} else {
+		t.Logf("Auto-refreshing %s.", strutil.Quoted(updated))
+
+		for _, taskset := range tasksets {
+			chg.AddAll(taskset)
+		}
+		st.EnsureBefore(0)
+	}
+	t.SetStatus(state.DoneStatus)
+
+	return nil
+}
+
 // InjectTasks makes all the halt tasks of the mainTask wait for extraTasks;
 // extraTasks join the same lane and change as the mainTask.
 func InjectTasks(mainTask *state.Task, extraTasks *state.TaskSet) {
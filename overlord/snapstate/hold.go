@@ -0,0 +1,150 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2020 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// RefreshHold describes a hold of the auto-refreshes of a snap
+// requested by the user.
+type RefreshHold struct {
+	// Time is when the hold was requested.
+	Time time.Time `json:"time"`
+	// Until is when the hold expires, a zero time holds the
+	// auto-refreshes of the snap indefinitely.
+	Until time.Time `json:"until"`
+}
+
+// Forever returns whether the hold does not expire.
+func (h *RefreshHold) Forever() bool {
+	return h.Until.IsZero()
+}
+
+// Active returns whether the hold is still in effect at the given time.
+func (h *RefreshHold) Active(now time.Time) bool {
+	if h == nil {
+		return false
+	}
+	return h.Forever() || h.Until.After(now)
+}
+
+var timeNow = time.Now
+
+// cannot hold the auto-refreshes of a snap for more than maxRefreshHold,
+// unless the refresh schedule is managed
+const maxRefreshHold = maxPostponement
+
+// HoldRefreshes holds the auto-refreshes of the given snaps until the
+// given time. A zero until time holds the auto-refreshes indefinitely,
+// until UnholdRefreshes is called. Holds are limited to maxRefreshHold
+// from now, holding indefinitely or for longer is only possible when
+// the refresh schedule is managed by a snap. Explicit refreshes
+// requested by the user are not affected by holds.
+func HoldRefreshes(st *state.State, instanceNames []string, until time.Time) error {
+	now := timeNow()
+	if !until.IsZero() && !until.After(now) {
+		return fmt.Errorf("cannot hold refreshes until %s: time is in the past", until.Format(time.RFC3339))
+	}
+	if managed, _ := refreshScheduleManaged(st); !managed {
+		if until.IsZero() {
+			return fmt.Errorf("cannot hold refreshes forever: refresh schedule is not managed")
+		}
+		if until.After(now.Add(maxRefreshHold)) {
+			return fmt.Errorf("cannot hold refreshes until %s: more than %d days from now", until.Format(time.RFC3339), int(maxRefreshHold.Hours()/24))
+		}
+	}
+	if len(instanceNames) == 0 {
+		return fmt.Errorf("cannot hold refreshes: no snaps given")
+	}
+
+	snapStates, err := installedSnapStates(st, instanceNames)
+	if err != nil {
+		return err
+	}
+
+	for i, name := range instanceNames {
+		snapst := snapStates[i]
+		snapst.RefreshHold = &RefreshHold{Time: now, Until: until}
+		Set(st, name, snapst)
+	}
+	return nil
+}
+
+// UnholdRefreshes removes any hold of the auto-refreshes of the given
+// snaps.
+func UnholdRefreshes(st *state.State, instanceNames []string) error {
+	if len(instanceNames) == 0 {
+		return fmt.Errorf("cannot unhold refreshes: no snaps given")
+	}
+
+	snapStates, err := installedSnapStates(st, instanceNames)
+	if err != nil {
+		return err
+	}
+
+	for i, name := range instanceNames {
+		snapst := snapStates[i]
+		if snapst.RefreshHold == nil {
+			continue
+		}
+		snapst.RefreshHold = nil
+		Set(st, name, snapst)
+	}
+	return nil
+}
+
+func installedSnapStates(st *state.State, instanceNames []string) ([]*SnapState, error) {
+	snapStates := make([]*SnapState, len(instanceNames))
+	for i, name := range instanceNames {
+		var snapst SnapState
+		err := Get(st, name, &snapst)
+		if err != nil && err != state.ErrNoState {
+			return nil, err
+		}
+		if !snapst.IsInstalled() {
+			return nil, &snap.NotInstalledError{Snap: name}
+		}
+		snapStates[i] = &snapst
+	}
+	return snapStates, nil
+}
+
+// HeldSnaps returns the holds of the snaps whose auto-refreshes are
+// currently held, keyed by instance name.
+func HeldSnaps(st *state.State) (map[string]*RefreshHold, error) {
+	snapStates, err := All(st)
+	if err != nil {
+		return nil, err
+	}
+
+	now := timeNow()
+	held := make(map[string]*RefreshHold)
+	for name, snapst := range snapStates {
+		if snapst.RefreshHold.Active(now) {
+			held[name] = snapst.RefreshHold
+		}
+	}
+	return held, nil
+}
@@ -0,0 +1,188 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2020 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) setupHoldSnap(name string) {
+	snapstate.Set(s.state, name, &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: name, SnapID: name + "-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+}
+
+func (s *snapmgrTestSuite) mockManagedRefreshSchedule() (restore func()) {
+	snapstate.CanManageRefreshes = func(st *state.State) bool {
+		return true
+	}
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.timer", "managed")
+	tr.Commit()
+	return func() {
+		snapstate.CanManageRefreshes = nil
+		tr := config.NewTransaction(s.state)
+		tr.Set("core", "refresh.timer", "")
+		tr.Commit()
+	}
+}
+
+func (s *snapmgrTestSuite) TestHoldRefreshesHappy(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	now := time.Date(2020, 4, 1, 10, 0, 0, 0, time.UTC)
+	restore := snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+	// holding forever requires a managed refresh schedule
+	restore = s.mockManagedRefreshSchedule()
+	defer restore()
+
+	s.setupHoldSnap("some-snap")
+	s.setupHoldSnap("some-other-snap")
+
+	until := now.Add(48 * time.Hour)
+	err := snapstate.HoldRefreshes(s.state, []string{"some-snap"}, until)
+	c.Assert(err, IsNil)
+	err = snapstate.HoldRefreshes(s.state, []string{"some-other-snap"}, time.Time{})
+	c.Assert(err, IsNil)
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.RefreshHold, DeepEquals, &snapstate.RefreshHold{Time: now, Until: until})
+	c.Check(snapst.RefreshHold.Forever(), Equals, false)
+
+	held, err := snapstate.HeldSnaps(s.state)
+	c.Assert(err, IsNil)
+	c.Check(held, HasLen, 2)
+	c.Check(held["some-other-snap"].Forever(), Equals, true)
+
+	// the timed hold expires, the indefinite one does not
+	now = until.Add(time.Second)
+	held, err = snapstate.HeldSnaps(s.state)
+	c.Assert(err, IsNil)
+	c.Check(held, HasLen, 1)
+	c.Check(held["some-other-snap"], NotNil)
+
+	err = snapstate.UnholdRefreshes(s.state, []string{"some-snap", "some-other-snap"})
+	c.Assert(err, IsNil)
+	held, err = snapstate.HeldSnaps(s.state)
+	c.Assert(err, IsNil)
+	c.Check(held, HasLen, 0)
+	c.Assert(snapstate.Get(s.state, "some-other-snap", &snapst), IsNil)
+	c.Check(snapst.RefreshHold, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestHoldRefreshesErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupHoldSnap("some-snap")
+
+	err := snapstate.HoldRefreshes(s.state, nil, time.Now().Add(time.Hour))
+	c.Check(err, ErrorMatches, "cannot hold refreshes: no snaps given")
+
+	err = snapstate.HoldRefreshes(s.state, []string{"some-snap"}, time.Now().Add(-time.Hour))
+	c.Check(err, ErrorMatches, `cannot hold refreshes until .*: time is in the past`)
+
+	err = snapstate.HoldRefreshes(s.state, []string{"some-snap", "not-installed"}, time.Now().Add(time.Hour))
+	c.Check(err, ErrorMatches, `snap "not-installed" is not installed`)
+	// nothing was held
+	held, err := snapstate.HeldSnaps(s.state)
+	c.Assert(err, IsNil)
+	c.Check(held, HasLen, 0)
+
+	err = snapstate.UnholdRefreshes(s.state, nil)
+	c.Check(err, ErrorMatches, "cannot unhold refreshes: no snaps given")
+	err = snapstate.UnholdRefreshes(s.state, []string{"not-installed"})
+	c.Check(err, ErrorMatches, `snap "not-installed" is not installed`)
+}
+
+func (s *snapmgrTestSuite) TestHoldRefreshesLimits(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	now := time.Date(2020, 4, 1, 10, 0, 0, 0, time.UTC)
+	restore := snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.setupHoldSnap("some-snap")
+
+	err := snapstate.HoldRefreshes(s.state, []string{"some-snap"}, time.Time{})
+	c.Check(err, ErrorMatches, "cannot hold refreshes forever: refresh schedule is not managed")
+	err = snapstate.HoldRefreshes(s.state, []string{"some-snap"}, now.Add(61*24*time.Hour))
+	c.Check(err, ErrorMatches, `cannot hold refreshes until 2020-06-01T10:00:00Z: more than 60 days from now`)
+	held, err := snapstate.HeldSnaps(s.state)
+	c.Assert(err, IsNil)
+	c.Check(held, HasLen, 0)
+
+	// up to the limit is fine
+	err = snapstate.HoldRefreshes(s.state, []string{"some-snap"}, now.Add(60*24*time.Hour))
+	c.Check(err, IsNil)
+
+	// there is no limit when the refresh schedule is managed
+	restore = s.mockManagedRefreshSchedule()
+	defer restore()
+	err = snapstate.HoldRefreshes(s.state, []string{"some-snap"}, now.Add(90*24*time.Hour))
+	c.Check(err, IsNil)
+	err = snapstate.HoldRefreshes(s.state, []string{"some-snap"}, time.Time{})
+	c.Check(err, IsNil)
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.RefreshHold.Forever(), Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestHoldRefreshesSkipsAutoRefresh(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupHoldSnap("some-snap")
+	err := snapstate.HoldRefreshes(s.state, []string{"some-snap"}, time.Now().Add(time.Hour))
+	c.Assert(err, IsNil)
+
+	updates, _, err := snapstate.UpdateMany(context.Background(), s.state, nil, 0, &snapstate.Flags{IsAutoRefresh: true})
+	c.Assert(err, IsNil)
+	c.Check(updates, HasLen, 0)
+
+	// explicit refreshes are not affected
+	updates, _, err = snapstate.UpdateMany(context.Background(), s.state, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(updates, DeepEquals, []string{"some-snap"})
+
+	// nor after the hold is lifted
+	err = snapstate.UnholdRefreshes(s.state, []string{"some-snap"})
+	c.Assert(err, IsNil)
+	updates, _, err = snapstate.UpdateMany(context.Background(), s.state, nil, 0, &snapstate.Flags{IsAutoRefresh: true})
+	c.Assert(err, IsNil)
+	c.Check(updates, DeepEquals, []string{"some-snap"})
+}
@@ -59,8 +59,14 @@ type SnapManager struct {
 	autoRefresh    *autoRefresh
 	refreshHints   *refreshHints
 	catalogRefresh *catalogRefresh
+	authRefresh    *authRefresh
 
 	lastUbuntuCoreTransitionAttempt time.Time
+
+	// desktopDatabaseFlushes holds, per change ID, the functions
+	// flushing the desktop database updates deferred while the snaps
+	// of the change are linked and unlinked
+	desktopDatabaseFlushes map[string]func() error
 }
 
 // SnapSetup holds the necessary snap details to perform most snap manager tasks.
@@ -153,6 +159,15 @@ type SnapState struct {
 	// attempted but inhibited because the snap was busy. This value is
 	// reset on each successful refresh.
 	RefreshInhibitedTime *time.Time `json:"refresh-inhibited-time,omitempty"`
+
+	// RefreshHold is set when the user held the auto-refreshes of
+	// the snap, see HoldRefreshes.
+	RefreshHold *RefreshHold `json:"refresh-hold,omitempty"`
+
+	// GatedRefreshHold is set when the snap held its own
+	// auto-refresh from its gate-auto-refresh hook, see
+	// GateAutoRefreshHold.
+	GatedRefreshHold *RefreshHold `json:"gated-refresh-hold,omitempty"`
 }
 
 // Type returns the type of the snap or an error.
@@ -252,7 +267,10 @@ const (
 	withAuxStoreInfo
 )
 
-var snapReadInfo = snap.ReadInfo
+// infoCache avoids parsing snap.yaml of unchanged snaps over and over
+var infoCache = snap.NewInfoCache()
+
+var snapReadInfo = infoCache.ReadInfo
 
 // AutomaticSnapshot allows to hook snapshot manager's AutomaticSnapshot.
 var AutomaticSnapshot func(st *state.State, instanceName string) (ts *state.TaskSet, err error)
@@ -368,6 +386,9 @@ func Manager(st *state.State, runner *state.TaskRunner) (*SnapManager, error) {
 		autoRefresh:    newAutoRefresh(st),
 		refreshHints:   newRefreshHints(st),
 		catalogRefresh: newCatalogRefresh(st),
+		authRefresh:    newAuthRefresh(st),
+
+		desktopDatabaseFlushes: make(map[string]func() error),
 	}
 
 	if err := os.MkdirAll(dirs.SnapCookieDir, 0700); err != nil {
@@ -393,12 +414,14 @@ func Manager(st *state.State, runner *state.TaskRunner) (*SnapManager, error) {
 	runner.AddHandler("mount-snap", m.doMountSnap, m.undoMountSnap)
 	runner.AddHandler("unlink-current-snap", m.doUnlinkCurrentSnap, m.undoUnlinkCurrentSnap)
 	runner.AddHandler("copy-snap-data", m.doCopySnapData, m.undoCopySnapData)
+	runner.AddCleanup("download-snap", m.cleanupDownloadSnap)
 	runner.AddCleanup("copy-snap-data", m.cleanupCopySnapData)
 	runner.AddHandler("link-snap", m.doLinkSnap, m.undoLinkSnap)
 	runner.AddHandler("start-snap-services", m.startSnapServices, m.stopSnapServices)
 	runner.AddHandler("switch-snap-channel", m.doSwitchSnapChannel, nil)
 	runner.AddHandler("toggle-snap-flags", m.doToggleSnapFlags, nil)
 	runner.AddHandler("check-rerefresh", m.doCheckReRefresh, nil)
+	runner.AddHandler("conditional-auto-refresh", m.doConditionalAutoRefresh, nil)
 
 	// FIXME: drop the task entirely after a while
 	// (having this wart here avoids yet-another-patch)
@@ -407,6 +430,10 @@ func Manager(st *state.State, runner *state.TaskRunner) (*SnapManager, error) {
 	// remove related
 	runner.AddHandler("stop-snap-services", m.stopSnapServices, m.startSnapServices)
 	runner.AddHandler("unlink-snap", m.doUnlinkSnap, nil)
+	// the desktop database is updated once for the whole change
+	runner.AddCleanup("unlink-current-snap", m.cleanupDesktopDatabase)
+	runner.AddCleanup("link-snap", m.cleanupDesktopDatabase)
+	runner.AddCleanup("unlink-snap", m.cleanupDesktopDatabase)
 	runner.AddHandler("clear-snap", m.doClearSnapData, nil)
 	runner.AddHandler("discard-snap", m.doDiscardSnap, nil)
 
@@ -808,6 +835,7 @@ func (m *SnapManager) Ensure() error {
 		m.autoRefresh.Ensure(),
 		m.refreshHints.Ensure(),
 		m.catalogRefresh.Ensure(),
+		m.authRefresh.Ensure(),
 		m.localInstallCleanup(),
 	}
 
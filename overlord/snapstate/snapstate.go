@@ -394,6 +394,10 @@ var SetupRemoveHook = func(st *state.State, snapName string) *state.Task {
 	panic("internal error: snapstate.SetupRemoveHook is unset")
 }
 
+var SetupGateAutoRefreshHook = func(st *state.State, snapName string) *state.Task {
+	panic("internal error: snapstate.SetupGateAutoRefreshHook is unset")
+}
+
 var CheckHealthHook = func(st *state.State, snapName string, rev snap.Revision) *state.Task {
 	panic("internal error: snapstate.CheckHealthHook is unset")
 }
@@ -826,6 +830,11 @@ func RefreshCandidates(st *state.State, user *auth.UserState) ([]*snap.Info, err
 // ValidateRefreshes allows to hook validation into the handling of refresh candidates.
 var ValidateRefreshes func(st *state.State, refreshes []*snap.Info, ignoreValidation map[string]bool, userID int, deviceCtx DeviceContext) (validated []*snap.Info, err error)
 
+// AutoRefreshHeldByHealth allows to hook the health of the snaps into
+// the auto-refresh decisions. It returns whether the auto-refresh of the
+// given revision of the snap should be held.
+var AutoRefreshHeldByHealth func(st *state.State, instanceName string, current snap.Revision) bool
+
 // UpdateMany updates everything from the given list of names that the
 // store says is updateable. If the list is empty, update everything.
 // Note that the state must be locked by the caller.
@@ -1223,6 +1232,23 @@ func resolveChannel(st *state.State, snapName, newChannel string, deviceCtx Devi
 	return resChannel, nil
 }
 
+// sameChannel returns whether the two channels are the same once
+// normalized, e.g. "latest/stable" and "stable".
+func sameChannel(ch1, ch2 string) bool {
+	if ch1 == ch2 {
+		return true
+	}
+	norm1, err := channel.Normalize(ch1)
+	if err != nil {
+		return false
+	}
+	norm2, err := channel.Normalize(ch2)
+	if err != nil {
+		return false
+	}
+	return norm1 == norm2
+}
+
 var errRevisionSwitch = errors.New("cannot switch revision")
 
 func switchSummary(snap, chanFrom, chanTo, cohFrom, cohTo string) string {
@@ -1436,7 +1462,7 @@ func UpdateWithDeviceContext(st *state.State, name string, opts *RevisionOptions
 	}
 
 	// see if we need to switch the channel or cohort, or toggle ignore-validation
-	switchChannel := snapst.Channel != opts.Channel
+	switchChannel := !sameChannel(snapst.Channel, opts.Channel)
 	switchCohortKey := snapst.CohortKey != opts.CohortKey
 	toggleIgnoreValidation := snapst.IgnoreValidation != flags.IgnoreValidation
 	if infoErr == store.ErrNoUpdateAvailable && (switchChannel || switchCohortKey || toggleIgnoreValidation) {
@@ -1549,7 +1575,28 @@ func AutoRefresh(ctx context.Context, st *state.State) ([]string, []*state.TaskS
 		}
 	}
 
-	return UpdateMany(ctx, st, nil, userID, &Flags{IsAutoRefresh: true})
+	// snaps with a gate-auto-refresh hook get to decide whether
+	// their auto-refresh proceeds, their refresh is setup once
+	// their hooks ran
+	var gated []string
+	gateFilter := func(update *snap.Info, snapst *SnapState) bool {
+		info, err := snapst.CurrentInfo()
+		if err != nil || info.Hooks["gate-auto-refresh"] == nil {
+			return true
+		}
+		gated = append(gated, update.InstanceName())
+		return false
+	}
+
+	updated, tasksets, err := updateManyFiltered(ctx, st, nil, userID, gateFilter, &Flags{IsAutoRefresh: true}, "")
+	if err != nil || len(gated) == 0 {
+		return updated, tasksets, err
+	}
+
+	tasksets = append(tasksets, gateAutoRefreshTasks(st, gated))
+	updated = append(updated, gated...)
+	sort.Strings(updated)
+	return updated, tasksets, nil
 }
 
 // Enable sets a snap to the active state
@@ -1695,6 +1742,13 @@ type RemoveFlags struct {
 // Remove returns a set of tasks for removing snap.
 // Note that the state must be locked by the caller.
 func Remove(st *state.State, name string, revision snap.Revision, flags *RemoveFlags) (*state.TaskSet, error) {
+	return RemoveWithDeviceContext(st, name, revision, flags, nil, "")
+}
+
+// RemoveWithDeviceContext returns a set of tasks for removing snap.
+// It checks whether the snap can be removed with the given deviceCtx.
+// Note that the state must be locked by the caller.
+func RemoveWithDeviceContext(st *state.State, name string, revision snap.Revision, flags *RemoveFlags, deviceCtx DeviceContext, fromChange string) (*state.TaskSet, error) {
 	var snapst SnapState
 	err := Get(st, name, &snapst)
 	if err != nil && err != state.ErrNoState {
@@ -1705,11 +1759,11 @@ func Remove(st *state.State, name string, revision snap.Revision, flags *RemoveF
 		return nil, &snap.NotInstalledError{Snap: name, Rev: snap.R(0)}
 	}
 
-	if err := CheckChangeConflict(st, name, nil); err != nil {
+	if err := checkChangeConflictIgnoringOneChange(st, name, nil, fromChange); err != nil {
 		return nil, err
 	}
 
-	deviceCtx, err := DeviceCtxFromState(st, nil)
+	deviceCtx, err = DeviceCtxFromState(st, deviceCtx)
 	if err != nil {
 		return nil, err
 	}
@@ -1943,6 +1997,9 @@ func RevertToRevision(st *state.State, name string, rev snap.Revision, flags Fla
 	if err != nil {
 		return nil, err
 	}
+	if err := revertEpochCheck(info, &snapst); err != nil {
+		return nil, err
+	}
 
 	snapsup := &SnapSetup{
 		SideInfo:    snapst.Sequence[i],
@@ -109,10 +109,12 @@ func (s *snapmgrTestSuite) SetUpTest(c *C) {
 	oldSetupPreRefreshHook := snapstate.SetupPreRefreshHook
 	oldSetupPostRefreshHook := snapstate.SetupPostRefreshHook
 	oldSetupRemoveHook := snapstate.SetupRemoveHook
+	oldSetupGateAutoRefreshHook := snapstate.SetupGateAutoRefreshHook
 	snapstate.SetupInstallHook = hookstate.SetupInstallHook
 	snapstate.SetupPreRefreshHook = hookstate.SetupPreRefreshHook
 	snapstate.SetupPostRefreshHook = hookstate.SetupPostRefreshHook
 	snapstate.SetupRemoveHook = hookstate.SetupRemoveHook
+	snapstate.SetupGateAutoRefreshHook = hookstate.SetupGateAutoRefreshHook
 
 	var err error
 	s.snapmgr, err = snapstate.Manager(s.state, s.o.TaskRunner())
@@ -143,6 +145,7 @@ func (s *snapmgrTestSuite) SetUpTest(c *C) {
 		snapstate.SetupPreRefreshHook = oldSetupPreRefreshHook
 		snapstate.SetupPostRefreshHook = oldSetupPostRefreshHook
 		snapstate.SetupRemoveHook = oldSetupRemoveHook
+		snapstate.SetupGateAutoRefreshHook = oldSetupGateAutoRefreshHook
 
 		dirs.SetRootDir("/")
 	})
@@ -3793,6 +3796,8 @@ func (s *snapmgrTestSuite) TestUpdateRunThrough(c *C) {
 		{
 			op:   "stop-snap-services:refresh",
 			path: filepath.Join(dirs.SnapMountDir, "services-snap/7"),
+			// stopped in the reverse order of their startup
+			services: []string{"svc2", "svc3", "svc1"},
 		},
 		{
 			op:   "remove-snap-aliases",
@@ -4016,6 +4021,8 @@ func (s *snapmgrTestSuite) TestParallelInstanceUpdateRunThrough(c *C) {
 		{
 			op:   "stop-snap-services:refresh",
 			path: filepath.Join(dirs.SnapMountDir, "services-snap_instance/7"),
+			// stopped in the reverse order of their startup
+			services: []string{"svc2", "svc3", "svc1"},
 		},
 		{
 			op:   "remove-snap-aliases",
@@ -4506,6 +4513,70 @@ func (s *snapmgrTestSuite) TestUpdateModelKernelSwitchTrackRunThrough(c *C) {
 	})
 }
 
+func (s *snapmgrTestSuite) TestUpdateManyDesktopDatabaseUpdatedOnce(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, name := range []string{"some-snap", "services-snap"} {
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active: true,
+			Sequence: []*snap.SideInfo{
+				{RealName: name, Revision: snap.R(1), SnapID: name + "-id"},
+			},
+			Current:  snap.R(1),
+			SnapType: "app",
+		})
+	}
+
+	chg := s.state.NewChange("refresh", "refresh all snaps")
+	updated, tts, err := snapstate.UpdateMany(context.Background(), s.state, nil, 0, nil)
+	c.Assert(err, IsNil)
+	for _, ts := range tts {
+		chg.AddAll(ts)
+	}
+	c.Check(updated, HasLen, 2)
+
+	s.state.Unlock()
+	defer s.se.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+
+	// the snaps were unlinked and linked again under a single deferral
+	c.Check(s.fakeBackend.ops.Count("unlink-snap"), Equals, 2)
+	c.Check(s.fakeBackend.ops.Count("link-snap"), Equals, 2)
+	c.Check(s.fakeBackend.desktopDatabaseDeferrals, Equals, 1)
+	c.Check(s.fakeBackend.desktopDatabaseFlushes, Equals, 1)
+	c.Check(s.fakeBackend.desktopDatabaseUpdates, Equals, 0)
+
+	var deferred bool
+	c.Assert(chg.Get("desktop-database-deferred", &deferred), IsNil)
+	c.Check(deferred, Equals, false)
+}
+
+func (s *snapmgrTestSuite) TestDesktopDatabaseUpdatedAfterRestart(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// the deferral was recorded by a previous snapd process
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	t := s.state.NewTask("link-snap", "link a snap")
+	chg.AddTask(t)
+	t.SetStatus(state.DoneStatus)
+	chg.Set("desktop-database-deferred", true)
+
+	s.state.Unlock()
+	defer s.se.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Check(t.IsClean(), Equals, true)
+	c.Check(s.fakeBackend.desktopDatabaseFlushes, Equals, 0)
+	c.Check(s.fakeBackend.desktopDatabaseUpdates, Equals, 1)
+}
+
 func (s *snapmgrTestSuite) TestUpdateManyMultipleCredsNoUserRunThrough(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -7897,6 +7968,42 @@ func (s *snapmgrTestSuite) TestRemoveRefused(c *C) {
 	c.Check(err, ErrorMatches, `snap "brand-gadget" is not removable: snap is used by the model`)
 }
 
+func (s *snapmgrTestSuite) TestRemoveWithDeviceContext(c *C) {
+	si := snap.SideInfo{
+		RealName: "brand-gadget",
+		Revision: snap.R(7),
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "brand-gadget", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{&si},
+		Current:  si.Revision,
+		SnapType: "gadget",
+	})
+
+	// the gadget is no longer used by the model of the device context
+	deviceCtx := &snapstatetest.TrivialDeviceContext{
+		DeviceModel: MakeModel(map[string]interface{}{"gadget": "other-gadget"}),
+	}
+
+	chg := s.state.NewChange("remodel", "...")
+	ts, err := snapstate.RemoveWithDeviceContext(s.state, "brand-gadget", snap.R(0), nil, deviceCtx, chg.ID())
+	c.Assert(err, IsNil)
+	c.Check(taskKinds(ts.Tasks()), DeepEquals, []string{
+		"stop-snap-services",
+		"run-hook[remove]",
+		"auto-disconnect",
+		"remove-aliases",
+		"unlink-snap",
+		"remove-profiles",
+		"clear-snap",
+		"discard-snap",
+	})
+}
+
 func (s *snapmgrTestSuite) TestRemoveRefusedLastRevision(c *C) {
 	si := snap.SideInfo{
 		RealName: "brand-gadget",
@@ -8274,6 +8381,26 @@ func (s *snapmgrTestSuite) TestRevertNoRevertAgain(c *C) {
 	c.Assert(ts, IsNil)
 }
 
+func (s *snapmgrTestSuite) TestRevertToIncompatibleEpoch(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-epoch-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-epoch-snap", SnapID: "some-epoch-snap-id", Revision: snap.R(5)},
+			{RealName: "some-epoch-snap", SnapID: "some-epoch-snap-id", Revision: snap.R(11)},
+		},
+		Current:  snap.R(11),
+		SnapType: "app",
+	})
+
+	// revision 5 with epoch 5 cannot read the data of epoch 13
+	ts, err := snapstate.Revert(s.state, "some-epoch-snap", snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `cannot revert "some-epoch-snap" to revision 5 with epoch 5, because it can't read the current epoch of 13`)
+	c.Assert(ts, IsNil)
+}
+
 func (s *snapmgrTestSuite) TestRevertNothingToRevertTo(c *C) {
 	si := snap.SideInfo{
 		RealName: "some-snap",
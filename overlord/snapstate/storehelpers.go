@@ -350,6 +350,7 @@ func refreshCandidates(ctx context.Context, st *state.State, names []string, use
 	stateByInstanceName := make(map[string]*SnapState, len(snapStates))
 	ignoreValidationByInstanceName := make(map[string]bool)
 	nCands := 0
+	now := timeNow()
 
 	addCand := func(installed *store.CurrentSnap, snapst *SnapState) {
 		// FIXME: snaps that are not active are skipped for now
@@ -363,6 +364,21 @@ func refreshCandidates(ctx context.Context, st *state.State, names []string, use
 			return
 		}
 
+		if opts.IsAutoRefresh && snapst.RefreshHold.Active(now) {
+			// auto-refreshes held by the user
+			return
+		}
+
+		if opts.IsAutoRefresh && snapst.GatedRefreshHold.Active(now) {
+			// auto-refresh held by the snap itself
+			return
+		}
+
+		if opts.IsAutoRefresh && AutoRefreshHeldByHealth != nil && AutoRefreshHeldByHealth(st, installed.InstanceName, snapst.Current) {
+			// the snap just went unhealthy
+			return
+		}
+
 		if len(names) > 0 && !strutil.SortedListContains(names, installed.InstanceName) {
 			return
 		}
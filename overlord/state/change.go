@@ -301,6 +301,9 @@ func (c *Change) Status() Status {
 // SetStatus sets the change status, overriding the default behavior (see Status method).
 func (c *Change) SetStatus(s Status) {
 	c.state.writing()
+	if c.status != s {
+		c.state.statusChanged = true
+	}
 	c.status = s
 	if s.Ready() {
 		c.markReady()
@@ -21,6 +21,8 @@
 package state
 
 import (
+	"bufio"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -43,6 +45,12 @@ type Backend interface {
 	RequestRestart(t RestartType)
 }
 
+// syncCheckpointer is implemented by backends which can delay
+// checkpoints, to write out a checkpoint right away instead.
+type syncCheckpointer interface {
+	CheckpointSync(data []byte) error
+}
+
 type customData map[string]*json.RawMessage
 
 func (data customData) get(key string, value interface{}) error {
@@ -108,6 +116,9 @@ type State struct {
 	warnings map[string]*Warning
 
 	modified bool
+	// statusChanged is set when the status of a task or change was
+	// modified since the last checkpoint
+	statusChanged bool
 
 	cache map[interface{}]interface{}
 
@@ -235,12 +246,19 @@ func (s *State) Unlock() {
 		return
 	}
 
+	checkpoint := s.backend.Checkpoint
+	if sc, ok := s.backend.(syncCheckpointer); ok && s.statusChanged {
+		// the task runner and the clients of the state act on the
+		// status of tasks and changes, which must not be lost
+		checkpoint = sc.CheckpointSync
+	}
 	data := s.checkpointData()
 	var err error
 	start := time.Now()
 	for time.Since(start) <= unlockCheckpointRetryMaxTime {
-		if err = s.backend.Checkpoint(data); err == nil {
+		if err = checkpoint(data); err == nil {
 			s.modified = false
+			s.statusChanged = false
 			return
 		}
 		time.Sleep(unlockCheckpointRetryInterval)
@@ -506,8 +524,21 @@ func (s *State) Prune(pruneWait, abortWait time.Duration, maxReadyChanges int) {
 	}
 }
 
-// ReadState returns the state deserialized from r.
+// ReadState returns the state deserialized from r, which can be gzip
+// compressed.
 func ReadState(backend Backend, r io.Reader) (*State, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read state: %s", err)
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+
 	s := new(State)
 	s.Lock()
 	defer s.unlock()
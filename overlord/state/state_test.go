@@ -21,6 +21,7 @@ package state_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"testing"
@@ -190,6 +191,48 @@ func (b *fakeStateBackend) RequestRestart(t state.RestartType) {
 	b.restartRequested = true
 }
 
+type syncFakeStateBackend struct {
+	fakeStateBackend
+	syncCheckpoints [][]byte
+}
+
+func (b *syncFakeStateBackend) CheckpointSync(data []byte) error {
+	b.syncCheckpoints = append(b.syncCheckpoints, data)
+	return nil
+}
+
+func (ss *stateSuite) TestCheckpointSyncOnStatusChange(c *C) {
+	b := new(syncFakeStateBackend)
+	st := state.New(b)
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t := st.NewTask("download", "...")
+	chg.AddTask(t)
+	st.Unlock()
+	c.Check(b.checkpoints, HasLen, 1)
+	c.Check(b.syncCheckpoints, HasLen, 0)
+
+	st.Lock()
+	t.SetStatus(state.DoingStatus)
+	st.Unlock()
+	c.Check(b.checkpoints, HasLen, 1)
+	c.Check(b.syncCheckpoints, HasLen, 1)
+
+	// setting the same status again is not a status change
+	st.Lock()
+	t.SetStatus(state.DoingStatus)
+	t.Set("a", 1)
+	st.Unlock()
+	c.Check(b.checkpoints, HasLen, 2)
+	c.Check(b.syncCheckpoints, HasLen, 1)
+
+	st.Lock()
+	chg.SetStatus(state.ErrorStatus)
+	st.Unlock()
+	c.Check(b.checkpoints, HasLen, 2)
+	c.Check(b.syncCheckpoints, HasLen, 2)
+}
+
 func (ss *stateSuite) TestImplicitCheckpointAndRead(c *C) {
 	b := new(fakeStateBackend)
 	st := state.New(b)
@@ -994,3 +1037,20 @@ func (ss *stateSuite) TestReadStateInitsCache(c *C) {
 	st.Cache("key", "value")
 	c.Assert(st.Cached("key"), Equals, "value")
 }
+
+func (ss *stateSuite) TestReadStateCompressed(c *C) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(`{"data": {"a": 1}}`))
+	c.Assert(err, IsNil)
+	c.Assert(zw.Close(), IsNil)
+
+	st, err := state.ReadState(nil, &buf)
+	c.Assert(err, IsNil)
+	st.Lock()
+	defer st.Unlock()
+
+	var v int
+	c.Assert(st.Get("a", &v), IsNil)
+	c.Check(v, Equals, 1)
+}
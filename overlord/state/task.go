@@ -201,6 +201,9 @@ func (t *Task) SetStatus(new Status) {
 	t.state.writing()
 	old := t.status
 	t.status = new
+	if old != new {
+		t.state.statusChanged = true
+	}
 	if !old.Ready() && new.Ready() {
 		t.readyTime = timeNow()
 	}
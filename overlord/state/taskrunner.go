@@ -62,6 +62,8 @@ type TaskRunner struct {
 	blocked     []blockedFunc
 	someBlocked bool
 
+	observeRun func(t *Task, status Status, dur time.Duration)
+
 	// go-routines lifecycle
 	tombs map[string]*tomb.Tomb
 }
@@ -142,6 +144,17 @@ func (r *TaskRunner) AddCleanup(kind string, cleanup HandlerFunc) {
 	r.cleanups[kind] = cleanup
 }
 
+// ObserveRuns registers a function that is called, with the state
+// locked, every time a handler of a task returns. It gets the status
+// the task was run in (DoingStatus or UndoingStatus) and how long the
+// handler took.
+func (r *TaskRunner) ObserveRuns(observe func(t *Task, status Status, dur time.Duration)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.observeRun = observe
+}
+
 // SetBlocked sets a predicate function to decide whether to block a task from running based on the current running tasks. It can be used to control task serialisation.
 func (r *TaskRunner) SetBlocked(pred func(t *Task, running []*Task) bool) {
 	r.mu.Lock()
@@ -162,6 +175,7 @@ func (r *TaskRunner) AddBlocked(pred func(t *Task, running []*Task) bool) {
 func (r *TaskRunner) run(t *Task) {
 	var handler HandlerFunc
 	var accuRuntime func(dur time.Duration)
+	var runStatus Status
 	switch t.Status() {
 	case DoStatus:
 		t.SetStatus(DoingStatus)
@@ -169,6 +183,7 @@ func (r *TaskRunner) run(t *Task) {
 	case DoingStatus:
 		handler = r.handlerPair(t).do
 		accuRuntime = t.accumulateDoingTime
+		runStatus = DoingStatus
 
 	case UndoStatus:
 		t.SetStatus(UndoingStatus)
@@ -176,6 +191,7 @@ func (r *TaskRunner) run(t *Task) {
 	case UndoingStatus:
 		handler = r.handlerPair(t).undo
 		accuRuntime = t.accumulateUndoingTime
+		runStatus = UndoingStatus
 
 	default:
 		panic("internal error: attempted to run task in status " + t.Status().String())
@@ -201,6 +217,9 @@ func (r *TaskRunner) run(t *Task) {
 		r.state.Lock()
 		defer r.state.Unlock()
 		accuRuntime(t1.Sub(t0))
+		if r.observeRun != nil {
+			r.observeRun(t, runStatus, t1.Sub(t0))
+		}
 
 		delete(r.tombs, t.ID())
 
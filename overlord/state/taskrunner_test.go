@@ -371,6 +371,49 @@ func (ts *taskRunnerSuite) TestExternalAbort(c *C) {
 	ensureChange(c, r, sb, chg)
 }
 
+func (ts *taskRunnerSuite) TestObserveRuns(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	type run struct {
+		kind   string
+		status state.Status
+	}
+	var runs []run
+	r.ObserveRuns(func(t *state.Task, status state.Status, dur time.Duration) {
+		c.Check(dur >= 0, Equals, true)
+		runs = append(runs, run{t.Kind(), status})
+	})
+
+	r.AddHandler("foo", func(t *state.Task, tb *tomb.Tomb) error {
+		return nil
+	}, func(t *state.Task, tb *tomb.Tomb) error {
+		return nil
+	})
+	r.AddHandler("bar", func(t *state.Task, tb *tomb.Tomb) error {
+		return errors.New("boom")
+	}, nil)
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("foo", "...")
+	t2 := st.NewTask("bar", "...")
+	t2.WaitFor(t1)
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	st.Unlock()
+
+	ensureChange(c, r, sb, chg)
+
+	c.Check(runs, DeepEquals, []run{
+		{"foo", state.DoingStatus},
+		{"bar", state.DoingStatus},
+		{"foo", state.UndoingStatus},
+	})
+}
+
 func (ts *taskRunnerSuite) TestStopHandlerJustFinishing(c *C) {
 	sb := &stateBackend{}
 	st := state.New(sb)
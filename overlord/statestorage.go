@@ -0,0 +1,340 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package overlord
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/snapcore/bolt"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// stateStorage persists the serialized state.
+type stateStorage interface {
+	// Exists returns whether a state was persisted already.
+	Exists() bool
+	// Open returns a reader for the persisted state.
+	Open() (io.ReadCloser, error)
+	// Write persists the given serialized state.
+	Write(data []byte) error
+	// Remove removes the persisted state.
+	Remove() error
+	// Close releases any resources held by the storage, it is
+	// reopened as needed.
+	Close() error
+}
+
+// newStateStorage returns the storage with the given name for the
+// state, kept next to the given path of the JSON state file.
+func newStateStorage(name, path string, compress bool) (stateStorage, error) {
+	switch name {
+	case "", "json":
+		return &jsonStateStorage{path: path, compress: compress}, nil
+	case "bolt":
+		return &boltStateStorage{path: boltStatePath(path)}, nil
+	}
+	return nil, fmt.Errorf("unknown state storage %q", name)
+}
+
+// jsonStateStorage keeps the state as a single JSON document, which is
+// rewritten on every checkpoint. This is the default.
+type jsonStateStorage struct {
+	path string
+	// compress makes the state be written out gzip compressed while
+	// snapd runs, see Close
+	compress bool
+}
+
+func (js *jsonStateStorage) Exists() bool {
+	return osutil.FileExists(js.path)
+}
+
+func (js *jsonStateStorage) Open() (io.ReadCloser, error) {
+	return os.Open(js.path)
+}
+
+func (js *jsonStateStorage) Write(data []byte) error {
+	if js.compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	// the state file is synced before being renamed into place and
+	// its directory is synced after
+	return osutil.AtomicWriteFile(js.path, data, 0600, 0)
+}
+
+func (js *jsonStateStorage) Remove() error {
+	return os.Remove(js.path)
+}
+
+// Close leaves the state file uncompressed behind, so that a snapd not
+// knowing about compressed state, e.g. after reverting snapd, can still
+// read it.
+func (js *jsonStateStorage) Close() error {
+	if !js.compress {
+		return nil
+	}
+	f, err := os.Open(js.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	if magic, err := br.Peek(2); err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		// not compressed
+		return nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return fmt.Errorf("cannot uncompress state: %v", err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return fmt.Errorf("cannot uncompress state: %v", err)
+	}
+	return osutil.AtomicWriteFile(js.path, data, 0600, 0)
+}
+
+// boltStatePath returns the path of the state database kept next to the
+// given JSON state file, with its extension, if any, replaced.
+func boltStatePath(jsonPath string) string {
+	return strings.TrimSuffix(jsonPath, filepath.Ext(jsonPath)) + ".db"
+}
+
+var (
+	// boltStateSplitKeys are the top-level keys of the state whose
+	// entries are stored individually, in buckets of the same name
+	boltStateSplitKeys = []string{"data", "changes", "tasks"}
+	// boltStateMetaBucket holds the other top-level keys of the state
+	boltStateMetaBucket = []byte("meta")
+)
+
+// boltStateStorage keeps the state in a bolt database, storing the
+// individual changes, tasks and data entries as separate keys. On
+// checkpoint only the entries that changed are written out, which
+// keeps the IO proportional to the changes instead of to the size of
+// the whole state.
+type boltStateStorage struct {
+	path string
+	db   *bolt.DB
+}
+
+func (bs *boltStateStorage) Exists() bool {
+	return osutil.FileExists(bs.path)
+}
+
+func (bs *boltStateStorage) open() error {
+	if bs.db != nil {
+		return nil
+	}
+	db, err := bolt.Open(bs.path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return fmt.Errorf("cannot open state database: %v", err)
+	}
+	bs.db = db
+	return nil
+}
+
+func (bs *boltStateStorage) Open() (io.ReadCloser, error) {
+	if err := bs.open(); err != nil {
+		return nil, err
+	}
+	state := make(map[string]json.RawMessage)
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		for _, key := range boltStateSplitKeys {
+			b := tx.Bucket([]byte(key))
+			if b == nil {
+				continue
+			}
+			entries := make(map[string]json.RawMessage)
+			err := b.ForEach(func(k, v []byte) error {
+				entries[string(k)] = append(json.RawMessage(nil), v...)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(entries)
+			if err != nil {
+				return err
+			}
+			state[key] = data
+		}
+		if b := tx.Bucket(boltStateMetaBucket); b != nil {
+			return b.ForEach(func(k, v []byte) error {
+				state[string(k)] = append(json.RawMessage(nil), v...)
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read state database: %v", err)
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// syncBucket makes the bucket hold exactly the given entries, only
+// touching the keys whose values differ.
+func syncBucket(b *bolt.Bucket, entries map[string]json.RawMessage) error {
+	var stale [][]byte
+	err := b.ForEach(func(k, v []byte) error {
+		if _, ok := entries[string(k)]; !ok {
+			stale = append(stale, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range stale {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	for k, v := range entries {
+		if bytes.Equal(b.Get([]byte(k)), v) {
+			continue
+		}
+		if err := b.Put([]byte(k), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (bs *boltStateStorage) Write(data []byte) error {
+	var state map[string]json.RawMessage
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("cannot split state: %v", err)
+	}
+	split := make(map[string]map[string]json.RawMessage, len(boltStateSplitKeys))
+	for _, key := range boltStateSplitKeys {
+		var entries map[string]json.RawMessage
+		if raw, ok := state[key]; ok {
+			if err := json.Unmarshal(raw, &entries); err != nil {
+				return fmt.Errorf("cannot split state: %v", err)
+			}
+			delete(state, key)
+		}
+		split[key] = entries
+	}
+
+	if err := bs.open(); err != nil {
+		return err
+	}
+	// the transaction is synced to disk when committed
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		for key, entries := range split {
+			b, err := tx.CreateBucketIfNotExists([]byte(key))
+			if err != nil {
+				return err
+			}
+			if err := syncBucket(b, entries); err != nil {
+				return err
+			}
+		}
+		b, err := tx.CreateBucketIfNotExists(boltStateMetaBucket)
+		if err != nil {
+			return err
+		}
+		return syncBucket(b, state)
+	})
+}
+
+func (bs *boltStateStorage) Remove() error {
+	if err := bs.Close(); err != nil {
+		return err
+	}
+	return os.Remove(bs.path)
+}
+
+func (bs *boltStateStorage) Close() error {
+	if bs.db == nil {
+		return nil
+	}
+	err := bs.db.Close()
+	bs.db = nil
+	return err
+}
+
+// migrateStateStorage copies over the state persisted by the other kind
+// of storage to the given one, so that switching the storage does not
+// lose the state. The other storage is returned, if it exists, and must
+// be removed by the caller only once the state was loaded back from the
+// given storage; until then it remains the reference and the state is
+// copied over again.
+func migrateStateStorage(to stateStorage, path string) (from stateStorage, err error) {
+	if _, ok := to.(*boltStateStorage); ok {
+		from = &jsonStateStorage{path: path}
+	} else {
+		from = &boltStateStorage{path: boltStatePath(path)}
+	}
+	if !from.Exists() {
+		return nil, nil
+	}
+	defer from.Close()
+
+	r, err := from.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	st, err := state.ReadState(nil, r)
+	if err != nil {
+		return nil, err
+	}
+	st.Lock()
+	data, err := json.Marshal(st)
+	st.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if err := to.Write(data); err != nil {
+		return nil, err
+	}
+	return from, nil
+}
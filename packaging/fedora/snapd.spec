@@ -627,8 +627,9 @@ install -m 644 -D data/polkit/io.snapcraft.snapd.policy %{buildroot}%{_datadir}/
 # Disable re-exec by default
 echo 'SNAP_REEXEC=0' > %{buildroot}%{_sysconfdir}/sysconfig/snapd
 
-# Create state.json and the README file to be ghosted
+# Create state.json, state.db and the README file to be ghosted
 touch %{buildroot}%{_sharedstatedir}/snapd/state.json
+touch %{buildroot}%{_sharedstatedir}/snapd/state.db
 touch %{buildroot}%{_sharedstatedir}/snapd/snap/README
 
 # When enabled, create a symlink for /snap to point to /var/lib/snapd/snap
@@ -729,6 +730,8 @@ popd
 %{_userunitdir}/snapd.session-agent.socket
 %{_datadir}/dbus-1/services/io.snapcraft.Launcher.service
 %{_datadir}/dbus-1/services/io.snapcraft.Settings.service
+%{_datadir}/dbus-1/session.d/snapd.session-services.conf
+%{_datadir}/dbus-1/system.d/snapd.system-services.conf
 %{_datadir}/polkit-1/actions/io.snapcraft.snapd.policy
 %{_sysconfdir}/xdg/autostart/snap-userd-autostart.desktop
 %config(noreplace) %{_sysconfdir}/sysconfig/snapd
@@ -753,6 +756,7 @@ popd
 %dir %{_localstatedir}/cache/snapd
 %dir %{_localstatedir}/snap
 %ghost %{_sharedstatedir}/snapd/state.json
+%ghost %{_sharedstatedir}/snapd/state.db
 %ghost %{_sharedstatedir}/snapd/snap/README
 %if %{with snap_symlink}
 /snap
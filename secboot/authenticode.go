@@ -0,0 +1,141 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secboot
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+const (
+	peSignatureOffsetOffset = 0x3c
+	coffHeaderSize          = 20
+	sectionHeaderSize       = 40
+
+	pe32Magic     = 0x10b
+	pe32PlusMagic = 0x20b
+
+	// index of the certificate table among the data directories
+	certificateTableIndex = 4
+)
+
+type peRange struct {
+	start, end uint32
+}
+
+// authenticodeDigest returns the SHA-256 Authenticode digest of the given
+// PE image, which is what the firmware measures when loading an EFI binary.
+// The digest covers the whole image except for the checksum, the entry
+// of the certificate table and the certificate table itself.
+func authenticodeDigest(image []byte) ([]byte, error) {
+	size := uint32(len(image))
+	u16 := func(off uint32) uint16 { return binary.LittleEndian.Uint16(image[off:]) }
+	u32 := func(off uint32) uint32 { return binary.LittleEndian.Uint32(image[off:]) }
+
+	if size < peSignatureOffsetOffset+4 || u16(0) != 0x5a4d {
+		return nil, fmt.Errorf("not a PE image")
+	}
+	peOff := u32(peSignatureOffsetOffset)
+	if uint64(peOff)+4+coffHeaderSize > uint64(size) || string(image[peOff:peOff+4]) != "PE\x00\x00" {
+		return nil, fmt.Errorf("not a PE image")
+	}
+	coffOff := peOff + 4
+	numSections := uint32(u16(coffOff + 2))
+	optHeaderSize := uint32(u16(coffOff + 16))
+	optOff := coffOff + coffHeaderSize
+	if uint64(optOff)+uint64(optHeaderSize) > uint64(size) || optHeaderSize < 2 {
+		return nil, fmt.Errorf("cannot read optional header")
+	}
+
+	var numDirsOff, dirsOff uint32
+	switch u16(optOff) {
+	case pe32Magic:
+		numDirsOff, dirsOff = optOff+92, optOff+96
+	case pe32PlusMagic:
+		numDirsOff, dirsOff = optOff+108, optOff+112
+	default:
+		return nil, fmt.Errorf("unknown optional header magic %#x", u16(optOff))
+	}
+	if numDirsOff+4 > optOff+optHeaderSize {
+		return nil, fmt.Errorf("cannot read optional header")
+	}
+	checksumOff := optOff + 64
+	sizeOfHeaders := u32(optOff + 60)
+	if sizeOfHeaders > size {
+		return nil, fmt.Errorf("invalid size of headers %d", sizeOfHeaders)
+	}
+
+	var certDirOff, certSize uint32
+	if u32(numDirsOff) > certificateTableIndex {
+		certDirOff = dirsOff + certificateTableIndex*8
+		if certDirOff+8 > optOff+optHeaderSize {
+			return nil, fmt.Errorf("cannot read optional header")
+		}
+		certSize = u32(certDirOff + 4)
+	}
+
+	h := sha256.New()
+	// the headers, skipping the checksum and the certificate table entry
+	if certDirOff != 0 {
+		h.Write(image[:checksumOff])
+		h.Write(image[checksumOff+4 : certDirOff])
+		h.Write(image[certDirOff+8 : sizeOfHeaders])
+	} else {
+		h.Write(image[:checksumOff])
+		h.Write(image[checksumOff+4 : sizeOfHeaders])
+	}
+	hashed := sizeOfHeaders
+
+	// the sections, in the order of their data in the image
+	sectionsOff := optOff + optHeaderSize
+	if uint64(sectionsOff)+uint64(numSections)*sectionHeaderSize > uint64(size) {
+		return nil, fmt.Errorf("cannot read section table")
+	}
+	sections := make([]peRange, 0, numSections)
+	for i := uint32(0); i < numSections; i++ {
+		hdr := sectionsOff + i*sectionHeaderSize
+		rawSize := u32(hdr + 16)
+		rawOff := u32(hdr + 20)
+		if rawSize == 0 {
+			continue
+		}
+		if uint64(rawOff)+uint64(rawSize) > uint64(size) {
+			return nil, fmt.Errorf("section %d is out of bounds", i)
+		}
+		sections = append(sections, peRange{rawOff, rawOff + rawSize})
+	}
+	sort.Slice(sections, func(i, j int) bool { return sections[i].start < sections[j].start })
+	for _, s := range sections {
+		h.Write(image[s.start:s.end])
+		hashed += s.end - s.start
+	}
+
+	// any data after the sections which is not the certificate table
+	if size > hashed {
+		if certSize > size-hashed {
+			return nil, fmt.Errorf("invalid certificate table size %d", certSize)
+		}
+		h.Write(image[hashed : size-certSize])
+	}
+
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,133 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secboot_test
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/secboot"
+)
+
+type authenticodeSuite struct{}
+
+var _ = Suite(&authenticodeSuite{})
+
+const (
+	peOffset      = 0x40
+	optOffset     = peOffset + 4 + 20
+	checksumOff   = optOffset + 64
+	certDirOff    = optOffset + 112 + 4*8
+	sectionsOff   = optOffset + 240
+	sizeOfHeaders = 0x200
+)
+
+// makePE returns a minimal PE32+ image with a single section holding the
+// payload, followed by the trailer and the certificate table cert.
+func makePE(payload, trailer, cert []byte, checksum uint32) []byte {
+	image := make([]byte, sizeOfHeaders)
+	le := binary.LittleEndian
+	copy(image, "MZ")
+	le.PutUint32(image[0x3c:], peOffset)
+	copy(image[peOffset:], "PE\x00\x00")
+	// COFF header
+	le.PutUint16(image[peOffset+4:], 0x8664)
+	le.PutUint16(image[peOffset+4+2:], 1)
+	le.PutUint16(image[peOffset+4+16:], 240)
+	// optional header
+	le.PutUint16(image[optOffset:], 0x20b)
+	le.PutUint32(image[optOffset+60:], sizeOfHeaders)
+	le.PutUint32(image[checksumOff:], checksum)
+	le.PutUint32(image[optOffset+108:], 16)
+	// section table
+	copy(image[sectionsOff:], ".text")
+	le.PutUint32(image[sectionsOff+16:], uint32(len(payload)))
+	le.PutUint32(image[sectionsOff+20:], sizeOfHeaders)
+
+	image = append(image, payload...)
+	image = append(image, trailer...)
+	if len(cert) > 0 {
+		le.PutUint32(image[certDirOff:], uint32(len(image)))
+		le.PutUint32(image[certDirOff+4:], uint32(len(cert)))
+		image = append(image, cert...)
+	}
+	return image
+}
+
+func (s *authenticodeSuite) TestDigest(c *C) {
+	image := makePE([]byte("payload"), nil, nil, 0)
+	digest, err := secboot.AuthenticodeDigest(image)
+	c.Assert(err, IsNil)
+
+	h := sha256.New()
+	h.Write(image[:checksumOff])
+	h.Write(image[checksumOff+4 : certDirOff])
+	h.Write(image[certDirOff+8 : sizeOfHeaders])
+	h.Write([]byte("payload"))
+	c.Check(digest, DeepEquals, h.Sum(nil))
+	// not a plain digest of the file
+	plain := sha256.Sum256(image)
+	c.Check(digest, Not(DeepEquals), plain[:])
+}
+
+func (s *authenticodeSuite) TestDigestIgnoresChecksumAndSignature(c *C) {
+	digest, err := secboot.AuthenticodeDigest(makePE([]byte("payload"), []byte("trailer"), nil, 0))
+	c.Assert(err, IsNil)
+
+	// the checksum and the certificate table are not covered
+	signed, err := secboot.AuthenticodeDigest(makePE([]byte("payload"), []byte("trailer"), []byte("signature"), 0x1234))
+	c.Assert(err, IsNil)
+	c.Check(signed, DeepEquals, digest)
+
+	// the content is
+	other, err := secboot.AuthenticodeDigest(makePE([]byte("payloaD"), []byte("trailer"), nil, 0))
+	c.Assert(err, IsNil)
+	c.Check(other, Not(DeepEquals), digest)
+	other, err = secboot.AuthenticodeDigest(makePE([]byte("payload"), []byte("traileR"), nil, 0))
+	c.Assert(err, IsNil)
+	c.Check(other, Not(DeepEquals), digest)
+}
+
+func (s *authenticodeSuite) TestDigestErrors(c *C) {
+	_, err := secboot.AuthenticodeDigest([]byte("not a PE image at all, really not one at all, but long enough"))
+	c.Check(err, ErrorMatches, "not a PE image")
+
+	image := makePE([]byte("payload"), nil, nil, 0)
+	copy(image[peOffset:], "NE")
+	_, err = secboot.AuthenticodeDigest(image)
+	c.Check(err, ErrorMatches, "not a PE image")
+
+	image = makePE([]byte("payload"), nil, nil, 0)
+	binary.LittleEndian.PutUint16(image[optOffset:], 0x107)
+	_, err = secboot.AuthenticodeDigest(image)
+	c.Check(err, ErrorMatches, "unknown optional header magic 0x107")
+
+	image = makePE([]byte("payload"), nil, nil, 0)
+	binary.LittleEndian.PutUint32(image[sectionsOff+16:], 4096)
+	_, err = secboot.AuthenticodeDigest(image)
+	c.Check(err, ErrorMatches, "section 0 is out of bounds")
+
+	image = makePE([]byte("payload"), []byte("trailer"), []byte("signature"), 0)
+	binary.LittleEndian.PutUint32(image[certDirOff+4:], 4096)
+	_, err = secboot.AuthenticodeDigest(image)
+	c.Check(err, ErrorMatches, "invalid certificate table size 4096")
+}
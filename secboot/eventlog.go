@@ -0,0 +1,172 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secboot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	evNoAction                   = 0x00000003
+	evEFIBootServicesApplication = 0x80000003
+
+	tpmAlgSHA256 = 0x000b
+)
+
+var specIDEventSignature = []byte("Spec ID Event03\x00")
+
+// logEvent is an event of the TCG event log, with its SHA-256 digest.
+type logEvent struct {
+	PCR    uint32
+	Type   uint32
+	Digest []byte
+}
+
+// readEventLog reads the events of a crypto agile TCG event log, as found in
+// /sys/kernel/security/tpm0/binary_bios_measurements.
+func readEventLog(r io.Reader) ([]logEvent, error) {
+	var hdr struct {
+		PCR    uint32
+		Type   uint32
+		Digest [20]byte
+		Size   uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, fmt.Errorf("cannot read event log header: %v", err)
+	}
+	specID := make([]byte, hdr.Size)
+	if _, err := io.ReadFull(r, specID); err != nil {
+		return nil, fmt.Errorf("cannot read event log header: %v", err)
+	}
+	digestSizes, err := parseSpecIDEvent(specID)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := digestSizes[tpmAlgSHA256]; !ok {
+		return nil, fmt.Errorf("event log has no SHA-256 digests")
+	}
+
+	var events []logEvent
+	for {
+		var evHdr struct {
+			PCR   uint32
+			Type  uint32
+			Count uint32
+		}
+		err := binary.Read(r, binary.LittleEndian, &evHdr)
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read event %d: %v", len(events), err)
+		}
+		ev := logEvent{PCR: evHdr.PCR, Type: evHdr.Type}
+		for i := uint32(0); i < evHdr.Count; i++ {
+			var alg uint16
+			if err := binary.Read(r, binary.LittleEndian, &alg); err != nil {
+				return nil, fmt.Errorf("cannot read event %d: %v", len(events), err)
+			}
+			size, ok := digestSizes[alg]
+			if !ok {
+				return nil, fmt.Errorf("cannot read event %d: unknown digest algorithm %#x", len(events), alg)
+			}
+			digest := make([]byte, size)
+			if _, err := io.ReadFull(r, digest); err != nil {
+				return nil, fmt.Errorf("cannot read event %d: %v", len(events), err)
+			}
+			if alg == tpmAlgSHA256 {
+				ev.Digest = digest
+			}
+		}
+		var size uint32
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, fmt.Errorf("cannot read event %d: %v", len(events), err)
+		}
+		if _, err := io.CopyN(ioutil.Discard, r, int64(size)); err != nil {
+			return nil, fmt.Errorf("cannot read event %d: %v", len(events), err)
+		}
+		if ev.Digest == nil && ev.Type != evNoAction {
+			return nil, fmt.Errorf("cannot read event %d: no SHA-256 digest", len(events))
+		}
+		events = append(events, ev)
+	}
+}
+
+// parseSpecIDEvent returns the sizes of the digests by algorithm, as
+// declared by the Spec ID event starting the log.
+func parseSpecIDEvent(data []byte) (map[uint16]int, error) {
+	if !bytes.HasPrefix(data, specIDEventSignature) {
+		return nil, fmt.Errorf("event log is not in the crypto agile format")
+	}
+	r := bytes.NewReader(data[len(specIDEventSignature):])
+	var hdr struct {
+		PlatformClass uint32
+		VersionMinor  uint8
+		VersionMajor  uint8
+		Errata        uint8
+		UintnSize     uint8
+		NumAlgorithms uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, fmt.Errorf("cannot read Spec ID event: %v", err)
+	}
+	sizes := make(map[uint16]int, hdr.NumAlgorithms)
+	for i := uint32(0); i < hdr.NumAlgorithms; i++ {
+		var alg struct {
+			ID   uint16
+			Size uint16
+		}
+		if err := binary.Read(r, binary.LittleEndian, &alg); err != nil {
+			return nil, fmt.Errorf("cannot read Spec ID event: %v", err)
+		}
+		sizes[alg.ID] = int(alg.Size)
+	}
+	return sizes, nil
+}
+
+// extendPCR returns the value of a PCR after extending it with the digest.
+func extendPCR(pcr, digest []byte) []byte {
+	h := sha256.New()
+	h.Write(pcr)
+	h.Write(digest)
+	return h.Sum(nil)
+}
+
+// replayPCR returns the value of the PCR after the events of the log until
+// stop returns true for one of them.
+func replayPCR(events []logEvent, pcr uint32, stop func(ev *logEvent) bool) []byte {
+	value := make([]byte, sha256.Size)
+	for i := range events {
+		ev := &events[i]
+		if ev.PCR != pcr || ev.Type == evNoAction {
+			continue
+		}
+		if stop != nil && stop(ev) {
+			break
+		}
+		value = extendPCR(value, ev.Digest)
+	}
+	return value
+}
@@ -0,0 +1,151 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secboot_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/secboot"
+)
+
+type eventLogSuite struct{}
+
+var _ = Suite(&eventLogSuite{})
+
+type mockEvent struct {
+	pcr  uint32
+	typ  uint32
+	data string
+}
+
+const (
+	evNoAction                   = 0x00000003
+	evSeparator                  = 0x00000004
+	evEFIVariableDriverConfig    = 0x80000001
+	evEFIBootServicesApplication = 0x80000003
+	evEFIAction                  = 0x80000007
+)
+
+// makeEventLog returns a crypto agile TCG event log with SHA-1 and SHA-256
+// digests of the data of the given events.
+func makeEventLog(events []mockEvent) []byte {
+	var log bytes.Buffer
+	le := binary.LittleEndian
+
+	var specID bytes.Buffer
+	specID.WriteString("Spec ID Event03\x00")
+	binary.Write(&specID, le, uint32(0))   // platform class
+	specID.Write([]byte{0, 2, 0, 2})       // version and uintn size
+	binary.Write(&specID, le, uint32(2))   // number of algorithms
+	binary.Write(&specID, le, uint16(0x4)) // SHA-1
+	binary.Write(&specID, le, uint16(sha1.Size))
+	binary.Write(&specID, le, uint16(0xb)) // SHA-256
+	binary.Write(&specID, le, uint16(sha256.Size))
+	specID.WriteByte(0) // vendor info size
+
+	binary.Write(&log, le, uint32(0))
+	binary.Write(&log, le, uint32(evNoAction))
+	log.Write(make([]byte, sha1.Size))
+	binary.Write(&log, le, uint32(specID.Len()))
+	log.Write(specID.Bytes())
+
+	for _, ev := range events {
+		binary.Write(&log, le, ev.pcr)
+		binary.Write(&log, le, ev.typ)
+		binary.Write(&log, le, uint32(2))
+		d1 := sha1.Sum([]byte(ev.data))
+		binary.Write(&log, le, uint16(0x4))
+		log.Write(d1[:])
+		d256 := sha256.Sum256([]byte(ev.data))
+		binary.Write(&log, le, uint16(0xb))
+		log.Write(d256[:])
+		binary.Write(&log, le, uint32(len(ev.data)))
+		log.WriteString(ev.data)
+	}
+	return log.Bytes()
+}
+
+var mockEvents = []mockEvent{
+	{pcr: 7, typ: evEFIVariableDriverConfig, data: "SecureBoot"},
+	{pcr: 7, typ: evEFIVariableDriverConfig, data: "PK"},
+	{pcr: 4, typ: evEFIAction, data: "Calling EFI Application from Boot Option"},
+	{pcr: 0, typ: evNoAction, data: "StartupLocality"},
+	{pcr: 4, typ: evSeparator, data: "\x00\x00\x00\x00"},
+	{pcr: 7, typ: evSeparator, data: "\x00\x00\x00\x00"},
+	{pcr: 4, typ: evEFIBootServicesApplication, data: "shim"},
+	{pcr: 4, typ: evEFIBootServicesApplication, data: "grub"},
+}
+
+func extend(pcr []byte, data string) []byte {
+	digest := sha256.Sum256([]byte(data))
+	h := sha256.New()
+	h.Write(pcr)
+	h.Write(digest[:])
+	return h.Sum(nil)
+}
+
+func (s *eventLogSuite) TestReadEventLog(c *C) {
+	events, err := secboot.ReadEventLog(bytes.NewReader(makeEventLog(mockEvents)))
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, len(mockEvents))
+	for i, ev := range events {
+		digest := sha256.Sum256([]byte(mockEvents[i].data))
+		c.Check(ev, DeepEquals, secboot.LogEvent{
+			PCR:    mockEvents[i].pcr,
+			Type:   mockEvents[i].typ,
+			Digest: digest[:],
+		})
+	}
+}
+
+func (s *eventLogSuite) TestReplayPCR(c *C) {
+	events, err := secboot.ReadEventLog(bytes.NewReader(makeEventLog(mockEvents)))
+	c.Assert(err, IsNil)
+
+	zero := make([]byte, sha256.Size)
+	pcr7 := extend(extend(extend(zero, "SecureBoot"), "PK"), "\x00\x00\x00\x00")
+	c.Check(secboot.ReplayPCR(events, 7, false), DeepEquals, pcr7)
+
+	pcr4 := extend(extend(zero, "Calling EFI Application from Boot Option"), "\x00\x00\x00\x00")
+	c.Check(secboot.ReplayPCR(events, 4, true), DeepEquals, pcr4)
+	c.Check(secboot.ReplayPCR(events, 4, false), DeepEquals, extend(extend(pcr4, "shim"), "grub"))
+
+	c.Check(secboot.ReplayPCR(events, 12, false), DeepEquals, zero)
+}
+
+func (s *eventLogSuite) TestReadEventLogErrors(c *C) {
+	_, err := secboot.ReadEventLog(bytes.NewReader(nil))
+	c.Check(err, ErrorMatches, "cannot read event log header: EOF")
+
+	log := makeEventLog(mockEvents)
+	// not a Spec ID event
+	notAgile := append([]byte(nil), log...)
+	copy(notAgile[32:], "Spec ID Event02")
+	_, err = secboot.ReadEventLog(bytes.NewReader(notAgile))
+	c.Check(err, ErrorMatches, "event log is not in the crypto agile format")
+
+	_, err = secboot.ReadEventLog(bytes.NewReader(log[:len(log)-2]))
+	c.Check(err, ErrorMatches, "cannot read event 7: EOF")
+}
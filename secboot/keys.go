@@ -0,0 +1,107 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secboot
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+const (
+	encryptionKeySize = 64
+	recoveryKeySize   = 16
+)
+
+// EncryptionKey is the key used to encrypt the data partition.
+type EncryptionKey [encryptionKeySize]byte
+
+// NewEncryptionKey returns a new random encryption key.
+func NewEncryptionKey() (EncryptionKey, error) {
+	var key EncryptionKey
+	// rand.Read() is protected against short reads
+	_, err := rand.Read(key[:])
+	// On return, n == len(b) if and only if err == nil
+	return key, err
+}
+
+// Save writes the key in the location specified by filename.
+func (key EncryptionKey) Save(filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(filename, key[:], 0600, 0)
+}
+
+// RecoveryKey is a key used to unlock the data partition when the encryption
+// key cannot be unsealed, it is meant to be noted down by the user.
+type RecoveryKey [recoveryKeySize]byte
+
+// NewRecoveryKey returns a new random recovery key.
+func NewRecoveryKey() (RecoveryKey, error) {
+	var key RecoveryKey
+	_, err := rand.Read(key[:])
+	return key, err
+}
+
+// String returns the recovery key in the form presented to the user, that is
+// eight groups of five digits separated by dashes.
+func (key RecoveryKey) String() string {
+	groups := make([]string, recoveryKeySize/2)
+	for i := range groups {
+		groups[i] = fmt.Sprintf("%05d", binary.LittleEndian.Uint16(key[i*2:]))
+	}
+	return strings.Join(groups, "-")
+}
+
+// Save writes the key in the location specified by filename.
+func (key RecoveryKey) Save(filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(filename, key[:], 0600, 0)
+}
+
+// ParseRecoveryKey parses a recovery key in the form returned by
+// RecoveryKey.String().
+func ParseRecoveryKey(s string) (RecoveryKey, error) {
+	var key RecoveryKey
+	groups := strings.Split(strings.TrimSpace(s), "-")
+	if len(groups) != recoveryKeySize/2 {
+		return RecoveryKey{}, fmt.Errorf("cannot parse recovery key: incorrect number of groups")
+	}
+	for i, group := range groups {
+		if len(group) != 5 {
+			return RecoveryKey{}, fmt.Errorf("cannot parse recovery key: incorrect length of group %d", i+1)
+		}
+		v, err := strconv.ParseUint(group, 10, 16)
+		if err != nil {
+			return RecoveryKey{}, fmt.Errorf("cannot parse recovery key: invalid group %d", i+1)
+		}
+		binary.LittleEndian.PutUint16(key[i*2:], uint16(v))
+	}
+	return key, nil
+}
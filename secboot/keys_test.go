@@ -0,0 +1,80 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secboot_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/secboot"
+)
+
+type keysSuite struct{}
+
+var _ = Suite(&keysSuite{})
+
+func (s *keysSuite) TestEncryptionKey(c *C) {
+	key1, err := secboot.NewEncryptionKey()
+	c.Assert(err, IsNil)
+	key2, err := secboot.NewEncryptionKey()
+	c.Assert(err, IsNil)
+	c.Check(key1, Not(DeepEquals), key2)
+
+	keyFile := filepath.Join(c.MkDir(), "some/dir/key")
+	c.Assert(key1.Save(keyFile), IsNil)
+	data, err := ioutil.ReadFile(keyFile)
+	c.Assert(err, IsNil)
+	c.Check(data, DeepEquals, key1[:])
+}
+
+func (s *keysSuite) TestRecoveryKeyString(c *C) {
+	var key secboot.RecoveryKey
+	for i := range key {
+		key[i] = byte(i)
+	}
+	c.Check(key.String(), Equals, "00256-00770-01284-01798-02312-02826-03340-03854")
+
+	parsed, err := secboot.ParseRecoveryKey(key.String() + "\n")
+	c.Assert(err, IsNil)
+	c.Check(parsed, Equals, key)
+
+	key, err = secboot.NewRecoveryKey()
+	c.Assert(err, IsNil)
+	parsed, err = secboot.ParseRecoveryKey(key.String())
+	c.Assert(err, IsNil)
+	c.Check(parsed, Equals, key)
+}
+
+func (s *keysSuite) TestParseRecoveryKeyErrors(c *C) {
+	for _, tc := range []struct {
+		key string
+		err string
+	}{
+		{"00000-00000", "cannot parse recovery key: incorrect number of groups"},
+		{"00000-00000-00000-00000-00000-00000-00000-0000", "cannot parse recovery key: incorrect length of group 8"},
+		{"00000-00000-00000-00000-00000-00000-00000-99999", "cannot parse recovery key: invalid group 8"},
+		{"0000a-00000-00000-00000-00000-00000-00000-00000", "cannot parse recovery key: invalid group 1"},
+	} {
+		_, err := secboot.ParseRecoveryKey(tc.key)
+		c.Check(err, ErrorMatches, tc.err)
+	}
+}
@@ -0,0 +1,273 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package secboot implements the sealing of the keys protecting the
+// encrypted data partition of a system to the TPM, against the measurements
+// of the boot process, and their unsealing at boot.
+package secboot
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/snap/squashfs"
+)
+
+const (
+	// BootManagerCodePCR is the PCR which the firmware extends with the
+	// measurements of the EFI binaries it loads.
+	BootManagerCodePCR = 4
+	// SecureBootPolicyPCR is the PCR which the firmware extends with the
+	// measurements of the secure boot configuration.
+	SecureBootPolicyPCR = 7
+	// KernelCmdlinePCR is the PCR which the EFI stub of the kernel extends
+	// with the measurement of the kernel command line.
+	KernelCmdlinePCR = 12
+)
+
+// ErrNoTPM is returned when no TPM is available to seal keys to.
+var ErrNoTPM = errors.New("no TPM support available")
+
+// A TPM seals keys against a PCR profile, and unseals them again when the
+// current values of the PCRs satisfy the profile.
+type TPM interface {
+	// Seal seals the key into keyFile, with a policy that is satisfied
+	// by the given profile.
+	Seal(key EncryptionKey, keyFile string, profile *PCRProfile) error
+	// UpdatePolicy updates the policy of the key sealed in keyFile to be
+	// satisfied by the given profile.
+	UpdatePolicy(keyFile string, profile *PCRProfile) error
+	// Unseal unseals the key from keyFile.
+	Unseal(keyFile string) (EncryptionKey, error)
+	// Close closes the connection to the TPM.
+	Close() error
+}
+
+// ConnectToTPM connects to the TPM of the device, it fails with ErrNoTPM if
+// the device has none.
+var ConnectToTPM = connectToTPM
+
+// BootFile describes a file loaded during the boot process, either found
+// inside a snap or directly on disk when Snap is unset.
+type BootFile struct {
+	// Snap is the path to the snap containing the file.
+	Snap string
+	// Path is the path of the file, inside the snap if Snap is set.
+	Path string
+}
+
+// NewBootFile returns a new boot file.
+func NewBootFile(snap, path string) BootFile {
+	return BootFile{Snap: snap, Path: path}
+}
+
+// LoadChain describes a boot file and the boot files it can load next.
+type LoadChain struct {
+	BootFile
+	Next []*LoadChain
+}
+
+// NewLoadChain returns a load chain for the given boot file, which loads
+// either of the next ones.
+func NewLoadChain(bf BootFile, next ...*LoadChain) *LoadChain {
+	return &LoadChain{BootFile: bf, Next: next}
+}
+
+// SealKeyModelParams holds the parameters of the boot process of a model the
+// key is sealed against.
+type SealKeyModelParams struct {
+	// EFILoadChains are the possible sequences of EFI binaries loaded
+	// during boot, starting with the first one loaded by the firmware.
+	EFILoadChains []*LoadChain
+	// KernelCmdlines are the kernel command lines the system can boot
+	// with.
+	KernelCmdlines []string
+}
+
+// SealKeyParams holds the parameters for sealing a key.
+type SealKeyParams struct {
+	ModelParams []*SealKeyModelParams
+	// KeyFile is the path where the sealed key is written.
+	KeyFile string
+}
+
+// ResealKeyParams holds the parameters for resealing a key.
+type ResealKeyParams struct {
+	ModelParams []*SealKeyModelParams
+	// KeyFile is the path to the sealed key.
+	KeyFile string
+}
+
+// PCRProfile describes the values of the PCRs a sealed key is bound to, as
+// the sequences of measurements leading to them.
+type PCRProfile struct {
+	// LoadSequences lists, for each possible boot sequence, the digests
+	// of the EFI binaries in the order they are loaded. They are measured
+	// into BootManagerCodePCR.
+	LoadSequences [][]string
+	// KernelCmdlines lists the kernel command lines measured into
+	// KernelCmdlinePCR.
+	KernelCmdlines []string
+}
+
+var readSnapFile = func(snapPath, path string) ([]byte, error) {
+	return squashfs.New(snapPath).ReadFile(path)
+}
+
+// MockReadSnapFile replaces the function used to read boot files from snaps
+// when measuring them.
+func MockReadSnapFile(f func(snapPath, path string) ([]byte, error)) (restore func()) {
+	old := readSnapFile
+	readSnapFile = f
+	return func() {
+		readSnapFile = old
+	}
+}
+
+var peDigest = authenticodeDigest
+
+// MockAuthenticodeDigest replaces the function used to compute the
+// Authenticode digest of boot files when measuring them.
+func MockAuthenticodeDigest(f func(image []byte) ([]byte, error)) (restore func()) {
+	old := peDigest
+	peDigest = f
+	return func() {
+		peDigest = old
+	}
+}
+
+// MeasureBootFile returns the digest the firmware measures the boot file,
+// an EFI binary, with. That is the SHA-256 Authenticode digest, hex encoded.
+func MeasureBootFile(bf BootFile) (string, error) {
+	var content []byte
+	var err error
+	if bf.Snap != "" {
+		content, err = readSnapFile(bf.Snap, bf.Path)
+	} else {
+		content, err = ioutil.ReadFile(bf.Path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot measure boot file %s: %v", bf, err)
+	}
+	digest, err := peDigest(content)
+	if err != nil {
+		return "", fmt.Errorf("cannot measure boot file %s: %v", bf, err)
+	}
+	return hex.EncodeToString(digest), nil
+}
+
+func (bf BootFile) String() string {
+	if bf.Snap != "" {
+		return fmt.Sprintf("%s:%s", filepath.Base(bf.Snap), bf.Path)
+	}
+	return bf.Path
+}
+
+// buildPCRProfile measures the boot files of the load chains of all the
+// models and combines them into a profile.
+func buildPCRProfile(modelParams []*SealKeyModelParams) (*PCRProfile, error) {
+	profile := &PCRProfile{}
+	seenCmdline := make(map[string]bool)
+	for _, mp := range modelParams {
+		for _, lc := range mp.EFILoadChains {
+			sequences, err := loadSequences(lc)
+			if err != nil {
+				return nil, err
+			}
+			profile.LoadSequences = append(profile.LoadSequences, sequences...)
+		}
+		for _, cmdline := range mp.KernelCmdlines {
+			if !seenCmdline[cmdline] {
+				seenCmdline[cmdline] = true
+				profile.KernelCmdlines = append(profile.KernelCmdlines, cmdline)
+			}
+		}
+	}
+	if len(profile.LoadSequences) == 0 {
+		return nil, fmt.Errorf("at least one EFI load chain is required")
+	}
+	if len(profile.KernelCmdlines) == 0 {
+		return nil, fmt.Errorf("at least one kernel command line is required")
+	}
+	return profile, nil
+}
+
+// loadSequences returns all the sequences of digests of the boot files
+// which can be loaded starting with the given load chain.
+func loadSequences(lc *LoadChain) ([][]string, error) {
+	digest, err := MeasureBootFile(lc.BootFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(lc.Next) == 0 {
+		return [][]string{{digest}}, nil
+	}
+	var sequences [][]string
+	for _, next := range lc.Next {
+		nextSequences, err := loadSequences(next)
+		if err != nil {
+			return nil, err
+		}
+		for _, seq := range nextSequences {
+			sequences = append(sequences, append([]string{digest}, seq...))
+		}
+	}
+	return sequences, nil
+}
+
+// SealKey seals the key to the TPM with a policy satisfied by the boot
+// processes described by the parameters.
+func SealKey(key EncryptionKey, params *SealKeyParams) error {
+	profile, err := buildPCRProfile(params.ModelParams)
+	if err != nil {
+		return fmt.Errorf("cannot seal key: %v", err)
+	}
+	tpm, err := ConnectToTPM()
+	if err != nil {
+		return fmt.Errorf("cannot seal key: %v", err)
+	}
+	defer tpm.Close()
+
+	if err := tpm.Seal(key, params.KeyFile, profile); err != nil {
+		return fmt.Errorf("cannot seal key: %v", err)
+	}
+	return nil
+}
+
+// ResealKey updates the policy of the sealed key to be satisfied by the boot
+// processes described by the parameters.
+func ResealKey(params *ResealKeyParams) error {
+	profile, err := buildPCRProfile(params.ModelParams)
+	if err != nil {
+		return fmt.Errorf("cannot reseal key: %v", err)
+	}
+	tpm, err := ConnectToTPM()
+	if err != nil {
+		return fmt.Errorf("cannot reseal key: %v", err)
+	}
+	defer tpm.Close()
+
+	if err := tpm.UpdatePolicy(params.KeyFile, profile); err != nil {
+		return fmt.Errorf("cannot reseal key: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secboot_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/secboot"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func TestSecboot(t *testing.T) { TestingT(t) }
+
+type mockTPM struct {
+	sealed    map[string]secboot.EncryptionKey
+	profiles  map[string]*secboot.PCRProfile
+	unsealErr error
+	closed    int
+}
+
+func newMockTPM() *mockTPM {
+	return &mockTPM{
+		sealed:   make(map[string]secboot.EncryptionKey),
+		profiles: make(map[string]*secboot.PCRProfile),
+	}
+}
+
+func (t *mockTPM) Seal(key secboot.EncryptionKey, keyFile string, profile *secboot.PCRProfile) error {
+	t.sealed[keyFile] = key
+	t.profiles[keyFile] = profile
+	return nil
+}
+
+func (t *mockTPM) UpdatePolicy(keyFile string, profile *secboot.PCRProfile) error {
+	if _, ok := t.sealed[keyFile]; !ok {
+		return fmt.Errorf("no key sealed in %s", keyFile)
+	}
+	t.profiles[keyFile] = profile
+	return nil
+}
+
+func (t *mockTPM) Unseal(keyFile string) (secboot.EncryptionKey, error) {
+	if t.unsealErr != nil {
+		return secboot.EncryptionKey{}, t.unsealErr
+	}
+	key, ok := t.sealed[keyFile]
+	if !ok {
+		return secboot.EncryptionKey{}, fmt.Errorf("no key sealed in %s", keyFile)
+	}
+	return key, nil
+}
+
+func (t *mockTPM) Close() error {
+	t.closed++
+	return nil
+}
+
+type secbootSuite struct {
+	testutil.BaseTest
+
+	dir string
+	tpm *mockTPM
+}
+
+var _ = Suite(&secbootSuite{})
+
+func (s *secbootSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.dir = c.MkDir()
+
+	s.tpm = newMockTPM()
+	oldConnect := secboot.ConnectToTPM
+	secboot.ConnectToTPM = func() (secboot.TPM, error) { return s.tpm, nil }
+	s.AddCleanup(func() { secboot.ConnectToTPM = oldConnect })
+
+	s.AddCleanup(secboot.MockReadSnapFile(func(snapPath, path string) ([]byte, error) {
+		return makePE([]byte(filepath.Base(snapPath)+":"+path), nil, nil, 0), nil
+	}))
+}
+
+func digestOf(content string) string {
+	digest, err := secboot.AuthenticodeDigest(makePE([]byte(content), nil, nil, 0))
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(digest)
+}
+
+func (s *secbootSuite) mockAsset(c *C, name string) secboot.BootFile {
+	path := filepath.Join(s.dir, name)
+	c.Assert(ioutil.WriteFile(path, makePE([]byte(name), nil, nil, 0), 0644), IsNil)
+	return secboot.NewBootFile("", path)
+}
+
+func (s *secbootSuite) TestMeasureBootFile(c *C) {
+	digest, err := secboot.MeasureBootFile(s.mockAsset(c, "shim"))
+	c.Assert(err, IsNil)
+	c.Check(digest, Equals, digestOf("shim"))
+	c.Check(digest, HasLen, 64)
+
+	digest, err = secboot.MeasureBootFile(secboot.NewBootFile("/var/lib/snapd/snaps/pc-kernel_1.snap", "kernel.efi"))
+	c.Assert(err, IsNil)
+	c.Check(digest, Equals, digestOf("pc-kernel_1.snap:kernel.efi"))
+
+	notPE := filepath.Join(s.dir, "not-pe")
+	c.Assert(ioutil.WriteFile(notPE, []byte("not a PE image"), 0644), IsNil)
+	_, err = secboot.MeasureBootFile(secboot.NewBootFile("", notPE))
+	c.Check(err, ErrorMatches, "cannot measure boot file .*/not-pe: not a PE image")
+
+	_, err = secboot.MeasureBootFile(secboot.NewBootFile("", filepath.Join(s.dir, "missing")))
+	c.Check(err, ErrorMatches, "cannot measure boot file .*/missing: open .*/missing: no such file or directory")
+}
+
+func (s *secbootSuite) TestSealKey(c *C) {
+	shim := s.mockAsset(c, "shim")
+	grubOld := s.mockAsset(c, "grub-old")
+	grubNew := s.mockAsset(c, "grub-new")
+	kernel := secboot.NewBootFile("pc-kernel_1.snap", "kernel.efi")
+
+	key, err := secboot.NewEncryptionKey()
+	c.Assert(err, IsNil)
+	keyFile := filepath.Join(s.dir, "ubuntu-data.sealed-key")
+	err = secboot.SealKey(key, &secboot.SealKeyParams{
+		ModelParams: []*secboot.SealKeyModelParams{{
+			EFILoadChains: []*secboot.LoadChain{
+				secboot.NewLoadChain(shim,
+					secboot.NewLoadChain(grubOld, secboot.NewLoadChain(kernel)),
+					secboot.NewLoadChain(grubNew, secboot.NewLoadChain(kernel)),
+				),
+			},
+			KernelCmdlines: []string{"snapd_recovery_mode=run", "snapd_recovery_mode=run"},
+		}},
+		KeyFile: keyFile,
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.tpm.sealed[keyFile], Equals, key)
+	c.Check(s.tpm.profiles[keyFile], DeepEquals, &secboot.PCRProfile{
+		LoadSequences: [][]string{
+			{digestOf("shim"), digestOf("grub-old"), digestOf("pc-kernel_1.snap:kernel.efi")},
+			{digestOf("shim"), digestOf("grub-new"), digestOf("pc-kernel_1.snap:kernel.efi")},
+		},
+		KernelCmdlines: []string{"snapd_recovery_mode=run"},
+	})
+	c.Check(s.tpm.closed, Equals, 1)
+
+	// reseal with the new grub only
+	err = secboot.ResealKey(&secboot.ResealKeyParams{
+		ModelParams: []*secboot.SealKeyModelParams{{
+			EFILoadChains: []*secboot.LoadChain{
+				secboot.NewLoadChain(shim, secboot.NewLoadChain(grubNew, secboot.NewLoadChain(kernel))),
+			},
+			KernelCmdlines: []string{"snapd_recovery_mode=run console=ttyS0"},
+		}},
+		KeyFile: keyFile,
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.tpm.profiles[keyFile], DeepEquals, &secboot.PCRProfile{
+		LoadSequences: [][]string{
+			{digestOf("shim"), digestOf("grub-new"), digestOf("pc-kernel_1.snap:kernel.efi")},
+		},
+		KernelCmdlines: []string{"snapd_recovery_mode=run console=ttyS0"},
+	})
+	c.Check(s.tpm.closed, Equals, 2)
+}
+
+func (s *secbootSuite) TestSealKeyErrors(c *C) {
+	shim := s.mockAsset(c, "shim")
+
+	for _, tc := range []struct {
+		params *secboot.SealKeyModelParams
+		err    string
+	}{
+		{&secboot.SealKeyModelParams{KernelCmdlines: []string{"foo"}}, "cannot seal key: at least one EFI load chain is required"},
+		{&secboot.SealKeyModelParams{EFILoadChains: []*secboot.LoadChain{secboot.NewLoadChain(shim)}}, "cannot seal key: at least one kernel command line is required"},
+		{&secboot.SealKeyModelParams{
+			EFILoadChains:  []*secboot.LoadChain{secboot.NewLoadChain(secboot.NewBootFile("", filepath.Join(s.dir, "missing")))},
+			KernelCmdlines: []string{"foo"},
+		}, "cannot seal key: cannot measure boot file .*/missing: .* no such file or directory"},
+	} {
+		err := secboot.SealKey(secboot.EncryptionKey{}, &secboot.SealKeyParams{
+			ModelParams: []*secboot.SealKeyModelParams{tc.params},
+			KeyFile:     filepath.Join(s.dir, "key"),
+		})
+		c.Check(err, ErrorMatches, tc.err)
+	}
+
+	secboot.ConnectToTPM = func() (secboot.TPM, error) { return nil, errors.New("no tpm") }
+	err := secboot.ResealKey(&secboot.ResealKeyParams{
+		ModelParams: []*secboot.SealKeyModelParams{{
+			EFILoadChains:  []*secboot.LoadChain{secboot.NewLoadChain(shim)},
+			KernelCmdlines: []string{"foo"},
+		}},
+		KeyFile: filepath.Join(s.dir, "key"),
+	})
+	c.Check(err, ErrorMatches, "cannot reseal key: no tpm")
+}
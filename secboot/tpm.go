@@ -0,0 +1,329 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secboot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+const (
+	tpmDevice    = "/dev/tpmrm0"
+	eventLogFile = "/sys/kernel/security/tpm0/binary_bios_measurements"
+
+	tpmCCPolicyOR  = 0x00000171
+	tpmCCPolicyPCR = 0x0000017f
+
+	// a PolicyOR takes at most 8 branches
+	maxPolicyBranches = 8
+)
+
+// sealedPCRs are the PCRs the key is sealed against, in ascending order.
+var sealedPCRs = []int{BootManagerCodePCR, SecureBootPolicyPCR, KernelCmdlinePCR}
+
+// sealedKeyObject is the content of a sealed key file.
+type sealedKeyObject struct {
+	// Public and Private are the parts of the sealed data object, as
+	// created by the TPM under the primary key of the owner hierarchy.
+	Public  []byte `json:"public"`
+	Private []byte `json:"private"`
+	// PolicyBranches are the PCR policy digests combined with a
+	// PolicyOR into the policy of the object, one per boot
+	// configuration.
+	PolicyBranches [][]byte `json:"policy-branches"`
+}
+
+// tpm2Tools accesses the TPM through the commands of tpm2-tools.
+type tpm2Tools struct {
+	tcti string
+	dir  string
+}
+
+func connectToTPM() (TPM, error) {
+	device := filepath.Join(dirs.GlobalRootDir, tpmDevice)
+	if !osutil.FileExists(device) {
+		return nil, ErrNoTPM
+	}
+	dir, err := ioutil.TempDir("", "snapd-tpm-")
+	if err != nil {
+		return nil, err
+	}
+	return &tpm2Tools{tcti: "device:" + device, dir: dir}, nil
+}
+
+func (t *tpm2Tools) path(name string) string {
+	return filepath.Join(t.dir, name)
+}
+
+func (t *tpm2Tools) run(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), "TPM2TOOLS_TCTI="+t.tcti)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v", name, osutil.OutputErr(output, err))
+	}
+	return nil
+}
+
+// createPrimary loads the primary key of the owner hierarchy the key is
+// sealed under, it is derived from the seed of the hierarchy each time.
+func (t *tpm2Tools) createPrimary() error {
+	return t.run("tpm2_createprimary", "-C", "o", "-g", "sha256", "-G", "ecc", "-c", t.path("primary.ctx"))
+}
+
+// Seal implements TPM.Seal.
+func (t *tpm2Tools) Seal(key EncryptionKey, keyFile string, profile *PCRProfile) error {
+	branches, err := policyBranches(profile)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(t.path("policy.digest"), policyORDigest(branches), 0600); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(t.path("key"), key[:], 0600); err != nil {
+		return err
+	}
+	defer os.Remove(t.path("key"))
+
+	if err := t.createPrimary(); err != nil {
+		return err
+	}
+	// the object cannot be duplicated and can only be unsealed by
+	// satisfying the policy
+	if err := t.run("tpm2_create", "-C", t.path("primary.ctx"), "-L", t.path("policy.digest"),
+		"-a", "fixedtpm|fixedparent|noda", "-i", t.path("key"),
+		"-u", t.path("key.pub"), "-r", t.path("key.priv")); err != nil {
+		return err
+	}
+
+	obj := &sealedKeyObject{PolicyBranches: branches}
+	if obj.Public, err = ioutil.ReadFile(t.path("key.pub")); err != nil {
+		return err
+	}
+	if obj.Private, err = ioutil.ReadFile(t.path("key.priv")); err != nil {
+		return err
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(keyFile, data, 0600, 0)
+}
+
+// UpdatePolicy implements TPM.UpdatePolicy. The policy of a sealed object
+// cannot be changed, the key is unsealed with the current boot
+// configuration and sealed again.
+func (t *tpm2Tools) UpdatePolicy(keyFile string, profile *PCRProfile) error {
+	key, err := t.Unseal(keyFile)
+	if err != nil {
+		return err
+	}
+	return t.Seal(key, keyFile, profile)
+}
+
+// Unseal implements TPM.Unseal.
+func (t *tpm2Tools) Unseal(keyFile string) (EncryptionKey, error) {
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return EncryptionKey{}, err
+	}
+	var obj sealedKeyObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return EncryptionKey{}, fmt.Errorf("cannot read sealed key: %v", err)
+	}
+	if len(obj.PolicyBranches) < 2 || len(obj.PolicyBranches) > maxPolicyBranches {
+		return EncryptionKey{}, fmt.Errorf("cannot read sealed key: invalid number of policy branches %d", len(obj.PolicyBranches))
+	}
+	if err := ioutil.WriteFile(t.path("key.pub"), obj.Public, 0600); err != nil {
+		return EncryptionKey{}, err
+	}
+	if err := ioutil.WriteFile(t.path("key.priv"), obj.Private, 0600); err != nil {
+		return EncryptionKey{}, err
+	}
+	branchFiles := make([]string, len(obj.PolicyBranches))
+	for i, branch := range obj.PolicyBranches {
+		branchFiles[i] = t.path(fmt.Sprintf("branch%d.digest", i))
+		if err := ioutil.WriteFile(branchFiles[i], branch, 0600); err != nil {
+			return EncryptionKey{}, err
+		}
+	}
+
+	if err := t.createPrimary(); err != nil {
+		return EncryptionKey{}, err
+	}
+	if err := t.run("tpm2_load", "-C", t.path("primary.ctx"), "-u", t.path("key.pub"), "-r", t.path("key.priv"), "-c", t.path("key.ctx")); err != nil {
+		return EncryptionKey{}, err
+	}
+	session := t.path("session.ctx")
+	if err := t.run("tpm2_startauthsession", "--policy-session", "-S", session); err != nil {
+		return EncryptionKey{}, err
+	}
+	defer t.run("tpm2_flushcontext", session)
+	// the current PCR values match one of the branches if the
+	// system booted with any of the boot configurations
+	if err := t.run("tpm2_policypcr", "-S", session, "-l", pcrSelectionList()); err != nil {
+		return EncryptionKey{}, err
+	}
+	if err := t.run("tpm2_policyor", "-S", session, "-l", "sha256:"+strings.Join(branchFiles, ",")); err != nil {
+		return EncryptionKey{}, err
+	}
+	unsealed := t.path("key.unsealed")
+	defer os.Remove(unsealed)
+	if err := t.run("tpm2_unseal", "-c", t.path("key.ctx"), "-p", "session:"+session, "-o", unsealed); err != nil {
+		return EncryptionKey{}, err
+	}
+	data, err = ioutil.ReadFile(unsealed)
+	if err != nil {
+		return EncryptionKey{}, err
+	}
+	var key EncryptionKey
+	if len(data) != len(key) {
+		return EncryptionKey{}, fmt.Errorf("unsealed key has unexpected size %d", len(data))
+	}
+	copy(key[:], data)
+	return key, nil
+}
+
+// Close implements TPM.Close.
+func (t *tpm2Tools) Close() error {
+	return os.RemoveAll(t.dir)
+}
+
+func pcrSelectionList() string {
+	pcrs := make([]string, len(sealedPCRs))
+	for i, pcr := range sealedPCRs {
+		pcrs[i] = fmt.Sprintf("%d", pcr)
+	}
+	return "sha256:" + strings.Join(pcrs, ",")
+}
+
+// pcrSelection returns the TPML_PCR_SELECTION of the sealed PCRs in the
+// SHA-256 bank.
+func pcrSelection() []byte {
+	var bitmap [3]byte
+	for _, pcr := range sealedPCRs {
+		bitmap[pcr/8] |= 1 << uint(pcr%8)
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint32(1))
+	binary.Write(&buf, binary.BigEndian, uint16(tpmAlgSHA256))
+	buf.WriteByte(byte(len(bitmap)))
+	buf.Write(bitmap[:])
+	return buf.Bytes()
+}
+
+// policyPCRDigest returns the digest of a policy satisfied by the sealed PCRs
+// having the given values.
+func policyPCRDigest(pcrValues [][]byte) []byte {
+	pcrs := sha256.New()
+	for _, v := range pcrValues {
+		pcrs.Write(v)
+	}
+	h := sha256.New()
+	h.Write(make([]byte, sha256.Size))
+	binary.Write(h, binary.BigEndian, uint32(tpmCCPolicyPCR))
+	h.Write(pcrSelection())
+	h.Write(pcrs.Sum(nil))
+	return h.Sum(nil)
+}
+
+// policyORDigest returns the digest of a policy satisfied by any of the
+// branches.
+func policyORDigest(branches [][]byte) []byte {
+	h := sha256.New()
+	h.Write(make([]byte, sha256.Size))
+	binary.Write(h, binary.BigEndian, uint32(tpmCCPolicyOR))
+	for _, branch := range branches {
+		h.Write(branch)
+	}
+	return h.Sum(nil)
+}
+
+// kernelCmdlineDigest returns the digest the EFI stub of the kernel measures
+// the command line with, that is of its UTF-16 form including the
+// terminating NUL.
+func kernelCmdlineDigest(cmdline string) []byte {
+	h := sha256.New()
+	for _, c := range utf16.Encode([]rune(cmdline + "\x00")) {
+		binary.Write(h, binary.LittleEndian, c)
+	}
+	return h.Sum(nil)
+}
+
+// policyBranches returns the PCR policy digests for all the combinations of
+// load sequences and kernel command lines of the profile. The values of the
+// PCRs are predicted by replaying the TCG event log of the current boot up to
+// the loading of the first EFI binary, the secure boot policy being expected
+// not to change.
+func policyBranches(profile *PCRProfile) ([][]byte, error) {
+	f, err := os.Open(filepath.Join(dirs.GlobalRootDir, eventLogFile))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open TCG event log: %v", err)
+	}
+	defer f.Close()
+	events, err := readEventLog(f)
+	if err != nil {
+		return nil, err
+	}
+
+	bootManagerCode := replayPCR(events, BootManagerCodePCR, func(ev *logEvent) bool {
+		return ev.Type == evEFIBootServicesApplication
+	})
+	secureBootPolicy := replayPCR(events, SecureBootPolicyPCR, nil)
+
+	var branches [][]byte
+	for _, seq := range profile.LoadSequences {
+		pcr4 := bootManagerCode
+		for _, digest := range seq {
+			d, err := hex.DecodeString(digest)
+			if err != nil {
+				return nil, fmt.Errorf("invalid boot file digest %q", digest)
+			}
+			pcr4 = extendPCR(pcr4, d)
+		}
+		for _, cmdline := range profile.KernelCmdlines {
+			pcr12 := extendPCR(make([]byte, sha256.Size), kernelCmdlineDigest(cmdline))
+			branches = append(branches, policyPCRDigest([][]byte{pcr4, secureBootPolicy, pcr12}))
+		}
+	}
+	if len(branches) > maxPolicyBranches {
+		return nil, fmt.Errorf("cannot seal against more than %d boot configurations", maxPolicyBranches)
+	}
+	// a PolicyOR needs at least two branches
+	if len(branches) == 1 {
+		branches = append(branches, branches[0])
+	}
+	return branches, nil
+}
@@ -0,0 +1,271 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secboot_test
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"unicode/utf16"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/secboot"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type tpmSuite struct {
+	testutil.BaseTest
+
+	rootDir string
+	dataDir string
+	cmd     *testutil.MockCmd
+}
+
+var _ = Suite(&tpmSuite{})
+
+func (s *tpmSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.rootDir = c.MkDir()
+	s.dataDir = c.MkDir()
+	dirs.SetRootDir(s.rootDir)
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+
+	c.Assert(os.MkdirAll(filepath.Join(s.rootDir, "/dev"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.rootDir, "/dev/tpmrm0"), nil, 0644), IsNil)
+	eventLog := filepath.Join(s.rootDir, "/sys/kernel/security/tpm0/binary_bios_measurements")
+	c.Assert(os.MkdirAll(filepath.Dir(eventLog), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(eventLog, makeEventLog(mockEvents), 0644), IsNil)
+
+	// the sealed object holds the key in the clear, the mocked
+	// tpm2_unseal returns it
+	s.cmd = testutil.MockCommand(c, "tpm2_createprimary", "")
+	s.cmd.Also("tpm2_create", fmt.Sprintf(`
+echo -n "$TPM2TOOLS_TCTI" > %[1]s/tcti
+while [ $# -gt 0 ]; do
+    case "$1" in
+        -L) cp "$2" %[1]s/policy.digest; shift;;
+        -i) cp "$2" %[1]s/key; shift;;
+        -u) echo -n public > "$2"; shift;;
+        -r) echo -n private > "$2"; shift;;
+    esac
+    shift
+done
+`, s.dataDir))
+	s.cmd.Also("tpm2_load", "")
+	s.cmd.Also("tpm2_startauthsession", "")
+	s.cmd.Also("tpm2_policypcr", "")
+	s.cmd.Also("tpm2_policyor", "")
+	s.cmd.Also("tpm2_unseal", fmt.Sprintf(`
+while [ $# -gt 0 ]; do
+    case "$1" in
+        -o) cp %[1]s/key "$2"; shift;;
+    esac
+    shift
+done
+`, s.dataDir))
+	s.cmd.Also("tpm2_flushcontext", "")
+	s.AddCleanup(s.cmd.Restore)
+}
+
+func pcrPolicy(pcr4, pcr7, pcr12 []byte) []byte {
+	pcrs := sha256.New()
+	pcrs.Write(pcr4)
+	pcrs.Write(pcr7)
+	pcrs.Write(pcr12)
+	h := sha256.New()
+	h.Write(make([]byte, sha256.Size))
+	h.Write([]byte{0x00, 0x00, 0x01, 0x7f})
+	// one selection in the SHA-256 bank of PCRs 4, 7 and 12
+	h.Write([]byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x0b, 0x03, 0x90, 0x10, 0x00})
+	h.Write(pcrs.Sum(nil))
+	return h.Sum(nil)
+}
+
+func cmdlinePCR(cmdline string) []byte {
+	h := sha256.New()
+	for _, c := range utf16.Encode([]rune(cmdline + "\x00")) {
+		binary.Write(h, binary.LittleEndian, c)
+	}
+	pcr := sha256.New()
+	pcr.Write(make([]byte, sha256.Size))
+	pcr.Write(h.Sum(nil))
+	return pcr.Sum(nil)
+}
+
+func (s *tpmSuite) TestSealUnseal(c *C) {
+	tpm, err := secboot.ConnectToTPM2Tools()
+	c.Assert(err, IsNil)
+	defer tpm.Close()
+
+	key, err := secboot.NewEncryptionKey()
+	c.Assert(err, IsNil)
+	shim := sha256.Sum256([]byte("shim"))
+	grubOld := sha256.Sum256([]byte("grub-old"))
+	grubNew := sha256.Sum256([]byte("grub-new"))
+	profile := &secboot.PCRProfile{
+		LoadSequences: [][]string{
+			{hex.EncodeToString(shim[:]), hex.EncodeToString(grubOld[:])},
+			{hex.EncodeToString(shim[:]), hex.EncodeToString(grubNew[:])},
+		},
+		KernelCmdlines: []string{"snapd_recovery_mode=run"},
+	}
+	keyFile := filepath.Join(s.rootDir, "/run/mnt/ubuntu-seed/device/fde/ubuntu-data.sealed-key")
+	c.Assert(tpm.Seal(key, keyFile, profile), IsNil)
+
+	c.Check(filepath.Join(s.dataDir, "tcti"), testutil.FileEquals, "device:"+filepath.Join(s.rootDir, "/dev/tpmrm0"))
+	c.Check(filepath.Join(s.dataDir, "key"), testutil.FileEquals, string(key[:]))
+
+	// the PCRs are predicted from the event log up to the first
+	// application
+	zero := make([]byte, sha256.Size)
+	pcr4 := extend(extend(zero, "Calling EFI Application from Boot Option"), "\x00\x00\x00\x00")
+	pcr7 := extend(extend(extend(zero, "SecureBoot"), "PK"), "\x00\x00\x00\x00")
+	pcr12 := cmdlinePCR("snapd_recovery_mode=run")
+	branches := [][]byte{
+		pcrPolicy(extend(extend(pcr4, "shim"), "grub-old"), pcr7, pcr12),
+		pcrPolicy(extend(extend(pcr4, "shim"), "grub-new"), pcr7, pcr12),
+	}
+	h := sha256.New()
+	h.Write(zero)
+	h.Write([]byte{0x00, 0x00, 0x01, 0x71})
+	h.Write(branches[0])
+	h.Write(branches[1])
+	c.Check(filepath.Join(s.dataDir, "policy.digest"), testutil.FileEquals, string(h.Sum(nil)))
+
+	data, err := ioutil.ReadFile(keyFile)
+	c.Assert(err, IsNil)
+	var obj struct {
+		Public         []byte   `json:"public"`
+		Private        []byte   `json:"private"`
+		PolicyBranches [][]byte `json:"policy-branches"`
+	}
+	c.Assert(json.Unmarshal(data, &obj), IsNil)
+	c.Check(string(obj.Public), Equals, "public")
+	c.Check(string(obj.Private), Equals, "private")
+	c.Check(obj.PolicyBranches, DeepEquals, branches)
+
+	calls := s.cmd.Calls()
+	c.Assert(calls, HasLen, 2)
+	c.Check(calls[0][0], Equals, "tpm2_createprimary")
+	c.Check(calls[1][:7], DeepEquals, []string{"tpm2_create", "-C", calls[1][2], "-L", calls[1][4], "-a", "fixedtpm|fixedparent|noda"})
+	s.cmd.ForgetCalls()
+
+	unsealed, err := tpm.Unseal(keyFile)
+	c.Assert(err, IsNil)
+	c.Check(unsealed, Equals, key)
+
+	calls = s.cmd.Calls()
+	c.Assert(calls, HasLen, 7)
+	var names []string
+	for _, call := range calls {
+		names = append(names, call[0])
+	}
+	c.Check(names, DeepEquals, []string{
+		"tpm2_createprimary", "tpm2_load", "tpm2_startauthsession",
+		"tpm2_policypcr", "tpm2_policyor", "tpm2_unseal", "tpm2_flushcontext",
+	})
+	c.Check(calls[3][3:], DeepEquals, []string{"-l", "sha256:4,7,12"})
+}
+
+func (s *tpmSuite) TestSealSingleBranch(c *C) {
+	tpm, err := secboot.ConnectToTPM2Tools()
+	c.Assert(err, IsNil)
+	defer tpm.Close()
+
+	shim := sha256.Sum256([]byte("shim"))
+	profile := &secboot.PCRProfile{
+		LoadSequences:  [][]string{{hex.EncodeToString(shim[:])}},
+		KernelCmdlines: []string{"snapd_recovery_mode=run"},
+	}
+	keyFile := filepath.Join(s.rootDir, "sealed-key")
+	c.Assert(tpm.Seal(secboot.EncryptionKey{}, keyFile, profile), IsNil)
+
+	data, err := ioutil.ReadFile(keyFile)
+	c.Assert(err, IsNil)
+	var obj struct {
+		PolicyBranches [][]byte `json:"policy-branches"`
+	}
+	c.Assert(json.Unmarshal(data, &obj), IsNil)
+	// a PolicyOR needs at least two branches
+	c.Assert(obj.PolicyBranches, HasLen, 2)
+	c.Check(obj.PolicyBranches[0], DeepEquals, obj.PolicyBranches[1])
+}
+
+func (s *tpmSuite) TestSealTooManyBootConfigurations(c *C) {
+	tpm, err := secboot.ConnectToTPM2Tools()
+	c.Assert(err, IsNil)
+	defer tpm.Close()
+
+	var seqs [][]string
+	for i := 0; i < 5; i++ {
+		digest := sha256.Sum256([]byte(fmt.Sprintf("grub-%d", i)))
+		seqs = append(seqs, []string{hex.EncodeToString(digest[:])})
+	}
+	profile := &secboot.PCRProfile{
+		LoadSequences:  seqs,
+		KernelCmdlines: []string{"snapd_recovery_mode=run", "snapd_recovery_mode=recover"},
+	}
+	err = tpm.Seal(secboot.EncryptionKey{}, filepath.Join(s.rootDir, "sealed-key"), profile)
+	c.Check(err, ErrorMatches, "cannot seal against more than 8 boot configurations")
+	c.Check(s.cmd.Calls(), HasLen, 0)
+}
+
+func (s *tpmSuite) TestSealNoEventLog(c *C) {
+	c.Assert(os.Remove(filepath.Join(s.rootDir, "/sys/kernel/security/tpm0/binary_bios_measurements")), IsNil)
+	tpm, err := secboot.ConnectToTPM2Tools()
+	c.Assert(err, IsNil)
+	defer tpm.Close()
+
+	err = tpm.Seal(secboot.EncryptionKey{}, filepath.Join(s.rootDir, "sealed-key"), &secboot.PCRProfile{})
+	c.Check(err, ErrorMatches, "cannot open TCG event log: .*")
+}
+
+func (s *tpmSuite) TestUnsealFails(c *C) {
+	tpm, err := secboot.ConnectToTPM2Tools()
+	c.Assert(err, IsNil)
+	defer tpm.Close()
+
+	keyFile := filepath.Join(s.rootDir, "sealed-key")
+	c.Assert(ioutil.WriteFile(keyFile, []byte(`{"public":"cHVibGlj","private":"cHJpdmF0ZQ==","policy-branches":["AA==","AQ=="]}`), 0600), IsNil)
+
+	cmd := testutil.MockCommand(c, "tpm2_policyor", `echo "ERROR: TPM_RC_VALUE"; exit 1`)
+	defer cmd.Restore()
+	_, err = tpm.Unseal(keyFile)
+	c.Check(err, ErrorMatches, "tpm2_policyor failed: ERROR: TPM_RC_VALUE")
+	// the session is flushed
+	c.Check(s.cmd.Calls()[len(s.cmd.Calls())-1][0], Equals, "tpm2_flushcontext")
+
+	c.Assert(ioutil.WriteFile(keyFile, []byte(`{"policy-branches":["AA=="]}`), 0600), IsNil)
+	_, err = tpm.Unseal(keyFile)
+	c.Check(err, ErrorMatches, "cannot read sealed key: invalid number of policy branches 1")
+}
+
+func (s *tpmSuite) TestNoTPM(c *C) {
+	c.Assert(os.Remove(filepath.Join(s.rootDir, "/dev/tpmrm0")), IsNil)
+	_, err := secboot.ConnectToTPM2Tools()
+	c.Check(err, Equals, secboot.ErrNoTPM)
+}
@@ -0,0 +1,101 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secboot
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// recoveryKeyAttempts is the number of times the user is asked for the
+// recovery key before giving up.
+const recoveryKeyAttempts = 3
+
+// UnlockVolumeIfEncrypted unlocks the volume with the given filesystem label,
+// if it is encrypted, in which case its encrypted counterpart is labeled with
+// an additional -enc suffix. The key sealed in sealedKeyFile is used to unlock
+// the volume. Should that fail, the user is asked for the recovery key. It
+// returns the device node to use for accessing the volume.
+func UnlockVolumeIfEncrypted(name, sealedKeyFile string) (device string, err error) {
+	byLabel := filepath.Join(dirs.GlobalRootDir, "/dev/disk/by-label")
+	encDevice := filepath.Join(byLabel, name+"-enc")
+	if !osutil.FileExists(encDevice) {
+		// not encrypted
+		return filepath.Join(byLabel, name), nil
+	}
+	mapperDevice := filepath.Join(dirs.GlobalRootDir, "/dev/mapper", name)
+
+	key, err := unsealKey(sealedKeyFile)
+	if err == nil {
+		err = activateVolume(name, encDevice, key[:])
+		if err == nil {
+			return mapperDevice, nil
+		}
+	}
+	logger.Noticef("cannot unlock encrypted device %q with the sealed key: %v", name, err)
+
+	for i := 0; i < recoveryKeyAttempts; i++ {
+		recoveryKey, err := askRecoveryKey(name)
+		if err != nil {
+			logger.Noticef("cannot obtain recovery key for encrypted device %q: %v", name, err)
+			continue
+		}
+		if err := activateVolume(name, encDevice, recoveryKey[:]); err != nil {
+			logger.Noticef("cannot unlock encrypted device %q with the recovery key: %v", name, err)
+			continue
+		}
+		return mapperDevice, nil
+	}
+	return "", fmt.Errorf("cannot unlock encrypted device %q", name)
+}
+
+func unsealKey(sealedKeyFile string) (EncryptionKey, error) {
+	tpm, err := ConnectToTPM()
+	if err != nil {
+		return EncryptionKey{}, err
+	}
+	defer tpm.Close()
+	return tpm.Unseal(sealedKeyFile)
+}
+
+func activateVolume(name, device string, key []byte) error {
+	cmd := exec.Command("cryptsetup", "open", "--key-file", "-", device, name)
+	cmd.Stdin = bytes.NewReader(key)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return osutil.OutputErr(output, err)
+	}
+	return nil
+}
+
+func askRecoveryKey(name string) (RecoveryKey, error) {
+	cmd := exec.Command("systemd-ask-password", "--icon", "drive-harddisk", "--id", "snapd:"+name,
+		fmt.Sprintf("Please enter the recovery key for disk %s:", name))
+	output, err := cmd.Output()
+	if err != nil {
+		return RecoveryKey{}, osutil.OutputErr(output, err)
+	}
+	return ParseRecoveryKey(string(output))
+}
@@ -0,0 +1,125 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secboot_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/secboot"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *secbootSuite) mockEncryptedDevice(c *C, name string) {
+	byLabel := filepath.Join(dirs.GlobalRootDir, "/dev/disk/by-label")
+	c.Assert(os.MkdirAll(byLabel, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(byLabel, name+"-enc"), nil, 0644), IsNil)
+}
+
+func (s *secbootSuite) TestUnlockVolumeNotEncrypted(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	cryptsetup := testutil.MockCommand(c, "cryptsetup", "")
+	defer cryptsetup.Restore()
+
+	device, err := secboot.UnlockVolumeIfEncrypted("ubuntu-data", "sealed-key")
+	c.Assert(err, IsNil)
+	c.Check(device, Equals, filepath.Join(dirs.GlobalRootDir, "/dev/disk/by-label/ubuntu-data"))
+	c.Check(cryptsetup.Calls(), HasLen, 0)
+}
+
+func (s *secbootSuite) TestUnlockVolumeWithSealedKey(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+	s.mockEncryptedDevice(c, "ubuntu-data")
+
+	var key secboot.EncryptionKey
+	copy(key[:], "sealed-encryption-key")
+	s.tpm.sealed["sealed-key"] = key
+
+	keyCopy := filepath.Join(s.dir, "key")
+	cryptsetup := testutil.MockCommand(c, "cryptsetup", "cat > "+keyCopy)
+	defer cryptsetup.Restore()
+
+	device, err := secboot.UnlockVolumeIfEncrypted("ubuntu-data", "sealed-key")
+	c.Assert(err, IsNil)
+	c.Check(device, Equals, filepath.Join(dirs.GlobalRootDir, "/dev/mapper/ubuntu-data"))
+	c.Check(cryptsetup.Calls(), DeepEquals, [][]string{
+		{"cryptsetup", "open", "--key-file", "-", filepath.Join(dirs.GlobalRootDir, "/dev/disk/by-label/ubuntu-data-enc"), "ubuntu-data"},
+	})
+	c.Check(keyCopy, testutil.FileEquals, string(key[:]))
+}
+
+func (s *secbootSuite) TestUnlockVolumeWithRecoveryKey(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+	s.mockEncryptedDevice(c, "ubuntu-data")
+	s.tpm.unsealErr = errors.New("PCR policy not satisfied")
+
+	var recoveryKey secboot.RecoveryKey
+	copy(recoveryKey[:], "recovery-key")
+
+	keyCopy := filepath.Join(s.dir, "key")
+	cryptsetup := testutil.MockCommand(c, "cryptsetup", "cat > "+keyCopy)
+	defer cryptsetup.Restore()
+	// the first attempt is mistyped
+	askPassword := testutil.MockCommand(c, "systemd-ask-password", `
+if [ -e `+s.dir+`/asked ]; then
+    echo `+recoveryKey.String()+`
+else
+    touch `+s.dir+`/asked
+    echo 12345
+fi`)
+	defer askPassword.Restore()
+
+	device, err := secboot.UnlockVolumeIfEncrypted("ubuntu-data", "sealed-key")
+	c.Assert(err, IsNil)
+	c.Check(device, Equals, filepath.Join(dirs.GlobalRootDir, "/dev/mapper/ubuntu-data"))
+	c.Check(askPassword.Calls(), DeepEquals, [][]string{
+		{"systemd-ask-password", "--icon", "drive-harddisk", "--id", "snapd:ubuntu-data", "Please enter the recovery key for disk ubuntu-data:"},
+		{"systemd-ask-password", "--icon", "drive-harddisk", "--id", "snapd:ubuntu-data", "Please enter the recovery key for disk ubuntu-data:"},
+	})
+	c.Check(cryptsetup.Calls(), HasLen, 1)
+	c.Check(keyCopy, testutil.FileEquals, string(recoveryKey[:]))
+}
+
+func (s *secbootSuite) TestUnlockVolumeFails(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+	s.mockEncryptedDevice(c, "ubuntu-data")
+	s.tpm.sealed["sealed-key"] = secboot.EncryptionKey{}
+
+	cryptsetup := testutil.MockCommand(c, "cryptsetup", "echo 'No key available with this passphrase.'; exit 2")
+	defer cryptsetup.Restore()
+	askPassword := testutil.MockCommand(c, "systemd-ask-password", "echo 00000-00000-00000-00000-00000-00000-00000-00000")
+	defer askPassword.Restore()
+
+	_, err := secboot.UnlockVolumeIfEncrypted("ubuntu-data", "sealed-key")
+	c.Assert(err, ErrorMatches, `cannot unlock encrypted device "ubuntu-data"`)
+	// the sealed key and three recovery key attempts
+	c.Check(cryptsetup.Calls(), HasLen, 4)
+	c.Check(askPassword.Calls(), HasLen, 3)
+}
@@ -20,11 +20,9 @@
 package seedtest
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -72,26 +70,10 @@ func (ss *SeedSnaps) MakeAssertedSnap(c *C, snapYaml string, files [][]string, r
 	snapFile := snaptest.MakeTestSnapWithFiles(c, snapYaml, files)
 
 	snapID := ss.AssertedSnapID(snapName)
-	declA, err := ss.StoreSigning.Sign(asserts.SnapDeclarationType, map[string]interface{}{
-		"series":       "16",
-		"snap-id":      snapID,
-		"publisher-id": developerID,
-		"snap-name":    snapName,
-		"timestamp":    time.Now().UTC().Format(time.RFC3339),
-	}, nil, "")
+	declA, err := ss.StoreSigning.Sign(asserts.SnapDeclarationType, snaptest.SnapDeclarationHeaders(snapName, snapID, developerID, nil), nil, "")
 	c.Assert(err, IsNil)
 
-	sha3_384, size, err := asserts.SnapFileSHA3_384(snapFile)
-	c.Assert(err, IsNil)
-
-	revA, err := ss.StoreSigning.Sign(asserts.SnapRevisionType, map[string]interface{}{
-		"snap-sha3-384": sha3_384,
-		"snap-size":     fmt.Sprintf("%d", size),
-		"snap-id":       snapID,
-		"developer-id":  developerID,
-		"snap-revision": revision.String(),
-		"timestamp":     time.Now().UTC().Format(time.RFC3339),
-	}, nil, "")
+	revA, err := ss.StoreSigning.Sign(asserts.SnapRevisionType, snaptest.SnapRevisionHeaders(c, snapFile, snapID, developerID, revision, nil), nil, "")
 	c.Assert(err, IsNil)
 
 	if !revision.Unset() {
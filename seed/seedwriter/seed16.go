@@ -28,6 +28,7 @@ import (
 	"strings"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/seed/internal"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/channel"
@@ -182,10 +183,18 @@ func (tr *tree16) localSnapPath(sn *SeedSnap) string {
 
 func (tr *tree16) writeAssertions(db asserts.RODatabase, modelRefs []*asserts.Ref, snapsFromModel []*SeedSnap, extraSnaps []*SeedSnap) error {
 	seedAssertsDir := filepath.Join(tr.opts.SeedDir, "assertions")
-	if err := os.MkdirAll(seedAssertsDir, 0755); err != nil {
+	if err := os.MkdirAll(tr.opts.SeedDir, 0755); err != nil {
 		return err
 	}
 
+	// replace the assertions as a whole so that a failed or
+	// interrupted write never leaves an inconsistent set behind
+	return osutil.AtomicReplaceDir(seedAssertsDir, 0755, func(assertsDir string) error {
+		return tr.writeAssertionsInto(assertsDir, db, modelRefs, snapsFromModel, extraSnaps)
+	})
+}
+
+func (tr *tree16) writeAssertionsInto(seedAssertsDir string, db asserts.RODatabase, modelRefs []*asserts.Ref, snapsFromModel []*SeedSnap, extraSnaps []*SeedSnap) error {
 	writeRefs := func(aRefs []*asserts.Ref) error {
 		for _, aRef := range aRefs {
 			var afn string
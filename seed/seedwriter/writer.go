@@ -234,6 +234,9 @@ func New(model *asserts.Model, opts *Options) (*Writer, error) {
 		if err := pol.checkDefaultChannel(deflCh); err != nil {
 			return nil, err
 		}
+		if deflCh.Branch != "" {
+			w.warningf("global default option channel %q is a branch, %s", opts.DefaultChannel, branchExpirationNote)
+		}
 	}
 
 	w.policy = pol
@@ -306,6 +309,8 @@ func (w *Writer) checkStep(thisStep writerStep) error {
 	return nil
 }
 
+var branchExpirationNote = fmt.Sprintf("branches are closed by the store %d days after the last release into them", int(channel.BranchLifetime.Hours()/24))
+
 // warningf adds a warning that can be later retrieved via Warnings.
 func (w *Writer) warningf(format string, a ...interface{}) {
 	w.warnings = append(w.warnings, fmt.Sprintf(format, a...))
@@ -359,6 +364,9 @@ func (w *Writer) SetOptionsSnaps(optSnaps []*OptionsSnap) error {
 			if err := w.policy.checkSnapChannel(ch, whichSnap); err != nil {
 				return err
 			}
+			if ch.Branch != "" {
+				w.warningf("option channel %q for snap %q is a branch, %s", sn.Channel, whichSnap, branchExpirationNote)
+			}
 		}
 		if local {
 			if w.localSnaps == nil {
@@ -750,8 +758,9 @@ func (w *Writer) downloaded(seedSnaps []*SeedSnap) error {
 			return err
 		}
 
-		needsClassic := info.NeedsClassic()
-		if needsClassic && !w.model.Classic() {
+		// classic snaps in a classic model are sanctioned by
+		// the model itself
+		if err := snap.CheckClassicConfinement(info, w.model.Classic(), true); err != nil {
 			return fmt.Errorf("cannot use classic snap %q in a core system", info.SnapName())
 		}
 
@@ -305,6 +305,28 @@ func (s writerSuite) TestSetOptionsSnapsErrors(c *C) {
 	}
 }
 
+func (s *writerSuite) TestBranchChannelsWarning(c *C) {
+	model := s.Brands.Model("my-brand", "my-model", map[string]interface{}{
+		"display-name":   "my model",
+		"architecture":   "amd64",
+		"gadget":         "pc",
+		"kernel":         "pc-kernel",
+		"required-snaps": []interface{}{"required"},
+	})
+
+	s.opts.DefaultChannel = "edge/fix-123"
+	w, err := seedwriter.New(model, s.opts)
+	c.Assert(err, IsNil)
+
+	err = w.SetOptionsSnaps([]*seedwriter.OptionsSnap{{Name: "pc", Channel: "18/beta/fix-456"}})
+	c.Assert(err, IsNil)
+
+	c.Check(w.Warnings(), DeepEquals, []string{
+		`global default option channel "edge/fix-123" is a branch, branches are closed by the store 30 days after the last release into them`,
+		`option channel "18/beta/fix-456" for snap "pc" is a branch, branches are closed by the store 30 days after the last release into them`,
+	})
+}
+
 func (s *writerSuite) TestSnapsToDownloadCore16(c *C) {
 	model := s.Brands.Model("my-brand", "my-model", map[string]interface{}{
 		"display-name":   "my model",
@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"path/filepath"
 
+	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/seed/internal"
 	"github.com/snapcore/snapd/snap"
 )
@@ -44,12 +45,17 @@ func ValidateFromYaml(seedYamlFile string) error {
 		if err != nil {
 			errs = append(errs, err)
 		} else {
-			info, err := snap.ReadInfoFromSnapFile(snapf, nil)
+			info, files, err := snap.ReadInfoAndFilesFromSnapFile(snapf, nil, []string{"meta/gadget.yaml"})
 			if err != nil {
 				errs = append(errs, fmt.Errorf("cannot use snap %s: %v", fn, err))
-			} else {
-				snapInfos[info.InstanceName()] = info
+				continue
 			}
+			if info.GetType() == snap.TypeGadget {
+				if _, err := gadget.InfoFromSnapFiles(files, nil); err != nil {
+					errs = append(errs, fmt.Errorf("cannot use gadget snap %s: %v", fn, err))
+				}
+			}
+			snapInfos[info.InstanceName()] = info
 		}
 	}
 
@@ -242,7 +242,7 @@ func (s *validateSuite) TestValidateFromYamlSnapSnapInvalid(c *C) {
 	// need to build the snap "manually" pack.Snap() will do validation
 	snapFilePath := filepath.Join(c.MkDir(), "some-snap-invalid-yaml_1.snap")
 	d := squashfs.New(snapFilePath)
-	err = d.Build(snapBuildDir, "app")
+	err = d.Build(snapBuildDir, &squashfs.BuildOpts{SnapType: "app"})
 	c.Assert(err, IsNil)
 
 	// put the broken snap in place
@@ -262,3 +262,23 @@ snaps:
 	c.Assert(err, ErrorMatches, `cannot validate seed:
 - cannot use snap /.*/snaps/some-snap-invalid-yaml_1.snap: invalid snap version: cannot be empty`)
 }
+
+func (s *validateSuite) TestValidateFromYamlSnapGadgetInvalid(c *C) {
+	s.makeSnapInSeed(c, coreYaml)
+	src := snaptest.MakeTestSnapWithFiles(c, "name: pc\nversion: 1.0\ntype: gadget\n", [][]string{
+		{"meta/gadget.yaml", "defaults:\n  not-a-snap-id:\n    foo: bar\n"},
+	})
+	c.Assert(os.Rename(src, filepath.Join(s.root, "snaps", "pc_1.snap")), IsNil)
+
+	seedFn := s.makeSeedYaml(c, `
+snaps:
+ - name: core
+   file: core_1.snap
+ - name: pc
+   file: pc_1.snap
+`)
+
+	err := seed.ValidateFromYaml(seedFn)
+	c.Assert(err, ErrorMatches, `cannot validate seed:
+- cannot use gadget snap /.*/snaps/pc_1.snap: default stanza not keyed by "system" or snap-id: not-a-snap-id`)
+}
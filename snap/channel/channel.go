@@ -23,6 +23,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/snapcore/snapd/arch"
 	"github.com/snapcore/snapd/strutil"
@@ -30,6 +31,10 @@ import (
 
 var channelRisks = []string{"stable", "candidate", "beta", "edge"}
 
+// BranchLifetime is how long the store keeps a branch open after the
+// last release into it.
+const BranchLifetime = 30 * 24 * time.Hour
+
 // Channel identifies and describes completely a store channel.
 type Channel struct {
 	Architecture string `json:"architecture"`
@@ -143,6 +148,17 @@ func (c Channel) String() string {
 	return c.Name
 }
 
+// Normalize parses the given channel string and returns its
+// normalized name, the default track "latest" is dropped and a
+// missing risk defaults to "stable".
+func Normalize(s string) (string, error) {
+	ch, err := Parse(s, "-")
+	if err != nil {
+		return "", err
+	}
+	return ch.Name, nil
+}
+
 // Full returns the full name of the channel, inclusive the default track "latest".
 func (c *Channel) Full() string {
 	if c.Track == "" {
@@ -161,6 +177,36 @@ func (c *Channel) VerbatimRiskOnly() bool {
 	return c.Track == "" && c.Risk != "" && c.Branch == ""
 }
 
+// BranchExpiration returns when the branch of the channel expires
+// given the time of the last release into it. The zero time is
+// returned if the channel is not a branch.
+func (c *Channel) BranchExpiration(releasedAt time.Time) time.Time {
+	if c.Branch == "" || releasedAt.IsZero() {
+		return time.Time{}
+	}
+	return releasedAt.Add(BranchLifetime)
+}
+
+// Fallbacks returns the normalized names of the channels, starting
+// with c itself, that the store falls back to in order when c is
+// closed: a branch falls back to its risk, and each risk to the more
+// stable ones within the same track.
+func (c *Channel) Fallbacks() []string {
+	clean := c.Clean()
+	var prefix string
+	if clean.Track != "" {
+		prefix = clean.Track + "/"
+	}
+	var fallbacks []string
+	if clean.Branch != "" {
+		fallbacks = append(fallbacks, clean.Name)
+	}
+	for i := riskLevel(clean.Risk); i >= 0; i-- {
+		fallbacks = append(fallbacks, prefix+channelRisks[i])
+	}
+	return fallbacks
+}
+
 func riskLevel(risk string) int {
 	for i, r := range channelRisks {
 		if r == risk {
@@ -21,6 +21,7 @@ package channel_test
 
 import (
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -353,3 +354,63 @@ func (s *storeChannelSuite) TestResolvePinned(c *C) {
 		}
 	}
 }
+
+func (s *storeChannelSuite) TestNormalize(c *C) {
+	tests := []struct {
+		channel string
+		norm    string
+		expErr  string
+	}{
+		{"stable", "stable", ""},
+		{"latest/stable", "stable", ""},
+		{"latest", "stable", ""},
+		{"edge/foo", "edge/foo", ""},
+		{"latest/edge/foo", "edge/foo", ""},
+		{"1.0", "1.0/stable", ""},
+		{"1.0/beta", "1.0/beta", ""},
+		{"", "", "channel name cannot be empty"},
+		{"foo/unknown", "", "invalid risk in channel name: foo/unknown"},
+	}
+	for _, t := range tests {
+		norm, err := channel.Normalize(t.channel)
+		tcomm := Commentf("%#v", t)
+		if t.expErr == "" {
+			c.Assert(err, IsNil, tcomm)
+			c.Check(norm, Equals, t.norm, tcomm)
+		} else {
+			c.Assert(err, ErrorMatches, t.expErr, tcomm)
+		}
+	}
+}
+
+func (s *storeChannelSuite) TestBranchExpiration(c *C) {
+	releasedAt := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	ch, err := channel.Parse("1.0/beta/fix-123", "")
+	c.Assert(err, IsNil)
+	c.Check(ch.BranchExpiration(releasedAt), Equals, time.Date(2020, 1, 31, 12, 0, 0, 0, time.UTC))
+	c.Check(ch.BranchExpiration(time.Time{}).IsZero(), Equals, true)
+
+	ch, err = channel.Parse("1.0/beta", "")
+	c.Assert(err, IsNil)
+	c.Check(ch.BranchExpiration(releasedAt).IsZero(), Equals, true)
+}
+
+func (s *storeChannelSuite) TestFallbacks(c *C) {
+	tests := []struct {
+		channel   string
+		fallbacks []string
+	}{
+		{"stable", []string{"stable"}},
+		{"latest/edge", []string{"edge", "beta", "candidate", "stable"}},
+		{"candidate/foo", []string{"candidate/foo", "candidate", "stable"}},
+		{"1.0", []string{"1.0/stable"}},
+		{"1.0/beta", []string{"1.0/beta", "1.0/candidate", "1.0/stable"}},
+		{"1.0/edge/foo", []string{"1.0/edge/foo", "1.0/edge", "1.0/beta", "1.0/candidate", "1.0/stable"}},
+	}
+	for _, t := range tests {
+		ch, err := channel.ParseVerbatim(t.channel, "")
+		c.Assert(err, IsNil)
+		c.Check(ch.Fallbacks(), DeepEquals, t.fallbacks, Commentf("%#v", t))
+	}
+}
@@ -0,0 +1,209 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/snap/naming"
+)
+
+// ComponentType is the type of a snap component.
+type ComponentType string
+
+const (
+	// TestComponent is a component carrying tests of the snap.
+	TestComponent ComponentType = "test"
+	// DebugSymbolsComponent is a component carrying the debug symbols
+	// of the binaries of the snap.
+	DebugSymbolsComponent ComponentType = "debug-symbols"
+	// AssetsComponent is a component carrying optional data, for
+	// example large media files or models.
+	AssetsComponent ComponentType = "assets"
+)
+
+var validComponentTypes = []ComponentType{TestComponent, DebugSymbolsComponent, AssetsComponent}
+
+// Component is a component of a snap, a sub-artifact that is built and
+// installed separately from the snap itself.
+type Component struct {
+	Snap *Info
+
+	Name        string
+	Type        ComponentType
+	Summary     string
+	Description string
+	// Required components are installed with the snap, the other
+	// ones only on request.
+	Required bool
+}
+
+// FullName returns the name of the component prefixed with the name of
+// its snap, as in <snap>+<component>.
+func (comp *Component) FullName() string {
+	return ComponentFullName(comp.Snap.SnapName(), comp.Name)
+}
+
+// ComponentFullName returns the name of a component prefixed with the
+// name of its snap.
+func ComponentFullName(snapName, componentName string) string {
+	return snapName + "+" + componentName
+}
+
+// SplitComponentName splits the full name of a component into the names
+// of the snap and of the component.
+func SplitComponentName(fullName string) (snapName, componentName string, err error) {
+	snapName, componentName = splitComponentName(fullName)
+	if snapName == "" || componentName == "" {
+		return "", "", fmt.Errorf("incorrect component name %q", fullName)
+	}
+	return snapName, componentName, nil
+}
+
+func splitComponentName(fullName string) (snapName, componentName string) {
+	idx := strings.IndexByte(fullName, '+')
+	if idx == -1 {
+		return "", ""
+	}
+	return fullName[:idx], fullName[idx+1:]
+}
+
+// SelectComponents returns the components to install along with the snap:
+// the required ones and the requested optional ones, sorted by name. It
+// is an error to request a component the snap does not declare.
+func (s *Info) SelectComponents(requested []string) ([]*Component, error) {
+	selected := make(map[string]*Component, len(requested))
+	for _, name := range requested {
+		comp, ok := s.Components[name]
+		if !ok {
+			return nil, fmt.Errorf("snap %q has no component %q", s.InstanceName(), name)
+		}
+		selected[name] = comp
+	}
+	for name, comp := range s.Components {
+		if comp.Required {
+			selected[name] = comp
+		}
+	}
+
+	comps := make([]*Component, 0, len(selected))
+	for _, comp := range selected {
+		comps = append(comps, comp)
+	}
+	sort.Slice(comps, func(i, j int) bool { return comps[i].Name < comps[j].Name })
+	return comps, nil
+}
+
+// ComponentInfo holds the information about a built component, as
+// described by its meta/component.yaml.
+type ComponentInfo struct {
+	// SnapName is the name of the snap the component belongs to.
+	SnapName string `yaml:"-"`
+	// Name is the name of the component.
+	Name        string        `yaml:"-"`
+	FullName    string        `yaml:"component"`
+	Type        ComponentType `yaml:"type"`
+	Version     string        `yaml:"version"`
+	Summary     string        `yaml:"summary"`
+	Description string        `yaml:"description"`
+}
+
+// InfoFromComponentYaml parses and validates the given component.yaml.
+func InfoFromComponentYaml(compYaml []byte) (*ComponentInfo, error) {
+	var ci ComponentInfo
+	if err := yaml.Unmarshal(compYaml, &ci); err != nil {
+		return nil, fmt.Errorf("cannot parse component.yaml: %s", err)
+	}
+	if ci.FullName == "" {
+		return nil, fmt.Errorf(`cannot parse component.yaml: missing "component" field`)
+	}
+	snapName, compName, err := SplitComponentName(ci.FullName)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateName(snapName); err != nil {
+		return nil, err
+	}
+	if err := naming.ValidateComponent(compName); err != nil {
+		return nil, err
+	}
+	if err := validateComponentType(ci.Type); err != nil {
+		return nil, err
+	}
+	if err := ValidateVersion(ci.Version); err != nil {
+		return nil, err
+	}
+	ci.SnapName = snapName
+	ci.Name = compName
+	return &ci, nil
+}
+
+// ReadComponentInfoFromContainer reads the component.yaml of the given
+// component container and checks that the component is declared by the
+// snap.
+func ReadComponentInfoFromContainer(compf Container, snapInfo *Info) (*ComponentInfo, error) {
+	compYaml, err := compf.ReadFile("meta/component.yaml")
+	if err != nil {
+		return nil, err
+	}
+	ci, err := InfoFromComponentYaml(compYaml)
+	if err != nil {
+		return nil, err
+	}
+	if snapInfo != nil {
+		if ci.SnapName != snapInfo.SnapName() {
+			return nil, fmt.Errorf("component %q does not belong to snap %q", ci.FullName, snapInfo.SnapName())
+		}
+		comp, ok := snapInfo.Components[ci.Name]
+		if !ok {
+			return nil, fmt.Errorf("component %q is not declared by snap %q", ci.FullName, snapInfo.SnapName())
+		}
+		if comp.Type != ci.Type {
+			return nil, fmt.Errorf("inconsistent component type (%q in snap, %q in component)", comp.Type, ci.Type)
+		}
+	}
+	return ci, nil
+}
+
+func validateComponentType(typ ComponentType) error {
+	for _, t := range validComponentTypes {
+		if typ == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot use unknown component type %q", typ)
+}
+
+// ValidateComponents validates the components declared by the snap.
+func ValidateComponents(info *Info) error {
+	for name, comp := range info.Components {
+		if err := naming.ValidateComponent(name); err != nil {
+			return err
+		}
+		if err := validateComponentType(comp.Type); err != nil {
+			return fmt.Errorf("invalid definition of component %q: %v", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snapdir"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type componentSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&componentSuite{})
+
+func (s *componentSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+}
+
+const componentsSnapYaml = `name: foo
+version: 1.0
+components:
+  debug:
+    type: debug-symbols
+  data:
+    type: assets
+    required: true
+  tests:
+    type: test
+`
+
+func (s *componentSuite) TestSplitComponentName(c *C) {
+	snapName, compName, err := snap.SplitComponentName("foo+debug")
+	c.Assert(err, IsNil)
+	c.Check(snapName, Equals, "foo")
+	c.Check(compName, Equals, "debug")
+
+	for _, name := range []string{"foo", "foo+", "+debug", ""} {
+		_, _, err := snap.SplitComponentName(name)
+		c.Check(err, ErrorMatches, `incorrect component name ".*"`)
+	}
+	c.Check(snap.ComponentFullName("foo", "debug"), Equals, "foo+debug")
+}
+
+func (s *componentSuite) TestSelectComponents(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(componentsSnapYaml))
+	c.Assert(err, IsNil)
+
+	comps, err := info.SelectComponents(nil)
+	c.Assert(err, IsNil)
+	c.Check(comps, DeepEquals, []*snap.Component{info.Components["data"]})
+
+	comps, err = info.SelectComponents([]string{"tests", "debug", "data"})
+	c.Assert(err, IsNil)
+	c.Check(comps, DeepEquals, []*snap.Component{info.Components["data"], info.Components["debug"], info.Components["tests"]})
+
+	_, err = info.SelectComponents([]string{"debug", "other"})
+	c.Check(err, ErrorMatches, `snap "foo" has no component "other"`)
+}
+
+func (s *componentSuite) TestInfoFromComponentYaml(c *C) {
+	ci, err := snap.InfoFromComponentYaml([]byte(`component: foo+debug
+type: debug-symbols
+version: 1.0
+summary: debug symbols of foo
+`))
+	c.Assert(err, IsNil)
+	c.Check(ci, DeepEquals, &snap.ComponentInfo{
+		SnapName: "foo",
+		Name:     "debug",
+		FullName: "foo+debug",
+		Type:     snap.DebugSymbolsComponent,
+		Version:  "1.0",
+		Summary:  "debug symbols of foo",
+	})
+}
+
+func (s *componentSuite) TestInfoFromComponentYamlErrors(c *C) {
+	for _, t := range []struct {
+		yaml string
+		err  string
+	}{
+		{"type: test\nversion: 1\n", `cannot parse component.yaml: missing "component" field`},
+		{"component: foo\ntype: test\nversion: 1\n", `incorrect component name "foo"`},
+		{"component: f+debug\ntype: test\nversion: 1\n", `invalid snap name: "f"`},
+		{"component: foo+d\ntype: test\nversion: 1\n", `invalid snap component name: "d"`},
+		{"component: foo+debug\ntype: other\nversion: 1\n", `cannot use unknown component type "other"`},
+		{"component: foo+debug\ntype: test\n", `invalid snap version: cannot be empty`},
+		{"component: [foo]\n", `(?s)cannot parse component.yaml: .*`},
+	} {
+		_, err := snap.InfoFromComponentYaml([]byte(t.yaml))
+		c.Check(err, ErrorMatches, t.err, Commentf(t.yaml))
+	}
+}
+
+func (s *componentSuite) TestReadComponentInfoFromContainer(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(componentsSnapYaml))
+	c.Assert(err, IsNil)
+
+	writeComponentYaml := func(content string) snap.Container {
+		d := c.MkDir()
+		c.Assert(os.MkdirAll(filepath.Join(d, "meta"), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(d, "meta", "component.yaml"), []byte(content), 0644), IsNil)
+		return snapdir.New(d)
+	}
+
+	ci, err := snap.ReadComponentInfoFromContainer(writeComponentYaml("component: foo+debug\ntype: debug-symbols\nversion: 1\n"), info)
+	c.Assert(err, IsNil)
+	c.Check(ci.Name, Equals, "debug")
+
+	_, err = snap.ReadComponentInfoFromContainer(writeComponentYaml("component: bar+debug\ntype: debug-symbols\nversion: 1\n"), info)
+	c.Check(err, ErrorMatches, `component "bar\+debug" does not belong to snap "foo"`)
+
+	_, err = snap.ReadComponentInfoFromContainer(writeComponentYaml("component: foo+other\ntype: test\nversion: 1\n"), info)
+	c.Check(err, ErrorMatches, `component "foo\+other" is not declared by snap "foo"`)
+
+	_, err = snap.ReadComponentInfoFromContainer(writeComponentYaml("component: foo+debug\ntype: test\nversion: 1\n"), info)
+	c.Check(err, ErrorMatches, `inconsistent component type \("debug-symbols" in snap, "test" in component\)`)
+
+	_, err = snap.ReadComponentInfoFromContainer(snapdir.New(c.MkDir()), info)
+	c.Check(err, NotNil)
+}
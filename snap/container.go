@@ -25,7 +25,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/snap/snapdir"
@@ -54,6 +56,34 @@ type Container interface {
 	Unpack(src, dst string) error
 }
 
+// filesReader is implemented by containers that can read several files
+// at once, cheaper than reading them one by one.
+type filesReader interface {
+	ReadFiles(paths []string) (map[string][]byte, error)
+}
+
+// ReadFiles returns the content of the given files of the container, keyed
+// by their path. Files that do not exist in the container are not part of
+// the result. Squashfs snaps extract all the files with a single unsquashfs
+// call, without mounting the snap.
+func ReadFiles(c Container, paths []string) (map[string][]byte, error) {
+	if fr, ok := c.(filesReader); ok {
+		return fr.ReadFiles(paths)
+	}
+	contents := make(map[string][]byte, len(paths))
+	for _, p := range paths {
+		content, err := c.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		contents[p] = content
+	}
+	return contents, nil
+}
+
 // backend implements a specific snap format
 type snapFormat struct {
 	magic []byte
@@ -100,38 +130,77 @@ func Open(path string) (Container, error) {
 }
 
 var (
-	// ErrBadModes is returned by ValidateContainer when the container has files with the wrong file modes for their role
+	// ErrBadModes is the summary of a ContainerValidationError for a
+	// container that has files with the wrong file modes for their role
 	ErrBadModes = errors.New("snap is unusable due to bad permissions")
-	// ErrMissingPaths is returned by ValidateContainer when the container is missing required files or directories
+	// ErrMissingPaths is the summary of a ContainerValidationError for
+	// a container that is missing required files or directories
 	ErrMissingPaths = errors.New("snap is unusable due to missing files")
 )
 
-// ValidateContainer does a minimal sanity check on the container.
-func ValidateContainer(c Container, s *Info, logf func(format string, v ...interface{})) error {
+// ContainerValidationError is returned by ValidateContainer and lists
+// all the problems found in the container.
+type ContainerValidationError struct {
+	// Err is ErrMissingPaths if any required path is missing,
+	// ErrBadModes otherwise.
+	Err error
+	// MissingPaths are the required paths that do not exist.
+	MissingPaths []string
+	// BadModes describes the paths that have the wrong type or
+	// file mode for their role.
+	BadModes []string
+}
+
+func (e *ContainerValidationError) Error() string {
+	return e.Err.Error()
+}
+
+// containerValidator tracks the requirements on the paths of a
+// container and the problems found while walking it.
+type containerValidator struct {
+	c    Container
+	info *Info
+
 	// needsrx keeps track of things that need to have at least 0555 perms
-	needsrx := map[string]bool{
-		".":    true,
-		"meta": true,
-	}
+	needsrx map[string]bool
 	// needsx keeps track of things that need to have at least 0111 perms
-	needsx := map[string]bool{}
+	needsx map[string]bool
 	// needsr keeps track of things that need to have at least 0444 perms
-	needsr := map[string]bool{
-		"meta/snap.yaml": true,
-	}
+	needsr map[string]bool
 	// needsf keeps track of things that need to be regular files (or symlinks to regular files)
-	needsf := map[string]bool{}
+	needsf map[string]bool
 	// noskipd tracks directories we want to descend into despite not being in needs*
-	noskipd := map[string]bool{}
+	noskipd map[string]bool
+
+	mu       sync.Mutex
+	seen     map[string]bool
+	badModes []string
+}
+
+func newContainerValidator(c Container, s *Info) *containerValidator {
+	v := &containerValidator{
+		c:    c,
+		info: s,
+		needsrx: map[string]bool{
+			".":    true,
+			"meta": true,
+		},
+		needsx: map[string]bool{},
+		needsr: map[string]bool{
+			"meta/snap.yaml": true,
+		},
+		needsf:  map[string]bool{},
+		noskipd: map[string]bool{},
+	}
 
 	for _, app := range s.Apps {
 		// for non-services, paths go into the needsrx bag because users
 		// need rx perms to execute it
-		bag := needsrx
+		bag := v.needsrx
 		paths := []string{app.Command}
 		if app.IsService() {
 			// services' paths just need to not be skipped by the validator
-			bag = noskipd
+			bag = v.noskipd
 			// additional paths to check for services:
 			// XXX maybe have a method on app to keep this in sync
 			paths = append(paths, app.StopCommand, app.ReloadCommand, app.PostStopCommand)
@@ -143,9 +212,9 @@ func ValidateContainer(c Container, s *Info, logf func(format string, v ...inter
 				continue
 			}
 
-			needsf[path] = true
+			v.needsf[path] = true
 			if app.IsService() {
-				needsx[path] = true
+				v.needsx[path] = true
 			}
 			for ; path != "."; path = filepath.Dir(path) {
 				bag[path] = true
@@ -154,103 +223,179 @@ func ValidateContainer(c Container, s *Info, logf func(format string, v ...inter
 
 		// completer is special :-/
 		if path := normPath(app.Completer); path != "" {
-			needsr[path] = true
+			v.needsr[path] = true
 			for path = filepath.Dir(path); path != "."; path = filepath.Dir(path) {
-				needsrx[path] = true
+				v.needsrx[path] = true
 			}
 		}
 	}
 	// note all needsr so far need to be regular files (or symlinks)
-	for k := range needsr {
-		needsf[k] = true
+	for k := range v.needsr {
+		v.needsf[k] = true
 	}
 	// thing can get jumbled up
-	for path := range needsrx {
-		delete(needsx, path)
-		delete(needsr, path)
+	for path := range v.needsrx {
+		delete(v.needsx, path)
+		delete(v.needsr, path)
 	}
-	for path := range needsx {
-		if needsr[path] {
-			delete(needsx, path)
-			delete(needsr, path)
-			needsrx[path] = true
+	for path := range v.needsx {
+		if v.needsr[path] {
+			delete(v.needsx, path)
+			delete(v.needsr, path)
+			v.needsrx[path] = true
 		}
 	}
-	seen := make(map[string]bool, len(needsx)+len(needsrx)+len(needsr))
+	v.seen = make(map[string]bool, len(v.needsx)+len(v.needsrx)+len(v.needsr))
 
-	// bad modes are logged instead of being returned because the end user
-	// can do nothing with the info (and the developer can read the logs)
-	hasBadModes := false
-	err := c.Walk(".", func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+	return v
+}
+
+func (v *containerValidator) badModef(format string, a ...interface{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.badModes = append(v.badModes, fmt.Sprintf(format, a...))
+}
+
+// check checks the given path of the container and returns whether
+// the walk should descend into it, if it is a directory.
+func (v *containerValidator) check(path string, mode os.FileMode) (descend bool) {
+	needed := v.needsrx[path] || v.needsx[path] || v.needsr[path]
+	if needed {
+		v.mu.Lock()
+		v.seen[path] = true
+		v.mu.Unlock()
+	}
+	if !needed && !strings.HasPrefix(path, "meta/") {
+		return mode.IsDir() && v.noskipd[path]
+	}
 
-		mode := info.Mode()
-		if needsrx[path] || needsx[path] || needsr[path] {
-			seen[path] = true
+	if v.needsrx[path] || mode.IsDir() {
+		if mode.Perm()&0555 != 0555 {
+			v.badModef("%q should be world-readable and executable, and isn't: %s", path, mode)
 		}
-		if !needsrx[path] && !needsx[path] && !needsr[path] && !strings.HasPrefix(path, "meta/") {
-			if mode.IsDir() {
-				if noskipd[path] {
-					return nil
-				}
-				return filepath.SkipDir
+	} else {
+		if v.needsf[path] {
+			// this assumes that if it's a symlink it's OK. Arguably we
+			// should instead follow the symlink.  We'd have to expose
+			// Lstat(), and guard against loops, and ...  huge can of
+			// worms, and as this validator is meant as a developer aid
+			// more than anything else, not worth it IMHO (as I can't
+			// imagine this happening by accident).
+			if mode&(os.ModeDir|os.ModeNamedPipe|os.ModeSocket|os.ModeDevice) != 0 {
+				v.badModef("%q should be a regular file (or a symlink) and isn't", path)
 			}
-			return nil
 		}
-
-		if needsrx[path] || mode.IsDir() {
-			if mode.Perm()&0555 != 0555 {
-				logf("in snap %q: %q should be world-readable and executable, and isn't: %s", s.InstanceName(), path, mode)
-				hasBadModes = true
+		if v.needsx[path] || strings.HasPrefix(path, "meta/hooks/") {
+			if mode.Perm()&0111 == 0 {
+				v.badModef("%q should be executable, and isn't: %s", path, mode)
 			}
 		} else {
-			if needsf[path] {
-				// this assumes that if it's a symlink it's OK. Arguably we
-				// should instead follow the symlink.  We'd have to expose
-				// Lstat(), and guard against loops, and ...  huge can of
-				// worms, and as this validator is meant as a developer aid
-				// more than anything else, not worth it IMHO (as I can't
-				// imagine this happening by accident).
-				if mode&(os.ModeDir|os.ModeNamedPipe|os.ModeSocket|os.ModeDevice) != 0 {
-					logf("in snap %q: %q should be a regular file (or a symlink) and isn't", s.InstanceName(), path)
-					hasBadModes = true
-				}
-			}
-			if needsx[path] || strings.HasPrefix(path, "meta/hooks/") {
-				if mode.Perm()&0111 == 0 {
-					logf("in snap %q: %q should be executable, and isn't: %s", s.InstanceName(), path, mode)
-					hasBadModes = true
-				}
-			} else {
-				// in needsr, or under meta but not a hook
-				if mode.Perm()&0444 != 0444 {
-					logf("in snap %q: %q should be world-readable, and isn't: %s", s.InstanceName(), path, mode)
-					hasBadModes = true
-				}
+			// in needsr, or under meta but not a hook
+			if mode.Perm()&0444 != 0444 {
+				v.badModef("%q should be world-readable, and isn't: %s", path, mode)
 			}
 		}
-		return nil
+	}
+	return true
+}
+
+// walk checks the top level of the container and then walks the
+// directories it needs to descend into in parallel.
+func (v *containerValidator) walk() error {
+	var subdirs []string
+	err := v.c.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		descend := v.check(path, info.Mode())
+		if path == "." || !info.IsDir() {
+			return nil
+		}
+		if descend {
+			subdirs = append(subdirs, path)
+		}
+		// the subdirectories are walked separately
+		return filepath.SkipDir
 	})
 	if err != nil {
 		return err
 	}
-	if len(seen) != len(needsx)+len(needsrx)+len(needsr) {
-		for _, needs := range []map[string]bool{needsx, needsrx, needsr} {
+
+	errs := make([]error, len(subdirs))
+	var wg sync.WaitGroup
+	for i, subdir := range subdirs {
+		wg.Add(1)
+		go func(i int, subdir string) {
+			defer wg.Done()
+			errs[i] = v.c.Walk(subdir, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if path == subdir {
+					// already checked from the top level
+					return nil
+				}
+				if !v.check(path, info.Mode()) && info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			})
+		}(i, subdir)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateContainer does a minimal sanity check on the container. All
+// the problems found are returned together in a *ContainerValidationError.
+func ValidateContainer(c Container, s *Info, logf func(format string, v ...interface{})) error {
+	v := newContainerValidator(c, s)
+	if err := v.walk(); err != nil {
+		return err
+	}
+
+	var missing []string
+	if len(v.seen) != len(v.needsx)+len(v.needsrx)+len(v.needsr) {
+		for _, needs := range []map[string]bool{v.needsx, v.needsrx, v.needsr} {
 			for path := range needs {
-				if !seen[path] {
-					logf("in snap %q: path %q does not exist", s.InstanceName(), path)
+				if !v.seen[path] {
+					missing = append(missing, path)
 				}
 			}
 		}
-		return ErrMissingPaths
+	}
+	if len(missing) == 0 && len(v.badModes) == 0 {
+		return nil
 	}
 
-	if hasBadModes {
-		return ErrBadModes
+	// the order of the walk is not deterministic
+	sort.Strings(missing)
+	sort.Strings(v.badModes)
+
+	// the problems are also logged as the end user can do nothing
+	// with the info (and the developer can read the logs)
+	for _, badMode := range v.badModes {
+		logf("in snap %q: %s", s.InstanceName(), badMode)
 	}
-	return nil
+	for _, path := range missing {
+		logf("in snap %q: path %q does not exist", s.InstanceName(), path)
+	}
+
+	verr := &ContainerValidationError{
+		Err:          ErrBadModes,
+		MissingPaths: missing,
+		BadModes:     v.badModes,
+	}
+	if len(missing) > 0 {
+		verr.Err = ErrMissingPaths
+	}
+	return verr
 }
 
 // normPath is a helper for validateContainer. It takes a relative path (e.g. an
@@ -20,6 +20,7 @@
 package snap_test
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -29,6 +30,7 @@ import (
 
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snapdir"
+	"github.com/snapcore/snapd/snap/snaptest"
 
 	"github.com/snapcore/snapd/testutil"
 )
@@ -56,6 +58,38 @@ func (s *FileSuite) TestFileOpenForSnapDirErrors(c *C) {
 	c.Assert(err, ErrorMatches, `"/.*" is not a snap or snapdir`)
 }
 
+func (s *FileSuite) TestReadFilesSnapDir(c *C) {
+	sd := c.MkDir()
+	snaptest.PopulateDir(sd, [][]string{
+		{"meta/snap.yaml", "name: foo"},
+		{"meta/gadget.yaml", "volumes: {}"},
+	})
+
+	f, err := snap.Open(sd)
+	c.Assert(err, IsNil)
+	files, err := snap.ReadFiles(f, []string{"meta/snap.yaml", "meta/gadget.yaml", "meta/missing"})
+	c.Assert(err, IsNil)
+	c.Check(files, DeepEquals, map[string][]byte{
+		"meta/snap.yaml":   []byte("name: foo"),
+		"meta/gadget.yaml": []byte("volumes: {}"),
+	})
+}
+
+func (s *FileSuite) TestReadFilesSquashfs(c *C) {
+	snapPath := snaptest.MakeTestSnapWithFiles(c, "name: foo\nversion: 1.0", [][]string{
+		{"meta/gadget.yaml", "volumes: {}"},
+	})
+	f, err := snap.Open(snapPath)
+	c.Assert(err, IsNil)
+
+	files, err := snap.ReadFiles(f, []string{"meta/snap.yaml", "meta/gadget.yaml", "meta/missing"})
+	c.Assert(err, IsNil)
+	c.Check(files, DeepEquals, map[string][]byte{
+		"meta/snap.yaml":   []byte("name: foo\nversion: 1.0"),
+		"meta/gadget.yaml": []byte("volumes: {}"),
+	})
+}
+
 type validateSuite struct {
 	testutil.BaseTest
 	log func(string, ...interface{})
@@ -85,7 +119,7 @@ version: 1
 	c.Assert(err, IsNil)
 
 	err = snap.ValidateContainer(snapdir.New(d), info, discard)
-	c.Check(err, Equals, snap.ErrMissingPaths)
+	c.Check(err, ErrorMatches, snap.ErrMissingPaths.Error())
 }
 
 func (s *validateSuite) TestValidateContainerEmptyButBadPermFails(c *C) {
@@ -107,7 +141,7 @@ version: 1
 	c.Assert(err, IsNil)
 
 	err = snap.ValidateContainer(snapdir.New(d), info, discard)
-	c.Check(err, Equals, snap.ErrBadModes)
+	c.Check(err, ErrorMatches, snap.ErrBadModes.Error())
 }
 
 func (s *validateSuite) TestValidateContainerMissingSnapYamlFails(c *C) {
@@ -124,7 +158,7 @@ version: 1
 	c.Assert(err, IsNil)
 
 	err = snap.ValidateContainer(snapdir.New(d), info, discard)
-	c.Check(err, Equals, snap.ErrMissingPaths)
+	c.Check(err, ErrorMatches, snap.ErrMissingPaths.Error())
 }
 
 func (s *validateSuite) TestValidateContainerSnapYamlBadPermsFails(c *C) {
@@ -143,7 +177,7 @@ version: 1
 	c.Assert(err, IsNil)
 
 	err = snap.ValidateContainer(snapdir.New(d), info, discard)
-	c.Check(err, Equals, snap.ErrBadModes)
+	c.Check(err, ErrorMatches, snap.ErrBadModes.Error())
 }
 
 func (s *validateSuite) TestValidateContainerSnapYamlNonRegularFails(c *C) {
@@ -162,7 +196,7 @@ version: 1
 	c.Assert(err, IsNil)
 
 	err = snap.ValidateContainer(snapdir.New(d), info, discard)
-	c.Check(err, Equals, snap.ErrBadModes)
+	c.Check(err, ErrorMatches, snap.ErrBadModes.Error())
 }
 
 // emptyContainer returns a minimal container that passes
@@ -206,7 +240,7 @@ apps:
 	c.Assert(err, IsNil)
 
 	err = snap.ValidateContainer(d, info, discard)
-	c.Check(err, Equals, snap.ErrMissingPaths)
+	c.Check(err, ErrorMatches, snap.ErrMissingPaths.Error())
 }
 
 func (s *validateSuite) TestValidateContainerBadAppPermsFails(c *C) {
@@ -225,7 +259,7 @@ apps:
 	c.Assert(err, IsNil)
 
 	err = snap.ValidateContainer(d, info, discard)
-	c.Check(err, Equals, snap.ErrBadModes)
+	c.Check(err, ErrorMatches, snap.ErrBadModes.Error())
 }
 
 func (s *validateSuite) TestValidateContainerBadAppDirPermsFails(c *C) {
@@ -245,7 +279,7 @@ apps:
 	c.Assert(err, IsNil)
 
 	err = snap.ValidateContainer(d, info, discard)
-	c.Check(err, Equals, snap.ErrBadModes)
+	c.Check(err, ErrorMatches, snap.ErrBadModes.Error())
 }
 
 func (s *validateSuite) TestValidateContainerBadSvcPermsFails(c *C) {
@@ -266,7 +300,7 @@ apps:
 	c.Assert(err, IsNil)
 
 	err = snap.ValidateContainer(d, info, discard)
-	c.Check(err, Equals, snap.ErrBadModes)
+	c.Check(err, ErrorMatches, snap.ErrBadModes.Error())
 }
 
 func (s *validateSuite) TestValidateContainerCompleterFails(c *C) {
@@ -289,7 +323,55 @@ apps:
 	c.Assert(err, IsNil)
 
 	err = snap.ValidateContainer(d, info, discard)
-	c.Check(err, Equals, snap.ErrMissingPaths)
+	c.Check(err, ErrorMatches, snap.ErrMissingPaths.Error())
+}
+
+func (s *validateSuite) TestValidateContainerReportsAllProblems(c *C) {
+	const yaml = `name: empty-snap
+version: 1
+apps:
+ foo:
+  command: cmds/foo
+ bar:
+  command: bin/bar
+ svc:
+  command: svcs/svc
+  stop-command: svcs/stop
+  daemon: simple
+`
+	d := emptyContainer(c)
+	c.Assert(os.Mkdir(filepath.Join(d.Path(), "cmds"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(d.Path(), "cmds", "foo"), nil, 0444), IsNil)
+	c.Assert(os.Mkdir(filepath.Join(d.Path(), "svcs"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(d.Path(), "svcs", "svc"), nil, 0644), IsNil)
+	c.Assert(os.Mkdir(filepath.Join(d.Path(), "meta", "hooks"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(d.Path(), "meta", "hooks", "install"), nil, 0644), IsNil)
+
+	// the commands and the hook are not executable and some of the
+	// commands are missing altogether
+
+	info, err := snap.InfoFromSnapYaml([]byte(yaml))
+	c.Assert(err, IsNil)
+
+	var logged []string
+	logf := func(format string, v ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, v...))
+	}
+
+	err = snap.ValidateContainer(d, info, logf)
+	c.Check(err, ErrorMatches, snap.ErrMissingPaths.Error())
+	c.Assert(err, FitsTypeOf, &snap.ContainerValidationError{})
+	verr := err.(*snap.ContainerValidationError)
+	c.Check(verr.Err, Equals, snap.ErrMissingPaths)
+	c.Check(verr.MissingPaths, DeepEquals, []string{"bin", "bin/bar", "svcs/stop"})
+	c.Check(verr.BadModes, DeepEquals, []string{
+		`"cmds/foo" should be world-readable and executable, and isn't: -r--r--r--`,
+		`"meta/hooks/install" should be executable, and isn't: -rw-r--r--`,
+		`"svcs/svc" should be executable, and isn't: -rw-r--r--`,
+	})
+	c.Check(logged, HasLen, 6)
+	c.Check(logged[0], Equals, `in snap "empty-snap": "cmds/foo" should be world-readable and executable, and isn't: -r--r--r--`)
+	c.Check(logged[5], Equals, `in snap "empty-snap": path "svcs/stop" does not exist`)
 }
 
 func (s *validateSuite) TestValidateContainerBadAppPathOK(c *C) {
@@ -331,7 +413,7 @@ apps:
 	c.Assert(err, IsNil)
 
 	err = snap.ValidateContainer(d, info, discard)
-	c.Check(err, Equals, snap.ErrBadModes)
+	c.Check(err, ErrorMatches, snap.ErrBadModes.Error())
 }
 
 func (s *validateSuite) TestValidateContainerSymlinksOK(c *C) {
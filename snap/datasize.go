@@ -0,0 +1,75 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// InstallSize returns an estimate, in bytes, of the disk space needed to
+// install the given snap from the given container. It is the sum of the
+// uncompressed size of the content of the container and of the size of
+// the system data of the currently active revision of the snap, if any,
+// which is copied over when a new revision is installed.
+func InstallSize(info *Info, container Container) (uint64, error) {
+	var total uint64
+	if err := container.Walk(".", sumRegularFiles(&total)); err != nil {
+		return 0, err
+	}
+
+	dataSize, err := CurrentDataSize(info.InstanceName())
+	if err != nil {
+		return 0, err
+	}
+	return total + dataSize, nil
+}
+
+// CurrentDataSize returns the size, in bytes, of the files in the system
+// data directory of the currently active revision of the given snap, that
+// is the data which is copied over when a new revision is installed. It
+// returns 0 if the snap has no current data.
+func CurrentDataSize(instanceName string) (uint64, error) {
+	dataDir, err := filepath.EvalSymlinks(filepath.Join(BaseDataDir(instanceName), "current"))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var total uint64
+	err = filepath.Walk(dataDir, sumRegularFiles(&total))
+	return total, err
+}
+
+// sumRegularFiles returns a walk function adding the size of the regular
+// files it visits to total.
+func sumRegularFiles(total *uint64) filepath.WalkFunc {
+	return func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			*total += uint64(fi.Size())
+		}
+		return nil
+	}
+}
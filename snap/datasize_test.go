@@ -0,0 +1,132 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snapdir"
+)
+
+type dataSizeSuite struct{}
+
+var _ = Suite(&dataSizeSuite{})
+
+func (s *dataSizeSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *dataSizeSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func writeFiles(c *C, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		c.Assert(os.MkdirAll(filepath.Dir(path), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+	}
+}
+
+func (s *dataSizeSuite) TestCurrentDataSizeNoData(c *C) {
+	size, err := snap.CurrentDataSize("foo")
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, uint64(0))
+}
+
+func (s *dataSizeSuite) TestCurrentDataSize(c *C) {
+	writeFiles(c, snap.DataDir("foo_bar", snap.R(1)), map[string]string{
+		"state/db":    "some data",
+		"config.json": "{}",
+	})
+	c.Assert(os.Symlink("config.json", filepath.Join(snap.DataDir("foo_bar", snap.R(1)), "link")), IsNil)
+	// older revisions are not copied on refresh
+	writeFiles(c, snap.DataDir("foo_bar", snap.R(0)), map[string]string{
+		"old": "ignored",
+	})
+	// common data is not copied on refresh
+	writeFiles(c, snap.CommonDataDir("foo_bar"), map[string]string{
+		"cache": "ignored",
+	})
+	c.Assert(os.Symlink("1", filepath.Join(snap.BaseDataDir("foo_bar"), "current")), IsNil)
+
+	size, err := snap.CurrentDataSize("foo_bar")
+	c.Assert(err, IsNil)
+	// symlinks and directories are not accounted for
+	c.Check(size, Equals, uint64(len("some data")+len("{}")))
+}
+
+func (s *dataSizeSuite) TestCurrentDataSizeError(c *C) {
+	baseDir := snap.BaseDataDir("foo")
+	c.Assert(os.MkdirAll(baseDir, 0755), IsNil)
+	c.Assert(os.Symlink("current", filepath.Join(baseDir, "current")), IsNil)
+
+	_, err := snap.CurrentDataSize("foo")
+	c.Assert(err, ErrorMatches, `EvalSymlinks: too many links`)
+}
+
+func (s *dataSizeSuite) TestInstallSizeFresh(c *C) {
+	sd := c.MkDir()
+	writeFiles(c, sd, map[string]string{
+		"meta/snap.yaml": "name: foo\nversion: 1\n",
+		"bin/foo":        "0123456789",
+	})
+	c.Assert(os.Symlink("foo", filepath.Join(sd, "bin", "bar")), IsNil)
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "foo", Revision: snap.R(2)}}
+
+	size, err := snap.InstallSize(info, snapdir.New(sd))
+	c.Assert(err, IsNil)
+	// symlinks and directories are not accounted for
+	c.Check(size, Equals, uint64(len("name: foo\nversion: 1\n")+10))
+}
+
+func (s *dataSizeSuite) TestInstallSizeWithCurrentData(c *C) {
+	sd := c.MkDir()
+	writeFiles(c, sd, map[string]string{
+		"meta/snap.yaml": "name: foo_bar\n",
+	})
+	writeFiles(c, snap.DataDir("foo_bar", snap.R(1)), map[string]string{
+		"state/db":    "some data",
+		"config.json": "{}",
+	})
+	// common data is not copied on refresh
+	writeFiles(c, snap.CommonDataDir("foo_bar"), map[string]string{
+		"cache": "ignored",
+	})
+	c.Assert(os.Symlink("1", filepath.Join(snap.BaseDataDir("foo_bar"), "current")), IsNil)
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "foo", Revision: snap.R(2)}, InstanceKey: "bar"}
+
+	size, err := snap.InstallSize(info, snapdir.New(sd))
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, uint64(len("name: foo_bar\n")+len("some data")+len("{}")))
+}
+
+func (s *dataSizeSuite) TestInstallSizeWalkError(c *C) {
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "foo", Revision: snap.R(2)}}
+
+	_, err := snap.InstallSize(info, snapdir.New(filepath.Join(c.MkDir(), "missing")))
+	c.Assert(err, ErrorMatches, `.*/missing: no such file or directory`)
+}
@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/snapcore/snapd/logger"
 )
@@ -62,11 +63,28 @@ type Epoch struct {
 	Write []uint32 `yaml:"write"`
 }
 
+// ParseEpoch returns the epoch represented by the expression s, which is
+// either the simplified "N" or "N*" form or the structured form as
+// produced by String, e.g. {"read":[1,2],"write":[2]}.
+func ParseEpoch(s string) (Epoch, error) {
+	var e Epoch
+	if strings.HasPrefix(strings.TrimSpace(s), "{") {
+		if err := e.UnmarshalJSON([]byte(s)); err != nil {
+			return Epoch{}, err
+		}
+		return e, nil
+	}
+	if err := e.fromString(s); err != nil {
+		return Epoch{}, err
+	}
+	return e, nil
+}
+
 // E returns the epoch represented by the expression s. It's meant for use in
 // testing, as it panics at the first sign of trouble.
 func E(s string) Epoch {
-	var e Epoch
-	if err := e.fromString(s); err != nil {
+	e, err := ParseEpoch(s)
+	if err != nil {
 		panic(fmt.Errorf("%q: %v", s, err))
 	}
 	return e
@@ -266,6 +284,16 @@ func (e *Epoch) CanRead(other Epoch) bool {
 	return intersect(rs, ws)
 }
 
+// CanWrite checks whether the data written by this epoch can be read by
+// the other one.
+func (e *Epoch) CanWrite(other Epoch) bool {
+	var self Epoch
+	if e != nil {
+		self = *e
+	}
+	return other.CanRead(self)
+}
+
 func intersect(rs, ws []uint32) bool {
 	// O(𝑚𝑛) instead of O(𝑚log𝑛) for the binary search we could do, but
 	// 𝑚 and 𝑛 < 10, so the simple solution is good enough (and if that
@@ -21,6 +21,8 @@ package snap_test
 
 import (
 	"encoding/json"
+	"strconv"
+
 	"github.com/snapcore/snapd/snap"
 
 	"gopkg.in/check.v1"
@@ -355,6 +357,112 @@ func (s *epochSuite) TestCanRead(c *check.C) {
 	}
 }
 
+func (s *epochSuite) TestParseEpoch(c *check.C) {
+	for _, test := range []struct {
+		s string
+		e snap.Epoch
+	}{
+		{s: "", e: snap.Epoch{Read: []uint32{0}, Write: []uint32{0}}},
+		{s: "0", e: snap.Epoch{Read: []uint32{0}, Write: []uint32{0}}},
+		{s: "3", e: snap.Epoch{Read: []uint32{3}, Write: []uint32{3}}},
+		{s: "3*", e: snap.Epoch{Read: []uint32{2, 3}, Write: []uint32{3}}},
+		{s: `{"read":[1,2,3],"write":[2]}`, e: snap.Epoch{Read: []uint32{1, 2, 3}, Write: []uint32{2}}},
+		{s: `{"write":[4]}`, e: snap.Epoch{Read: []uint32{4}, Write: []uint32{4}}},
+	} {
+		e, err := snap.ParseEpoch(test.s)
+		c.Assert(err, check.IsNil, check.Commentf("%q", test.s))
+		c.Check(e, check.DeepEquals, test.e, check.Commentf("%q", test.s))
+	}
+
+	for _, test := range []struct {
+		s string
+		e string
+	}{
+		{s: "0*", e: epochZeroStar},
+		{s: "01", e: badEpochNumber},
+		{s: "x", e: badEpochNumber},
+		{s: `{"read":[1],"write":[2]}`, e: noEpochIntersection},
+		{s: `{"read":[2,1]}`, e: epochListNotIncreasing},
+	} {
+		_, err := snap.ParseEpoch(test.s)
+		c.Check(err, check.ErrorMatches, test.e, check.Commentf("%q", test.s))
+	}
+}
+
+func (s *epochSuite) TestCanWrite(c *check.C) {
+	one, twoStar, zero := snap.E("1"), snap.E("2*"), snap.E("0")
+	c.Check(one.CanWrite(twoStar), check.Equals, true)
+	c.Check(twoStar.CanWrite(one), check.Equals, false)
+	c.Check(zero.CanWrite(snap.Epoch{}), check.Equals, true)
+
+	var nilEpoch *snap.Epoch
+	c.Check(nilEpoch.CanWrite(snap.E("1*")), check.Equals, true)
+	c.Check(nilEpoch.CanWrite(snap.E("1")), check.Equals, false)
+}
+
+// someEpochs returns a selection of valid epochs exercising the
+// simplified as well as the structured forms.
+func someEpochs() []snap.Epoch {
+	return []snap.Epoch{
+		{},
+		snap.E("0"),
+		snap.E("1"),
+		snap.E("1*"),
+		snap.E("2"),
+		snap.E("2*"),
+		snap.E("42*"),
+		{Read: []uint32{0, 1, 2}, Write: []uint32{2}},
+		{Read: []uint32{1, 2, 3}, Write: []uint32{1, 3}},
+		{Read: []uint32{0, 2, 4}, Write: []uint32{0}},
+		{Read: []uint32{3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, Write: []uint32{12}},
+	}
+}
+
+func (s *epochSuite) TestEpochProperties(c *check.C) {
+	epochs := someEpochs()
+	for _, a := range epochs {
+		a := a
+		comment := check.Commentf("%s", a)
+
+		// every epoch can read what it writes
+		c.Check(a.CanRead(a), check.Equals, true, comment)
+		c.Check(a.CanWrite(a), check.Equals, true, comment)
+
+		// the string form parses back to an equal epoch
+		p, err := snap.ParseEpoch(a.String())
+		c.Assert(err, check.IsNil, comment)
+		c.Check(p.Equal(&a), check.Equals, true, comment)
+		c.Check(p.String(), check.Equals, a.String(), comment)
+
+		// and so does the JSON form
+		buf, err := json.Marshal(a)
+		c.Assert(err, check.IsNil, comment)
+		p, err = snap.ParseEpoch(string(buf))
+		c.Assert(err, check.IsNil, comment)
+		c.Check(p.Equal(&a), check.Equals, true, comment)
+
+		for _, b := range epochs {
+			b := b
+			comment := check.Commentf("%s vs %s", a, b)
+			// writing and reading are two sides of the same coin
+			c.Check(a.CanWrite(b), check.Equals, b.CanRead(a), comment)
+			// equal epochs are interchangeable
+			if a.Equal(&b) {
+				c.Check(a.CanRead(b), check.Equals, true, comment)
+				c.Check(b.CanRead(a), check.Equals, true, comment)
+			}
+		}
+	}
+
+	// N* can migrate data from N-1 but not the other way round
+	for n := 1; n < 100; n++ {
+		prev, star := snap.E(strconv.Itoa(n-1)), snap.E(strconv.Itoa(n)+"*")
+		c.Check(star.CanRead(prev), check.Equals, true, check.Commentf("%d", n))
+		c.Check(prev.CanRead(star), check.Equals, false, check.Commentf("%d", n))
+		c.Check(prev.CanWrite(star), check.Equals, true, check.Commentf("%d", n))
+	}
+}
+
 func (s *epochSuite) TestEqual(c *check.C) {
 	tests := []struct {
 		a, b *snap.Epoch
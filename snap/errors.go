@@ -48,3 +48,19 @@ type NotSnapError struct {
 func (e NotSnapError) Error() string {
 	return fmt.Sprintf("%q is not a snap or snapdir", e.Path)
 }
+
+// ClassicConfinementError is returned by CheckClassicConfinement when
+// the classic confinement requested by a snap is not sanctioned.
+type ClassicConfinementError struct {
+	Snap string
+	// NeedsClassicSystem is set if the snap cannot be used at all
+	// because the target system is not a classic one.
+	NeedsClassicSystem bool
+}
+
+func (e *ClassicConfinementError) Error() string {
+	if e.NeedsClassicSystem {
+		return fmt.Sprintf("snap %q requires classic confinement which is only available on classic systems", e.Snap)
+	}
+	return fmt.Sprintf("snap %q requires classic confinement", e.Snap)
+}
@@ -0,0 +1,121 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// ExecCommandChain returns the command-chain of the app as absolute paths
+// inside the mounted snap.
+func (app *AppInfo) ExecCommandChain() []string {
+	return absoluteCommandChain(app.Snap, app.CommandChain)
+}
+
+// ExecCommandChain returns the command-chain of the hook as absolute paths
+// inside the mounted snap.
+func (hook *HookInfo) ExecCommandChain() []string {
+	return absoluteCommandChain(hook.Snap, hook.CommandChain)
+}
+
+func absoluteCommandChain(info *Info, commandChain []string) []string {
+	chain := make([]string, 0, len(commandChain))
+	snapMountDir := info.MountDir()
+
+	for _, element := range commandChain {
+		chain = append(chain, filepath.Join(snapMountDir, element))
+	}
+
+	return chain
+}
+
+// ExecEnv returns the environment to execute the app with, that is base
+// followed by the environment of the snap and of the app, with variable
+// references substituted.
+func (app *AppInfo) ExecEnv(base []string) []string {
+	return append(base, osutil.SubstituteEnv(app.Env())...)
+}
+
+// ExecEnv returns the environment to execute the hook with, that is base
+// followed by the environment of the snap and of the hook, with variable
+// references substituted.
+func (hook *HookInfo) ExecEnv(base []string) []string {
+	return append(base, osutil.SubstituteEnv(hook.Env())...)
+}
+
+// ExpandCommandArgs expands any $VAR in args from the given environment.
+// Arguments that expand to nothing are dropped.
+func ExpandCommandArgs(args []string, env map[string]string) []string {
+	cmdArgs := make([]string, 0, len(args))
+	for _, arg := range args {
+		maybeExpanded := os.Expand(arg, func(k string) string {
+			return env[k]
+		})
+		if maybeExpanded != "" {
+			cmdArgs = append(cmdArgs, maybeExpanded)
+		}
+	}
+	return cmdArgs
+}
+
+// ExecCommand returns the complete command line to execute the given
+// command of the app, one of "" for the main command, "stop", "reload"
+// and "post-stop". The command-chain comes first, the arguments from
+// snap.yaml are expanded using env, which is typically the result of
+// ExecEnv, and args are appended last.
+func (app *AppInfo) ExecCommand(command string, env []string, args []string) ([]string, error) {
+	var cmdAndArgs string
+	switch command {
+	case "":
+		cmdAndArgs = app.Command
+	case "stop":
+		cmdAndArgs = app.StopCommand
+	case "reload":
+		cmdAndArgs = app.ReloadCommand
+	case "post-stop":
+		cmdAndArgs = app.PostStopCommand
+	default:
+		return nil, fmt.Errorf("cannot use %q command", command)
+	}
+	if cmdAndArgs == "" {
+		return nil, fmt.Errorf("no %q command found for %q", command, app.Name)
+	}
+
+	// strings.Split() is ok here because we validate all app fields and the
+	// whitelist is pretty strict (see validate.go:appContentWhitelist)
+	tmpArgv := strings.Split(cmdAndArgs, " ")
+
+	fullCmd := app.ExecCommandChain()
+	fullCmd = append(fullCmd, filepath.Join(app.Snap.MountDir(), tmpArgv[0]))
+	fullCmd = append(fullCmd, ExpandCommandArgs(tmpArgv[1:], osutil.EnvMap(env))...)
+	fullCmd = append(fullCmd, args...)
+	return fullCmd, nil
+}
+
+// ExecCommand returns the complete command line to execute the hook, with
+// its command-chain.
+func (hook *HookInfo) ExecCommand() []string {
+	return append(hook.ExecCommandChain(), filepath.Join(hook.Snap.HooksDir(), hook.Name))
+}
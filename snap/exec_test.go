@@ -0,0 +1,156 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type execSuite struct{}
+
+var _ = Suite(&execSuite{})
+
+const execYaml = `name: snapname
+version: 1.0
+environment:
+ SNAP_ENV: snap
+ OVERRIDDEN: snap
+apps:
+ app:
+  command: run-app --data $SNAP_DATA $EMPTY
+  stop-command: stop-app
+  command-chain: [chain1, chain2]
+  environment:
+   OVERRIDDEN: app
+   APP_PATH: $SNAP/app
+ nochain:
+  command: bin/run
+hooks:
+ configure:
+  command-chain: [chain3]
+  environment:
+   HOOK_ENV: hook
+`
+
+func (s *execSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *execSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *execSuite) TestExpandCommandArgs(c *C) {
+	for _, t := range []struct {
+		args     []string
+		env      map[string]string
+		expected []string
+	}{
+		{
+			args:     []string{"foo"},
+			env:      nil,
+			expected: []string{"foo"},
+		},
+		{
+			args:     []string{"$var"},
+			env:      map[string]string{"var": "value"},
+			expected: []string{"value"},
+		},
+		{
+			args:     []string{"foo", "$not_existing"},
+			env:      nil,
+			expected: []string{"foo"},
+		},
+		{
+			args:     []string{"foo", "$var", "baz"},
+			env:      map[string]string{"var": "bar", "unrelated": "env"},
+			expected: []string{"foo", "bar", "baz"},
+		},
+	} {
+		c.Check(snap.ExpandCommandArgs(t.args, t.env), DeepEquals, t.expected)
+	}
+}
+
+func (s *execSuite) TestAppExecEnv(c *C) {
+	info := snaptest.MockInfo(c, execYaml, &snap.SideInfo{Revision: snap.R(42)})
+
+	env := info.Apps["app"].ExecEnv([]string{"BASE=base"})
+	c.Check(env, DeepEquals, []string{
+		"BASE=base",
+		"SNAP_ENV=snap",
+		"OVERRIDDEN=app",
+		"APP_PATH=/app",
+	})
+}
+
+func (s *execSuite) TestAppExecCommand(c *C) {
+	info := snaptest.MockInfo(c, execYaml, &snap.SideInfo{Revision: snap.R(42)})
+	app := info.Apps["app"]
+	mountDir := info.MountDir()
+
+	c.Check(app.ExecCommandChain(), DeepEquals, []string{mountDir + "/chain1", mountDir + "/chain2"})
+
+	cmd, err := app.ExecCommand("", []string{"SNAP_DATA=/var/snap/snapname/42"}, []string{"--extra", "$SNAP_DATA"})
+	c.Assert(err, IsNil)
+	c.Check(cmd, DeepEquals, []string{
+		mountDir + "/chain1",
+		mountDir + "/chain2",
+		mountDir + "/run-app",
+		"--data", "/var/snap/snapname/42",
+		// arguments given by the user are not expanded
+		"--extra", "$SNAP_DATA",
+	})
+
+	cmd, err = app.ExecCommand("stop", nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(cmd, DeepEquals, []string{mountDir + "/chain1", mountDir + "/chain2", mountDir + "/stop-app"})
+
+	cmd, err = info.Apps["nochain"].ExecCommand("", nil, []string{"arg"})
+	c.Assert(err, IsNil)
+	c.Check(cmd, DeepEquals, []string{mountDir + "/bin/run", "arg"})
+}
+
+func (s *execSuite) TestAppExecCommandErrors(c *C) {
+	info := snaptest.MockInfo(c, execYaml, &snap.SideInfo{Revision: snap.R(42)})
+
+	_, err := info.Apps["app"].ExecCommand("xxx", nil, nil)
+	c.Check(err, ErrorMatches, `cannot use "xxx" command`)
+	_, err = info.Apps["app"].ExecCommand("reload", nil, nil)
+	c.Check(err, ErrorMatches, `no "reload" command found for "app"`)
+}
+
+func (s *execSuite) TestHookExec(c *C) {
+	info := snaptest.MockInfo(c, execYaml, &snap.SideInfo{Revision: snap.R(42)})
+	hook := info.Hooks["configure"]
+
+	c.Check(hook.ExecCommand(), DeepEquals, []string{
+		info.MountDir() + "/chain3",
+		info.MountDir() + "/meta/hooks/configure",
+	})
+	c.Check(hook.ExecEnv(nil), DeepEquals, []string{
+		"SNAP_ENV=snap",
+		"OVERRIDDEN=snap",
+		"HOOK_ENV=hook",
+	})
+}
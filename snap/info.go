@@ -260,6 +260,10 @@ type Info struct {
 	// List of system users (usernames) this snap may use. The group
 	// of the same name must also exist.
 	SystemUsernames map[string]*SystemUsernameInfo
+
+	// Components are the optional or separately built parts of the
+	// snap, indexed by name.
+	Components map[string]*Component
 }
 
 // StoreAccount holds information about a store account, for example
@@ -283,6 +287,9 @@ type Layout struct {
 	Group    string      `json:"group,omitempty"`
 	Mode     os.FileMode `json:"mode,omitempty"`
 	Symlink  string      `json:"symlink,omitempty"`
+	// Size is the size limit, in bytes, of a tmpfs layout. Zero means
+	// that the default limit of the kernel applies.
+	Size int64 `json:"size,omitempty"`
 }
 
 // String returns a simple textual representation of a layout.
@@ -310,6 +317,9 @@ func (l *Layout) String() string {
 	if l.Mode != 0755 {
 		fmt.Fprintf(&buf, ", mode: %#o", l.Mode)
 	}
+	if l.Size != 0 {
+		fmt.Fprintf(&buf, ", size: %d", l.Size)
+	}
 	return buf.String()
 }
 
@@ -442,6 +452,23 @@ func (s *Info) NeedsClassic() bool {
 	return s.Confinement == ClassicConfinement
 }
 
+// CheckClassicConfinement checks whether the classic confinement requested
+// by the snap, if any, is sanctioned. Classic confinement is only available
+// on classic systems and there it needs to be sanctioned explicitly, by the
+// user consenting to it or by a classic model listing the snap.
+func CheckClassicConfinement(info *Info, onClassic, sanctioned bool) error {
+	if !info.NeedsClassic() {
+		return nil
+	}
+	if !onClassic {
+		return &ClassicConfinementError{Snap: info.InstanceName(), NeedsClassicSystem: true}
+	}
+	if !sanctioned {
+		return &ClassicConfinementError{Snap: info.InstanceName()}
+	}
+	return nil
+}
+
 // Services returns a list of the apps that have "daemon" set.
 func (s *Info) Services() []*AppInfo {
 	svcs := make([]*AppInfo, 0, len(s.Apps))
@@ -724,6 +751,25 @@ func (st StopModeType) Validate() error {
 	return fmt.Errorf(`"stop-mode" field contains invalid value %q`, st)
 }
 
+// DaemonScope is the type for the "daemon-scope:" of a snap app,
+// telling whether a daemon runs in the system or in the sessions of
+// the logged-in users.
+type DaemonScope string
+
+const (
+	SystemDaemon DaemonScope = "system"
+	UserDaemon   DaemonScope = "user"
+)
+
+func (ds DaemonScope) Validate() error {
+	switch ds {
+	case "", SystemDaemon, UserDaemon:
+		// valid
+		return nil
+	}
+	return fmt.Errorf(`"daemon-scope" field contains invalid value %q`, ds)
+}
+
 // AppInfo provides information about an app.
 type AppInfo struct {
 	Snap *Info
@@ -735,6 +781,7 @@ type AppInfo struct {
 	CommonID      string
 
 	Daemon          string
+	DaemonScope     DaemonScope
 	StopTimeout     timeout.Timeout
 	StartTimeout    timeout.Timeout
 	WatchdogTimeout timeout.Timeout
@@ -752,6 +799,10 @@ type AppInfo struct {
 	// https://github.com/snapcore/snapd/pull/794#discussion_r58688496
 	BusName string
 
+	// ActivatesOn are the dbus interface slots whose bus names
+	// activate the service on demand.
+	ActivatesOn []*SlotInfo
+
 	Plugs   map[string]*PlugInfo
 	Slots   map[string]*SlotInfo
 	Sockets map[string]*SocketInfo
@@ -831,12 +882,25 @@ type SystemUsernameInfo struct {
 
 // File returns the path to the *.socket file
 func (socket *SocketInfo) File() string {
-	return filepath.Join(dirs.SnapServicesDir, socket.App.SecurityTag()+"."+socket.Name+".socket")
+	return filepath.Join(socket.App.serviceDir(), socket.App.SecurityTag()+"."+socket.Name+".socket")
 }
 
 // File returns the path to the *.timer file
 func (timer *TimerInfo) File() string {
-	return filepath.Join(dirs.SnapServicesDir, timer.App.SecurityTag()+".timer")
+	return filepath.Join(timer.App.serviceDir(), timer.App.SecurityTag()+".timer")
+}
+
+var calendarShorthands = []string{"minutely", "hourly", "daily", "weekly", "monthly", "quarterly", "semiannually", "yearly", "annually"}
+
+// UsesCalendarSyntax returns whether the timer is given as a list of
+// systemd calendar events separated by ";" rather than in the snapd
+// schedule format. The snapd format has no spaces nor any of "*",
+// ".." and ";", and it has no shorthands.
+func (timer *TimerInfo) UsesCalendarSyntax() bool {
+	if strings.ContainsAny(timer.Timer, " *;") || strings.Contains(timer.Timer, "..") {
+		return true
+	}
+	return strutil.ListContains(calendarShorthands, strings.ToLower(timer.Timer))
 }
 
 func (app *AppInfo) String() string {
@@ -904,9 +968,16 @@ func (app *AppInfo) ServiceName() string {
 	return app.SecurityTag() + ".service"
 }
 
+func (app *AppInfo) serviceDir() string {
+	if app.DaemonScope == UserDaemon {
+		return dirs.SnapUserServicesDir
+	}
+	return dirs.SnapServicesDir
+}
+
 // ServiceFile returns the systemd service file path for the daemon app.
 func (app *AppInfo) ServiceFile() string {
-	return filepath.Join(dirs.SnapServicesDir, app.ServiceName())
+	return filepath.Join(app.serviceDir(), app.ServiceName())
 }
 
 // Env returns the app specific environment overrides
@@ -924,6 +995,12 @@ func (app *AppInfo) IsService() bool {
 	return app.Daemon != ""
 }
 
+// IsUserService returns whether app represents a daemon/service that
+// runs in the user sessions rather than in the system.
+func (app *AppInfo) IsUserService() bool {
+	return app.IsService() && app.DaemonScope == UserDaemon
+}
+
 // SecurityTag returns the hook-specific security tag.
 //
 // Security tags are used by various security subsystems as "profile names" and
@@ -1085,7 +1162,32 @@ func ReadInfoFromSnapFile(snapf Container, si *SideInfo) (*Info, error) {
 	if err != nil {
 		return nil, err
 	}
+	return infoFromSnapFile(snapf, meta, si)
+}
 
+// ReadInfoAndFilesFromSnapFile is like ReadInfoFromSnapFile but also returns
+// the content of the given files of the snap, keyed by their path, files that
+// do not exist in the snap are not part of the result. The files are read
+// together with meta/snap.yaml, see ReadFiles.
+func ReadInfoAndFilesFromSnapFile(snapf Container, si *SideInfo, filePaths []string) (*Info, map[string][]byte, error) {
+	files, err := ReadFiles(snapf, append([]string{"meta/snap.yaml"}, filePaths...))
+	if err != nil {
+		return nil, nil, err
+	}
+	meta, ok := files["meta/snap.yaml"]
+	if !ok {
+		return nil, nil, fmt.Errorf("cannot find meta/snap.yaml in snap")
+	}
+	delete(files, "meta/snap.yaml")
+
+	info, err := infoFromSnapFile(snapf, meta, si)
+	if err != nil {
+		return nil, nil, err
+	}
+	return info, files, nil
+}
+
+func infoFromSnapFile(snapf Container, meta []byte, si *SideInfo) (*Info, error) {
 	strk := new(scopedTracker)
 	info, err := infoFromSnapYamlWithSideInfo(meta, si, strk)
 	if err != nil {
@@ -1182,6 +1284,10 @@ func (r ByType) Less(i, j int) bool {
 	return r[i].GetType().SortsBefore(r[j].GetType())
 }
 
+// SortServices sorts the given services of a snap so that each comes
+// after the services it declares to start after, and before the
+// services it declares to start before. Ordering dependencies on
+// services that are not part of the given list are ignored.
 func SortServices(apps []*AppInfo) (sorted []*AppInfo, err error) {
 	nameToApp := make(map[string]*AppInfo, len(apps))
 	for _, app := range apps {
@@ -1195,10 +1301,16 @@ func SortServices(apps []*AppInfo) (sorted []*AppInfo, err error) {
 
 	for _, app := range apps {
 		for _, other := range app.After {
+			if nameToApp[other] == nil {
+				continue
+			}
 			predecessors[app.Name]++
 			successors[other] = append(successors[other], app)
 		}
 		for _, other := range app.Before {
+			if nameToApp[other] == nil {
+				continue
+			}
 			predecessors[other]++
 			successors[app.Name] = append(successors[app.Name], nameToApp[other])
 		}
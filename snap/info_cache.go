@@ -0,0 +1,307 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// InfoCache caches the information read from installed snaps so that
+// snap.yaml is parsed again only when it or the snap file changed. Each
+// reader gets its own copy of the information.
+type InfoCache struct {
+	mu      sync.Mutex
+	entries map[infoCacheKey]*infoCacheEntry
+}
+
+type infoCacheKey struct {
+	name     string
+	revision Revision
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	mode    os.FileMode
+}
+
+func stampOf(fi os.FileInfo) fileStamp {
+	return fileStamp{modTime: fi.ModTime(), size: fi.Size(), mode: fi.Mode()}
+}
+
+type infoCacheEntry struct {
+	sideInfo  SideInfo
+	yamlStamp fileStamp
+	snapStamp fileStamp
+	info      *Info
+}
+
+// NewInfoCache returns an empty InfoCache.
+func NewInfoCache() *InfoCache {
+	return &InfoCache{
+		entries: make(map[infoCacheKey]*infoCacheEntry),
+	}
+}
+
+// ReadInfo works like snap.ReadInfo but returns the cached information
+// when the snap did not change since it was last read.
+func (c *InfoCache) ReadInfo(name string, si *SideInfo) (*Info, error) {
+	key := infoCacheKey{name: name, revision: si.Revision}
+	// stat before reading, a change while reading makes the entry stale
+	// instead of hiding the change
+	yamlFi, yamlErr := os.Stat(filepath.Join(MountDir(name, si.Revision), "meta", "snap.yaml"))
+	snapFi, snapErr := os.Lstat(MountFile(name, si.Revision))
+	cacheable := yamlErr == nil && snapErr == nil
+
+	c.mu.Lock()
+	if e := c.entries[key]; e != nil {
+		if cacheable && e.sideInfo == *si && e.yamlStamp == stampOf(yamlFi) && e.snapStamp == stampOf(snapFi) {
+			c.mu.Unlock()
+			return e.info.clone(), nil
+		}
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+
+	info, err := ReadInfo(name, si)
+	if err != nil || !cacheable {
+		return info, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = &infoCacheEntry{
+		sideInfo:  *si,
+		yamlStamp: stampOf(yamlFi),
+		snapStamp: stampOf(snapFi),
+		info:      info.clone(),
+	}
+	return info, nil
+}
+
+// Forget drops the cached information of all the revisions of the given
+// snap.
+func (c *InfoCache) Forget(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.name == name {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// clone returns a copy of the information that can be modified without
+// affecting the original, with all the references between apps, hooks,
+// plugs and slots pointing into the copy.
+func (s *Info) clone() *Info {
+	c := *s
+
+	plugs := make(map[*PlugInfo]*PlugInfo, len(s.Plugs))
+	c.Plugs = make(map[string]*PlugInfo, len(s.Plugs))
+	for name, plug := range s.Plugs {
+		plugCopy := *plug
+		plugCopy.Snap = &c
+		plugCopy.Attrs = copyAttrs(plug.Attrs)
+		plugs[plug] = &plugCopy
+		c.Plugs[name] = &plugCopy
+	}
+	slots := make(map[*SlotInfo]*SlotInfo, len(s.Slots))
+	c.Slots = make(map[string]*SlotInfo, len(s.Slots))
+	for name, slot := range s.Slots {
+		slotCopy := *slot
+		slotCopy.Snap = &c
+		slotCopy.Attrs = copyAttrs(slot.Attrs)
+		slots[slot] = &slotCopy
+		c.Slots[name] = &slotCopy
+	}
+	apps := make(map[*AppInfo]*AppInfo, len(s.Apps))
+	c.Apps = make(map[string]*AppInfo, len(s.Apps))
+	for name, app := range s.Apps {
+		appCopy := *app
+		appCopy.Snap = &c
+		apps[app] = &appCopy
+		c.Apps[name] = &appCopy
+	}
+	hooks := make(map[*HookInfo]*HookInfo, len(s.Hooks))
+	c.Hooks = make(map[string]*HookInfo, len(s.Hooks))
+	for name, hook := range s.Hooks {
+		hookCopy := *hook
+		hookCopy.Snap = &c
+		hooks[hook] = &hookCopy
+		c.Hooks[name] = &hookCopy
+	}
+
+	remapPlugs := func(m map[string]*PlugInfo) map[string]*PlugInfo {
+		if m == nil {
+			return nil
+		}
+		r := make(map[string]*PlugInfo, len(m))
+		for k, v := range m {
+			if plug, ok := plugs[v]; ok {
+				v = plug
+			}
+			r[k] = v
+		}
+		return r
+	}
+	remapSlots := func(m map[string]*SlotInfo) map[string]*SlotInfo {
+		if m == nil {
+			return nil
+		}
+		r := make(map[string]*SlotInfo, len(m))
+		for k, v := range m {
+			if slot, ok := slots[v]; ok {
+				v = slot
+			}
+			r[k] = v
+		}
+		return r
+	}
+	remapApps := func(m map[string]*AppInfo) map[string]*AppInfo {
+		if m == nil {
+			return nil
+		}
+		r := make(map[string]*AppInfo, len(m))
+		for k, v := range m {
+			if app, ok := apps[v]; ok {
+				v = app
+			}
+			r[k] = v
+		}
+		return r
+	}
+	remapHooks := func(m map[string]*HookInfo) map[string]*HookInfo {
+		if m == nil {
+			return nil
+		}
+		r := make(map[string]*HookInfo, len(m))
+		for k, v := range m {
+			if hook, ok := hooks[v]; ok {
+				v = hook
+			}
+			r[k] = v
+		}
+		return r
+	}
+
+	for _, plug := range plugs {
+		plug.Apps = remapApps(plug.Apps)
+		plug.Hooks = remapHooks(plug.Hooks)
+	}
+	for _, slot := range slots {
+		slot.Apps = remapApps(slot.Apps)
+		slot.Hooks = remapHooks(slot.Hooks)
+	}
+	for _, app := range apps {
+		app.Plugs = remapPlugs(app.Plugs)
+		app.Slots = remapSlots(app.Slots)
+		if app.ActivatesOn != nil {
+			activatesOn := make([]*SlotInfo, len(app.ActivatesOn))
+			for i, slot := range app.ActivatesOn {
+				if slotCopy, ok := slots[slot]; ok {
+					slot = slotCopy
+				}
+				activatesOn[i] = slot
+			}
+			app.ActivatesOn = activatesOn
+		}
+		if app.Sockets != nil {
+			sockets := make(map[string]*SocketInfo, len(app.Sockets))
+			for name, socket := range app.Sockets {
+				socketCopy := *socket
+				socketCopy.App = app
+				sockets[name] = &socketCopy
+			}
+			app.Sockets = sockets
+		}
+		if app.Timer != nil {
+			timerCopy := *app.Timer
+			timerCopy.App = app
+			app.Timer = &timerCopy
+		}
+	}
+	for _, hook := range hooks {
+		hook.Plugs = remapPlugs(hook.Plugs)
+		hook.Slots = remapSlots(hook.Slots)
+	}
+	c.LegacyAliases = remapApps(s.LegacyAliases)
+
+	if s.Layout != nil {
+		c.Layout = make(map[string]*Layout, len(s.Layout))
+		for path, layout := range s.Layout {
+			layoutCopy := *layout
+			layoutCopy.Snap = &c
+			c.Layout[path] = &layoutCopy
+		}
+	}
+	if s.Components != nil {
+		c.Components = make(map[string]*Component, len(s.Components))
+		for name, comp := range s.Components {
+			compCopy := *comp
+			compCopy.Snap = &c
+			c.Components[name] = &compCopy
+		}
+	}
+	if s.SystemUsernames != nil {
+		c.SystemUsernames = make(map[string]*SystemUsernameInfo, len(s.SystemUsernames))
+		for name, user := range s.SystemUsernames {
+			userCopy := *user
+			c.SystemUsernames[name] = &userCopy
+		}
+	}
+	if s.BadInterfaces != nil {
+		c.BadInterfaces = make(map[string]string, len(s.BadInterfaces))
+		for k, v := range s.BadInterfaces {
+			c.BadInterfaces[k] = v
+		}
+	}
+	return &c
+}
+
+func copyAttrs(attrs map[string]interface{}) map[string]interface{} {
+	if attrs == nil {
+		return nil
+	}
+	return copyAttrValue(attrs).(map[string]interface{})
+}
+
+func copyAttrValue(value interface{}) interface{} {
+	// attribute values are normalized, lists and maps are the only
+	// mutable types found in them
+	switch v := value.(type) {
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, el := range v {
+			l[i] = copyAttrValue(el)
+		}
+		return l
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, el := range v {
+			m[k] = copyAttrValue(el)
+		}
+		return m
+	}
+	return value
+}
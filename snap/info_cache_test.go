@@ -0,0 +1,194 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type infoCacheSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&infoCacheSuite{})
+
+func (s *infoCacheSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	s.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+}
+
+func (s *infoCacheSuite) TestReadInfoCached(c *C) {
+	si := &snap.SideInfo{RealName: "foo", Revision: snap.R(1)}
+	snaptest.MockSnap(c, "name: foo\nversion: 1.0\n", si)
+
+	cache := snap.NewInfoCache()
+	info1, err := cache.ReadInfo("foo", si)
+	c.Assert(err, IsNil)
+	c.Check(info1.Version, Equals, "1.0")
+
+	info2, err := cache.ReadInfo("foo", si)
+	c.Assert(err, IsNil)
+	c.Check(info2, Not(Equals), info1)
+	c.Check(info2, DeepEquals, info1)
+
+	// a different side info is read again
+	si2 := &snap.SideInfo{RealName: "foo", Revision: snap.R(1), Channel: "edge"}
+	info3, err := cache.ReadInfo("foo", si2)
+	c.Assert(err, IsNil)
+	c.Check(info3, Not(Equals), info1)
+	c.Check(info3.Channel, Equals, "edge")
+
+	// so is a different revision
+	si3 := &snap.SideInfo{RealName: "foo", Revision: snap.R(2)}
+	snaptest.MockSnap(c, "name: foo\nversion: 2.0\n", si3)
+	info4, err := cache.ReadInfo("foo", si3)
+	c.Assert(err, IsNil)
+	c.Check(info4.Version, Equals, "2.0")
+}
+
+func (s *infoCacheSuite) TestReadInfoCopies(c *C) {
+	si := &snap.SideInfo{RealName: "foo", Revision: snap.R(1)}
+	snaptest.MockSnap(c, `name: foo
+version: 1.0
+apps:
+  app:
+    command: foo
+    plugs: [network]
+    slots: [dbus-slot]
+    activates-on: [dbus-slot]
+  svc:
+    command: svc
+    daemon: simple
+    timer: 10:00-12:00
+    sockets:
+      sock:
+        listen-stream: $SNAP_DATA/sock
+hooks:
+  configure:
+    plugs: [network]
+slots:
+  dbus-slot:
+    interface: dbus
+    bus: session
+    name: org.foo
+`, si)
+
+	cache := snap.NewInfoCache()
+	info1, err := cache.ReadInfo("foo", si)
+	c.Assert(err, IsNil)
+	// readers may modify what they get
+	info1.Publisher.ID = "publisher-id"
+	info1.Slots["hotplug"] = &snap.SlotInfo{Snap: info1, Name: "hotplug"}
+	info1.Slots["dbus-slot"].Attrs["name"] = "org.bar"
+
+	info2, err := cache.ReadInfo("foo", si)
+	c.Assert(err, IsNil)
+	c.Check(info2.Publisher.ID, Equals, "")
+	c.Check(info2.Slots, HasLen, 1)
+	c.Check(info2.Slots["dbus-slot"].Attrs["name"], Equals, "org.foo")
+
+	// the references point into the copy
+	app := info2.Apps["app"]
+	c.Check(app.Snap, Equals, info2)
+	c.Check(app.Plugs["network"], Equals, info2.Plugs["network"])
+	c.Check(info2.Plugs["network"].Apps["app"], Equals, app)
+	c.Check(info2.Plugs["network"].Hooks["configure"], Equals, info2.Hooks["configure"])
+	c.Check(info2.Hooks["configure"].Snap, Equals, info2)
+	c.Check(app.Slots["dbus-slot"], Equals, info2.Slots["dbus-slot"])
+	c.Check(app.ActivatesOn, DeepEquals, []*snap.SlotInfo{info2.Slots["dbus-slot"]})
+	c.Check(app.ActivatesOn[0], Equals, info2.Slots["dbus-slot"])
+	svc := info2.Apps["svc"]
+	c.Check(svc.Sockets["sock"].App, Equals, svc)
+	c.Check(svc.Timer.App, Equals, svc)
+}
+
+func (s *infoCacheSuite) TestReadInfoCopiesNestedAttrs(c *C) {
+	si := &snap.SideInfo{RealName: "foo", Revision: snap.R(1)}
+	snaptest.MockSnap(c, `name: foo
+version: 1.0
+slots:
+  content-slot:
+    interface: content
+    write: [$SNAP_DATA/foo]
+    extra:
+      key: value
+`, si)
+
+	cache := snap.NewInfoCache()
+	info1, err := cache.ReadInfo("foo", si)
+	c.Assert(err, IsNil)
+	// readers may modify nested attributes as well
+	attrs := info1.Slots["content-slot"].Attrs
+	attrs["write"].([]interface{})[0] = "$SNAP_DATA/bar"
+	attrs["extra"].(map[string]interface{})["key"] = "other-value"
+
+	info2, err := cache.ReadInfo("foo", si)
+	c.Assert(err, IsNil)
+	c.Check(info2.Slots["content-slot"].Attrs, DeepEquals, map[string]interface{}{
+		"write": []interface{}{"$SNAP_DATA/foo"},
+		"extra": map[string]interface{}{"key": "value"},
+	})
+}
+
+func (s *infoCacheSuite) TestReadInfoChanged(c *C) {
+	si := &snap.SideInfo{RealName: "foo", Revision: snap.R(1)}
+	info := snaptest.MockSnap(c, "name: foo\nversion: 1.0\n", si)
+
+	cache := snap.NewInfoCache()
+	info1, err := cache.ReadInfo("foo", si)
+	c.Assert(err, IsNil)
+
+	yamlFn := filepath.Join(info.MountDir(), "meta", "snap.yaml")
+	c.Assert(ioutil.WriteFile(yamlFn, []byte("name: foo\nversion: 1.0-changed\n"), 0644), IsNil)
+	info2, err := cache.ReadInfo("foo", si)
+	c.Assert(err, IsNil)
+	c.Check(info2, Not(Equals), info1)
+	c.Check(info2.Version, Equals, "1.0-changed")
+
+	c.Assert(os.Remove(info.MountFile()), IsNil)
+	_, err = cache.ReadInfo("foo", si)
+	c.Check(err, FitsTypeOf, &snap.NotFoundError{})
+}
+
+func (s *infoCacheSuite) TestForget(c *C) {
+	si := &snap.SideInfo{RealName: "foo", Revision: snap.R(1)}
+	snaptest.MockSnap(c, "name: foo\nversion: 1.0\n", si)
+
+	cache := snap.NewInfoCache()
+	info1, err := cache.ReadInfo("foo", si)
+	c.Assert(err, IsNil)
+
+	cache.Forget("foo")
+	info2, err := cache.ReadInfo("foo", si)
+	c.Assert(err, IsNil)
+	c.Check(info2, Not(Equals), info1)
+	c.Check(info2, DeepEquals, info1)
+}
@@ -34,25 +34,26 @@ import (
 )
 
 type snapYaml struct {
-	Name            string                 `yaml:"name"`
-	Version         string                 `yaml:"version"`
-	Type            Type                   `yaml:"type"`
-	Architectures   []string               `yaml:"architectures,omitempty"`
-	Assumes         []string               `yaml:"assumes"`
-	Title           string                 `yaml:"title"`
-	Description     string                 `yaml:"description"`
-	Summary         string                 `yaml:"summary"`
-	License         string                 `yaml:"license,omitempty"`
-	Epoch           Epoch                  `yaml:"epoch,omitempty"`
-	Base            string                 `yaml:"base,omitempty"`
-	Confinement     ConfinementType        `yaml:"confinement,omitempty"`
-	Environment     strutil.OrderedMap     `yaml:"environment,omitempty"`
-	Plugs           map[string]interface{} `yaml:"plugs,omitempty"`
-	Slots           map[string]interface{} `yaml:"slots,omitempty"`
-	Apps            map[string]appYaml     `yaml:"apps,omitempty"`
-	Hooks           map[string]hookYaml    `yaml:"hooks,omitempty"`
-	Layout          map[string]layoutYaml  `yaml:"layout,omitempty"`
-	SystemUsernames map[string]interface{} `yaml:"system-usernames,omitempty"`
+	Name            string                   `yaml:"name"`
+	Version         string                   `yaml:"version"`
+	Type            Type                     `yaml:"type"`
+	Architectures   []string                 `yaml:"architectures,omitempty"`
+	Assumes         []string                 `yaml:"assumes"`
+	Title           string                   `yaml:"title"`
+	Description     string                   `yaml:"description"`
+	Summary         string                   `yaml:"summary"`
+	License         string                   `yaml:"license,omitempty"`
+	Epoch           Epoch                    `yaml:"epoch,omitempty"`
+	Base            string                   `yaml:"base,omitempty"`
+	Confinement     ConfinementType          `yaml:"confinement,omitempty"`
+	Environment     strutil.OrderedMap       `yaml:"environment,omitempty"`
+	Plugs           map[string]interface{}   `yaml:"plugs,omitempty"`
+	Slots           map[string]interface{}   `yaml:"slots,omitempty"`
+	Apps            map[string]appYaml       `yaml:"apps,omitempty"`
+	Hooks           map[string]hookYaml      `yaml:"hooks,omitempty"`
+	Layout          map[string]layoutYaml    `yaml:"layout,omitempty"`
+	SystemUsernames map[string]interface{}   `yaml:"system-usernames,omitempty"`
+	Components      map[string]componentYaml `yaml:"components,omitempty"`
 
 	// TypoLayouts is used to detect the use of the incorrect plural form of "layout"
 	TypoLayouts typoDetector `yaml:"layouts,omitempty"`
@@ -72,7 +73,8 @@ type appYaml struct {
 	Command      string   `yaml:"command"`
 	CommandChain []string `yaml:"command-chain,omitempty"`
 
-	Daemon string `yaml:"daemon"`
+	Daemon      string      `yaml:"daemon"`
+	DaemonScope DaemonScope `yaml:"daemon-scope,omitempty"`
 
 	StopCommand     string          `yaml:"stop-command,omitempty"`
 	ReloadCommand   string          `yaml:"reload-command,omitempty"`
@@ -89,8 +91,9 @@ type appYaml struct {
 	SlotNames    []string         `yaml:"slots,omitempty"`
 	PlugNames    []string         `yaml:"plugs,omitempty"`
 
-	BusName  string `yaml:"bus-name,omitempty"`
-	CommonID string `yaml:"common-id,omitempty"`
+	BusName     string   `yaml:"bus-name,omitempty"`
+	ActivatesOn []string `yaml:"activates-on,omitempty"`
+	CommonID    string   `yaml:"common-id,omitempty"`
 
 	Environment strutil.OrderedMap `yaml:"environment,omitempty"`
 
@@ -119,6 +122,14 @@ type layoutYaml struct {
 	Group    string `yaml:"group,omitempty"`
 	Mode     string `yaml:"mode,omitempty"`
 	Symlink  string `yaml:"symlink,omitempty"`
+	Size     string `yaml:"size,omitempty"`
+}
+
+type componentYaml struct {
+	Type        ComponentType `yaml:"type"`
+	Summary     string        `yaml:"summary,omitempty"`
+	Description string        `yaml:"description,omitempty"`
+	Required    bool          `yaml:"required,omitempty"`
 }
 
 type socketsYaml struct {
@@ -202,6 +213,17 @@ func infoFromSnapYaml(yamlData []byte, strk *scopedTracker) (*Info, error) {
 				}
 				mode = os.FileMode(m)
 			}
+			var size int64
+			if l.Size != "" {
+				sz, err := strutil.ParseByteSize(l.Size)
+				if err != nil {
+					return nil, fmt.Errorf("layout %q uses invalid size: %v", path, err)
+				}
+				if sz == 0 {
+					return nil, fmt.Errorf("layout %q uses invalid size: size cannot be zero", path)
+				}
+				size = sz
+			}
 			user := "root"
 			if l.User != "" {
 				user = l.User
@@ -213,7 +235,7 @@ func infoFromSnapYaml(yamlData []byte, strk *scopedTracker) (*Info, error) {
 			snap.Layout[path] = &Layout{
 				Snap: snap, Path: path,
 				Bind: l.Bind, Type: l.Type, Symlink: l.Symlink, BindFile: l.BindFile,
-				User: user, Group: group, Mode: mode,
+				User: user, Group: group, Mode: mode, Size: size,
 			}
 		}
 	}
@@ -229,6 +251,11 @@ func infoFromSnapYaml(yamlData []byte, strk *scopedTracker) (*Info, error) {
 		return nil, err
 	}
 
+	// Collect components
+	if err := setComponentsFromSnapYaml(y, snap); err != nil {
+		return nil, err
+	}
+
 	// FIXME: validation of the fields
 	return snap, nil
 }
@@ -349,6 +376,7 @@ func setAppsFromSnapYaml(y snapYaml, snap *Info, strk *scopedTracker) error {
 			CommandChain:    yApp.CommandChain,
 			StartTimeout:    yApp.StartTimeout,
 			Daemon:          yApp.Daemon,
+			DaemonScope:     yApp.DaemonScope,
 			StopTimeout:     yApp.StopTimeout,
 			StopCommand:     yApp.StopCommand,
 			ReloadCommand:   yApp.ReloadCommand,
@@ -417,6 +445,17 @@ func setAppsFromSnapYaml(y snapYaml, snap *Info, strk *scopedTracker) error {
 			app.Slots[slotName] = slot
 			slot.Apps[appName] = app
 		}
+		for _, slotName := range yApp.ActivatesOn {
+			slot, ok := snap.Slots[slotName]
+			if !ok {
+				return fmt.Errorf("invalid activates-on value %q on application %q: slot not found", slotName, appName)
+			}
+			app.ActivatesOn = append(app.ActivatesOn, slot)
+			// the app is implicitly bound to the slots it is activated on
+			strk.markSlot(slot)
+			app.Slots[slotName] = slot
+			slot.Apps[appName] = app
+		}
 		for name, data := range yApp.Sockets {
 			app.Sockets[name] = &SocketInfo{
 				App:          app,
@@ -527,6 +566,27 @@ func setSystemUsernamesFromSnapYaml(y snapYaml, snap *Info) error {
 	return nil
 }
 
+func setComponentsFromSnapYaml(y snapYaml, snap *Info) error {
+	if len(y.Components) == 0 {
+		return nil
+	}
+	snap.Components = make(map[string]*Component, len(y.Components))
+	for name, data := range y.Components {
+		if data.Type == "" {
+			return fmt.Errorf("component %q does not specify a type", name)
+		}
+		snap.Components[name] = &Component{
+			Snap:        snap,
+			Name:        name,
+			Type:        data.Type,
+			Summary:     data.Summary,
+			Description: data.Description,
+			Required:    data.Required,
+		}
+	}
+	return nil
+}
+
 func bindUnscopedPlugs(snap *Info, strk *scopedTracker) {
 	for plugName, plug := range snap.Plugs {
 		if strk.plug(plug) {
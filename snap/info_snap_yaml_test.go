@@ -20,6 +20,7 @@
 package snap_test
 
 import (
+	"fmt"
 	"regexp"
 	"testing"
 	"time"
@@ -1726,6 +1727,52 @@ layout:
 	})
 }
 
+func (s *YamlSuite) TestLayoutTmpfsSize(c *C) {
+	y := []byte(`
+name: foo
+version: 1.0
+layout:
+  /var/cache/foo:
+    type: tmpfs
+    size: 64MB
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Assert(info.Layout["/var/cache/foo"], DeepEquals, &snap.Layout{
+		Snap:  info,
+		Path:  "/var/cache/foo",
+		Type:  "tmpfs",
+		User:  "root",
+		Group: "root",
+		Mode:  0755,
+		Size:  64 * 1000 * 1000,
+	})
+	c.Check(info.Layout["/var/cache/foo"].String(), Equals, "/var/cache/foo: type tmpfs, size: 64000000")
+}
+
+func (s *YamlSuite) TestLayoutTmpfsSizeInvalid(c *C) {
+	for _, t := range []struct {
+		size, err string
+	}{
+		{"64", `layout "/var/cache/foo" uses invalid size: cannot parse "64": need a number with a unit as input`},
+		{"64XB", `layout "/var/cache/foo" uses invalid size: cannot parse "64XB": try 'kB' or 'MB'`},
+		{"-1MB", `layout "/var/cache/foo" uses invalid size: cannot parse "-1MB": size cannot be negative`},
+		{"0MB", `layout "/var/cache/foo" uses invalid size: size cannot be zero`},
+	} {
+		y := []byte(fmt.Sprintf(`
+name: foo
+version: 1.0
+layout:
+  /var/cache/foo:
+    type: tmpfs
+    size: %s
+`, t.size))
+		info, err := snap.InfoFromSnapYaml(y)
+		c.Check(err, ErrorMatches, t.err, Commentf("size: %s", t.size))
+		c.Check(info, IsNil)
+	}
+}
+
 func (s *YamlSuite) TestLayoutsWithTypo(c *C) {
 	y := []byte(`
 name: foo
@@ -1842,6 +1889,49 @@ apps:
 	c.Check(app.RestartDelay, Equals, timeout.Timeout(12*time.Second))
 }
 
+func (s *YamlSuite) TestSnapYamlComponentsParsing(c *C) {
+	y := []byte(`name: binary
+version: 1.0
+components:
+  debug:
+    type: debug-symbols
+    summary: debug symbols
+  data:
+    type: assets
+    description: |
+      Large data files.
+    required: true
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Assert(info.Components, HasLen, 2)
+	c.Check(info.Components["debug"], DeepEquals, &snap.Component{
+		Snap:    info,
+		Name:    "debug",
+		Type:    snap.DebugSymbolsComponent,
+		Summary: "debug symbols",
+	})
+	c.Check(info.Components["data"], DeepEquals, &snap.Component{
+		Snap:        info,
+		Name:        "data",
+		Type:        snap.AssetsComponent,
+		Description: "Large data files.\n",
+		Required:    true,
+	})
+	c.Check(info.Components["debug"].FullName(), Equals, "binary+debug")
+}
+
+func (s *YamlSuite) TestSnapYamlComponentsParsingNoType(c *C) {
+	y := []byte(`name: binary
+version: 1.0
+components:
+  debug:
+    summary: debug symbols
+`)
+	_, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, ErrorMatches, `component "debug" does not specify a type`)
+}
+
 func (s *YamlSuite) TestSnapYamlSystemUsernamesParsing(c *C) {
 	y := []byte(`name: binary
 version: 1.0
@@ -1985,3 +2075,41 @@ system-usernames:
 	_, err := snap.InfoFromSnapYaml(y)
 	c.Assert(err, ErrorMatches, `scope on system username "foo" is not a string \(found int\)`)
 }
+
+func (s *YamlSuite) TestSnapYamlActivatesOn(c *C) {
+	y := []byte(`name: foo
+version: 1.0
+slots:
+  dbus-slot:
+    interface: dbus
+    bus: system
+    name: org.example.Foo
+apps:
+  daemon:
+    daemon: simple
+    activates-on: [dbus-slot]
+  cmd:
+    command: bin/foo
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	app := info.Apps["daemon"]
+	slot := info.Slots["dbus-slot"]
+	c.Check(app.ActivatesOn, DeepEquals, []*snap.SlotInfo{slot})
+	// the slot is implicitly bound to the app it activates and
+	// nothing else
+	c.Check(app.Slots, DeepEquals, map[string]*snap.SlotInfo{"dbus-slot": slot})
+	c.Check(slot.Apps, DeepEquals, map[string]*snap.AppInfo{"daemon": app})
+}
+
+func (s *YamlSuite) TestSnapYamlActivatesOnMissingSlot(c *C) {
+	y := []byte(`name: foo
+version: 1.0
+apps:
+  daemon:
+    daemon: simple
+    activates-on: [dbus-slot]
+`)
+	_, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, ErrorMatches, `invalid activates-on value "dbus-slot" on application "daemon": slot not found`)
+}
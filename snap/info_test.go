@@ -371,7 +371,7 @@ func makeTestSnap(c *C, snapYaml string) string {
 
 	dest := filepath.Join(tmp, "foo.snap")
 	snap := squashfs.New(dest)
-	err = snap.Build(snapSource, m.Type)
+	err = snap.Build(snapSource, &squashfs.BuildOpts{SnapType: m.Type})
 	c.Assert(err, IsNil)
 
 	return dest
@@ -429,6 +429,29 @@ confinement: classic`
 	c.Check(info.NeedsClassic(), Equals, true)
 }
 
+func (s *infoSuite) TestCheckClassicConfinement(c *C) {
+	strict := &snap.Info{SideInfo: snap.SideInfo{RealName: "foo"}, Confinement: snap.StrictConfinement}
+	classic := &snap.Info{SideInfo: snap.SideInfo{RealName: "foo"}, InstanceKey: "bar", Confinement: snap.ClassicConfinement}
+
+	for _, onClassic := range []bool{true, false} {
+		for _, sanctioned := range []bool{true, false} {
+			c.Check(snap.CheckClassicConfinement(strict, onClassic, sanctioned), IsNil)
+		}
+	}
+
+	c.Check(snap.CheckClassicConfinement(classic, true, true), IsNil)
+
+	err := snap.CheckClassicConfinement(classic, true, false)
+	c.Check(err, ErrorMatches, `snap "foo_bar" requires classic confinement`)
+	c.Check(err, DeepEquals, &snap.ClassicConfinementError{Snap: "foo_bar"})
+
+	for _, sanctioned := range []bool{true, false} {
+		err = snap.CheckClassicConfinement(classic, false, sanctioned)
+		c.Check(err, ErrorMatches, `snap "foo_bar" requires classic confinement which is only available on classic systems`)
+		c.Check(err, DeepEquals, &snap.ClassicConfinementError{Snap: "foo_bar", NeedsClassicSystem: true})
+	}
+}
+
 func (s *infoSuite) TestReadInfoFromSnapFileMissingEpoch(c *C) {
 	yaml := `name: foo
 version: 1.0
@@ -469,6 +492,63 @@ type: app`
 	c.Check(info.Revision, Equals, snap.R(42))
 }
 
+func (s *infoSuite) TestReadInfoAndFilesFromSnapFile(c *C) {
+	yaml := `name: foo
+version: 1.0
+type: gadget`
+	snapPath := snaptest.MakeTestSnapWithFiles(c, yaml, [][]string{
+		{"meta/gadget.yaml", "volumes: {}"},
+		{"meta/hooks/install", ""},
+	})
+
+	snapf, err := snap.Open(snapPath)
+	c.Assert(err, IsNil)
+
+	info, files, err := snap.ReadInfoAndFilesFromSnapFile(snapf, nil, []string{"meta/gadget.yaml", "meta/missing"})
+	c.Assert(err, IsNil)
+	c.Check(info.InstanceName(), Equals, "foo")
+	c.Check(info.GetType(), Equals, snap.TypeGadget)
+	c.Check(info.Hooks, HasLen, 1)
+	c.Check(files, DeepEquals, map[string][]byte{
+		"meta/gadget.yaml": []byte("volumes: {}"),
+	})
+}
+
+func (s *infoSuite) TestReadInfoAndFilesFromSnapDir(c *C) {
+	yaml := `name: foo
+version: 1.0
+type: gadget`
+	snapDir := c.MkDir()
+	snaptest.PopulateDir(snapDir, [][]string{
+		{"meta/snap.yaml", yaml},
+		{"meta/gadget.yaml", "volumes: {}"},
+	})
+
+	snapf, err := snap.Open(snapDir)
+	c.Assert(err, IsNil)
+
+	info, files, err := snap.ReadInfoAndFilesFromSnapFile(snapf, &snap.SideInfo{Revision: snap.R(42)}, []string{"meta/gadget.yaml", "meta/missing"})
+	c.Assert(err, IsNil)
+	c.Check(info.InstanceName(), Equals, "foo")
+	c.Check(info.Revision, Equals, snap.R(42))
+	c.Check(files, DeepEquals, map[string][]byte{
+		"meta/gadget.yaml": []byte("volumes: {}"),
+	})
+}
+
+func (s *infoSuite) TestReadInfoAndFilesFromSnapFileValidates(c *C) {
+	snapDir := c.MkDir()
+	snaptest.PopulateDir(snapDir, [][]string{
+		{"meta/snap.yaml", "name: foo.bar\nversion: 1.0"},
+	})
+
+	snapf, err := snap.Open(snapDir)
+	c.Assert(err, IsNil)
+
+	_, _, err = snap.ReadInfoAndFilesFromSnapFile(snapf, nil, nil)
+	c.Assert(err, ErrorMatches, `invalid snap name.*`)
+}
+
 func (s *infoSuite) TestReadInfoFromSnapFileValidates(c *C) {
 	yaml := `name: foo.bar
 version: 1.0
@@ -1182,6 +1262,36 @@ apps:
 	c.Check(svc.ServiceFile(), Equals, dirs.GlobalRootDir+"/etc/systemd/system/snap.pans_instance.svc1.service")
 }
 
+func (s *infoSuite) TestAppInfoIsUserService(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(`name: pans
+apps:
+  svc1:
+    daemon: simple
+    daemon-scope: user
+    timer: mon,10:00-12:00
+  svc2:
+    daemon: simple
+    daemon-scope: system
+  svc3:
+    daemon: simple
+  app1:
+`))
+	c.Assert(err, IsNil)
+
+	svc := info.Apps["svc1"]
+	c.Check(svc.DaemonScope, Equals, snap.UserDaemon)
+	c.Check(svc.IsService(), Equals, true)
+	c.Check(svc.IsUserService(), Equals, true)
+	c.Check(svc.ServiceFile(), Equals, dirs.GlobalRootDir+"/etc/systemd/user/snap.pans.svc1.service")
+	c.Check(svc.Timer.File(), Equals, dirs.GlobalRootDir+"/etc/systemd/user/snap.pans.svc1.timer")
+
+	c.Check(info.Apps["svc2"].DaemonScope, Equals, snap.SystemDaemon)
+	c.Check(info.Apps["svc2"].IsUserService(), Equals, false)
+	c.Check(info.Apps["svc2"].ServiceFile(), Equals, dirs.GlobalRootDir+"/etc/systemd/system/snap.pans.svc2.service")
+	c.Check(info.Apps["svc3"].IsUserService(), Equals, false)
+	c.Check(info.Apps["app1"].IsUserService(), Equals, false)
+}
+
 func (s *infoSuite) TestAppInfoStringer(c *C) {
 	info, err := snap.InfoFromSnapYaml([]byte(`name: asnap
 apps:
@@ -1213,6 +1323,25 @@ apps:
 	c.Check(socket.File(), Equals, dirs.GlobalRootDir+"/etc/systemd/system/snap.pans_instance.app1.sock1.socket")
 }
 
+func (s *infoSuite) TestTimerUsesCalendarSyntax(c *C) {
+	for _, t := range []struct {
+		timer    string
+		calendar bool
+	}{
+		{"mon,10:00-12:00", false},
+		{"mon1-wed,,fri,9:00~11:00/2", false},
+		{"10:00", false},
+		{"*-*-* 10:00", true},
+		{"Mon..Fri", true},
+		{"Mon 10:00;Fri 12:00", true},
+		{"*:0/15", true},
+		{"Daily", true},
+	} {
+		timer := &snap.TimerInfo{Timer: t.timer}
+		c.Check(timer.UsesCalendarSyntax(), Equals, t.calendar, Commentf("%q", t.timer))
+	}
+}
+
 func (s *infoSuite) TestTimerFile(c *C) {
 	info, err := snap.InfoFromSnapYaml([]byte(`name: pans
 apps:
@@ -1669,6 +1798,13 @@ func (s *infoSuite) TestSortApps(c *C) {
 			{Name: "bar", After: []string{"foo"}},
 		},
 		sorted: []string{"foo", "bar", "baz"},
+	}, {
+		// dependencies on apps that are not being sorted are ignored
+		apps: []*snap.AppInfo{
+			{Name: "baz", After: []string{"bar", "foo"}},
+			{Name: "bar", Before: []string{"zed"}, After: []string{"foo"}},
+		},
+		sorted: []string{"bar", "baz"},
 	}}
 	for _, tc := range tcs {
 		sorted, err := snap.SortServices(tc.apps)
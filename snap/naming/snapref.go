@@ -19,6 +19,11 @@
 
 package naming
 
+import (
+	"encoding/json"
+	"sort"
+)
+
 // A SnapRef references a snap by name and/or id.
 type SnapRef interface {
 	SnapName() string
@@ -125,3 +130,99 @@ func (s *SnapSet) Add(ref SnapRef) {
 		s.byName[name] = ref
 	}
 }
+
+// Size returns the number of references in the set.
+func (s *SnapSet) Size() int {
+	return len(s.Refs())
+}
+
+// Refs returns the references in the set, sorted by name and then id.
+func (s *SnapSet) Refs() []SnapRef {
+	refs := make([]SnapRef, 0, len(s.byName)+len(s.byID))
+	for _, ref := range s.byName {
+		refs = append(refs, ref)
+	}
+	for id, ref := range s.byID {
+		if byName := s.byName[ref.SnapName()]; byName != nil && byName.ID() == id {
+			// already included
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	sort.Sort(byNameAndID(refs))
+	return refs
+}
+
+type byNameAndID []SnapRef
+
+func (refs byNameAndID) Len() int      { return len(refs) }
+func (refs byNameAndID) Swap(i, j int) { refs[i], refs[j] = refs[j], refs[i] }
+func (refs byNameAndID) Less(i, j int) bool {
+	if refs[i].SnapName() != refs[j].SnapName() {
+		return refs[i].SnapName() < refs[j].SnapName()
+	}
+	return refs[i].ID() < refs[j].ID()
+}
+
+// Union returns a new set with the references in s and in other. When
+// both have a matching reference the one from s is kept.
+func (s *SnapSet) Union(other *SnapSet) *SnapSet {
+	union := NewSnapSet(s.Refs())
+	for _, ref := range other.Refs() {
+		union.Add(ref)
+	}
+	return union
+}
+
+// Intersection returns a new set with the references in s that have a
+// matching one in other.
+func (s *SnapSet) Intersection(other *SnapSet) *SnapSet {
+	intersection := NewSnapSet(nil)
+	for _, ref := range s.Refs() {
+		if other.Contains(ref) {
+			intersection.Add(ref)
+		}
+	}
+	return intersection
+}
+
+// Difference returns a new set with the references in s that have no
+// matching one in other.
+func (s *SnapSet) Difference(other *SnapSet) *SnapSet {
+	difference := NewSnapSet(nil)
+	for _, ref := range s.Refs() {
+		if !other.Contains(ref) {
+			difference.Add(ref)
+		}
+	}
+	return difference
+}
+
+type jsonSnapRef struct {
+	Name string `json:"name,omitempty"`
+	ID   string `json:"id,omitempty"`
+}
+
+// MarshalJSON serializes the set as a list of names and ids.
+func (s *SnapSet) MarshalJSON() ([]byte, error) {
+	refs := s.Refs()
+	jrefs := make([]jsonSnapRef, len(refs))
+	for i, ref := range refs {
+		jrefs[i] = jsonSnapRef{Name: ref.SnapName(), ID: ref.ID()}
+	}
+	return json.Marshal(jrefs)
+}
+
+// UnmarshalJSON deserializes a set serialized by MarshalJSON.
+func (s *SnapSet) UnmarshalJSON(data []byte) error {
+	var jrefs []jsonSnapRef
+	if err := json.Unmarshal(data, &jrefs); err != nil {
+		return err
+	}
+	refs := make([]SnapRef, len(jrefs))
+	for i, jref := range jrefs {
+		refs[i] = NewSnapRef(jref.Name, jref.ID)
+	}
+	*s = *NewSnapSet(refs)
+	return nil
+}
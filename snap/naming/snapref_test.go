@@ -20,6 +20,8 @@
 package naming_test
 
 import (
+	"encoding/json"
+
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/snap/naming"
@@ -91,3 +93,66 @@ func (s *snapRefSuite) TestSnapSet(c *C) {
 	c.Check(ss1.Lookup(barNameOnylRef), Equals, nil)
 	c.Check(ss1.Lookup(unrelFooRef), Equals, fooNameOnlyRef)
 }
+
+func (s *snapRefSuite) TestSnapSetRefs(c *C) {
+	fooRef := naming.NewSnapRef("foo", "foo-id")
+	barNameOnlyRef := naming.Snap("bar")
+	bazIDOnlyRef := naming.NewSnapRef("", "baz-id")
+	unrelFooRef := naming.NewSnapRef("foo", "unrel-id")
+
+	ss := naming.NewSnapSet([]naming.SnapRef{fooRef, barNameOnlyRef, bazIDOnlyRef, unrelFooRef})
+	c.Check(ss.Size(), Equals, 4)
+	c.Check(ss.Refs(), DeepEquals, []naming.SnapRef{bazIDOnlyRef, barNameOnlyRef, fooRef, unrelFooRef})
+
+	c.Check(naming.NewSnapSet(nil).Refs(), HasLen, 0)
+}
+
+func (s *snapRefSuite) TestSnapSetOperations(c *C) {
+	fooRef := naming.NewSnapRef("foo", "foo-id")
+	barRef := naming.NewSnapRef("bar", "bar-id")
+	bazNameOnlyRef := naming.Snap("baz")
+	altFooRef := naming.NewSnapRef("foo-proj", "foo-id")
+	quuxRef := naming.NewSnapRef("quux", "quux-id")
+
+	ss1 := naming.NewSnapSet([]naming.SnapRef{fooRef, barRef, bazNameOnlyRef})
+	ss2 := naming.NewSnapSet([]naming.SnapRef{altFooRef, naming.NewSnapRef("baz", "baz-id"), quuxRef})
+
+	union := ss1.Union(ss2)
+	c.Check(union.Refs(), DeepEquals, []naming.SnapRef{barRef, bazNameOnlyRef, fooRef, quuxRef})
+
+	intersection := ss1.Intersection(ss2)
+	c.Check(intersection.Refs(), DeepEquals, []naming.SnapRef{bazNameOnlyRef, fooRef})
+	c.Check(ss2.Intersection(ss1).Refs(), DeepEquals, []naming.SnapRef{naming.NewSnapRef("baz", "baz-id"), altFooRef})
+
+	difference := ss1.Difference(ss2)
+	c.Check(difference.Refs(), DeepEquals, []naming.SnapRef{barRef})
+	c.Check(ss2.Difference(ss1).Refs(), DeepEquals, []naming.SnapRef{quuxRef})
+
+	// the operands are not modified
+	c.Check(ss1.Size(), Equals, 3)
+	c.Check(ss2.Size(), Equals, 3)
+}
+
+func (s *snapRefSuite) TestSnapSetJSON(c *C) {
+	ss := naming.NewSnapSet([]naming.SnapRef{
+		naming.NewSnapRef("foo", "foo-id"),
+		naming.Snap("bar"),
+		naming.NewSnapRef("", "baz-id"),
+	})
+
+	data, err := json.Marshal(ss)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `[{"id":"baz-id"},{"name":"bar"},{"name":"foo","id":"foo-id"}]`)
+
+	var ss1 naming.SnapSet
+	c.Assert(json.Unmarshal(data, &ss1), IsNil)
+	c.Check(ss1.Refs(), DeepEquals, []naming.SnapRef{
+		naming.NewSnapRef("", "baz-id"),
+		naming.NewSnapRef("bar", ""),
+		naming.NewSnapRef("foo", "foo-id"),
+	})
+	c.Check(ss1.Contains(naming.Snap("foo")), Equals, true)
+
+	var ss2 naming.SnapSet
+	c.Check(json.Unmarshal([]byte(`{}`), &ss2), ErrorMatches, `json: cannot unmarshal object into Go value of type \[\]naming.jsonSnapRef`)
+}
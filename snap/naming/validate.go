@@ -85,6 +85,14 @@ func ValidateSnap(name string) error {
 	return nil
 }
 
+// ValidateComponent checks if a string can be used as a snap component name.
+func ValidateComponent(name string) error {
+	if len(name) < 2 || len(name) > 40 || !isValidName(name) {
+		return fmt.Errorf("invalid snap component name: %q", name)
+	}
+	return nil
+}
+
 // Regular expression describing correct plug, slot and interface names.
 var validPlugSlotIface = regexp.MustCompile("^[a-z](?:-?[a-z0-9])*$")
 
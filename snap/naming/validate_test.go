@@ -20,6 +20,8 @@
 package naming_test
 
 import (
+	"strings"
+
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/snap/naming"
@@ -130,6 +132,15 @@ func (s *ValidateSuite) TestValidateInstanceName(c *C) {
 
 }
 
+func (s *ValidateSuite) TestValidateComponentName(c *C) {
+	for _, name := range []string{"aa", "debug-symbols", "assets-1", "1a"} {
+		c.Check(naming.ValidateComponent(name), IsNil, Commentf(name))
+	}
+	for _, name := range []string{"", "a", "-aa", "aa-", "a--a", "a_b", "a+b", "123", "A", strings.Repeat("a", 41)} {
+		c.Check(naming.ValidateComponent(name), ErrorMatches, `invalid snap component name: ".*"`, Commentf(name))
+	}
+}
+
 func (s *ValidateSuite) TestValidateHookName(c *C) {
 	validHooks := []string{
 		"a",
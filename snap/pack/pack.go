@@ -174,10 +174,25 @@ func excludesFile() (filename string, err error) {
 	return filename, err
 }
 
+// Options for packing a snap.
+type Options struct {
+	// TargetDir is the directory where the snap file will be placed, or
+	// empty to use the current directory
+	TargetDir string
+	// SnapName is the name of the snap file, or empty to use the default
+	// name which is <snapname>_<version>_<architecture>.snap
+	SnapName string
+	// Compression method to use, xz by default
+	Compression string
+}
+
 // Snap the given sourceDirectory and return the generated
 // snap file
-func Snap(sourceDir, targetDir, snapName string) (string, error) {
-	info, err := prepare(sourceDir, targetDir)
+func Snap(sourceDir string, opts *Options) (string, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	info, err := prepare(sourceDir, opts.TargetDir)
 	if err != nil {
 		return "", err
 	}
@@ -188,9 +203,14 @@ func Snap(sourceDir, targetDir, snapName string) (string, error) {
 	}
 	defer os.Remove(excludes)
 
-	snapName = snapPath(info, targetDir, snapName)
+	snapName := snapPath(info, opts.TargetDir, opts.SnapName)
 	d := squashfs.New(snapName)
-	if err = d.Build(sourceDir, string(info.GetType()), excludes); err != nil {
+	buildOpts := &squashfs.BuildOpts{
+		SnapType:     string(info.GetType()),
+		Compression:  opts.Compression,
+		ExcludeFiles: []string{excludes},
+	}
+	if err = d.Build(sourceDir, buildOpts); err != nil {
 		return "", err
 	}
 
@@ -112,7 +112,7 @@ printf "hello world"
 func (s *packSuite) TestPackNoManifestFails(c *C) {
 	sourceDir := makeExampleSnapSourceDir(c, "{name: hello, version: 0}")
 	c.Assert(os.Remove(filepath.Join(sourceDir, "meta", "snap.yaml")), IsNil)
-	_, err := pack.Snap(sourceDir, "", "")
+	_, err := pack.Snap(sourceDir, nil)
 	c.Assert(err, ErrorMatches, `.*/meta/snap\.yaml: no such file or directory`)
 }
 
@@ -124,8 +124,8 @@ apps:
   command: bin/hello-world
 `)
 	c.Assert(os.Remove(filepath.Join(sourceDir, "bin", "hello-world")), IsNil)
-	_, err := pack.Snap(sourceDir, "", "")
-	c.Assert(err, Equals, snap.ErrMissingPaths)
+	_, err := pack.Snap(sourceDir, nil)
+	c.Assert(err, ErrorMatches, snap.ErrMissingPaths.Error())
 }
 
 func (s *packSuite) TestValidateMissingAppFailsWithErrMissingPaths(c *C) {
@@ -137,7 +137,7 @@ apps:
 `)
 	c.Assert(os.Remove(filepath.Join(sourceDir, "bin", "hello-world")), IsNil)
 	err := pack.CheckSkeleton(sourceDir)
-	c.Assert(err, Equals, snap.ErrMissingPaths)
+	c.Assert(err, ErrorMatches, snap.ErrMissingPaths.Error())
 }
 
 func (s *packSuite) TestPackExcludesBackups(c *C) {
@@ -145,7 +145,7 @@ func (s *packSuite) TestPackExcludesBackups(c *C) {
 	target := c.MkDir()
 	// add a backup file
 	c.Assert(ioutil.WriteFile(filepath.Join(sourceDir, "foo~"), []byte("hi"), 0755), IsNil)
-	snapfile, err := pack.Snap(sourceDir, c.MkDir(), "")
+	snapfile, err := pack.Snap(sourceDir, &pack.Options{TargetDir: c.MkDir()})
 	c.Assert(err, IsNil)
 	c.Assert(squashfs.New(snapfile).Unpack("*", target), IsNil)
 
@@ -163,7 +163,7 @@ func (s *packSuite) TestPackExcludesTopLevelDEBIAN(c *C) {
 	c.Assert(os.MkdirAll(filepath.Join(sourceDir, "DEBIAN", "foo"), 0755), IsNil)
 	// and a non-toplevel DEBIAN
 	c.Assert(os.MkdirAll(filepath.Join(sourceDir, "bar", "DEBIAN", "baz"), 0755), IsNil)
-	snapfile, err := pack.Snap(sourceDir, c.MkDir(), "")
+	snapfile, err := pack.Snap(sourceDir, &pack.Options{TargetDir: c.MkDir()})
 	c.Assert(err, IsNil)
 	c.Assert(squashfs.New(snapfile).Unpack("*", target), IsNil)
 	cmd := exec.Command("diff", "-qr", sourceDir, target)
@@ -181,7 +181,7 @@ func (s *packSuite) TestPackExcludesWholeDirs(c *C) {
 	// add a file inside a skipped dir
 	c.Assert(os.Mkdir(filepath.Join(sourceDir, ".bzr"), 0755), IsNil)
 	c.Assert(ioutil.WriteFile(filepath.Join(sourceDir, ".bzr", "foo"), []byte("hi"), 0755), IsNil)
-	snapfile, err := pack.Snap(sourceDir, c.MkDir(), "")
+	snapfile, err := pack.Snap(sourceDir, &pack.Options{TargetDir: c.MkDir()})
 	c.Assert(err, IsNil)
 	c.Assert(squashfs.New(snapfile).Unpack("*", target), IsNil)
 	out, _ := exec.Command("find", sourceDir).Output()
@@ -232,7 +232,7 @@ integration:
 
 	for i, t := range table {
 		comm := Commentf("%d", i)
-		resultSnap, err := pack.Snap(sourceDir, t.outputDir, t.filename)
+		resultSnap, err := pack.Snap(sourceDir, &pack.Options{TargetDir: t.outputDir, SnapName: t.filename})
 		c.Assert(err, IsNil, comm)
 
 		// check that there is result
@@ -287,19 +287,19 @@ volumes:
 	absSnapFile := filepath.Join(c.MkDir(), "foo.snap")
 
 	// gadget validation fails during layout
-	_, err = pack.Snap(sourceDir, outputDir, absSnapFile)
+	_, err = pack.Snap(sourceDir, &pack.Options{TargetDir: outputDir, SnapName: absSnapFile})
 	c.Assert(err, ErrorMatches, `invalid layout of volume "bad": cannot lay out structure #1 \("bare-struct"\): content "bare.img": stat .*/bare.img: no such file or directory`)
 
 	err = ioutil.WriteFile(filepath.Join(sourceDir, "bare.img"), []byte("foo"), 0644)
 	c.Assert(err, IsNil)
 
 	// gadget validation fails during content presence checks
-	_, err = pack.Snap(sourceDir, outputDir, absSnapFile)
+	_, err = pack.Snap(sourceDir, &pack.Options{TargetDir: outputDir, SnapName: absSnapFile})
 	c.Assert(err, ErrorMatches, `invalid volume "bad": structure #0 \("fs-struct"\), content source:foo/: source path does not exist`)
 
 	err = os.Mkdir(filepath.Join(sourceDir, "foo"), 0644)
 	c.Assert(err, IsNil)
 	// all good now
-	_, err = pack.Snap(sourceDir, outputDir, absSnapFile)
+	_, err = pack.Snap(sourceDir, &pack.Options{TargetDir: outputDir, SnapName: absSnapFile})
 	c.Assert(err, IsNil)
 }
@@ -0,0 +1,179 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snaptest
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/snap"
+)
+
+// SnapYaml builds the snap.yaml of a test snap piece by piece, e.g.:
+//
+//	yaml := snaptest.NewSnapYaml("foo").
+//		App("daemon", map[string]interface{}{"command": "bin/daemon", "daemon": "simple"}).
+//		Plug("network", "network", nil).
+//		String()
+type SnapYaml struct {
+	top map[string]interface{}
+}
+
+// NewSnapYaml returns a builder for the snap.yaml of the snap with the
+// given name and version 1.0.
+func NewSnapYaml(name string) *SnapYaml {
+	return &SnapYaml{top: map[string]interface{}{
+		"name":    name,
+		"version": "1.0",
+	}}
+}
+
+// Set sets the given top-level key to value.
+func (y *SnapYaml) Set(key string, value interface{}) *SnapYaml {
+	y.top[key] = value
+	return y
+}
+
+// Version sets the version of the snap.
+func (y *SnapYaml) Version(version string) *SnapYaml {
+	return y.Set("version", version)
+}
+
+// Type sets the type of the snap.
+func (y *SnapYaml) Type(typ snap.Type) *SnapYaml {
+	return y.Set("type", string(typ))
+}
+
+// Base sets the base of the snap.
+func (y *SnapYaml) Base(base string) *SnapYaml {
+	return y.Set("base", base)
+}
+
+func (y *SnapYaml) setEntry(section, name string, attrs map[string]interface{}) *SnapYaml {
+	entries, _ := y.top[section].(map[string]interface{})
+	if entries == nil {
+		entries = make(map[string]interface{})
+		y.top[section] = entries
+	}
+	entry := make(map[string]interface{}, len(attrs))
+	for k, v := range attrs {
+		entry[k] = v
+	}
+	entries[name] = entry
+	return y
+}
+
+// App adds an application with the given attributes, a command named
+// after the application is used if none is given.
+func (y *SnapYaml) App(name string, attrs map[string]interface{}) *SnapYaml {
+	y.setEntry("apps", name, attrs)
+	app := y.top["apps"].(map[string]interface{})[name].(map[string]interface{})
+	if _, ok := app["command"]; !ok {
+		app["command"] = "bin/" + name
+	}
+	return y
+}
+
+// Hook adds a hook with the given attributes.
+func (y *SnapYaml) Hook(name string, attrs map[string]interface{}) *SnapYaml {
+	return y.setEntry("hooks", name, attrs)
+}
+
+// Plug adds a plug of the given interface with the given attributes.
+func (y *SnapYaml) Plug(name, iface string, attrs map[string]interface{}) *SnapYaml {
+	y.setEntry("plugs", name, attrs)
+	y.top["plugs"].(map[string]interface{})[name].(map[string]interface{})["interface"] = iface
+	return y
+}
+
+// Slot adds a slot of the given interface with the given attributes.
+func (y *SnapYaml) Slot(name, iface string, attrs map[string]interface{}) *SnapYaml {
+	y.setEntry("slots", name, attrs)
+	y.top["slots"].(map[string]interface{})[name].(map[string]interface{})["interface"] = iface
+	return y
+}
+
+// Layout adds a layout for the given path, e.g. with a "bind" or a
+// "symlink" attribute.
+func (y *SnapYaml) Layout(path string, attrs map[string]interface{}) *SnapYaml {
+	return y.setEntry("layout", path, attrs)
+}
+
+// String returns the snap.yaml.
+func (y *SnapYaml) String() string {
+	out, err := yaml.Marshal(y.top)
+	if err != nil {
+		panic(fmt.Sprintf("cannot marshal snap.yaml: %v", err))
+	}
+	return string(out)
+}
+
+// MockSnap puts the snap.yaml on disk and returns the snap.Info, see
+// MockSnap.
+func (y *SnapYaml) MockSnap(c *check.C, sideInfo *snap.SideInfo) *snap.Info {
+	return MockSnap(c, y.String(), sideInfo)
+}
+
+// MockInfo parses the snap.yaml and returns the snap.Info, see
+// MockInfo.
+func (y *SnapYaml) MockInfo(c *check.C, sideInfo *snap.SideInfo) *snap.Info {
+	return MockInfo(c, y.String(), sideInfo)
+}
+
+// SnapDeclarationHeaders returns the headers for signing a
+// snap-declaration assertion fixture, extra headers are merged in.
+func SnapDeclarationHeaders(snapName, snapID, publisherID string, extra map[string]interface{}) map[string]interface{} {
+	headers := map[string]interface{}{
+		"series":       "16",
+		"snap-id":      snapID,
+		"publisher-id": publisherID,
+		"snap-name":    snapName,
+		"timestamp":    time.Now().UTC().Format(time.RFC3339),
+	}
+	for k, v := range extra {
+		headers[k] = v
+	}
+	return headers
+}
+
+// SnapRevisionHeaders returns the headers for signing a snap-revision
+// assertion fixture for the snap file at snapPath, extra headers are
+// merged in.
+func SnapRevisionHeaders(c *check.C, snapPath, snapID, developerID string, revision snap.Revision, extra map[string]interface{}) map[string]interface{} {
+	digest, size, err := asserts.SnapFileSHA3_384(snapPath)
+	c.Assert(err, check.IsNil)
+
+	headers := map[string]interface{}{
+		"snap-sha3-384": digest,
+		"snap-size":     fmt.Sprintf("%d", size),
+		"snap-id":       snapID,
+		"developer-id":  developerID,
+		"snap-revision": revision.String(),
+		"timestamp":     time.Now().UTC().Format(time.RFC3339),
+	}
+	for k, v := range extra {
+		headers[k] = v
+	}
+	return headers
+}
@@ -0,0 +1,132 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snaptest_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type builderSuite struct{}
+
+var _ = Suite(&builderSuite{})
+
+func (s *builderSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *builderSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *builderSuite) TestSnapYaml(c *C) {
+	yaml := snaptest.NewSnapYaml("foo").
+		Version("2.0").
+		Base("core18").
+		App("app", nil).
+		App("daemon", map[string]interface{}{"command": "bin/run", "daemon": "simple", "plugs": []string{"network"}}).
+		Hook("configure", nil).
+		Plug("network", "network", nil).
+		Slot("content", "content", map[string]interface{}{"read": []string{"$SNAP/data"}}).
+		Layout("/usr/share/foo", map[string]interface{}{"bind": "$SNAP/usr/share/foo"}).
+		String()
+
+	c.Check(yaml, Equals, `apps:
+  app:
+    command: bin/app
+  daemon:
+    command: bin/run
+    daemon: simple
+    plugs:
+    - network
+base: core18
+hooks:
+  configure: {}
+layout:
+  /usr/share/foo:
+    bind: $SNAP/usr/share/foo
+name: foo
+plugs:
+  network:
+    interface: network
+slots:
+  content:
+    interface: content
+    read:
+    - $SNAP/data
+version: "2.0"
+`)
+}
+
+func (s *builderSuite) TestSnapYamlMockInfo(c *C) {
+	restore := snap.MockSanitizePlugsSlots(func(*snap.Info) {})
+	defer restore()
+
+	info := snaptest.NewSnapYaml("foo").
+		Type(snap.TypeGadget).
+		App("app", nil).
+		Hook("install", nil).
+		Plug("network", "network", nil).
+		MockInfo(c, &snap.SideInfo{Revision: snap.R(42)})
+
+	c.Check(info.InstanceName(), Equals, "foo")
+	c.Check(info.SnapType, Equals, snap.TypeGadget)
+	c.Check(info.Revision, Equals, snap.R(42))
+	c.Assert(info.Apps["app"], NotNil)
+	c.Check(info.Apps["app"].Command, Equals, "bin/app")
+	c.Check(info.Hooks["install"], NotNil)
+	c.Assert(info.Plugs["network"], NotNil)
+	c.Check(info.Plugs["network"].Interface, Equals, "network")
+}
+
+func (s *builderSuite) TestSnapYamlMockSnap(c *C) {
+	info := snaptest.NewSnapYaml("foo").MockSnap(c, &snap.SideInfo{Revision: snap.R(42)})
+	c.Check(filepath.Join(info.MountDir(), "meta", "snap.yaml"), testutil.FilePresent)
+}
+
+func (s *builderSuite) TestSnapAssertionHeaders(c *C) {
+	snapPath := filepath.Join(c.MkDir(), "foo.snap")
+	c.Assert(ioutil.WriteFile(snapPath, []byte("not-really-a-snap"), 0644), IsNil)
+
+	headers := snaptest.SnapDeclarationHeaders("foo", "foo-id", "dev-id", map[string]interface{}{"series": "18"})
+	c.Check(headers["snap-name"], Equals, "foo")
+	c.Check(headers["snap-id"], Equals, "foo-id")
+	c.Check(headers["publisher-id"], Equals, "dev-id")
+	c.Check(headers["series"], Equals, "18")
+	c.Check(headers["timestamp"], NotNil)
+
+	digest, size, err := asserts.SnapFileSHA3_384(snapPath)
+	c.Assert(err, IsNil)
+	headers = snaptest.SnapRevisionHeaders(c, snapPath, "foo-id", "dev-id", snap.R(7), nil)
+	c.Check(headers["snap-sha3-384"], Equals, digest)
+	c.Check(headers["snap-size"], Equals, fmt.Sprintf("%d", size))
+	c.Check(headers["snap-id"], Equals, "foo-id")
+	c.Check(headers["developer-id"], Equals, "dev-id")
+	c.Check(headers["snap-revision"], Equals, "7")
+}
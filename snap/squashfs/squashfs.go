@@ -28,11 +28,13 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/snapcore/snapd/cmd/cmdutil"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/squashfs"
 	"github.com/snapcore/snapd/strutil"
 )
 
@@ -168,6 +170,18 @@ func (s *Snap) Size() (size int64, err error) {
 	return st.Size(), nil
 }
 
+// extractFiles extracts the given files from the squashfs snap into
+// unpackDir, using a single unsquashfs invocation and without mounting
+// the snap. Files missing from the snap are silently skipped.
+func (s *Snap) extractFiles(unpackDir string, filePaths []string) error {
+	args := []string{"-n", "-f", "-d", unpackDir, s.path}
+	args = append(args, filePaths...)
+	if output, err := exec.Command("unsquashfs", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot extract %s from %q: %v", strutil.Quoted(filePaths), s.path, osutil.OutputErr(output, err))
+	}
+	return nil
+}
+
 // ReadFile returns the content of a single file inside a squashfs snap.
 func (s *Snap) ReadFile(filePath string) (content []byte, err error) {
 	tmpdir, err := ioutil.TempDir("", "read-file")
@@ -177,13 +191,44 @@ func (s *Snap) ReadFile(filePath string) (content []byte, err error) {
 	defer os.RemoveAll(tmpdir)
 
 	unpackDir := filepath.Join(tmpdir, "unpack")
-	if err := exec.Command("unsquashfs", "-n", "-i", "-d", unpackDir, s.path, filePath).Run(); err != nil {
+	if err := s.extractFiles(unpackDir, []string{filePath}); err != nil {
 		return nil, err
 	}
 
 	return ioutil.ReadFile(filepath.Join(unpackDir, filePath))
 }
 
+// ReadFiles returns the content of the given files inside a squashfs
+// snap, keyed by their path. Files that do not exist in the snap are
+// not part of the result. All the files are extracted at once, which
+// makes this cheaper than calling ReadFile repeatedly when inspecting
+// things like meta/snap.yaml and meta/gadget.yaml together.
+func (s *Snap) ReadFiles(filePaths []string) (map[string][]byte, error) {
+	tmpdir, err := ioutil.TempDir("", "read-files")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	unpackDir := filepath.Join(tmpdir, "unpack")
+	if err := s.extractFiles(unpackDir, filePaths); err != nil {
+		return nil, err
+	}
+
+	contents := make(map[string][]byte, len(filePaths))
+	for _, filePath := range filePaths {
+		content, err := ioutil.ReadFile(filepath.Join(unpackDir, filePath))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		contents[filePath] = content
+	}
+	return contents, nil
+}
+
 // skipper is used to track directories that should be skipped
 //
 // Given sk := make(skipper), if you sk.Add("foo/bar"), then
@@ -252,7 +297,12 @@ func (s *Snap) Walk(relative string, walkFn filepath.WalkFunc) error {
 				return err
 			}
 		} else {
-			path := filepath.Join(relative, st.Path())
+			// unsquashfs lists the paths from the top of the
+			// squashfs, including the parents of relative
+			path := filepath.Join(".", st.Path())
+			if relative != "." && path != relative && !strings.HasPrefix(path, relative+"/") {
+				continue
+			}
 			if skipper.Has(path) {
 				continue
 			}
@@ -301,8 +351,30 @@ func (s *Snap) ListDir(dirPath string) ([]string, error) {
 	return directoryContents, nil
 }
 
+// BuildOpts are the options for building a snap.
+type BuildOpts struct {
+	SnapType string
+	// Compression is the compression to use, xz by default.
+	Compression  string
+	ExcludeFiles []string
+}
+
+// the compressions snaps can be built with
+var buildCompressions = []string{"xz", "lzo", "zstd"}
+
 // Build builds the snap.
-func (s *Snap) Build(sourceDir, snapType string, excludeFiles ...string) error {
+func (s *Snap) Build(sourceDir string, opts *BuildOpts) error {
+	if opts == nil {
+		opts = &BuildOpts{}
+	}
+	compression := "xz"
+	if opts.Compression != "" {
+		compression = opts.Compression
+	}
+	if !strutil.ListContains(buildCompressions, compression) {
+		return fmt.Errorf("cannot use compression %q", compression)
+	}
+
 	fullSnapPath, err := filepath.Abs(s.path)
 	if err != nil {
 		return err
@@ -314,16 +386,17 @@ func (s *Snap) Build(sourceDir, snapType string, excludeFiles ...string) error {
 	cmd.Args = append(cmd.Args,
 		".", fullSnapPath,
 		"-noappend",
-		"-comp", "xz",
+		"-comp", compression,
 		"-no-fragments",
 		"-no-progress",
 	)
-	if len(excludeFiles) > 0 {
+	if len(opts.ExcludeFiles) > 0 {
 		cmd.Args = append(cmd.Args, "-wildcards")
-		for _, excludeFile := range excludeFiles {
+		for _, excludeFile := range opts.ExcludeFiles {
 			cmd.Args = append(cmd.Args, "-ef", excludeFile)
 		}
 	}
+	snapType := opts.SnapType
 	if snapType != "os" && snapType != "core" && snapType != "base" {
 		cmd.Args = append(cmd.Args, "-all-root", "-no-xattrs")
 	}
@@ -338,6 +411,11 @@ func (s *Snap) Build(sourceDir, snapType string, excludeFiles ...string) error {
 	})
 }
 
+// Compression returns the compression used by the snap.
+func (s *Snap) Compression() (string, error) {
+	return squashfs.Compression(s.path)
+}
+
 // BuildDate returns the "Creation or last append time" as reported by unsquashfs.
 func (s *Snap) BuildDate() time.Time {
 	return BuildDate(s.path)
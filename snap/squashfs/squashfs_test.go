@@ -96,7 +96,7 @@ func makeSnapInDir(c *C, dir, manifest, data string) *squashfs.Snap {
 	tmp := makeSnapContents(c, manifest, data)
 	// build it
 	snap := squashfs.New(filepath.Join(dir, "foo.snap"))
-	err := snap.Build(tmp, snapType)
+	err := snap.Build(tmp, &squashfs.BuildOpts{SnapType: snapType})
 	c.Assert(err, IsNil)
 
 	return snap
@@ -218,6 +218,61 @@ func (s *SquashfsTestSuite) TestReadFile(c *C) {
 	c.Assert(string(content), Equals, "name: foo")
 }
 
+func (s *SquashfsTestSuite) TestReadFileFails(c *C) {
+	mockUnsquashfs := testutil.MockCommand(c, "unsquashfs", `echo boom; exit 1`)
+	defer mockUnsquashfs.Restore()
+
+	snap := squashfs.New("foo.snap")
+	_, err := snap.ReadFile("meta/snap.yaml")
+	c.Assert(err, ErrorMatches, `cannot extract "meta/snap.yaml" from "foo.snap": boom`)
+}
+
+func (s *SquashfsTestSuite) TestReadFiles(c *C) {
+	snap := makeSnap(c, "name: foo", "some data")
+
+	contents, err := snap.ReadFiles([]string{"meta/snap.yaml", "data.bin", "meta/gadget.yaml"})
+	c.Assert(err, IsNil)
+	c.Check(contents, DeepEquals, map[string][]byte{
+		"meta/snap.yaml": []byte("name: foo"),
+		"data.bin":       []byte("some data"),
+	})
+}
+
+func (s *SquashfsTestSuite) TestReadFilesSingleInvocation(c *C) {
+	mockUnsquashfs := testutil.MockCommand(c, "unsquashfs", `
+dest="$4"
+shift 5
+for f in "$@"; do
+    if [ "$f" = "meta/gadget.yaml" ]; then
+        continue
+    fi
+    mkdir -p "$dest/$(dirname "$f")"
+    printf "content of $f" > "$dest/$f"
+done
+`)
+	defer mockUnsquashfs.Restore()
+
+	snap := squashfs.New("foo.snap")
+	contents, err := snap.ReadFiles([]string{"meta/snap.yaml", "meta/gadget.yaml"})
+	c.Assert(err, IsNil)
+	c.Check(contents, DeepEquals, map[string][]byte{
+		"meta/snap.yaml": []byte("content of meta/snap.yaml"),
+	})
+
+	calls := mockUnsquashfs.Calls()
+	c.Assert(calls, HasLen, 1)
+	c.Check(calls[0][5:], DeepEquals, []string{"foo.snap", "meta/snap.yaml", "meta/gadget.yaml"})
+}
+
+func (s *SquashfsTestSuite) TestReadFilesFails(c *C) {
+	mockUnsquashfs := testutil.MockCommand(c, "unsquashfs", `echo boom; exit 1`)
+	defer mockUnsquashfs.Restore()
+
+	snap := squashfs.New("foo.snap")
+	_, err := snap.ReadFiles([]string{"meta/snap.yaml", "meta/gadget.yaml"})
+	c.Assert(err, ErrorMatches, `cannot extract "meta/snap.yaml", "meta/gadget.yaml" from "foo.snap": boom`)
+}
+
 func (s *SquashfsTestSuite) TestListDir(c *C) {
 	snap := makeSnap(c, "name: foo", "")
 
@@ -370,7 +425,7 @@ func (s *SquashfsTestSuite) TestBuild(c *C) {
 	c.Assert(err, IsNil)
 
 	snap := squashfs.New(filepath.Join(c.MkDir(), "foo.snap"))
-	err = snap.Build(buildDir, "app")
+	err = snap.Build(buildDir, &squashfs.BuildOpts{SnapType: "app"})
 	c.Assert(err, IsNil)
 
 	// unsquashfs writes a funny header like:
@@ -411,7 +466,7 @@ data.bin
 	c.Assert(err, IsNil)
 
 	snap := squashfs.New(filepath.Join(c.MkDir(), "foo.snap"))
-	err = snap.Build(buildDir, "app", excludesFilename)
+	err = snap.Build(buildDir, &squashfs.BuildOpts{SnapType: "app", ExcludeFiles: []string{excludesFilename}})
 	c.Assert(err, IsNil)
 
 	outputWithHeader, err := exec.Command("unsquashfs", "-n", "-l", snap.Path()).Output()
@@ -436,7 +491,7 @@ func (s *SquashfsTestSuite) TestBuildSupportsMultipleExcludesWithOnlyOneWildcard
 
 	snapPath := filepath.Join(c.MkDir(), "foo.snap")
 	snap := squashfs.New(snapPath)
-	err := snap.Build(c.MkDir(), "core", "exclude1", "exclude2", "exclude3")
+	err := snap.Build(c.MkDir(), &squashfs.BuildOpts{SnapType: "core", ExcludeFiles: []string{"exclude1", "exclude2", "exclude3"}})
 	c.Assert(err, IsNil)
 	calls := mksq.Calls()
 	c.Assert(calls, HasLen, 1)
@@ -461,7 +516,7 @@ func (s *SquashfsTestSuite) TestBuildUsesMksquashfsFromCoreIfAvailable(c *C) {
 	buildDir := c.MkDir()
 
 	snap := squashfs.New(filepath.Join(c.MkDir(), "foo.snap"))
-	err := snap.Build(buildDir, "app")
+	err := snap.Build(buildDir, &squashfs.BuildOpts{SnapType: "app"})
 	c.Assert(err, IsNil)
 	c.Check(usedFromCore, Equals, true)
 	c.Check(mksq.Calls(), HasLen, 0)
@@ -480,7 +535,7 @@ func (s *SquashfsTestSuite) TestBuildUsesMksquashfsFromClassicIfCoreUnavailable(
 	buildDir := c.MkDir()
 
 	snap := squashfs.New(filepath.Join(c.MkDir(), "foo.snap"))
-	err := snap.Build(buildDir, "app")
+	err := snap.Build(buildDir, &squashfs.BuildOpts{SnapType: "app"})
 	c.Assert(err, IsNil)
 	c.Check(triedFromCore, Equals, true)
 	c.Check(mksq.Calls(), HasLen, 1)
@@ -499,7 +554,7 @@ func (s *SquashfsTestSuite) TestBuildFailsIfNoMksquashfs(c *C) {
 	buildDir := c.MkDir()
 
 	snap := squashfs.New(filepath.Join(c.MkDir(), "foo.snap"))
-	err := snap.Build(buildDir, "app")
+	err := snap.Build(buildDir, &squashfs.BuildOpts{SnapType: "app"})
 	c.Assert(err, ErrorMatches, "mksquashfs call failed:.*")
 	c.Check(triedFromCore, Equals, true)
 	c.Check(mksq.Calls(), HasLen, 1)
@@ -536,7 +591,7 @@ func (s *SquashfsTestSuite) TestBuildVariesArgsByType(c *C) {
 		mksq.ForgetCalls()
 		comm := Commentf("type: %s", t.snapType)
 
-		c.Check(snap.Build(buildDir, t.snapType), IsNil, comm)
+		c.Check(snap.Build(buildDir, &squashfs.BuildOpts{SnapType: t.snapType}), IsNil, comm)
 		c.Assert(mksq.Calls(), HasLen, 1, comm)
 		c.Assert(mksq.Calls()[0], HasLen, len(t.args)+1)
 		c.Check(mksq.Calls()[0][0], Equals, "mksquashfs", comm)
@@ -544,6 +599,27 @@ func (s *SquashfsTestSuite) TestBuildVariesArgsByType(c *C) {
 	}
 }
 
+func (s *SquashfsTestSuite) TestBuildCompression(c *C) {
+	defer squashfs.MockCommandFromSystemSnap(func(cmd string, args ...string) (*exec.Cmd, error) {
+		return nil, errors.New("bzzt")
+	})()
+	mksq := testutil.MockCommand(c, "mksquashfs", "")
+	defer mksq.Restore()
+
+	filename := filepath.Join(c.MkDir(), "foo.snap")
+	snap := squashfs.New(filename)
+	for _, comp := range []string{"xz", "lzo", "zstd"} {
+		mksq.ForgetCalls()
+		c.Check(snap.Build(c.MkDir(), &squashfs.BuildOpts{SnapType: "app", Compression: comp}), IsNil)
+		c.Assert(mksq.Calls(), HasLen, 1)
+		c.Check(mksq.Calls()[0][3:7], DeepEquals, []string{"-noappend", "-comp", comp, "-no-fragments"})
+	}
+
+	mksq.ForgetCalls()
+	c.Check(snap.Build(c.MkDir(), &squashfs.BuildOpts{Compression: "gzip"}), ErrorMatches, `cannot use compression "gzip"`)
+	c.Check(mksq.Calls(), HasLen, 0)
+}
+
 func (s *SquashfsTestSuite) TestBuildReportsFailures(c *C) {
 	mockUnsquashfs := testutil.MockCommand(c, "mksquashfs", `
 echo Yeah, nah. >&2
@@ -554,7 +630,7 @@ exit 1
 	data := "mock kernel snap"
 	dir := makeSnapContents(c, "", data)
 	snap := squashfs.New("foo.snap")
-	c.Check(snap.Build(dir, "kernel"), ErrorMatches, `mksquashfs call failed: Yeah, nah.`)
+	c.Check(snap.Build(dir, &squashfs.BuildOpts{SnapType: "kernel"}), ErrorMatches, `mksquashfs call failed: Yeah, nah.`)
 }
 
 func (s *SquashfsTestSuite) TestUnsquashfsStderrWriter(c *C) {
@@ -617,7 +693,7 @@ func (s *SquashfsTestSuite) TestBuildDate(c *C) {
 	// make a snap using this directory
 	filename := filepath.Join(c.MkDir(), "foo.snap")
 	snap := squashfs.New(filename)
-	c.Assert(snap.Build(d, "app"), IsNil)
+	c.Assert(snap.Build(d, &squashfs.BuildOpts{SnapType: "app"}), IsNil)
 	// and see it's BuildDate is _now_, not _then_.
 	c.Check(squashfs.BuildDate(filename), Equals, snap.BuildDate())
 	c.Check(math.Abs(now.Sub(snap.BuildDate()).Seconds()) <= 61, Equals, true, Commentf("Unexpected build date %s", snap.BuildDate()))
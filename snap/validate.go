@@ -357,6 +357,11 @@ func Validate(info *Info) error {
 		return err
 	}
 
+	// Ensure components are valid
+	if err := ValidateComponents(info); err != nil {
+		return err
+	}
+
 	return ValidateLayoutAll(info)
 }
 
@@ -549,6 +554,13 @@ func validateAppTimer(app *AppInfo) error {
 		return errors.New("timer is only applicable to services")
 	}
 
+	if app.Timer.UsesCalendarSyntax() {
+		if _, err := timeutil.ParseCalendarEvents(app.Timer.Timer); err != nil {
+			return fmt.Errorf("timer has invalid format: %v", err)
+		}
+		return nil
+	}
+
 	if _, err := timeutil.ParseSchedule(app.Timer.Timer); err != nil {
 		return fmt.Errorf("timer has invalid format: %v", err)
 	}
@@ -556,6 +568,42 @@ func validateAppTimer(app *AppInfo) error {
 	return nil
 }
 
+func validateAppActivatesOn(app *AppInfo) error {
+	if len(app.ActivatesOn) == 0 {
+		return nil
+	}
+
+	if !app.IsService() {
+		return errors.New("activates-on is only applicable to services")
+	}
+
+	scope, wantBus := SystemDaemon, "system"
+	if app.DaemonScope == UserDaemon {
+		scope, wantBus = UserDaemon, "session"
+	}
+	for _, slot := range app.ActivatesOn {
+		// the service can only be activated on the names the snap
+		// declares through its own slots
+		if slot.Snap != app.Snap || app.Snap.Slots[slot.Name] != slot {
+			return fmt.Errorf("invalid activates-on value %q: slot is not a slot of the snap", slot.Name)
+		}
+		if slot.Interface != "dbus" {
+			return fmt.Errorf("invalid activates-on value %q: slot does not use dbus interface", slot.Name)
+		}
+		var bus, name string
+		if err := slot.Attr("bus", &bus); err != nil {
+			return fmt.Errorf("invalid activates-on value %q: %v", slot.Name, err)
+		}
+		if bus != wantBus {
+			return fmt.Errorf("invalid activates-on value %q: slot on the %s bus cannot activate a %s daemon", slot.Name, bus, scope)
+		}
+		if err := slot.Attr("name", &name); err != nil {
+			return fmt.Errorf("invalid activates-on value %q: %v", slot.Name, err)
+		}
+	}
+	return nil
+}
+
 func validateAppRestart(app *AppInfo) error {
 	// app.RestartCond value is validated when unmarshalling
 
@@ -656,6 +704,10 @@ func ValidateApp(app *AppInfo) error {
 		return err
 	}
 
+	if err := validateAppActivatesOn(app); err != nil {
+		return err
+	}
+
 	// validate stop-mode
 	if err := app.StopMode.Validate(); err != nil {
 		return err
@@ -674,6 +726,19 @@ func ValidateApp(app *AppInfo) error {
 		return fmt.Errorf(`"refresh-mode" cannot be used for %q, only for services`, app.Name)
 	}
 
+	// validate daemon-scope
+	if err := app.DaemonScope.Validate(); err != nil {
+		return err
+	}
+	if app.DaemonScope != "" && app.Daemon == "" {
+		return fmt.Errorf(`"daemon-scope" cannot be used for %q, only for services`, app.Name)
+	}
+	if app.DaemonScope == UserDaemon && len(app.Sockets) > 0 {
+		// socket paths are limited to $SNAP_DATA and
+		// $SNAP_COMMON which are not writable by the users
+		return fmt.Errorf(`"sockets" cannot be used for %q, only for system services`, app.Name)
+	}
+
 	return validateAppTimer(app)
 }
 
@@ -883,11 +948,17 @@ func ValidateLayout(layout *Layout, constraints []LayoutConstraint) error {
 
 	switch layout.Type {
 	case "tmpfs":
+		if layout.Size < 0 {
+			return fmt.Errorf("layout %q uses invalid size %d", layout.Path, layout.Size)
+		}
 	case "":
 		// nothing to do
 	default:
 		return fmt.Errorf("layout %q uses invalid filesystem %q", layout.Path, layout.Type)
 	}
+	if layout.Size != 0 && layout.Type != "tmpfs" {
+		return fmt.Errorf("layout %q uses a size limit but is not a tmpfs", layout.Path)
+	}
 
 	if layout.Symlink != "" {
 		oldname := layout.Symlink
@@ -434,6 +434,95 @@ func (s *ValidateSuite) TestAppRefreshMode(c *C) {
 	c.Check(err, ErrorMatches, `"refresh-mode" cannot be used for "foo", only for services`)
 }
 
+func (s *ValidateSuite) TestAppDaemonScope(c *C) {
+	// check services
+	for _, t := range []struct {
+		daemonScope DaemonScope
+		ok          bool
+	}{
+		// good
+		{"", true},
+		{SystemDaemon, true},
+		{UserDaemon, true},
+		// bad
+		{"invalid-thing", false},
+	} {
+		if t.ok {
+			c.Check(ValidateApp(&AppInfo{Name: "foo", Daemon: "simple", DaemonScope: t.daemonScope}), IsNil)
+		} else {
+			c.Check(ValidateApp(&AppInfo{Name: "foo", Daemon: "simple", DaemonScope: t.daemonScope}), ErrorMatches, fmt.Sprintf(`"daemon-scope" field contains invalid value %q`, t.daemonScope))
+		}
+	}
+
+	// non-services cannot have a daemon-scope
+	err := ValidateApp(&AppInfo{Name: "foo", Daemon: "", DaemonScope: UserDaemon})
+	c.Check(err, ErrorMatches, `"daemon-scope" cannot be used for "foo", only for services`)
+
+	// user services cannot be socket activated
+	app := &AppInfo{Name: "foo", Daemon: "simple", DaemonScope: UserDaemon, Plugs: map[string]*PlugInfo{"network-bind": {}}}
+	app.Sockets = map[string]*SocketInfo{"sock": {App: app, Name: "sock", ListenStream: "$SNAP_DATA/sock"}}
+	err = ValidateApp(app)
+	c.Check(err, ErrorMatches, `"sockets" cannot be used for "foo", only for system services`)
+}
+
+func (s *ValidateSuite) TestAppActivatesOn(c *C) {
+	const yamlTemplate = `name: foo
+version: 1.0
+slots:
+  dbus-slot:
+    interface: %s
+    bus: %s
+    name: org.example.Foo
+apps:
+  app:
+    %s
+    activates-on: [dbus-slot]
+`
+	for _, t := range []struct {
+		iface, bus, app, err string
+	}{
+		{"dbus", "system", "daemon: simple", ""},
+		{"dbus", "session", "daemon: simple\n    daemon-scope: user", ""},
+		{"dbus", "session", "daemon: simple", `invalid activates-on value "dbus-slot": slot on the session bus cannot activate a system daemon`},
+		{"dbus", "system", "daemon: simple\n    daemon-scope: user", `invalid activates-on value "dbus-slot": slot on the system bus cannot activate a user daemon`},
+		{"dbus", "system", "command: foo", `activates-on is only applicable to services`},
+		{"other", "system", "daemon: simple", `invalid activates-on value "dbus-slot": slot does not use dbus interface`},
+	} {
+		info, err := InfoFromSnapYaml([]byte(fmt.Sprintf(yamlTemplate, t.iface, t.bus, t.app)))
+		c.Assert(err, IsNil)
+		err = ValidateApp(info.Apps["app"])
+		if t.err == "" {
+			c.Check(err, IsNil)
+		} else {
+			c.Check(err, ErrorMatches, t.err)
+		}
+	}
+}
+
+func (s *ValidateSuite) TestAppActivatesOnSlotOfOtherSnap(c *C) {
+	const yaml = `name: %s
+version: 1.0
+slots:
+  dbus-slot:
+    interface: dbus
+    bus: system
+    name: org.example.Foo
+apps:
+  app:
+    daemon: simple
+    activates-on: [dbus-slot]
+`
+	info, err := InfoFromSnapYaml([]byte(fmt.Sprintf(yaml, "foo")))
+	c.Assert(err, IsNil)
+	other, err := InfoFromSnapYaml([]byte(fmt.Sprintf(yaml, "bar")))
+	c.Assert(err, IsNil)
+
+	app := info.Apps["app"]
+	app.ActivatesOn = []*SlotInfo{other.Slots["dbus-slot"]}
+	err = ValidateApp(app)
+	c.Check(err, ErrorMatches, `invalid activates-on value "dbus-slot": slot is not a slot of the snap`)
+}
+
 func (s *ValidateSuite) TestAppWhitelistError(c *C) {
 	err := ValidateApp(&AppInfo{Name: "foo", Command: "x\n"})
 	c.Assert(err, NotNil)
@@ -695,6 +784,11 @@ func (s *ValidateSuite) TestValidateLayout(c *C) {
 		ErrorMatches, `layout "/foo/bar" uses invalid group "foo"`)
 	c.Check(ValidateLayout(&Layout{Snap: si, Path: "/foo", Type: "tmpfs", Mode: 02755}, nil),
 		ErrorMatches, `layout "/foo" uses invalid mode 02755`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "/foo", Type: "tmpfs", Size: 64 * 1000 * 1000}, nil), IsNil)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "/foo", Type: "tmpfs", Size: -1}, nil),
+		ErrorMatches, `layout "/foo" uses invalid size -1`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "/foo", Bind: "$SNAP/foo", Size: 1000}, nil),
+		ErrorMatches, `layout "/foo" uses a size limit but is not a tmpfs`)
 	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$FOO", Type: "tmpfs"}, nil),
 		ErrorMatches, `layout "\$FOO" uses invalid mount point: reference to unknown variable "\$FOO"`)
 	c.Check(ValidateLayout(&Layout{Snap: si, Path: "/foo", Bind: "$BAR"}, nil),
@@ -1260,6 +1354,18 @@ apps:
     daemon: oneshot
     timer: mon,10:00-12:00,mon2-wed3
 `)
+	calendarTimer := []byte(`
+apps:
+  foo:
+    daemon: oneshot
+    timer: Mon..Fri *-*-* 8..18/2:00 UTC;Sat,Sun 12:00
+`)
+	badCalendarTimer := []byte(`
+apps:
+  foo:
+    daemon: oneshot
+    timer: Mon..Fri *-*-* 25:00
+`)
 
 	tcs := []struct {
 		name string
@@ -1276,6 +1382,13 @@ apps:
 		name: "invalid timer",
 		desc: badTimer,
 		err:  `timer has invalid format: cannot parse "mon2-wed3": invalid schedule fragment`,
+	}, {
+		name: "calendar timer",
+		desc: calendarTimer,
+	}, {
+		name: "invalid calendar timer",
+		desc: badCalendarTimer,
+		err:  `timer has invalid format: cannot parse calendar event "Mon..Fri \*-\*-\* 25:00": invalid hour "25"`,
 	}}
 	for _, tc := range tcs {
 		c.Logf("trying %q", tc.name)
@@ -1560,6 +1673,30 @@ apps:
 	}
 }
 
+func (s *ValidateSuite) TestValidateComponents(c *C) {
+	const meta = `name: foo
+version: 1.0
+components:
+`
+	for _, t := range []struct {
+		comps string
+		err   string
+	}{
+		{"  debug:\n    type: debug-symbols\n  data:\n    type: assets\n", ""},
+		{"  b@d:\n    type: test\n", `invalid snap component name: "b@d"`},
+		{"  debug:\n    type: foo\n", `invalid definition of component "debug": cannot use unknown component type "foo"`},
+	} {
+		info, err := InfoFromSnapYaml([]byte(meta + t.comps))
+		c.Assert(err, IsNil)
+		err = Validate(info)
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf(t.comps))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf(t.comps))
+		}
+	}
+}
+
 func (s *ValidateSuite) TestValidateSystemUsernames(c *C) {
 	const yaml1 = `name: binary
 version: 1.0
@@ -26,6 +26,7 @@ import (
 
 	"github.com/snapcore/snapd/jsonutil/safejson"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/channel"
 	"github.com/snapcore/snapd/strutil"
 )
 
@@ -132,7 +133,12 @@ func infoFromStoreInfo(si *storeInfo) (*snap.Info, error) {
 	seen := make(map[string]bool, len(si.ChannelMap))
 	for _, s := range si.ChannelMap {
 		ch := s.Channel
-		info.Channels[ch.Track+"/"+ch.Risk] = &snap.ChannelSnapInfo{
+		chName := ch.Track + "/" + ch.Risk
+		if parsed, err := channel.ParseVerbatim(ch.Name, "-"); err == nil && parsed.Branch != "" {
+			// do not let branches shadow the channel of their risk
+			chName += "/" + parsed.Branch
+		}
+		info.Channels[chName] = &snap.ChannelSnapInfo{
 			Revision:    snap.R(s.Revision),
 			Confinement: snap.ConfinementType(s.Confinement),
 			Version:     s.Version,
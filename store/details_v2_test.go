@@ -303,6 +303,7 @@ func (s *detailsV2Suite) TestInfoFromStoreSnap(c *C) {
 		"SideInfo.Channel",
 		"DownloadInfo.AnonDownloadURL", // TODO: going away at some point
 		"SystemUsernames",
+		"Components",
 	}
 	var checker func(string, reflect.Value)
 	checker = func(pfx string, x reflect.Value) {
@@ -390,6 +391,29 @@ func fillStruct(a interface{}, c *C) {
 	}
 }
 
+func (s *detailsV2Suite) TestInfoFromStoreInfoBranches(c *C) {
+	var snp storeSnap
+	err := json.Unmarshal([]byte(coreStoreJSON), &snp)
+	c.Assert(err, IsNil)
+
+	si := &storeInfo{
+		ChannelMap: []*storeInfoChannelSnap{
+			{storeSnap: snp, Channel: storeInfoChannel{Name: "stable", Track: "latest", Risk: "stable"}},
+			{storeSnap: snp, Channel: storeInfoChannel{Name: "stable/fix-123", Track: "latest", Risk: "stable"}},
+			{storeSnap: snp, Channel: storeInfoChannel{Name: "1.0/beta/fix-456", Track: "1.0", Risk: "beta"}},
+		},
+		Name:   "core",
+		SnapID: snp.SnapID,
+	}
+	info, err := infoFromStoreInfo(si)
+	c.Assert(err, IsNil)
+	c.Assert(info.Channels, HasLen, 3)
+	c.Check(info.Channels["latest/stable"].Channel, Equals, "stable")
+	c.Check(info.Channels["latest/stable/fix-123"].Channel, Equals, "stable/fix-123")
+	c.Check(info.Channels["1.0/beta/fix-456"].Channel, Equals, "1.0/beta/fix-456")
+	c.Check(info.Tracks, DeepEquals, []string{"latest", "1.0"})
+}
+
 func (s *detailsV2Suite) TestCopyNonZero(c *C) {
 	// a is a storeSnap with everything non-zero
 	a := storeSnap{}
@@ -560,6 +560,25 @@ func (s *Store) refreshUser(user *auth.UserState) error {
 	return nil
 }
 
+// RefreshUserAuth renews the store discharges of the user, typically
+// ahead of their expiry.
+func (s *Store) RefreshUserAuth(user *auth.UserState) error {
+	return s.refreshUser(user)
+}
+
+// RefreshDeviceSession renews the store session of the device,
+// typically ahead of its expiry.
+func (s *Store) RefreshDeviceSession() error {
+	if s.dauthCtx == nil {
+		return fmt.Errorf("internal error: no authContext")
+	}
+	device, err := s.dauthCtx.Device()
+	if err != nil {
+		return err
+	}
+	return s.refreshDeviceSession(device)
+}
+
 // refreshDeviceSession will set or refresh the device session in the state
 func (s *Store) refreshDeviceSession(device *auth.DeviceState) error {
 	if s.dauthCtx == nil {
@@ -2409,6 +2428,12 @@ func (s *Store) snapAction(ctx context.Context, currentSnaps []*CurrentSnap, act
 				refreshErrors[cur.InstanceName] = ErrNoUpdateAvailable
 				continue
 			}
+			if a := refreshes[res.InstanceKey]; (a == nil || a.Revision.Unset()) && !snapInfo.Epoch.CanRead(cur.Epoch) {
+				// the store must only offer refreshes that can
+				// read the data of the current revision
+				refreshErrors[cur.InstanceName] = fmt.Errorf("store offered revision %s with epoch %s which cannot read the current epoch %s", rrev, snapInfo.Epoch, cur.Epoch)
+				continue
+			}
 			instanceName = cur.InstanceName
 		} else if res.Result == "install" {
 			if action := installs[res.InstanceKey]; action != nil {
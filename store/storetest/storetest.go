@@ -104,3 +104,11 @@ func (Store) LoginUser(username, password, otp string) (string, string, error) {
 func (Store) UserInfo(email string) (userinfo *store.User, err error) {
 	panic("UserInfo not expected")
 }
+
+func (Store) RefreshUserAuth(user *auth.UserState) error {
+	panic("RefreshUserAuth not expected")
+}
+
+func (Store) RefreshDeviceSession() error {
+	panic("RefreshDeviceSession not expected")
+}
@@ -21,12 +21,21 @@ package systemd
 
 import (
 	"io"
+	"os/user"
 )
 
 var (
 	Jctl = jctl
+
+	SystemctlAsUserCmd = systemctlAsUserCmd
 )
 
+func MockUserLookupId(f func(string) (*user.User, error)) func() {
+	old := userLookupId
+	userLookupId = f
+	return func() { userLookupId = old }
+}
+
 func MockOsGetenv(f func(string) string) func() {
 	oldOsGetenv := osGetenv
 	osGetenv = f
@@ -0,0 +1,145 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package systemd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/snapcore/snapd/strutil"
+)
+
+// JournalOptions select which entries of the journal a JournalReader
+// returns.
+type JournalOptions struct {
+	// Units restricts the entries to the ones of the given units.
+	Units []string
+	// N is the number of most recent entries to start with, all of
+	// them if negative.
+	N int
+	// Follow keeps waiting for new entries once the existing ones
+	// have been read.
+	Follow bool
+	// AfterCursor, if set, starts with the entry following the one
+	// with the given cursor, as obtained from JournalReader.Cursor.
+	// It takes precedence over N.
+	AfterCursor string
+	// Priority, if set, restricts the entries to the ones of the
+	// given syslog priority or a more important one, either by name
+	// ("err") or by number ("3").
+	Priority string
+}
+
+var journalPriorities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+func validateJournalPriority(prio string) error {
+	if strutil.ListContains(journalPriorities, prio) {
+		return nil
+	}
+	if n, err := strconv.Atoi(prio); err == nil && n >= 0 && n < len(journalPriorities) {
+		return nil
+	}
+	return fmt.Errorf("invalid journal priority %q", prio)
+}
+
+func journalctlArgs(opts *JournalOptions) []string {
+	// two entries per unit, plus up to 6 for the fixed options and
+	// another 2 for the priority
+	size := 2*len(opts.Units) + 6
+	if opts.Priority != "" {
+		size += 2
+	}
+	args := make([]string, 0, size)
+	args = append(args, "-o", "json", "--no-pager")
+	switch {
+	case opts.AfterCursor != "":
+		args = append(args, "--after-cursor", opts.AfterCursor)
+	case opts.N < 0:
+		args = append(args, "--no-tail")
+	default:
+		args = append(args, "-n", strconv.Itoa(opts.N))
+	}
+	if opts.Follow {
+		args = append(args, "-f")
+	}
+	if opts.Priority != "" {
+		args = append(args, "-p", opts.Priority)
+	}
+	for _, unit := range opts.Units {
+		args = append(args, "-u", unit)
+	}
+	return args
+}
+
+// A JournalReader reads entries from the systemd journal.
+type JournalReader struct {
+	rc     io.ReadCloser
+	dec    *json.Decoder
+	cursor string
+}
+
+// NewJournalReader returns a JournalReader for the entries selected by
+// the given options. The reader must be closed when done with it.
+func NewJournalReader(opts *JournalOptions) (*JournalReader, error) {
+	if opts == nil {
+		opts = &JournalOptions{N: -1}
+	}
+	if opts.Priority != "" {
+		if err := validateJournalPriority(opts.Priority); err != nil {
+			return nil, err
+		}
+	}
+	rc, err := osutilStreamCommand("journalctl", journalctlArgs(opts)...)
+	if err != nil {
+		return nil, err
+	}
+	return &JournalReader{
+		rc:     rc,
+		dec:    json.NewDecoder(rc),
+		cursor: opts.AfterCursor,
+	}, nil
+}
+
+// Next returns the next entry of the journal, blocking for it in follow
+// mode. It returns io.EOF when there are no more entries.
+func (r *JournalReader) Next() (Log, error) {
+	var log Log
+	if err := r.dec.Decode(&log); err != nil {
+		return nil, err
+	}
+	if cursor := log.Cursor(); cursor != "" {
+		r.cursor = cursor
+	}
+	return log, nil
+}
+
+// Cursor returns the cursor of the last entry returned by Next, which
+// can be used as JournalOptions.AfterCursor to resume reading from
+// there. Before any entry was read it is the starting cursor, if any.
+func (r *JournalReader) Cursor() string {
+	return r.cursor
+}
+
+// Close stops reading the journal.
+func (r *JournalReader) Close() error {
+	return r.rc.Close()
+}
@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package systemd_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/systemd"
+)
+
+type journalReaderSuite struct {
+	args []string
+	out  string
+	err  error
+
+	restore func()
+}
+
+var _ = Suite(&journalReaderSuite{})
+
+func (s *journalReaderSuite) SetUpTest(c *C) {
+	s.args = nil
+	s.out = ""
+	s.err = nil
+	s.restore = systemd.MockOsutilStreamCommand(func(name string, args ...string) (io.ReadCloser, error) {
+		c.Check(name, Equals, "journalctl")
+		s.args = args
+		if s.err != nil {
+			return nil, s.err
+		}
+		return ioutil.NopCloser(strings.NewReader(s.out)), nil
+	})
+}
+
+func (s *journalReaderSuite) TearDownTest(c *C) {
+	s.restore()
+}
+
+func (s *journalReaderSuite) TestArgs(c *C) {
+	for _, t := range []struct {
+		opts *systemd.JournalOptions
+		args []string
+	}{
+		{nil, []string{"-o", "json", "--no-pager", "--no-tail"}},
+		{&systemd.JournalOptions{N: 10}, []string{"-o", "json", "--no-pager", "-n", "10"}},
+		{&systemd.JournalOptions{N: 10, Follow: true, Units: []string{"foo", "bar"}},
+			[]string{"-o", "json", "--no-pager", "-n", "10", "-f", "-u", "foo", "-u", "bar"}},
+		{&systemd.JournalOptions{N: 10, AfterCursor: "s=1;i=2", Follow: true},
+			[]string{"-o", "json", "--no-pager", "--after-cursor", "s=1;i=2", "-f"}},
+		{&systemd.JournalOptions{N: -1, Priority: "err", Units: []string{"foo"}},
+			[]string{"-o", "json", "--no-pager", "--no-tail", "-p", "err", "-u", "foo"}},
+		{&systemd.JournalOptions{Priority: "4"}, []string{"-o", "json", "--no-pager", "-n", "0", "-p", "4"}},
+	} {
+		r, err := systemd.NewJournalReader(t.opts)
+		c.Assert(err, IsNil)
+		c.Check(s.args, DeepEquals, t.args)
+		c.Check(r.Close(), IsNil)
+	}
+}
+
+func (s *journalReaderSuite) TestInvalidPriority(c *C) {
+	for _, prio := range []string{"8", "-1", "error", "err..warning"} {
+		_, err := systemd.NewJournalReader(&systemd.JournalOptions{Priority: prio})
+		c.Check(err, ErrorMatches, `invalid journal priority ".*"`)
+	}
+	c.Check(s.args, IsNil)
+}
+
+func (s *journalReaderSuite) TestStreamCommandError(c *C) {
+	s.err = errors.New("boom")
+	_, err := systemd.NewJournalReader(nil)
+	c.Check(err, ErrorMatches, "boom")
+}
+
+func (s *journalReaderSuite) TestNextAndCursor(c *C) {
+	s.out = `{"MESSAGE": "one", "__CURSOR": "c1", "PRIORITY": "6"}
+{"MESSAGE": "two", "__CURSOR": "c2", "PRIORITY": "3"}
+{"MESSAGE": "three"}
+`
+	r, err := systemd.NewJournalReader(&systemd.JournalOptions{AfterCursor: "c0"})
+	c.Assert(err, IsNil)
+	defer r.Close()
+	c.Check(r.Cursor(), Equals, "c0")
+
+	log, err := r.Next()
+	c.Assert(err, IsNil)
+	c.Check(log.Message(), Equals, "one")
+	c.Check(log.Priority(), Equals, 6)
+	c.Check(r.Cursor(), Equals, "c1")
+
+	log, err = r.Next()
+	c.Assert(err, IsNil)
+	c.Check(log.Message(), Equals, "two")
+	c.Check(log.Priority(), Equals, 3)
+	c.Check(r.Cursor(), Equals, "c2")
+
+	// an entry without cursor keeps the last one
+	log, err = r.Next()
+	c.Assert(err, IsNil)
+	c.Check(log.Message(), Equals, "three")
+	c.Check(log.Priority(), Equals, -1)
+	c.Check(r.Cursor(), Equals, "c2")
+
+	_, err = r.Next()
+	c.Check(err, Equals, io.EOF)
+}
+
+func (s *journalReaderSuite) TestNextBadEntry(c *C) {
+	s.out = "{\"MESSAGE\": \"one\"}\nnot-json\n"
+	r, err := systemd.NewJournalReader(nil)
+	c.Assert(err, IsNil)
+	defer r.Close()
+
+	_, err = r.Next()
+	c.Assert(err, IsNil)
+	_, err = r.Next()
+	c.Check(err, ErrorMatches, "invalid character .*")
+}
@@ -0,0 +1,242 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package systemd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// ResourceLimits describes the resources available to the processes
+// of a slice or a scope. A zero value means no limit.
+type ResourceLimits struct {
+	// MemoryMax is the maximum memory usage, in bytes.
+	MemoryMax uint64
+	// CPUQuota is the CPU time available, as a percentage of the
+	// time of a single CPU; it can be above 100 on systems with
+	// multiple CPUs.
+	CPUQuota int
+}
+
+func (l *ResourceLimits) validate() error {
+	if l.CPUQuota < 0 {
+		return fmt.Errorf("invalid CPU quota %d%%", l.CPUQuota)
+	}
+	return nil
+}
+
+// unitProperties returns the properties setting the limits as written
+// in unit files, with the unset limits left out unless reset is true,
+// in which case they are explicitly lifted.
+func (l *ResourceLimits) unitProperties(reset bool) []string {
+	var props []string
+	switch {
+	case l.MemoryMax != 0:
+		props = append(props, fmt.Sprintf("MemoryMax=%d", l.MemoryMax))
+	case reset:
+		props = append(props, "MemoryMax=infinity")
+	}
+	switch {
+	case l.CPUQuota != 0:
+		props = append(props, fmt.Sprintf("CPUQuota=%d%%", l.CPUQuota))
+	case reset:
+		props = append(props, "CPUQuota=")
+	}
+	return props
+}
+
+// busProperties returns the properties setting the limits in the
+// format expected by busctl for an a(sv) argument, without the count.
+func (l *ResourceLimits) busProperties() (props []string, n int) {
+	if l.MemoryMax != 0 {
+		props = append(props, "MemoryMax", "t", strconv.FormatUint(l.MemoryMax, 10))
+		n++
+	}
+	if l.CPUQuota != 0 {
+		// one percent of a second, in microseconds
+		usec := uint64(l.CPUQuota) * 10000
+		props = append(props, "CPUQuotaPerSecUSec", "t", strconv.FormatUint(usec, 10))
+		n++
+	}
+	return props, n
+}
+
+func validateUnitName(name, suffix string) error {
+	if !strings.HasSuffix(name, suffix) || len(name) == len(suffix) || strings.ContainsRune(name, '/') {
+		return fmt.Errorf("invalid %s unit name %q", strings.TrimPrefix(suffix, "."), name)
+	}
+	return nil
+}
+
+var busctlCmd = func(args ...string) ([]byte, error) {
+	bs, err := exec.Command("busctl", args...).CombinedOutput()
+	if err != nil {
+		exitCode, _ := osutil.ExitCode(err)
+		return nil, &Error{cmd: args, exitCode: exitCode, msg: bs}
+	}
+
+	return bs, nil
+}
+
+// MockBusctl allows to mock the calls to busctl used to talk to
+// systemd over D-Bus, see StartTransientScope.
+func MockBusctl(f func(args ...string) ([]byte, error)) func() {
+	oldBusctlCmd := busctlCmd
+	busctlCmd = f
+	return func() {
+		busctlCmd = oldBusctlCmd
+	}
+}
+
+// SliceUnitPath returns the path of the unit file of the given slice.
+func SliceUnitPath(name string) string {
+	return filepath.Join(dirs.SnapServicesDir, name)
+}
+
+// AddSliceUnitFile writes the unit file of the slice with the given
+// name, e.g. "snap-foo.slice", limiting the resources of the units
+// placed in it, and makes systemd load it. Rewriting the unit file
+// of an existing slice changes its limits.
+func (s *systemd) AddSliceUnitFile(name, description string, limits *ResourceLimits) error {
+	if err := validateUnitName(name, ".slice"); err != nil {
+		return err
+	}
+	if err := limits.validate(); err != nil {
+		return err
+	}
+
+	daemonReloadLock.Lock()
+	defer daemonReloadLock.Unlock()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `[Unit]
+Description=%s
+Before=slices.target
+
+[Slice]
+`, description)
+	for _, prop := range limits.unitProperties(false) {
+		fmt.Fprintln(&buf, prop)
+	}
+	if err := osutil.AtomicWriteFile(SliceUnitPath(name), []byte(buf.String()), 0644, 0); err != nil {
+		return err
+	}
+
+	return s.daemonReloadNoLock()
+}
+
+// RemoveSliceUnitFile removes the unit file of the given slice and makes
+// systemd forget about it. The units still running in the slice are
+// left alone, and so is the slice until they are gone.
+func (s *systemd) RemoveSliceUnitFile(name string) error {
+	if err := validateUnitName(name, ".slice"); err != nil {
+		return err
+	}
+
+	daemonReloadLock.Lock()
+	defer daemonReloadLock.Unlock()
+
+	if err := os.Remove(SliceUnitPath(name)); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return s.daemonReloadNoLock()
+}
+
+// SetUnitResources changes the resource limits of the given running unit,
+// typically a slice or a scope, until it is stopped. The limits not set
+// are lifted.
+func (s *systemd) SetUnitResources(unit string, limits *ResourceLimits) error {
+	if err := limits.validate(); err != nil {
+		return err
+	}
+	args := append([]string{"--runtime", "set-property", unit}, limits.unitProperties(true)...)
+	_, err := s.systemctl(args...)
+	return err
+}
+
+// StartTransientScope asks systemd to start the transient scope with the
+// given name, e.g. "snap.foo.app.1234.scope", moving the process with
+// the given pid into it. Unless empty, the scope is placed in the given
+// slice. The scope goes away with the last of its processes.
+//
+// The scope is started asynchronously: the process may not have been
+// moved yet when StartTransientScope returns.
+func (s *systemd) StartTransientScope(name, slice string, pid int, limits *ResourceLimits) error {
+	if err := validateUnitName(name, ".scope"); err != nil {
+		return err
+	}
+	if slice != "" {
+		if err := validateUnitName(slice, ".slice"); err != nil {
+			return err
+		}
+	}
+	if pid <= 0 {
+		return fmt.Errorf("invalid process ID %d", pid)
+	}
+	if err := limits.validate(); err != nil {
+		return err
+	}
+
+	var args []string
+	switch s.mode {
+	case SystemMode:
+	case UserMode:
+		if s.underUser {
+			return fmt.Errorf("cannot start transient scopes of another user")
+		}
+		args = append(args, "--user")
+	case GlobalUserMode:
+		panic("cannot start transient scopes with GlobalUserMode")
+	default:
+		panic("unknown InstanceMode")
+	}
+
+	limitProps, n := limits.busProperties()
+	props := []string{"PIDs", "au", "1", strconv.Itoa(pid)}
+	n++
+	if slice != "" {
+		props = append(props, "Slice", "s", slice)
+		n++
+	}
+	props = append(props, limitProps...)
+
+	// StartTransientUnit(in s name, in s mode, in a(sv) properties,
+	//                    in a(sa(sv)) aux, out o job)
+	args = append(args, "call", "--quiet",
+		"org.freedesktop.systemd1", "/org/freedesktop/systemd1",
+		"org.freedesktop.systemd1.Manager", "StartTransientUnit",
+		"ssa(sv)a(sa(sv))", name, "fail", strconv.Itoa(n))
+	args = append(args, props...)
+	args = append(args, "0")
+	_, err := busctlCmd(args...)
+	return err
+}
@@ -0,0 +1,172 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package systemd_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/sys"
+	"github.com/snapcore/snapd/testutil"
+
+	. "github.com/snapcore/snapd/systemd"
+)
+
+func (s *SystemdTestSuite) TestAddSliceUnitFile(c *C) {
+	sysd := New("", SystemMode, nil)
+	err := sysd.AddSliceUnitFile("snap-foo.slice", "Slice for foo", &ResourceLimits{
+		MemoryMax: 512 * 1024 * 1024,
+		CPUQuota:  150,
+	})
+	c.Assert(err, IsNil)
+
+	c.Check(filepath.Join(dirs.SnapServicesDir, "snap-foo.slice"), testutil.FileEquals, `[Unit]
+Description=Slice for foo
+Before=slices.target
+
+[Slice]
+MemoryMax=536870912
+CPUQuota=150%
+`)
+	c.Check(s.argses, DeepEquals, [][]string{{"daemon-reload"}})
+
+	// rewriting it changes the limits
+	err = sysd.AddSliceUnitFile("snap-foo.slice", "Slice for foo", &ResourceLimits{CPUQuota: 50})
+	c.Assert(err, IsNil)
+	c.Check(SliceUnitPath("snap-foo.slice"), testutil.FileEquals, `[Unit]
+Description=Slice for foo
+Before=slices.target
+
+[Slice]
+CPUQuota=50%
+`)
+	c.Check(s.argses, HasLen, 2)
+}
+
+func (s *SystemdTestSuite) TestAddSliceUnitFileErrors(c *C) {
+	sysd := New("", SystemMode, nil)
+	for _, name := range []string{"", ".slice", "foo", "foo.scope", "foo/bar.slice"} {
+		err := sysd.AddSliceUnitFile(name, "", &ResourceLimits{})
+		c.Check(err, ErrorMatches, `invalid slice unit name ".*"`, Commentf(name))
+	}
+	err := sysd.AddSliceUnitFile("snap-foo.slice", "", &ResourceLimits{CPUQuota: -1})
+	c.Check(err, ErrorMatches, `invalid CPU quota -1%`)
+
+	c.Check(osutil.FileExists(SliceUnitPath("snap-foo.slice")), Equals, false)
+	c.Check(s.argses, HasLen, 0)
+}
+
+func (s *SystemdTestSuite) TestRemoveSliceUnitFile(c *C) {
+	sysd := New("", SystemMode, nil)
+	err := sysd.AddSliceUnitFile("snap-foo.slice", "Slice for foo", &ResourceLimits{})
+	c.Assert(err, IsNil)
+
+	err = sysd.RemoveSliceUnitFile("snap-foo.slice")
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(SliceUnitPath("snap-foo.slice")), Equals, false)
+	c.Check(s.argses, DeepEquals, [][]string{{"daemon-reload"}, {"daemon-reload"}})
+
+	// nothing to do the second time
+	err = sysd.RemoveSliceUnitFile("snap-foo.slice")
+	c.Assert(err, IsNil)
+	c.Check(s.argses, HasLen, 2)
+}
+
+func (s *SystemdTestSuite) TestSetUnitResources(c *C) {
+	err := New("", SystemMode, nil).SetUnitResources("snap-foo.slice", &ResourceLimits{MemoryMax: 4096})
+	c.Assert(err, IsNil)
+	err = New("", UserMode, nil).SetUnitResources("snap.foo.app.1.scope", &ResourceLimits{CPUQuota: 20})
+	c.Assert(err, IsNil)
+	c.Check(s.argses, DeepEquals, [][]string{
+		{"--runtime", "set-property", "snap-foo.slice", "MemoryMax=4096", "CPUQuota="},
+		{"--user", "--runtime", "set-property", "snap.foo.app.1.scope", "MemoryMax=infinity", "CPUQuota=20%"},
+	})
+}
+
+func (s *SystemdTestSuite) TestStartTransientScope(c *C) {
+	var calls [][]string
+	restore := MockBusctl(func(args ...string) ([]byte, error) {
+		calls = append(calls, args)
+		return nil, nil
+	})
+	defer restore()
+
+	err := New("", SystemMode, nil).StartTransientScope("snap.foo.app.1.scope", "snap-foo.slice", 1234, &ResourceLimits{
+		MemoryMax: 1024,
+		CPUQuota:  25,
+	})
+	c.Assert(err, IsNil)
+	err = New("", UserMode, nil).StartTransientScope("snap.foo.app.2.scope", "", 42, &ResourceLimits{})
+	c.Assert(err, IsNil)
+
+	c.Check(calls, DeepEquals, [][]string{
+		{"call", "--quiet", "org.freedesktop.systemd1", "/org/freedesktop/systemd1",
+			"org.freedesktop.systemd1.Manager", "StartTransientUnit", "ssa(sv)a(sa(sv))",
+			"snap.foo.app.1.scope", "fail", "4",
+			"PIDs", "au", "1", "1234",
+			"Slice", "s", "snap-foo.slice",
+			"MemoryMax", "t", "1024",
+			"CPUQuotaPerSecUSec", "t", "250000",
+			"0"},
+		{"--user", "call", "--quiet", "org.freedesktop.systemd1", "/org/freedesktop/systemd1",
+			"org.freedesktop.systemd1.Manager", "StartTransientUnit", "ssa(sv)a(sa(sv))",
+			"snap.foo.app.2.scope", "fail", "1",
+			"PIDs", "au", "1", "42",
+			"0"},
+	})
+}
+
+func (s *SystemdTestSuite) TestStartTransientScopeErrors(c *C) {
+	restore := MockBusctl(func(args ...string) ([]byte, error) {
+		c.Fatalf("unexpected busctl call")
+		return nil, nil
+	})
+	defer restore()
+
+	sysd := New("", SystemMode, nil)
+	err := sysd.StartTransientScope("foo.slice", "", 1, &ResourceLimits{})
+	c.Check(err, ErrorMatches, `invalid scope unit name "foo.slice"`)
+	err = sysd.StartTransientScope("foo.scope", "foo", 1, &ResourceLimits{})
+	c.Check(err, ErrorMatches, `invalid slice unit name "foo"`)
+	err = sysd.StartTransientScope("foo.scope", "", 0, &ResourceLimits{})
+	c.Check(err, ErrorMatches, `invalid process ID 0`)
+	err = sysd.StartTransientScope("foo.scope", "", 1, &ResourceLimits{CPUQuota: -5})
+	c.Check(err, ErrorMatches, `invalid CPU quota -5%`)
+
+	err = NewUnderUser("", sys.UserID(1000), nil).StartTransientScope("foo.scope", "", 1, &ResourceLimits{})
+	c.Check(err, ErrorMatches, `cannot start transient scopes of another user`)
+	c.Check(func() {
+		New("", GlobalUserMode, nil).StartTransientScope("foo.scope", "", 1, &ResourceLimits{})
+	}, PanicMatches, `cannot start transient scopes with GlobalUserMode`)
+}
+
+func (s *SystemdTestSuite) TestStartTransientScopeBusctlError(c *C) {
+	restore := MockBusctl(func(args ...string) ([]byte, error) {
+		return nil, &os.PathError{Op: "exec", Path: "busctl", Err: os.ErrNotExist}
+	})
+	defer restore()
+
+	err := New("", SystemMode, nil).StartTransientScope("foo.scope", "", 1, &ResourceLimits{})
+	c.Check(err, ErrorMatches, `exec busctl: file does not exist`)
+}
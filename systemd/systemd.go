@@ -25,12 +25,14 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	_ "github.com/snapcore/squashfuse"
@@ -38,6 +40,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/osutil/squashfs"
+	"github.com/snapcore/snapd/osutil/sys"
 	"github.com/snapcore/snapd/sandbox/selinux"
 )
 
@@ -99,6 +102,49 @@ var systemctlCmd = func(args ...string) ([]byte, error) {
 	return bs, nil
 }
 
+// systemctlAsUserCmd calls systemctl as the given user, connecting to
+// the user's session bus so that the user's own instance of systemd is
+// reached
+var systemctlAsUserCmd = func(uid sys.UserID, args ...string) ([]byte, error) {
+	u, err := userLookupId(strconv.FormatUint(uint64(uid), 10))
+	if err != nil {
+		return nil, err
+	}
+	_, gid, err := osutil.UidGid(u)
+	if err != nil {
+		return nil, err
+	}
+	runtimeDir := filepath.Join(dirs.XdgRuntimeDirBase, strconv.FormatUint(uint64(uid), 10))
+	busPath := filepath.Join(runtimeDir, "bus")
+	if !osutil.FileExists(busPath) {
+		return nil, fmt.Errorf("cannot find session bus of user %q", u.Username)
+	}
+
+	cmd := exec.Command("systemctl", args...)
+	cmd.Env = []string{
+		"HOME=" + u.HomeDir,
+		"USER=" + u.Username,
+		"XDG_RUNTIME_DIR=" + runtimeDir,
+		"DBUS_SESSION_BUS_ADDRESS=unix:path=" + busPath,
+		"PATH=" + os.Getenv("PATH"),
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{
+			Uid: uint32(uid),
+			Gid: uint32(gid),
+		},
+	}
+	bs, err := cmd.CombinedOutput()
+	if err != nil {
+		exitCode, _ := osutil.ExitCode(err)
+		return nil, &Error{cmd: args, exitCode: exitCode, msg: bs}
+	}
+
+	return bs, nil
+}
+
+var userLookupId = user.LookupId
+
 // MockSystemctl is called from the commands to actually call out to
 // systemctl. It's exported so it can be overridden by testing.
 func MockSystemctl(f func(args ...string) ([]byte, error)) func() {
@@ -109,6 +155,16 @@ func MockSystemctl(f func(args ...string) ([]byte, error)) func() {
 	}
 }
 
+// MockSystemctlAsUser allows to mock the calls to systemctl done on
+// behalf of a given user, see NewUnderUser.
+func MockSystemctlAsUser(f func(uid sys.UserID, args ...string) ([]byte, error)) func() {
+	oldSystemctlAsUserCmd := systemctlAsUserCmd
+	systemctlAsUserCmd = f
+	return func() {
+		systemctlAsUserCmd = oldSystemctlAsUserCmd
+	}
+}
+
 // MockStopDelays is used from tests so that Stop can be less
 // forgiving there.
 func MockStopDelays(checkDelay, notifyDelay time.Duration) func() {
@@ -131,23 +187,7 @@ var osutilStreamCommand = osutil.StreamCommand
 
 // jctl calls journalctl to get the JSON logs of the given services.
 var jctl = func(svcs []string, n int, follow bool) (io.ReadCloser, error) {
-	// args will need two entries per service, plus a fixed number (give or take
-	// one) for the initial options.
-	args := make([]string, 0, 2*len(svcs)+6)        // the fixed number is 6
-	args = append(args, "-o", "json", "--no-pager") //   3...
-	if n < 0 {
-		args = append(args, "--no-tail") // < 2
-	} else {
-		args = append(args, "-n", strconv.Itoa(n)) // ... + 2 ...
-	}
-	if follow {
-		args = append(args, "-f") // ... + 1 == 6
-	}
-
-	for i := range svcs {
-		args = append(args, "-u", svcs[i]) // this is why 2×
-	}
-
+	args := journalctlArgs(&JournalOptions{Units: svcs, N: n, Follow: follow})
 	return osutilStreamCommand("journalctl", args...)
 }
 
@@ -177,6 +217,10 @@ type Systemd interface {
 	RemoveMountUnitFile(baseDir string) error
 	Mask(service string) error
 	Unmask(service string) error
+	AddSliceUnitFile(name, description string, limits *ResourceLimits) error
+	RemoveSliceUnitFile(name string) error
+	SetUnitResources(unit string, limits *ResourceLimits) error
+	StartTransientScope(name, slice string, pid int, limits *ResourceLimits) error
 }
 
 // A Log is a single entry in the systemd journal
@@ -186,9 +230,16 @@ const (
 	// the default target for systemd units that we generate
 	ServicesTarget = "multi-user.target"
 
+	// the default target for systemd user units that we generate
+	UserServicesTarget = "default.target"
+
 	// the target prerequisite for systemd units we generate
 	PrerequisiteTarget = "network.target"
 
+	// the target after which the users and groups of the system,
+	// including the ones from libnss-extrausers, can be looked up
+	UserLookupTarget = "nss-user-lookup.target"
+
 	// the default target for systemd socket units that we generate
 	SocketsTarget = "sockets.target"
 
@@ -205,6 +256,15 @@ func New(rootDir string, mode InstanceMode, rep reporter) Systemd {
 	return &systemd{rootDir: rootDir, mode: mode, reporter: rep}
 }
 
+// NewUnderUser returns a Systemd that controls the instance of systemd
+// managing the session of the user with the given uid, which is
+// reached over that user's session bus. Unlike with UserMode, the
+// calling process need not be part of the user's session, but it needs
+// the privileges to act as that user.
+func NewUnderUser(rootDir string, uid sys.UserID, rep reporter) Systemd {
+	return &systemd{rootDir: rootDir, mode: UserMode, reporter: rep, uid: uid, underUser: true}
+}
+
 // InstanceMode determines which instance of systemd to control.
 //
 // SystemMode refers to the system instance (i.e. pid 1).  UserMode
@@ -227,6 +287,10 @@ type systemd struct {
 	rootDir  string
 	reporter reporter
 	mode     InstanceMode
+
+	// uid is the user whose instance is controlled, if underUser
+	uid       sys.UserID
+	underUser bool
 }
 
 func (s *systemd) systemctl(args ...string) ([]byte, error) {
@@ -239,6 +303,9 @@ func (s *systemd) systemctl(args ...string) ([]byte, error) {
 	default:
 		panic("unknown InstanceMode")
 	}
+	if s.underUser {
+		return systemctlAsUserCmd(s.uid, args...)
+	}
 	return systemctlCmd(args...)
 }
 
@@ -462,9 +529,6 @@ func (s *systemd) Status(unitNames ...string) ([]*UnitStatus, error) {
 
 // IsEnabled checkes whether the given service is enabled
 func (s *systemd) IsEnabled(serviceName string) (bool, error) {
-	if s.mode == GlobalUserMode {
-		panic("cannot call is-enabled with GlobalUserMode")
-	}
 	_, err := s.systemctl("--root", s.rootDir, "is-enabled", serviceName)
 	if err == nil {
 		return true, nil
@@ -634,6 +698,19 @@ func (l Log) PID() string {
 	return "-"
 }
 
+// Cursor of the Log, if any; otherwise, "".
+func (l Log) Cursor() string {
+	return l["__CURSOR"]
+}
+
+// Priority is the syslog priority of the Log, if any; otherwise, -1.
+func (l Log) Priority() int {
+	if prio, err := strconv.Atoi(l["PRIORITY"]); err == nil {
+		return prio
+	}
+	return -1
+}
+
 // MountUnitPath returns the path of a {,auto}mount unit
 func MountUnitPath(baseDir string) string {
 	escapedPath := EscapeUnitNamePath(baseDir)
@@ -647,7 +724,14 @@ func (s *systemd) AddMountUnitFile(snapName, revision, what, where, fstype strin
 
 	options := []string{"nodev"}
 	if fstype == "squashfs" {
-		newFsType, newOptions, err := squashfs.FsType()
+		// images with a compression the kernel cannot handle may
+		// still be mounted with fuse
+		var compression string
+		if !osutil.IsDirectory(what) {
+			// an unreadable image fails to mount anyway
+			compression, _ = squashfs.Compression(what)
+		}
+		newFsType, newOptions, err := squashfs.FsTypeForCompression(compression)
 		if err != nil {
 			return "", err
 		}
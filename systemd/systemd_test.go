@@ -25,6 +25,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"os/user"
 	"path/filepath"
 	"strconv"
 	"testing"
@@ -35,6 +36,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/osutil/squashfs"
+	"github.com/snapcore/snapd/osutil/sys"
 	"github.com/snapcore/snapd/sandbox/selinux"
 	"github.com/snapcore/snapd/testutil"
 
@@ -571,6 +573,31 @@ WantedBy=multi-user.target
 	})
 }
 
+func (s *SystemdTestSuite) TestAddMountUnitZstdFallsBackToFuse(c *C) {
+	restore := squashfs.MockNeedsFuse(false)
+	defer restore()
+	restore = squashfs.MockKernelSupportsZstd(false)
+	defer restore()
+	fuseCmd := testutil.MockCommand(c, "squashfuse", "")
+	defer fuseCmd.Restore()
+
+	// a squashfs superblock with the zstd compression id
+	sb := make([]byte, 96)
+	copy(sb, "hsqs")
+	sb[20] = 6
+	mockSnapPath := filepath.Join(c.MkDir(), "foo_1.0.snap")
+	c.Assert(ioutil.WriteFile(mockSnapPath, sb, 0644), IsNil)
+
+	mountUnitName, err := New("", SystemMode, nil).AddMountUnitFile("foo", "42", mockSnapPath, "/snap/snapname/123", "squashfs")
+	c.Assert(err, IsNil)
+	defer os.Remove(mountUnitName)
+
+	c.Check(filepath.Join(dirs.SnapServicesDir, mountUnitName), testutil.FileContains, `
+Type=fuse.squashfuse
+Options=nodev,ro,x-gdu.hide,allow_other
+`)
+}
+
 func (s *SystemdTestSuite) TestWriteSELinuxMountUnit(c *C) {
 	restore := selinux.MockIsEnabled(func() (bool, error) { return true, nil })
 	defer restore()
@@ -806,6 +833,49 @@ func (s *SystemdTestSuite) TestUserMode(c *C) {
 	c.Check(s.argses[1], DeepEquals, []string{"--user", "start", "foo"})
 }
 
+func (s *SystemdTestSuite) TestUnderUser(c *C) {
+	var uids []sys.UserID
+	restore := MockSystemctlAsUser(func(uid sys.UserID, args ...string) ([]byte, error) {
+		uids = append(uids, uid)
+		return s.myRun(args...)
+	})
+	defer restore()
+
+	rootDir := dirs.GlobalRootDir
+	sysd := NewUnderUser(rootDir, 1000, nil)
+
+	c.Assert(sysd.Enable("foo"), IsNil)
+	c.Check(s.argses[0], DeepEquals, []string{"--user", "--root", rootDir, "enable", "foo"})
+	c.Assert(sysd.Start("foo"), IsNil)
+	c.Check(s.argses[1], DeepEquals, []string{"--user", "start", "foo"})
+	active, err := sysd.IsActive("foo")
+	c.Assert(err, IsNil)
+	c.Check(active, Equals, true)
+	c.Check(s.argses[2], DeepEquals, []string{"--user", "--root", rootDir, "is-active", "foo"})
+	c.Check(uids, DeepEquals, []sys.UserID{1000, 1000, 1000})
+}
+
+func (s *SystemdTestSuite) TestSystemctlAsUserNoSessionBus(c *C) {
+	restore := MockUserLookupId(func(uid string) (*user.User, error) {
+		c.Check(uid, Equals, "1000")
+		return &user.User{Uid: "1000", Gid: "1000", Username: "foo", HomeDir: "/home/foo"}, nil
+	})
+	defer restore()
+
+	_, err := SystemctlAsUserCmd(1000, "--user", "start", "foo")
+	c.Check(err, ErrorMatches, `cannot find session bus of user "foo"`)
+}
+
+func (s *SystemdTestSuite) TestSystemctlAsUserUnknownUser(c *C) {
+	restore := MockUserLookupId(func(uid string) (*user.User, error) {
+		return nil, user.UnknownUserIdError(1000)
+	})
+	defer restore()
+
+	_, err := SystemctlAsUserCmd(1000, "--user", "start", "foo")
+	c.Check(err, ErrorMatches, "user: unknown userid 1000")
+}
+
 func (s *SystemdTestSuite) TestGlobalUserMode(c *C) {
 	rootDir := dirs.GlobalRootDir
 	sysd := New(rootDir, GlobalUserMode, nil)
@@ -818,6 +888,9 @@ func (s *SystemdTestSuite) TestGlobalUserMode(c *C) {
 	c.Check(s.argses[2], DeepEquals, []string{"--user", "--global", "--root", rootDir, "mask", "foo"})
 	c.Assert(sysd.Unmask("foo"), IsNil)
 	c.Check(s.argses[3], DeepEquals, []string{"--user", "--global", "--root", rootDir, "unmask", "foo"})
+	_, err := sysd.IsEnabled("foo")
+	c.Assert(err, IsNil)
+	c.Check(s.argses[4], DeepEquals, []string{"--user", "--global", "--root", rootDir, "is-enabled", "foo"})
 
 	// Commands that don't make sense for GlobalUserMode panic
 	c.Check(sysd.DaemonReload, Panics, "cannot call daemon-reload with GlobalUserMode")
@@ -827,6 +900,5 @@ func (s *SystemdTestSuite) TestGlobalUserMode(c *C) {
 	c.Check(func() { sysd.Restart("foo", 0) }, Panics, "cannot call restart with GlobalUserMode")
 	c.Check(func() { sysd.Kill("foo", "HUP", "") }, Panics, "cannot call kill with GlobalUserMode")
 	c.Check(func() { sysd.Status("foo") }, Panics, "cannot call status with GlobalUserMode")
-	c.Check(func() { sysd.IsEnabled("foo") }, Panics, "cannot call is-enabled with GlobalUserMode")
 	c.Check(func() { sysd.IsActive("foo") }, Panics, "cannot call is-active with GlobalUserMode")
 }
@@ -0,0 +1,380 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timeutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CalendarValue is a value, or a range of values, of a component of a
+// calendar event, optionally repeated every Step.
+type CalendarValue struct {
+	Start int
+	// End is the last value of a range, for single values it is
+	// the same as Start, it is -1 for an open ended repetition
+	// (e.g. "0/15")
+	End  int
+	Step int
+}
+
+// CalendarEvent is an event in the systemd calendar syntax, see
+// systemd.time(7). Empty components match any value.
+type CalendarEvent struct {
+	Weekdays []time.Weekday
+
+	Year  []CalendarValue
+	Month []CalendarValue
+	Day   []CalendarValue
+	// LastDays indicates that Day counts from the end of the
+	// month (e.g. "*-02~03").
+	LastDays bool
+
+	Hour   []CalendarValue
+	Minute []CalendarValue
+	Second []CalendarValue
+
+	// Location is the name of the timezone of the event, the local
+	// timezone is used if it is empty.
+	Location string
+}
+
+var calendarShorthands = map[string]string{
+	"minutely":     "*-*-* *:*:00",
+	"hourly":       "*-*-* *:00:00",
+	"daily":        "*-*-* 00:00:00",
+	"monthly":      "*-*-01 00:00:00",
+	"weekly":       "Mon *-*-* 00:00:00",
+	"yearly":       "*-01-01 00:00:00",
+	"annually":     "*-01-01 00:00:00",
+	"quarterly":    "*-01,04,07,10-01 00:00:00",
+	"semiannually": "*-01,07-01 00:00:00",
+}
+
+var calendarWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// ParseCalendarEvents parses a list of calendar events separated by
+// ";", e.g. "Mon..Fri 10:00;Sat,Sun 12:00".
+func ParseCalendarEvents(s string) ([]*CalendarEvent, error) {
+	var events []*CalendarEvent
+	for _, spec := range strings.Split(s, ";") {
+		event, err := ParseCalendarEvent(spec)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// ParseCalendarEvent parses an event in the systemd calendar syntax:
+//
+//	[weekdays] [year-]month-day [hour:minute[:second]] [timezone]
+//
+// Each component but the weekdays is either "*" or a list of values
+// and ranges, e.g. "1,3..5", that can be repeated every step, e.g.
+// "0/15" or "8..18/2". The day can be counted from the end of the month
+// by using "~" in place of "-" before it, and weekdays are given as
+// lists and ranges of their names, e.g. "Mon..Fri,Sun". The date or the
+// time can be omitted, and so can the seconds, in which case they
+// default to "*-*-*" and "00:00:00" respectively. The shorthands
+// minutely, hourly, daily, weekly, monthly, quarterly, semiannually,
+// yearly and annually are supported as well.
+func ParseCalendarEvent(s string) (*CalendarEvent, error) {
+	event, err := parseCalendarEvent(s)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse calendar event %q: %v", s, err)
+	}
+	return event, nil
+}
+
+func parseCalendarEvent(s string) (*CalendarEvent, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, errors.New("empty event")
+	}
+	if expanded, ok := calendarShorthands[strings.ToLower(fields[0])]; ok {
+		fields = append(strings.Fields(expanded), fields[1:]...)
+	}
+
+	var event CalendarEvent
+	if last := fields[len(fields)-1]; len(fields) > 1 && !isCalendarValueStart(last[0]) && !strings.Contains(last, ",") && !strings.Contains(last, "..") {
+		if _, err := time.LoadLocation(last); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q", last)
+		}
+		event.Location = last
+		fields = fields[:len(fields)-1]
+	}
+
+	if !isCalendarValueStart(fields[0][0]) {
+		weekdays, err := parseCalendarWeekdays(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		event.Weekdays = weekdays
+		fields = fields[1:]
+	}
+
+	var date, clock string
+	for _, field := range fields {
+		switch {
+		case strings.Contains(field, ":") && clock == "":
+			clock = field
+		case !strings.Contains(field, ":") && date == "" && clock == "":
+			date = field
+		default:
+			return nil, fmt.Errorf("unexpected %q", field)
+		}
+	}
+	if date == "" && clock == "" && event.Weekdays == nil {
+		return nil, errors.New("empty event")
+	}
+	if date == "" {
+		date = "*-*-*"
+	}
+	if clock == "" {
+		clock = "00:00:00"
+	}
+
+	if err := event.parseDate(date); err != nil {
+		return nil, err
+	}
+	if err := event.parseClock(clock); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func isCalendarValueStart(c byte) bool {
+	return c == '*' || (c >= '0' && c <= '9')
+}
+
+func parseCalendarWeekday(s string) (time.Weekday, error) {
+	weekday, ok := calendarWeekdays[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("invalid weekday %q", s)
+	}
+	return weekday, nil
+}
+
+func parseCalendarWeekdays(s string) ([]time.Weekday, error) {
+	var weekdays []time.Weekday
+	seen := make(map[time.Weekday]bool, 7)
+	for _, item := range strings.Split(s, ",") {
+		var startStr, endStr string
+		if idx := strings.Index(item, ".."); idx > 0 {
+			startStr, endStr = item[:idx], item[idx+2:]
+		} else if idx := strings.Index(item, "-"); idx > 0 {
+			startStr, endStr = item[:idx], item[idx+1:]
+		} else {
+			startStr, endStr = item, item
+		}
+		start, err := parseCalendarWeekday(startStr)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseCalendarWeekday(endStr)
+		if err != nil {
+			return nil, err
+		}
+		// ranges can wrap around the end of the week
+		for day := start; ; day = (day + 1) % 7 {
+			if !seen[day] {
+				seen[day] = true
+				weekdays = append(weekdays, day)
+			}
+			if day == end {
+				break
+			}
+		}
+	}
+	return weekdays, nil
+}
+
+func (event *CalendarEvent) parseDate(s string) error {
+	var year, month, day string
+	sep := "-"
+	if strings.Contains(s, "~") {
+		sep = "~"
+		event.LastDays = true
+	}
+	idx := strings.LastIndex(s, sep)
+	if idx < 0 {
+		return fmt.Errorf("invalid date %q", s)
+	}
+	day = s[idx+1:]
+	yearMonth := strings.Split(s[:idx], "-")
+	switch len(yearMonth) {
+	case 1:
+		year, month = "*", yearMonth[0]
+	case 2:
+		year, month = yearMonth[0], yearMonth[1]
+	default:
+		return fmt.Errorf("invalid date %q", s)
+	}
+
+	var err error
+	if event.Year, err = parseCalendarComponent(year, "year", 1970, 2199); err != nil {
+		return err
+	}
+	if event.Month, err = parseCalendarComponent(month, "month", 1, 12); err != nil {
+		return err
+	}
+	if event.Day, err = parseCalendarComponent(day, "day", 1, 31); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (event *CalendarEvent) parseClock(s string) error {
+	parts := strings.Split(s, ":")
+	switch len(parts) {
+	case 2:
+		parts = append(parts, "00")
+	case 3:
+	default:
+		return fmt.Errorf("invalid time %q", s)
+	}
+
+	var err error
+	if event.Hour, err = parseCalendarComponent(parts[0], "hour", 0, 23); err != nil {
+		return err
+	}
+	if event.Minute, err = parseCalendarComponent(parts[1], "minute", 0, 59); err != nil {
+		return err
+	}
+	if event.Second, err = parseCalendarComponent(parts[2], "second", 0, 59); err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseCalendarComponent(s, what string, min, max int) ([]CalendarValue, error) {
+	if s == "*" {
+		return nil, nil
+	}
+	parseValue := func(v string) (int, error) {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("invalid %s %q", what, v)
+		}
+		return n, nil
+	}
+
+	var values []CalendarValue
+	for _, item := range strings.Split(s, ",") {
+		var value CalendarValue
+		if idx := strings.Index(item, "/"); idx >= 0 {
+			step, err := strconv.Atoi(item[idx+1:])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid %s repetition %q", what, item)
+			}
+			value.Step = step
+			value.End = -1
+			item = item[:idx]
+		}
+		var err error
+		if idx := strings.Index(item, ".."); idx >= 0 {
+			if value.Start, err = parseValue(item[:idx]); err != nil {
+				return nil, err
+			}
+			if value.End, err = parseValue(item[idx+2:]); err != nil {
+				return nil, err
+			}
+			if value.End < value.Start {
+				return nil, fmt.Errorf("invalid %s range %q", what, item)
+			}
+		} else {
+			if value.Start, err = parseValue(item); err != nil {
+				return nil, err
+			}
+			if value.Step == 0 {
+				value.End = value.Start
+			}
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+var calendarWeekdayNames = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// String returns the event in a form suitable for OnCalendar= of
+// systemd timers. Ranges are expanded into lists of values as not all
+// the supported versions of systemd understand them.
+func (event *CalendarEvent) String() string {
+	var buf bytes.Buffer
+	if len(event.Weekdays) > 0 {
+		names := make([]string, len(event.Weekdays))
+		for i, day := range event.Weekdays {
+			names[i] = calendarWeekdayNames[day]
+		}
+		fmt.Fprintf(&buf, "%s ", strings.Join(names, ","))
+	}
+	daySep := "-"
+	if event.LastDays {
+		daySep = "~"
+	}
+	fmt.Fprintf(&buf, "%s-%s%s%s %s:%s:%s",
+		calendarComponentString(event.Year, "%04d"),
+		calendarComponentString(event.Month, "%02d"),
+		daySep,
+		calendarComponentString(event.Day, "%02d"),
+		calendarComponentString(event.Hour, "%02d"),
+		calendarComponentString(event.Minute, "%02d"),
+		calendarComponentString(event.Second, "%02d"))
+	if event.Location != "" {
+		fmt.Fprintf(&buf, " %s", event.Location)
+	}
+	return buf.String()
+}
+
+func calendarComponentString(values []CalendarValue, format string) string {
+	if len(values) == 0 {
+		return "*"
+	}
+	var items []string
+	for _, value := range values {
+		if value.End < 0 {
+			items = append(items, fmt.Sprintf(format+"/%d", value.Start, value.Step))
+			continue
+		}
+		step := value.Step
+		if step == 0 {
+			step = 1
+		}
+		for n := value.Start; n <= value.End; n += step {
+			items = append(items, fmt.Sprintf(format, n))
+		}
+	}
+	return strings.Join(items, ",")
+}
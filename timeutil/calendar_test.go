@@ -0,0 +1,111 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timeutil_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/timeutil"
+)
+
+type calendarSuite struct{}
+
+var _ = Suite(&calendarSuite{})
+
+func (s *calendarSuite) TestParseCalendarEventHappy(c *C) {
+	for _, t := range []struct {
+		in  string
+		out string
+	}{
+		{"*-*-* 10:00", "*-*-* 10:00:00"},
+		{"10:00", "*-*-* 10:00:00"},
+		{"2020-01-01", "2020-01-01 00:00:00"},
+		{"Mon", "Mon *-*-* 00:00:00"},
+		{"mon..wed,Fri 9:30:15", "Mon,Tue,Wed,Fri *-*-* 09:30:15"},
+		{"Fri-Mon 9:30", "Fri,Sat,Sun,Mon *-*-* 09:30:00"},
+		{"Saturday,sunday *-*-1..3 12:00", "Sat,Sun *-*-01,02,03 12:00:00"},
+		{"*-*-* 8..18/2:00", "*-*-* 08,10,12,14,16,18:00:00"},
+		{"*:0/15", "*-*-* *:00/15:00"},
+		{"*-02~03 23:59", "*-02~03 23:59:00"},
+		{"*-*-* 10:00 UTC", "*-*-* 10:00:00 UTC"},
+		{"Mon UTC", "Mon *-*-* 00:00:00 UTC"},
+		{"daily", "*-*-* 00:00:00"},
+		{"Weekly", "Mon *-*-* 00:00:00"},
+		{"quarterly UTC", "*-01,04,07,10-01 00:00:00 UTC"},
+		{"hourly", "*-*-* *:00:00"},
+	} {
+		event, err := timeutil.ParseCalendarEvent(t.in)
+		c.Assert(err, IsNil, Commentf("%q", t.in))
+		c.Check(event.String(), Equals, t.out, Commentf("%q", t.in))
+	}
+}
+
+func (s *calendarSuite) TestParseCalendarEventFields(c *C) {
+	event, err := timeutil.ParseCalendarEvent("Mon..Wed 2020-*~1,5..7 8..18/2:0/15 UTC")
+	c.Assert(err, IsNil)
+	c.Check(event, DeepEquals, &timeutil.CalendarEvent{
+		Weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday},
+		Year:     []timeutil.CalendarValue{{Start: 2020, End: 2020}},
+		Day:      []timeutil.CalendarValue{{Start: 1, End: 1}, {Start: 5, End: 7}},
+		LastDays: true,
+		Hour:     []timeutil.CalendarValue{{Start: 8, End: 18, Step: 2}},
+		Minute:   []timeutil.CalendarValue{{Start: 0, End: -1, Step: 15}},
+		Second:   []timeutil.CalendarValue{{Start: 0, End: 0}},
+		Location: "UTC",
+	})
+}
+
+func (s *calendarSuite) TestParseCalendarEventUnhappy(c *C) {
+	for _, t := range []struct {
+		in  string
+		err string
+	}{
+		{"", `cannot parse calendar event "": empty event`},
+		{"Foo 10:00", `cannot parse calendar event "Foo 10:00": invalid weekday "Foo"`},
+		{"Mon..Foo", `cannot parse calendar event "Mon..Foo": invalid weekday "Foo"`},
+		{"25:00", `cannot parse calendar event "25:00": invalid hour "25"`},
+		{"10:60", `cannot parse calendar event "10:60": invalid minute "60"`},
+		{"10:00:00:00", `cannot parse calendar event "10:00:00:00": invalid time "10:00:00:00"`},
+		{"*-13-01", `cannot parse calendar event "\*-13-01": invalid month "13"`},
+		{"*-*-32", `cannot parse calendar event "\*-\*-32": invalid day "32"`},
+		{"1-2-3-4", `cannot parse calendar event "1-2-3-4": invalid date "1-2-3-4"`},
+		{"12", `cannot parse calendar event "12": invalid date "12"`},
+		{"*-*-5..1", `cannot parse calendar event "\*-\*-5..1": invalid day range "5..1"`},
+		{"*:0/0", `cannot parse calendar event "\*:0/0": invalid minute repetition "0/0"`},
+		{"10:00 11:00", `cannot parse calendar event "10:00 11:00": unexpected "11:00"`},
+		{"10:00 Not/A_Zone", `cannot parse calendar event "10:00 Not/A_Zone": invalid timezone "Not/A_Zone"`},
+	} {
+		_, err := timeutil.ParseCalendarEvent(t.in)
+		c.Check(err, ErrorMatches, t.err, Commentf("%q", t.in))
+	}
+}
+
+func (s *calendarSuite) TestParseCalendarEvents(c *C) {
+	events, err := timeutil.ParseCalendarEvents("Mon..Fri 10:00;Sat,Sun 12:00")
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 2)
+	c.Check(events[0].String(), Equals, "Mon,Tue,Wed,Thu,Fri *-*-* 10:00:00")
+	c.Check(events[1].String(), Equals, "Sat,Sun *-*-* 12:00:00")
+
+	_, err = timeutil.ParseCalendarEvents("Mon 10:00;;Sat 12:00")
+	c.Check(err, ErrorMatches, `cannot parse calendar event "": empty event`)
+}
@@ -22,6 +22,7 @@ package timeutil
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
 	"math/rand"
 	"regexp"
 	"strconv"
@@ -513,13 +514,19 @@ func (sched *Schedule) Next(last time.Time) ScheduleWindow {
 
 }
 
-func randDur(a, b time.Time) time.Duration {
+// spreadDur returns the duration of the window between a and b within which
+// an event may be spread.
+func spreadDur(a, b time.Time) time.Duration {
 	dur := b.Sub(a)
 	if dur > 5*time.Minute {
 		// doing it this way we still spread really small windows about
 		dur -= 5 * time.Minute
 	}
+	return dur
+}
 
+func randDur(a, b time.Time) time.Duration {
+	dur := spreadDur(a, b)
 	if dur <= 0 {
 		// avoid panic'ing (even if things are probably messed up)
 		return 0
@@ -528,6 +535,20 @@ func randDur(a, b time.Time) time.Duration {
 	return time.Duration(rand.Int63n(int64(dur)))
 }
 
+// seededDur returns a duration within the window between a and b that only
+// depends on the seed, placing the event at the same relative position of
+// every window.
+func seededDur(a, b time.Time, seed string) time.Duration {
+	dur := spreadDur(a, b)
+	if dur <= 0 {
+		return 0
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	return time.Duration(h.Sum64() % uint64(dur))
+}
+
 var (
 	timeNow = time.Now
 )
@@ -536,11 +557,9 @@ func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
-// Next returns the earliest event after last according to the provided
-// schedule but no later than maxDuration since last.
-func Next(schedule []*Schedule, last time.Time, maxDuration time.Duration) time.Duration {
-	now := timeNow()
-
+// nextWindow returns the earliest window after last according to the
+// provided schedule but no later than maxDuration since last.
+func nextWindow(schedule []*Schedule, last time.Time, maxDuration time.Duration) ScheduleWindow {
 	window := ScheduleWindow{
 		Start: last.Add(maxDuration),
 		End:   last.Add(maxDuration).Add(1 * time.Hour),
@@ -552,6 +571,15 @@ func Next(schedule []*Schedule, last time.Time, maxDuration time.Duration) time.
 			window = next
 		}
 	}
+	return window
+}
+
+// Next returns the earliest event after last according to the provided
+// schedule but no later than maxDuration since last.
+func Next(schedule []*Schedule, last time.Time, maxDuration time.Duration) time.Duration {
+	now := timeNow()
+
+	window := nextWindow(schedule, last, maxDuration)
 	if window.Start.Before(now) {
 		return 0
 	}
@@ -565,6 +593,25 @@ func Next(schedule []*Schedule, last time.Time, maxDuration time.Duration) time.
 
 }
 
+// NextWithSeed is like Next, but events of spread windows are placed at a
+// position derived from the seed rather than a random one. Given a seed that
+// is stable for a device, like its serial, the device keeps its position in
+// the windows, while a fleet of devices gets staggered across them.
+func NextWithSeed(schedule []*Schedule, last time.Time, maxDuration time.Duration, seed string) time.Duration {
+	now := timeNow()
+
+	window := nextWindow(schedule, last, maxDuration)
+	when := window.Start
+	if window.Spread {
+		when = when.Add(seededDur(window.Start, window.End, seed))
+	}
+	if when.Before(now) {
+		return 0
+	}
+
+	return when.Sub(now)
+}
+
 var weekdayMap = map[string]time.Weekday{
 	"sun": time.Sunday,
 	"mon": time.Monday,
@@ -1232,6 +1232,53 @@ func (ts *timeutilSuite) TestTimeZero(c *C) {
 	}
 }
 
+func (ts *timeutilSuite) TestScheduleNextWithSeed(c *C) {
+	const shortForm = "2006-01-02 15:04"
+
+	schedule, err := timeutil.ParseSchedule("mon,10:00~12:00,,fri,15:00~17:00")
+	c.Assert(err, IsNil)
+	// sun 22:00
+	last, err := time.ParseInLocation(shortForm, "2017-02-05 22:00", time.Local)
+	c.Assert(err, IsNil)
+
+	var fakeNow time.Time
+	restorer := timeutil.MockTimeNow(func() time.Time {
+		return fakeNow
+	})
+	defer restorer()
+	mockNow := func(now string) {
+		fakeNow, err = time.ParseInLocation(shortForm, now, time.Local)
+		c.Assert(err, IsNil)
+	}
+
+	// mon 9:00
+	mockNow("2017-02-06 9:00")
+	next := timeutil.NextWithSeed(schedule, last, 24*time.Hour, "serial-1")
+	// spread within the window, minus the 5 minutes margin
+	c.Check(next >= 1*time.Hour, Equals, true, Commentf("%v", next))
+	c.Check(next < 2*time.Hour+55*time.Minute, Equals, true, Commentf("%v", next))
+	// the position within the window is stable for the seed
+	for i := 0; i < 10; i++ {
+		c.Check(timeutil.NextWithSeed(schedule, last, 24*time.Hour, "serial-1"), Equals, next)
+	}
+	// but devices get staggered
+	c.Check(timeutil.NextWithSeed(schedule, last, 24*time.Hour, "serial-2"), Not(Equals), next)
+
+	// same position when already inside the window
+	mockNow("2017-02-06 10:00")
+	c.Check(timeutil.NextWithSeed(schedule, last, 24*time.Hour, "serial-1"), Equals, next-time.Hour)
+
+	// position within the window was missed already
+	mockNow("2017-02-06 11:58")
+	c.Check(timeutil.NextWithSeed(schedule, last, 24*time.Hour, "serial-1"), Equals, time.Duration(0))
+
+	// windows without spread are not affected by the seed
+	schedule, err = timeutil.ParseSchedule("mon,10:00-12:00")
+	c.Assert(err, IsNil)
+	mockNow("2017-02-06 9:00")
+	c.Check(timeutil.NextWithSeed(schedule, last, 24*time.Hour, "serial-1"), Equals, time.Hour)
+}
+
 func (ts *timeutilSuite) TestMonthNext(c *C) {
 	const shortForm = "2006-01-02"
 	for _, t := range []struct {
@@ -0,0 +1,108 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timings
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// HandlerTimings aggregates the run times of the handlers of a task kind,
+// either the do or the undo ones, across all changes.
+type HandlerTimings struct {
+	Kind   string `json:"kind"`
+	Status string `json:"status"`
+	// Count is the number of times the handler was run.
+	Count int `json:"count"`
+	// Total and Max are the total and the longest run time of the handler.
+	Total time.Duration `json:"total"`
+	Max   time.Duration `json:"max"`
+}
+
+// Average returns the average run time of the handler.
+func (h *HandlerTimings) Average() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Total / time.Duration(h.Count)
+}
+
+// RecordHandlerRun accounts a run of the handler of the given task, in
+// the given status, that took dur. It is meant to be registered with
+// the task runner via ObserveRuns.
+// It's responsibility of the caller to lock the state before calling this function.
+func RecordHandlerRun(t *state.Task, status state.Status, dur time.Duration) {
+	st := t.State()
+	var handlerTimings map[string]*HandlerTimings
+	if err := st.Get("handler-timings", &handlerTimings); err != nil && err != state.ErrNoState {
+		logger.Noticef("could not get handler timings from the state: %v", err)
+		return
+	}
+	if handlerTimings == nil {
+		handlerTimings = make(map[string]*HandlerTimings)
+	}
+
+	key := fmt.Sprintf("%s/%s", t.Kind(), status)
+	h := handlerTimings[key]
+	if h == nil {
+		h = &HandlerTimings{Kind: t.Kind(), Status: status.String()}
+		handlerTimings[key] = h
+	}
+	h.Count++
+	h.Total += dur
+	if dur > h.Max {
+		h.Max = dur
+	}
+	st.Set("handler-timings", handlerTimings)
+}
+
+type byTotalTime []*HandlerTimings
+
+func (h byTotalTime) Len() int      { return len(h) }
+func (h byTotalTime) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h byTotalTime) Less(i, j int) bool {
+	if h[i].Total != h[j].Total {
+		return h[i].Total > h[j].Total
+	}
+	if h[i].Kind != h[j].Kind {
+		return h[i].Kind < h[j].Kind
+	}
+	return h[i].Status < h[j].Status
+}
+
+// GetHandlerTimings returns the aggregated run times of all the
+// handlers that were run, the ones that took the longest in total first.
+// It's responsibility of the caller to lock the state before calling this function.
+func GetHandlerTimings(st *state.State) ([]*HandlerTimings, error) {
+	var handlerTimings map[string]*HandlerTimings
+	if err := st.Get("handler-timings", &handlerTimings); err != nil && err != state.ErrNoState {
+		return nil, fmt.Errorf("could not get handler timings from the state: %v", err)
+	}
+	result := make([]*HandlerTimings, 0, len(handlerTimings))
+	for _, h := range handlerTimings {
+		result = append(result, h)
+	}
+	sort.Sort(byTotalTime(result))
+	return result, nil
+}
@@ -439,3 +439,28 @@ func (s *timingsSuite) TestGet(c *C) {
 		},
 	})
 }
+
+func (s *timingsSuite) TestHandlerTimings(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	handlerTimings, err := timings.GetHandlerTimings(s.st)
+	c.Assert(err, IsNil)
+	c.Check(handlerTimings, HasLen, 0)
+
+	foo := s.st.NewTask("foo", "...")
+	bar := s.st.NewTask("bar", "...")
+	timings.RecordHandlerRun(foo, state.DoingStatus, time.Second)
+	timings.RecordHandlerRun(foo, state.DoingStatus, 3*time.Second)
+	timings.RecordHandlerRun(bar, state.DoingStatus, 5*time.Second)
+	timings.RecordHandlerRun(foo, state.UndoingStatus, time.Second)
+
+	handlerTimings, err = timings.GetHandlerTimings(s.st)
+	c.Assert(err, IsNil)
+	c.Check(handlerTimings, DeepEquals, []*timings.HandlerTimings{
+		{Kind: "bar", Status: "Doing", Count: 1, Total: 5 * time.Second, Max: 5 * time.Second},
+		{Kind: "foo", Status: "Doing", Count: 2, Total: 4 * time.Second, Max: 3 * time.Second},
+		{Kind: "foo", Status: "Undoing", Count: 1, Total: time.Second, Max: time.Second},
+	})
+	c.Check(handlerTimings[1].Average(), Equals, 2*time.Second)
+}
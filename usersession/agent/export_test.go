@@ -24,7 +24,8 @@ import (
 )
 
 var (
-	SessionInfoCmd = sessionInfoCmd
+	SessionInfoCmd    = sessionInfoCmd
+	ServiceControlCmd = serviceControlCmd
 )
 
 func MockUcred(ucred *syscall.Ucred, err error) (restore func()) {
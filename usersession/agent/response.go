@@ -93,7 +93,8 @@ func (r *resp) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
 type errorKind string
 
 const (
-	errorKindLoginRequired = errorKind("login-required")
+	errorKindLoginRequired  = errorKind("login-required")
+	errorKindServiceControl = errorKind("service-control")
 )
 
 type errorValue interface{}
@@ -154,6 +155,20 @@ func makeErrorResponder(status int) errorResponder {
 // e.g., InternalError("something broke: %v", err), etc.
 type errorResponder func(string, ...interface{}) Response
 
+// serviceControlError builds an error Response reporting the
+// per-service errors of a service control request.
+func serviceControlError(message string, value errorValue) Response {
+	return &resp{
+		Type: ResponseTypeError,
+		Result: &errorResult{
+			Message: message,
+			Kind:    errorKindServiceControl,
+			Value:   value,
+		},
+		Status: 500,
+	}
+}
+
 // standard error responses
 var (
 	Unauthorized     = makeErrorResponder(401)
@@ -20,12 +20,24 @@
 package agent
 
 import (
+	"encoding/json"
+	"fmt"
+	"mime"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/timeout"
 )
 
 var restApi = []*Command{
 	rootCmd,
 	sessionInfoCmd,
+	serviceControlCmd,
 }
 
 var (
@@ -38,6 +50,11 @@ var (
 		Path: "/v1/session-info",
 		GET:  sessionInfo,
 	}
+
+	serviceControlCmd = &Command{
+		Path: "/v1/service-control",
+		POST: postServiceControl,
+	}
 )
 
 func sessionInfo(c *Command, r *http.Request) Response {
@@ -46,3 +63,118 @@ func sessionInfo(c *Command, r *http.Request) Response {
 	}
 	return SyncResponse(m)
 }
+
+type serviceInstruction struct {
+	Action   string   `json:"action"`
+	Services []string `json:"services"`
+}
+
+var (
+	// serviceControlLock serialises the control of the user
+	// services of the session
+	serviceControlLock sync.Mutex
+	stopTimeout        = time.Duration(timeout.DefaultTimeout)
+)
+
+func validateUnits(units []string) error {
+	for _, unit := range units {
+		// only the units generated by snapd can be controlled
+		if !strings.HasPrefix(unit, "snap.") || strings.ContainsRune(unit, '/') {
+			return fmt.Errorf("cannot control unit %q", unit)
+		}
+	}
+	return nil
+}
+
+func serviceStart(inst *serviceInstruction, sysd systemd.Systemd) Response {
+	if err := validateUnits(inst.Services); err != nil {
+		return BadRequest("%v", err)
+	}
+	startErrors := make(map[string]string)
+	var started []string
+	for _, service := range inst.Services {
+		if err := sysd.Start(service); err != nil {
+			startErrors[service] = err.Error()
+			break
+		}
+		started = append(started, service)
+	}
+	if len(startErrors) == 0 {
+		return SyncResponse(nil)
+	}
+
+	// roll back the services started before the failure
+	stopErrors := make(map[string]string)
+	for i := len(started) - 1; i >= 0; i-- {
+		if err := sysd.Stop(started[i], stopTimeout); err != nil {
+			stopErrors[started[i]] = err.Error()
+		}
+	}
+	return serviceControlError("some user services failed to start", map[string]interface{}{
+		"start-errors": startErrors,
+		"stop-errors":  stopErrors,
+	})
+}
+
+func serviceStop(inst *serviceInstruction, sysd systemd.Systemd) Response {
+	if err := validateUnits(inst.Services); err != nil {
+		return BadRequest("%v", err)
+	}
+	stopErrors := make(map[string]string)
+	for _, service := range inst.Services {
+		if err := sysd.Stop(service, stopTimeout); err != nil {
+			stopErrors[service] = err.Error()
+		}
+	}
+	if len(stopErrors) == 0 {
+		return SyncResponse(nil)
+	}
+	return serviceControlError("some user services failed to stop", map[string]interface{}{
+		"stop-errors": stopErrors,
+	})
+}
+
+func serviceDaemonReload(inst *serviceInstruction, sysd systemd.Systemd) Response {
+	if len(inst.Services) != 0 {
+		return BadRequest("daemon-reload should not be called with any services")
+	}
+	if err := sysd.DaemonReload(); err != nil {
+		return InternalError("cannot reload daemon: %v", err)
+	}
+	return SyncResponse(nil)
+}
+
+var serviceInstructionDispTable = map[string]func(*serviceInstruction, systemd.Systemd) Response{
+	"start":         serviceStart,
+	"stop":          serviceStop,
+	"daemon-reload": serviceDaemonReload,
+}
+
+func postServiceControl(c *Command, r *http.Request) Response {
+	contentType := r.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != "application/json" {
+		return BadRequest("unknown content type: %s", contentType)
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	var inst serviceInstruction
+	if err := decoder.Decode(&inst); err != nil {
+		return BadRequest("cannot decode request body into service instruction: %v", err)
+	}
+	impl := serviceInstructionDispTable[inst.Action]
+	if impl == nil {
+		return BadRequest("unknown action %q", inst.Action)
+	}
+
+	serviceControlLock.Lock()
+	defer serviceControlLock.Unlock()
+
+	sysd := systemd.New(dirs.GlobalRootDir, systemd.UserMode, noticeReporter{})
+	return impl(&inst, sysd)
+}
+
+type noticeReporter struct{}
+
+func (noticeReporter) Notify(msg string) {
+	logger.Noticef("%s", msg)
+}
@@ -20,29 +20,47 @@
 package agent_test
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/http/httptest"
 	"os"
+	"time"
 
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/testutil"
 	"github.com/snapcore/snapd/usersession/agent"
 )
 
-type restSuite struct{}
+type restSuite struct {
+	testutil.BaseTest
+	sysdLog [][]string
+}
 
 var _ = Suite(&restSuite{})
 
 func (s *restSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
 	dirs.SetRootDir(c.MkDir())
 	xdgRuntimeDir := fmt.Sprintf("%s/%d", dirs.XdgRuntimeDirBase, os.Getuid())
 	c.Assert(os.MkdirAll(xdgRuntimeDir, 0700), IsNil)
+
+	s.sysdLog = nil
+	s.AddCleanup(systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		s.sysdLog = append(s.sysdLog, args)
+		return []byte("ActiveState=inactive\n"), nil
+	}))
+	s.AddCleanup(systemd.MockStopDelays(time.Millisecond, 25*time.Second))
 }
 
 func (s *restSuite) TearDownTest(c *C) {
 	dirs.SetRootDir("")
+	s.BaseTest.TearDownTest(c)
 }
 
 type resp struct {
@@ -74,3 +92,109 @@ func (s *restSuite) TestSessionInfo(c *C) {
 		"version": "42b1",
 	})
 }
+
+func (s *restSuite) postServiceControl(c *C, body string) (int, map[string]interface{}) {
+	req, err := http.NewRequest("POST", "/v1/service-control", bytes.NewBufferString(body))
+	c.Assert(err, IsNil)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	agent.ServiceControlCmd.POST(agent.ServiceControlCmd, req).ServeHTTP(rec, req)
+
+	var rsp resp
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &rsp), IsNil)
+	result, _ := rsp.Result.(map[string]interface{})
+	return rec.Code, result
+}
+
+func (s *restSuite) TestServiceControl(c *C) {
+	// the agent.ServiceControl end point only supports POST requests
+	c.Check(agent.ServiceControlCmd.GET, IsNil)
+	c.Check(agent.ServiceControlCmd.PUT, IsNil)
+	c.Check(agent.ServiceControlCmd.DELETE, IsNil)
+	c.Assert(agent.ServiceControlCmd.POST, NotNil)
+
+	c.Check(agent.ServiceControlCmd.Path, Equals, "/v1/service-control")
+}
+
+func (s *restSuite) TestServiceControlDaemonReload(c *C) {
+	code, _ := s.postServiceControl(c, `{"action":"daemon-reload"}`)
+	c.Check(code, Equals, 200)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"--user", "daemon-reload"},
+	})
+}
+
+func (s *restSuite) TestServiceControlStartStop(c *C) {
+	code, _ := s.postServiceControl(c, `{"action":"start","services":["snap.foo.service", "snap.bar.timer"]}`)
+	c.Check(code, Equals, 200)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"--user", "start", "snap.foo.service"},
+		{"--user", "start", "snap.bar.timer"},
+	})
+
+	s.sysdLog = nil
+	code, _ = s.postServiceControl(c, `{"action":"stop","services":["snap.foo.service"]}`)
+	c.Check(code, Equals, 200)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"--user", "stop", "snap.foo.service"},
+		{"--user", "show", "--property=ActiveState", "snap.foo.service"},
+	})
+}
+
+func (s *restSuite) TestServiceControlStartFailureRollsBack(c *C) {
+	restore := systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		s.sysdLog = append(s.sysdLog, args)
+		if args[1] == "start" && args[2] == "snap.bar.service" {
+			return nil, errors.New("mock error")
+		}
+		return []byte("ActiveState=inactive\n"), nil
+	})
+	defer restore()
+
+	code, result := s.postServiceControl(c, `{"action":"start","services":["snap.foo.service", "snap.bar.service", "snap.baz.service"]}`)
+	c.Check(code, Equals, 500)
+	c.Check(result, DeepEquals, map[string]interface{}{
+		"message": "some user services failed to start",
+		"kind":    "service-control",
+		"value": map[string]interface{}{
+			"start-errors": map[string]interface{}{
+				"snap.bar.service": "mock error",
+			},
+			"stop-errors": map[string]interface{}{},
+		},
+	})
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"--user", "start", "snap.foo.service"},
+		{"--user", "start", "snap.bar.service"},
+		{"--user", "stop", "snap.foo.service"},
+		{"--user", "show", "--property=ActiveState", "snap.foo.service"},
+	})
+}
+
+func (s *restSuite) TestServiceControlErrors(c *C) {
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action":"start","services":["dbus.service"]}`, `cannot control unit "dbus.service"`},
+		{`{"action":"stop","services":["snap.foo/../dbus.service"]}`, `cannot control unit "snap.foo/../dbus.service"`},
+		{`{"action":"daemon-reload","services":["snap.foo.service"]}`, `daemon-reload should not be called with any services`},
+		{`{"action":"potato"}`, `unknown action "potato"`},
+		{`{"action":`, `cannot decode request body into service instruction: unexpected EOF`},
+	} {
+		code, result := s.postServiceControl(c, t.body)
+		c.Check(code, Equals, 400, Commentf(t.body))
+		c.Check(result["message"], Equals, t.err, Commentf(t.body))
+	}
+	c.Check(s.sysdLog, HasLen, 0)
+}
+
+func (s *restSuite) TestServiceControlBadContentType(c *C) {
+	req, err := http.NewRequest("POST", "/v1/service-control", bytes.NewBufferString(`{"action":"daemon-reload"}`))
+	c.Assert(err, IsNil)
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	agent.ServiceControlCmd.POST(agent.ServiceControlCmd, req).ServeHTTP(rec, req)
+	c.Check(rec.Code, Equals, 400)
+	c.Check(s.sysdLog, HasLen, 0)
+}
@@ -0,0 +1,210 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package client talks to the session agents running in the sessions
+// of the logged-in users.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+)
+
+// dialSessionAgent connects to the session agent of the user whose
+// uid is the host part of the address.
+func dialSessionAgent(network, address string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	socket := filepath.Join(dirs.XdgRuntimeDirBase, host, "snapd-session-agent.socket")
+	return net.Dial("unix", socket)
+}
+
+// Client talks to the session agents of all the logged-in users.
+type Client struct {
+	doer *http.Client
+}
+
+// New returns a new session agent client.
+func New() *Client {
+	transport := &http.Transport{
+		Dial:              dialSessionAgent,
+		DisableKeepAlives: true,
+	}
+	return &Client{
+		doer: &http.Client{Transport: transport},
+	}
+}
+
+// Error is an error reported by a session agent.
+type Error struct {
+	Kind    string      `json:"kind"`
+	Message string      `json:"message"`
+	Value   interface{} `json:"value"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+type response struct {
+	uid int
+	err error
+}
+
+// sessionAgentUids returns the uids of the users with a session agent.
+func sessionAgentUids() ([]int, error) {
+	sockets, err := filepath.Glob(filepath.Join(dirs.XdgRuntimeDirBase, "*", "snapd-session-agent.socket"))
+	if err != nil {
+		return nil, err
+	}
+	uids := make([]int, 0, len(sockets))
+	for _, socket := range sockets {
+		uid, err := strconv.Atoi(filepath.Base(filepath.Dir(socket)))
+		if err != nil {
+			// not a directory named after a uid
+			continue
+		}
+		uids = append(uids, uid)
+	}
+	sort.Ints(uids)
+	return uids, nil
+}
+
+func (client *Client) doOne(ctx context.Context, uid int, method, urlpath string, body []byte) *response {
+	u := url.URL{
+		Scheme: "http",
+		Host:   strconv.Itoa(uid),
+		Path:   urlpath,
+	}
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return &response{uid: uid, err: err}
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := client.doer.Do(req)
+	if err != nil {
+		if uerr, ok := err.(*url.Error); ok {
+			if operr, ok := uerr.Err.(*net.OpError); ok && operr.Op == "dial" {
+				// the session of the user is gone
+				logger.Debugf("cannot connect to the session agent of uid %d: %v", uid, err)
+				return nil
+			}
+		}
+		return &response{uid: uid, err: err}
+	}
+	defer httpResp.Body.Close()
+
+	var rsp struct {
+		Type   string          `json:"type"`
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(httpResp.Body).Decode(&rsp); err != nil {
+		return &response{uid: uid, err: fmt.Errorf("cannot decode response: %v", err)}
+	}
+	if rsp.Type == "error" {
+		var agentErr Error
+		if err := json.Unmarshal(rsp.Result, &agentErr); err != nil {
+			return &response{uid: uid, err: fmt.Errorf("cannot decode error: %v", err)}
+		}
+		return &response{uid: uid, err: &agentErr}
+	}
+	return &response{uid: uid}
+}
+
+// doMany sends the same request to the session agents of all the
+// logged-in users concurrently.
+func (client *Client) doMany(ctx context.Context, method, urlpath string, body []byte) ([]*response, error) {
+	uids, err := sessionAgentUids()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*response, len(uids))
+	var wg sync.WaitGroup
+	for i, uid := range uids {
+		wg.Add(1)
+		go func(i, uid int) {
+			defer wg.Done()
+			responses[i] = client.doOne(ctx, uid, method, urlpath, body)
+		}(i, uid)
+	}
+	wg.Wait()
+	return responses, nil
+}
+
+func (client *Client) serviceControl(ctx context.Context, action string, services []string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"action":   action,
+		"services": services,
+	})
+	if err != nil {
+		return err
+	}
+	responses, err := client.doMany(ctx, "POST", "/v1/service-control", body)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, rsp := range responses {
+		if rsp == nil || rsp.err == nil {
+			continue
+		}
+		errs = append(errs, fmt.Sprintf("uid %d: %v", rsp.uid, rsp.err))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("cannot %s user services: %s", action, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ServicesDaemonReload asks the session agents to reload the
+// configuration of the systemd instances of the user sessions.
+func (client *Client) ServicesDaemonReload(ctx context.Context) error {
+	return client.serviceControl(ctx, "daemon-reload", nil)
+}
+
+// ServicesStart asks the session agents to start the given user
+// services, in order.
+func (client *Client) ServicesStart(ctx context.Context, services []string) error {
+	return client.serviceControl(ctx, "start", services)
+}
+
+// ServicesStop asks the session agents to stop the given user
+// services.
+func (client *Client) ServicesStop(ctx context.Context, services []string) error {
+	return client.serviceControl(ctx, "stop", services)
+}
@@ -0,0 +1,149 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/usersession/client"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type clientSuite struct {
+	cli *client.Client
+
+	mu       sync.Mutex
+	requests map[string]string
+	handler  func(uid string, w http.ResponseWriter, r *http.Request)
+
+	servers []*http.Server
+}
+
+var _ = Suite(&clientSuite{})
+
+func (s *clientSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.cli = client.New()
+	s.requests = make(map[string]string)
+	s.handler = func(uid string, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"type": "sync", "result": null}`))
+	}
+
+	for _, uid := range []string{"42", "1000"} {
+		s.startAgent(c, uid)
+	}
+}
+
+func (s *clientSuite) TearDownTest(c *C) {
+	for _, srv := range s.servers {
+		srv.Close()
+	}
+	s.servers = nil
+	dirs.SetRootDir("")
+}
+
+func (s *clientSuite) startAgent(c *C, uid string) {
+	dir := filepath.Join(dirs.XdgRuntimeDirBase, uid)
+	c.Assert(os.MkdirAll(dir, 0700), IsNil)
+	l, err := net.Listen("unix", filepath.Join(dir, "snapd-session-agent.socket"))
+	c.Assert(err, IsNil)
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v1/service-control")
+		c.Check(r.Header.Get("Content-Type"), Equals, "application/json")
+		body, err := ioutil.ReadAll(r.Body)
+		c.Check(err, IsNil)
+		s.mu.Lock()
+		s.requests[uid] = string(body)
+		s.mu.Unlock()
+		s.handler(uid, w, r)
+	})}
+	go srv.Serve(l)
+	s.servers = append(s.servers, srv)
+}
+
+func (s *clientSuite) TestServicesDaemonReload(c *C) {
+	err := s.cli.ServicesDaemonReload(context.Background())
+	c.Assert(err, IsNil)
+	c.Check(s.requests, DeepEquals, map[string]string{
+		"42":   `{"action":"daemon-reload","services":null}`,
+		"1000": `{"action":"daemon-reload","services":null}`,
+	})
+}
+
+func (s *clientSuite) TestServicesStartStop(c *C) {
+	err := s.cli.ServicesStart(context.Background(), []string{"snap.foo.service", "snap.bar.service"})
+	c.Assert(err, IsNil)
+	c.Check(s.requests, DeepEquals, map[string]string{
+		"42":   `{"action":"start","services":["snap.foo.service","snap.bar.service"]}`,
+		"1000": `{"action":"start","services":["snap.foo.service","snap.bar.service"]}`,
+	})
+
+	err = s.cli.ServicesStop(context.Background(), []string{"snap.foo.service"})
+	c.Assert(err, IsNil)
+	c.Check(s.requests, DeepEquals, map[string]string{
+		"42":   `{"action":"stop","services":["snap.foo.service"]}`,
+		"1000": `{"action":"stop","services":["snap.foo.service"]}`,
+	})
+}
+
+func (s *clientSuite) TestServicesStartFailure(c *C) {
+	s.handler = func(uid string, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if uid == "42" {
+			w.WriteHeader(500)
+			w.Write([]byte(`{"type": "error", "result": {"kind": "service-control", "message": "some user services failed to start"}}`))
+			return
+		}
+		w.Write([]byte(`{"type": "sync", "result": null}`))
+	}
+
+	err := s.cli.ServicesStart(context.Background(), []string{"snap.foo.service"})
+	c.Check(err, ErrorMatches, `cannot start user services: uid 42: some user services failed to start`)
+}
+
+func (s *clientSuite) TestStaleSocketIgnored(c *C) {
+	// a socket left behind by a session that is gone
+	dir := filepath.Join(dirs.XdgRuntimeDirBase, "1234")
+	c.Assert(os.MkdirAll(dir, 0700), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "snapd-session-agent.socket"), nil, 0600), IsNil)
+
+	err := s.cli.ServicesDaemonReload(context.Background())
+	c.Assert(err, IsNil)
+	c.Check(s.requests, HasLen, 2)
+}
+
+func (s *clientSuite) TestNoSessions(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	err := s.cli.ServicesDaemonReload(context.Background())
+	c.Assert(err, IsNil)
+}
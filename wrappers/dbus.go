@@ -0,0 +1,181 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wrappers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// dbusActivationSnapKey is the key recording the snap that provides a
+// service activation file. Activation files are named after the bus
+// name they activate, this allows finding the files of a given snap.
+const dbusActivationSnapKey = "X-Snap="
+
+// dbusActivationMu serializes updates of the activation files, which
+// unlike most other generated files of different snaps share a
+// namespace.
+var dbusActivationMu sync.Mutex
+
+// dbusActivationName returns the bus and the well-known name a service is
+// activated on through the given slot. The slots a service is activated on
+// have been checked by snap.Validate to be dbus slots of the snap on the
+// bus matching the scope of the daemon.
+func dbusActivationName(slot *snap.SlotInfo) (bus, name string, err error) {
+	if err := slot.Attr("bus", &bus); err != nil {
+		return "", "", err
+	}
+	if err := slot.Attr("name", &name); err != nil {
+		return "", "", err
+	}
+	return bus, name, nil
+}
+
+func dbusActivationFileContent(app *snap.AppInfo, name, bus string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "[D-BUS Service]\n")
+	fmt.Fprintf(&buf, "Name=%s\n", name)
+	fmt.Fprintf(&buf, "Comment=Bus name for snap application %s\n", app.Snap.InstanceName()+"."+app.Name)
+	fmt.Fprintf(&buf, "SystemdService=%s\n", app.ServiceName())
+	// the service is always started by systemd
+	fmt.Fprintf(&buf, "Exec=/bin/false\n")
+	if bus == "system" {
+		fmt.Fprintf(&buf, "User=root\n")
+	}
+	fmt.Fprintf(&buf, "AssumedAppArmorLabel=%s\n", app.SecurityTag())
+	fmt.Fprintf(&buf, "%s%s\n", dbusActivationSnapKey, app.Snap.InstanceName())
+	return buf.Bytes()
+}
+
+// AddSnapDBusActivationFiles writes the D-Bus service activation files
+// for the bus names the services of the given snap are activated on.
+// Services of user daemons are activated on the session bus, the ones
+// of system daemons on the system bus. Activation files of the snap
+// that are no longer needed are removed.
+func AddSnapDBusActivationFiles(s *snap.Info) error {
+	sessionContent := make(map[string]osutil.FileState)
+	systemContent := make(map[string]osutil.FileState)
+	for _, app := range s.Apps {
+		for _, slot := range app.ActivatesOn {
+			bus, name, err := dbusActivationName(slot)
+			if err != nil {
+				return err
+			}
+			content := sessionContent
+			if bus == "system" {
+				content = systemContent
+			}
+			content[name+".service"] = &osutil.MemoryFileState{
+				Content: dbusActivationFileContent(app, name, bus),
+				Mode:    0644,
+			}
+		}
+	}
+
+	dbusActivationMu.Lock()
+	defer dbusActivationMu.Unlock()
+
+	snapName := s.InstanceName()
+	if err := ensureDBusActivationFiles(dirs.SnapDBusSessionServicesDir, snapName, sessionContent); err != nil {
+		return err
+	}
+	if err := ensureDBusActivationFiles(dirs.SnapDBusSystemServicesDir, snapName, systemContent); err != nil {
+		// do not leave the session half of the files behind
+		ensureDBusActivationFiles(dirs.SnapDBusSessionServicesDir, snapName, nil)
+		return err
+	}
+	return nil
+}
+
+// RemoveSnapDBusActivationFiles removes the D-Bus service activation
+// files of the given snap.
+func RemoveSnapDBusActivationFiles(s *snap.Info) error {
+	dbusActivationMu.Lock()
+	defer dbusActivationMu.Unlock()
+
+	for _, dir := range []string{dirs.SnapDBusSessionServicesDir, dirs.SnapDBusSystemServicesDir} {
+		if err := ensureDBusActivationFiles(dir, s.InstanceName(), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureDBusActivationFiles makes the activation files of the given snap
+// in dir match content. Activation files of other snaps are left alone
+// and cannot be replaced.
+func ensureDBusActivationFiles(dir, snapName string, content map[string]osutil.FileState) error {
+	existing, err := filepath.Glob(filepath.Join(dir, "*.service"))
+	if err != nil {
+		return err
+	}
+	var globs []string
+	for _, path := range existing {
+		owner, err := dbusActivationFileSnap(path)
+		if err != nil {
+			return err
+		}
+		base := filepath.Base(path)
+		switch {
+		case owner == snapName:
+			globs = append(globs, base)
+		case content[base] != nil:
+			return fmt.Errorf("cannot provide service activation file %q, it is provided by snap %q", base, owner)
+		}
+	}
+	for name := range content {
+		if !strutil.ListContains(globs, name) {
+			globs = append(globs, name)
+		}
+	}
+	if len(globs) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	_, _, err = osutil.EnsureDirStateGlobs(dir, globs, content)
+	return err
+}
+
+// dbusActivationFileSnap returns the name of the snap providing the given
+// activation file, or "" if it is not provided by a snap.
+func dbusActivationFileSnap(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, dbusActivationSnapKey) {
+			return strings.TrimPrefix(line, dbusActivationSnapKey), nil
+		}
+	}
+	return "", nil
+}
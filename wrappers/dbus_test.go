@@ -0,0 +1,131 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wrappers_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+type dbusTestSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&dbusTestSuite{})
+
+func (s *dbusTestSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.BaseTest.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *dbusTestSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+	s.BaseTest.TearDownTest(c)
+}
+
+const dbusActivatedSnapYaml = `name: foo
+version: 1
+apps:
+  system-svc:
+    daemon: simple
+    activates-on: [system-name]
+  user-svc:
+    daemon: simple
+    daemon-scope: user
+    activates-on: [session-name]
+slots:
+  system-name:
+    interface: dbus
+    bus: system
+    name: org.example.System
+  session-name:
+    interface: dbus
+    bus: session
+    name: org.example.Session
+`
+
+func (s *dbusTestSuite) TestAddSnapDBusActivationFiles(c *C) {
+	info := snaptest.MockInfo(c, dbusActivatedSnapYaml, &snap.SideInfo{Revision: snap.R(1)})
+	c.Assert(wrappers.AddSnapDBusActivationFiles(info), IsNil)
+
+	c.Check(filepath.Join(dirs.SnapDBusSessionServicesDir, "org.example.Session.service"), testutil.FileEquals, `[D-BUS Service]
+Name=org.example.Session
+Comment=Bus name for snap application foo.user-svc
+SystemdService=snap.foo.user-svc.service
+Exec=/bin/false
+AssumedAppArmorLabel=snap.foo.user-svc
+X-Snap=foo
+`)
+	c.Check(filepath.Join(dirs.SnapDBusSystemServicesDir, "org.example.System.service"), testutil.FileEquals, `[D-BUS Service]
+Name=org.example.System
+Comment=Bus name for snap application foo.system-svc
+SystemdService=snap.foo.system-svc.service
+Exec=/bin/false
+User=root
+AssumedAppArmorLabel=snap.foo.system-svc
+X-Snap=foo
+`)
+
+	// activation files that are no longer needed are removed
+	delete(info.Apps, "user-svc")
+	c.Assert(wrappers.AddSnapDBusActivationFiles(info), IsNil)
+	c.Check(filepath.Join(dirs.SnapDBusSessionServicesDir, "org.example.Session.service"), testutil.FileAbsent)
+	c.Check(filepath.Join(dirs.SnapDBusSystemServicesDir, "org.example.System.service"), testutil.FilePresent)
+
+	// and all of them are removed with the snap
+	c.Assert(wrappers.RemoveSnapDBusActivationFiles(info), IsNil)
+	c.Check(filepath.Join(dirs.SnapDBusSystemServicesDir, "org.example.System.service"), testutil.FileAbsent)
+}
+
+func (s *dbusTestSuite) TestAddSnapDBusActivationFilesNone(c *C) {
+	info := snaptest.MockInfo(c, packageHello, &snap.SideInfo{Revision: snap.R(11)})
+	c.Assert(wrappers.AddSnapDBusActivationFiles(info), IsNil)
+	c.Check(dirs.SnapDBusSessionServicesDir, testutil.FileAbsent)
+	c.Check(dirs.SnapDBusSystemServicesDir, testutil.FileAbsent)
+	c.Assert(wrappers.RemoveSnapDBusActivationFiles(info), IsNil)
+}
+
+func (s *dbusTestSuite) TestAddSnapDBusActivationFilesOfOtherSnap(c *C) {
+	c.Assert(os.MkdirAll(dirs.SnapDBusSystemServicesDir, 0755), IsNil)
+	other := filepath.Join(dirs.SnapDBusSystemServicesDir, "org.example.System.service")
+	c.Assert(ioutil.WriteFile(other, []byte("[D-BUS Service]\nX-Snap=bar\n"), 0644), IsNil)
+	unrelated := filepath.Join(dirs.SnapDBusSystemServicesDir, "org.example.Unrelated.service")
+	c.Assert(ioutil.WriteFile(unrelated, []byte("[D-BUS Service]\n"), 0644), IsNil)
+
+	info := snaptest.MockInfo(c, dbusActivatedSnapYaml, &snap.SideInfo{Revision: snap.R(1)})
+	err := wrappers.AddSnapDBusActivationFiles(info)
+	c.Assert(err, ErrorMatches, `cannot provide service activation file "org.example.System.service", it is provided by snap "bar"`)
+	// the files on the session bus are not left behind
+	c.Check(filepath.Join(dirs.SnapDBusSessionServicesDir, "org.example.Session.service"), testutil.FileAbsent)
+
+	c.Assert(wrappers.RemoveSnapDBusActivationFiles(info), IsNil)
+	c.Check(other, testutil.FilePresent)
+	c.Check(unrelated, testutil.FilePresent)
+}
@@ -29,6 +29,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
@@ -122,7 +123,11 @@ func rewriteExecLine(s *snap.Info, desktopFile, line string) (string, error) {
 		if cmd == validCmd {
 			return "Exec=" + env + wrapper, nil
 		} else if strings.HasPrefix(cmd, validCmd+" ") {
-			return fmt.Sprintf("Exec=%s%s%s", env, wrapper, line[len("Exec=")+len(validCmd):]), nil
+			args, err := sanitizeExecArgs(cmd[len(validCmd):])
+			if err != nil {
+				return "", fmt.Errorf("invalid exec command: %q: %v", cmd, err)
+			}
+			return fmt.Sprintf("Exec=%s%s%s", env, wrapper, args), nil
 		}
 	}
 
@@ -142,6 +147,47 @@ func rewriteExecLine(s *snap.Info, desktopFile, line string) (string, error) {
 	return "", fmt.Errorf("invalid exec command: %q", cmd)
 }
 
+// See the "The Exec key" section of the Desktop Entry Specification.
+var (
+	execFieldCodes           = "fFuUick%"
+	execDeprecatedFieldCodes = "dDnNvm"
+)
+
+// sanitizeExecArgs checks the field codes in the arguments of an Exec=
+// line, dropping the deprecated ones. At most one of the file or URL
+// field codes can be used. Such a field code is expected to be an argument
+// on its own but is also kept when embedded in an argument, as in
+// --url=%u, which desktop environments expand in place.
+func sanitizeExecArgs(args string) (string, error) {
+	var out strings.Builder
+	seenFileOrURL := false
+	for i := 0; i < len(args); i++ {
+		if args[i] != '%' {
+			out.WriteByte(args[i])
+			continue
+		}
+		if i+1 == len(args) {
+			return "", fmt.Errorf("incomplete field code at end of line")
+		}
+		i++
+		code := args[i]
+		switch {
+		case strings.IndexByte(execDeprecatedFieldCodes, code) >= 0:
+			continue
+		case strings.IndexByte(execFieldCodes, code) < 0:
+			return "", fmt.Errorf("unknown field code %%%c", code)
+		case strings.IndexByte("fFuU", code) >= 0:
+			if seenFileOrURL {
+				return "", fmt.Errorf("cannot use more than one file or URL field code")
+			}
+			seenFileOrURL = true
+		}
+		out.WriteByte('%')
+		out.WriteByte(code)
+	}
+	return strings.TrimRight(out.String(), " "), nil
+}
+
 func rewriteIconLine(s *snap.Info, line string) (string, error) {
 	icon := strings.SplitN(line, "=", 2)[1]
 
@@ -190,6 +236,7 @@ func sanitizeDesktopFile(s *snap.Info, desktopFile string, rawcontent []byte) []
 			line, err := rewriteExecLine(s, desktopFile, string(bline))
 			if err != nil {
 				// something went wrong, ignore the line
+				logger.Noticef("ignoring Exec line in source of desktop file %q: %v", filepath.Base(desktopFile), err)
 				continue
 			}
 			bline = []byte(line)
@@ -221,11 +268,63 @@ func sanitizeDesktopFile(s *snap.Info, desktopFile string, rawcontent []byte) []
 	return newContent.Bytes()
 }
 
+// desktopDatabase tracks the deferral of updates of the desktop
+// database, see DeferDesktopDatabaseUpdate.
+var desktopDatabase struct {
+	mu       sync.Mutex
+	deferred int
+	pending  bool
+}
+
+// DeferDesktopDatabaseUpdate postpones the update of the desktop
+// database (which includes the MIME type associations) that otherwise
+// happens whenever desktop files of a snap are added or removed,
+// until the returned function is called. If there were changes
+// meanwhile, the database is then updated once. This lets the
+// desktop files of many snaps be set up at the cost of a single
+// update.
+//
+// Deferrals can be nested, the database is updated once all of them
+// have been flushed.
+func DeferDesktopDatabaseUpdate() (flush func() error) {
+	desktopDatabase.mu.Lock()
+	defer desktopDatabase.mu.Unlock()
+	desktopDatabase.deferred++
+
+	flushed := false
+	return func() error {
+		desktopDatabase.mu.Lock()
+		defer desktopDatabase.mu.Unlock()
+		if flushed {
+			return nil
+		}
+		flushed = true
+		desktopDatabase.deferred--
+		if desktopDatabase.deferred > 0 || !desktopDatabase.pending {
+			return nil
+		}
+		desktopDatabase.pending = false
+		return UpdateDesktopDatabase()
+	}
+}
+
 func updateDesktopDatabase(desktopFiles []string) error {
 	if len(desktopFiles) == 0 {
 		return nil
 	}
 
+	desktopDatabase.mu.Lock()
+	defer desktopDatabase.mu.Unlock()
+	if desktopDatabase.deferred > 0 {
+		desktopDatabase.pending = true
+		return nil
+	}
+	return UpdateDesktopDatabase()
+}
+
+// UpdateDesktopDatabase updates the desktop database of the snap
+// desktop files right away, regardless of any deferral.
+func UpdateDesktopDatabase() error {
 	if _, err := exec.LookPath("update-desktop-database"); err == nil {
 		if output, err := exec.Command("update-desktop-database", dirs.SnapDesktopFilesDir).CombinedOutput(); err != nil {
 			return fmt.Errorf("cannot update-desktop-database %q: %s", output, err)
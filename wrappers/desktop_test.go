@@ -185,6 +185,51 @@ func (s *desktopSuite) TestAddPackageDesktopFilesCleanup(c *C) {
 	c.Check(osutil.FileExists(mockDesktopInstanceFilePath), Equals, true)
 }
 
+func (s *desktopSuite) TestDeferDesktopDatabaseUpdate(c *C) {
+	info := snaptest.MockSnap(c, desktopAppYaml, &snap.SideInfo{Revision: snap.R(11)})
+	guiDir := filepath.Join(info.MountDir(), "meta", "gui")
+	c.Assert(os.MkdirAll(guiDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(guiDir, "foobar.desktop"), mockDesktopFile, 0644), IsNil)
+
+	flush := wrappers.DeferDesktopDatabaseUpdate()
+	innerFlush := wrappers.DeferDesktopDatabaseUpdate()
+
+	c.Assert(wrappers.AddSnapDesktopFiles(info), IsNil)
+	c.Assert(wrappers.RemoveSnapDesktopFiles(info), IsNil)
+	c.Assert(wrappers.AddSnapDesktopFiles(info), IsNil)
+	c.Check(s.mockUpdateDesktopDatabase.Calls(), HasLen, 0)
+
+	// only the last flush updates
+	c.Assert(innerFlush(), IsNil)
+	c.Check(s.mockUpdateDesktopDatabase.Calls(), HasLen, 0)
+	// flushing again is harmless
+	c.Assert(innerFlush(), IsNil)
+	c.Assert(flush(), IsNil)
+	c.Check(s.mockUpdateDesktopDatabase.Calls(), DeepEquals, [][]string{
+		{"update-desktop-database", dirs.SnapDesktopFilesDir},
+	})
+
+	// no longer deferred
+	c.Assert(wrappers.RemoveSnapDesktopFiles(info), IsNil)
+	c.Check(s.mockUpdateDesktopDatabase.Calls(), HasLen, 2)
+}
+
+func (s *desktopSuite) TestDeferDesktopDatabaseUpdateNoChanges(c *C) {
+	flush := wrappers.DeferDesktopDatabaseUpdate()
+	c.Assert(flush(), IsNil)
+	c.Check(s.mockUpdateDesktopDatabase.Calls(), HasLen, 0)
+}
+
+func (s *desktopSuite) TestUpdateDesktopDatabaseNotDeferred(c *C) {
+	flush := wrappers.DeferDesktopDatabaseUpdate()
+	defer flush()
+
+	c.Assert(wrappers.UpdateDesktopDatabase(), IsNil)
+	c.Check(s.mockUpdateDesktopDatabase.Calls(), DeepEquals, [][]string{
+		{"update-desktop-database", dirs.SnapDesktopFilesDir},
+	})
+}
+
 // sanitize
 
 type sanitizeDesktopFileSuite struct {
@@ -454,6 +499,46 @@ apps:
 	c.Assert(newl, Equals, fmt.Sprintf("Exec=env BAMF_DESKTOP_FILE_HINT=foo.desktop %s/bin/snap.app", dirs.SnapMountDir))
 }
 
+func (s *sanitizeDesktopFileSuite) TestRewriteExecLineFieldCodes(c *C) {
+	snap, err := snap.InfoFromSnapYaml([]byte(`
+name: snap
+version: 1.0
+apps:
+ app:
+  command: cmd
+`))
+	c.Assert(err, IsNil)
+
+	wrapper := fmt.Sprintf("Exec=env BAMF_DESKTOP_FILE_HINT=foo.desktop %s/bin/snap.app", dirs.SnapMountDir)
+	for _, t := range []struct {
+		line string
+		exec string
+		err  string
+	}{
+		{"Exec=snap.app %U", wrapper + " %U", ""},
+		{"Exec=snap.app --new-window %f", wrapper + " --new-window %f", ""},
+		{"Exec=snap.app --icon %i --class %c %k %%", wrapper + " --icon %i --class %c %k %%", ""},
+		// deprecated field codes are dropped
+		{"Exec=snap.app %d %U", wrapper + "  %U", ""},
+		{"Exec=snap.app %U %m", wrapper + " %U", ""},
+		{"Exec=snap.app %x", "", `invalid exec command: "snap.app %x": unknown field code %x`},
+		{"Exec=snap.app %", "", `invalid exec command: "snap.app %": incomplete field code at end of line`},
+		{"Exec=snap.app %f %U", "", `invalid exec command: "snap.app %f %U": cannot use more than one file or URL field code`},
+		// file and URL field codes embedded in an argument are kept in place
+		{"Exec=snap.app --file=%f", wrapper + " --file=%f", ""},
+		{"Exec=snap.app --url=%u --new-window", wrapper + " --url=%u --new-window", ""},
+		{"Exec=snap.app --url=%u %F", "", `invalid exec command: "snap.app --url=%u %F": cannot use more than one file or URL field code`},
+	} {
+		newl, err := wrappers.RewriteExecLine(snap, "foo.desktop", t.line)
+		if t.err != "" {
+			c.Check(err, ErrorMatches, t.err, Commentf(t.line))
+			continue
+		}
+		c.Check(err, IsNil, Commentf(t.line))
+		c.Check(newl, Equals, t.exec, Commentf(t.line))
+	}
+}
+
 func (s *sanitizeDesktopFileSuite) TestLangLang(c *C) {
 	langs := []struct {
 		line    string
@@ -23,13 +23,30 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/snap"
 )
 
+// iconSizeDir matches the size directories of an icon theme, see the
+// Icon Theme Specification.
+var iconSizeDir = regexp.MustCompile(`^(?:[0-9]+x[0-9]+(?:@[0-9]+)?|scalable|symbolic)$`)
+
+// isIconThemePath checks that the given relative path of an icon is of
+// the form <theme>/<size>/<context>/<icon>, as icons elsewhere would not
+// be found by the desktop.
+func isIconThemePath(rel string) bool {
+	parts := strings.Split(rel, "/")
+	if len(parts) != 4 {
+		return false
+	}
+	return iconSizeDir.MatchString(parts[1])
+}
+
 func findIconFiles(snapName string, rootDir string) (icons []string, err error) {
 	if !osutil.IsDirectory(rootDir) {
 		return nil, nil
@@ -55,9 +72,14 @@ func findIconFiles(snapName string, rootDir string) (icons []string, err error)
 				return err
 			} else if ok {
 				ext := filepath.Ext(base)
-				if ext == ".png" || ext == ".svg" {
-					icons = append(icons, rel)
+				if ext != ".png" && ext != ".svg" {
+					return nil
+				}
+				if !isIconThemePath(rel) {
+					logger.Noticef("ignoring icon %q of snap %q: not in an icon theme directory", rel, snapName)
+					return nil
 				}
+				icons = append(icons, rel)
 			}
 		}
 		return nil
@@ -78,6 +78,35 @@ func (s *iconsTestSuite) TestFindIconFiles(c *C) {
 	})
 }
 
+func (s *iconsTestSuite) TestFindIconFilesThemeLayout(c *C) {
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(11)})
+
+	iconsDir := filepath.Join(info.MountDir(), "meta", "gui", "icons")
+	for _, p := range []string{
+		"hicolor/48x48/apps/snap.hello-snap.foo.png",
+		"hicolor/48x48@2/apps/snap.hello-snap.foo.png",
+		"hicolor/symbolic/apps/snap.hello-snap.foo.svg",
+		// not in theme directories
+		"snap.hello-snap.foo.png",
+		"hicolor/snap.hello-snap.foo.png",
+		"hicolor/apps/snap.hello-snap.foo.png",
+		"hicolor/big/apps/snap.hello-snap.foo.png",
+		"hicolor/48x48/apps/extra/snap.hello-snap.foo.png",
+	} {
+		c.Assert(os.MkdirAll(filepath.Dir(filepath.Join(iconsDir, p)), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(iconsDir, p), nil, 0644), IsNil)
+	}
+
+	icons, err := wrappers.FindIconFiles(info.SnapName(), iconsDir)
+	c.Assert(err, IsNil)
+	sort.Strings(icons)
+	c.Check(icons, DeepEquals, []string{
+		"hicolor/48x48/apps/snap.hello-snap.foo.png",
+		"hicolor/48x48@2/apps/snap.hello-snap.foo.png",
+		"hicolor/symbolic/apps/snap.hello-snap.foo.svg",
+	})
+}
+
 func (s *iconsTestSuite) TestAddSnapIcons(c *C) {
 	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(11)})
 
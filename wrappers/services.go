@@ -21,6 +21,7 @@ package wrappers
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"math/rand"
 	"os"
@@ -40,6 +41,7 @@ import (
 	"github.com/snapcore/snapd/timeout"
 	"github.com/snapcore/snapd/timeutil"
 	"github.com/snapcore/snapd/timings"
+	"github.com/snapcore/snapd/usersession/client"
 )
 
 type interacter interface {
@@ -57,6 +59,34 @@ func serviceStopTimeout(app *snap.AppInfo) time.Duration {
 	return time.Duration(tout)
 }
 
+// userServiceControlTimeout is how long the session agents of the
+// logged-in users get to act on their user services
+var userServiceControlTimeout = 5 * time.Minute
+
+// userServicesControl asks the session agents of the logged-in users
+// to act on the given user services. A failure in the session of a
+// user does not affect the services of the system and of the other
+// users, so it is only reported.
+func userServicesControl(inter interacter, action string, services []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), userServiceControlTimeout)
+	defer cancel()
+
+	cli := client.New()
+	var err error
+	switch action {
+	case "start":
+		err = cli.ServicesStart(ctx, services)
+	case "stop":
+		err = cli.ServicesStop(ctx, services)
+	case "daemon-reload":
+		err = cli.ServicesDaemonReload(ctx)
+	}
+	if err != nil {
+		logger.Noticef("%v", err)
+		inter.Notify(err.Error())
+	}
+}
+
 func generateSnapServiceFile(app *snap.AppInfo) ([]byte, error) {
 	if err := snap.ValidateApp(app); err != nil {
 		return nil, err
@@ -106,14 +136,45 @@ func stopService(sysd systemd.Systemd, app *snap.AppInfo, inter interacter) erro
 // caller.
 func StartServices(apps []*snap.AppInfo, inter interacter, tm timings.Measurer) (err error) {
 	sysd := systemd.New(dirs.GlobalRootDir, systemd.SystemMode, inter)
+	userGlobalSysd := systemd.New(dirs.GlobalRootDir, systemd.GlobalUserMode, inter)
 
 	services := make([]string, 0, len(apps))
+	var userServices []string
 	for _, app := range apps {
 		// they're *supposed* to be all services, but checking doesn't hurt
 		if !app.IsService() {
 			continue
 		}
 
+		if app.IsUserService() {
+			// user services are started in the sessions of the
+			// logged-in users, and by systemd when users log in
+			if app.Timer != nil {
+				timerService := filepath.Base(app.Timer.File())
+				defer func() {
+					if err == nil {
+						return
+					}
+					if e := userGlobalSysd.Disable(timerService); e != nil {
+						inter.Notify(fmt.Sprintf("While trying to disable previously enabled timer service %q: %v", timerService, e))
+					}
+				}()
+				if err := userGlobalSysd.Enable(timerService); err != nil {
+					return err
+				}
+				userServices = append(userServices, timerService)
+				continue
+			}
+			isEnabled, err := userGlobalSysd.IsEnabled(app.ServiceName())
+			if err != nil {
+				return err
+			}
+			if isEnabled {
+				userServices = append(userServices, app.ServiceName())
+			}
+			continue
+		}
+
 		defer func(app *snap.AppInfo) {
 			if err == nil {
 				return
@@ -197,6 +258,12 @@ func StartServices(apps []*snap.AppInfo, inter interacter, tm timings.Measurer)
 		}
 	}
 
+	if len(userServices) != 0 {
+		timings.Run(tm, "start-user-services", "start user services", func(nested timings.Measurer) {
+			userServicesControl(inter, "start", userServices)
+		})
+	}
+
 	return nil
 }
 
@@ -218,8 +285,9 @@ func AddSnapServices(s *snap.Info, disabledSvcs []string, inter interacter) (err
 	}
 
 	sysd := systemd.New(dirs.GlobalRootDir, systemd.SystemMode, inter)
-	var written []string
-	var enabled []string
+	userGlobalSysd := systemd.New(dirs.GlobalRootDir, systemd.GlobalUserMode, inter)
+	var written, userWritten []string
+	var enabled, userEnabled []string
 	defer func() {
 		if err == nil {
 			return
@@ -229,7 +297,12 @@ func AddSnapServices(s *snap.Info, disabledSvcs []string, inter interacter) (err
 				inter.Notify(fmt.Sprintf("while trying to disable %s due to previous failure: %v", s, e))
 			}
 		}
-		for _, s := range written {
+		for _, s := range userEnabled {
+			if e := userGlobalSysd.Disable(s); e != nil {
+				inter.Notify(fmt.Sprintf("while trying to disable %s due to previous failure: %v", s, e))
+			}
+		}
+		for _, s := range append(written, userWritten...) {
 			if e := os.Remove(s); e != nil {
 				inter.Notify(fmt.Sprintf("while trying to remove %s due to previous failure: %v", s, e))
 			}
@@ -239,12 +312,24 @@ func AddSnapServices(s *snap.Info, disabledSvcs []string, inter interacter) (err
 				inter.Notify(fmt.Sprintf("while trying to perform systemd daemon-reload due to previous failure: %v", e))
 			}
 		}
+		if len(userWritten) > 0 {
+			userServicesControl(inter, "daemon-reload", nil)
+		}
 	}()
 
 	for _, app := range s.Apps {
 		if !app.IsService() {
 			continue
 		}
+		appWritten := &written
+		appSysd := sysd
+		appEnabled := &enabled
+		if app.IsUserService() {
+			appWritten = &userWritten
+			appSysd = userGlobalSysd
+			appEnabled = &userEnabled
+		}
+
 		// Generate service file
 		content, err := generateSnapServiceFile(app)
 		if err != nil {
@@ -255,7 +340,7 @@ func AddSnapServices(s *snap.Info, disabledSvcs []string, inter interacter) (err
 		if err := osutil.AtomicWriteFile(svcFilePath, content, 0644, 0); err != nil {
 			return err
 		}
-		written = append(written, svcFilePath)
+		*appWritten = append(*appWritten, svcFilePath)
 
 		// Generate systemd .socket files if needed
 		socketFiles, err := generateSnapSocketFiles(app)
@@ -267,7 +352,7 @@ func AddSnapServices(s *snap.Info, disabledSvcs []string, inter interacter) (err
 			if err := osutil.AtomicWriteFile(path, content, 0644, 0); err != nil {
 				return err
 			}
-			written = append(written, path)
+			*appWritten = append(*appWritten, path)
 		}
 
 		if app.Timer != nil {
@@ -280,7 +365,7 @@ func AddSnapServices(s *snap.Info, disabledSvcs []string, inter interacter) (err
 			if err := osutil.AtomicWriteFile(path, content, 0644, 0); err != nil {
 				return err
 			}
-			written = append(written, path)
+			*appWritten = append(*appWritten, path)
 		}
 
 		if app.Timer != nil || len(app.Sockets) != 0 {
@@ -296,10 +381,10 @@ func AddSnapServices(s *snap.Info, disabledSvcs []string, inter interacter) (err
 			continue
 		}
 
-		if err := sysd.Enable(svcName); err != nil {
+		if err := appSysd.Enable(svcName); err != nil {
 			return err
 		}
-		enabled = append(enabled, svcName)
+		*appEnabled = append(*appEnabled, svcName)
 	}
 
 	if len(written) > 0 {
@@ -307,6 +392,9 @@ func AddSnapServices(s *snap.Info, disabledSvcs []string, inter interacter) (err
 			return err
 		}
 	}
+	if len(userWritten) > 0 {
+		userServicesControl(inter, "daemon-reload", nil)
+	}
 
 	return nil
 }
@@ -316,6 +404,7 @@ func StopServices(apps []*snap.AppInfo, reason snap.ServiceStopReason, inter int
 	sysd := systemd.New(dirs.GlobalRootDir, systemd.SystemMode, inter)
 
 	logger.Debugf("StopServices called for %q, reason: %v", apps, reason)
+	var userServices []string
 	for _, app := range apps {
 		// Handle the case where service file doesn't exist and don't try to stop it as it will fail.
 		// This can happen with snap try when snap.yaml is modified on the fly and a daemon line is added.
@@ -333,6 +422,14 @@ func StopServices(apps []*snap.AppInfo, reason snap.ServiceStopReason, inter int
 			}
 		}
 
+		if app.IsUserService() {
+			if app.Timer != nil {
+				userServices = append(userServices, filepath.Base(app.Timer.File()))
+			}
+			userServices = append(userServices, app.ServiceName())
+			continue
+		}
+
 		var err error
 		timings.Run(tm, "stop-service", fmt.Sprintf("stop service %q", app.ServiceName()), func(nested timings.Measurer) {
 			err = stopService(sysd, app, inter)
@@ -353,6 +450,12 @@ func StopServices(apps []*snap.AppInfo, reason snap.ServiceStopReason, inter int
 		}
 	}
 
+	if len(userServices) != 0 {
+		timings.Run(tm, "stop-user-services", "stop user services", func(nested timings.Measurer) {
+			userServicesControl(inter, "stop", userServices)
+		})
+	}
+
 	return nil
 }
 
@@ -360,6 +463,7 @@ func StopServices(apps []*snap.AppInfo, reason snap.ServiceStopReason, inter int
 // together with their enable/disable status.
 func ServicesEnableState(s *snap.Info, inter interacter) (map[string]bool, error) {
 	sysd := systemd.New(dirs.GlobalRootDir, systemd.SystemMode, inter)
+	userGlobalSysd := systemd.New(dirs.GlobalRootDir, systemd.GlobalUserMode, inter)
 
 	// loop over all services in the snap, querying systemd for the current
 	// systemd state of the snaps
@@ -368,7 +472,11 @@ func ServicesEnableState(s *snap.Info, inter interacter) (map[string]bool, error
 		if !app.IsService() {
 			continue
 		}
-		state, err := sysd.IsEnabled(app.ServiceName())
+		appSysd := sysd
+		if app.IsUserService() {
+			appSysd = userGlobalSysd
+		}
+		state, err := appSysd.IsEnabled(app.ServiceName())
 		if err != nil {
 			return nil, err
 		}
@@ -379,14 +487,22 @@ func ServicesEnableState(s *snap.Info, inter interacter) (map[string]bool, error
 
 // RemoveSnapServices disables and removes service units for the applications from the snap which are services.
 func RemoveSnapServices(s *snap.Info, inter interacter) error {
-	sysd := systemd.New(dirs.GlobalRootDir, systemd.SystemMode, inter)
+	systemSysd := systemd.New(dirs.GlobalRootDir, systemd.SystemMode, inter)
+	userGlobalSysd := systemd.New(dirs.GlobalRootDir, systemd.GlobalUserMode, inter)
 	nservices := 0
+	nuserServices := 0
 
 	for _, app := range s.Apps {
 		if !app.IsService() || !osutil.FileExists(app.ServiceFile()) {
 			continue
 		}
-		nservices++
+		sysd := systemSysd
+		if app.IsUserService() {
+			sysd = userGlobalSysd
+			nuserServices++
+		} else {
+			nservices++
+		}
 
 		serviceName := filepath.Base(app.ServiceFile())
 
@@ -427,10 +543,13 @@ func RemoveSnapServices(s *snap.Info, inter interacter) error {
 
 	// only reload if we actually had services
 	if nservices > 0 {
-		if err := sysd.DaemonReload(); err != nil {
+		if err := systemSysd.DaemonReload(); err != nil {
 			return err
 		}
 	}
+	if nuserServices > 0 {
+		userServicesControl(inter, "daemon-reload", nil)
+	}
 
 	return nil
 }
@@ -450,9 +569,13 @@ func genServiceFile(appInfo *snap.AppInfo) []byte {
 	serviceTemplate := `[Unit]
 # Auto-generated, DO NOT EDIT
 Description=Service for snap application {{.App.Snap.InstanceName}}.{{.App.Name}}
+{{- if .MountUnit}}
 Requires={{.MountUnit}}
 Wants={{.PrerequisiteTarget}}
 After={{.MountUnit}} {{.PrerequisiteTarget}}{{if .After}} {{ stringsJoin .After " " }}{{end}}
+{{- else if .After}}
+After={{ stringsJoin .After " " }}
+{{- end}}
 {{- if .Before}}
 Before={{ stringsJoin .Before " "}}
 {{- end}}
@@ -465,7 +588,7 @@ Restart={{.Restart}}
 {{- if .App.RestartDelay}}
 RestartSec={{.App.RestartDelay.Seconds}}
 {{- end}}
-WorkingDirectory={{.App.Snap.DataDir}}
+WorkingDirectory={{.WorkingDir}}
 {{- if .App.StopCommand}}
 ExecStop={{.App.LauncherStopCommand}}
 {{- end}}
@@ -539,6 +662,7 @@ WantedBy={{.ServicesTarget}}
 		ServicesTarget     string
 		PrerequisiteTarget string
 		MountUnit          string
+		WorkingDir         string
 		Remain             string
 		KillMode           string
 		KillSignal         string
@@ -550,15 +674,12 @@ WantedBy={{.ServicesTarget}}
 	}{
 		App: appInfo,
 
-		Restart:            restartCond,
-		StopTimeout:        serviceStopTimeout(appInfo),
-		StartTimeout:       time.Duration(appInfo.StartTimeout),
-		ServicesTarget:     systemd.ServicesTarget,
-		PrerequisiteTarget: systemd.PrerequisiteTarget,
-		MountUnit:          filepath.Base(systemd.MountUnitPath(appInfo.Snap.MountDir())),
-		Remain:             remain,
-		KillMode:           killMode,
-		KillSignal:         appInfo.StopMode.KillSignal(),
+		Restart:      restartCond,
+		StopTimeout:  serviceStopTimeout(appInfo),
+		StartTimeout: time.Duration(appInfo.StartTimeout),
+		Remain:       remain,
+		KillMode:     killMode,
+		KillSignal:   appInfo.StopMode.KillSignal(),
 
 		Before: genServiceNames(appInfo.Snap, appInfo.Before),
 		After:  genServiceNames(appInfo.Snap, appInfo.After),
@@ -566,6 +687,22 @@ WantedBy={{.ServicesTarget}}
 		// systemd runs as PID 1 so %h will not work.
 		Home: "/root",
 	}
+	if appInfo.DaemonScope == snap.UserDaemon {
+		// the mount units of the system are not visible to the
+		// systemd instances of the user sessions
+		wrapperData.ServicesTarget = systemd.UserServicesTarget
+		wrapperData.WorkingDir = appInfo.Snap.UserDataDir("%h")
+	} else {
+		wrapperData.ServicesTarget = systemd.ServicesTarget
+		wrapperData.PrerequisiteTarget = systemd.PrerequisiteTarget
+		wrapperData.MountUnit = filepath.Base(systemd.MountUnitPath(appInfo.Snap.MountDir()))
+		wrapperData.WorkingDir = appInfo.Snap.DataDir()
+		if len(appInfo.Snap.SystemUsernames) > 0 {
+			// the service may drop privileges to the system
+			// users the snap requested
+			wrapperData.After = append(wrapperData.After, systemd.UserLookupTarget)
+		}
+	}
 
 	if err := t.Execute(&templateOut, wrapperData); err != nil {
 		// this can never happen, except we forget a variable
@@ -666,13 +803,23 @@ WantedBy={{.TimersTarget}}
 	var templateOut bytes.Buffer
 	t := template.Must(template.New("timer-wrapper").Parse(timerTemplate))
 
-	timerSchedule, err := timeutil.ParseSchedule(app.Timer.Timer)
-	if err != nil {
-		return nil, err
+	var schedules []string
+	if app.Timer.UsesCalendarSyntax() {
+		events, err := timeutil.ParseCalendarEvents(app.Timer.Timer)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			schedules = append(schedules, event.String())
+		}
+	} else {
+		timerSchedule, err := timeutil.ParseSchedule(app.Timer.Timer)
+		if err != nil {
+			return nil, err
+		}
+		schedules = generateOnCalendarSchedules(timerSchedule)
 	}
 
-	schedules := generateOnCalendarSchedules(timerSchedule)
-
 	wrapperData := struct {
 		App             *snap.AppInfo
 		ServiceFileName string
@@ -132,6 +132,49 @@ apps:
 	c.Check(string(generatedWrapper), Equals, expectedAppService)
 }
 
+func (s *servicesWrapperGenSuite) TestGenerateSnapUserServiceFile(c *C) {
+	yamlText := `
+name: snap
+version: 1.0
+apps:
+    app:
+        command: bin/start
+        stop-command: bin/stop
+        daemon: simple
+        daemon-scope: user
+        after: [other]
+    other:
+        command: bin/other
+        daemon: simple
+        daemon-scope: user
+`
+	info, err := snap.InfoFromSnapYaml([]byte(yamlText))
+	c.Assert(err, IsNil)
+	info.Revision = snap.R(44)
+	app := info.Apps["app"]
+
+	generatedWrapper, err := wrappers.GenerateSnapServiceFile(app)
+	c.Assert(err, IsNil)
+	c.Check(string(generatedWrapper), Equals, `[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Service for snap application snap.app
+After=snap.snap.other.service
+X-Snappy=yes
+
+[Service]
+ExecStart=/usr/bin/snap run snap.app
+SyslogIdentifier=snap.app
+Restart=on-failure
+WorkingDirectory=%h/snap/snap/44
+ExecStop=/usr/bin/snap run --command=stop snap.app
+TimeoutStopSec=30
+Type=simple
+
+[Install]
+WantedBy=default.target
+`)
+}
+
 func (s *servicesWrapperGenSuite) TestGenerateSnapServiceFileWithStartTimeout(c *C) {
 	yamlText := `
 name: snap
@@ -152,6 +195,27 @@ apps:
 	c.Check(string(generatedWrapper), testutil.Contains, "\nTimeoutStartSec=600\n")
 }
 
+func (s *servicesWrapperGenSuite) TestGenerateSnapServiceFileWithSystemUsernames(c *C) {
+	yamlText := `
+name: snap
+version: 1.0
+system-usernames:
+    snap_daemon: shared
+apps:
+    app:
+        command: bin/start
+        daemon: simple
+`
+	info, err := snap.InfoFromSnapYaml([]byte(yamlText))
+	c.Assert(err, IsNil)
+	info.Revision = snap.R(44)
+	app := info.Apps["app"]
+
+	generatedWrapper, err := wrappers.GenerateSnapServiceFile(app)
+	c.Assert(err, IsNil)
+	c.Check(string(generatedWrapper), testutil.Contains, fmt.Sprintf("\nAfter=%s-snap-44.mount network.target nss-user-lookup.target\n", mountUnitPrefix))
+}
+
 func (s *servicesWrapperGenSuite) TestGenerateSnapServiceFileRestart(c *C) {
 	yamlTextTemplate := `
 name: snap
@@ -477,6 +541,49 @@ WantedBy=timers.target
 	c.Assert(string(generatedWrapper), Equals, expectedService)
 }
 
+func (s *servicesWrapperGenSuite) TestServiceTimerUnitCalendarSyntax(c *C) {
+	const expectedServiceFmt = `[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Timer app for snap application snap.app
+Requires=%s-snap-44.mount
+After=%s-snap-44.mount
+X-Snappy=yes
+
+[Timer]
+Unit=snap.snap.app.service
+OnCalendar=Mon,Tue,Wed,Thu,Fri *-*-* 08,10,12:00/30:00 UTC
+OnCalendar=*-*-01 00:00:00
+
+[Install]
+WantedBy=timers.target
+`
+
+	expectedService := fmt.Sprintf(expectedServiceFmt, mountUnitPrefix, mountUnitPrefix)
+	service := &snap.AppInfo{
+		Snap: &snap.Info{
+			SuggestedName: "snap",
+			Version:       "0.3.4",
+			SideInfo:      snap.SideInfo{Revision: snap.R(44)},
+		},
+		Name:        "app",
+		Command:     "bin/foo start",
+		Daemon:      "simple",
+		StopTimeout: timeout.DefaultTimeout,
+		Timer: &snap.TimerInfo{
+			Timer: "Mon..Fri 8..12/2:0/30 UTC;monthly",
+		},
+	}
+	service.Timer.App = service
+
+	generatedWrapper, err := wrappers.GenerateSnapTimerFile(service)
+	c.Assert(err, IsNil)
+	c.Check(string(generatedWrapper), Equals, expectedService)
+
+	service.Timer.Timer = "Mon..Fri 25:00"
+	_, err = wrappers.GenerateSnapTimerFile(service)
+	c.Check(err, ErrorMatches, `cannot parse calendar event "Mon..Fri 25:00": invalid hour "25"`)
+}
+
 func (s *servicesWrapperGenSuite) TestServiceTimerUnitBadTimer(c *C) {
 	service := &snap.AppInfo{
 		Snap: &snap.Info{
@@ -22,10 +22,14 @@ package wrappers_test
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	. "gopkg.in/check.v1"
@@ -114,6 +118,91 @@ func (s *servicesTestSuite) TestAddSnapServicesAndRemove(c *C) {
 	c.Check(s.sysdLog[1], DeepEquals, []string{"daemon-reload"})
 }
 
+func (s *servicesTestSuite) startSessionAgent(c *C, uid string) (requests *[]string, stop func()) {
+	dir := filepath.Join(dirs.XdgRuntimeDirBase, uid)
+	c.Assert(os.MkdirAll(dir, 0700), IsNil)
+	l, err := net.Listen("unix", filepath.Join(dir, "snapd-session-agent.socket"))
+	c.Assert(err, IsNil)
+
+	var mu sync.Mutex
+	requests = &[]string{}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		c.Check(err, IsNil)
+		mu.Lock()
+		*requests = append(*requests, string(body))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"type": "sync", "result": null}`))
+	})}
+	go srv.Serve(l)
+	return requests, func() { srv.Close() }
+}
+
+func (s *servicesTestSuite) TestAddSnapUserServicesAndRemove(c *C) {
+	requests, stop := s.startSessionAgent(c, "42")
+	defer stop()
+
+	info := snaptest.MockSnap(c, `name: hello-snap
+version: 1.0
+apps:
+ svc1:
+  command: bin/hello
+  daemon: simple
+  daemon-scope: user
+ svc2:
+  command: bin/hello
+  daemon: oneshot
+  daemon-scope: user
+  timer: 10:00-12:00
+`, &snap.SideInfo{Revision: snap.R(12)})
+	svcFile := filepath.Join(s.tempdir, "/etc/systemd/user/snap.hello-snap.svc1.service")
+	timerFile := filepath.Join(s.tempdir, "/etc/systemd/user/snap.hello-snap.svc2.timer")
+
+	err := wrappers.AddSnapServices(info, nil, progress.Null)
+	c.Assert(err, IsNil)
+	// the user services are enabled for all users, no system
+	// daemon-reload is needed
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"--user", "--global", "--root", dirs.GlobalRootDir, "enable", filepath.Base(svcFile)},
+	})
+	c.Check(svcFile, testutil.FileContains, "\nWantedBy=default.target\n")
+	c.Check(timerFile, testutil.FilePresent)
+	c.Check(*requests, DeepEquals, []string{`{"action":"daemon-reload","services":null}`})
+
+	apps := []*snap.AppInfo{info.Apps["svc1"], info.Apps["svc2"]}
+	s.sysdLog = nil
+	*requests = nil
+	err = wrappers.StartServices(apps, progress.Null, s.perfTimings)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"--user", "--global", "--root", dirs.GlobalRootDir, "is-enabled", "snap.hello-snap.svc1.service"},
+		{"--user", "--global", "--root", dirs.GlobalRootDir, "enable", "snap.hello-snap.svc2.timer"},
+	})
+	c.Check(*requests, DeepEquals, []string{`{"action":"start","services":["snap.hello-snap.svc1.service","snap.hello-snap.svc2.timer"]}`})
+
+	s.sysdLog = nil
+	*requests = nil
+	err = wrappers.StopServices(apps, "", progress.Null, s.perfTimings)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, HasLen, 0)
+	c.Check(*requests, DeepEquals, []string{`{"action":"stop","services":["snap.hello-snap.svc1.service","snap.hello-snap.svc2.timer","snap.hello-snap.svc2.service"]}`})
+
+	s.sysdLog = nil
+	*requests = nil
+	err = wrappers.RemoveSnapServices(info, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(svcFile), Equals, false)
+	c.Check(osutil.FileExists(timerFile), Equals, false)
+	sort.Slice(s.sysdLog, func(i, j int) bool { return s.sysdLog[i][5] < s.sysdLog[j][5] })
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"--user", "--global", "--root", dirs.GlobalRootDir, "disable", "snap.hello-snap.svc1.service"},
+		{"--user", "--global", "--root", dirs.GlobalRootDir, "disable", "snap.hello-snap.svc2.service"},
+		{"--user", "--global", "--root", dirs.GlobalRootDir, "disable", "snap.hello-snap.svc2.timer"},
+	})
+	c.Check(*requests, DeepEquals, []string{`{"action":"daemon-reload","services":null}`})
+}
+
 var snapdYaml = `name: snapd
 version: 1.0
 type: snapd